@@ -1,8 +1,10 @@
 package apidemo
 
 import (
+	"compress/zlib"
 	"fmt"
 	"io"
+	"mime"
 	"os"
 	"path/filepath"
 	"strings"
@@ -50,19 +52,16 @@ func AddAttachments(doc *model.Document, enc *model.Encrypt, w io.Writer, files
 			return fmt.Errorf("can't read file : %w", err)
 		}
 
-		var emb model.EmbeddedFileStream
-		emb.Params.SetChecksumAndSize(content)
-		emb.Params.ModDate = fi.ModTime()
+		// the MIME type is optional
+		mimeType, _, _ := mime.ParseMediaType(mime.TypeByExtension(filepath.Ext(fileName)))
 
 		// compression with flate, optional
-		emb.Stream = model.NewCompressedStream(content)
-		if err != nil {
-			return fmt.Errorf("can't compress file : %w", err)
-		}
+		emb := model.NewEmbeddedFileStream(content, mimeType, zlib.BestSpeed)
+		emb.Params.ModDate = fi.ModTime()
 
 		fs := model.FileSpec{
 			UF:   filepath.Base(fileName),
-			EF:   &emb,
+			EF:   emb,
 			Desc: desc,
 		}
 
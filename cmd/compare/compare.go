@@ -0,0 +1,41 @@
+// This script prints the differences between two PDF files,
+// at the level of the object model (see the package compare).
+// It exits with status 1 if the files differ.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/benoitkugler/pdf/compare"
+	"github.com/benoitkugler/pdf/reader"
+)
+
+func main() {
+	var opts compare.Options
+	flag.BoolVar(&opts.IgnoreContent, "ignore-content", false, "do not compare the content of the pages")
+	flag.BoolVar(&opts.IgnoreDates, "ignore-dates", false, "do not compare the creation and modification dates")
+	flag.Parse()
+	if flag.NArg() < 2 {
+		log.Fatal("usage: compare [options] old.pdf new.pdf")
+	}
+
+	old, _, err := reader.ParsePDFFile(flag.Arg(0), reader.Options{})
+	if err != nil {
+		log.Fatalf("reading %s: %s", flag.Arg(0), err)
+	}
+	new, _, err := reader.ParsePDFFile(flag.Arg(1), reader.Options{})
+	if err != nil {
+		log.Fatalf("reading %s: %s", flag.Arg(1), err)
+	}
+
+	diffs := compare.Documents(&old, &new, opts)
+	for _, diff := range diffs {
+		fmt.Println(diff)
+	}
+	if len(diffs) != 0 {
+		os.Exit(1)
+	}
+}
@@ -1,31 +1,100 @@
 // This script decodes the streams of a PDF file.
+//
+// By default, the content streams of the pages are decoded and
+// the file is written back, which is useful to read the contents
+// with a text editor. Flags are provided to inspect a file instead:
+//
+//	decompress -object 12 -pretty file.pdf  # dump one object
+//	decompress -fonts file.pdf              # list the fonts
+//	decompress -images file.pdf             # list the images
+//	decompress -page 3 file.pdf             # decode the contents of the third page
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf8"
 
 	"github.com/benoitkugler/pdf/model"
 	"github.com/benoitkugler/pdf/reader"
+	"github.com/benoitkugler/pdf/reader/file"
+	"github.com/benoitkugler/pdf/reader/parser"
 )
 
+// decodeBuffers stores the buffers used to decode the
+// streams which are only printed
+var decodeBuffers model.BufferPool
+
 func main() {
-	if len(os.Args) < 2 {
+	object := flag.Int("object", -1, "print the object with the given number, with its decoded stream content")
+	pretty := flag.Bool("pretty", false, "indent the dictionaries printed with -object")
+	listFonts := flag.Bool("fonts", false, "list the fonts used by the pages")
+	listImages := flag.Bool("images", false, "list the images used by the pages")
+	page := flag.Int("page", 0, "print the decoded content of the given page (starting at 1)")
+	output := flag.String("o", "", "output file when decoding the whole file (default to <input>.decoded.pdf)")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: decompress [flags] <file.pdf>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 1 {
 		log.Fatal("missing input file")
 	}
-	filePath := os.Args[1]
+	filePath := flag.Arg(0)
+
+	if *object >= 0 {
+		if err := dumpObject(filePath, *object, *pretty); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
 	fi, _, err := reader.ParsePDFFile(filePath, reader.Options{})
 	if err != nil {
 		log.Fatalf("reading input: %s", err)
 	}
 
+	switch {
+	case *listFonts:
+		printFonts(fi)
+	case *listImages:
+		printImages(fi)
+	case *page != 0:
+		pages := fi.Catalog.Pages.Flatten()
+		if *page < 1 || *page > len(pages) {
+			log.Fatalf("invalid page %d (the document has %d pages)", *page, len(pages))
+		}
+		content, err := pages[*page-1].DecodeAllContentsTo(decodeBuffers.Get())
+		if err != nil {
+			log.Fatal(err)
+		}
+		os.Stdout.Write(content)
+		decodeBuffers.Put(content)
+	default:
+		if *output == "" {
+			*output = filePath + ".decoded.pdf"
+		}
+		if err := decodeContents(fi, *output); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("Written in", *output)
+	}
+}
+
+// decodeContents removes the filters of the page contents
+// and writes the document into `output`
+func decodeContents(fi model.Document, output string) error {
 	for _, page := range fi.Catalog.Pages.Flatten() {
 		for i, ct := range page.Contents {
 			decoded, err := ct.Decode()
 			if err != nil {
-				log.Fatal(err)
+				return err
 			}
 			page.Contents[i] = model.ContentStream{
 				Stream: model.Stream{
@@ -35,11 +104,257 @@ func main() {
 			}
 		}
 	}
+	return fi.WriteFile(output, nil)
+}
+
+// dumpObject prints the object `number`, as found in the file
+func dumpObject(filePath string, number int, pretty bool) error {
+	ctx, err := file.ReadFile(filePath, nil)
+	if err != nil {
+		return fmt.Errorf("reading input: %s", err)
+	}
+	obj, ok := ctx.XrefTable[number]
+	if !ok {
+		return fmt.Errorf("object %d not found", number)
+	}
+	fmt.Printf("%d 0 obj\n%s\n", number, formatObject(obj, pretty, ""))
+	if stream, ok := obj.(model.ObjStream); ok {
+		fmt.Println(formatStream(ctx.XrefTable, stream))
+	}
+	fmt.Println("endobj")
+	return nil
+}
+
+// formatObject returns the PDF representation of `obj`, with sorted keys.
+// If `pretty` is true, dictionaries are written on several lines, indented.
+func formatObject(obj model.Object, pretty bool, indent string) string {
+	switch obj := obj.(type) {
+	case model.ObjStream:
+		return formatObject(obj.Args, pretty, indent)
+	case model.ObjDict:
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, string(k))
+		}
+		sort.Strings(keys)
+		if !pretty {
+			chunks := make([]string, len(keys))
+			for i, k := range keys {
+				chunks[i] = model.Name(k).String() + " " + formatObject(obj[model.Name(k)], false, "")
+			}
+			return "<<" + strings.Join(chunks, " ") + ">>"
+		}
+		var b strings.Builder
+		b.WriteString("<<\n")
+		for _, k := range keys {
+			fmt.Fprintf(&b, "%s  %s %s\n", indent, model.Name(k), formatObject(obj[model.Name(k)], true, indent+"  "))
+		}
+		b.WriteString(indent + ">>")
+		return b.String()
+	case model.ObjArray:
+		chunks := make([]string, len(obj))
+		for i, o := range obj {
+			chunks[i] = formatObject(o, pretty, indent)
+		}
+		return "[" + strings.Join(chunks, " ") + "]"
+	case nil:
+		return "null"
+	default:
+		return obj.Write(nil, 0)
+	}
+}
 
-	output := filePath + ".decoded.pdf"
-	err = fi.WriteFile(output, nil)
+// formatStream returns the decoded content of `stream`,
+// or a summary if it is not decodable or not textual
+func formatStream(xref file.XrefTable, stream model.ObjStream) string {
+	filters, err := parser.ParseFilters(stream.Args["Filter"], stream.Args["DecodeParms"], func(o parser.Object) (parser.Object, error) {
+		return xref.ResolveObject(o), nil
+	})
+	if err != nil {
+		return fmt.Sprintf("%% invalid filters: %s", err)
+	}
+	content, err := model.Stream{Filter: filters, Content: stream.Content}.DecodeTo(decodeBuffers.Get())
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Sprintf("%% can't decode the stream (%d bytes): %s", len(stream.Content), err)
+	}
+	defer decodeBuffers.Put(content)
+	if !isText(content) {
+		return fmt.Sprintf("%% binary stream: %d bytes, %d bytes decoded", len(stream.Content), len(content))
+	}
+	return "stream\n" + string(content) + "\nendstream"
+}
+
+// isText returns true for valid UTF-8 content without control characters
+// (except whitespaces)
+func isText(content []byte) bool {
+	if !utf8.Valid(content) {
+		return false
+	}
+	for _, r := range string(content) {
+		if r < 0x20 && r != '\n' && r != '\r' && r != '\t' && r != '\f' {
+			return false
+		}
+	}
+	return true
+}
+
+// resourceWalker visits the resources of the pages, including
+// the resources of the forms, and reports each resource once.
+type resourceWalker struct {
+	seenResources map[interface{}]bool
+
+	onFont  func(page int, name model.Name, font *model.FontDict)
+	onImage func(page int, name model.Name, img *model.XObjectImage)
+}
+
+func (w *resourceWalker) walk(doc model.Document) {
+	w.seenResources = make(map[interface{}]bool)
+	for i, page := range doc.Catalog.Pages.FlattenInherit() {
+		if page.Resources != nil {
+			w.walkResources(i+1, *page.Resources)
+		}
+	}
+}
+
+func (w *resourceWalker) walkResources(page int, res model.ResourcesDict) {
+	for _, name := range sortedNames(res.Font) {
+		font := res.Font[name]
+		if w.seenResources[font] {
+			continue
+		}
+		w.seenResources[font] = true
+		if w.onFont != nil {
+			w.onFont(page, name, font)
+		}
+		if t3, ok := font.Subtype.(model.FontType3); ok {
+			w.walkResources(page, t3.Resources)
+		}
+	}
+	for _, name := range sortedNames(res.XObject) {
+		xo := res.XObject[name]
+		if w.seenResources[xo] {
+			continue
+		}
+		w.seenResources[xo] = true
+		switch xo := xo.(type) {
+		case *model.XObjectImage:
+			if w.onImage != nil {
+				w.onImage(page, name, xo)
+			}
+		case *model.XObjectForm:
+			w.walkResources(page, xo.Resources)
+		case *model.XObjectTransparencyGroup:
+			w.walkResources(page, xo.Resources)
+		}
+	}
+}
+
+// sortedNames returns the keys of `m`, which must be a map with Name keys
+func sortedNames(m interface{}) []model.Name {
+	var out []model.Name
+	switch m := m.(type) {
+	case map[model.Name]*model.FontDict:
+		for name := range m {
+			out = append(out, name)
+		}
+	case map[model.Name]model.XObject:
+		for name := range m {
+			out = append(out, name)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+func printFonts(doc model.Document) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PAGE\tNAME\tTYPE\tBASE FONT\tEMBEDDED\tENCODING\tTO UNICODE")
+	w := resourceWalker{onFont: func(page int, name model.Name, font *model.FontDict) {
+		kind, embedded, encoding := describeFont(font.Subtype)
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%v\n", page, name, kind, string(font.Subtype.FontName()), embedded, encoding, font.ToUnicode != nil)
+	}}
+	w.walk(doc)
+	tw.Flush()
+}
+
+// embeddedFile returns the kind of the embedded font file, or "no"
+func embeddedFile(desc model.FontDescriptor, implicit string) string {
+	if desc.FontFile == nil {
+		return "no"
+	}
+	if desc.FontFile.Subtype != "" {
+		return string(desc.FontFile.Subtype)
+	}
+	return implicit
+}
+
+func describeSimpleEncoding(enc model.SimpleEncoding) string {
+	switch enc := enc.(type) {
+	case model.SimpleEncodingPredefined:
+		return string(enc)
+	case *model.SimpleEncodingDict:
+		if enc.BaseEncoding != "" {
+			return fmt.Sprintf("%s with differences", enc.BaseEncoding)
+		}
+		return "differences"
+	default:
+		return "builtin"
+	}
+}
+
+func describeFont(font model.Font) (kind, embedded, encoding string) {
+	switch font := font.(type) {
+	case model.FontType1:
+		return "Type1", embeddedFile(font.FontDescriptor, "Type1"), describeSimpleEncoding(font.Encoding)
+	case model.FontTrueType:
+		return "TrueType", embeddedFile(font.FontDescriptor, "TrueType"), describeSimpleEncoding(font.Encoding)
+	case model.FontType3:
+		return "Type3", "glyph procedures", describeSimpleEncoding(font.Encoding)
+	case model.FontType0:
+		implicit := "Type1"
+		if font.DescendantFonts.Subtype == "CIDFontType2" {
+			implicit = "TrueType"
+		}
+		encoding = "embedded CMap"
+		if enc, ok := font.Encoding.(model.CMapEncodingPredefined); ok {
+			encoding = string(enc)
+		}
+		return "Type0/" + string(font.DescendantFonts.Subtype), embeddedFile(font.DescendantFonts.FontDescriptor, implicit), encoding
+	default:
+		return fmt.Sprintf("%T", font), "", ""
+	}
+}
+
+func printImages(doc model.Document) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PAGE\tNAME\tWIDTH\tHEIGHT\tBPC\tCOLOR SPACE\tFILTERS\tSIZE\tSMASK")
+	w := resourceWalker{onImage: func(page int, name model.Name, img *model.XObjectImage) {
+		filters := make([]string, len(img.Filter))
+		for i, f := range img.Filter {
+			filters[i] = string(f.Name)
+		}
+		space := describeColorSpace(img.ColorSpace)
+		if img.ImageMask {
+			space = "mask"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%d\t%d\t%s\t%s\t%d\t%v\n", page, name, img.Width, img.Height, img.BitsPerComponent,
+			space, strings.Join(filters, ","), len(img.Content), img.SMask != nil)
+	}}
+	w.walk(doc)
+	tw.Flush()
+}
+
+func describeColorSpace(cs model.ColorSpace) string {
+	switch cs := cs.(type) {
+	case nil:
+		return "-"
+	case model.ColorSpaceName:
+		return string(cs)
+	case *model.ColorSpaceICCBased:
+		return fmt.Sprintf("ICCBased(%d)", cs.N)
+	case model.ColorSpaceIndexed:
+		return fmt.Sprintf("Indexed(%s)", describeColorSpace(cs.Base))
+	default:
+		return strings.TrimPrefix(fmt.Sprintf("%T", cs), "model.ColorSpace")
 	}
-	fmt.Println("Written in", output)
 }
@@ -0,0 +1,251 @@
+// This script prints information about a PDF file, in the spirit of pdfinfo:
+// version, encryption, metadata, page sizes, form fields and fonts.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/benoitkugler/pdf/model"
+	"github.com/benoitkugler/pdf/reader"
+)
+
+func main() {
+	password := flag.String("password", "", "user password, for encrypted files")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: info [flags] <file.pdf>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 1 {
+		log.Fatal("missing input file")
+	}
+	filePath := flag.Arg(0)
+
+	version, err := headerVersion(filePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	doc, enc, err := reader.ParsePDFFile(filePath, reader.Options{UserPassword: *password})
+	if err != nil {
+		log.Fatalf("reading input: %s", err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 1, ' ', 0)
+	printEntry := func(key, value string) {
+		if value != "" {
+			fmt.Fprintf(tw, "%s:\t%s\n", key, value)
+		}
+	}
+	info := doc.Trailer.Info
+	printEntry("Title", info.Title)
+	printEntry("Subject", info.Subject)
+	printEntry("Keywords", info.Keywords)
+	printEntry("Author", info.Author)
+	printEntry("Creator", info.Creator)
+	printEntry("Producer", info.Producer)
+	printEntry("CreationDate", formatDate(info.CreationDate))
+	printEntry("ModDate", formatDate(info.ModDate))
+	printEntry("Metadata", metadataSummary(doc.Catalog.Metadata))
+	printEntry("Tagged", yesNo(doc.Catalog.MarkInfo != nil && doc.Catalog.MarkInfo.Marked))
+	printEntry("Form", formSummary(doc.Catalog.AcroForm))
+	printEntry("JavaScript", yesNo(len(doc.Scripts()) != 0))
+	printEntry("Encrypted", encryptionSummary(enc))
+	printEntry("PDF version", version)
+
+	pages := doc.Catalog.Pages.FlattenInherit()
+	printEntry("Pages", fmt.Sprint(len(pages)))
+	for i, page := range pages {
+		printEntry(fmt.Sprintf("Page %4d size", i+1), pageSize(page))
+	}
+	tw.Flush()
+
+	if fields := doc.Catalog.AcroForm.Flatten(); len(fields) != 0 {
+		fmt.Println("\nForm fields:")
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		tw = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tTYPE\tFLAGS")
+		for _, name := range names {
+			field := fields[name].Merged
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", name, field.Kind(), fieldFlags(field.Ff))
+		}
+		tw.Flush()
+	}
+
+	if fonts := doc.Fonts(); len(fonts) != 0 {
+		fmt.Println("\nFonts:")
+		tw = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tTYPE\tEMBEDDED\tSUBSET\tTO UNICODE")
+		for _, font := range fonts {
+			name := string(font.Subtype.FontName())
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", name, fontType(font.Subtype),
+				yesNo(font.IsEmbedded()), yesNo(isSubset(name)), yesNo(font.ToUnicode != nil))
+		}
+		tw.Flush()
+	}
+}
+
+// headerVersion returns the version found in the %PDF- header
+func headerVersion(filePath string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	// the header may be preceded by garbage bytes
+	sc := bufio.NewScanner(f)
+	for i := 0; sc.Scan() && i < 10; i++ {
+		if index := strings.Index(sc.Text(), "%PDF-"); index != -1 {
+			version := sc.Text()[index+5:]
+			// the header line may be followed by binary characters
+			end := strings.IndexFunc(version, func(r rune) bool { return r != '.' && (r < '0' || r > '9') })
+			if end != -1 {
+				version = version[:end]
+			}
+			return version, nil
+		}
+	}
+	return "", fmt.Errorf("missing PDF header in %s", filePath)
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC1123Z)
+}
+
+func metadataSummary(m *model.MetadataStream) string {
+	if m == nil {
+		return "no"
+	}
+	return fmt.Sprintf("yes (XMP, %d bytes)", len(m.Content))
+}
+
+func formSummary(form model.AcroForm) string {
+	switch {
+	case form.NeedAppearances:
+		return "AcroForm (needs appearances)"
+	case len(form.Fields) != 0:
+		return "AcroForm"
+	default:
+		return "none"
+	}
+}
+
+func encryptionSummary(enc *model.Encrypt) string {
+	if enc == nil {
+		return "no"
+	}
+	return fmt.Sprintf("yes (filter %s, algorithm %d, %d bits, permissions %d)", enc.Filter, enc.V, int(enc.Length)*8, enc.P)
+}
+
+// pageSize returns the size of the visible area of the page,
+// taking into account its rotation
+func pageSize(page model.PageObject) string {
+	box := page.CropBox
+	if box == nil {
+		box = page.MediaBox
+	}
+	if box == nil {
+		return "unknown"
+	}
+	width, height := box.Width(), box.Height()
+	if page.Rotate.Degrees()%180 != 0 {
+		width, height = height, width
+	}
+	out := fmt.Sprintf("%g x %g pts", width, height)
+	if format := paperFormat(width, height); format != "" {
+		out += " (" + format + ")"
+	}
+	if rot := page.Rotate.Degrees(); rot != 0 {
+		out += fmt.Sprintf(", rotated %d degrees", rot)
+	}
+	return out
+}
+
+// paperFormat returns the name of common paper formats,
+// or an empty string
+func paperFormat(width, height model.Fl) string {
+	formats := []struct {
+		name string
+		w, h model.Fl
+	}{
+		{"A3", 842, 1191},
+		{"A4", 595, 842},
+		{"A5", 420, 595},
+		{"letter", 612, 792},
+		{"legal", 612, 1008},
+	}
+	near := func(a, b model.Fl) bool { return a-b < 1 && b-a < 1 }
+	for _, f := range formats {
+		if near(width, f.w) && near(height, f.h) || near(width, f.h) && near(height, f.w) {
+			return f.name
+		}
+	}
+	return ""
+}
+
+func fieldFlags(ff model.FormFlag) string {
+	var out []string
+	if ff&model.ReadOnly != 0 {
+		out = append(out, "read-only")
+	}
+	if ff&model.Required != 0 {
+		out = append(out, "required")
+	}
+	if ff&model.NoExport != 0 {
+		out = append(out, "no export")
+	}
+	return strings.Join(out, ", ")
+}
+
+func fontType(font model.Font) string {
+	switch font := font.(type) {
+	case model.FontType1:
+		if font.FontDescriptor.FontFile != nil && font.FontDescriptor.FontFile.Subtype == "Type1C" {
+			return "Type1C"
+		}
+		return "Type1"
+	case model.FontTrueType:
+		return "TrueType"
+	case model.FontType3:
+		return "Type3"
+	case model.FontType0:
+		return "CID " + strings.TrimPrefix(string(font.DescendantFonts.Subtype), "CIDFont")
+	default:
+		return fmt.Sprintf("%T", font)
+	}
+}
+
+// isSubset returns true for names with a six
+// uppercase letters tag, such as ABCDEF+Helvetica
+func isSubset(name string) bool {
+	if len(name) < 7 || name[6] != '+' {
+		return false
+	}
+	for _, c := range name[:6] {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
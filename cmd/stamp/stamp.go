@@ -0,0 +1,178 @@
+// This script stamps a text or the page of another PDF file
+// onto the pages of a PDF file, for instance:
+//
+//	stamp in.pdf --text "CONFIDENTIAL" --opacity 0.2 --diagonal
+//	stamp in.pdf --overlay letterhead.pdf --under
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/color"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/benoitkugler/pdf/fonts"
+	"github.com/benoitkugler/pdf/fonts/standardfonts"
+	"github.com/benoitkugler/pdf/model"
+	"github.com/benoitkugler/pdf/reader"
+	"github.com/benoitkugler/pdf/watermark"
+)
+
+var positions = map[string]watermark.Position{
+	"center":       watermark.Center,
+	"top":          watermark.Top,
+	"bottom":       watermark.Bottom,
+	"left":         watermark.Left,
+	"right":        watermark.Right,
+	"top-left":     watermark.TopLeft,
+	"top-right":    watermark.TopRight,
+	"bottom-left":  watermark.BottomLeft,
+	"bottom-right": watermark.BottomRight,
+}
+
+// parseArgs accepts the input file before or after the flags
+func parseArgs(fs *flag.FlagSet, args []string) (positional []string, err error) {
+	for {
+		if err = fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// parsePages parses a list of 1-based page numbers, such as "1,3-5"
+func parsePages(s string) ([]int, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var out []int
+	for _, chunk := range strings.Split(s, ",") {
+		bounds := strings.SplitN(chunk, "-", 2)
+		start, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid page range %s", chunk)
+		}
+		end := start
+		if len(bounds) == 2 {
+			if end, err = strconv.Atoi(strings.TrimSpace(bounds[1])); err != nil {
+				return nil, fmt.Errorf("invalid page range %s", chunk)
+			}
+		}
+		if start < 1 || end < start {
+			return nil, fmt.Errorf("invalid page range %s (pages start at 1)", chunk)
+		}
+		for page := start; page <= end; page++ {
+			out = append(out, page-1)
+		}
+	}
+	return out, nil
+}
+
+// parseColor parses an hexadecimal RGB color, such as "ff0000"
+func parseColor(s string) (color.Color, error) {
+	v, err := strconv.ParseUint(strings.TrimPrefix(s, "#"), 16, 32)
+	if err != nil || len(strings.TrimPrefix(s, "#")) != 6 {
+		return nil, fmt.Errorf("invalid color %s", s)
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xFF}, nil
+}
+
+func main() {
+	fs := flag.NewFlagSet("stamp", flag.ExitOnError)
+	text := fs.String("text", "", "text to stamp")
+	overlay := fs.String("overlay", "", "PDF file whose page is stamped")
+	overlayPage := fs.Int("overlay-page", 1, "page of the overlay file to use")
+	under := fs.Bool("under", false, "draw the stamp under the existing content")
+	opacity := fs.Float64("opacity", 1, "opacity of the stamp, between 0 and 1")
+	diagonal := fs.Bool("diagonal", false, "align the stamp with the diagonal of the pages")
+	rotation := fs.Float64("rotation", 0, "rotation of the stamp, in degrees")
+	position := fs.String("position", "center", "position of the stamp: center, top, bottom-left, ...")
+	size := fs.Float64("size", 48, "font size of the text")
+	textColor := fs.String("color", "808080", "hexadecimal RGB color of the text")
+	pages := fs.String("pages", "", "pages to stamp, such as 1,3-5 (default all)")
+	output := fs.String("o", "", "output file (default <input>.stamped.pdf)")
+	args, err := parseArgs(fs, os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(args) != 1 {
+		log.Fatal("usage: stamp input.pdf (--text <text> | --overlay <file.pdf>) [options]")
+	}
+	if (*text == "") == (*overlay == "") {
+		log.Fatal("exactly one of --text and --overlay is required")
+	}
+
+	pos, ok := positions[*position]
+	if !ok {
+		log.Fatalf("invalid position %s", *position)
+	}
+	opts := watermark.Options{
+		Position: pos,
+		Rotation: model.Fl(*rotation),
+		Diagonal: *diagonal,
+		Below:    *under,
+	}
+	if *opacity < 0 || *opacity > 1 {
+		log.Fatalf("invalid opacity %g (expected a value between 0 and 1)", *opacity)
+	}
+	opts.Opacity = model.Fl(*opacity)
+	if opts.Opacity == 0 {
+		// the zero value of Options.Opacity means opaque:
+		// use a value written as 0 in the file instead
+		opts.Opacity = 1e-6
+	}
+	if opts.Pages, err = parsePages(*pages); err != nil {
+		log.Fatal(err)
+	}
+
+	input := args[0]
+	doc, _, err := reader.ParsePDFFile(input, reader.Options{})
+	if err != nil {
+		log.Fatalf("reading input: %s", err)
+	}
+
+	if *text != "" {
+		c, err := parseColor(*textColor)
+		if err != nil {
+			log.Fatal(err)
+		}
+		font, err := fonts.BuildFont(&model.FontDict{Subtype: standardfonts.Helvetica_Bold.WesternType1Font()})
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = watermark.StampText(&doc, *text, font, model.Fl(*size), c, opts)
+		if err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		stampDoc, _, err := reader.ParsePDFFile(*overlay, reader.Options{})
+		if err != nil {
+			log.Fatalf("reading overlay: %s", err)
+		}
+		form, err := watermark.PageForm(&stampDoc, *overlayPage-1)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = watermark.StampXObject(&doc, form, form.BBox.Width(), form.BBox.Height(), opts)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	out := *output
+	if out == "" {
+		out = input + ".stamped.pdf"
+	}
+	if err = doc.WriteFile(out, nil); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Written in", out)
+}
@@ -0,0 +1,320 @@
+// Package colors converts the colors expressed in the PDF color spaces
+// to sRGB, so that they may be displayed or exported.
+//
+// CIE-based color spaces (CalGray, CalRGB, Lab) are converted
+// using their white point and a Bradford chromatic adaptation.
+// ICC profiles are supported for the simple matrix/TRC (RGB) and gray
+// profiles, and fall back to the alternate color space otherwise.
+// Special color spaces (Indexed, Separation, DeviceN) are resolved
+// using their lookup table or tint transform.
+// DeviceCMYK uses a naive conversion, and patterns are approximated by a gray.
+package colors
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/benoitkugler/pdf/model"
+)
+
+type Fl = model.Fl
+
+// maxSpaceDepth protects against invalid nested color spaces
+const maxSpaceDepth = 10
+
+// d65 is the white point of sRGB
+var d65 = [3]Fl{0.9505, 1, 1.089}
+
+// Converter converts the colors expressed in a color space to sRGB.
+// It should be created once for each color space, with `NewConverter`,
+// and may then be used for many colors.
+type Converter struct {
+	convert func(comps []Fl) [3]Fl
+}
+
+// NewConverter returns a converter for `space`. A nil color space
+// is interpreted from the number of components (gray, RGB or CMYK).
+func NewConverter(space model.ColorSpace) Converter {
+	return Converter{convert: newConverter(space, 0)}
+}
+
+// SRGB converts `comps` to sRGB components, in [0, 1].
+// Missing components are treated as zeros.
+func (c Converter) SRGB(comps []Fl) (r, g, b Fl) {
+	rgb := c.convert(comps)
+	return clip(rgb[0], 0, 1), clip(rgb[1], 0, 1), clip(rgb[2], 0, 1)
+}
+
+// RGBA converts `comps` to an opaque color.
+func (c Converter) RGBA(comps []Fl) color.RGBA {
+	r, g, b := c.SRGB(comps)
+	return color.RGBA{toByte(r), toByte(g), toByte(b), 0xFF}
+}
+
+// ToRGBA is a convenience function converting `comps`, expressed in `space`,
+// to an opaque color. See `NewConverter` to convert many colors.
+func ToRGBA(space model.ColorSpace, comps []Fl) color.RGBA {
+	return NewConverter(space).RGBA(comps)
+}
+
+func toByte(v Fl) uint8 { return uint8(v*0xFF + 0.5) }
+
+func component(comps []Fl, i int) Fl {
+	if i < len(comps) {
+		return comps[i]
+	}
+	return 0
+}
+
+func gray(g Fl) [3]Fl { return [3]Fl{g, g, g} }
+
+func deviceRGB(comps []Fl) [3]Fl {
+	return [3]Fl{component(comps, 0), component(comps, 1), component(comps, 2)}
+}
+
+func deviceCMYK(comps []Fl) [3]Fl {
+	k := 1 - component(comps, 3)
+	return [3]Fl{
+		(1 - component(comps, 0)) * k,
+		(1 - component(comps, 1)) * k,
+		(1 - component(comps, 2)) * k,
+	}
+}
+
+// byComponents guesses the color space from the number of components
+func byComponents(n int) func([]Fl) [3]Fl {
+	switch n {
+	case 3:
+		return deviceRGB
+	case 4:
+		return deviceCMYK
+	default:
+		return func(comps []Fl) [3]Fl { return gray(component(comps, 0)) }
+	}
+}
+
+func constant(c [3]Fl) func([]Fl) [3]Fl {
+	return func([]Fl) [3]Fl { return c }
+}
+
+func newConverter(space model.ColorSpace, depth int) func([]Fl) [3]Fl {
+	if depth > maxSpaceDepth {
+		return constant(gray(0))
+	}
+	switch space := space.(type) {
+	case nil:
+		return func(comps []Fl) [3]Fl { return byComponents(len(comps))(comps) }
+	case model.ColorSpaceName:
+		switch space {
+		case model.ColorSpaceGray, model.ColorSpaceRGB, model.ColorSpaceCMYK:
+			return byComponents(space.NbColorComponents())
+		default: // patterns are not supported
+			return constant(gray(0.5))
+		}
+	case model.ColorSpaceCalGray:
+		return newCalGray(space)
+	case model.ColorSpaceCalRGB:
+		return newCalRGB(space)
+	case model.ColorSpaceLab:
+		return newLab(space)
+	case *model.ColorSpaceICCBased:
+		if space == nil {
+			return constant(gray(0))
+		}
+		if toXYZ := iccProfile(space); toXYZ != nil {
+			return func(comps []Fl) [3]Fl { return xyzToSRGB(toXYZ(comps), d50) }
+		}
+		if space.Alternate != nil {
+			return newConverter(space.Alternate, depth+1)
+		}
+		return byComponents(space.N)
+	case model.ColorSpaceIndexed:
+		return newIndexed(space, depth)
+	case model.ColorSpaceSeparation:
+		switch space.Name {
+		case "None":
+			return constant(gray(1))
+		case "All":
+			return func(comps []Fl) [3]Fl { return gray(1 - component(comps, 0)) }
+		}
+		return newTintTransform(space.TintTransform, space.AlternateSpace, depth, func(comps []Fl) [3]Fl {
+			return gray(1 - component(comps, 0))
+		})
+	case model.ColorSpaceDeviceN:
+		return newTintTransform(space.TintTransform, space.AlternateSpace, depth, func(comps []Fl) [3]Fl {
+			var tint Fl
+			for _, c := range comps {
+				tint += c
+			}
+			return gray(1 - tint)
+		})
+	case model.ColorSpaceUncoloredPattern:
+		return constant(gray(0.5))
+	default:
+		return byComponents(space.NbColorComponents())
+	}
+}
+
+// newTintTransform evaluates the tint transform and converts the result in the
+// alternate space, using `fallback` if the function is invalid
+func newTintTransform(fn model.FunctionDict, alternate model.ColorSpace, depth int, fallback func([]Fl) [3]Fl) func([]Fl) [3]Fl {
+	transform, err := NewFunction(fn)
+	if err != nil || alternate == nil {
+		return fallback
+	}
+	convert := newConverter(alternate, depth+1)
+	return func(comps []Fl) [3]Fl { return convert(transform(comps)) }
+}
+
+// newIndexed precomputes the colors of the lookup table
+func newIndexed(space model.ColorSpaceIndexed, depth int) func([]Fl) [3]Fl {
+	var lookup []byte
+	switch table := space.Lookup.(type) {
+	case model.ColorTableBytes:
+		lookup = table
+	case *model.ColorTableStream:
+		lookup, _ = model.Stream(*table).Decode()
+	}
+	if space.Base == nil {
+		return constant(gray(0))
+	}
+	n := space.Base.NbColorComponents()
+	if n == 0 {
+		n = 3
+	}
+	base := newConverter(space.Base, depth+1)
+	palette := make([][3]Fl, int(space.Hival)+1)
+	comps := make([]Fl, n)
+	for index := range palette {
+		for i := range comps {
+			comps[i] = 0
+			if j := index*n + i; j < len(lookup) {
+				comps[i] = Fl(lookup[j]) / 255
+			}
+		}
+		palette[index] = base(comps)
+	}
+	return func(comps []Fl) [3]Fl {
+		index := int(component(comps, 0))
+		if index < 0 {
+			index = 0
+		} else if index >= len(palette) {
+			index = len(palette) - 1
+		}
+		return palette[index]
+	}
+}
+
+// ------------------------- CIE-based -------------------------
+
+// whitePoint returns `wp`, or D65 for invalid values
+func whitePoint(wp [3]Fl) [3]Fl {
+	if wp[1] <= 0 {
+		return d65
+	}
+	return wp
+}
+
+func pow(x, y Fl) Fl {
+	if x <= 0 {
+		return 0
+	}
+	return Fl(math.Pow(float64(x), float64(y)))
+}
+
+func newCalGray(space model.ColorSpaceCalGray) func([]Fl) [3]Fl {
+	wp, g := whitePoint(space.WhitePoint), space.Gamma
+	if g == 0 {
+		g = 1
+	}
+	return func(comps []Fl) [3]Fl {
+		a := pow(component(comps, 0), g)
+		return xyzToSRGB([3]Fl{wp[0] * a, wp[1] * a, wp[2] * a}, wp)
+	}
+}
+
+func newCalRGB(space model.ColorSpaceCalRGB) func([]Fl) [3]Fl {
+	wp, gamma, m := whitePoint(space.WhitePoint), space.Gamma, space.Matrix
+	for i, g := range gamma {
+		if g == 0 {
+			gamma[i] = 1
+		}
+	}
+	if m == ([9]Fl{}) {
+		m = [9]Fl{1, 0, 0, 0, 1, 0, 0, 0, 1}
+	}
+	return func(comps []Fl) [3]Fl {
+		a, b, c := pow(component(comps, 0), gamma[0]), pow(component(comps, 1), gamma[1]), pow(component(comps, 2), gamma[2])
+		xyz := [3]Fl{
+			m[0]*a + m[3]*b + m[6]*c,
+			m[1]*a + m[4]*b + m[7]*c,
+			m[2]*a + m[5]*b + m[8]*c,
+		}
+		return xyzToSRGB(xyz, wp)
+	}
+}
+
+func newLab(space model.ColorSpaceLab) func([]Fl) [3]Fl {
+	wp, rg := whitePoint(space.WhitePoint), space.Range
+	if rg == ([4]Fl{}) {
+		rg = [4]Fl{-100, 100, -100, 100}
+	}
+	g := func(x Fl) Fl {
+		if x >= 6./29 {
+			return x * x * x
+		}
+		return 108. / 841 * (x - 4./29)
+	}
+	return func(comps []Fl) [3]Fl {
+		l := clip(component(comps, 0), 0, 100)
+		a := clip(component(comps, 1), rg[0], rg[1])
+		b := clip(component(comps, 2), rg[2], rg[3])
+		m := (l + 16) / 116
+		xyz := [3]Fl{wp[0] * g(m+a/500), wp[1] * g(m), wp[2] * g(m-b/200)}
+		return xyzToSRGB(xyz, wp)
+	}
+}
+
+// bradford is the cone response matrix used for chromatic adaptation
+var (
+	bradford    = [9]Fl{0.8951, 0.2664, -0.1614, -0.7502, 1.7135, 0.0367, 0.0389, -0.0685, 1.0296}
+	bradfordInv = [9]Fl{0.9869929, -0.1470543, 0.1599627, 0.4323053, 0.5183603, 0.0492912, -0.0085287, 0.0400428, 0.9684867}
+	// xyzToLinearRGB maps D65 XYZ to linear sRGB
+	xyzToLinearRGB = [9]Fl{3.2406, -1.5372, -0.4986, -0.9689, 1.8758, 0.0415, 0.0557, -0.2040, 1.0570}
+)
+
+func mul(m [9]Fl, v [3]Fl) [3]Fl {
+	return [3]Fl{
+		m[0]*v[0] + m[1]*v[1] + m[2]*v[2],
+		m[3]*v[0] + m[4]*v[1] + m[5]*v[2],
+		m[6]*v[0] + m[7]*v[1] + m[8]*v[2],
+	}
+}
+
+// adapt maps `xyz`, relative to the white point `src`, to D65.
+func adapt(xyz, src [3]Fl) [3]Fl {
+	if src == d65 {
+		return xyz
+	}
+	cone, srcCone, dstCone := mul(bradford, xyz), mul(bradford, src), mul(bradford, d65)
+	for i := range cone {
+		if srcCone[i] != 0 {
+			cone[i] *= dstCone[i] / srcCone[i]
+		}
+	}
+	return mul(bradfordInv, cone)
+}
+
+// xyzToSRGB converts `xyz`, relative to the white point `wp`,
+// to (companded) sRGB
+func xyzToSRGB(xyz, wp [3]Fl) [3]Fl {
+	rgb := mul(xyzToLinearRGB, adapt(xyz, wp))
+	for i, v := range rgb {
+		if v <= 0.0031308 {
+			rgb[i] = 12.92 * v
+		} else {
+			rgb[i] = 1.055*pow(v, 1/2.4) - 0.055
+		}
+	}
+	return rgb
+}
@@ -0,0 +1,261 @@
+package colors
+
+import (
+	"encoding/binary"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/benoitkugler/pdf/model"
+)
+
+func isClose(a, b Fl) bool { return math.Abs(float64(a-b)) < 1e-2 }
+
+func assertValues(t *testing.T, got, exp []Fl) {
+	t.Helper()
+	if len(got) != len(exp) {
+		t.Fatalf("expected %v, got %v", exp, got)
+	}
+	for i := range got {
+		if !isClose(got[i], exp[i]) {
+			t.Fatalf("expected %v, got %v", exp, got)
+		}
+	}
+}
+
+func TestFunctions(t *testing.T) {
+	unit := []model.Range{{0, 1}}
+	exp := model.FunctionDict{
+		FunctionType: model.FunctionExpInterpolation{C0: []Fl{0, 1}, C1: []Fl{1, 0}, N: 2},
+		Domain:       unit,
+	}
+	fn, err := NewFunction(exp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertValues(t, fn([]Fl{0.5}), []Fl{0.25, 0.75})
+	assertValues(t, fn([]Fl{2}), []Fl{1, 0}) // clipped to the domain
+
+	stitching := model.FunctionDict{
+		FunctionType: model.FunctionStitching{
+			Functions: []model.FunctionDict{
+				{FunctionType: model.FunctionExpInterpolation{C0: []Fl{0}, C1: []Fl{1}, N: 1}, Domain: unit},
+				{FunctionType: model.FunctionExpInterpolation{C0: []Fl{1}, C1: []Fl{0}, N: 1}, Domain: unit},
+			},
+			Bounds: []Fl{0.5},
+			Encode: model.FunctionEncodeRepeat(2),
+		},
+		Domain: unit,
+	}
+	fn, err = NewFunction(stitching)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertValues(t, fn([]Fl{0.25}), []Fl{0.5})
+	assertValues(t, fn([]Fl{0.75}), []Fl{0.5})
+
+	sampled := model.FunctionDict{
+		FunctionType: model.FunctionSampled{
+			Stream:        model.Stream{Content: []byte{0, 255, 255, 0, 0, 128}},
+			Size:          []int{3},
+			BitsPerSample: 8,
+		},
+		Domain: unit,
+		Range:  []model.Range{{0, 1}, {0, 1}},
+	}
+	fn, err = NewFunction(sampled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertValues(t, fn([]Fl{0}), []Fl{0, 1})
+	assertValues(t, fn([]Fl{0.25}), []Fl{0.5, 0.5})
+	assertValues(t, fn([]Fl{1}), []Fl{0, 0.5})
+
+	postscript := model.FunctionDict{
+		FunctionType: model.FunctionPostScriptCalculator{Content: []byte(
+			"{ dup 0.5 gt { 1 exch sub } if dup dup 2 mul 3 1 roll }",
+		)},
+		Domain: unit,
+		Range:  []model.Range{{0, 2}, {0, 1}, {0, 1}},
+	}
+	fn, err = NewFunction(postscript)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertValues(t, fn([]Fl{0.25}), []Fl{0.5, 0.25, 0.25})
+	assertValues(t, fn([]Fl{0.75}), []Fl{0.5, 0.25, 0.25})
+
+	if _, err = NewFunction(model.FunctionDict{FunctionType: model.FunctionPostScriptCalculator{Content: []byte("{ 1 2 add")}}); err == nil {
+		t.Fatal("expected error for invalid PostScript function")
+	}
+}
+
+func TestPostScriptOperators(t *testing.T) {
+	for code, exp := range map[string]Fl{
+		"{ 7 2 idiv }":                  3,
+		"{ 7 2 mod }":                   1,
+		"{ 2 3 exp }":                   8,
+		"{ 1 1 atan }":                  45,
+		"{ 90 sin }":                    1,
+		"{ 2.6 round cvi }":             3,
+		"{ -2.6 truncate abs }":         2,
+		"{ 1 4 bitshift }":              16,
+		"{ 1 2 lt { 5 } { 6 } ifelse }": 5,
+		"{ 1 2 eq not { 5 } if }":       5,
+		"{ true false or { 4 } if }":    4,
+		"{ 1 2 3 1 index }":             2,
+	} {
+		fn, err := NewFunction(model.FunctionDict{
+			FunctionType: model.FunctionPostScriptCalculator{Content: []byte(code)},
+			Range:        []model.Range{{-100, 100}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertValues(t, fn(nil), []Fl{exp})
+	}
+}
+
+func assertColor(t *testing.T, got, exp color.RGBA) {
+	t.Helper()
+	diff := func(a, b uint8) bool { return int(a) > int(b)+2 || int(b) > int(a)+2 }
+	if diff(got.R, exp.R) || diff(got.G, exp.G) || diff(got.B, exp.B) || got.A != exp.A {
+		t.Fatalf("expected %v, got %v", exp, got)
+	}
+}
+
+var (
+	white = color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}
+	black = color.RGBA{A: 0xFF}
+)
+
+func TestDeviceSpaces(t *testing.T) {
+	assertColor(t, ToRGBA(model.ColorSpaceGray, []Fl{0.5}), color.RGBA{128, 128, 128, 0xFF})
+	assertColor(t, ToRGBA(model.ColorSpaceRGB, []Fl{1, 0, 2}), color.RGBA{0xFF, 0, 0xFF, 0xFF})
+	assertColor(t, ToRGBA(model.ColorSpaceCMYK, []Fl{0, 0, 0, 1}), black)
+	assertColor(t, ToRGBA(nil, []Fl{0, 1, 0}), color.RGBA{0, 0xFF, 0, 0xFF})
+}
+
+func TestCIESpaces(t *testing.T) {
+	d50 := [3]Fl{0.9642, 1, 0.8249}
+	lab := model.ColorSpaceLab{WhitePoint: d50}
+	assertColor(t, ToRGBA(lab, []Fl{100, 0, 0}), white)
+	assertColor(t, ToRGBA(lab, []Fl{0, 0, 0}), black)
+	if c := ToRGBA(lab, []Fl{50, 80, 60}); c.R < c.G || c.R < c.B {
+		t.Fatalf("expected reddish color, got %v", c)
+	}
+
+	calRGB := model.ColorSpaceCalRGB{
+		WhitePoint: [3]Fl{0.9505, 1, 1.089}, Gamma: [3]Fl{2.2, 2.2, 2.2},
+		Matrix: [9]Fl{0.4124, 0.2126, 0.0193, 0.3576, 0.7152, 0.1192, 0.1805, 0.0722, 0.9505},
+	}
+	assertColor(t, ToRGBA(calRGB, []Fl{1, 1, 1}), white)
+	assertColor(t, ToRGBA(calRGB, []Fl{0, 0, 0}), black)
+
+	calGray := model.ColorSpaceCalGray{WhitePoint: d50}
+	assertColor(t, ToRGBA(calGray, []Fl{1}), white)
+}
+
+func TestSpecialSpaces(t *testing.T) {
+	indexed := model.ColorSpaceIndexed{
+		Base:   model.ColorSpaceRGB,
+		Hival:  1,
+		Lookup: model.ColorTableBytes{0xFF, 0, 0, 0, 0, 0xFF},
+	}
+	conv := NewConverter(indexed)
+	assertColor(t, conv.RGBA([]Fl{0}), color.RGBA{0xFF, 0, 0, 0xFF})
+	assertColor(t, conv.RGBA([]Fl{1}), color.RGBA{0, 0, 0xFF, 0xFF})
+	assertColor(t, conv.RGBA([]Fl{5}), color.RGBA{0, 0, 0xFF, 0xFF}) // clamped
+
+	// tint to CMYK magenta
+	separation := model.ColorSpaceSeparation{
+		Name:           "Magenta",
+		AlternateSpace: model.ColorSpaceCMYK,
+		TintTransform: model.FunctionDict{
+			FunctionType: model.FunctionExpInterpolation{C0: []Fl{0, 0, 0, 0}, C1: []Fl{0, 1, 0, 0}, N: 1},
+			Domain:       []model.Range{{0, 1}},
+		},
+	}
+	assertColor(t, ToRGBA(separation, []Fl{1}), color.RGBA{0xFF, 0, 0xFF, 0xFF})
+	assertColor(t, ToRGBA(separation, []Fl{0}), white)
+
+	// invalid tint transform: use the tint as a gray level
+	separation.TintTransform = model.FunctionDict{}
+	assertColor(t, ToRGBA(separation, []Fl{1}), black)
+
+	deviceN := model.ColorSpaceDeviceN{
+		Names:          []model.Name{"Cyan", "Spot"},
+		AlternateSpace: model.ColorSpaceRGB,
+		TintTransform: model.FunctionDict{
+			FunctionType: model.FunctionPostScriptCalculator{Content: []byte("{ pop 1 exch sub dup 1 }")},
+			Domain:       []model.Range{{0, 1}, {0, 1}},
+			Range:        []model.Range{{0, 1}, {0, 1}, {0, 1}},
+		},
+	}
+	assertColor(t, ToRGBA(deviceN, []Fl{1, 0.5}), color.RGBA{0, 0, 0xFF, 0xFF})
+}
+
+// iccProfileData builds a minimal ICC profile, with the given tags
+func iccProfileData(space string, tags map[string][]byte) []byte {
+	data := make([]byte, 132)
+	copy(data[16:], space)
+	copy(data[20:], "XYZ ")
+	binary.BigEndian.PutUint32(data[128:], uint32(len(tags)))
+	table := make([]byte, 0, 12*len(tags))
+	var content []byte
+	offset := 132 + 12*len(tags)
+	for sig, tag := range tags {
+		entry := make([]byte, 12)
+		copy(entry, sig)
+		binary.BigEndian.PutUint32(entry[4:], uint32(offset+len(content)))
+		binary.BigEndian.PutUint32(entry[8:], uint32(len(tag)))
+		table = append(table, entry...)
+		content = append(content, tag...)
+	}
+	return append(append(data, table...), content...)
+}
+
+func xyzTag(x, y, z Fl) []byte {
+	out := make([]byte, 20)
+	copy(out, "XYZ ")
+	for i, v := range [3]Fl{x, y, z} {
+		binary.BigEndian.PutUint32(out[8+4*i:], uint32(int32(v*0x10000)))
+	}
+	return out
+}
+
+func gammaTag(gamma Fl) []byte {
+	out := make([]byte, 14)
+	copy(out, "curv")
+	binary.BigEndian.PutUint32(out[8:], 1)
+	binary.BigEndian.PutUint16(out[12:], uint16(gamma*0x100))
+	return out
+}
+
+func TestICCBased(t *testing.T) {
+	rgb := iccProfileData("RGB ", map[string][]byte{
+		"rXYZ": xyzTag(0.4361, 0.2225, 0.0139),
+		"gXYZ": xyzTag(0.3851, 0.7169, 0.0971),
+		"bXYZ": xyzTag(0.1431, 0.0606, 0.7141),
+		"rTRC": gammaTag(2.2),
+		"gTRC": gammaTag(2.2),
+		"bTRC": gammaTag(2.2),
+	})
+	space := &model.ColorSpaceICCBased{Stream: model.Stream{Content: rgb}, N: 3}
+	assertColor(t, ToRGBA(space, []Fl{1, 1, 1}), white)
+	assertColor(t, ToRGBA(space, []Fl{0, 0, 0}), black)
+	if c := ToRGBA(space, []Fl{1, 0, 0}); c.R < 0xF0 || c.G > 0x10 || c.B > 0x10 {
+		t.Fatalf("expected red, got %v", c)
+	}
+
+	// linear table, converted to sRGB
+	table := []byte{'c', 'u', 'r', 'v', 0, 0, 0, 0, 0, 0, 0, 2, 0, 0, 0xFF, 0xFF}
+	gray := &model.ColorSpaceICCBased{Stream: model.Stream{Content: iccProfileData("GRAY", map[string][]byte{"kTRC": table})}, N: 1}
+	assertColor(t, ToRGBA(gray, []Fl{0.2}), color.RGBA{124, 124, 124, 0xFF})
+
+	// unsupported profile: use the alternate space, or the number of components
+	cmyk := &model.ColorSpaceICCBased{Stream: model.Stream{Content: iccProfileData("CMYK", nil)}, N: 4}
+	assertColor(t, ToRGBA(cmyk, []Fl{0, 0, 0, 1}), black)
+	cmyk.Alternate = model.ColorSpaceGray
+	assertColor(t, ToRGBA(cmyk, []Fl{1, 0, 0, 0}), white)
+}
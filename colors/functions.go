@@ -0,0 +1,222 @@
+package colors
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/benoitkugler/pdf/model"
+)
+
+// Function is a compiled PDF function, mapping m inputs to n outputs.
+// The inputs are clipped to the domain, and the outputs to the range, if any.
+type Function func(inputs []Fl) []Fl
+
+// maxFunctionDepth limits the nesting of stitching functions
+const maxFunctionDepth = 20
+
+// NewFunction compiles `fn`, so that it may be evaluated efficiently.
+// All the function types are supported; cubic sampled functions
+// are linearly interpolated.
+func NewFunction(fn model.FunctionDict) (Function, error) {
+	return newFunction(fn, 0)
+}
+
+func newFunction(fn model.FunctionDict, depth int) (Function, error) {
+	if depth > maxFunctionDepth {
+		return nil, errors.New("too many nested functions")
+	}
+	var (
+		eval func(in []Fl) []Fl
+		err  error
+	)
+	switch ft := fn.FunctionType.(type) {
+	case model.FunctionSampled:
+		eval, err = newSampled(ft, fn.Domain, fn.Range)
+	case model.FunctionExpInterpolation:
+		eval = newExpInterpolation(ft)
+	case model.FunctionStitching:
+		eval, err = newStitching(ft, fn.Domain, depth)
+	case model.FunctionPostScriptCalculator:
+		eval, err = newPostScript(ft, len(fn.Range))
+	default:
+		return nil, fmt.Errorf("unsupported function type %T", fn.FunctionType)
+	}
+	if err != nil {
+		return nil, err
+	}
+	domain, rg := fn.Domain, fn.Range
+	return func(inputs []Fl) []Fl {
+		in := make([]Fl, len(domain))
+		for i, d := range domain {
+			if i < len(inputs) {
+				in[i] = clip(inputs[i], d[0], d[1])
+			} else {
+				in[i] = d[0]
+			}
+		}
+		out := eval(in)
+		for i, r := range rg {
+			if i < len(out) {
+				out[i] = clip(out[i], r[0], r[1])
+			}
+		}
+		return out
+	}, nil
+}
+
+func clip(v, min, max Fl) Fl {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+// interpolate maps x from [xmin, xmax] to [ymin, ymax]
+func interpolate(x, xmin, xmax, ymin, ymax Fl) Fl {
+	if xmax == xmin {
+		return ymin
+	}
+	return ymin + (x-xmin)*(ymax-ymin)/(xmax-xmin)
+}
+
+func newExpInterpolation(fn model.FunctionExpInterpolation) func([]Fl) []Fl {
+	c0, c1 := fn.C0, fn.C1
+	if len(c0) == 0 {
+		c0 = []Fl{0}
+	}
+	if len(c1) == 0 {
+		c1 = []Fl{1}
+	}
+	return func(in []Fl) []Fl {
+		var x Fl
+		if len(in) != 0 {
+			x = in[0]
+		}
+		p := Fl(math.Pow(float64(x), float64(fn.N)))
+		out := make([]Fl, len(c0))
+		for i := range out {
+			var v1 Fl
+			if i < len(c1) {
+				v1 = c1[i]
+			}
+			out[i] = c0[i] + p*(v1-c0[i])
+		}
+		return out
+	}
+}
+
+func newStitching(fn model.FunctionStitching, domain []model.Range, depth int) (func([]Fl) []Fl, error) {
+	k := len(fn.Functions)
+	if k == 0 || len(fn.Bounds) != k-1 || len(fn.Encode) != k || len(domain) == 0 {
+		return nil, errors.New("invalid stitching function")
+	}
+	subs := make([]Function, k)
+	for i, sub := range fn.Functions {
+		var err error
+		subs[i], err = newFunction(sub, depth+1)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return func(in []Fl) []Fl {
+		x := in[0]
+		i := 0
+		for i < len(fn.Bounds) && x >= fn.Bounds[i] {
+			i++
+		}
+		low, high := domain[0][0], domain[0][1]
+		if i > 0 {
+			low = fn.Bounds[i-1]
+		}
+		if i < len(fn.Bounds) {
+			high = fn.Bounds[i]
+		}
+		return subs[i]([]Fl{interpolate(x, low, high, fn.Encode[i][0], fn.Encode[i][1])})
+	}, nil
+}
+
+func newSampled(fn model.FunctionSampled, domain, rg []model.Range) (func([]Fl) []Fl, error) {
+	m, n := len(domain), len(rg)
+	if m == 0 || n == 0 || len(fn.Size) != m {
+		return nil, errors.New("invalid sampled function")
+	}
+	bps := int(fn.BitsPerSample)
+	if bps == 0 || bps > 32 {
+		return nil, fmt.Errorf("invalid BitsPerSample for sampled function: %d", bps)
+	}
+	data, err := fn.Stream.Decode()
+	if err != nil {
+		return nil, err
+	}
+	encode, decode := fn.Encode, fn.Decode
+	if len(encode) < m {
+		encode = make([][2]Fl, m)
+		for i, s := range fn.Size {
+			encode[i] = [2]Fl{0, Fl(s - 1)}
+		}
+	}
+	if len(decode) < n {
+		decode = make([][2]Fl, n)
+		for i, r := range rg {
+			decode[i] = [2]Fl(r)
+		}
+	}
+	maxSample := Fl(float64(uint64(1)<<uint(bps) - 1))
+	sample := func(index int) Fl {
+		var v uint64
+		bit := index * bps
+		for i := 0; i < bps; i++ {
+			pos := bit + i
+			if pos/8 >= len(data) {
+				return 0
+			}
+			v = v<<1 | uint64(data[pos/8]>>(7-uint(pos%8))&1)
+		}
+		return Fl(v)
+	}
+	return func(in []Fl) []Fl {
+		// position in the sample grid
+		e := make([]Fl, m)
+		for i := range e {
+			e[i] = clip(interpolate(in[i], domain[i][0], domain[i][1], encode[i][0], encode[i][1]), 0, Fl(fn.Size[i]-1))
+		}
+		out := make([]Fl, n)
+		// multilinear interpolation between the 2^m surrounding samples
+		for corner := 0; corner < 1<<uint(m); corner++ {
+			weight, offset, stride := Fl(1), 0, 1
+			for i := 0; i < m; i++ {
+				low := int(e[i])
+				if low == fn.Size[i]-1 && low > 0 {
+					low--
+				}
+				t := e[i] - Fl(low)
+				index := low
+				if corner>>uint(i)&1 == 1 {
+					index++
+					weight *= t
+				} else {
+					weight *= 1 - t
+				}
+				if index >= fn.Size[i] {
+					index = fn.Size[i] - 1
+				}
+				offset += index * stride
+				stride *= fn.Size[i]
+			}
+			if weight == 0 {
+				continue
+			}
+			for j := range out {
+				out[j] += weight * sample(offset*n+j)
+			}
+		}
+		for j := range out {
+			out[j] = interpolate(out[j], 0, maxSample, decode[j][0], decode[j][1])
+		}
+		return out
+	}, nil
+}
@@ -0,0 +1,155 @@
+package colors
+
+import (
+	"encoding/binary"
+
+	"github.com/benoitkugler/pdf/model"
+)
+
+// This file implements a minimal ICC transform, supporting
+// the matrix/TRC RGB profiles and the gray profiles (see the ICC.1 specification).
+
+// d50 is the white point of the ICC profile connection space
+var d50 = [3]Fl{0.9642, 1, 0.8249}
+
+// iccProfile parses the ICC profile of `space`, and returns
+// a function mapping the components to the XYZ PCS (relative to D50),
+// or nil if the profile is invalid or not supported.
+func iccProfile(space *model.ColorSpaceICCBased) func([]Fl) [3]Fl {
+	data, err := space.Stream.Decode()
+	if err != nil || len(data) < 132 {
+		return nil
+	}
+	if string(data[20:24]) != "XYZ " { // PCS
+		return nil
+	}
+	tags := parseTagTable(data)
+	switch string(data[16:20]) {
+	case "GRAY":
+		trc := parseCurve(tags["kTRC"])
+		if trc == nil {
+			return nil
+		}
+		return func(comps []Fl) [3]Fl {
+			y := trc(clip(component(comps, 0), 0, 1))
+			return [3]Fl{d50[0] * y, d50[1] * y, d50[2] * y}
+		}
+	case "RGB ":
+		r, okR := parseXYZ(tags["rXYZ"])
+		g, okG := parseXYZ(tags["gXYZ"])
+		b, okB := parseXYZ(tags["bXYZ"])
+		rTRC, gTRC, bTRC := parseCurve(tags["rTRC"]), parseCurve(tags["gTRC"]), parseCurve(tags["bTRC"])
+		if !(okR && okG && okB) || rTRC == nil || gTRC == nil || bTRC == nil {
+			return nil
+		}
+		return func(comps []Fl) [3]Fl {
+			lr, lg, lb := rTRC(clip(component(comps, 0), 0, 1)), gTRC(clip(component(comps, 1), 0, 1)), bTRC(clip(component(comps, 2), 0, 1))
+			return [3]Fl{
+				r[0]*lr + g[0]*lg + b[0]*lb,
+				r[1]*lr + g[1]*lg + b[1]*lb,
+				r[2]*lr + g[2]*lg + b[2]*lb,
+			}
+		}
+	default:
+		return nil
+	}
+}
+
+// parseTagTable returns the tag data, indexed by signature
+func parseTagTable(data []byte) map[string][]byte {
+	count := int(binary.BigEndian.Uint32(data[128:]))
+	out := make(map[string][]byte)
+	for i := 0; i < count; i++ {
+		entry := 132 + 12*i
+		if entry+12 > len(data) {
+			break
+		}
+		offset := binary.BigEndian.Uint32(data[entry+4:])
+		size := binary.BigEndian.Uint32(data[entry+8:])
+		if uint64(offset)+uint64(size) > uint64(len(data)) {
+			continue
+		}
+		out[string(data[entry:entry+4])] = data[offset : offset+size]
+	}
+	return out
+}
+
+func s15Fixed16(b []byte) Fl {
+	return Fl(int32(binary.BigEndian.Uint32(b))) / 0x10000
+}
+
+// parseXYZ reads a XYZType tag
+func parseXYZ(tag []byte) ([3]Fl, bool) {
+	if len(tag) < 20 || string(tag[:4]) != "XYZ " {
+		return [3]Fl{}, false
+	}
+	return [3]Fl{s15Fixed16(tag[8:]), s15Fixed16(tag[12:]), s15Fixed16(tag[16:])}, true
+}
+
+// parseCurve reads a curveType or parametricCurveType tag,
+// returning nil if it is invalid
+func parseCurve(tag []byte) func(Fl) Fl {
+	if len(tag) < 12 {
+		return nil
+	}
+	switch string(tag[:4]) {
+	case "curv":
+		count := int(binary.BigEndian.Uint32(tag[8:]))
+		if len(tag) < 12+2*count {
+			return nil
+		}
+		switch count {
+		case 0: // identity
+			return func(x Fl) Fl { return x }
+		case 1: // gamma, as u8Fixed8Number
+			gamma := Fl(binary.BigEndian.Uint16(tag[12:])) / 0x100
+			return func(x Fl) Fl { return pow(x, gamma) }
+		}
+		table := make([]Fl, count)
+		for i := range table {
+			table[i] = Fl(binary.BigEndian.Uint16(tag[12+2*i:])) / 0xFFFF
+		}
+		return func(x Fl) Fl {
+			pos := x * Fl(count-1)
+			i := int(pos)
+			if i >= count-1 {
+				return table[count-1]
+			}
+			t := pos - Fl(i)
+			return table[i]*(1-t) + table[i+1]*t
+		}
+	case "para":
+		nbParams := [...]int{1, 3, 4, 5, 7}
+		fnType := int(binary.BigEndian.Uint16(tag[8:]))
+		if fnType >= len(nbParams) || len(tag) < 12+4*nbParams[fnType] {
+			return nil
+		}
+		var p [7]Fl
+		for i := 0; i < nbParams[fnType]; i++ {
+			p[i] = s15Fixed16(tag[12+4*i:])
+		}
+		g, a, b, c, d, e, f := p[0], p[1], p[2], p[3], p[4], p[5], p[6]
+		switch fnType {
+		case 0:
+			return func(x Fl) Fl { return pow(x, g) }
+		case 1, 2:
+			if a == 0 {
+				return nil
+			}
+			return func(x Fl) Fl {
+				if x >= -b/a {
+					return pow(a*x+b, g) + c
+				}
+				return c
+			}
+		default: // 3 and 4
+			return func(x Fl) Fl {
+				if x >= d {
+					return pow(a*x+b, g) + e
+				}
+				return c*x + f
+			}
+		}
+	}
+	return nil
+}
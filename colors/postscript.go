@@ -0,0 +1,388 @@
+package colors
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/benoitkugler/pdf/model"
+)
+
+// This file implements the PostScript calculator functions (type 4),
+// see 7.10.5 - Type 4 (PostScript Calculator) Functions.
+
+// psValue is a number or a boolean
+type psValue struct {
+	v      float64
+	isBool bool
+	isInt  bool
+}
+
+func psBool(b bool) psValue {
+	if b {
+		return psValue{v: 1, isBool: true}
+	}
+	return psValue{isBool: true}
+}
+
+func psInt(i int) psValue { return psValue{v: float64(i), isInt: true} }
+
+// psOp is an operator, a number, or a block (for if and ifelse)
+type psOp struct {
+	operator string
+	operand  psValue
+	block    []psOp // for operator == "{"
+}
+
+// maxStackSize is the limit imposed by the SPEC
+const maxStackSize = 100
+
+var errStack = errors.New("invalid PostScript function stack")
+
+func parsePostScript(code []byte) ([]psOp, error) {
+	s := strings.NewReplacer("{", " { ", "}", " } ").Replace(string(code))
+	tokens := strings.Fields(s)
+	if len(tokens) == 0 || tokens[0] != "{" {
+		return nil, errors.New("PostScript function must start with {")
+	}
+	ops, rest, err := parseBlock(tokens[1:], 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("unexpected tokens after PostScript function: %v", rest)
+	}
+	return ops, nil
+}
+
+// parseBlock parses until the closing brace, and returns the remaining tokens
+func parseBlock(tokens []string, depth int) ([]psOp, []string, error) {
+	if depth > maxStackSize {
+		return nil, nil, errors.New("too many nested PostScript blocks")
+	}
+	var out []psOp
+	for len(tokens) != 0 {
+		tok := tokens[0]
+		tokens = tokens[1:]
+		switch tok {
+		case "}":
+			return out, tokens, nil
+		case "{":
+			block, rest, err := parseBlock(tokens, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			out = append(out, psOp{operator: "{", block: block})
+			tokens = rest
+		case "true", "false":
+			out = append(out, psOp{operand: psBool(tok == "true")})
+		default:
+			if i, err := strconv.Atoi(tok); err == nil {
+				out = append(out, psOp{operand: psInt(i)})
+			} else if f, err := strconv.ParseFloat(tok, 64); err == nil {
+				out = append(out, psOp{operand: psValue{v: f}})
+			} else {
+				out = append(out, psOp{operator: tok})
+			}
+		}
+	}
+	return nil, nil, errors.New("missing } in PostScript function")
+}
+
+type psStack []psValue
+
+func (st *psStack) push(v psValue) error {
+	if len(*st) >= maxStackSize {
+		return errStack
+	}
+	*st = append(*st, v)
+	return nil
+}
+
+func (st *psStack) pop() (psValue, error) {
+	s := *st
+	if len(s) == 0 {
+		return psValue{}, errStack
+	}
+	v := s[len(s)-1]
+	*st = s[:len(s)-1]
+	return v, nil
+}
+
+func newPostScript(fn model.FunctionPostScriptCalculator, n int) (func([]Fl) []Fl, error) {
+	code, err := model.Stream(fn).Decode()
+	if err != nil {
+		return nil, err
+	}
+	ops, err := parsePostScript(code)
+	if err != nil {
+		return nil, err
+	}
+	return func(in []Fl) []Fl {
+		stack := make(psStack, 0, len(in)+10)
+		for _, v := range in {
+			stack = append(stack, psValue{v: float64(v)})
+		}
+		// on error, the values computed so far are used
+		_ = execute(ops, &stack)
+		out := make([]Fl, n)
+		// the outputs are the last values of the stack
+		for i := range out {
+			if j := len(stack) - n + i; j >= 0 {
+				out[i] = Fl(stack[j].v)
+			}
+		}
+		return out
+	}, nil
+}
+
+func execute(ops []psOp, stack *psStack) error {
+	for i, op := range ops {
+		if op.operator == "" {
+			if err := stack.push(op.operand); err != nil {
+				return err
+			}
+			continue
+		}
+		if op.operator == "{" {
+			continue // consumed by if and ifelse
+		}
+		var err error
+		switch op.operator {
+		case "if":
+			if i < 1 || ops[i-1].operator != "{" {
+				return errors.New("missing block for if")
+			}
+			var cond psValue
+			if cond, err = stack.pop(); err == nil && cond.v != 0 {
+				err = execute(ops[i-1].block, stack)
+			}
+		case "ifelse":
+			if i < 2 || ops[i-1].operator != "{" || ops[i-2].operator != "{" {
+				return errors.New("missing blocks for ifelse")
+			}
+			var cond psValue
+			if cond, err = stack.pop(); err == nil {
+				if cond.v != 0 {
+					err = execute(ops[i-2].block, stack)
+				} else {
+					err = execute(ops[i-1].block, stack)
+				}
+			}
+		default:
+			err = executeOperator(op.operator, stack)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// executeOperator executes the arithmetic, relational, boolean and stack operators
+func executeOperator(operator string, stack *psStack) error {
+	switch operator {
+	case "dup":
+		v, err := stack.pop()
+		if err != nil {
+			return err
+		}
+		stack.push(v)
+		return stack.push(v)
+	case "pop":
+		_, err := stack.pop()
+		return err
+	case "exch":
+		b, err := stack.pop()
+		if err != nil {
+			return err
+		}
+		a, err := stack.pop()
+		if err != nil {
+			return err
+		}
+		stack.push(b)
+		return stack.push(a)
+	case "copy":
+		n, err := stack.pop()
+		if err != nil {
+			return err
+		}
+		s := *stack
+		if int(n.v) < 0 || int(n.v) > len(s) {
+			return errStack
+		}
+		for _, v := range s[len(s)-int(n.v):] {
+			if err := stack.push(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "index":
+		n, err := stack.pop()
+		if err != nil {
+			return err
+		}
+		s := *stack
+		if int(n.v) < 0 || int(n.v) >= len(s) {
+			return errStack
+		}
+		return stack.push(s[len(s)-1-int(n.v)])
+	case "roll":
+		j, err := stack.pop()
+		if err != nil {
+			return err
+		}
+		n, err := stack.pop()
+		if err != nil {
+			return err
+		}
+		s := *stack
+		count := int(n.v)
+		if count < 0 || count > len(s) {
+			return errStack
+		}
+		if count == 0 {
+			return nil
+		}
+		top := s[len(s)-count:]
+		shift := ((int(j.v) % count) + count) % count
+		rolled := append(append([]psValue(nil), top[count-shift:]...), top[:count-shift]...)
+		copy(top, rolled)
+		return nil
+	case "true", "false":
+		return stack.push(psBool(operator == "true"))
+	}
+
+	// unary operators
+	switch operator {
+	case "abs", "neg", "ceiling", "floor", "round", "truncate", "cvi", "cvr",
+		"sqrt", "sin", "cos", "ln", "log", "not":
+		a, err := stack.pop()
+		if err != nil {
+			return err
+		}
+		return stack.push(psUnary(operator, a))
+	}
+
+	// binary operators
+	b, err := stack.pop()
+	if err != nil {
+		return err
+	}
+	a, err := stack.pop()
+	if err != nil {
+		return err
+	}
+	res, err := psBinary(operator, a, b)
+	if err != nil {
+		return err
+	}
+	return stack.push(res)
+}
+
+func psUnary(operator string, a psValue) psValue {
+	switch operator {
+	case "abs":
+		return psValue{v: math.Abs(a.v), isInt: a.isInt}
+	case "neg":
+		return psValue{v: -a.v, isInt: a.isInt}
+	case "ceiling":
+		return psValue{v: math.Ceil(a.v), isInt: a.isInt}
+	case "floor":
+		return psValue{v: math.Floor(a.v), isInt: a.isInt}
+	case "round":
+		return psValue{v: math.Floor(a.v + 0.5), isInt: a.isInt}
+	case "truncate":
+		return psValue{v: math.Trunc(a.v), isInt: a.isInt}
+	case "cvi":
+		return psInt(int(a.v))
+	case "cvr":
+		return psValue{v: a.v}
+	case "sqrt":
+		return psValue{v: math.Sqrt(a.v)}
+	case "sin": // in degrees
+		return psValue{v: math.Sin(a.v * math.Pi / 180)}
+	case "cos":
+		return psValue{v: math.Cos(a.v * math.Pi / 180)}
+	case "ln":
+		return psValue{v: math.Log(a.v)}
+	case "log":
+		return psValue{v: math.Log10(a.v)}
+	default: // not
+		if a.isBool {
+			return psBool(a.v == 0)
+		}
+		return psInt(^int(a.v))
+	}
+}
+
+func psBinary(operator string, a, b psValue) (psValue, error) {
+	bothInts := a.isInt && b.isInt
+	switch operator {
+	case "add":
+		return psValue{v: a.v + b.v, isInt: bothInts}, nil
+	case "sub":
+		return psValue{v: a.v - b.v, isInt: bothInts}, nil
+	case "mul":
+		return psValue{v: a.v * b.v, isInt: bothInts}, nil
+	case "div":
+		if b.v == 0 {
+			return psValue{}, errors.New("division by zero in PostScript function")
+		}
+		return psValue{v: a.v / b.v}, nil
+	case "idiv", "mod":
+		if int(b.v) == 0 {
+			return psValue{}, errors.New("division by zero in PostScript function")
+		}
+		if operator == "idiv" {
+			return psInt(int(a.v) / int(b.v)), nil
+		}
+		return psInt(int(a.v) % int(b.v)), nil
+	case "exp":
+		return psValue{v: math.Pow(a.v, b.v)}, nil
+	case "atan": // in degrees, in [0, 360)
+		angle := math.Atan2(a.v, b.v) * 180 / math.Pi
+		if angle < 0 {
+			angle += 360
+		}
+		return psValue{v: angle}, nil
+	case "eq":
+		return psBool(a.v == b.v), nil
+	case "ne":
+		return psBool(a.v != b.v), nil
+	case "gt":
+		return psBool(a.v > b.v), nil
+	case "ge":
+		return psBool(a.v >= b.v), nil
+	case "lt":
+		return psBool(a.v < b.v), nil
+	case "le":
+		return psBool(a.v <= b.v), nil
+	case "and", "or", "xor":
+		x, y := int(a.v), int(b.v)
+		var r int
+		switch operator {
+		case "and":
+			r = x & y
+		case "or":
+			r = x | y
+		default:
+			r = x ^ y
+		}
+		if a.isBool && b.isBool {
+			return psBool(r != 0), nil
+		}
+		return psInt(r), nil
+	case "bitshift":
+		x, shift := int(a.v), int(b.v)
+		if shift >= 0 {
+			return psInt(x << uint(shift)), nil
+		}
+		return psInt(x >> uint(-shift)), nil
+	default:
+		return psValue{}, fmt.Errorf("unsupported PostScript operator %s", operator)
+	}
+}
@@ -0,0 +1,307 @@
+// Package compare reports the differences between two documents,
+// at the level of the object model: page count and geometry,
+// annotations, form field values and metadata.
+//
+// It is mainly intended to write regression tests of
+// PDF pipelines, where the exact bytes of the output file
+// are not relevant.
+package compare
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/benoitkugler/pdf/model"
+)
+
+// Kind categorizes the differences.
+type Kind uint8
+
+const (
+	PageCount   Kind = iota
+	PageBox          // media box or rotation of a page
+	PageContent      // content streams of a page
+	AnnotationAdded
+	AnnotationRemoved
+	FieldAdded
+	FieldRemoved
+	FieldValue
+	Metadata // Info dictionary or XMP metadata
+)
+
+func (k Kind) String() string {
+	switch k {
+	case PageCount:
+		return "page count"
+	case PageBox:
+		return "page box"
+	case PageContent:
+		return "page content"
+	case AnnotationAdded:
+		return "annotation added"
+	case AnnotationRemoved:
+		return "annotation removed"
+	case FieldAdded:
+		return "field added"
+	case FieldRemoved:
+		return "field removed"
+	case FieldValue:
+		return "field value"
+	case Metadata:
+		return "metadata"
+	default:
+		return fmt.Sprintf("<kind %d>", k)
+	}
+}
+
+// Difference is one change between two documents.
+type Difference struct {
+	Kind Kind
+	// Location is a path to the changed element, such as
+	// "Pages[2]/MediaBox", "AcroForm/address.city" or "Info/Title"
+	Location string
+	// Old and New are human readable values of the element,
+	// empty for added or removed ones.
+	Old, New string
+}
+
+func (d Difference) String() string {
+	return fmt.Sprintf("%s: %s (%q -> %q)", d.Location, d.Kind, d.Old, d.New)
+}
+
+// Options controls which elements are compared.
+type Options struct {
+	IgnoreContent bool // do not compare the content streams of the pages
+	IgnoreDates   bool // do not compare the creation and modification dates, nor the XMP metadata
+}
+
+// Documents compares `old` and `new`, returning an empty slice
+// if no difference is found.
+func Documents(old, new *model.Document, opts Options) []Difference {
+	var out []Difference
+	out = append(out, comparePages(old, new, opts)...)
+	out = append(out, compareFields(old.Catalog.AcroForm, new.Catalog.AcroForm)...)
+	out = append(out, compareMetadata(old, new, opts)...)
+	return out
+}
+
+// page with its inherited media box
+type inheritedPage struct {
+	page     *model.PageObject
+	mediaBox *model.Rectangle
+}
+
+func flatten(tree *model.PageTree, mediaBox *model.Rectangle) []inheritedPage {
+	if tree.MediaBox != nil {
+		mediaBox = tree.MediaBox
+	}
+	var out []inheritedPage
+	for _, kid := range tree.Kids {
+		switch kid := kid.(type) {
+		case *model.PageTree:
+			out = append(out, flatten(kid, mediaBox)...)
+		case *model.PageObject:
+			page := inheritedPage{page: kid, mediaBox: mediaBox}
+			if kid.MediaBox != nil {
+				page.mediaBox = kid.MediaBox
+			}
+			out = append(out, page)
+		}
+	}
+	return out
+}
+
+func boxString(r *model.Rectangle) string {
+	if r == nil {
+		return ""
+	}
+	return r.String()
+}
+
+func comparePages(old, new *model.Document, opts Options) []Difference {
+	oldPages, newPages := flatten(&old.Catalog.Pages, nil), flatten(&new.Catalog.Pages, nil)
+	var out []Difference
+	if len(oldPages) != len(newPages) {
+		out = append(out, Difference{Kind: PageCount, Location: "Pages",
+			Old: fmt.Sprint(len(oldPages)), New: fmt.Sprint(len(newPages))})
+	}
+	n := len(oldPages)
+	if len(newPages) < n {
+		n = len(newPages)
+	}
+	for i := 0; i < n; i++ {
+		out = append(out, comparePage(fmt.Sprintf("Pages[%d]", i), oldPages[i], newPages[i], opts)...)
+	}
+	return out
+}
+
+func comparePage(location string, old, new inheritedPage, opts Options) []Difference {
+	var out []Difference
+	if o, n := boxString(old.mediaBox), boxString(new.mediaBox); o != n {
+		out = append(out, Difference{Kind: PageBox, Location: location + "/MediaBox", Old: o, New: n})
+	}
+	if o, n := boxString(old.page.CropBox), boxString(new.page.CropBox); o != n {
+		out = append(out, Difference{Kind: PageBox, Location: location + "/CropBox", Old: o, New: n})
+	}
+	if o, n := old.page.Rotate.Degrees(), new.page.Rotate.Degrees(); o != n {
+		out = append(out, Difference{Kind: PageBox, Location: location + "/Rotate", Old: fmt.Sprint(o), New: fmt.Sprint(n)})
+	}
+
+	if !opts.IgnoreContent {
+		o, errO := old.page.DecodeAllContents()
+		n, errN := new.page.DecodeAllContents()
+		if errO != nil || errN != nil || !bytes.Equal(o, n) {
+			out = append(out, Difference{Kind: PageContent, Location: location + "/Contents",
+				Old: fmt.Sprintf("%d bytes", len(o)), New: fmt.Sprintf("%d bytes", len(n))})
+		}
+	}
+
+	out = append(out, compareAnnotations(location+"/Annots", old.page.Annots, new.page.Annots)...)
+	return out
+}
+
+// annotationString returns a summary of the annotation,
+// used to match the annotations of two pages
+func annotationString(annot *model.AnnotationDict) string {
+	kind := strings.TrimPrefix(fmt.Sprintf("%T", annot.Subtype), "model.Annotation")
+	out := fmt.Sprintf("%s %s", kind, annot.Rect)
+	if annot.NM != "" {
+		out += fmt.Sprintf(" NM=%q", annot.NM)
+	}
+	if annot.Contents != "" {
+		out += fmt.Sprintf(" %q", annot.Contents)
+	}
+	return out
+}
+
+// compareAnnotations matches the annotations by their subtype,
+// rectangle, name and contents, ignoring their order
+func compareAnnotations(location string, old, new []*model.AnnotationDict) []Difference {
+	remaining := make(map[string]int)
+	for _, annot := range new {
+		remaining[annotationString(annot)]++
+	}
+	var out []Difference
+	for _, annot := range old {
+		s := annotationString(annot)
+		if remaining[s] > 0 {
+			remaining[s]--
+			continue
+		}
+		out = append(out, Difference{Kind: AnnotationRemoved, Location: location, Old: s})
+	}
+	for _, annot := range new {
+		s := annotationString(annot)
+		if remaining[s] > 0 {
+			remaining[s]--
+			out = append(out, Difference{Kind: AnnotationAdded, Location: location, New: s})
+		}
+	}
+	return out
+}
+
+// fieldValue returns the value of the field, formatted as text
+func fieldValue(field model.FormField) string {
+	switch field := field.(type) {
+	case model.FormFieldText:
+		return field.V
+	case model.FormFieldButton:
+		return string(field.V)
+	case model.FormFieldChoice:
+		return strings.Join(field.V, ", ")
+	case model.FormFieldSignature:
+		if field.V != nil {
+			return "<signed>"
+		}
+	}
+	return ""
+}
+
+func sortedFieldNames(fields map[string]model.FormFieldInherited) []string {
+	out := make([]string, 0, len(fields))
+	for name := range fields {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func compareFields(old, new model.AcroForm) []Difference {
+	oldFields, newFields := old.Flatten(), new.Flatten()
+	var out []Difference
+	for _, name := range sortedFieldNames(oldFields) {
+		location := "AcroForm/" + name
+		oldValue := fieldValue(oldFields[name].Merged.FT)
+		newField, ok := newFields[name]
+		if !ok {
+			out = append(out, Difference{Kind: FieldRemoved, Location: location, Old: oldValue})
+			continue
+		}
+		if newValue := fieldValue(newField.Merged.FT); newValue != oldValue {
+			out = append(out, Difference{Kind: FieldValue, Location: location, Old: oldValue, New: newValue})
+		}
+	}
+	for _, name := range sortedFieldNames(newFields) {
+		if _, ok := oldFields[name]; !ok {
+			out = append(out, Difference{Kind: FieldAdded, Location: "AcroForm/" + name, New: fieldValue(newFields[name].Merged.FT)})
+		}
+	}
+	return out
+}
+
+func dateString(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func compareMetadata(old, new *model.Document, opts Options) []Difference {
+	o, n := old.Trailer.Info, new.Trailer.Info
+	entries := [][3]string{
+		{"Title", o.Title, n.Title},
+		{"Author", o.Author, n.Author},
+		{"Subject", o.Subject, n.Subject},
+		{"Keywords", o.Keywords, n.Keywords},
+		{"Creator", o.Creator, n.Creator},
+		{"Producer", o.Producer, n.Producer},
+	}
+	if !opts.IgnoreDates {
+		entries = append(entries,
+			[3]string{"CreationDate", dateString(o.CreationDate), dateString(n.CreationDate)},
+			[3]string{"ModDate", dateString(o.ModDate), dateString(n.ModDate)},
+		)
+	}
+	var out []Difference
+	for _, entry := range entries {
+		if entry[1] != entry[2] {
+			out = append(out, Difference{Kind: Metadata, Location: "Info/" + entry[0], Old: entry[1], New: entry[2]})
+		}
+	}
+
+	// XMP metadata is compared as a whole, since its content usually
+	// includes the dates, it is skipped with IgnoreDates
+	if !opts.IgnoreDates {
+		oldXMP, newXMP := metadataContent(old.Catalog.Metadata), metadataContent(new.Catalog.Metadata)
+		if !bytes.Equal(oldXMP, newXMP) {
+			out = append(out, Difference{Kind: Metadata, Location: "Metadata",
+				Old: fmt.Sprintf("%d bytes", len(oldXMP)), New: fmt.Sprintf("%d bytes", len(newXMP))})
+		}
+	}
+	return out
+}
+
+// metadataContent returns the decoded XMP packet, or nil
+func metadataContent(m *model.MetadataStream) []byte {
+	if m == nil {
+		return nil
+	}
+	content, err := m.Decode()
+	if err != nil { // compare the raw content instead
+		return m.Content
+	}
+	return content
+}
@@ -0,0 +1,66 @@
+package compare
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/benoitkugler/pdf/model"
+)
+
+func newDocument() model.Document {
+	var doc model.Document
+	doc.Catalog.Pages.MediaBox = &model.Rectangle{Urx: 600, Ury: 800}
+	for i := 0; i < 2; i++ {
+		page := &model.PageObject{
+			Contents: []model.ContentStream{{Stream: model.Stream{Content: []byte("0 0 10 10 re f")}}},
+			Annots:   []*model.AnnotationDict{{Subtype: model.AnnotationText{}, BaseAnnotation: model.BaseAnnotation{Contents: "note"}}},
+		}
+		doc.Catalog.Pages.Kids = append(doc.Catalog.Pages.Kids, page)
+	}
+	doc.Catalog.AcroForm.Fields = []*model.FormFieldDict{
+		{T: "name", FormFieldInheritable: model.FormFieldInheritable{FT: model.FormFieldText{V: "John"}}},
+		{T: "agree", FormFieldInheritable: model.FormFieldInheritable{FT: model.FormFieldButton{V: "Off"}}},
+	}
+	doc.Trailer.Info.Title = "Report"
+	return doc
+}
+
+func TestIdentical(t *testing.T) {
+	old, new := newDocument(), newDocument()
+	if diffs := Documents(&old, &new, Options{}); len(diffs) != 0 {
+		t.Fatalf("unexpected differences %v", diffs)
+	}
+}
+
+func TestDocuments(t *testing.T) {
+	old, new := newDocument(), newDocument()
+	pages := new.Catalog.Pages.Flatten()
+	pages[0].MediaBox = &model.Rectangle{Urx: 500, Ury: 800}
+	pages[1].Contents[0].Content = []byte("0 0 20 20 re f")
+	pages[1].Annots = append(pages[1].Annots, &model.AnnotationDict{Subtype: model.AnnotationSquare{}})
+	new.Catalog.Pages.Kids = append(new.Catalog.Pages.Kids, &model.PageObject{})
+	new.Catalog.AcroForm.Fields[0].FT = model.FormFieldText{V: "Jane"}
+	new.Catalog.AcroForm.Fields = new.Catalog.AcroForm.Fields[:1]
+	new.Trailer.Info.Title = "Final report"
+
+	var got []string
+	for _, diff := range Documents(&old, &new, Options{}) {
+		got = append(got, diff.Kind.String()+" "+diff.Location)
+	}
+	exp := []string{
+		"page count Pages",
+		"page box Pages[0]/MediaBox",
+		"page content Pages[1]/Contents",
+		"annotation added Pages[1]/Annots",
+		"field removed AcroForm/agree",
+		"field value AcroForm/name",
+		"metadata Info/Title",
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Fatalf("expected %v, got %v", exp, got)
+	}
+
+	if diffs := Documents(&old, &new, Options{IgnoreContent: true}); len(diffs) != len(exp)-1 {
+		t.Fatalf("unexpected differences %v", diffs)
+	}
+}
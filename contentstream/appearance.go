@@ -236,6 +236,7 @@ func (ap *GraphicStream) MoveText(x, y Fl) {
 
 // ShowText shows the `text`, after encoding it
 // according to the current font.
+// The kerning of the font is applied, if any (see fonts.EncodeKern).
 // And error is returned (only) if a font has not been setup.
 // A typical text drawing should apply the following methods ;
 //   - BeginText
@@ -246,19 +247,32 @@ func (ap *GraphicStream) ShowText(text string) error {
 	if ap.State.Font.Font == nil {
 		return errNoFont
 	}
-	s := string(ap.State.Font.Encode([]rune(text)))
-	ap.Ops(OpShowText{Text: s})
+	ap.Ops(ap.showTextOp(text))
 	return nil
 }
 
+// showTextOp encodes `text` with the current font, and returns
+// a TJ operation if kerning is needed, or a simple Tj operation.
+func (ap *GraphicStream) showTextOp(text string) Operation {
+	texts := fonts.EncodeKern(ap.State.Font, []rune(text))
+	if len(texts) == 1 {
+		return OpShowText{Text: string(texts[0].CharCodes)}
+	}
+	return OpShowSpaceText{Texts: texts}
+}
+
 // NewlineShowText moves to the next line and shows text.
 func (ap *GraphicStream) NewlineShowText(text string) error {
 	if ap.State.Font.Font == nil {
 		return errNoFont
 	}
 	ap.State.YTLM -= ap.State.Leading
-	s := string(ap.State.Font.Encode([]rune(text)))
-	ap.Ops(OpMoveShowText{Text: s})
+	switch op := ap.showTextOp(text).(type) {
+	case OpShowText:
+		ap.Ops(OpMoveShowText{Text: op.Text})
+	default: // there is no TJ variant of '
+		ap.Ops(OpTextNextLine{}, op)
+	}
 	return nil
 }
 
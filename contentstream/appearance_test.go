@@ -2,6 +2,7 @@ package contentstream
 
 import (
 	"fmt"
+	"path/filepath"
 	"testing"
 
 	"github.com/benoitkugler/pdf/fonts"
@@ -49,7 +50,7 @@ func TestKerning(t *testing.T) {
 	doc.Catalog.Pages.Kids = []model.PageNode{
 		a.toPageObject(),
 	}
-	err = doc.WriteFile("test/kerning.pdf", nil)
+	err = doc.WriteFile(filepath.Join(t.TempDir(), "kerning.pdf"), nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -74,7 +75,7 @@ func TestImages(t *testing.T) {
 		doc.Catalog.Pages.Kids = append(doc.Catalog.Pages.Kids, a.toPageObject())
 	}
 
-	err := doc.WriteFile("test/images.pdf", nil)
+	err := doc.WriteFile(filepath.Join(t.TempDir(), "images.pdf"), nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -95,7 +96,7 @@ func TestRoundedRect(t *testing.T) {
 
 	doc.Catalog.Pages.Kids = append(doc.Catalog.Pages.Kids, a.toPageObject())
 
-	err := doc.WriteFile("test/rectangles.pdf", nil)
+	err := doc.WriteFile(filepath.Join(t.TempDir(), "rectangles.pdf"), nil)
 	if err != nil {
 		t.Fatal(err)
 	}
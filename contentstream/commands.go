@@ -10,6 +10,8 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 
 	pdfFonts "github.com/benoitkugler/pdf/fonts"
@@ -758,8 +760,8 @@ type ImageColorSpaceIndexed struct {
 }
 
 func (c ImageColorSpaceIndexed) Write() string {
-	return fmt.Sprintf("[/Indexed %s %d %s]",
-		model.ObjName(c.Base), c.Hival, c.Lookup)
+	return fmt.Sprintf("[/Indexed %s %d <%x>]",
+		model.ObjName(c.Base), c.Hival, []byte(c.Lookup))
 }
 
 func (c ImageColorSpaceIndexed) ToColorSpace() model.ColorSpace {
@@ -773,24 +775,95 @@ type OpBeginImage struct {
 }
 
 func (o OpBeginImage) Add(out *bytes.Buffer) {
-	out.WriteString("BI ")
-	for k, v := range o.Image.PDFFields(true).Fields {
-		out.WriteString(k.String() + " " + v)
+	out.WriteString("BI")
+	fields := o.Image.PDFFields(true).Fields
+	hasParams := false
+	for _, fi := range o.Image.Filter {
+		hasParams = hasParams || len(fi.DecodeParms) != 0
+	}
+	if !hasParams { // avoid useless null entries
+		delete(fields, "DecodeParms")
+	}
+	// the Length entry (PDF 2.0) avoids relying on the EI keyword
+	fields["L"] = strconv.Itoa(len(o.Image.Content))
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, string(k))
+	}
+	sort.Strings(keys) // deterministic output
+	for _, k := range keys {
+		out.WriteString(" " + model.Name(k).String() + " " + fields[model.Name(k)])
 	}
 	if o.ColorSpace != nil {
 		out.WriteString(" /CS " + o.ColorSpace.Write())
 	}
 	out.WriteString(" ID ") // one space
 	out.Write(o.Image.Content)
-	out.WriteString("EI")
+	out.WriteString("\nEI")
+}
+
+// NewInlineImage returns the inline form of `img`.
+// Since inline images are restricted, an error is returned
+// if `img` has a mask, or if its color space is not a name or an Indexed color space
+// with a device base and a byte string lookup table.
+// Note that names other than the device color spaces must be defined
+// in the resources of the content stream.
+func NewInlineImage(img *model.XObjectImage) (OpBeginImage, error) {
+	if img.Mask != nil || img.SMask != nil {
+		return OpBeginImage{}, errors.New("masks are not supported by inline images")
+	}
+	out := OpBeginImage{Image: img.Image.Clone()}
+	switch colorSpace := img.ColorSpace.(type) {
+	case nil:
+	case model.ColorSpaceName:
+		out.ColorSpace = ImageColorSpaceName{ColorSpaceName: colorSpace}
+	case model.ColorSpaceIndexed:
+		base, ok := colorSpace.Base.(model.ColorSpaceName)
+		if !ok {
+			return OpBeginImage{}, fmt.Errorf("unsupported base color space %T for inline image", colorSpace.Base)
+		}
+		lookup, ok := colorSpace.Lookup.(model.ColorTableBytes)
+		if !ok {
+			return OpBeginImage{}, errors.New("unsupported color table stream for inline image")
+		}
+		out.ColorSpace = ImageColorSpaceIndexed{Base: base, Hival: colorSpace.Hival, Lookup: append(model.ColorTableBytes(nil), lookup...)}
+	default:
+		return OpBeginImage{}, fmt.Errorf("unsupported color space %T for inline image", colorSpace)
+	}
+	return out, nil
+}
+
+// ToXObject returns the image XObject equivalent to the inline image.
+// The color space names other than the device color spaces are resolved
+// using `res`, the color spaces of the resources of the content stream.
+func (img OpBeginImage) ToXObject(res model.ResourcesColorSpace) (*model.XObjectImage, error) {
+	out := &model.XObjectImage{Image: img.Image.Clone()}
+	switch colorSpace := img.ColorSpace.(type) {
+	case ImageColorSpaceName:
+		switch colorSpace.ColorSpaceName {
+		case model.ColorSpaceGray, model.ColorSpaceRGB, model.ColorSpaceCMYK:
+			out.ColorSpace = colorSpace.ColorSpaceName
+		default:
+			var err error
+			out.ColorSpace, err = res.Resolve(colorSpace.ColorSpaceName)
+			if err != nil {
+				return nil, err
+			}
+		}
+	case ImageColorSpaceIndexed:
+		indexed := colorSpace.ToColorSpace().(model.ColorSpaceIndexed)
+		indexed.Lookup = append(model.ColorTableBytes(nil), colorSpace.Lookup...)
+		out.ColorSpace = indexed
+	}
+	return out, nil
 }
 
 // Metrics returns the number of color components and the number of bits for each.
 // An error is returned if the color space can't be resolved from the resources dictionary.
 func (img OpBeginImage) Metrics(res model.ResourcesColorSpace) (comps, bits int, err error) {
 	bits = int(img.Image.BitsPerComponent)
-	if img.Image.ImageMask {
-		bits = 1
+	if img.Image.ImageMask { // no color space
+		return 1, 1, nil
 	}
 	colorSpace, err := img.resolveColorSpace(res)
 	if err != nil {
@@ -293,17 +293,22 @@ func (gr GradientComplex) BuildShadings() (color, alpha *model.ShadingDict) {
 	return color, alpha
 }
 
-// SetAlphaMask adds the given `transparency` content as an alpha mask in
-// the graphic state.
+// SetAlphaMask adds the given `transparency` content as a luminosity mask in
+// the graphic state (see model.NewSoftMask).
 func (ap *GraphicStream) SetAlphaMask(transparency *model.XObjectForm) {
-	alphaState := model.GraphicState{
-		SMask: model.SoftMaskDict{
-			S: model.ObjName("Luminosity"),
-			G: &model.XObjectTransparencyGroup{XObjectForm: *transparency},
-		},
-		Ca:  model.ObjFloat(1),
-		AIS: false,
-	}
+	alphaState, _ := model.NewSoftMask(transparency, model.SoftMaskLuminosity) // the subtype is valid
+	alphaState.Ca = model.ObjFloat(1)
 
-	ap.SetGraphicState(&alphaState)
+	ap.SetGraphicState(alphaState)
+}
+
+// SetSoftMask applies the given soft mask to the subsequent
+// painting operations (see model.NewSoftMask).
+func (ap *GraphicStream) SetSoftMask(mask *model.XObjectForm, subtype model.Name) error {
+	state, err := model.NewSoftMask(mask, subtype)
+	if err != nil {
+		return err
+	}
+	ap.SetGraphicState(state)
+	return nil
 }
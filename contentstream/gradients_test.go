@@ -1,6 +1,7 @@
 package contentstream
 
 import (
+	"path/filepath"
 	"testing"
 
 	"github.com/benoitkugler/pdf/model"
@@ -45,7 +46,7 @@ func TestGradient(t *testing.T) {
 
 	doc.Catalog.Pages.Kids = append(doc.Catalog.Pages.Kids, a.toPageObject())
 
-	err := doc.WriteFile("test/gradients.pdf", nil)
+	err := doc.WriteFile(filepath.Join(t.TempDir(), "gradients.pdf"), nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -104,7 +105,7 @@ func TestGradientTransform(t *testing.T) {
 
 	doc.Catalog.Pages.Kids = append(doc.Catalog.Pages.Kids, a.toPageObject())
 
-	err := doc.WriteFile("test/gradient_transform.pdf", nil)
+	err := doc.WriteFile(filepath.Join(t.TempDir(), "gradient_transform.pdf"), nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -143,7 +144,7 @@ func TestMultiGradient(t *testing.T) {
 
 	doc.Catalog.Pages.Kids = append(doc.Catalog.Pages.Kids, a.toPageObject())
 
-	err := doc.WriteFile("test/gradient_multi.pdf", nil)
+	err := doc.WriteFile(filepath.Join(t.TempDir(), "gradient_multi.pdf"), nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -209,7 +210,7 @@ func TestGradientOpacity(t *testing.T) {
 
 	doc.Catalog.Pages.Kids = append(doc.Catalog.Pages.Kids, a.toPageObject())
 
-	err := doc.WriteFile("test/gradient_opacity.pdf", nil)
+	err := doc.WriteFile(filepath.Join(t.TempDir(), "gradient_opacity.pdf"), nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -228,7 +229,7 @@ func TestOpacity(t *testing.T) {
 
 	doc.Catalog.Pages.Kids = append(doc.Catalog.Pages.Kids, a.toPageObject())
 
-	err := doc.WriteFile("/tmp/opa.pdf", nil)
+	err := doc.WriteFile(filepath.Join(t.TempDir(), "opa.pdf"), nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -1,10 +1,14 @@
 package contentstream
 
 import (
+	"bytes"
 	"fmt"
 	"image"
 	"os"
+	"reflect"
 	"testing"
+
+	"github.com/benoitkugler/pdf/model"
 )
 
 var imagesFiles = [...]string{
@@ -42,3 +46,58 @@ func TestImageFormat(t *testing.T) {
 		fmt.Println(file, format)
 	}
 }
+
+func TestInlineImage(t *testing.T) {
+	indexed := model.ColorSpaceIndexed{Base: model.ColorSpaceRGB, Hival: 1, Lookup: model.ColorTableBytes{0xff, 0, 0, 0, 0, 0xff}}
+	for _, cs := range []model.ColorSpace{nil, model.ColorSpaceGray, indexed} {
+		img := &model.XObjectImage{
+			Image:      model.Image{Width: 2, Height: 2, BitsPerComponent: 8, Stream: model.Stream{Content: []byte{0, 1, 1, 0}}},
+			ColorSpace: cs,
+		}
+		op, err := NewInlineImage(img)
+		if err != nil {
+			t.Fatal(err)
+		}
+		back, err := op.ToXObject(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(img, back) {
+			t.Fatalf("expected %v, got %v", img, back)
+		}
+	}
+
+	// named color spaces are resolved from the resources
+	op := OpBeginImage{ColorSpace: ImageColorSpaceName{ColorSpaceName: "CS0"}}
+	img, err := op.ToXObject(model.ResourcesColorSpace{"CS0": indexed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(img.ColorSpace, indexed) {
+		t.Fatalf("unexpected color space %v", img.ColorSpace)
+	}
+	if _, err = op.ToXObject(nil); err == nil {
+		t.Fatal("expected error for missing color space")
+	}
+
+	for _, img := range []*model.XObjectImage{
+		{SMask: &model.ImageSMask{}},
+		{ColorSpace: model.ColorSpaceIndexed{Base: model.ColorSpaceRGB, Lookup: &model.ColorTableStream{}}},
+		{ColorSpace: &model.ColorSpaceICCBased{N: 3}},
+	} {
+		if _, err = NewInlineImage(img); err == nil {
+			t.Fatalf("expected error for unsupported image %v", img)
+		}
+	}
+
+	// binary data is preserved, even if it contains the EI keyword
+	op = OpBeginImage{
+		Image:      model.Image{Width: 3, Height: 1, BitsPerComponent: 8, Stream: model.Stream{Content: []byte(" EI")}},
+		ColorSpace: ImageColorSpaceIndexed{Base: model.ColorSpaceRGB, Hival: 1, Lookup: model.ColorTableBytes{0xff, 0, 0, 0, 0, 0xff}},
+	}
+	var buf bytes.Buffer
+	op.Add(&buf)
+	if exp := "BI /BPC 8 /H 1 /L 3 /W 3 /CS [/Indexed /DeviceRGB 1 <ff00000000ff>] ID  EI\nEI"; buf.String() != exp {
+		t.Fatalf("expected %q, got %q", exp, buf.String())
+	}
+}
@@ -0,0 +1,128 @@
+package contentstream
+
+import "github.com/benoitkugler/pdf/model"
+
+// Artifact describes content which is not part of the
+// logical structure of the document, such as page headers and footers, watermarks or
+// decorative elements.
+// See 14.8.2.2.2 - Real Content and Artifacts
+type Artifact struct {
+	Type     model.Name       // optional, Pagination, Layout, Page or Background
+	Subtype  model.Name       // optional, for pagination artifacts : Header, Footer or Watermark
+	BBox     *model.Rectangle // optional, required for background artifacts
+	Attached []model.Name     // optional, Top, Bottom, Left or Right
+}
+
+// PropertyList returns the property list of the Artifact
+// marked-content sequence, or nil if `a` has no properties.
+func (a Artifact) PropertyList() PropertyList {
+	out := PropertyListDict{}
+	if a.Type != "" {
+		out["Type"] = a.Type
+	}
+	if a.Subtype != "" {
+		out["Subtype"] = a.Subtype
+	}
+	if a.BBox != nil {
+		out["BBox"] = model.ObjArray{
+			model.ObjFloat(a.BBox.Llx), model.ObjFloat(a.BBox.Lly),
+			model.ObjFloat(a.BBox.Urx), model.ObjFloat(a.BBox.Ury),
+		}
+	}
+	if len(a.Attached) != 0 {
+		attached := make(model.ObjArray, len(a.Attached))
+		for i, side := range a.Attached {
+			attached[i] = side
+		}
+		out["Attached"] = attached
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// MarkedContentSequence encloses `ops` in a marked-content sequence
+// with the given tag and (optional) properties.
+func MarkedContentSequence(tag model.ObjName, properties PropertyList, ops ...Operation) []Operation {
+	out := make([]Operation, 0, len(ops)+2)
+	out = append(out, OpBeginMarkedContent{Tag: tag, Properties: properties})
+	out = append(out, ops...)
+	return append(out, OpEndMarkedContent{})
+}
+
+// ArtifactSequence marks `ops` as an artifact.
+func ArtifactSequence(a Artifact, ops ...Operation) []Operation {
+	return MarkedContentSequence("Artifact", a.PropertyList(), ops...)
+}
+
+// BeginMarkedContent starts a marked-content sequence, with
+// optional properties. It must be closed by EndMarkedContent.
+func (ap *GraphicStream) BeginMarkedContent(tag model.ObjName, properties PropertyList) {
+	ap.Ops(OpBeginMarkedContent{Tag: tag, Properties: properties})
+}
+
+// BeginMarkedContentID starts a marked-content sequence identified by `mcid`,
+// which may be referenced by a structure element (see model.ContentItemMarkedReference).
+// It must be closed by EndMarkedContent.
+func (ap *GraphicStream) BeginMarkedContentID(tag model.ObjName, mcid int) {
+	ap.BeginMarkedContent(tag, PropertyListDict{"MCID": model.ObjInt(mcid)})
+}
+
+// BeginArtifact starts an artifact sequence.
+// It must be closed by EndMarkedContent.
+func (ap *GraphicStream) BeginArtifact(a Artifact) {
+	ap.BeginMarkedContent("Artifact", a.PropertyList())
+}
+
+// EndMarkedContent ends a marked-content sequence.
+func (ap *GraphicStream) EndMarkedContent() {
+	ap.Ops(OpEndMarkedContent{})
+}
+
+// MarkedContentID returns the marked-content identifier (MCID)
+// of the given marked-content sequence, or -1 if there is none.
+// `properties` is used to resolve named property lists, and may be nil.
+func (o OpBeginMarkedContent) MarkedContentID(properties map[model.Name]model.PropertyList) int {
+	var dict model.ObjDict
+	switch pr := o.Properties.(type) {
+	case PropertyListDict:
+		dict = model.ObjDict(pr)
+	case PropertyListName:
+		dict = properties[model.Name(pr)]
+	}
+	if mcid, ok := dict["MCID"].(model.ObjInt); ok {
+		return int(mcid)
+	}
+	return -1
+}
+
+// MarkedContents groups the given operations by marked-content sequences,
+// returning a map from MCID to the operations of the sequence, excluding the
+// enclosing BDC and EMC operators.
+// Nested sequences are included in their parent, and also returned on their own.
+// `properties` is used to resolve named property lists, and may be nil.
+func MarkedContents(ops []Operation, properties map[model.Name]model.PropertyList) map[int][]Operation {
+	out := make(map[int][]Operation)
+	type opened struct {
+		mcid  int // -1 for sequences without identifier
+		start int // index of the first operation
+	}
+	var stack []opened
+	for i, op := range ops {
+		switch op := op.(type) {
+		case OpBeginMarkedContent:
+			stack = append(stack, opened{mcid: op.MarkedContentID(properties), start: i + 1})
+		case OpEndMarkedContent:
+			if len(stack) == 0 { // invalid EMC: ignore it
+				continue
+			}
+			last := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if last.mcid >= 0 {
+				out[last.mcid] = append(out[last.mcid], ops[last.start:i]...)
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,66 @@
+package contentstream
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/benoitkugler/pdf/model"
+)
+
+func TestMarkedContents(t *testing.T) {
+	ops := []Operation{
+		OpBeginMarkedContent{Tag: "P", Properties: PropertyListDict{"MCID": model.ObjInt(0)}},
+		OpBeginText{},
+		OpBeginMarkedContent{Tag: "Span", Properties: PropertyListName("MC1")},
+		OpShowText{Text: "nested"},
+		OpEndMarkedContent{},
+		OpEndText{},
+		OpEndMarkedContent{},
+		OpBeginMarkedContent{Tag: "Artifact"},
+		OpShowText{Text: "ignored"},
+		OpEndMarkedContent{},
+		OpEndMarkedContent{}, // invalid
+	}
+	properties := map[model.Name]model.PropertyList{"MC1": {"MCID": model.ObjInt(1)}}
+	mcs := MarkedContents(ops, properties)
+	if len(mcs) != 2 {
+		t.Fatalf("unexpected marked contents %v", mcs)
+	}
+	if len(mcs[0]) != 5 {
+		t.Fatalf("unexpected operations %v", mcs[0])
+	}
+	if len(mcs[1]) != 1 || mcs[1][0] != (OpShowText{Text: "nested"}) {
+		t.Fatalf("unexpected operations %v", mcs[1])
+	}
+}
+
+func TestArtifact(t *testing.T) {
+	if pr := (Artifact{}).PropertyList(); pr != nil {
+		t.Fatalf("expected no properties, got %v", pr)
+	}
+	a := Artifact{Type: "Pagination", Subtype: "Header", BBox: &model.Rectangle{Urx: 100, Ury: 20}, Attached: []model.Name{"Top"}}
+	pr := a.PropertyList().(PropertyListDict)
+	if len(pr) != 4 || pr["Subtype"] != model.Name("Header") {
+		t.Fatalf("unexpected properties %v", pr)
+	}
+
+	ops := ArtifactSequence(a, OpRectangle{W: 100, H: 20}, OpFill{})
+	if len(ops) != 4 || ops[0].(OpBeginMarkedContent).Tag != "Artifact" || ops[3] != (OpEndMarkedContent{}) {
+		t.Fatalf("unexpected operations %v", ops)
+	}
+
+	gs := NewGraphicStream(model.Rectangle{Urx: 100, Ury: 100})
+	gs.BeginArtifact(Artifact{})
+	gs.Ops(OpFill{})
+	gs.EndMarkedContent()
+	gs.BeginMarkedContentID("P", 4)
+	gs.Ops(OpFill{})
+	gs.EndMarkedContent()
+	content := strings.Join(strings.Fields(string(gs.ToXFormObject(false).Content)), " ")
+	if !strings.HasPrefix(content, "/Artifact BMC f EMC /P <<") || !strings.HasSuffix(content, "BDC f EMC") {
+		t.Fatalf("unexpected content %s", content)
+	}
+	if mcs := MarkedContents(gs.ops, nil); len(mcs) != 1 || len(mcs[4]) != 1 {
+		t.Fatalf("unexpected marked contents %v", mcs)
+	}
+}
@@ -0,0 +1,252 @@
+package contentstream
+
+import (
+	"bytes"
+
+	pdfFonts "github.com/benoitkugler/pdf/fonts"
+)
+
+// Block is a self-contained sequence of operations of a content stream,
+// given by the indices [Start, End) in the operations slice.
+type Block struct {
+	Start, End int
+	// Removable is true for the blocks which do not
+	// change the graphic state: saved states (q/Q), paths (including their
+	// painting operator), images, XObjects and shadings.
+	// Text objects (BT/ET) and marked-content sequences are removable
+	// only if the operators setting the graphic state (such as Tf, rg or cm)
+	// they contain are enclosed in a saved state, since these settings
+	// persist after the end of the group.
+	Removable bool
+}
+
+// returns +1 for operators opening a group, -1 for operators
+// closing it, 0 otherwise
+func groupDelta(op Operation) int {
+	switch op.(type) {
+	case OpSave, OpBeginText, OpBeginMarkedContent:
+		return 1
+	case OpRestore, OpEndText, OpEndMarkedContent:
+		return -1
+	default:
+		return 0
+	}
+}
+
+// setsState returns true for the operators modifying the graphic state,
+// including the text state. Text positioning operators are excluded,
+// since the text matrix is reset by each BT operator.
+func setsState(op Operation) bool {
+	switch op.(type) {
+	case OpSetFillGray, OpSetStrokeGray, OpSetFillRGBColor, OpSetStrokeRGBColor,
+		OpSetFillCMYKColor, OpSetStrokeCMYKColor, OpSetFillColorSpace, OpSetStrokeColorSpace,
+		OpSetFillColor, OpSetStrokeColor, OpSetFillColorN, OpSetStrokeColorN,
+		OpSetLineCap, OpSetLineJoin, OpSetMiterLimit, OpSetLineWidth, OpSetFlat, OpSetDash,
+		OpSetRenderingIntent, OpSetExtGState, OpConcat, OpClip, OpEOClip,
+		OpSetFont, OpSetTextLeading, OpSetCharSpacing, OpSetWordSpacing,
+		OpSetHorizScaling, OpSetTextRender, OpSetTextRise:
+		return true
+	default:
+		return false
+	}
+}
+
+// isIsolated returns true if the state-setting operators
+// of the balanced group `ops` are enclosed in q/Q.
+func isIsolated(ops []Operation) bool {
+	saved := 0
+	for _, op := range ops {
+		switch op.(type) {
+		case OpSave:
+			saved++
+		case OpRestore:
+			saved--
+		default:
+			if saved == 0 && setsState(op) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func isPathConstruction(op Operation) bool {
+	switch op.(type) {
+	case OpMoveTo, OpLineTo, OpCubicTo, OpCurveTo, OpRectangle, OpClosePath, OpClip, OpEOClip:
+		return true
+	default:
+		return false
+	}
+}
+
+func isPathPainting(op Operation) bool {
+	switch op.(type) {
+	case OpStroke, OpCloseStroke, OpFill, OpEOFill, OpFillStroke, OpEOFillStroke,
+		OpCloseFillStroke, OpCloseEOFillStroke, OpEndPath:
+		return true
+	default:
+		return false
+	}
+}
+
+// SplitBlocks partitions the operations into top level blocks.
+// An unbalanced group extends to the end of the operations,
+// and is not removable.
+func SplitBlocks(ops []Operation) []Block {
+	var out []Block
+	for i := 0; i < len(ops); {
+		start := i
+		switch {
+		case groupDelta(ops[i]) > 0:
+			depth := 0
+			for ; i < len(ops); i++ {
+				depth += groupDelta(ops[i])
+				if depth == 0 {
+					break
+				}
+			}
+			if i == len(ops) { // unbalanced
+				out = append(out, Block{Start: start, End: len(ops)})
+				return out
+			}
+			i++
+			out = append(out, Block{Start: start, End: i, Removable: isIsolated(ops[start:i])})
+		case isPathConstruction(ops[i]):
+			for i < len(ops) && isPathConstruction(ops[i]) {
+				i++
+			}
+			// only clipping paths modify the graphic state
+			removable := i < len(ops) && isPathPainting(ops[i])
+			for _, op := range ops[start:i] {
+				switch op.(type) {
+				case OpClip, OpEOClip:
+					removable = false
+				}
+			}
+			if i < len(ops) && isPathPainting(ops[i]) {
+				i++
+			}
+			out = append(out, Block{Start: start, End: i, Removable: removable})
+		default:
+			removable := false
+			switch ops[i].(type) {
+			case OpXObject, OpBeginImage, OpShFill:
+				removable = true
+			}
+			i++
+			out = append(out, Block{Start: start, End: i, Removable: removable})
+		}
+	}
+	return out
+}
+
+// RepeatedOptions tunes the detection of repeated blocks.
+type RepeatedOptions struct {
+	// MinRatio is the minimum fraction of pages on which a block
+	// must be found to be considered as repeated. It defaults to 0.8
+	MinRatio Fl
+	// IgnoreDigits considers as equal the texts differing only by their
+	// (ASCII) digits, so that page numbers are detected.
+	IgnoreDigits bool
+}
+
+// replace the ASCII digits by 0
+func normalizeDigits(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if '0' <= c && c <= '9' {
+			b[i] = '0'
+		}
+	}
+	return string(b)
+}
+
+func (opts RepeatedOptions) blockKey(ops []Operation) string {
+	if !opts.IgnoreDigits {
+		return string(WriteOperations(ops...))
+	}
+	var buf bytes.Buffer
+	for _, op := range ops {
+		switch o := op.(type) {
+		case OpShowText:
+			o.Text = normalizeDigits(o.Text)
+			op = o
+		case OpMoveShowText:
+			o.Text = normalizeDigits(o.Text)
+			op = o
+		case OpMoveSetShowText:
+			o.Text = normalizeDigits(o.Text)
+			op = o
+		case OpShowSpaceText:
+			texts := make([]pdfFonts.TextSpaced, len(o.Texts))
+			for i, t := range o.Texts {
+				t.CharCodes = []byte(normalizeDigits(string(t.CharCodes)))
+				texts[i] = t
+			}
+			o.Texts = texts
+			op = o
+		}
+		op.Add(&buf)
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
+
+// FindRepeated detects the blocks repeated on most pages,
+// typically headers, footers or watermarks, where `pages` are
+// the operations of each page.
+// The returned slice has the same length as `pages` and gives the removable
+// repeated blocks of each page, which may be then removed or extracted
+// with `SplitRepeated`.
+func FindRepeated(pages [][]Operation, opts RepeatedOptions) [][]Block {
+	if opts.MinRatio == 0 {
+		opts.MinRatio = 0.8
+	}
+	out := make([][]Block, len(pages))
+	if len(pages) < 2 {
+		return out
+	}
+
+	blocks := make([][]Block, len(pages))
+	keys := make([][]string, len(pages))
+	occurences := make(map[string]int) // number of pages
+	for i, ops := range pages {
+		blocks[i] = SplitBlocks(ops)
+		keys[i] = make([]string, len(blocks[i]))
+		seen := make(map[string]bool)
+		for j, block := range blocks[i] {
+			if !block.Removable {
+				continue
+			}
+			key := opts.blockKey(ops[block.Start:block.End])
+			keys[i][j] = key
+			if !seen[key] {
+				seen[key] = true
+				occurences[key]++
+			}
+		}
+	}
+
+	threshold := opts.MinRatio * Fl(len(pages))
+	for i := range pages {
+		for j, block := range blocks[i] {
+			if block.Removable && Fl(occurences[keys[i][j]]) >= threshold {
+				out[i] = append(out[i], block)
+			}
+		}
+	}
+	return out
+}
+
+// SplitRepeated partitions `ops` into the operations of the given blocks
+// (as returned by `FindRepeated`) and the other operations, preserving their order.
+func SplitRepeated(ops []Operation, blocks []Block) (repeated, others []Operation) {
+	cursor := 0
+	for _, block := range blocks {
+		others = append(others, ops[cursor:block.Start]...)
+		repeated = append(repeated, ops[block.Start:block.End]...)
+		cursor = block.End
+	}
+	others = append(others, ops[cursor:]...)
+	return repeated, others
+}
@@ -0,0 +1,86 @@
+package contentstream
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/benoitkugler/pdf/model"
+)
+
+func TestFindRepeated(t *testing.T) {
+	header := []Operation{OpSave{}, OpBeginText{}, OpShowText{Text: "Header"}, OpEndText{}, OpRestore{}}
+	footer := func(page string) []Operation {
+		return []Operation{OpBeginText{}, OpShowText{Text: "Page " + page}, OpEndText{}}
+	}
+	line := []Operation{OpMoveTo{X: 0, Y: 0}, OpLineTo{X: 10, Y: 0}, OpStroke{}}
+
+	var pages [][]Operation
+	for i, content := range []string{"first", "second", "third"} {
+		var ops []Operation
+		ops = append(ops, OpSetFillGray{G: 0.5})
+		ops = append(ops, header...)
+		ops = append(ops, OpBeginText{}, OpShowText{Text: content}, OpEndText{})
+		ops = append(ops, line...)
+		ops = append(ops, footer(string(rune('1'+i)))...)
+		pages = append(pages, ops)
+	}
+
+	blocks := FindRepeated(pages, RepeatedOptions{})
+	if len(blocks[0]) != 2 { // header and line
+		t.Fatalf("unexpected blocks %v", blocks[0])
+	}
+
+	blocks = FindRepeated(pages, RepeatedOptions{IgnoreDigits: true})
+	if len(blocks[0]) != 3 { // header, line and footer
+		t.Fatalf("unexpected blocks %v", blocks[0])
+	}
+	repeated, others := SplitRepeated(pages[1], blocks[1])
+	if len(repeated) != len(header)+len(line)+3 {
+		t.Fatalf("unexpected repeated operations %v", repeated)
+	}
+	expected := []Operation{OpSetFillGray{G: 0.5}, OpBeginText{}, OpShowText{Text: "second"}, OpEndText{}}
+	if !reflect.DeepEqual(others, expected) {
+		t.Fatalf("expected %v, got %v", expected, others)
+	}
+
+	// not enough pages
+	if blocks = FindRepeated(pages[:1], RepeatedOptions{}); len(blocks[0]) != 0 {
+		t.Fatalf("unexpected blocks %v", blocks[0])
+	}
+}
+
+func TestSplitBlocksUnbalanced(t *testing.T) {
+	ops := []Operation{OpRectangle{W: 1, H: 1}, OpClip{}, OpEndPath{}, OpSave{}, OpBeginText{}, OpEndText{}}
+	blocks := SplitBlocks(ops)
+	expected := []Block{{Start: 0, End: 3}, {Start: 3, End: 6}}
+	if !reflect.DeepEqual(blocks, expected) {
+		t.Fatalf("expected %v, got %v", expected, blocks)
+	}
+}
+
+func TestSplitBlocksState(t *testing.T) {
+	ops := []Operation{
+		OpBeginText{}, OpSetFont{Font: "F1", Size: 12}, OpShowText{Text: "Header"}, OpEndText{},
+		OpBeginText{}, OpSave{}, OpSetFillGray{G: 0.5}, OpRestore{}, OpShowText{Text: "Header"}, OpEndText{},
+		OpBeginMarkedContent{Tag: "Artifact"}, OpConcat{Matrix: model.Matrix{1, 0, 0, 1, 10, 10}}, OpEndMarkedContent{},
+		OpSave{}, OpSetFont{Font: "F1", Size: 12}, OpRestore{},
+	}
+	blocks := SplitBlocks(ops)
+	expected := []Block{
+		{Start: 0, End: 4},                   // Tf persists after ET
+		{Start: 4, End: 10, Removable: true}, // isolated by q/Q
+		{Start: 10, End: 13},                 // cm persists after EMC
+		{Start: 13, End: 16, Removable: true},
+	}
+	if !reflect.DeepEqual(blocks, expected) {
+		t.Fatalf("expected %v, got %v", expected, blocks)
+	}
+
+	// a header setting the font is not removed, since
+	// the following text depends on it
+	page := append(ops[:4:4], OpBeginText{}, OpShowText{Text: "Body"}, OpEndText{})
+	blocks = FindRepeated([][]Operation{page, page}, RepeatedOptions{})[0]
+	if expected := []Block{{Start: 4, End: 7, Removable: true}}; !reflect.DeepEqual(blocks, expected) {
+		t.Fatalf("expected %v, got %v", expected, blocks)
+	}
+}
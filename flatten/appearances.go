@@ -0,0 +1,420 @@
+package flatten
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	cs "github.com/benoitkugler/pdf/contentstream"
+	"github.com/benoitkugler/pdf/fonts"
+	"github.com/benoitkugler/pdf/fonts/standardfonts"
+	"github.com/benoitkugler/pdf/model"
+)
+
+var (
+	defaultNoteColor      = model.Color{1, 1, 0}
+	defaultHighlightColor = model.Color{1, 1, 0}
+	defaultStampColor     = model.Color{0.8, 0, 0}
+)
+
+// Appearances generates a normal appearance stream for the visible
+// annotations of `doc` missing one, so that they are displayed identically by
+// all viewers. Contrary to the other functions of this package, the
+// annotations are kept in their page.
+//
+// The following annotations are supported:
+//   - free texts, squares and circles, as for `FreeTexts` and `Shapes`
+//   - lines, polygons, polylines and inks, without line endings
+//   - highlights, underlines, squiggly underlines and strike outs
+//   - links with a border color (C entry)
+//   - text notes and stamps, drawn with a simple icon
+//   - widgets, with their border and background (MK entry), and the
+//     caption of push buttons or the mark of check boxes and radio buttons.
+//     The value of text and choice fields is not drawn: see the formfill package.
+//
+// `opts.Font` is required for free texts, stamps and push buttons captions.
+func Appearances(doc *model.Document, opts Options) error {
+	opts.setDefaults()
+
+	// the widgets need the type of their field
+	fields := make(map[*model.AnnotationDict]model.FormFieldInherited)
+	for _, field := range doc.Catalog.AcroForm.Flatten() {
+		for _, widget := range field.Field.Widgets {
+			fields[widget.AnnotationDict] = field
+		}
+	}
+
+	for index, page := range doc.Catalog.Pages.Flatten() {
+		for _, annot := range page.Annots {
+			if isHidden(annot) || (annot.AP != nil && annot.AP.N != nil) {
+				continue
+			}
+			var err error
+			if widget, ok := annot.Subtype.(model.AnnotationWidget); ok {
+				err = setWidgetAppearance(annot, widget, fields[annot], opts)
+			} else {
+				var form *model.XObjectForm
+				form, err = annotationForm(annot, opts)
+				if form != nil {
+					annot.AP = &model.AppearanceDict{N: model.AppearanceEntry{"": form}}
+				}
+			}
+			if err != nil {
+				return fmt.Errorf("page %d: %s", index, err)
+			}
+		}
+	}
+	return nil
+}
+
+// annotationForm returns the appearance of a (non widget) annotation,
+// or nil if it is not supported or invisible
+func annotationForm(annot *model.AnnotationDict, opts Options) (*model.XObjectForm, error) {
+	rect := annot.Rect.Normalize()
+	if rect.Width() <= 0 || rect.Height() <= 0 {
+		return nil, nil
+	}
+	gs := cs.NewGraphicStream(rect)
+	var ops []cs.Operation
+	switch st := annot.Subtype.(type) {
+	case model.AnnotationFreeText:
+		return freeTextForm(annot, st, opts)
+	case model.AnnotationStamp:
+		if err := drawStamp(&gs, annot.C, st.Name, rect, opts); err != nil {
+			return nil, err
+		}
+		return gs.ToXFormObject(true), nil
+	case model.AnnotationHighlight:
+		quads := quadPoints(st.QuadPoints)
+		if len(quads) == 0 {
+			return nil, nil
+		}
+		c := model.Color(annot.C)
+		if len(c) == 0 {
+			c = defaultHighlightColor
+		}
+		// the highlighted text stays visible with the Multiply blend mode
+		gs.SetGraphicState(&model.GraphicState{BM: []model.Name{"Multiply"}})
+		ops = append(ops, colorOp(c, false))
+		for _, quad := range quads {
+			ops = append(ops, cs.OpMoveTo{X: quad[0], Y: quad[1]}, cs.OpLineTo{X: quad[2], Y: quad[3]},
+				cs.OpLineTo{X: quad[6], Y: quad[7]}, cs.OpLineTo{X: quad[4], Y: quad[5]}, cs.OpClosePath{})
+		}
+		ops = append(ops, cs.OpFill{})
+	case model.AnnotationSquare:
+		ops = shapeOps(annot, st, false)
+	case model.AnnotationCircle:
+		ops = shapeOps(annot, model.AnnotationSquare(st), true)
+	case model.AnnotationLine:
+		ops = polylineOps(annot.C, borderWidth(annot.Border, st.BS), nil, false, st.L[:])
+	case model.AnnotationPolygon:
+		ops = polylineOps(annot.C, borderWidth(annot.Border, st.BS), st.IC, true, st.Vertices)
+	case model.AnnotationPolyLine:
+		ops = polylineOps(annot.C, borderWidth(annot.Border, st.BS), nil, false, st.Vertices)
+	case model.AnnotationInk:
+		width := borderWidth(annot.Border, st.BS)
+		for _, path := range st.InkList {
+			ops = append(ops, polylineOps(annot.C, width, nil, false, path)...)
+		}
+		if len(ops) != 0 {
+			ops = append([]cs.Operation{cs.OpSetLineCap{Style: 1}, cs.OpSetLineJoin{Style: 1}}, ops...)
+		}
+	case model.AnnotationUnderline:
+		ops = textMarkupOps(annot.C, st.QuadPoints, 0.1, false)
+	case model.AnnotationStrikeOut:
+		ops = textMarkupOps(annot.C, st.QuadPoints, 0.5, false)
+	case model.AnnotationSquiggly:
+		ops = textMarkupOps(annot.C, st.QuadPoints, 0.1, true)
+	case model.AnnotationLink:
+		width := borderWidth(annot.Border, st.BS)
+		if len(annot.C) == 0 || width <= 0 {
+			return nil, nil
+		}
+		ops = append(ops, colorOp(annot.C, true), cs.OpSetLineWidth{W: width})
+		if dash := borderDash(annot.Border, st.BS); dash != nil {
+			ops = append(ops, cs.OpSetDash{Dash: model.DashPattern{Array: dash}})
+		}
+		ops = append(ops, cs.OpRectangle{X: rect.Llx + width/2, Y: rect.Lly + width/2, W: rect.Width() - width, H: rect.Height() - width}, cs.OpStroke{})
+	case model.AnnotationText:
+		ops = noteIconOps(annot.C, rect)
+	}
+	if len(ops) == 0 {
+		return nil, nil
+	}
+	gs.Ops(ops...)
+	return gs.ToXFormObject(true), nil
+}
+
+// polylineOps strokes the path joining the points, given as alternating
+// horizontal and vertical coordinates, and optionally closes and fills it
+func polylineOps(stroke model.Color, width Fl, fill model.Color, closed bool, coords []Fl) []cs.Operation {
+	if len(coords) < 4 || (len(stroke) == 0 && len(fill) == 0) {
+		return nil
+	}
+	out := []cs.Operation{cs.OpSave{}, colorOp(stroke, true), cs.OpSetLineWidth{W: width}}
+	if len(fill) != 0 {
+		out = append(out, colorOp(fill, false))
+	}
+	out = append(out, cs.OpMoveTo{X: coords[0], Y: coords[1]})
+	for i := 2; i+1 < len(coords); i += 2 {
+		out = append(out, cs.OpLineTo{X: coords[i], Y: coords[i+1]})
+	}
+	switch {
+	case closed && len(fill) != 0 && len(stroke) != 0:
+		out = append(out, cs.OpClosePath{}, cs.OpFillStroke{})
+	case closed && len(fill) != 0:
+		out = append(out, cs.OpClosePath{}, cs.OpFill{})
+	case closed:
+		out = append(out, cs.OpClosePath{}, cs.OpStroke{})
+	default:
+		out = append(out, cs.OpStroke{})
+	}
+	return append(out, cs.OpRestore{})
+}
+
+// quadPoints splits `qp` into quadrilaterals, ignoring invalid values
+func quadPoints(qp []Fl) (out [][]Fl) {
+	for i := 0; i+8 <= len(qp); i += 8 {
+		out = append(out, qp[i:i+8])
+	}
+	return out
+}
+
+// textMarkupOps draws a line parallel to the bottom side of each quadrilateral,
+// at the relative height `height`. The line is wavy if `squiggly` is true.
+func textMarkupOps(c model.Color, qp []Fl, height Fl, squiggly bool) []cs.Operation {
+	if len(c) == 0 {
+		return nil
+	}
+	out := []cs.Operation{colorOp(c, true)}
+	for _, quad := range quadPoints(qp) {
+		// the top side goes from (x1, y1) to (x2, y2), the bottom side from (x3, y3) to (x4, y4)
+		x1, y1 := quad[4]+(quad[0]-quad[4])*height, quad[5]+(quad[1]-quad[5])*height
+		x2, y2 := quad[6]+(quad[2]-quad[6])*height, quad[7]+(quad[3]-quad[7])*height
+		textHeight := quad[1] - quad[5]
+		if textHeight < 0 {
+			textHeight = -textHeight
+		}
+		width := textHeight / 16
+		if width < 0.5 {
+			width = 0.5
+		}
+		out = append(out, cs.OpSetLineWidth{W: width}, cs.OpMoveTo{X: x1, Y: y1})
+		if squiggly {
+			// one period of the wave is as long as its height is small
+			period := 4 * width
+			n := int((x2 - x1) / period)
+			for i := 1; i <= n; i++ {
+				t := Fl(i) / Fl(n)
+				dy := width
+				if i%2 == 1 {
+					dy = -width
+				}
+				out = append(out, cs.OpLineTo{X: x1 + (x2-x1)*t, Y: y1 + (y2-y1)*t + dy})
+			}
+		}
+		out = append(out, cs.OpLineTo{X: x2, Y: y2}, cs.OpStroke{})
+	}
+	return out
+}
+
+// noteIconOps draws a sheet of paper with lines of text
+func noteIconOps(c model.Color, rect model.Rectangle) []cs.Operation {
+	if len(c) == 0 {
+		c = defaultNoteColor
+	}
+	w, h := rect.Width(), rect.Height()
+	out := []cs.Operation{
+		cs.OpSave{},
+		colorOp(c, false), cs.OpSetStrokeGray{G: 0}, cs.OpSetLineWidth{W: 1},
+		cs.OpRectangle{X: rect.Llx + 0.5, Y: rect.Lly + 0.5, W: w - 1, H: h - 1},
+		cs.OpFillStroke{},
+	}
+	for i := 1; i <= 3; i++ {
+		y := rect.Ury - h*Fl(i)/4
+		out = append(out, cs.OpMoveTo{X: rect.Llx + w/5, Y: y}, cs.OpLineTo{X: rect.Urx - w/5, Y: y})
+	}
+	return append(out, cs.OpStroke{}, cs.OpRestore{})
+}
+
+// stampLabel returns the text displayed for a stamp name,
+// such as "NOT APPROVED" for NotApproved
+func stampLabel(name model.Name) string {
+	if name == "" {
+		name = "Draft"
+	}
+	var b strings.Builder
+	for i, r := range string(name) {
+		if i != 0 && unicode.IsUpper(r) {
+			b.WriteByte(' ')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+// drawStamp draws a rounded frame containing the name of the stamp
+func drawStamp(gs *cs.GraphicStream, c model.Color, name model.Name, rect model.Rectangle, opts Options) error {
+	if opts.Font.Font == nil {
+		return errMissingFont
+	}
+	if len(c) == 0 {
+		c = defaultStampColor
+	}
+	width := rect.Height() / 15
+	inner := model.Rectangle{Llx: rect.Llx + width, Lly: rect.Lly + width, Urx: rect.Urx - width, Ury: rect.Ury - width}
+	gs.Ops(colorOp(c, true), colorOp(c, false), cs.OpSetLineWidth{W: width}, cs.OpSetLineJoin{Style: 1})
+	gs.Ops(cs.OpRectangle{X: inner.Llx, Y: inner.Lly, W: inner.Width(), H: inner.Height()}, cs.OpStroke{})
+
+	label := stampLabel(name)
+	layout := fonts.TextLayout{Font: opts.Font, Width: inner.Width() - 2*width, Height: inner.Height() - 2*width, Quadding: model.Centered}
+	size := layout.FitSize(label)
+	line := layout.Lines(label, size)[0]
+	gs.BeginText()
+	gs.SetFontAndSize(opts.Font, size)
+	gs.SetTextMatrix(1, 0, 0, 1, inner.Llx+width+line.X, (rect.Lly+rect.Ury)/2-size*0.35)
+	if err := gs.ShowText(line.Text); err != nil {
+		return err
+	}
+	gs.EndText()
+	return nil
+}
+
+// ------------------------------- widgets -------------------------------
+
+// zapfDingbats is used for the check box and radio button marks
+var zapfDingbats fonts.BuiltFont
+
+func init() {
+	zapfDingbats, _ = fonts.BuildFont(&model.FontDict{Subtype: standardfonts.ZapfDingbats.WesternType1Font()})
+}
+
+// widgetBackground draws the background and the border of the widget,
+// and returns the border width and true if something was drawn
+func widgetBackground(gs *cs.GraphicStream, rect model.Rectangle, annot *model.AnnotationDict, widget model.AnnotationWidget) (Fl, bool) {
+	var bg, bc model.Color
+	if widget.MK != nil {
+		bg, bc = widget.MK.BG, widget.MK.BC
+	}
+	if len(bg) != 0 {
+		gs.Ops(colorOp(bg, false), cs.OpRectangle{X: rect.Llx, Y: rect.Lly, W: rect.Width(), H: rect.Height()}, cs.OpFill{})
+	}
+	if len(bc) == 0 {
+		return 0, len(bg) != 0
+	}
+	width := borderWidth(annot.Border, widget.BS)
+	if width <= 0 {
+		return 0, len(bg) != 0
+	}
+	gs.Ops(cs.OpSave{}, colorOp(bc, true), cs.OpSetLineWidth{W: width})
+	if dash := borderDash(annot.Border, widget.BS); dash != nil {
+		gs.Ops(cs.OpSetDash{Dash: model.DashPattern{Array: dash}})
+	}
+	if widget.BS != nil && widget.BS.S == "U" { // underline
+		gs.Ops(cs.OpMoveTo{X: rect.Llx, Y: rect.Lly + width/2}, cs.OpLineTo{X: rect.Urx, Y: rect.Lly + width/2})
+	} else {
+		gs.Ops(cs.OpRectangle{X: rect.Llx + width/2, Y: rect.Lly + width/2, W: rect.Width() - width, H: rect.Height() - width})
+	}
+	gs.Ops(cs.OpStroke{}, cs.OpRestore{})
+	return width, true
+}
+
+// drawCentered draws `text` centered in `rect`, using the size
+// and color of the `da` string, or the largest size fitting in `rect`
+func drawCentered(gs *cs.GraphicStream, font fonts.BuiltFont, da, text string, rect model.Rectangle) error {
+	size, textColor := parseDA(da)
+	layout := fonts.TextLayout{Font: font, Width: rect.Width(), Height: rect.Height(), Quadding: model.Centered}
+	if size == 0 {
+		size = layout.FitSize(text)
+	}
+	line := layout.Lines(text, size)[0]
+	gs.BeginText()
+	gs.SetFontAndSize(font, size)
+	if textColor != nil {
+		gs.Ops(colorOp(textColor, false))
+	}
+	gs.SetTextMatrix(1, 0, 0, 1, rect.Llx+line.X, (rect.Lly+rect.Ury)/2-size*0.35)
+	if err := gs.ShowText(line.Text); err != nil {
+		return err
+	}
+	gs.EndText()
+	return nil
+}
+
+// setWidgetAppearance generates the appearance of a widget, whose field may be unknown
+func setWidgetAppearance(annot *model.AnnotationDict, widget model.AnnotationWidget, field model.FormFieldInherited, opts Options) error {
+	rect := annot.Rect.Normalize()
+	if rect.Width() <= 0 || rect.Height() <= 0 {
+		return nil
+	}
+	var caption string
+	if widget.MK != nil {
+		caption = widget.MK.CA
+	}
+	var drawn bool
+	newForm := func() (cs.GraphicStream, model.Rectangle) {
+		gs := cs.NewGraphicStream(rect)
+		var width Fl
+		width, drawn = widgetBackground(&gs, rect, annot, widget)
+		inner := model.Rectangle{Llx: rect.Llx + 2*width, Lly: rect.Lly + 2*width, Urx: rect.Urx - 2*width, Ury: rect.Ury - 2*width}
+		return gs, inner
+	}
+
+	_, isButton := field.Merged.FT.(model.FormFieldButton)
+	if isButton && field.Merged.Ff&model.Pushbutton == 0 { // check box or radio button
+		if caption == "" {
+			caption = "✔" // check mark
+			if field.Merged.Ff&model.Radio != 0 {
+				caption = "●" // bullet
+			}
+		}
+		off, _ := newForm()
+		on, inner := newForm()
+		if err := drawCentered(&on, zapfDingbats, field.Merged.DA, caption, inner); err != nil {
+			return err
+		}
+		onState := onState(annot, field)
+		annot.AP = &model.AppearanceDict{N: model.AppearanceEntry{
+			onState: on.ToXFormObject(true),
+			"Off":   off.ToXFormObject(true),
+		}}
+		if annot.AS == "" {
+			annot.AS = "Off"
+			if v, _ := field.Merged.FT.(model.FormFieldButton); v.V == onState {
+				annot.AS = onState
+			}
+		}
+		return nil
+	}
+
+	gs, inner := newForm()
+	if isButton && caption != "" {
+		if opts.Font.Font == nil {
+			return errMissingFont
+		}
+		if err := drawCentered(&gs, opts.Font, field.Merged.DA, caption, inner); err != nil {
+			return err
+		}
+		drawn = true
+	}
+	if !drawn {
+		return nil
+	}
+	annot.AP = &model.AppearanceDict{N: model.AppearanceEntry{"": gs.ToXFormObject(true)}}
+	return nil
+}
+
+// onState returns the name of the "on" state of a check box
+// or radio button widget, defaulting to Yes
+func onState(annot *model.AnnotationDict, field model.FormFieldInherited) model.Name {
+	if annot.AS != "" && annot.AS != "Off" {
+		return annot.AS
+	}
+	if button, ok := field.Merged.FT.(model.FormFieldButton); ok && button.V != "" && button.V != "Off" {
+		// for radio buttons, the value selects one widget only
+		if field.Merged.Ff&model.Radio == 0 || len(field.Field.Widgets) == 1 {
+			return button.V
+		}
+	}
+	return "Yes"
+}
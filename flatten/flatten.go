@@ -0,0 +1,579 @@
+// Package flatten converts annotations into regular page content,
+// which is useful for archival policies disallowing interactive annotations.
+//
+// Free texts, squares and circles are drawn using their appearance stream when present,
+// or from their properties otherwise. Links are replaced by an underline, and
+// text notes by a numbered marker, their content being listed in footnote pages
+// added at the end of the document.
+// In each case, the converted annotations are removed from their page.
+//
+// Alternatively, Appearances keeps the annotations but generates their missing
+// appearance streams, so that they are displayed identically by all viewers.
+package flatten
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+
+	cs "github.com/benoitkugler/pdf/contentstream"
+	"github.com/benoitkugler/pdf/fonts"
+	"github.com/benoitkugler/pdf/model"
+)
+
+type Fl = model.Fl
+
+var identity = model.Matrix{1, 0, 0, 1, 0, 0}
+
+// A4 is the default size for the footnote pages
+var A4 = model.Rectangle{Urx: 595.28, Ury: 841.89}
+
+// Options configures the generated content.
+type Options struct {
+	// Font is used to draw the free texts without appearance streams,
+	// the note markers and the footnotes. It is required when such text is needed.
+	Font     fonts.BuiltFont
+	FontSize Fl // optional, default to 10, for the note markers and the footnotes
+
+	// LinkColor is the color of the underline replacing links which
+	// do not specify a color (C entry). It defaults to blue.
+	LinkColor model.Color
+}
+
+func (opts *Options) setDefaults() {
+	if opts.FontSize == 0 {
+		opts.FontSize = 10
+	}
+	if opts.LinkColor == nil {
+		opts.LinkColor = model.Color{0, 0, 1}
+	}
+}
+
+var errMissingFont = errors.New("missing font to draw annotation text")
+
+// pageEditor accumulates the operations drawn
+// on top of the existing content of a page
+type pageEditor struct {
+	page  *model.PageObject
+	index int                 // 0-based
+	res   model.ResourcesDict // copy of the (inherited) page resources
+	ops   []cs.Operation
+}
+
+// addForm draws `form` on the page, fitted in `rect`, following the
+// algorithm of the PDF spec (12.5.5 Appearance Streams)
+func (pe *pageEditor) addForm(form *model.XObjectForm, rect model.Rectangle) {
+	mat := form.Matrix
+	if mat == (model.Matrix{}) {
+		mat = identity
+	}
+	box := form.BBox.Transform(mat)
+	if box.Width() == 0 || box.Height() == 0 {
+		return
+	}
+	rect = rect.Normalize()
+	sx, sy := rect.Width()/box.Width(), rect.Height()/box.Height()
+	name := pe.res.UnusedName("Annot")
+	pe.res.XObject[name] = form
+	pe.ops = append(pe.ops,
+		cs.OpSave{},
+		cs.OpConcat{Matrix: model.Matrix{sx, 0, 0, sy, rect.Llx - box.Llx*sx, rect.Lly - box.Lly*sy}},
+		cs.OpXObject{XObject: name},
+		cs.OpRestore{},
+	)
+}
+
+// apply updates the page content and resources
+func (pe *pageEditor) apply() error {
+	if len(pe.ops) == 0 {
+		return nil
+	}
+	// the added resources are already in `pe.res`
+	pe.page.Resources = &pe.res
+	return pe.page.AppendContent(cs.WriteOperations(pe.ops...), model.ResourcesDict{})
+}
+
+// convert removes the annotations for which `draw` returns true,
+// after drawing them on the page
+func convert(doc *model.Document, draw func(pe *pageEditor, annot *model.AnnotationDict) (bool, error)) error {
+	inherited := doc.Catalog.Pages.FlattenInherit()
+	for index, page := range doc.Catalog.Pages.Flatten() {
+		pe := pageEditor{page: page, index: index}
+		if res := inherited[index].Resources; res != nil {
+			pe.res = res.ShallowCopy()
+		} else {
+			pe.res = model.NewResourcesDict()
+		}
+		var kept []*model.AnnotationDict
+		for _, annot := range page.Annots {
+			converted, err := draw(&pe, annot)
+			if err != nil {
+				return fmt.Errorf("page %d: %s", index, err)
+			}
+			if !converted {
+				kept = append(kept, annot)
+			}
+		}
+		if len(kept) != len(page.Annots) {
+			page.Annots = kept
+		}
+		if err := pe.apply(); err != nil {
+			return fmt.Errorf("page %d: %s", index, err)
+		}
+	}
+	return nil
+}
+
+// appearance returns the normal appearance of the annotation, or nil
+func appearance(annot *model.AnnotationDict) *model.XObjectForm {
+	if annot.AP == nil {
+		return nil
+	}
+	return annot.AP.N.Appearance(annot.AS)
+}
+
+func isHidden(annot *model.AnnotationDict) bool {
+	return annot.F&(model.AHidden|model.ANoView) != 0
+}
+
+// FreeTexts converts the free text annotations of `doc` into page content.
+// The appearance stream is used if present; otherwise, the text is drawn
+// with `opts.Font`, using the size and color of the DA entry, the background color (C entry)
+// and the border style.
+// Hidden annotations are simply removed.
+func FreeTexts(doc *model.Document, opts Options) error {
+	opts.setDefaults()
+	return convert(doc, func(pe *pageEditor, annot *model.AnnotationDict) (bool, error) {
+		ft, ok := annot.Subtype.(model.AnnotationFreeText)
+		if !ok {
+			return false, nil
+		}
+		if isHidden(annot) {
+			return true, nil
+		}
+		if form := appearance(annot); form != nil {
+			pe.addForm(form, annot.Rect)
+			return true, nil
+		}
+		form, err := freeTextForm(annot, ft, opts)
+		if err != nil {
+			return false, err
+		}
+		pe.addForm(form, annot.Rect)
+		return true, nil
+	})
+}
+
+// freeTextForm generates an appearance for the free text annotation
+func freeTextForm(annot *model.AnnotationDict, ft model.AnnotationFreeText, opts Options) (*model.XObjectForm, error) {
+	if opts.Font.Font == nil {
+		return nil, errMissingFont
+	}
+	rect := annot.Rect.Normalize()
+	gs := cs.NewGraphicStream(rect)
+	if len(annot.C) != 0 { // background
+		gs.Ops(colorOp(annot.C, false), cs.OpRectangle{X: rect.Llx, Y: rect.Lly, W: rect.Width(), H: rect.Height()}, cs.OpFill{})
+	}
+	borderWidth := strokeBorder(&gs, rect, annot.Border, ft.BS)
+
+	size, textColor := parseDA(ft.DA)
+	if size == 0 {
+		size = opts.FontSize
+	}
+	padding := borderWidth + 2
+	width := rect.Width() - 2*padding
+	lines := wrapText(opts.Font, annot.Contents, size, width)
+
+	gs.Ops(cs.OpSave{}, cs.OpRectangle{X: rect.Llx, Y: rect.Lly, W: rect.Width(), H: rect.Height()}, cs.OpClip{}, cs.OpEndPath{})
+	gs.BeginText()
+	gs.SetFontAndSize(opts.Font, size)
+	if textColor != nil {
+		gs.Ops(colorOp(textColor, false))
+	}
+	y := rect.Ury - padding - size
+	for _, line := range lines {
+		x := rect.Llx + padding
+		switch ft.Q {
+		case 1: // centered
+			x += (width - textWidth(opts.Font, line, size)) / 2
+		case 2: // right justified
+			x += width - textWidth(opts.Font, line, size)
+		}
+		gs.SetTextMatrix(1, 0, 0, 1, x, y)
+		if err := gs.ShowText(line); err != nil {
+			return nil, err
+		}
+		y -= size * 1.2
+	}
+	gs.EndText()
+	gs.Ops(cs.OpRestore{})
+	return gs.ToXFormObject(true), nil
+}
+
+// Shapes converts the square and circle annotations of `doc` into page content.
+// The appearance stream is used if present; otherwise, the shape is drawn
+// using the border style, the border color (C entry) and the interior color (IC entry).
+// Hidden annotations are simply removed.
+func Shapes(doc *model.Document) error {
+	return convert(doc, func(pe *pageEditor, annot *model.AnnotationDict) (bool, error) {
+		var (
+			square model.AnnotationSquare
+			circle bool
+		)
+		switch st := annot.Subtype.(type) {
+		case model.AnnotationSquare:
+			square = st
+		case model.AnnotationCircle:
+			square, circle = model.AnnotationSquare(st), true
+		default:
+			return false, nil
+		}
+		if isHidden(annot) {
+			return true, nil
+		}
+		if form := appearance(annot); form != nil {
+			pe.addForm(form, annot.Rect)
+			return true, nil
+		}
+		pe.ops = append(pe.ops, shapeOps(annot, square, circle)...)
+		return true, nil
+	})
+}
+
+// shapeOps returns the operations drawing the square or circle annotation
+func shapeOps(annot *model.AnnotationDict, square model.AnnotationSquare, circle bool) []cs.Operation {
+	stroke, fill := len(annot.C) != 0, len(square.IC) != 0
+	if !stroke && !fill {
+		return nil
+	}
+	width := borderWidth(annot.Border, square.BS)
+	rect := annot.Rect.Normalize()
+	// the RD entry describes the inner rectangle
+	rect.Llx, rect.Lly = rect.Llx+square.RD.Llx, rect.Lly+square.RD.Lly
+	rect.Urx, rect.Ury = rect.Urx-square.RD.Urx, rect.Ury-square.RD.Ury
+	if stroke { // the border is drawn inside the rectangle
+		rect.Llx, rect.Lly, rect.Urx, rect.Ury = rect.Llx+width/2, rect.Lly+width/2, rect.Urx-width/2, rect.Ury-width/2
+	}
+	if rect.Width() <= 0 || rect.Height() <= 0 {
+		return nil
+	}
+
+	out := []cs.Operation{cs.OpSave{}}
+	if stroke {
+		out = append(out, colorOp(annot.C, true), cs.OpSetLineWidth{W: width})
+		if dash := borderDash(annot.Border, square.BS); dash != nil {
+			out = append(out, cs.OpSetDash{Dash: model.DashPattern{Array: dash}})
+		}
+	}
+	if fill {
+		out = append(out, colorOp(square.IC, false))
+	}
+	if circle {
+		out = append(out, ellipsePath(rect)...)
+	} else {
+		out = append(out, cs.OpRectangle{X: rect.Llx, Y: rect.Lly, W: rect.Width(), H: rect.Height()})
+	}
+	switch {
+	case stroke && fill:
+		out = append(out, cs.OpFillStroke{})
+	case stroke:
+		out = append(out, cs.OpStroke{})
+	default:
+		out = append(out, cs.OpFill{})
+	}
+	return append(out, cs.OpRestore{})
+}
+
+// approximation of a quarter of circle with a Bezier curve
+const kappa = 4 * (math.Sqrt2 - 1) / 3
+
+// ellipsePath returns the path of the ellipse inscribed in `rect`
+func ellipsePath(rect model.Rectangle) []cs.Operation {
+	cx, cy := (rect.Llx+rect.Urx)/2, (rect.Lly+rect.Ury)/2
+	rx, ry := rect.Width()/2, rect.Height()/2
+	kx, ky := rx*kappa, ry*kappa
+	return []cs.Operation{
+		cs.OpMoveTo{X: cx + rx, Y: cy},
+		cs.OpCubicTo{X1: cx + rx, Y1: cy + ky, X2: cx + kx, Y2: cy + ry, X3: cx, Y3: cy + ry},
+		cs.OpCubicTo{X1: cx - kx, Y1: cy + ry, X2: cx - rx, Y2: cy + ky, X3: cx - rx, Y3: cy},
+		cs.OpCubicTo{X1: cx - rx, Y1: cy - ky, X2: cx - kx, Y2: cy - ry, X3: cx, Y3: cy - ry},
+		cs.OpCubicTo{X1: cx + kx, Y1: cy - ry, X2: cx + rx, Y2: cy - ky, X3: cx + rx, Y3: cy},
+		cs.OpClosePath{},
+	}
+}
+
+// Links removes the link annotations of `doc`, replacing them by an underline
+// of the link area (or of each quadrilateral, if QuadPoints is specified),
+// drawn with the link color (or `opts.LinkColor`) and border width (at least 1).
+// Hidden links are simply removed.
+func Links(doc *model.Document, opts Options) error {
+	opts.setDefaults()
+	return convert(doc, func(pe *pageEditor, annot *model.AnnotationDict) (bool, error) {
+		link, ok := annot.Subtype.(model.AnnotationLink)
+		if !ok {
+			return false, nil
+		}
+		if isHidden(annot) {
+			return true, nil
+		}
+		c := model.Color(annot.C)
+		if len(c) == 0 {
+			c = opts.LinkColor
+		}
+		width := borderWidth(annot.Border, link.BS)
+		if width < 1 {
+			width = 1
+		}
+		pe.ops = append(pe.ops, cs.OpSave{}, colorOp(c, true), cs.OpSetLineWidth{W: width})
+		if qp := link.QuadPoints; len(qp) != 0 && len(qp)%8 == 0 {
+			for i := 0; i < len(qp); i += 8 {
+				// the bottom side goes from (x3, y3) to (x4, y4)
+				pe.ops = append(pe.ops, cs.OpMoveTo{X: qp[i+4], Y: qp[i+5]}, cs.OpLineTo{X: qp[i+6], Y: qp[i+7]})
+			}
+		} else {
+			rect := annot.Rect.Normalize()
+			y := rect.Lly + width/2
+			pe.ops = append(pe.ops, cs.OpMoveTo{X: rect.Llx, Y: y}, cs.OpLineTo{X: rect.Urx, Y: y})
+		}
+		pe.ops = append(pe.ops, cs.OpStroke{}, cs.OpRestore{})
+		return true, nil
+	})
+}
+
+// note is a text note converted to a footnote
+type note struct {
+	page   int // 0-based
+	author string
+	text   string
+}
+
+// Notes removes the text annotations (sticky notes) of `doc`,
+// replacing them by a numbered marker, and lists their content
+// in printable footnote pages appended at the end of the document,
+// with the size of the last page (or A4).
+// Hidden notes and notes without content are simply removed.
+func Notes(doc *model.Document, opts Options) error {
+	opts.setDefaults()
+	var notes []note
+	err := convert(doc, func(pe *pageEditor, annot *model.AnnotationDict) (bool, error) {
+		text, ok := annot.Subtype.(model.AnnotationText)
+		if !ok {
+			return false, nil
+		}
+		if isHidden(annot) || strings.TrimSpace(annot.Contents) == "" {
+			return true, nil
+		}
+		if opts.Font.Font == nil {
+			return false, errMissingFont
+		}
+		notes = append(notes, note{page: pe.index, author: text.T, text: annot.Contents})
+
+		// draw the marker at the top left corner of the note
+		marker := strconv.Itoa(len(notes))
+		rect := annot.Rect.Normalize()
+		box := model.Rectangle{Llx: rect.Llx, Lly: rect.Ury - opts.FontSize, Urx: rect.Llx + textWidth(opts.Font, marker, opts.FontSize), Ury: rect.Ury}
+		gs := cs.NewGraphicStream(box)
+		gs.BeginText()
+		gs.SetFontAndSize(opts.Font, opts.FontSize)
+		gs.SetTextMatrix(1, 0, 0, 1, box.Llx, box.Lly+opts.FontSize*0.2)
+		if err := gs.ShowText(marker); err != nil {
+			return false, err
+		}
+		gs.EndText()
+		pe.addForm(gs.ToXFormObject(true), box)
+		return true, nil
+	})
+	if err != nil || len(notes) == 0 {
+		return err
+	}
+	return addFootnotePages(doc, notes, opts)
+}
+
+// addFootnotePages appends the pages listing `notes`
+func addFootnotePages(doc *model.Document, notes []note, opts Options) error {
+	mediaBox := A4
+	if pages := doc.Catalog.Pages.FlattenInherit(); len(pages) != 0 && pages[len(pages)-1].MediaBox != nil {
+		mediaBox = *pages[len(pages)-1].MediaBox
+	}
+	const margin = 50
+	size, leading := opts.FontSize, opts.FontSize*1.4
+	width := mediaBox.Width() - 2*margin
+
+	var lines []string
+	for i, n := range notes {
+		header := fmt.Sprintf("%d. (page %d)", i+1, n.page+1)
+		if n.author != "" {
+			header += " " + n.author
+		}
+		lines = append(lines, wrapText(opts.Font, header+": "+n.text, size, width)...)
+	}
+	linesPerPage := int((mediaBox.Height() - 2*margin) / leading)
+	if linesPerPage < 1 {
+		return errors.New("page too small for the footnotes")
+	}
+
+	for len(lines) != 0 {
+		n := linesPerPage
+		if n > len(lines) {
+			n = len(lines)
+		}
+		gs := cs.NewGraphicStream(mediaBox)
+		gs.BeginText()
+		gs.SetFontAndSize(opts.Font, size)
+		y := mediaBox.Ury - margin - size
+		for _, line := range lines[:n] {
+			gs.SetTextMatrix(1, 0, 0, 1, mediaBox.Llx+margin, y)
+			if err := gs.ShowText(line); err != nil {
+				return err
+			}
+			y -= leading
+		}
+		gs.EndText()
+		lines = lines[n:]
+
+		page := new(model.PageObject)
+		gs.ApplyToPageObject(page, true)
+		doc.Catalog.Pages.Kids = append(doc.Catalog.Pages.Kids, page)
+	}
+	return nil
+}
+
+// ------------------------------- utilities -------------------------------
+
+func textWidth(font fonts.BuiltFont, text string, size Fl) Fl {
+	return fonts.TextWidth(font, []rune(text), size)
+}
+
+// wrapText splits `text` into lines fitting in `width`,
+// breaking at spaces when possible, and preserving the explicit line breaks.
+func wrapText(font fonts.BuiltFont, text string, size, width Fl) []string {
+	var out []string
+	text = strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(text)
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if line == "" || textWidth(font, candidate, size) <= width {
+				line = candidate
+				continue
+			}
+			out = append(out, line)
+			line = word
+		}
+		out = append(out, line)
+	}
+	return out
+}
+
+// parseDA returns the font size and the text color of a
+// default appearance string, or zero values
+func parseDA(da string) (size Fl, c model.Color) {
+	fields := strings.Fields(da)
+	numbers := func(i, n int) []Fl {
+		if i < n {
+			return nil
+		}
+		out := make([]Fl, n)
+		for j := range out {
+			v, err := strconv.ParseFloat(fields[i-n+j], 64)
+			if err != nil {
+				return nil
+			}
+			out[j] = Fl(v)
+		}
+		return out
+	}
+	for i, field := range fields {
+		switch field {
+		case "Tf":
+			if v := numbers(i, 1); v != nil {
+				size = v[0]
+			}
+		case "g":
+			c = numbers(i, 1)
+		case "rg":
+			c = numbers(i, 3)
+		case "k":
+			c = numbers(i, 4)
+		}
+	}
+	return size, c
+}
+
+// colorOp returns the operation setting the fill or stroke color,
+// defaulting to black for invalid colors
+func colorOp(c model.Color, stroke bool) cs.Operation {
+	switch len(c) {
+	case 3:
+		if stroke {
+			return cs.OpSetStrokeRGBColor{R: c[0], G: c[1], B: c[2]}
+		}
+		return cs.OpSetFillRGBColor{R: c[0], G: c[1], B: c[2]}
+	case 4:
+		if stroke {
+			return cs.OpSetStrokeCMYKColor{C: c[0], M: c[1], Y: c[2], K: c[3]}
+		}
+		return cs.OpSetFillCMYKColor{C: c[0], M: c[1], Y: c[2], K: c[3]}
+	}
+	var g Fl
+	if len(c) == 1 {
+		g = c[0]
+	}
+	if stroke {
+		return cs.OpSetStrokeGray{G: g}
+	}
+	return cs.OpSetFillGray{G: g}
+}
+
+// borderWidth returns the width defined by the border style,
+// or the border array, defaulting to 1
+func borderWidth(border *model.Border, bs *model.BorderStyle) Fl {
+	if bs != nil {
+		if w, ok := bs.W.(model.ObjFloat); ok {
+			return Fl(w)
+		}
+		return 1
+	}
+	if border != nil {
+		return border.BorderWidth
+	}
+	return 1
+}
+
+// borderDash returns the dash array of a dashed border, or nil
+func borderDash(border *model.Border, bs *model.BorderStyle) []Fl {
+	if bs != nil {
+		if bs.S != "D" {
+			return nil
+		}
+		if len(bs.D) == 0 {
+			return []Fl{3}
+		}
+		return bs.D
+	}
+	if border != nil && len(border.DashArray) != 0 {
+		return border.DashArray
+	}
+	return nil
+}
+
+// strokeBorder draws the border inside `rect`, and returns its width
+func strokeBorder(gs *cs.GraphicStream, rect model.Rectangle, border *model.Border, bs *model.BorderStyle) Fl {
+	width := borderWidth(border, bs)
+	if width <= 0 {
+		return 0
+	}
+	gs.SetColorStroke(color.Black)
+	gs.Ops(cs.OpSetLineWidth{W: width})
+	if dash := borderDash(border, bs); dash != nil {
+		gs.Ops(cs.OpSetDash{Dash: model.DashPattern{Array: dash}})
+	}
+	gs.Ops(cs.OpRectangle{X: rect.Llx + width/2, Y: rect.Lly + width/2, W: rect.Width() - width, H: rect.Height() - width}, cs.OpStroke{})
+	return width
+}
@@ -0,0 +1,270 @@
+package flatten
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/benoitkugler/pdf/fonts"
+	"github.com/benoitkugler/pdf/fonts/standardfonts"
+	"github.com/benoitkugler/pdf/model"
+)
+
+func testOptions(t *testing.T) Options {
+	font, err := fonts.BuildFont(&model.FontDict{Subtype: standardfonts.Helvetica.WesternType1Font()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Options{Font: font}
+}
+
+func newTestDocument(annots ...*model.AnnotationDict) (model.Document, *model.PageObject) {
+	var doc model.Document
+	page := &model.PageObject{
+		MediaBox: &model.Rectangle{Urx: 300, Ury: 400},
+		Contents: []model.ContentStream{{Stream: model.Stream{Content: []byte("0 0 m 10 10 l S")}}},
+		Annots:   annots,
+	}
+	doc.Catalog.Pages.Kids = []model.PageNode{page}
+	return doc, page
+}
+
+func pageContent(t *testing.T, page *model.PageObject) string {
+	content, err := page.DecodeAllContents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.ReplaceAll(string(content), "\n", " ")
+}
+
+func writeDocument(t *testing.T, doc model.Document) {
+	var b bytes.Buffer
+	if err := doc.Write(&b, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFreeTexts(t *testing.T) {
+	form := &model.XObjectForm{BBox: model.Rectangle{Urx: 10, Ury: 10}}
+	withAP := &model.AnnotationDict{
+		BaseAnnotation: model.BaseAnnotation{
+			Rect: model.Rectangle{Llx: 100, Lly: 100, Urx: 120, Ury: 110},
+			AP:   &model.AppearanceDict{N: model.AppearanceEntry{"": form}},
+		},
+		Subtype: model.AnnotationFreeText{DA: "/Helv 12 Tf 0 g"},
+	}
+	withoutAP := &model.AnnotationDict{
+		BaseAnnotation: model.BaseAnnotation{
+			Rect:     model.Rectangle{Llx: 10, Lly: 10, Urx: 110, Ury: 60},
+			Contents: "Some free text, long enough to be wrapped on several lines",
+			C:        []model.Fl{1, 1, 0},
+		},
+		Subtype: model.AnnotationFreeText{DA: "/Helv 9 Tf 1 0 0 rg", Q: 1},
+	}
+	link := &model.AnnotationDict{Subtype: model.AnnotationLink{}}
+	doc, page := newTestDocument(withAP, withoutAP, link)
+
+	if err := FreeTexts(&doc, Options{}); err == nil || !strings.Contains(err.Error(), errMissingFont.Error()) {
+		t.Fatalf("expected missing font error, got %v", err)
+	}
+	if err := FreeTexts(&doc, testOptions(t)); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Annots) != 1 || page.Annots[0] != link {
+		t.Fatalf("unexpected annotations %v", page.Annots)
+	}
+	if len(page.Resources.XObject) != 2 || page.Resources.XObject["Annot0"] != form {
+		t.Fatalf("unexpected resources %v", page.Resources.XObject)
+	}
+	content := pageContent(t, page)
+	if !strings.HasPrefix(content, "q") || !strings.Contains(content, "2 0 0 1 100 100 cm /Annot0 Do") {
+		t.Fatalf("unexpected content %s", content)
+	}
+	generated, err := page.Resources.XObject["Annot1"].(*model.XObjectForm).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(generated); !strings.Contains(s, "1 1 0 rg") || !strings.Contains(s, "1 0 0 rg") || strings.Count(s, "Tm") < 2 {
+		t.Fatalf("unexpected generated appearance %s", s)
+	}
+	writeDocument(t, doc)
+}
+
+func TestShapes(t *testing.T) {
+	square := &model.AnnotationDict{
+		BaseAnnotation: model.BaseAnnotation{Rect: model.Rectangle{Llx: 10, Lly: 10, Urx: 50, Ury: 30}, C: []model.Fl{1, 0, 0}},
+		Subtype:        model.AnnotationSquare{BS: &model.BorderStyle{W: model.ObjFloat(2), S: "D"}},
+	}
+	circle := &model.AnnotationDict{
+		BaseAnnotation: model.BaseAnnotation{Rect: model.Rectangle{Llx: 100, Lly: 100, Urx: 140, Ury: 120}},
+		Subtype:        model.AnnotationCircle{IC: []model.Fl{0.5}},
+	}
+	hidden := &model.AnnotationDict{
+		BaseAnnotation: model.BaseAnnotation{Rect: model.Rectangle{Urx: 10, Ury: 10}, C: []model.Fl{0}, F: model.AHidden},
+		Subtype:        model.AnnotationSquare{},
+	}
+	doc, page := newTestDocument(square, circle, hidden)
+	if err := Shapes(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Annots) != 0 {
+		t.Fatalf("unexpected annotations %v", page.Annots)
+	}
+	content := pageContent(t, page)
+	for _, exp := range []string{"1 0 0 RG 2 w [3] 0 d 11 11 38 18 re S", "0.5 g 140 110 m", " c h f"} {
+		if !strings.Contains(content, exp) {
+			t.Fatalf("expected %s in content %s", exp, content)
+		}
+	}
+	writeDocument(t, doc)
+}
+
+func TestLinks(t *testing.T) {
+	link := &model.AnnotationDict{
+		BaseAnnotation: model.BaseAnnotation{Rect: model.Rectangle{Llx: 10, Lly: 10, Urx: 50, Ury: 30}, Border: &model.Border{}},
+		Subtype:        model.AnnotationLink{A: model.Action{ActionType: model.ActionURI{URI: "http://example.com"}}},
+	}
+	quads := &model.AnnotationDict{
+		BaseAnnotation: model.BaseAnnotation{Rect: model.Rectangle{Urx: 100, Ury: 100}, C: []model.Fl{0, 1, 0}},
+		Subtype:        model.AnnotationLink{QuadPoints: []model.Fl{0, 20, 40, 20, 0, 5, 40, 5}},
+	}
+	doc, page := newTestDocument(link, quads)
+	if err := Links(&doc, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Annots) != 0 {
+		t.Fatalf("unexpected annotations %v", page.Annots)
+	}
+	content := pageContent(t, page)
+	for _, exp := range []string{"0 0 1 RG 1 w 10 10.5 m 50 10.5 l S", "0 1 0 RG 1 w 0 5 m 40 5 l S"} {
+		if !strings.Contains(content, exp) {
+			t.Fatalf("expected %s in content %s", exp, content)
+		}
+	}
+	writeDocument(t, doc)
+}
+
+func TestNotes(t *testing.T) {
+	var annots []*model.AnnotationDict
+	for i := 0; i < 60; i++ {
+		annots = append(annots, &model.AnnotationDict{
+			BaseAnnotation: model.BaseAnnotation{Rect: model.Rectangle{Llx: 10, Lly: 10, Urx: 30, Ury: 30}, Contents: "A remark\non two lines"},
+			Subtype: model.AnnotationText{
+				AnnotationMarkup: model.AnnotationMarkup{T: "Bob", Popup: &model.AnnotationPopup{}},
+			},
+		})
+	}
+	empty := &model.AnnotationDict{Subtype: model.AnnotationText{}}
+	doc, page := newTestDocument(append(annots, empty)...)
+	if err := Notes(&doc, testOptions(t)); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Annots) != 0 {
+		t.Fatalf("unexpected annotations %v", page.Annots)
+	}
+	if len(page.Resources.XObject) != 60 {
+		t.Fatalf("unexpected markers %v", page.Resources.XObject)
+	}
+	pages := doc.Catalog.Pages.Flatten()
+	if len(pages) != 7 { // 120 lines, with 21 lines per page
+		t.Fatalf("expected 6 footnotes pages, got %d", len(pages)-1)
+	}
+	if *pages[1].MediaBox != *page.MediaBox {
+		t.Fatalf("unexpected media box %v", pages[1].MediaBox)
+	}
+	writeDocument(t, doc)
+}
+
+func TestAppearances(t *testing.T) {
+	form := &model.XObjectForm{BBox: model.Rectangle{Urx: 10, Ury: 10}}
+	withAP := &model.AnnotationDict{
+		BaseAnnotation: model.BaseAnnotation{
+			Rect: model.Rectangle{Urx: 10, Ury: 10},
+			AP:   &model.AppearanceDict{N: model.AppearanceEntry{"": form}},
+		},
+		Subtype: model.AnnotationSquare{},
+	}
+	square := &model.AnnotationDict{
+		BaseAnnotation: model.BaseAnnotation{Rect: model.Rectangle{Llx: 10, Lly: 10, Urx: 50, Ury: 30}, C: []model.Fl{1, 0, 0}},
+		Subtype:        model.AnnotationSquare{},
+	}
+	highlight := &model.AnnotationDict{
+		BaseAnnotation: model.BaseAnnotation{Rect: model.Rectangle{Urx: 40, Ury: 20}},
+		Subtype:        model.AnnotationHighlight{QuadPoints: []model.Fl{0, 20, 40, 20, 0, 5, 40, 5}},
+	}
+	stamp := &model.AnnotationDict{
+		BaseAnnotation: model.BaseAnnotation{Rect: model.Rectangle{Llx: 100, Lly: 100, Urx: 250, Ury: 140}},
+		Subtype:        model.AnnotationStamp{Name: "NotApproved"},
+	}
+	note := &model.AnnotationDict{
+		BaseAnnotation: model.BaseAnnotation{Rect: model.Rectangle{Llx: 200, Lly: 200, Urx: 220, Ury: 220}},
+		Subtype:        model.AnnotationText{},
+	}
+	link := &model.AnnotationDict{ // no color: invisible
+		BaseAnnotation: model.BaseAnnotation{Rect: model.Rectangle{Urx: 40, Ury: 20}},
+		Subtype:        model.AnnotationLink{},
+	}
+	checkBox := &model.AnnotationDict{
+		BaseAnnotation: model.BaseAnnotation{Rect: model.Rectangle{Llx: 10, Lly: 300, Urx: 25, Ury: 315}},
+		Subtype:        model.AnnotationWidget{MK: &model.AppearanceCharacteristics{BC: []model.Fl{0}}},
+	}
+	pushButton := &model.AnnotationDict{
+		BaseAnnotation: model.BaseAnnotation{Rect: model.Rectangle{Llx: 50, Lly: 300, Urx: 150, Ury: 320}},
+		Subtype:        model.AnnotationWidget{MK: &model.AppearanceCharacteristics{BG: []model.Fl{0.8}, CA: "Submit"}},
+	}
+	doc, page := newTestDocument(withAP, square, highlight, stamp, note, link, checkBox, pushButton)
+	doc.Catalog.AcroForm.Fields = []*model.FormFieldDict{
+		{
+			FormFieldInheritable: model.FormFieldInheritable{FT: model.FormFieldButton{V: "Checked"}},
+			Widgets:              []model.FormFieldWidget{{AnnotationDict: checkBox}},
+		},
+		{
+			FormFieldInheritable: model.FormFieldInheritable{FT: model.FormFieldButton{}, Ff: model.Pushbutton},
+			Widgets:              []model.FormFieldWidget{{AnnotationDict: pushButton}},
+		},
+	}
+
+	if err := Appearances(&doc, testOptions(t)); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Annots) != 8 {
+		t.Fatalf("annotations should be kept, got %v", page.Annots)
+	}
+	if withAP.AP.N[""] != form {
+		t.Fatal("existing appearance should be kept")
+	}
+	if link.AP != nil {
+		t.Fatal("invisible link should not have an appearance")
+	}
+
+	expected := map[*model.AnnotationDict]string{
+		square:     "1 0 0 RG",
+		highlight:  "1 1 0 rg 0 20 m",
+		stamp:      "(VED)]TJ", // kerned
+		note:       "re B",
+		pushButton: "(Submit)Tj",
+	}
+	for annot, exp := range expected {
+		if annot.AP == nil {
+			t.Fatalf("missing appearance for %T", annot.Subtype)
+		}
+		content, err := annot.AP.N[""].Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s := strings.ReplaceAll(string(content), "\n", " "); !strings.Contains(s, exp) {
+			t.Fatalf("expected %s in appearance %s", exp, s)
+		}
+	}
+
+	if checkBox.AS != "Checked" {
+		t.Fatalf("unexpected appearance state %s", checkBox.AS)
+	}
+	if len(checkBox.AP.N) != 2 || checkBox.AP.N["Checked"] == nil || checkBox.AP.N["Off"] == nil {
+		t.Fatalf("unexpected check box appearance %v", checkBox.AP.N)
+	}
+	if content, _ := checkBox.AP.N["Checked"].Decode(); !strings.Contains(string(content), "(4)Tj") {
+		t.Fatalf("unexpected check box appearance %s", content)
+	}
+	writeDocument(t, doc)
+}
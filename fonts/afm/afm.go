@@ -0,0 +1,123 @@
+// Package afm builds the metrics of Type1 fonts from
+// Adobe Font Metrics (.afm) files, at runtime.
+// This is useful for Type1 fonts which are not part of the standard 14 fonts
+// (whose metrics are provided by the standardfonts package).
+package afm
+
+import (
+	"io"
+
+	"github.com/benoitkugler/pdf/fonts/standardfonts"
+	"github.com/benoitkugler/pdf/fonts/type1"
+	"github.com/benoitkugler/pdf/model"
+)
+
+type Fl = model.Fl
+
+// Parse reads an .afm file and returns the metrics of the font,
+// which may be used to build a font dictionary (see Font).
+func Parse(source io.Reader) (standardfonts.Metrics, error) {
+	f, err := type1.ParseAFMFile(source)
+	if err != nil {
+		return standardfonts.Metrics{}, err
+	}
+	return NewMetrics(f), nil
+}
+
+// NewMetrics returns the essential information from the font:
+// its descriptor, its builtin encoding, its widths and its kerning pairs.
+func NewMetrics(f type1.AFMFont) standardfonts.Metrics {
+	widths := make(map[string]int, len(f.CharMetrics))
+	for name, m := range f.CharMetrics {
+		widths[name] = m.Width
+	}
+	return standardfonts.Metrics{
+		Descriptor:  fontDescriptor(f),
+		CharsWidths: widths,
+		Builtin:     f.CharCodeToCharName,
+		KernPairs:   f.KernPairs,
+	}
+}
+
+// widthsStats collect the mean and the maximum values
+// of the glyphs width
+func widthsStats(f type1.AFMFont) (mean, max Fl) {
+	if len(f.CharMetrics) == 0 {
+		return 0, 0
+	}
+	for _, c := range f.CharMetrics {
+		w := Fl(c.Width)
+		if w > max {
+			max = w
+		}
+		mean += w
+	}
+	mean /= Fl(len(f.CharMetrics))
+	return mean, max
+}
+
+// synthetize a fontDescriptor from various
+// font metrics.
+func fontDescriptor(f type1.AFMFont) model.FontDescriptor {
+	if f.CapHeight == 0 {
+		f.CapHeight = f.Ascender
+	}
+
+	flag := model.Nonsymbolic
+	if f.IsSymbolic() {
+		flag = model.Symbolic
+	}
+
+	if f.IsFixedPitch {
+		flag |= model.FixedPitch
+	}
+	if f.ItalicAngle != 0 {
+		flag |= model.Italic
+	}
+	if f.StdVw == 0 {
+		isBold := f.Weight == "bold" || f.Weight == "black"
+		if isBold {
+			f.StdVw = 120
+		} else {
+			f.StdVw = 80
+		}
+	}
+
+	out := model.FontDescriptor{
+		FontName:    model.ObjName(f.FontName),
+		FontFamily:  f.FamilyName,
+		Flags:       flag,
+		FontBBox:    model.Rectangle{Llx: Fl(f.Llx), Lly: Fl(f.Lly), Urx: Fl(f.Urx), Ury: Fl(f.Ury)},
+		ItalicAngle: Fl(f.ItalicAngle),
+		Ascent:      Fl(f.Ascender),
+		Descent:     Fl(f.Descender),
+		Leading:     0, // unknown
+		CapHeight:   Fl(f.CapHeight),
+		XHeight:     Fl(f.XHeight),
+		StemV:       Fl(f.StdVw),
+		StemH:       Fl(f.StdHw),
+	}
+
+	// use its width as missing width
+	if notdef, ok := f.CharMetrics[type1.Notdef]; ok {
+		out.MissingWidth = notdef.Width
+	}
+
+	out.AvgWidth, out.MaxWidth = widthsStats(f)
+
+	out.CharSet = f.CharSet()
+
+	return out
+}
+
+// Font returns a font dictionary using the metrics `m`, with
+// the WinAnsi encoding for non symbolic fonts (see standardfonts.Metrics.WesternType1Font).
+// `file` is the (optional) font program, which should be provided
+// for fonts other than the standard 14 fonts.
+// The returned font may be used with the measuring
+// and text layout functions of the fonts package.
+func Font(m standardfonts.Metrics, file *model.FontFile) *model.FontDict {
+	ft := m.WesternType1Font()
+	ft.FontDescriptor.FontFile = file
+	return &model.FontDict{Subtype: ft}
+}
@@ -0,0 +1,78 @@
+package afm
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/benoitkugler/pdf/fonts"
+	"github.com/benoitkugler/pdf/fonts/standardfonts"
+	"github.com/benoitkugler/pdf/model"
+)
+
+func TestParse(t *testing.T) {
+	f, err := os.Open("../test/Times-Bold.afm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	metrics, err := Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the standard fonts are generated from the same file
+	exp := standardfonts.Times_Bold
+	if !reflect.DeepEqual(metrics.CharsWidths, exp.CharsWidths) || !reflect.DeepEqual(metrics.KernPairs, exp.KernPairs) ||
+		metrics.Builtin != exp.Builtin {
+		t.Fatal("unexpected metrics")
+	}
+	desc, expDesc := metrics.Descriptor, exp.Descriptor
+	desc.CharSet, expDesc.CharSet = "", "" // random order
+	if desc != expDesc {
+		t.Fatalf("expected %v, got %v", expDesc, desc)
+	}
+
+	font := Font(metrics, nil)
+	width, err := fonts.Measure(font, "AV", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := Fl(722+722) * 0.01; width < exp-0.001 || width > exp+0.001 {
+		t.Fatalf("expected width %v, got %v", exp, width)
+	}
+	if font.Subtype.(model.FontType1).Encoding != model.WinAnsiEncoding {
+		t.Fatal("expected WinAnsi encoding")
+	}
+
+	if _, err := Parse(strings.NewReader("StartFontMetrics 4.1\n")); err == nil {
+		t.Fatal("expected error on invalid file")
+	}
+}
+
+func TestSymbolic(t *testing.T) {
+	input := `StartFontMetrics 4.1
+FontName MySymbols
+EncodingScheme FontSpecific
+StartCharMetrics 2
+C 32 ; WX 300 ; N space ; B 0 0 0 0 ;
+C 65 ; WX 700 ; N a1 ; B 10 0 690 700 ;
+EndCharMetrics
+EndFontMetrics
+`
+	metrics, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metrics.Descriptor.Flags&model.Symbolic == 0 {
+		t.Fatal("expected symbolic font")
+	}
+	ft := Font(metrics, &model.FontFile{}).Subtype.(model.FontType1)
+	if ft.Encoding != nil || ft.FirstChar != 32 || len(ft.Widths) != 34 || ft.Widths[33] != 700 {
+		t.Fatalf("unexpected font %v", ft)
+	}
+	if ft.FontDescriptor.FontFile == nil {
+		t.Fatal("missing font file")
+	}
+}
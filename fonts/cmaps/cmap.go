@@ -19,7 +19,7 @@ const (
 
 // CharCode is a compact representation of 1 to 4 bytes,
 // as found in PDF content streams.
-type CharCode int32
+type CharCode uint32
 
 // Append add 1 to 4 bytes to `bs`, in Big-Endian order.
 func (c CharCode) Append(bs *[]byte) {
@@ -37,7 +37,8 @@ func (c CharCode) Append(bs *[]byte) {
 // CMap map character code to CIDs.
 // It is either predefined, or embedded in PDF as a stream.
 type CMap struct {
-	simple        *bool // cached value of Simple
+	simple        *bool     // cached value of Simple
+	maxSpan       *CharCode // cached by SortCIDs, used by Lookup
 	Name          model.ObjName
 	UseCMap       model.ObjName
 	CIDSystemInfo model.CIDSystemInfo
@@ -58,7 +59,7 @@ func newCodespaceFromBytes(low, high []byte) (Codespace, error) {
 	if len(low) != len(high) {
 		return Codespace{}, errors.New("unequal number of bytes in range")
 	}
-	if L := len(low); L > 4 {
+	if L := len(low); L == 0 || L > 4 {
 		return Codespace{}, fmt.Errorf("unsupported number of bytes: %d", L)
 	}
 	lowR := hexToCharCode(low)
@@ -66,23 +67,9 @@ func newCodespaceFromBytes(low, high []byte) (Codespace, error) {
 	if highR < lowR {
 		return Codespace{}, errors.New("invalid caracter code range")
 	}
-	c := Codespace{Low: lowR, High: highR}
-	c.NumBytes = numBytes(c)
-	return c, nil
-}
-
-// numBytes returns how many bytes should be read to match this code.
-// It will always be between 1 and 4.
-func numBytes(c Codespace) int {
-	if c.High < (1 << 8) {
-		return 1
-	} else if c.High < (1 << 16) {
-		return 2
-	} else if c.High < (1 << 24) {
-		return 3
-	} else {
-		return 4
-	}
+	// the number of bytes is given by the hex strings, so that
+	// <0000> <00FF> is a two bytes range
+	return Codespace{NumBytes: len(low), Low: lowR, High: highR}, nil
 }
 
 // CIDRange is an increasing number of CIDs,
@@ -209,6 +196,80 @@ func (cmap *parser) computeInverseMappings() {
 	sort.Slice(cmap.cids.Codespaces, func(i, j int) bool {
 		return cmap.cids.Codespaces[i].Low < cmap.cids.Codespaces[j].Low
 	})
+	cmap.cids.SortCIDs()
+}
+
+// SortCIDs sorts the CID ranges by their first character code, as required by `Lookup`.
+func (cm *CMap) SortCIDs() {
+	sort.SliceStable(cm.CIDs, func(i, j int) bool { return cm.CIDs[i].Low < cm.CIDs[j].Low })
+	var span CharCode
+	for _, rg := range cm.CIDs {
+		if rg.High-rg.Low > span {
+			span = rg.High - rg.Low
+		}
+	}
+	cm.maxSpan = &span
+}
+
+// Lookup returns the CID for `code`, or false if it is not mapped.
+// The CID ranges must be sorted (see `SortCIDs`).
+// When ranges overlap, the one starting last is used.
+func (cm CMap) Lookup(code CharCode) (model.CID, bool) {
+	// the last range starting before code
+	i := sort.Search(len(cm.CIDs), func(i int) bool { return cm.CIDs[i].Low > code }) - 1
+	for j := i; j >= 0; j-- {
+		rg := cm.CIDs[j]
+		if code <= rg.High {
+			return rg.CIDStart + model.CID(code-rg.Low), true
+		}
+		// the remaining ranges start even before, and are too short to contain code
+		if cm.maxSpan != nil && code-rg.Low > *cm.maxSpan {
+			break
+		}
+	}
+	return 0, false
+}
+
+// NextCode reads the first character code in `data`, which must not be empty,
+// and returns it with its CID and its length, in bytes.
+// Codes which are not mapped are associated to CID 0 (see 9.7.6.3 - Handling Undefined Characters).
+func (cm *CMap) NextCode(data []byte) (code CharCode, cid model.CID, n int) {
+	if cm.Simple() {
+		code, n = CharCode(data[0]), 1
+	} else {
+		var matched bool
+		code, n, matched = cm.matchCode(data)
+		if !matched {
+			// use the length of the shortest codespace matching the first byte
+			n = 1
+			for _, cs := range cm.Codespaces {
+				shift := 8 * uint(cs.NumBytes-1)
+				if b := CharCode(data[0]); cs.Low>>shift <= b && b <= cs.High>>shift && cs.NumBytes <= len(data) {
+					n = cs.NumBytes
+					break
+				}
+			}
+			code = 0
+			for _, b := range data[:n] {
+				code = code<<8 | CharCode(b)
+			}
+			return code, 0, n
+		}
+	}
+	cid, _ = cm.Lookup(code)
+	return code, cid, n
+}
+
+// Decode splits `data` into character codes and returns the associated CIDs
+// (see 9.7.6.2 - CMap Mapping).
+func (cm *CMap) Decode(data []byte) []model.CID {
+	var out []model.CID
+	for len(data) != 0 {
+		_, cid, n := cm.NextCode(data)
+		out = append(out, cid)
+		data = data[n:]
+	}
+	return out
 }
 
 // // CharcodeBytesToUnicode converts a byte array of charcodes to a unicode string representation.
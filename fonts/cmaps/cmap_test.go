@@ -3,6 +3,7 @@ package cmaps
 import (
 	"fmt"
 	"os"
+	"reflect"
 	"testing"
 
 	"github.com/benoitkugler/pdf/model"
@@ -409,7 +410,7 @@ func TestParser4(t *testing.T) {
 
 func TestFullCIDCMap(t *testing.T) {
 	names := [...]model.ObjName{"Adobe-CNS1-3", "KSCms-UHC-H", "Ext-RKSJ-V"}
-	nbCidRanges := [...]int{74, 675, 1}
+	nbCidRanges := [...]int{74, 675, 6} // 1 range and 5 cidchar
 	for i, file := range [...]string{
 		"test/Adobe-CNS1-3.cmap",
 		"test/KSCms-UHC-H.cmap",
@@ -451,3 +452,52 @@ func TestFullToUnicodeCMap(t *testing.T) {
 		fmt.Println(len(cmap.ProperLookupTable()))
 	}
 }
+
+func TestLookup(t *testing.T) {
+	b, err := os.ReadFile("test/usecmap.cmap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmap, err := ParseCIDCMap(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmap.SortCIDs()
+	for code, exp := range map[CharCode]model.CID{0x8141: 7887, 0x8142: 7888, 0x8144: 8274, 0x838e: 7937} {
+		if cid, ok := cmap.Lookup(code); !ok || cid != exp {
+			t.Errorf("expected %d for %x, got %d", exp, code, cid)
+		}
+	}
+	if _, ok := cmap.Lookup(0x8145); ok {
+		t.Error("unexpected CID for unmapped code")
+	}
+}
+
+func TestLookupOverrides(t *testing.T) {
+	// an identity base range, followed by single code overrides
+	cmap := CMap{CIDs: []CIDRange{{Codespace: Codespace{NumBytes: 2, Low: 0, High: 0xFFFF}}}}
+	for code := CharCode(0x100); code < 0x10A; code++ {
+		cmap.CIDs = append(cmap.CIDs, CIDRange{Codespace: Codespace{NumBytes: 2, Low: code, High: code}, CIDStart: 1})
+	}
+	cmap.SortCIDs()
+	for code, exp := range map[CharCode]model.CID{0x10: 0x10, 0x105: 1, 0x1000: 0x1000} {
+		if cid, ok := cmap.Lookup(code); !ok || cid != exp {
+			t.Errorf("expected %d for %x, got %d", exp, code, cid)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	cmap := CMap{
+		Codespaces: []Codespace{{NumBytes: 1, Low: 0, High: 0x80}, {NumBytes: 2, Low: 0x8140, High: 0x9FFC}},
+		CIDs: []CIDRange{
+			{Codespace: Codespace{NumBytes: 1, Low: 0x20, High: 0x7E}, CIDStart: 1},
+			{Codespace: Codespace{NumBytes: 2, Low: 0x8140, High: 0x817E}, CIDStart: 633},
+		},
+	}
+	cids := cmap.Decode([]byte{' ', 'a', 0x81, 0x41, 0x81, 0x80, 0xFF})
+	exp := []model.CID{1, 66, 634, 0, 0}
+	if !reflect.DeepEqual(cids, exp) {
+		t.Fatalf("expected %v, got %v", exp, cids)
+	}
+}
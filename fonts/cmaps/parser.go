@@ -57,6 +57,11 @@ func (cmap *parser) parse() error {
 				if err != nil {
 					return err
 				}
+			case "begincidchar":
+				err := cmap.parseCIDChar()
+				if err != nil {
+					return err
+				}
 			case "beginbfchar":
 				err := cmap.parseBfchar()
 				if err != nil {
@@ -401,6 +406,48 @@ func (cmap *parser) parseCIDRange() error {
 	return nil
 }
 
+// parseCIDChar parses the CID char section of a CMap,
+// storing each mapping as a range of one code.
+func (cmap *parser) parseCIDChar() error {
+	for {
+		o, err := cmap.parseObject()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+
+		hexCode, ok := o.(cmapHexString)
+		if !ok {
+			if op, isOperand := o.(cmapOperand); isOperand && op == "endcidchar" {
+				return nil
+			}
+			return errors.New("cid char code must be a hex string")
+		}
+
+		o, err = cmap.parseObject()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+		cid, ok := o.(int)
+		if !ok || cid < 0 || cid >= (1<<16) {
+			return errors.New("invalid cid value")
+		}
+
+		codespace, err := newCodespaceFromBytes(hexCode, hexCode)
+		if err != nil {
+			return err
+		}
+		cmap.cids.CIDs = append(cmap.cids.CIDs, CIDRange{Codespace: codespace, CIDStart: model.CID(cid)})
+	}
+
+	return nil
+}
+
 // parseBfchar parses a bfchar section of a CMap file.
 func (cmap *parser) parseBfchar() error {
 	for {
@@ -0,0 +1,172 @@
+package cmaps
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/benoitkugler/pdf/model"
+)
+
+// the number of entries in one begin/end block is limited
+// to 100 (see 9.7.5.4 - CMap Example and Operator Summary)
+const maxBlockEntries = 100
+
+// writeBlocks writes `entries` in sections of at most 100 entries,
+// delimited by begin`op` and end`op`
+func writeBlocks(buf *bytes.Buffer, op string, entries []string) {
+	for len(entries) != 0 {
+		n := len(entries)
+		if n > maxBlockEntries {
+			n = maxBlockEntries
+		}
+		fmt.Fprintf(buf, "%d begin%s\n", n, op)
+		for _, entry := range entries[:n] {
+			buf.WriteString(entry)
+			buf.WriteByte('\n')
+		}
+		fmt.Fprintf(buf, "end%s\n", op)
+		entries = entries[n:]
+	}
+}
+
+// writeHeader starts a CMap file
+func writeHeader(buf *bytes.Buffer, name, useCMap model.ObjName, info model.CIDSystemInfo, cmapType int) {
+	buf.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n")
+	if useCMap != "" {
+		fmt.Fprintf(buf, "%s usecmap\n", model.Name(useCMap))
+	}
+	fmt.Fprintf(buf, "/CIDSystemInfo << /Registry (%s) /Ordering (%s) /Supplement %d >> def\n",
+		info.Registry, info.Ordering, info.Supplement)
+	fmt.Fprintf(buf, "/CMapName %s def\n/CMapType %d def\n", model.Name(name), cmapType)
+}
+
+const cmapTrailer = "endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n"
+
+// hexCode formats `code` as an hex string of `numBytes` bytes
+func hexCode(code CharCode, numBytes int) string {
+	return fmt.Sprintf("<%0*x>", 2*numBytes, code)
+}
+
+// Bytes serializes the CMap to a CMap file, which may be embedded
+// in a PDF file (see 9.7.5.3 - Embedded CMap Files).
+// The ranges mapping only one code are written with the cidchar operator.
+func (cm CMap) Bytes() []byte {
+	var buf bytes.Buffer
+	name := cm.Name
+	if name == "" {
+		name = "Custom"
+	}
+	cmapType := cm.Type
+	if cmapType == 0 {
+		cmapType = 1
+	}
+	writeHeader(&buf, name, cm.UseCMap, cm.CIDSystemInfo, cmapType)
+
+	entries := make([]string, len(cm.Codespaces))
+	for i, cs := range cm.Codespaces {
+		entries[i] = hexCode(cs.Low, cs.NumBytes) + " " + hexCode(cs.High, cs.NumBytes)
+	}
+	writeBlocks(&buf, "codespacerange", entries)
+
+	var chars, ranges []string
+	for _, rg := range cm.CIDs {
+		if rg.Low == rg.High {
+			chars = append(chars, fmt.Sprintf("%s %d", hexCode(rg.Low, rg.NumBytes), rg.CIDStart))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%s %s %d", hexCode(rg.Low, rg.NumBytes), hexCode(rg.High, rg.NumBytes), rg.CIDStart))
+		}
+	}
+	writeBlocks(&buf, "cidchar", chars)
+	writeBlocks(&buf, "cidrange", ranges)
+
+	buf.WriteString(cmapTrailer)
+	return buf.Bytes()
+}
+
+// NewUnicodeCMap returns a compact representation of `mapping`,
+// merging the consecutive codes mapped to consecutive runes.
+func NewUnicodeCMap(mapping map[model.CID][]rune) UnicodeCMap {
+	codes := make([]model.CID, 0, len(mapping))
+	for code := range mapping {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+
+	var out UnicodeCMap
+	for i := 0; i < len(codes); {
+		from, rs := codes[i], mapping[codes[i]]
+		if len(rs) != 1 {
+			out.Mappings = append(out.Mappings, ToUnicodePair{From: from, Dest: rs})
+			i++
+			continue
+		}
+		// extend the range as long as possible
+		to := from
+		for j := i + 1; j < len(codes); j++ {
+			next := mapping[codes[j]]
+			if codes[j] != to+1 || len(next) != 1 || next[0] != rs[0]+rune(codes[j]-from) {
+				break
+			}
+			to = codes[j]
+		}
+		out.Mappings = append(out.Mappings, ToUnicodeTranslation{From: from, To: to, Dest: rs[0]})
+		i += int(to-from) + 1
+	}
+	return out
+}
+
+// Bytes serializes the CMap to a ToUnicode CMap file, which may be embedded
+// in a PDF file (see 9.10.3 - ToUnicode CMaps). Two bytes codes are used.
+func (u UnicodeCMap) Bytes() []byte {
+	var buf bytes.Buffer
+	writeHeader(&buf, "Adobe-Identity-UCS", u.UseCMap, model.CIDSystemInfo{Registry: "Adobe", Ordering: "UCS"}, 2)
+	writeBlocks(&buf, "codespacerange", []string{"<0000> <ffff>"})
+
+	var chars, ranges []string
+	addChar := func(code model.CID, rs []rune) {
+		chars = append(chars, fmt.Sprintf("<%04x> <%s>", code, runesToHex(rs)))
+	}
+	for _, m := range u.Mappings {
+		switch m := m.(type) {
+		case ToUnicodePair:
+			addChar(m.From, m.Dest)
+		case ToUnicodeTranslation:
+			// a bfrange only increments the last byte of the codes and of the destination:
+			// split the range accordingly
+			for from, dest := int(m.From), m.Dest; from <= int(m.To); {
+				if dest > 0xFFFF { // surrogates pairs are not incremented
+					addChar(model.CID(from), []rune{dest})
+					from, dest = from+1, dest+1
+					continue
+				}
+				to := int(m.To)
+				if last := from | 0xFF; to > last {
+					to = last
+				}
+				if last := from + int(dest|0xFF-dest); to > last {
+					to = last
+				}
+				if to == from {
+					addChar(model.CID(from), []rune{dest})
+				} else {
+					ranges = append(ranges, fmt.Sprintf("<%04x> <%04x> <%04x>", from, to, dest))
+				}
+				dest += rune(to - from + 1)
+				from = to + 1
+			}
+		case ToUnicodeArray:
+			for code := m.From; code <= m.To; code++ {
+				addChar(code, m.Runes[code-m.From])
+				if code == 0xFFFF {
+					break
+				}
+			}
+		}
+	}
+	writeBlocks(&buf, "bfchar", chars)
+	writeBlocks(&buf, "bfrange", ranges)
+
+	buf.WriteString(cmapTrailer)
+	return buf.Bytes()
+}
@@ -0,0 +1,66 @@
+package cmaps
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/benoitkugler/pdf/model"
+)
+
+func TestWriteCIDCMap(t *testing.T) {
+	for _, file := range [...]string{
+		"test/Adobe-CNS1-3.cmap",
+		"test/KSCms-UHC-H.cmap",
+		"test/usecmap.cmap",
+	} {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cmap, err := ParseCIDCMap(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cmap.simple = nil
+
+		cmap2, err := ParseCIDCMap(cmap.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		cmap2.simple = nil
+		if cmap.Type == 0 {
+			cmap.Type = 1
+		}
+		if !reflect.DeepEqual(cmap.CharCodeToCID(), cmap2.CharCodeToCID()) {
+			t.Fatalf("%s: CIDs not preserved", file)
+		}
+		cmap.CIDs, cmap2.CIDs = nil, nil // the order of cidchar and cidrange may change
+		if !reflect.DeepEqual(cmap, cmap2) {
+			t.Fatalf("%s: expected\n%v\n got\n%v", file, cmap, cmap2)
+		}
+	}
+}
+
+func TestWriteUnicodeCMap(t *testing.T) {
+	mapping := map[model.CID][]rune{
+		1: {'a'}, 2: {'b'}, 3: {'c'}, // range
+		10:   {'f', 'i'},                                         // ligature
+		0xF0: {0xFE}, 0xF1: {0xFF}, 0xF2: {0x100}, 0xF3: {0x101}, // range crossing byte boundaries
+		0x120: {0x1F600}, 0x121: {0x1F601}, // non BMP runes
+	}
+	u := NewUnicodeCMap(mapping)
+	if len(u.Mappings) != 4 {
+		t.Fatalf("unexpected compact form %v", u.Mappings)
+	}
+	u.Mappings = append(u.Mappings, ToUnicodeArray{From: 200, To: 201, Runes: [][]rune{{'x'}, {'y', 'z'}}})
+	mapping[200], mapping[201] = []rune{'x'}, []rune{'y', 'z'}
+
+	parsed, err := ParseUnicodeCMap(u.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := parsed.ProperLookupTable(); !reflect.DeepEqual(got, mapping) {
+		t.Fatalf("expected %v, got %v", mapping, got)
+	}
+}
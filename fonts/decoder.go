@@ -0,0 +1,189 @@
+package fonts
+
+import (
+	"github.com/benoitkugler/pdf/fonts/cmaps"
+	"github.com/benoitkugler/pdf/fonts/glyphsnames"
+	"github.com/benoitkugler/pdf/fonts/standardcmaps"
+	"github.com/benoitkugler/pdf/fonts/standardfonts"
+	"github.com/benoitkugler/pdf/model"
+)
+
+// Glyph is one character code shown by a text-showing operator.
+type Glyph struct {
+	Code  []byte    // 1 byte for simple fonts, 1 to 4 bytes for Type0 fonts
+	CID   model.CID // for Type0 fonts, the CID given by the encoding CMap
+	Text  []rune    // Unicode text, nil if unknown
+	Width Fl        // horizontal advance, in thousandths of text space unit
+}
+
+// Decoder splits the strings shown with a font into glyphs,
+// providing their widths and Unicode values (see 9.10 - Extraction of Text Content).
+// Since it is used to locate and extract the content of existing documents,
+// it never fails, and reasonable defaults are used for missing values.
+// The codes of Type0 fonts are split and mapped to CIDs by their encoding CMap
+// (see standardcmaps.ResolveCMap for the supported CMaps; Identity-H is used as fallback),
+// and mapped to Unicode by their ToUnicode entry or, if missing, by the predefined
+// CMap of their ordering.
+type Decoder struct {
+	// vertical extent of the glyphs, in thousandths of text space unit.
+	// Ascent is always greater than Descent.
+	Ascent, Descent Fl
+
+	// simple fonts
+	firstChar byte
+	widths    []Fl
+
+	// Type0 fonts
+	cmap         *cmaps.CMap // nil for simple fonts
+	cidWidths    map[model.CID]Fl
+	cidToUnicode map[model.CID][]rune // used when the ToUnicode entry is missing
+
+	defaultWidth Fl
+	toUnicode    map[model.CID][]rune // from character codes
+}
+
+// NewDecoder returns the decoder for `font`, which may be nil.
+func NewDecoder(font *model.FontDict) Decoder {
+	f, _ := newDecoder(font) // errors are ignored
+	return f
+}
+
+// newDecoder returns the error met when resolving the ToUnicode
+// entry or the encoding of `font`, if any, with a usable decoder
+func newDecoder(font *model.FontDict) (Decoder, error) {
+	f := Decoder{defaultWidth: 500}
+	var (
+		desc model.FontDescriptor
+		err  error
+	)
+	if font != nil {
+		if font.ToUnicode != nil {
+			f.toUnicode, err = ResolveToUnicode(*font.ToUnicode)
+		}
+		switch ft := font.Subtype.(type) {
+		case model.FontType1:
+			desc = f.setSimpleMetrics(ft, ft)
+		case model.FontTrueType:
+			desc = f.setSimpleMetrics(model.FontType1(ft), ft)
+		case model.FontType3:
+			scale := ft.FontMatrix[0] * 1000
+			f.firstChar, f.widths = ft.FirstChar, make([]Fl, len(ft.Widths))
+			for i, w := range ft.Widths {
+				f.widths[i] = Fl(w) * scale
+			}
+			f.defaultWidth = 0
+			desc.Ascent, desc.Descent = ft.FontBBox.Ury*ft.FontMatrix[3]*1000, ft.FontBBox.Lly*ft.FontMatrix[3]*1000
+			f.mergeSimpleEncoding(ft)
+		case model.FontType0:
+			cmap, cmapErr := standardcmaps.ResolveCMap(ft.Encoding)
+			if cmapErr != nil {
+				if err == nil {
+					err = cmapErr
+				}
+				cmap, _ = standardcmaps.PredefinedCMap("Identity-H")
+			}
+			f.cmap = &cmap
+			f.cidWidths = ft.DescendantFonts.Widths()
+			f.defaultWidth = 1000
+			if dw := ft.DescendantFonts.DW; dw != 0 {
+				f.defaultWidth = Fl(dw)
+			}
+			desc = ft.DescendantFonts.FontDescriptor
+			if f.toUnicode == nil {
+				name := ft.DescendantFonts.CIDSystemInfo.ToUnicodeCMapName()
+				if cmap, ok := standardcmaps.ToUnicodeCMaps[model.ObjName(name)]; ok {
+					f.cidToUnicode = cmap.ProperLookupTable()
+				}
+			}
+		}
+	}
+	f.Ascent, f.Descent = desc.Ascent, desc.Descent
+	if f.Ascent <= f.Descent {
+		f.Ascent, f.Descent = 800, -200
+	}
+	return f, err
+}
+
+// setSimpleMetrics fills the widths of `f`, using the standard fonts
+// metrics if needed, and returns the font descriptor to use.
+// `font` is the original font, used to resolve the encoding.
+func (f *Decoder) setSimpleMetrics(ft model.FontType1, font model.FontSimple) model.FontDescriptor {
+	desc := ft.FontDescriptor
+	firstChar, widths := ft.FirstChar, ft.Widths
+	if metrics, ok := standardfonts.Fonts[string(ft.BaseFont)]; ok {
+		if len(widths) == 0 {
+			firstChar, widths = metrics.WidthsWithEncoding(ResolveSimpleEncoding(font))
+		}
+		if desc.Ascent <= desc.Descent {
+			desc = metrics.Descriptor
+		}
+	}
+	f.firstChar, f.widths = firstChar, make([]Fl, len(widths))
+	for i, w := range widths {
+		f.widths[i] = Fl(w)
+	}
+	f.defaultWidth = Fl(desc.MissingWidth)
+	f.mergeSimpleEncoding(font)
+	return desc
+}
+
+// mergeSimpleEncoding completes the ToUnicode mapping
+// using the glyph names of the encoding of `ft`
+func (f *Decoder) mergeSimpleEncoding(ft model.FontSimple) {
+	enc := ResolveSimpleEncoding(ft)
+	if f.toUnicode == nil {
+		f.toUnicode = make(map[model.CID][]rune)
+	}
+	for code, name := range enc {
+		if _, has := f.toUnicode[model.CID(code)]; has || name == "" {
+			continue
+		}
+		if r, ok := glyphsnames.GlyphToRune(name); ok {
+			f.toUnicode[model.CID(code)] = []rune{r}
+		}
+	}
+}
+
+// width returns the width of the glyph for the code `code`
+// of a simple font, in thousandths of text space unit
+func (f Decoder) width(code byte) Fl {
+	if i := int(code) - int(f.firstChar); i >= 0 && i < len(f.widths) {
+		return f.widths[i]
+	}
+	return f.defaultWidth
+}
+
+// cidWidth returns the width of the glyph `cid`
+// of a Type0 font, in thousandths of text space unit
+func (f Decoder) cidWidth(cid model.CID) Fl {
+	if w, ok := f.cidWidths[cid]; ok {
+		return w
+	}
+	return f.defaultWidth
+}
+
+// Decode splits `codes` into glyphs.
+func (f Decoder) Decode(codes []byte) []Glyph {
+	out := make([]Glyph, 0, len(codes))
+	for len(codes) != 0 {
+		if f.cmap == nil {
+			code := codes[0]
+			out = append(out, Glyph{Code: codes[:1], Text: f.toUnicode[model.CID(code)], Width: f.width(code)})
+			codes = codes[1:]
+			continue
+		}
+
+		code, cid, n := f.cmap.NextCode(codes)
+		glyph := Glyph{Code: codes[:n], CID: cid, Width: f.cidWidth(cid)}
+		if f.toUnicode != nil {
+			if code <= 0xFFFF {
+				glyph.Text = f.toUnicode[model.CID(code)]
+			}
+		} else {
+			glyph.Text = f.cidToUnicode[cid]
+		}
+		out = append(out, glyph)
+		codes = codes[n:]
+	}
+	return out
+}
@@ -2,7 +2,6 @@ package fonts
 
 import (
 	"bytes"
-	"log"
 
 	"github.com/benoitkugler/pdf/fonts/cmaps"
 	"github.com/benoitkugler/pdf/fonts/simpleencodings"
@@ -18,7 +17,7 @@ import (
 // (in order of priority):
 //  1. FontDict.Encoding or FontDict.Encoding.BaseEncoding
 //     - MacRoman / MacExpert / WinAnsi / Standard
-//  2. embedded or external font file
+//  2. embedded or external font file (for TrueType fonts, only if symbolic)
 //  3. default:
 //     - builtin --> builtin encoding
 //     - TrueType --> WinAnsiEncoding
@@ -37,10 +36,15 @@ func ResolveSimpleEncoding(font model.FontSimple) simpleencodings.Encoding {
 		baseEnc = standardfonts.PredefinedEncodings[encDict.BaseEncoding]
 	} else {
 		// check embedded font file for base encoding
-		// (only for Type 1 fonts - trying to get an encoding out of a
-		// TrueType font is a losing proposition)
-		if font, ok := font.(model.FontType1); ok {
+		// (for TrueType fonts, only symbolic fonts use the 'cmap'
+		// subtables of the font file)
+		switch font := font.(type) {
+		case model.FontType1:
 			baseEnc = builtinType1Encoding(font.FontDescriptor)
+		case model.FontTrueType:
+			if font.FontDescriptor.Flags&model.Symbolic != 0 {
+				baseEnc = builtinTrueTypeEncoding(font.FontDescriptor)
+			}
 		}
 	}
 
@@ -67,7 +71,7 @@ func buildSimpleFromUnicode(enc *simpleencodings.Encoding, toUnicode map[model.C
 	encToUnicode := enc.RuneToByte()
 	for cid, r := range toUnicode {
 		if cid > 255 { // invalid char code: warn and ignore it
-			log.Printf("invalid char code in simple ToUnicode CMap : %d > 255\n", cid)
+			model.DefaultLogger().Log(model.LogWarning, "invalid char code in simple ToUnicode CMap", "code", cid)
 			continue
 		}
 		encToUnicode[r[0]] = byte(cid)
@@ -149,14 +153,14 @@ func builtinType1Encoding(desc model.FontDescriptor) *simpleencodings.Encoding {
 	}
 	content, err := desc.FontFile.Decode()
 	if err != nil {
-		log.Printf("unable to decode embedded font file: %s\n", err)
+		model.DefaultLogger().Log(model.LogWarning, "unable to decode embedded font file", "error", err)
 		return &simpleencodings.AdobeStandard
 	}
 	isCFF := desc.FontFile.Subtype == "Type1C"
 	if isCFF {
 		enc, err := type1c.ParseEncoding(bytes.NewReader(content))
 		if err != nil {
-			log.Printf("invalid Type1C embedded font file: %s\n", err)
+			model.DefaultLogger().Log(model.LogWarning, "invalid Type1C embedded font file", "error", err)
 		}
 
 		// some Type 1C font files have empty encodings, which can break the
@@ -174,7 +178,7 @@ func builtinType1Encoding(desc model.FontDescriptor) *simpleencodings.Encoding {
 	} else {
 		info, err := type1.ParseEncoding(bytes.NewReader(content))
 		if err != nil {
-			log.Printf("invalid Type1 embedded font file: %s\n", err)
+			model.DefaultLogger().Log(model.LogWarning, "invalid Type1 embedded font file", "error", err)
 			return &simpleencodings.AdobeStandard
 		}
 		return info
@@ -314,8 +318,11 @@ func builtinType1Encoding(desc model.FontDescriptor) *simpleencodings.Encoding {
 // 	}
 // }
 
-// parse the CMap and resolve the chain of UseCMap if needed
-func resolveToUnicode(cmap model.UnicodeCMap) (map[model.CID][]rune, error) {
+// ResolveToUnicode parses the ToUnicode CMap stream `cmap` and returns
+// the mapping from character codes to Unicode, resolving the chain of UseCMap if needed.
+// The mappings of `cmap` take precedence over the used CMaps.
+// See `cmaps.UnicodeCMap.Bytes` for the reverse operation.
+func ResolveToUnicode(cmap model.UnicodeCMap) (map[model.CID][]rune, error) {
 	content, err := cmap.Decode()
 	if err != nil {
 		return nil, err
@@ -329,20 +336,22 @@ func resolveToUnicode(cmap model.UnicodeCMap) (map[model.CID][]rune, error) {
 	var used map[model.CID][]rune
 	switch use := cmap.UseCMap.(type) {
 	case model.UnicodeCMap:
-		used, err = resolveToUnicode(use)
+		used, err = ResolveToUnicode(use)
 		if err != nil {
 			return nil, err
 		}
 	case model.UnicodeCMapBasePredefined:
 		predef, ok := standardcmaps.ToUnicodeCMaps[model.ObjName(use)]
 		if !ok {
-			log.Printf("unknown predefined UnicodeCMap %s", use)
+			model.DefaultLogger().Log(model.LogWarning, "unknown predefined UnicodeCMap", "name", use)
 		}
 		used = predef.ProperLookupTable()
 	}
 	// merged the data from the UseCMap entry
 	for k, v := range used {
-		out[k] = v
+		if _, has := out[k]; !has {
+			out[k] = v
+		}
 	}
 	return out, nil
 }
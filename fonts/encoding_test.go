@@ -1,10 +1,16 @@
 package fonts
 
 import (
+	"encoding/binary"
 	"os"
+	"reflect"
 	"testing"
 
+	"github.com/benoitkugler/pdf/fonts/cmaps"
+	"github.com/benoitkugler/pdf/fonts/simpleencodings"
 	"github.com/benoitkugler/pdf/model"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
 )
 
 func TestDefinedEnc(t *testing.T) {
@@ -34,3 +40,80 @@ func TestDefinedEnc(t *testing.T) {
 		t.Errorf("expected 25, got %d", enc[239])
 	}
 }
+
+func TestResolveToUnicode(t *testing.T) {
+	base := cmaps.NewUnicodeCMap(map[model.CID][]rune{1: {'a'}, 2: {'b'}})
+	custom := cmaps.NewUnicodeCMap(map[model.CID][]rune{2: {'c'}, 3: {'d'}})
+	cmap := model.UnicodeCMap{
+		Stream:  model.Stream{Content: custom.Bytes()},
+		UseCMap: model.UnicodeCMap{Stream: model.Stream{Content: base.Bytes()}},
+	}
+	got, err := ResolveToUnicode(cmap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := map[model.CID][]rune{1: {'a'}, 2: {'c'}, 3: {'d'}}; !reflect.DeepEqual(got, exp) {
+		t.Fatalf("expected %v, got %v", exp, got)
+	}
+}
+
+// symbolicCmapTable returns a 'cmap' table with one subtable mapping
+// `code` to `gid`, either (3,0) with format 4, or (1,0) with format 0
+func symbolicCmapTable(windows bool, code byte, gid uint16) []byte {
+	be := binary.BigEndian
+	if windows {
+		sub := make([]byte, 16+8*2)
+		be.PutUint16(sub, 4)
+		be.PutUint16(sub[2:], uint16(len(sub)))
+		be.PutUint16(sub[6:], 2*2)                        // segCountX2
+		be.PutUint16(sub[14:], 0xF000+uint16(code))       // endCode
+		be.PutUint16(sub[16:], 0xFFFF)                    // endCode
+		be.PutUint16(sub[20:], 0xF000+uint16(code))       // startCode
+		be.PutUint16(sub[22:], 0xFFFF)                    // startCode
+		be.PutUint16(sub[24:], gid-(0xF000+uint16(code))) // idDelta
+		be.PutUint16(sub[26:], 1)                         // idDelta
+		return append([]byte{0, 0, 0, 1, 0, 3, 0, 0, 0, 0, 0, 12}, sub...)
+	}
+	sub := make([]byte, 6+256)
+	be.PutUint16(sub[2:], uint16(len(sub)))
+	sub[6+int(code)] = byte(gid)
+	return append([]byte{0, 0, 0, 1, 0, 1, 0, 0, 0, 0, 0, 12}, sub...)
+}
+
+func TestSymbolicTrueType(t *testing.T) {
+	ft, err := sfnt.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gA, _ := ft.GlyphIndex(nil, 'A')
+
+	for _, windows := range []bool{true, false} {
+		file := withTable(goregular.TTF, "cmap", symbolicCmapTable(windows, 'a', uint16(gA)))
+		font := &model.FontDict{Subtype: model.FontTrueType{
+			BaseFont: "Symbols",
+			FontDescriptor: model.FontDescriptor{
+				Flags:    model.Symbolic,
+				FontFile: &model.FontFile{Stream: model.Stream{Content: file}},
+			},
+		}}
+		enc, ok := EncodingFor(font)
+		if !ok {
+			t.Fatal("expected simple font")
+		}
+		if enc['a'] != "A" || enc['b'] != "" {
+			t.Fatalf("unexpected encoding %q %q", enc['a'], enc['b'])
+		}
+		if glyphs := NewDecoder(font).Decode([]byte("a")); string(glyphs[0].Text) != "A" {
+			t.Fatalf("unexpected text %q", string(glyphs[0].Text))
+		}
+	}
+
+	// non symbolic fonts use the default encoding
+	enc, _ := EncodingFor(&model.FontDict{Subtype: model.FontTrueType{}})
+	if enc != simpleencodings.WinAnsi {
+		t.Fatal("expected WinAnsi encoding")
+	}
+	if _, ok := EncodingFor(&model.FontDict{Subtype: model.FontType0{}}); ok {
+		t.Fatal("Type0 fonts have no simple encoding")
+	}
+}
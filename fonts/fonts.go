@@ -20,7 +20,6 @@ package fonts
 
 import (
 	"errors"
-	"log"
 
 	"github.com/benoitkugler/pdf/fonts/cmaps"
 	"github.com/benoitkugler/pdf/fonts/standardfonts"
@@ -51,9 +50,16 @@ type BuiltFont struct {
 type Font interface {
 	// GetWidth return the size, in points, needed to display the character `c`
 	// using the font size `size`.
-	// Note that this method can't handle kerning.
+	// Note that this method can't handle kerning (see `Kern` and `TextWidth`).
 	GetWidth(c rune, size Fl) Fl
 
+	// Kern returns the kerning adjustment to apply between `c1` and `c2`,
+	// in thousandths of text space unit. A negative value brings the
+	// characters closer. Only the kerning found in embedded TrueType or
+	// OpenType font files, or in the metrics of the (non embedded) standard
+	// Type1 fonts is supported: 0 is returned for other fonts.
+	Kern(c1, c2 rune) int
+
 	// Encode transform a slice of unicode points to a
 	// slice of bytes, conform to the font expectation.
 	// See `EncodeKern` for kerning support.
@@ -69,6 +75,7 @@ type simpleFont struct {
 	firstChar byte
 	widths    []int
 	charMap   map[rune]byte
+	kerning   kerning
 }
 
 func (ft simpleFont) GetWidth(c rune, size Fl) Fl {
@@ -94,7 +101,7 @@ func (ft simpleFont) Encode(cs []rune) []byte {
 		default:
 			b, ok := ft.charMap[c]
 			if !ok {
-				log.Printf("unsupported rune %s %d", string(c), c)
+				model.DefaultLogger().Log(model.LogWarning, "unsupported rune", "rune", string(c), "code", c)
 				b = '.'
 			}
 			out[i] = b
@@ -103,6 +110,8 @@ func (ft simpleFont) Encode(cs []rune) []byte {
 	return out
 }
 
+func (ft simpleFont) Kern(c1, c2 rune) int { return ft.kerning.kern(c1, c2) }
+
 func (ft simpleFont) Desc() model.FontDescriptor {
 	return ft.desc
 }
@@ -116,6 +125,8 @@ type compositeFont struct {
 	// the special case of the Identity CMap
 	// is handled by setting cmap to nil
 	reversedCMap map[model.CID]cmaps.CharCode
+
+	kerning kerning
 }
 
 func (ft compositeFont) Encode(cs []rune) []byte {
@@ -123,7 +134,7 @@ func (ft compositeFont) Encode(cs []rune) []byte {
 	for _, r := range cs {
 		cid, ok := ft.fromUnicode[r]
 		if !ok {
-			log.Printf("unsupported rune %s %d", string(r), r)
+			model.DefaultLogger().Log(model.LogWarning, "unsupported rune", "rune", string(r), "code", r)
 		}
 		var charCode cmaps.CharCode
 		if ft.reversedCMap == nil { // identity
@@ -131,7 +142,7 @@ func (ft compositeFont) Encode(cs []rune) []byte {
 		} else {
 			charCode, ok = ft.reversedCMap[cid]
 			if !ok {
-				log.Printf("unsupported CID %d", cid)
+				model.DefaultLogger().Log(model.LogWarning, "unsupported CID", "cid", cid)
 			}
 		}
 		charCode.Append(&out)
@@ -148,6 +159,8 @@ func (ft compositeFont) GetWidth(c rune, size Fl) Fl {
 	return Fl(w) * 0.001 * size
 }
 
+func (ct compositeFont) Kern(c1, c2 rune) int { return ct.kerning.kern(c1, c2) }
+
 func (ct compositeFont) Desc() model.FontDescriptor { return ct.desc }
 
 // BuildFont compiles an existing FontDictionary, as found in a PDF,
@@ -160,7 +173,7 @@ func BuildFont(f *model.FontDict) (BuiltFont, error) {
 		err       error
 	)
 	if f.ToUnicode != nil {
-		toUnicode, err = resolveToUnicode(*f.ToUnicode)
+		toUnicode, err = ResolveToUnicode(*f.ToUnicode)
 		if err != nil {
 			return BuiltFont{}, err
 		}
@@ -177,6 +190,7 @@ func BuildFont(f *model.FontDict) (BuiltFont, error) {
 				charMap:   simpleCharMap,
 				firstChar: ft.FirstChar,
 				widths:    ft.Widths,
+				kerning:   loadStandardKerning(ft, simpleCharMap, enc), // OpenType or standard fonts only
 			}
 		case model.FontTrueType:
 			out = simpleFont{
@@ -184,6 +198,7 @@ func BuildFont(f *model.FontDict) (BuiltFont, error) {
 				charMap:   simpleCharMap,
 				firstChar: ft.FirstChar,
 				widths:    ft.Widths,
+				kerning:   loadKerning(ft.FontDescriptor),
 			}
 		case model.FontType3:
 			out = simpleFont{
@@ -212,6 +227,7 @@ func BuildFont(f *model.FontDict) (BuiltFont, error) {
 		return BuiltFont{Meta: f, Font: compositeFont{
 			fromUnicode: fromUnicode,
 			desc:        buildType0FontDesc(ft),
+			kerning:     loadKerning(ft.DescendantFonts.FontDescriptor),
 		}}, nil
 	}
 
@@ -1,11 +1,19 @@
 package fonts
 
 import (
+	"encoding/binary"
 	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
 
+	"github.com/benoitkugler/pdf/fonts/cmaps"
 	"github.com/benoitkugler/pdf/fonts/standardfonts"
 	"github.com/benoitkugler/pdf/model"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
 )
 
 func TestStandard(t *testing.T) {
@@ -18,3 +26,286 @@ func TestStandard(t *testing.T) {
 		fmt.Println(name, font.GetWidth('u', 12))
 	}
 }
+
+// withKernTable returns a copy of the TrueType font `ttf`, with an additional
+// 'kern' table defining one pair
+func withKernTable(ttf []byte, left, right sfnt.GlyphIndex, value int16) []byte {
+	kern := []byte{
+		0, 0, 0, 1, // version, nTables
+		0, 0, 0, 20, 0, 1, // subtable version, length, format 0 and horizontal coverage
+		0, 1, 0, 6, 0, 0, 0, 0, // nPairs, searchRange, entrySelector, rangeShift
+		byte(left >> 8), byte(left), byte(right >> 8), byte(right), byte(uint16(value) >> 8), byte(value),
+	}
+	return withTable(ttf, "kern", kern)
+}
+
+// withTable returns a copy of the TrueType font `ttf`, where
+// the table `tag` is added, or replaced by `table`
+func withTable(ttf []byte, tag string, table []byte) []byte {
+	numTables := int(binary.BigEndian.Uint16(ttf[4:]))
+	records := make([][]byte, 0, numTables+1)
+	for i := 0; i < numTables; i++ {
+		if string(ttf[12+16*i:12+16*i+4]) == tag { // replaced
+			continue
+		}
+		records = append(records, append([]byte(nil), ttf[12+16*i:12+16*(i+1)]...))
+	}
+	// the directory may grow by one record
+	shift := 16 * (len(records) + 1 - numTables)
+	for _, record := range records {
+		binary.BigEndian.PutUint32(record[8:], uint32(int(binary.BigEndian.Uint32(record[8:]))+shift))
+	}
+	for len(ttf)%4 != 0 {
+		ttf = append(ttf, 0)
+	}
+	record := make([]byte, 16)
+	copy(record, tag)
+	binary.BigEndian.PutUint32(record[8:], uint32(len(ttf)+shift))
+	binary.BigEndian.PutUint32(record[12:], uint32(len(table)))
+	records = append(records, record)
+	sort.Slice(records, func(i, j int) bool { return string(records[i][:4]) < string(records[j][:4]) })
+
+	out := append([]byte(nil), ttf[:12]...)
+	binary.BigEndian.PutUint16(out[4:], uint16(len(records)))
+	for _, record := range records {
+		out = append(out, record...)
+	}
+	out = append(out, ttf[12+16*numTables:]...)
+	return append(out, table...)
+}
+
+func TestKerning(t *testing.T) {
+	ft, err := sfnt.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gA, _ := ft.GlyphIndex(nil, 'A')
+	gV, _ := ft.GlyphIndex(nil, 'V')
+	// Go Regular uses 2048 units per em
+	fontFile := &model.FontFile{Stream: model.Stream{Content: withKernTable(goregular.TTF, gA, gV, -160)}}
+
+	widths := make([]int, 'Z'-'A'+1)
+	for i := range widths {
+		widths[i] = 600
+	}
+	fontDict := &model.FontDict{Subtype: model.FontTrueType{
+		BaseFont:       "GoRegular",
+		FirstChar:      'A',
+		Widths:         widths,
+		Encoding:       model.WinAnsiEncoding,
+		FontDescriptor: model.FontDescriptor{FontName: "GoRegular", FontFile: fontFile},
+	}}
+	font, err := BuildFont(fontDict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k := font.Kern('A', 'V'); k != -78 {
+		t.Fatalf("unexpected kerning %d", k)
+	}
+	if k := font.Kern('V', 'A'); k != 0 {
+		t.Fatalf("unexpected kerning %d", k)
+	}
+
+	texts := EncodeKern(font, []rune("AVA"))
+	expected := []TextSpaced{{CharCodes: []byte("A"), SpaceSubtractedAfter: 78}, {CharCodes: []byte("VA")}}
+	if !reflect.DeepEqual(texts, expected) {
+		t.Fatalf("unexpected encoding %v", texts)
+	}
+	if w := TextWidth(font, []rune("AVA"), 10); math.Abs(float64(w-(18-0.78))) > 1e-4 {
+		t.Fatalf("unexpected width %f", w)
+	}
+
+	// standard fonts use the kerning of their metrics
+	standard, err := BuildFont(&model.FontDict{Subtype: standardfonts.Helvetica.WesternType1Font()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k := standard.Kern('A', 'V'); k != -70 {
+		t.Fatalf("unexpected kerning %d", k)
+	}
+	if k := standard.Kern('V', 'A'); k != -80 {
+		t.Fatalf("unexpected kerning %d", k)
+	}
+	expected = []TextSpaced{{CharCodes: []byte("A"), SpaceSubtractedAfter: 70}, {CharCodes: []byte("V"), SpaceSubtractedAfter: 80}, {CharCodes: []byte("A")}}
+	if texts := EncodeKern(standard, []rune("AVA")); !reflect.DeepEqual(texts, expected) {
+		t.Fatalf("unexpected encoding %v", texts)
+	}
+
+	// other fonts without embedded file have no kerning
+	custom := standardfonts.Helvetica.WesternType1Font()
+	custom.BaseFont = "MyHelvetica"
+	other, err := BuildFont(&model.FontDict{Subtype: custom})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if texts := EncodeKern(other, []rune("AVA")); len(texts) != 1 {
+		t.Fatalf("unexpected encoding %v", texts)
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	font := standardfonts.Helvetica.WesternType1Font()
+	// the widths are not updated by the differences
+	font.Encoding = &model.SimpleEncodingDict{Differences: model.Differences{65: "eacute"}}
+	dec := NewDecoder(&model.FontDict{Subtype: font})
+	glyphs := dec.Decode([]byte("AB "))
+	if len(glyphs) != 3 {
+		t.Fatalf("unexpected glyphs %v", glyphs)
+	}
+	if string(glyphs[0].Text) != "é" || string(glyphs[1].Text) != "B" || string(glyphs[2].Text) != " " {
+		t.Fatalf("unexpected text %v", glyphs)
+	}
+	if glyphs[0].Width != 667 || glyphs[1].Width != 667 || glyphs[2].Width != 278 {
+		t.Fatalf("unexpected widths %v", glyphs)
+	}
+
+	// missing fonts use default values
+	dec = NewDecoder(nil)
+	if glyphs := dec.Decode([]byte("a")); len(glyphs) != 1 || glyphs[0].Text != nil || dec.Ascent <= dec.Descent {
+		t.Fatalf("unexpected glyphs %v", glyphs)
+	}
+}
+
+func TestMeasure(t *testing.T) {
+	near := func(a, b Fl) bool { return math.Abs(float64(a-b)) < 1e-3 }
+
+	// Widths array
+	helvetica := standardfonts.Helvetica.WesternType1Font()
+	w, err := Measure(&model.FontDict{Subtype: helvetica}, "Hé l", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := Fl(722+556+278+222) * 0.01; !near(w, exp) {
+		t.Fatalf("expected %g, got %g", exp, w)
+	}
+
+	// standard font without widths
+	w, err = Measure(&model.FontDict{Subtype: model.FontType1{BaseFont: "Courier"}}, "abc", 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := Fl(3*600) * 0.012; !near(w, exp) {
+		t.Fatalf("expected %g, got %g", exp, w)
+	}
+
+	// differences
+	helvetica.Encoding = &model.SimpleEncodingDict{BaseEncoding: model.WinAnsiEncoding, Differences: model.Differences{65: "eacute"}}
+	if _, err = Measure(&model.FontDict{Subtype: helvetica}, "A", 12); err == nil {
+		t.Fatal("expected error for unsupported rune")
+	}
+
+	// Type0 font with W array
+	toUnicode := cmaps.WriteAdobeIdentityUnicodeCMap(map[uint32][]rune{1: {'a'}, 2: {'b'}, 300: {'c'}})
+	type0 := model.FontType0{
+		BaseFont: "Custom",
+		Encoding: model.CMapEncodingPredefined("Identity-H"),
+		DescendantFonts: model.CIDFontDictionary{
+			Subtype: "CIDFontType2",
+			DW:      800,
+			W: []model.CIDWidth{
+				model.CIDWidthArray{Start: 1, W: []Fl{400, 500}},
+				model.CIDWidthRange{First: 300, Last: 310, Width: 250},
+			},
+		},
+	}
+	font := &model.FontDict{Subtype: type0, ToUnicode: &model.UnicodeCMap{Stream: model.Stream{Content: toUnicode}}}
+	w, err = Measure(font, "abcab", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := Fl(400 + 500 + 250 + 400 + 500); !near(w, exp) {
+		t.Fatalf("expected %g, got %g", exp, w)
+	}
+	if _, err = Measure(font, "d", 12); err == nil {
+		t.Fatal("expected error for unsupported rune")
+	}
+
+	if _, err = Measure(nil, "a", 12); err == nil {
+		t.Fatal("expected error for missing font")
+	}
+}
+
+func TestTextLayout(t *testing.T) {
+	font, err := BuildFont(&model.FontDict{Subtype: standardfonts.Courier.WesternType1Font()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Courier glyphs are 6 points wide at size 10
+	layout := TextLayout{Font: font, Width: 60, Height: 100, Multiline: true}
+	lines := layout.Lines("aaaa bbbb cccc\r\ndd", 10)
+	if len(lines) != 3 || lines[0].Text != "aaaa bbbb" || lines[1].Text != "cccc" || lines[2].Text != "dd" {
+		t.Fatalf("unexpected lines %v", lines)
+	}
+	for _, line := range lines {
+		if line.X != 0 {
+			t.Fatalf("unexpected offset %v", line)
+		}
+	}
+
+	layout.Quadding = model.RightJustified
+	if lines := layout.Lines("aaaa bbbb cccc", 10); lines[1].X != 60-24 {
+		t.Fatalf("unexpected lines %v", lines)
+	}
+	layout.Quadding = model.Centered
+	if lines := layout.Lines("aaaa bbbb cccc", 10); lines[1].X != 18 {
+		t.Fatalf("unexpected lines %v", lines)
+	}
+
+	// automatic size: the lines must fit
+	size := layout.FitSize(strings.Repeat("word ", 30))
+	if n := len(layout.Lines(strings.Repeat("word ", 30), size)); size >= 12 || Fl(n)*layout.LineHeight(size) > layout.Height {
+		t.Fatalf("unexpected size %g", size)
+	}
+	if size := layout.FitSize(strings.Repeat("word ", 1000)); size != 4 {
+		t.Fatalf("unexpected size %g", size)
+	}
+
+	layout.Multiline = false
+	if size := layout.FitSize("aaaaa"); math.Abs(float64(size-20)) > 1e-3 { // the width is the limiting dimension
+		t.Fatalf("unexpected size %g", size)
+	}
+
+	// comb fields
+	layout.MaxLen = 6
+	cells := layout.Comb("abcdefgh", 10)
+	if len(cells) != 6 || cells[0].Text != "a" || cells[0].X != 2 || cells[5].X != 52 {
+		t.Fatalf("unexpected cells %v", cells)
+	}
+	layout.Quadding = model.RightJustified
+	if cells := layout.Comb("ab", 10); len(cells) != 2 || cells[0].X != 42 {
+		t.Fatalf("unexpected cells %v", cells)
+	}
+}
+
+func TestDecoderCMap(t *testing.T) {
+	font := model.FontType0{
+		BaseFont: "STSong-Light",
+		Encoding: model.CMapEncodingPredefined("UniGB-UTF16-H"),
+		DescendantFonts: model.CIDFontDictionary{
+			Subtype:       "CIDFontType0",
+			CIDSystemInfo: model.CIDSystemInfo{Registry: "Adobe", Ordering: "GB1"},
+			W:             []model.CIDWidth{model.CIDWidthRange{First: 1, Last: 95, Width: 500}},
+		},
+	}
+	dec := NewDecoder(&model.FontDict{Subtype: font})
+	glyphs := dec.Decode([]byte{0x4E, 0x2D, 0, 'a'}) // 中a
+	if len(glyphs) != 2 || string(glyphs[0].Text) != "中" || string(glyphs[1].Text) != "a" {
+		t.Fatalf("unexpected glyphs %v", glyphs)
+	}
+	if glyphs[0].Width != 1000 || glyphs[1].Width != 500 || glyphs[1].CID != 66 {
+		t.Fatalf("unexpected glyphs %v", glyphs)
+	}
+
+	w, err := Measure(&model.FontDict{Subtype: font}, "中a", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(float64(w-15)) > 1e-3 {
+		t.Fatalf("unexpected width %g", w)
+	}
+
+	font.Encoding = model.CMapEncodingPredefined("GBK-EUC-H")
+	if _, err = Measure(&model.FontDict{Subtype: font}, "a", 10); err == nil {
+		t.Fatal("expected error for unsupported CMap")
+	}
+}
@@ -0,0 +1,128 @@
+package fonts
+
+import (
+	"math"
+
+	"github.com/benoitkugler/pdf/fonts/simpleencodings"
+	"github.com/benoitkugler/pdf/fonts/standardfonts"
+	"github.com/benoitkugler/pdf/model"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// kerning provides the pair adjustments found in an embedded
+// TrueType or OpenType font file, either in the 'kern' table
+// or as GPOS pair adjustments, or in the metrics of the standard Type1 fonts.
+// The zero value (nil font and metrics) has no kerning.
+type kerning struct {
+	font *sfnt.Font
+
+	metrics *standardfonts.Metrics
+	glyphs  map[rune]string // glyph names, used with metrics
+}
+
+// loadStandardKerning uses the kerning pairs of the standard fonts,
+// for non embedded Type1 fonts. `charMap` and `enc` are used to
+// select the glyphs.
+func loadStandardKerning(ft model.FontType1, charMap map[rune]byte, enc simpleencodings.Encoding) kerning {
+	if ft.FontDescriptor.FontFile != nil {
+		return loadKerning(ft.FontDescriptor)
+	}
+	metrics, ok := standardfonts.Fonts[string(ft.BaseFont)]
+	if !ok || len(metrics.KernPairs) == 0 {
+		return kerning{}
+	}
+	glyphs := make(map[rune]string, len(charMap))
+	for r, code := range charMap {
+		if name := enc[code]; name != "" {
+			glyphs[r] = name
+		}
+	}
+	return kerning{metrics: &metrics, glyphs: glyphs}
+}
+
+// loadKerning parses the font file of `desc`.
+// Since kerning is optional, font files which are missing or
+// can't be parsed (such as Type1 fonts) are silently ignored.
+func loadKerning(desc model.FontDescriptor) kerning {
+	if desc.FontFile == nil {
+		return kerning{}
+	}
+	switch desc.FontFile.Subtype {
+	case "", "OpenType":
+	default: // Type1C or CIDFontType0C
+		return kerning{}
+	}
+	content, err := desc.FontFile.Decode()
+	if err != nil {
+		return kerning{}
+	}
+	ft, err := sfnt.Parse(content)
+	if err != nil {
+		return kerning{}
+	}
+	return kerning{font: ft}
+}
+
+// kern returns the adjustment between `c1` and `c2`, in thousandths of text space unit.
+// The glyphs are selected using the 'cmap' table of the font file,
+// or the encoding of the font for the standard fonts.
+func (k kerning) kern(c1, c2 rune) int {
+	if k.metrics != nil {
+		g1, g2 := k.glyphs[c1], k.glyphs[c2]
+		if g1 == "" || g2 == "" {
+			return 0
+		}
+		return k.metrics.Kern(g1, g2) // standard fonts use 1000 units per em
+	}
+	if k.font == nil {
+		return 0
+	}
+	g1, err := k.font.GlyphIndex(nil, c1)
+	if err != nil || g1 == 0 {
+		return 0
+	}
+	g2, err := k.font.GlyphIndex(nil, c2)
+	if err != nil || g2 == 0 {
+		return 0
+	}
+	// using a ppem equal to the units per em returns the value in font units
+	unitsPerEm := k.font.UnitsPerEm()
+	adjust, err := k.font.Kern(nil, g1, g2, fixed.Int26_6(unitsPerEm)<<6, font.HintingNone)
+	if err != nil { // in particular, ErrNotFound
+		return 0
+	}
+	return int(math.Round(float64(adjust) / 64 * 1000 / float64(unitsPerEm)))
+}
+
+// EncodeKern is the same as `Font.Encode`, but the text is splitted
+// when a kerning adjustment is needed between two characters,
+// so that the result may be used in a TJ operator.
+// If the font has no kerning, only one chunk is returned.
+func EncodeKern(ft Font, cs []rune) []TextSpaced {
+	var (
+		out   []TextSpaced
+		start int
+	)
+	for i := 1; i < len(cs); i++ {
+		if k := ft.Kern(cs[i-1], cs[i]); k != 0 {
+			out = append(out, TextSpaced{CharCodes: ft.Encode(cs[start:i]), SpaceSubtractedAfter: -k})
+			start = i
+		}
+	}
+	return append(out, TextSpaced{CharCodes: ft.Encode(cs[start:])})
+}
+
+// TextWidth returns the size, in points, needed to display `cs`
+// using the font size `size`, taking kerning into account.
+func TextWidth(ft Font, cs []rune, size Fl) Fl {
+	var out Fl
+	for i, c := range cs {
+		out += ft.GetWidth(c, size)
+		if i != 0 {
+			out += Fl(ft.Kern(cs[i-1], c)) * 0.001 * size
+		}
+	}
+	return out
+}
@@ -0,0 +1,247 @@
+package fonts
+
+import (
+	"strings"
+
+	"github.com/benoitkugler/pdf/model"
+)
+
+// the bounds used when computing an automatic font size
+const (
+	minAutoFontSize          = 4
+	maxAutoFontSizeMultiline = 12
+)
+
+// TextLayout splits and positions text in a rectangular area,
+// as needed to build the appearance streams of variable text
+// fields (see 12.7.3.3 - Variable Text).
+// Horizontal positions are relative to the left side of the area.
+type TextLayout struct {
+	Font Font
+
+	// Dimensions of the area
+	Width, Height Fl
+
+	Quadding model.Quadding
+
+	// If true, the text is broken into lines fitting `Width`,
+	// in addition to its hard line breaks.
+	Multiline bool
+
+	// MaxLen is the number of cells of comb fields (see `Comb`).
+	MaxLen int
+}
+
+// Line is one line of text, with its horizontal offset.
+type Line struct {
+	Text string
+	X    Fl
+}
+
+// LineHeight returns the distance between two lines,
+// for the font size `size`, deduced from the font bounding box.
+func (l TextLayout) LineHeight(size Fl) Fl {
+	bbox := l.Font.Desc().FontBBox
+	factor := (bbox.Ury - bbox.Lly) / 1000
+	if factor <= 0 { // missing bbox
+		factor = 1
+	}
+	return size * factor
+}
+
+// FitSize returns the font size to use for `text` when the automatic
+// size is requested (a zero size in a DA string, as in "/Helv 0 Tf").
+// Single line text is scaled to fill the area; multiline text is shrunk
+// from 12 points until all the lines fit in the area.
+// In both cases, the returned size is at least 4.
+func (l TextLayout) FitSize(text string) Fl {
+	if l.Multiline {
+		breaks := hardBreaks(text)
+		size := l.Height / Fl(len(breaks)) / l.LineHeight(1)
+		if size > maxAutoFontSizeMultiline {
+			size = maxAutoFontSizeMultiline
+		}
+		step := (size - minAutoFontSize) / 10
+		if step < 0.2 {
+			step = 0.2
+		}
+		for ; size > minAutoFontSize; size -= step {
+			lines := breakLines(breaks, l.Font, size, l.Width)
+			if Fl(len(lines))*l.LineHeight(size) <= l.Height {
+				return size
+			}
+		}
+		return minAutoFontSize
+	}
+
+	size := l.Height / l.LineHeight(1)
+	if wd := TextWidth(l.Font, []rune(text), 1); wd != 0 && l.Width/wd < size {
+		size = l.Width / wd
+	}
+	if size < minAutoFontSize {
+		size = minAutoFontSize
+	}
+	return size
+}
+
+// Lines splits `text` on its hard line breaks (and, for multiline layouts,
+// wraps it to fit the width), and positions the lines according
+// to the quadding, for the font size `size`.
+func (l TextLayout) Lines(text string, size Fl) []Line {
+	lines := []string{text}
+	if l.Multiline {
+		lines = breakLines(hardBreaks(text), l.Font, size, l.Width)
+	}
+	out := make([]Line, len(lines))
+	for i, line := range lines {
+		if l.Multiline && l.Quadding == model.Centered {
+			line = strings.TrimSpace(line)
+		}
+		out[i] = Line{Text: line}
+		switch l.Quadding {
+		case model.RightJustified:
+			out[i].X = l.Width - TextWidth(l.Font, []rune(line), size)
+		case model.Centered:
+			out[i].X = (l.Width - TextWidth(l.Font, []rune(line), size)) / 2
+		}
+	}
+	return out
+}
+
+// Comb divides the width in `MaxLen` cells and returns one line
+// for each character of `text`, centered in its cell.
+// The cells used are chosen according to the quadding, and
+// the characters after `MaxLen` are ignored.
+// An empty slice is returned if `MaxLen` is not positive.
+func (l TextLayout) Comb(text string, size Fl) []Line {
+	if l.MaxLen <= 0 {
+		return nil
+	}
+	rs := []rune(text)
+	if len(rs) > l.MaxLen {
+		rs = rs[:l.MaxLen]
+	}
+	var position Fl // first cell
+	switch l.Quadding {
+	case model.RightJustified:
+		position = Fl(l.MaxLen - len(rs))
+	case model.Centered:
+		position = Fl(l.MaxLen-len(rs)) / 2
+	}
+	step := l.Width / Fl(l.MaxLen)
+	center := step/2 + position*step
+	out := make([]Line, len(rs))
+	for i, c := range rs {
+		out[i] = Line{Text: string(c), X: center - l.Font.GetWidth(c, size)/2}
+		center += step
+	}
+	return out
+}
+
+// hardBreaks splits `text` on \r, \n and \r\n
+func hardBreaks(text string) (arr []string) {
+	cs := []rune(text)
+	var buf strings.Builder
+	for k := 0; k < len(cs); k++ {
+		c := cs[k]
+		if c == '\r' {
+			if k+1 < len(cs) && cs[k+1] == '\n' {
+				k++
+			}
+			arr = append(arr, buf.String())
+			buf.Reset()
+		} else if c == '\n' {
+			arr = append(arr, buf.String())
+			buf.Reset()
+		} else {
+			buf.WriteRune(c)
+		}
+	}
+	arr = append(arr, buf.String())
+	return arr
+}
+
+// breakLines wraps each paragraph in `breaks` on spaces (or inside
+// words when needed), so that the lines fit in `width`
+func breakLines(breaks []string, font Font, fontSize, width Fl) (lines []string) {
+	var buf []rune
+	for _, break_ := range breaks {
+		buf = buf[:0]
+		var w Fl
+		cs := []rune(break_)
+		// 0 inline first, 1 inline, 2 spaces
+		state := 0
+		lastspace := -1
+		refk := 0
+		for k := 0; k < len(cs); k++ {
+			c := cs[k]
+			switch state {
+			case 0:
+				w += font.GetWidth(c, fontSize)
+				if len(buf) != 0 {
+					w += Fl(font.Kern(buf[len(buf)-1], c)) * 0.001 * fontSize
+				}
+				buf = append(buf, c)
+				if w > width {
+					w = 0
+					if len(buf) > 1 {
+						k--
+						buf = buf[:len(buf)-1]
+					}
+					lines = append(lines, string(buf))
+					buf = buf[:0]
+					refk = k
+					if c == ' ' {
+						state = 2
+					} else {
+						state = 1
+					}
+				} else {
+					if c != ' ' {
+						state = 1
+					}
+				}
+			case 1:
+				w += font.GetWidth(c, fontSize)
+				if len(buf) != 0 {
+					w += Fl(font.Kern(buf[len(buf)-1], c)) * 0.001 * fontSize
+				}
+				buf = append(buf, c)
+				if c == ' ' {
+					lastspace = k
+				}
+				if w > width {
+					w = 0
+					if lastspace >= 0 {
+						k = lastspace
+						buf = buf[:lastspace-refk]
+						lines = append(lines, strings.TrimRight(string(buf), " "))
+						buf = buf[:0]
+						refk = k
+						lastspace = -1
+						state = 2
+					} else {
+						if len(buf) > 1 {
+							k--
+							buf = buf[:len(buf)-1]
+						}
+						lines = append(lines, string(buf))
+						buf = buf[:0]
+						refk = k
+						if c == ' ' {
+							state = 2
+						}
+					}
+				}
+			case 2:
+				if c != ' ' {
+					w = 0
+					k--
+					state = 1
+				}
+			}
+		}
+		lines = append(lines, strings.TrimRight(string(buf), " "))
+	}
+	return lines
+}
@@ -0,0 +1,69 @@
+package fonts
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/benoitkugler/pdf/fonts/cmaps"
+	"github.com/benoitkugler/pdf/model"
+)
+
+// Measure returns the width, in points, needed to display `s`
+// with `font` at size `size`.
+// The widths are read from the Widths array of simple fonts,
+// the W array of the descendant font of Type0 fonts, or
+// from the metrics of the standard 14 fonts when no widths are given.
+// The text is encoded using the font ToUnicode entry and encoding:
+// an error is returned if a character is not supported by the font.
+// Kerning is not taken into account.
+func Measure(font *model.FontDict, s string, size Fl) (width Fl, err error) {
+	if font == nil || font.Subtype == nil {
+		return 0, errors.New("missing font subtype")
+	}
+	dec, err := newDecoder(font)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ToUnicode entry: %s", err)
+	}
+	codes := dec.codesFromUnicode()
+	for _, r := range s {
+		code, ok := codes[r]
+		if !ok {
+			return 0, fmt.Errorf("unsupported character %q in font %s", r, font.Subtype.FontName())
+		}
+		if dec.cmap == nil {
+			width += dec.width(byte(code))
+		} else {
+			width += dec.cidWidth(code)
+		}
+	}
+	return width * 0.001 * size, nil
+}
+
+// codesFromUnicode reverses the Unicode mapping of the decoder,
+// returning character codes for simple fonts and CIDs for Type0 fonts.
+// The smallest value is chosen when several glyphs map to the same rune,
+// and ligatures are ignored.
+func (f Decoder) codesFromUnicode() map[rune]model.CID {
+	toUnicode := f.toUnicode
+	if f.cmap != nil && toUnicode == nil {
+		toUnicode = f.cidToUnicode
+	}
+	out := make(map[rune]model.CID, len(toUnicode))
+	for code, rs := range toUnicode {
+		if len(rs) != 1 {
+			continue
+		}
+		if f.cmap != nil && f.toUnicode != nil { // convert the character code to CID
+			var ok bool
+			code, ok = f.cmap.Lookup(cmaps.CharCode(code))
+			if !ok {
+				continue
+			}
+		}
+		if other, has := out[rs[0]]; has && other < code {
+			continue
+		}
+		out[rs[0]] = code
+	}
+	return out
+}
@@ -0,0 +1,249 @@
+package fonts
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/benoitkugler/pdf/fonts/glyphsnames"
+	ps "github.com/benoitkugler/pdf/fonts/psinterpreter"
+	"github.com/benoitkugler/pdf/fonts/simpleencodings"
+	"github.com/benoitkugler/pdf/fonts/type1"
+	type1c "github.com/benoitkugler/pdf/fonts/type1C"
+	"github.com/benoitkugler/pdf/model"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// SegmentOp is the kind of a path segment.
+type SegmentOp uint8
+
+const (
+	SegmentMoveTo SegmentOp = iota
+	SegmentLineTo
+	SegmentQuadTo // quadratic Bézier curve
+	SegmentCubeTo // cubic Bézier curve
+)
+
+// Point is a point in glyph space, expressed
+// in thousandths of text space unit, with the y axis going up.
+type Point struct {
+	X, Y Fl
+}
+
+// Segment is one element of a glyph outline.
+// Only the first point of Args is used for SegmentMoveTo and SegmentLineTo,
+// the first two for SegmentQuadTo (the control point and the end point),
+// and all of them for SegmentCubeTo.
+type Segment struct {
+	Op   SegmentOp
+	Args [3]Point
+}
+
+// Outlines provides the glyph outlines of a font program
+// embedded in a PDF file.
+type Outlines struct {
+	// only one is not nil
+	sfnt  *sfnt.Font
+	cff   *type1c.Font
+	type1 *type1.Font
+
+	encoding *simpleencodings.Encoding // for simple fonts
+	cidToGID []byte                    // for CIDFontType2 fonts, nil for the identity
+
+	buffer    sfnt.Buffer
+	sfntNames map[string]sfnt.GlyphIndex // lazily built from the 'post' table
+}
+
+// LoadOutlines parses the font program embedded in `font`.
+// TrueType and OpenType font files, Type1 font files and CFF font files
+// (Type1C and CIDFontType0C) are supported.
+// An error is returned for Type3 fonts and for fonts whose program
+// is not embedded.
+func LoadOutlines(font *model.FontDict) (*Outlines, error) {
+	if font == nil {
+		return nil, errors.New("missing font")
+	}
+	var (
+		out   Outlines
+		desc  model.FontDescriptor
+		isTTF bool // for font files without subtype
+	)
+	switch ft := font.Subtype.(type) {
+	case model.FontType1:
+		desc = ft.FontDescriptor
+	case model.FontTrueType:
+		desc, isTTF = ft.FontDescriptor, true
+	case model.FontType0:
+		desc, isTTF = ft.DescendantFonts.FontDescriptor, ft.DescendantFonts.Subtype == "CIDFontType2"
+		if m, ok := ft.DescendantFonts.CIDToGIDMap.(model.CIDToGIDMapStream); ok {
+			var err error
+			out.cidToGID, err = m.Decode()
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDToGIDMap: %s", err)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("glyph outlines are not supported for font type %T", ft)
+	}
+	if simple, ok := font.Subtype.(model.FontSimple); ok {
+		enc := ResolveSimpleEncoding(simple)
+		out.encoding = &enc
+	}
+
+	if desc.FontFile == nil {
+		return nil, errors.New("missing embedded font file")
+	}
+	content, err := desc.FontFile.Decode()
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode embedded font file: %s", err)
+	}
+	switch subtype := desc.FontFile.Subtype; {
+	case subtype == "Type1C" || subtype == "CIDFontType0C":
+		out.cff, err = type1c.Parse(bytes.NewReader(content))
+	case subtype == "OpenType" || subtype == "" && isTTF:
+		out.sfnt, err = sfnt.Parse(content)
+	case subtype == "":
+		out.type1, err = type1.Parse(bytes.NewReader(content))
+	default:
+		return nil, fmt.Errorf("unsupported font file subtype %s", subtype)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid embedded font file: %s", err)
+	}
+	return &out, nil
+}
+
+// GlyphByName returns the outline of the glyph `name`, which is
+// relevant for simple fonts.
+// For TrueType fonts, the glyph is selected using the 'post' table
+// or, as a fallback, the 'cmap' table with the Unicode value of the name.
+func (o *Outlines) GlyphByName(name string) ([]Segment, error) {
+	switch {
+	case o.type1 != nil:
+		segments, err := o.type1.LoadGlyph(name)
+		return fromFontUnits(segments), err
+	case o.cff != nil:
+		gid, ok := o.cff.GlyphIndexByName(name)
+		if !ok {
+			return nil, fmt.Errorf("missing glyph %s", name)
+		}
+		segments, err := o.cff.LoadGlyph(gid)
+		return fromFontUnits(segments), err
+	default:
+		gid, ok := o.sfntGlyphByName(name)
+		if !ok {
+			return nil, fmt.Errorf("missing glyph %s", name)
+		}
+		return o.loadSfntGlyph(gid)
+	}
+}
+
+// GlyphByCID returns the outline of the glyph selected by `cid`, which
+// is relevant for the CIDFonts used by Type0 fonts.
+// For TrueType fonts, the CIDToGIDMap of the font is used, and for
+// CFF fonts, the charset of the font program.
+// An error is returned for Type1 font files.
+func (o *Outlines) GlyphByCID(cid model.CID) ([]Segment, error) {
+	switch {
+	case o.type1 != nil:
+		return nil, errors.New("Type1 font files do not support CIDs")
+	case o.cff != nil:
+		gid, ok := o.cff.GlyphIndexByCID(uint16(cid))
+		if !ok {
+			return nil, fmt.Errorf("missing glyph for CID %d", cid)
+		}
+		segments, err := o.cff.LoadGlyph(gid)
+		return fromFontUnits(segments), err
+	default:
+		gid := sfnt.GlyphIndex(cid)
+		if o.cidToGID != nil {
+			if 2*int(cid)+1 >= len(o.cidToGID) {
+				return nil, fmt.Errorf("missing glyph for CID %d", cid)
+			}
+			gid = sfnt.GlyphIndex(o.cidToGID[2*cid])<<8 | sfnt.GlyphIndex(o.cidToGID[2*cid+1])
+		}
+		return o.loadSfntGlyph(gid)
+	}
+}
+
+// Glyph returns the outline of `glyph`, as returned by a Decoder
+// built from the same font.
+// For simple fonts, the glyph name is resolved from the character code
+// (see ResolveSimpleEncoding); TrueType fonts also try the 'cmap'
+// table of the font with the character code, as used by symbolic fonts.
+func (o *Outlines) Glyph(glyph Glyph) ([]Segment, error) {
+	if o.encoding == nil { // CIDFont
+		return o.GlyphByCID(glyph.CID)
+	}
+	if len(glyph.Code) == 0 {
+		return nil, errors.New("missing character code")
+	}
+	code := glyph.Code[0]
+	segments, err := o.GlyphByName(o.encoding[code])
+	if err == nil || o.sfnt == nil {
+		return segments, err
+	}
+	for _, r := range [2]rune{0xF000 + rune(code), rune(code)} {
+		if gid, err := o.sfnt.GlyphIndex(&o.buffer, r); err == nil && gid != 0 {
+			return o.loadSfntGlyph(gid)
+		}
+	}
+	return nil, fmt.Errorf("missing glyph for character code %d", code)
+}
+
+// sfntGlyphByName uses the 'post' table, then the Unicode 'cmap' table
+func (o *Outlines) sfntGlyphByName(name string) (sfnt.GlyphIndex, bool) {
+	if name == "" {
+		return 0, false
+	}
+	if o.sfntNames == nil {
+		o.sfntNames = make(map[string]sfnt.GlyphIndex)
+		for gid := 0; gid < o.sfnt.NumGlyphs(); gid++ {
+			glyphName, err := o.sfnt.GlyphName(&o.buffer, sfnt.GlyphIndex(gid))
+			if err != nil || glyphName == "" { // no 'post' table
+				break
+			}
+			o.sfntNames[glyphName] = sfnt.GlyphIndex(gid)
+		}
+	}
+	if gid, ok := o.sfntNames[name]; ok {
+		return gid, true
+	}
+	r, ok := glyphsnames.GlyphToRune(name)
+	if !ok {
+		return 0, false
+	}
+	gid, err := o.sfnt.GlyphIndex(&o.buffer, r)
+	return gid, err == nil && gid != 0
+}
+
+// loadSfntGlyph returns the outline of `gid`, scaled to 1000 units per em
+func (o *Outlines) loadSfntGlyph(gid sfnt.GlyphIndex) ([]Segment, error) {
+	// with 1000 pixels per em, the segments are expressed in thousandths of em
+	segments, err := o.sfnt.LoadGlyph(&o.buffer, gid, fixed.I(1000), nil)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Segment, len(segments))
+	for i, seg := range segments {
+		out[i].Op = SegmentOp(seg.Op) // same values
+		for j, pt := range seg.Args {
+			out[i].Args[j] = Point{X: Fl(pt.X) / 64, Y: -Fl(pt.Y) / 64} // the sfnt Y axis goes down
+		}
+	}
+	return out, nil
+}
+
+// fromFontUnits converts the outlines of Type1 and CFF fonts,
+// assuming the usual font matrix of 1000 units per em.
+func fromFontUnits(segments []ps.Segment) []Segment {
+	out := make([]Segment, len(segments))
+	for i, seg := range segments {
+		out[i].Op = SegmentOp(seg.Op) // same values
+		for j, pt := range seg.Args {
+			out[i].Args[j] = Point{X: Fl(pt.X), Y: Fl(pt.Y)}
+		}
+	}
+	return out
+}
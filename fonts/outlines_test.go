@@ -0,0 +1,135 @@
+package fonts
+
+import (
+	"os"
+	"testing"
+
+	"github.com/benoitkugler/pdf/model"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// bounds returns the bounding box of the end points of the segments
+func bounds(segments []Segment) model.Rectangle {
+	var box model.Rectangle
+	for i, seg := range segments {
+		pt := seg.Args[0]
+		switch seg.Op {
+		case SegmentQuadTo:
+			pt = seg.Args[1]
+		case SegmentCubeTo:
+			pt = seg.Args[2]
+		}
+		if i == 0 {
+			box = model.Rectangle{Llx: pt.X, Lly: pt.Y, Urx: pt.X, Ury: pt.Y}
+		}
+		box = box.Union(model.Rectangle{Llx: pt.X, Lly: pt.Y, Urx: pt.X, Ury: pt.Y})
+	}
+	return box
+}
+
+func fontFile(t *testing.T, filename string, subtype model.Name) *model.FontFile {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &model.FontFile{Stream: model.Stream{Content: b}, Subtype: subtype}
+}
+
+func TestOutlines(t *testing.T) {
+	for _, test := range []struct {
+		font *model.FontDict
+		name string
+	}{
+		{&model.FontDict{Subtype: model.FontTrueType{
+			FontDescriptor: model.FontDescriptor{FontFile: &model.FontFile{Stream: model.Stream{Content: goregular.TTF}}},
+		}}, "TrueType"},
+		{&model.FontDict{Subtype: model.FontType1{
+			FontDescriptor: model.FontDescriptor{FontFile: fontFile(t, "test/CalligrapherRegular.pfb", "")},
+		}}, "Type1"},
+		{&model.FontDict{Subtype: model.FontType1{
+			FontDescriptor: model.FontDescriptor{FontFile: fontFile(t, "test/AAAPKB+SourceSansPro-Bold.cff", "Type1C")},
+		}}, "Type1C"},
+	} {
+		outlines, err := LoadOutlines(test.font)
+		if err != nil {
+			t.Fatal(err)
+		}
+		byName, err := outlines.GlyphByName("A")
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the outline is in thousandths of text space unit, with y going up
+		if box := bounds(byName); box.Lly < -50 || box.Ury < 500 || box.Ury > 900 || box.Urx > 1000 {
+			t.Fatalf("%s: unexpected bounding box %v", test.name, box)
+		}
+
+		byCode, err := outlines.Glyph(Glyph{Code: []byte{'A'}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(byCode) != len(byName) {
+			t.Fatalf("%s: inconsistent outlines", test.name)
+		}
+
+		if _, err = outlines.GlyphByName("<missing>"); err == nil {
+			t.Fatalf("%s: expected error for missing glyph", test.name)
+		}
+	}
+}
+
+func TestOutlinesCID(t *testing.T) {
+	ttf := &model.FontDict{Subtype: model.FontType0{DescendantFonts: model.CIDFontDictionary{
+		Subtype:        "CIDFontType2",
+		FontDescriptor: model.FontDescriptor{FontFile: &model.FontFile{Stream: model.Stream{Content: goregular.TTF}}},
+	}}}
+	outlines, err := LoadOutlines(ttf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName, _ := outlines.GlyphByName("A")
+	// remap the glyph of 'A' to the CID 1
+	gid, _ := outlines.sfntGlyphByName("A")
+	cidToGID := model.CIDToGIDMapStream{Stream: model.Stream{Content: []byte{0, 0, byte(gid >> 8), byte(gid)}}}
+	ft := ttf.Subtype.(model.FontType0)
+	ft.DescendantFonts.CIDToGIDMap = cidToGID
+	outlines, err = LoadOutlines(&model.FontDict{Subtype: ft})
+	if err != nil {
+		t.Fatal(err)
+	}
+	byCID, err := outlines.GlyphByCID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byCID) == 0 || len(byCID) != len(byName) {
+		t.Fatalf("unexpected outline for CID 1: %v", byCID)
+	}
+	if _, err = outlines.GlyphByCID(2); err == nil {
+		t.Fatal("expected error for CID outside the CIDToGIDMap")
+	}
+
+	cff := &model.FontDict{Subtype: model.FontType0{DescendantFonts: model.CIDFontDictionary{
+		Subtype:        "CIDFontType0",
+		FontDescriptor: model.FontDescriptor{FontFile: fontFile(t, "test/AdobeMingStd-Light-Identity-H.cff", "CIDFontType0C")},
+	}}}
+	outlines, err = LoadOutlines(cff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byCID, err = outlines.GlyphByCID(401)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byCID) == 0 {
+		t.Fatal("expected non empty outline")
+	}
+	if byCode, _ := outlines.Glyph(Glyph{CID: 401}); len(byCode) != len(byCID) {
+		t.Fatal("inconsistent outlines")
+	}
+
+	if _, err := LoadOutlines(&model.FontDict{Subtype: model.FontType3{}}); err == nil {
+		t.Fatal("expected error for Type3 fonts")
+	}
+	if _, err := LoadOutlines(&model.FontDict{Subtype: model.FontType1{}}); err == nil {
+		t.Fatal("expected error for non embedded fonts")
+	}
+}
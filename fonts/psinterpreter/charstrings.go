@@ -0,0 +1,495 @@
+package psinterpreter
+
+import (
+	"errors"
+	"fmt"
+)
+
+// code is adapted from golang.org/x/image/font/sfnt
+
+var errInvalidCharstring = errors.New("invalid charstring")
+
+// 5177.Type2.pdf Appendix B "Type 2 Charstring Implementation Limits"
+// says that "number of stem hints (H/V total)" is limited to 96.
+const maxHintBits = 96
+
+// Point is a point in font units.
+type Point struct {
+	X, Y int32
+}
+
+// Move translates the point by (dx, dy).
+func (p *Point) Move(dx, dy int32) {
+	p.X += dx
+	p.Y += dy
+}
+
+// SegmentOp is the kind of a path segment.
+type SegmentOp uint8
+
+const (
+	SegmentOpMoveTo SegmentOp = iota
+	SegmentOpLineTo
+	SegmentOpQuadTo
+	SegmentOpCubeTo
+)
+
+// Segment is one element of a glyph outline.
+// Only the first point of Args is used for SegmentOpMoveTo and SegmentOpLineTo,
+// the first two for SegmentOpQuadTo (the control point and the end point),
+// and all of them for SegmentOpCubeTo.
+type Segment struct {
+	Op   SegmentOp
+	Args [3]Point
+}
+
+// CharstringReader provides implementation
+// of the path operators found in Type1 and Type2 charstrings.
+// The coordinates are expressed in font units, with the y axis going up.
+type CharstringReader struct {
+	// Segments is the outline built by the charstring operators.
+	Segments []Segment
+
+	// CurrentPoint is the current point of the path.
+	CurrentPoint Point
+
+	firstPoint Point // of the current subpath
+	isPathOpen bool
+
+	seenWidth bool
+	hintBits  int32
+}
+
+// ClosePath closes the current subpath, if any.
+func (out *CharstringReader) ClosePath() {
+	if out.isPathOpen && out.CurrentPoint != out.firstPoint {
+		out.Segments = append(out.Segments, Segment{
+			Op:   SegmentOpLineTo,
+			Args: [3]Point{out.firstPoint},
+		})
+	}
+	out.isPathOpen = false
+}
+
+// MoveTo closes the current subpath and starts a new one,
+// at the current point translated by (dx, dy).
+func (out *CharstringReader) MoveTo(dx, dy int32) {
+	out.ClosePath()
+	out.CurrentPoint.Move(dx, dy)
+	out.Segments = append(out.Segments, Segment{
+		Op:   SegmentOpMoveTo,
+		Args: [3]Point{out.CurrentPoint},
+	})
+	out.firstPoint = out.CurrentPoint
+	out.isPathOpen = true
+}
+
+// LineTo adds a line from the current point to the current
+// point translated by (dx, dy).
+func (out *CharstringReader) LineTo(dx, dy int32) {
+	out.ensureOpen()
+	out.CurrentPoint.Move(dx, dy)
+	out.Segments = append(out.Segments, Segment{
+		Op:   SegmentOpLineTo,
+		Args: [3]Point{out.CurrentPoint},
+	})
+}
+
+// CubeTo adds a cubic Bézier curve, whose points are given
+// relatively to the previous one.
+func (out *CharstringReader) CubeTo(dxa, dya, dxb, dyb, dxc, dyc int32) {
+	out.ensureOpen()
+	var seg Segment
+	seg.Op = SegmentOpCubeTo
+	out.CurrentPoint.Move(dxa, dya)
+	seg.Args[0] = out.CurrentPoint
+	out.CurrentPoint.Move(dxb, dyb)
+	seg.Args[1] = out.CurrentPoint
+	out.CurrentPoint.Move(dxc, dyc)
+	seg.Args[2] = out.CurrentPoint
+	out.Segments = append(out.Segments, seg)
+}
+
+// ensureOpen starts a subpath at the current point,
+// for charstrings drawing before any moveto.
+func (out *CharstringReader) ensureOpen() {
+	if out.isPathOpen {
+		return
+	}
+	out.Segments = append(out.Segments, Segment{
+		Op:   SegmentOpMoveTo,
+		Args: [3]Point{out.CurrentPoint},
+	})
+	out.firstPoint = out.CurrentPoint
+	out.isPathOpen = true
+}
+
+// The following methods implement the Type 2 Charstring operators,
+// defined by 5177.Type2.pdf Appendix A "Type 2 Charstring Command Codes".
+// They don't clear the argument stack.
+
+// ReadWidth removes the optional width adjustment, which
+// is on the bottom of the arg stack. nArgs is the expected number of arguments on the
+// stack. A negative nArgs means a multiple of 2.
+//
+// 5177.Type2.pdf page 16 Note 4 says: "The first stack-clearing operator,
+// which must be one of hstem, hstemhm, vstem, vstemhm, cntrmask, hintmask,
+// hmoveto, vmoveto, rmoveto, or endchar, takes an additional argument — the
+// width... which may be expressed as zero or one numeric argument."
+func (out *CharstringReader) ReadWidth(state *Machine, nArgs int32) {
+	if out.seenWidth {
+		return
+	}
+	out.seenWidth = true
+	if nArgs >= 0 {
+		if state.ArgStack.Top != nArgs+1 {
+			return
+		}
+	} else if state.ArgStack.Top&1 == 0 {
+		return
+	}
+	// the width is not used in PDF files, which provide the widths
+	// in the font dictionary
+	copy(state.ArgStack.Vals[:state.ArgStack.Top-1], state.ArgStack.Vals[1:state.ArgStack.Top])
+	state.ArgStack.Top--
+}
+
+// Stem implements the hstem, vstem, hstemhm and vstemhm operators.
+// The hints are ignored, but their number is recorded,
+// as required by the hintmask and cntrmask operators.
+func (out *CharstringReader) Stem(state *Machine) error {
+	out.ReadWidth(state, -1)
+	if state.ArgStack.Top%2 != 0 {
+		return errInvalidCharstring
+	}
+	out.hintBits += state.ArgStack.Top / 2
+	if out.hintBits > maxHintBits {
+		return errors.New("unsupported number of hints")
+	}
+	return nil
+}
+
+// HintMask implements the hintmask and cntrmask operators,
+// skipping the mask bytes.
+func (out *CharstringReader) HintMask(state *Machine) error {
+	// 5176.CFF.pdf section 4.3 "Hint Operators" says that "If hstem and vstem
+	// hints are both declared at the beginning of a charstring, and this
+	// sequence is followed directly by the hintmask or cntrmask operators, the
+	// vstem hint operator need not be included."
+	if state.ArgStack.Top != 0 {
+		if err := out.Stem(state); err != nil {
+			return err
+		}
+	} else {
+		out.seenWidth = true
+	}
+
+	hintBytes := (out.hintBits + 7) / 8
+	if len(state.instructions) < int(hintBytes) {
+		return errInvalidCharstring
+	}
+	state.instructions = state.instructions[hintBytes:]
+	return nil
+}
+
+// Hmoveto implements the hmoveto operator.
+func (out *CharstringReader) Hmoveto(state *Machine) error {
+	out.ReadWidth(state, 1)
+	if state.ArgStack.Top != 1 {
+		return errInvalidCharstring
+	}
+	out.MoveTo(state.ArgStack.Vals[0], 0)
+	return nil
+}
+
+// Vmoveto implements the vmoveto operator.
+func (out *CharstringReader) Vmoveto(state *Machine) error {
+	out.ReadWidth(state, 1)
+	if state.ArgStack.Top != 1 {
+		return errInvalidCharstring
+	}
+	out.MoveTo(0, state.ArgStack.Vals[0])
+	return nil
+}
+
+// Rmoveto implements the rmoveto operator.
+func (out *CharstringReader) Rmoveto(state *Machine) error {
+	out.ReadWidth(state, 2)
+	if state.ArgStack.Top != 2 {
+		return errInvalidCharstring
+	}
+	out.MoveTo(state.ArgStack.Vals[0], state.ArgStack.Vals[1])
+	return nil
+}
+
+// Hlineto implements the hlineto operator.
+func (out *CharstringReader) Hlineto(state *Machine) error { return out.lineto(state, false) }
+
+// Vlineto implements the vlineto operator.
+func (out *CharstringReader) Vlineto(state *Machine) error { return out.lineto(state, true) }
+
+func (out *CharstringReader) lineto(state *Machine, vertical bool) error {
+	if !out.seenWidth || state.ArgStack.Top < 1 {
+		return errInvalidCharstring
+	}
+	for i := int32(0); i < state.ArgStack.Top; i, vertical = i+1, !vertical {
+		dx, dy := state.ArgStack.Vals[i], int32(0)
+		if vertical {
+			dx, dy = dy, dx
+		}
+		out.LineTo(dx, dy)
+	}
+	return nil
+}
+
+// Rlineto implements the rlineto operator.
+func (out *CharstringReader) Rlineto(state *Machine) error {
+	if !out.seenWidth || state.ArgStack.Top < 2 || state.ArgStack.Top%2 != 0 {
+		return errInvalidCharstring
+	}
+	for i := int32(0); i < state.ArgStack.Top; i += 2 {
+		out.LineTo(state.ArgStack.Vals[i], state.ArgStack.Vals[i+1])
+	}
+	return nil
+}
+
+// As per 5177.Type2.pdf section 4.1 "Path Construction Operators",
+//
+// rcurveline is:
+//	- {dxa dya dxb dyb dxc dyc}+ dxd dyd
+//
+// rlinecurve is:
+//	- {dxa dya}+ dxb dyb dxc dyc dxd dyd
+
+// Rcurveline implements the rcurveline operator.
+func (out *CharstringReader) Rcurveline(state *Machine) error {
+	args := &state.ArgStack
+	if !out.seenWidth || args.Top < 8 || args.Top%6 != 2 {
+		return errInvalidCharstring
+	}
+	i := int32(0)
+	for iMax := args.Top - 2; i < iMax; i += 6 {
+		out.CubeTo(args.Vals[i], args.Vals[i+1], args.Vals[i+2], args.Vals[i+3], args.Vals[i+4], args.Vals[i+5])
+	}
+	out.LineTo(args.Vals[i], args.Vals[i+1])
+	return nil
+}
+
+// Rlinecurve implements the rlinecurve operator.
+func (out *CharstringReader) Rlinecurve(state *Machine) error {
+	args := &state.ArgStack
+	if !out.seenWidth || args.Top < 8 || args.Top%2 != 0 {
+		return errInvalidCharstring
+	}
+	i := int32(0)
+	for iMax := args.Top - 6; i < iMax; i += 2 {
+		out.LineTo(args.Vals[i], args.Vals[i+1])
+	}
+	out.CubeTo(args.Vals[i], args.Vals[i+1], args.Vals[i+2], args.Vals[i+3], args.Vals[i+4], args.Vals[i+5])
+	return nil
+}
+
+// Rrcurveto implements the rrcurveto operator.
+func (out *CharstringReader) Rrcurveto(state *Machine) error {
+	args := &state.ArgStack
+	if !out.seenWidth || args.Top < 6 || args.Top%6 != 0 {
+		return errInvalidCharstring
+	}
+	for i := int32(0); i != args.Top; i += 6 {
+		out.CubeTo(args.Vals[i], args.Vals[i+1], args.Vals[i+2], args.Vals[i+3], args.Vals[i+4], args.Vals[i+5])
+	}
+	return nil
+}
+
+// As per 5177.Type2.pdf section 4.1 "Path Construction Operators",
+//
+// hhcurveto is:
+//	- dy1 {dxa dxb dyb dxc}+
+//
+// vvcurveto is:
+//	- dx1 {dya dxb dyb dyc}+
+//
+// hvcurveto is one of:
+//	- dx1 dx2 dy2 dy3 {dya dxb dyb dxc dxd dxe dye dyf}* dxf?
+//	- {dxa dxb dyb dyc dyd dxe dye dxf}+ dyf?
+//
+// vhcurveto is one of:
+//	- dy1 dx2 dy2 dx3 {dxa dxb dyb dyc dyd dxe dye dxf}* dyf?
+//	- {dya dxb dyb dxc dxd dxe dye dyf}+ dxf?
+
+// Hhcurveto implements the hhcurveto operator.
+func (out *CharstringReader) Hhcurveto(state *Machine) error { return out.curveto(state, false, false) }
+
+// Vvcurveto implements the vvcurveto operator.
+func (out *CharstringReader) Vvcurveto(state *Machine) error { return out.curveto(state, false, true) }
+
+// Hvcurveto implements the hvcurveto operator.
+func (out *CharstringReader) Hvcurveto(state *Machine) error { return out.curveto(state, true, false) }
+
+// Vhcurveto implements the vhcurveto operator.
+func (out *CharstringReader) Vhcurveto(state *Machine) error { return out.curveto(state, true, true) }
+
+// curveto implements the hh / vv / hv / vh xxcurveto operators. N relative
+// cubic curve requires 6*N control points, but only 4*N+0 or 4*N+1 are used
+// here: all (or all but one) of the piecewise cubic curve's tangents are
+// implicitly horizontal or vertical.
+//
+// swap is whether that implicit horizontal / vertical constraint swaps as you
+// move along the piecewise cubic curve. If swap is false, the constraints are
+// either all horizontal or all vertical. If swap is true, it alternates.
+//
+// vertical is whether the first implicit constraint is vertical.
+func (out *CharstringReader) curveto(state *Machine, swap, vertical bool) error {
+	args := &state.ArgStack
+	if !out.seenWidth || args.Top < 4 {
+		return errInvalidCharstring
+	}
+
+	i := int32(0)
+	switch args.Top & 3 {
+	case 0:
+		// No-op.
+	case 1:
+		if swap {
+			break
+		}
+		i = 1
+		if vertical {
+			out.CurrentPoint.X += args.Vals[0]
+		} else {
+			out.CurrentPoint.Y += args.Vals[0]
+		}
+	default:
+		return errInvalidCharstring
+	}
+
+	for i != args.Top {
+		i = out.curveto4(args, swap, vertical, i)
+		if i < 0 {
+			return errInvalidCharstring
+		}
+		if swap {
+			vertical = !vertical
+		}
+	}
+	return nil
+}
+
+func (out *CharstringReader) curveto4(args *ArgStack, swap bool, vertical bool, i int32) (j int32) {
+	if i+4 > args.Top {
+		return -1
+	}
+	dxa := args.Vals[i+0]
+	dya := int32(0)
+	dxb := args.Vals[i+1]
+	dyb := args.Vals[i+2]
+	dxc := args.Vals[i+3]
+	dyc := int32(0)
+	i += 4
+
+	if vertical {
+		dxa, dya = dya, dxa
+	}
+
+	if swap {
+		if i+1 == args.Top {
+			dyc = args.Vals[i]
+			i++
+		}
+	}
+
+	if swap != vertical {
+		dxc, dyc = dyc, dxc
+	}
+
+	out.CubeTo(dxa, dya, dxb, dyb, dxc, dyc)
+	return i
+}
+
+// For the flex operators, we ignore the flex depth and always produce cubic
+// segments, not linear segments.
+
+// Hflex implements the hflex operator.
+func (out *CharstringReader) Hflex(state *Machine) error {
+	args := &state.ArgStack
+	if args.Top != 7 {
+		return errInvalidCharstring
+	}
+	out.CubeTo(args.Vals[0], 0, args.Vals[1], +args.Vals[2], args.Vals[3], 0)
+	out.CubeTo(args.Vals[4], 0, args.Vals[5], -args.Vals[2], args.Vals[6], 0)
+	return nil
+}
+
+// Flex implements the flex operator.
+func (out *CharstringReader) Flex(state *Machine) error {
+	args := &state.ArgStack
+	if args.Top != 13 {
+		return errInvalidCharstring
+	}
+	out.CubeTo(args.Vals[0], args.Vals[1], args.Vals[2], args.Vals[3], args.Vals[4], args.Vals[5])
+	out.CubeTo(args.Vals[6], args.Vals[7], args.Vals[8], args.Vals[9], args.Vals[10], args.Vals[11])
+	return nil
+}
+
+// Hflex1 implements the hflex1 operator.
+func (out *CharstringReader) Hflex1(state *Machine) error {
+	args := &state.ArgStack
+	if args.Top != 9 {
+		return errInvalidCharstring
+	}
+	dy1, dy2, dy5 := args.Vals[1], args.Vals[3], args.Vals[7]
+	out.CubeTo(args.Vals[0], dy1, args.Vals[2], dy2, args.Vals[4], 0)
+	out.CubeTo(args.Vals[5], 0, args.Vals[6], dy5, args.Vals[8], -dy1-dy2-dy5)
+	return nil
+}
+
+// Flex1 implements the flex1 operator.
+func (out *CharstringReader) Flex1(state *Machine) error {
+	args := &state.ArgStack
+	if args.Top != 11 {
+		return errInvalidCharstring
+	}
+	var dx, dy int32
+	for i := 0; i < 10; i += 2 {
+		dx += args.Vals[i]
+		dy += args.Vals[i+1]
+	}
+	// the last point is either horizontal or vertical
+	// relative to the start point
+	dx6, dy6 := args.Vals[10], args.Vals[10]
+	if abs(dx) > abs(dy) {
+		dy6 = -dy
+	} else {
+		dx6 = -dx
+	}
+	out.CubeTo(args.Vals[0], args.Vals[1], args.Vals[2], args.Vals[3], args.Vals[4], args.Vals[5])
+	out.CubeTo(args.Vals[6], args.Vals[7], args.Vals[8], args.Vals[9], dx6, dy6)
+	return nil
+}
+
+func abs(x int32) int32 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// LocalSubr calls the local subroutine whose (biased) index
+// is on top of the stack.
+func (out *CharstringReader) LocalSubr(state *Machine) error {
+	return out.callSubr(state, true)
+}
+
+// GlobalSubr calls the global subroutine whose (biased) index
+// is on top of the stack.
+func (out *CharstringReader) GlobalSubr(state *Machine) error {
+	return out.callSubr(state, false)
+}
+
+func (out *CharstringReader) callSubr(state *Machine, isLocal bool) error {
+	if state.ArgStack.Top < 1 {
+		return fmt.Errorf("invalid number of arguments for subroutine call")
+	}
+	index := state.ArgStack.Pop()
+	return state.CallSubroutine(index, isLocal)
+}
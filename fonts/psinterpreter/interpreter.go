@@ -133,7 +133,15 @@ func (p *Machine) Run(instructions []byte, localSubrs, globalSubrs [][]byte, han
 	p.ArgStack.Top = 0
 	p.callStack.top = 0
 
-	for len(p.instructions) > 0 {
+	for len(p.instructions) > 0 || p.callStack.top > 0 {
+		// a subroutine may end without an explicit return
+		if len(p.instructions) == 0 {
+			if err := p.Return(); err != nil {
+				return err
+			}
+			continue
+		}
+
 		// Push a numeric operand on the stack, if applicable.
 		if hasResult, err := p.parseNumber(); hasResult {
 			if err != nil {
@@ -249,6 +257,12 @@ func (p *Machine) parseNumber() (hasResult bool, err error) {
 		}
 		number, hasResult = int32(be.Uint32(p.instructions[1:])), true
 		p.instructions = p.instructions[5:]
+		if p.ctx == Type2Charstring {
+			// 5177.Type2.pdf section 3.2 "Charstring Number Encoding" says that
+			// this number is interpreted as a 16.16 fixed point number:
+			// round it to the closest integer value, avoiding overflows
+			number = (number >> 16) + (1 & (number >> 15))
+		}
 	}
 
 	if hasResult {
@@ -1,4 +1,4 @@
-// Adobe predefined ToUnicode cmaps
+// Adobe predefined ToUnicode cmaps, and the Unicode based CID CMaps derived from them
 package standardcmaps
 
 import (
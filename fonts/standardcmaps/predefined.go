@@ -0,0 +1,232 @@
+package standardcmaps
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/benoitkugler/pdf/fonts/cmaps"
+	"github.com/benoitkugler/pdf/model"
+)
+
+// the character collections of the Unicode based CMaps,
+// identified by the prefix of their name
+var uniOrderings = [...]struct {
+	prefix    string
+	toUnicode model.ObjName
+}{
+	{"UniGB", "Adobe-GB1-UCS2"},
+	{"UniCNS", "Adobe-CNS1-UCS2"},
+	{"UniJIS", "Adobe-Japan1-UCS2"},
+	{"UniKS", "Adobe-Korea1-UCS2"},
+}
+
+// uniEncoding describes how Unicode points are written
+// as character codes in a Unicode based CMap
+type uniEncoding struct {
+	codespaces []cmaps.Codespace
+	// returns false for unsupported runes
+	encode func(r rune) (code cmaps.CharCode, numBytes int, ok bool)
+}
+
+var uniEncodings = map[string]uniEncoding{
+	"UCS2": {
+		codespaces: []cmaps.Codespace{{NumBytes: 2, Low: 0, High: 0xFFFF}},
+		encode: func(r rune) (cmaps.CharCode, int, bool) {
+			return cmaps.CharCode(r), 2, r <= 0xFFFF && !utf16.IsSurrogate(r)
+		},
+	},
+	"UTF16": {
+		codespaces: []cmaps.Codespace{
+			{NumBytes: 2, Low: 0, High: 0xD7FF},
+			{NumBytes: 4, Low: 0xD800DC00, High: 0xDBFFDFFF},
+			{NumBytes: 2, Low: 0xE000, High: 0xFFFF},
+		},
+		encode: func(r rune) (cmaps.CharCode, int, bool) {
+			if r <= 0xFFFF {
+				return cmaps.CharCode(r), 2, !utf16.IsSurrogate(r)
+			}
+			r1, r2 := utf16.EncodeRune(r)
+			return cmaps.CharCode(r1)<<16 | cmaps.CharCode(r2), 4, r1 != utf8.RuneError
+		},
+	},
+	"UTF32": {
+		codespaces: []cmaps.Codespace{{NumBytes: 4, Low: 0, High: 0x10FFFF}},
+		encode: func(r rune) (cmaps.CharCode, int, bool) {
+			return cmaps.CharCode(r), 4, true
+		},
+	},
+	"UTF8": {
+		codespaces: []cmaps.Codespace{
+			{NumBytes: 1, Low: 0, High: 0x7F},
+			{NumBytes: 2, Low: 0xC280, High: 0xDFBF},
+			{NumBytes: 3, Low: 0xE08080, High: 0xEFBFBF},
+			{NumBytes: 4, Low: 0xF0808080, High: 0xF48FBFBF},
+		},
+		encode: func(r rune) (cmaps.CharCode, int, bool) {
+			var buf [utf8.UTFMax]byte
+			n := utf8.EncodeRune(buf[:], r)
+			var code cmaps.CharCode
+			for _, b := range buf[:n] {
+				code = code<<8 | cmaps.CharCode(b)
+			}
+			return code, n, true
+		},
+	},
+}
+
+var predefinedCache struct {
+	sync.Mutex
+	cmaps map[model.ObjName]cmaps.CMap
+}
+
+// PredefinedCMap returns the predefined CMap `name` (see 9.7.5.2 - Predefined CMaps).
+// Since the Adobe CMap files are not bundled, only a subset is supported:
+//   - Identity-H and Identity-V
+//   - the horizontal Unicode based CMaps, such as UniGB-UCS2-H or UniJIS-UTF16-H.
+//     They are built (once) by inverting the CID to Unicode tables of this package,
+//     using the smallest CID when several glyphs share a Unicode value, so that
+//     a few codes may be mapped to a different (equivalent) glyph than in the Adobe files.
+//
+// An error is returned for the other CMaps: the vertical Unicode CMaps (like UniJIS-UTF16-V),
+// which use dedicated glyphs for some codes, the variants like UniJIS-UCS2-HW-H, and the
+// legacy CMaps like GBK-EUC-H or 90ms-RKSJ-H.
+// The returned CMap is shared and must not be modified.
+func PredefinedCMap(name model.ObjName) (cmaps.CMap, error) {
+	predefinedCache.Lock()
+	defer predefinedCache.Unlock()
+	if cm, ok := predefinedCache.cmaps[name]; ok {
+		return cm, nil
+	}
+	cm, err := buildPredefinedCMap(name)
+	if err != nil {
+		return cmaps.CMap{}, err
+	}
+	if predefinedCache.cmaps == nil {
+		predefinedCache.cmaps = make(map[model.ObjName]cmaps.CMap)
+	}
+	predefinedCache.cmaps[name] = cm
+	return cm, nil
+}
+
+func buildPredefinedCMap(name model.ObjName) (cmaps.CMap, error) {
+	if name == "Identity-H" || name == "Identity-V" {
+		identity := cmaps.Codespace{NumBytes: 2, Low: 0, High: 0xFFFF}
+		return cmaps.CMap{
+			Name:          name,
+			CIDSystemInfo: model.CIDSystemInfo{Registry: "Adobe", Ordering: "Identity"},
+			Codespaces:    []cmaps.Codespace{identity},
+			CIDs:          []cmaps.CIDRange{{Codespace: identity}},
+		}, nil
+	}
+
+	// Uni<Collection>-<Encoding>-H
+	parts := strings.Split(string(name), "-")
+	if len(parts) != 3 || parts[2] != "H" {
+		return cmaps.CMap{}, fmt.Errorf("unsupported predefined CMap %s", name)
+	}
+	var toUnicodeName model.ObjName
+	for _, ord := range uniOrderings {
+		if strings.HasPrefix(parts[0], ord.prefix) {
+			toUnicodeName = ord.toUnicode
+			break
+		}
+	}
+	enc, ok := uniEncodings[parts[1]]
+	if toUnicodeName == "" || !ok {
+		return cmaps.CMap{}, fmt.Errorf("unsupported predefined CMap %s", name)
+	}
+
+	toUnicode := ToUnicodeCMaps[toUnicodeName]
+	// invert the CID to Unicode table, using the smallest CID
+	// when several glyphs have the same Unicode value
+	fromUnicode := make(map[rune]model.CID)
+	for cid, rs := range toUnicode.ProperLookupTable() {
+		if len(rs) != 1 || rs[0] == cmaps.MissingCodeRune {
+			continue
+		}
+		if other, has := fromUnicode[rs[0]]; has && other < cid {
+			continue
+		}
+		fromUnicode[rs[0]] = cid
+	}
+
+	type mapping struct {
+		code     cmaps.CharCode
+		numBytes int
+		cid      model.CID
+	}
+	mappings := make([]mapping, 0, len(fromUnicode))
+	for r, cid := range fromUnicode {
+		if code, n, ok := enc.encode(r); ok {
+			mappings = append(mappings, mapping{code: code, numBytes: n, cid: cid})
+		}
+	}
+	sort.Slice(mappings, func(i, j int) bool { return mappings[i].code < mappings[j].code })
+
+	out := cmaps.CMap{
+		Name:       name,
+		Codespaces: enc.codespaces,
+		CIDSystemInfo: model.CIDSystemInfo{
+			Registry: "Adobe",
+			Ordering: strings.TrimSuffix(strings.TrimPrefix(string(toUnicodeName), "Adobe-"), "-UCS2"),
+		},
+	}
+	// merge the consecutive mappings into ranges
+	for _, m := range mappings {
+		if L := len(out.CIDs); L != 0 {
+			last := &out.CIDs[L-1]
+			if last.NumBytes == m.numBytes && m.code == last.High+1 &&
+				m.cid == last.CIDStart+model.CID(m.code-last.Low) {
+				last.High = m.code
+				continue
+			}
+		}
+		out.CIDs = append(out.CIDs, cmaps.CIDRange{
+			Codespace: cmaps.Codespace{NumBytes: m.numBytes, Low: m.code, High: m.code},
+			CIDStart:  m.cid,
+		})
+	}
+	return out, nil
+}
+
+// ResolveCMap returns the CMap defined by `enc`, which is either
+// predefined (see `PredefinedCMap`) or embedded in the PDF file.
+// The CMaps used by embedded CMaps are resolved and merged.
+func ResolveCMap(enc model.CMapEncoding) (cmaps.CMap, error) {
+	switch enc := enc.(type) {
+	case model.CMapEncodingPredefined:
+		return PredefinedCMap(model.ObjName(enc))
+	case model.CMapEncodingEmbedded:
+		content, err := enc.Decode()
+		if err != nil {
+			return cmaps.CMap{}, err
+		}
+		cm, err := cmaps.ParseCIDCMap(content)
+		if err != nil {
+			return cmaps.CMap{}, err
+		}
+		var base cmaps.CMap
+		if enc.UseCMap != nil {
+			base, err = ResolveCMap(enc.UseCMap)
+		} else if cm.UseCMap != "" {
+			base, err = PredefinedCMap(cm.UseCMap)
+		} else {
+			return cm, nil
+		}
+		if err != nil {
+			return cmaps.CMap{}, fmt.Errorf("invalid CMap %s used by %s: %s", cm.UseCMap, cm.Name, err)
+		}
+		// the mappings of `cm` take precedence
+		cm.Codespaces = append(append([]cmaps.Codespace(nil), base.Codespaces...), cm.Codespaces...)
+		cm.CIDs = append(append([]cmaps.CIDRange(nil), base.CIDs...), cm.CIDs...)
+		cm.SortCIDs()
+		return cm, nil
+	default:
+		return cmaps.CMap{}, errors.New("missing CMap encoding")
+	}
+}
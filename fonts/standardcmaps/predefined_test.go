@@ -0,0 +1,95 @@
+package standardcmaps
+
+import (
+	"testing"
+	"unicode/utf16"
+
+	"github.com/benoitkugler/pdf/model"
+)
+
+func encodeUTF16(s string) []byte {
+	var out []byte
+	for _, u := range utf16.Encode([]rune(s)) {
+		out = append(out, byte(u>>8), byte(u))
+	}
+	return out
+}
+
+func encodeUTF32(s string) []byte {
+	var out []byte
+	for _, r := range s {
+		out = append(out, byte(r>>24), byte(r>>16), byte(r>>8), byte(r))
+	}
+	return out
+}
+
+func TestPredefinedCMap(t *testing.T) {
+	identity, err := PredefinedCMap("Identity-H")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cids := identity.Decode([]byte{0x12, 0x34, 0, 5}); len(cids) != 2 || cids[0] != 0x1234 || cids[1] != 5 {
+		t.Fatalf("unexpected CIDs %v", cids)
+	}
+
+	for _, test := range []struct {
+		name      model.ObjName
+		toUnicode model.ObjName
+		text      string
+		codes     []byte
+	}{
+		{"UniGB-UCS2-H", "Adobe-GB1-UCS2", "中文 A", encodeUTF16("中文 A")},
+		{"UniCNS-UTF16-H", "Adobe-CNS1-UCS2", "中文", encodeUTF16("中文")},
+		{"UniJIS-UTF16-H", "Adobe-Japan1-UCS2", "日本語𠀋", encodeUTF16("日本語𠀋")},
+		{"UniKS-UTF32-H", "Adobe-Korea1-UCS2", "한국어", encodeUTF32("한국어")},
+		{"UniJIS-UTF8-H", "Adobe-Japan1-UCS2", "日本語 a", []byte("日本語 a")},
+	} {
+		cmap, err := PredefinedCMap(test.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		toUnicode := ToUnicodeCMaps[test.toUnicode].ProperLookupTable()
+		var text []rune
+		for _, cid := range cmap.Decode(test.codes) {
+			text = append(text, toUnicode[cid]...)
+		}
+		if string(text) != test.text {
+			t.Errorf("%s: expected %s, got %s", test.name, test.text, string(text))
+		}
+	}
+
+	for _, name := range []model.ObjName{"GBK-EUC-H", "90ms-RKSJ-H", "UniJIS-UTF16-V", "UniJIS-UCS2-HW-H"} {
+		if _, err := PredefinedCMap(name); err == nil {
+			t.Fatalf("expected error for unsupported CMap %s", name)
+		}
+	}
+}
+
+func TestResolveCMap(t *testing.T) {
+	// override the CID of 'A' in UniJIS-UCS2-H
+	content := []byte(`/CIDInit /ProcSet findresource begin
+12 dict begin
+begincmap
+/UniJIS-UCS2-H usecmap
+/CMapName /Custom def
+1 begincidchar
+<0041> 1000
+endcidchar
+endcmap
+CMapName currentdict /CMap defineresource pop
+end
+end`)
+	cmap, err := ResolveCMap(model.CMapEncodingEmbedded{Stream: model.Stream{Content: content}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, _ := PredefinedCMap("UniJIS-UCS2-H")
+	cids, baseCIDs := cmap.Decode([]byte{0, 'A', 0, 'B'}), base.Decode([]byte{0, 'A', 0, 'B'})
+	if cids[0] != 1000 || cids[1] != baseCIDs[1] || cids[1] == 0 {
+		t.Fatalf("unexpected CIDs %v", cids)
+	}
+
+	if _, err = ResolveCMap(nil); err == nil {
+		t.Fatal("expected error for missing encoding")
+	}
+}
@@ -1,8 +1,6 @@
 package standardfonts
 
 import (
-	"log"
-
 	"github.com/benoitkugler/pdf/fonts/simpleencodings"
 	"github.com/benoitkugler/pdf/fonts/type1"
 	"github.com/benoitkugler/pdf/model"
@@ -62,7 +60,7 @@ func (m Metrics) WidthsWithEncoding(encoding simpleencodings.Encoding) (firstCha
 		}
 		width, ok := m.CharsWidths[name]
 		if !ok {
-			log.Printf("unsupported glyph name : %s", name)
+			model.DefaultLogger().Log(model.LogWarning, "unsupported glyph name", "name", name)
 		}
 		index := code - int(firstChar)
 		widths[index] = width
@@ -71,9 +69,9 @@ func (m Metrics) WidthsWithEncoding(encoding simpleencodings.Encoding) (firstCha
 }
 
 // WesternType1Font return a version of the font
-// using WinAnsi encoding (except for Symbol and ZapfDingbats)
+// using WinAnsi encoding (except for symbolic fonts, such as Symbol and ZapfDingbats)
 func (m Metrics) WesternType1Font() model.FontType1 {
-	if m.Descriptor.FontName == "ZapfDingbats" || m.Descriptor.FontName == "Symbol" {
+	if m.Descriptor.Flags&model.Symbolic != 0 {
 		// keep the builtin encoding
 		f, w := m.WidthsWithEncoding(m.Builtin)
 		return model.FontType1{
@@ -94,3 +92,16 @@ func (m Metrics) WesternType1Font() model.FontType1 {
 		Encoding:       model.WinAnsiEncoding,
 	}
 }
+
+// Kern returns the kerning adjustment between the glyphs `first` and `second`
+// (as found in the KernPairs entry), in glyph units.
+// A negative value brings the glyphs closer, and 0 is returned
+// for glyphs without kerning.
+func (m Metrics) Kern(first, second string) int {
+	for _, pair := range m.KernPairs[first] {
+		if pair.SndChar == second {
+			return pair.KerningDistance
+		}
+	}
+	return 0
+}
@@ -0,0 +1,223 @@
+package fonts
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/benoitkugler/pdf/fonts/simpleencodings"
+	"github.com/benoitkugler/pdf/model"
+	"golang.org/x/image/font/sfnt"
+)
+
+var errInvalidCmap = errors.New("invalid 'cmap' table")
+
+// sfntTable returns the content of the table `tag` of the TrueType font file
+func sfntTable(file []byte, tag string) ([]byte, error) {
+	if len(file) < 12 {
+		return nil, errors.New("invalid font file header")
+	}
+	numTables := int(binary.BigEndian.Uint16(file[4:]))
+	if len(file) < 12+16*numTables {
+		return nil, errors.New("invalid font file table directory")
+	}
+	for i := 0; i < numTables; i++ {
+		record := file[12+16*i:]
+		if string(record[:4]) != tag {
+			continue
+		}
+		offset, length := binary.BigEndian.Uint32(record[8:]), binary.BigEndian.Uint32(record[12:])
+		if uint64(offset)+uint64(length) > uint64(len(file)) {
+			return nil, fmt.Errorf("invalid '%s' table bounds", tag)
+		}
+		return file[offset : offset+length], nil
+	}
+	return nil, fmt.Errorf("missing '%s' table", tag)
+}
+
+// symbolicCmap returns the mapping from the character codes (of a simple font)
+// to the glyph indices, using the (3,0) subtable, or the (1,0) one.
+// For the (3,0) subtable, the codes may be mapped in the ranges
+// 0x0000-0x00FF, 0xF000-0xF0FF, 0xF100-0xF1FF or 0xF200-0xF2FF.
+func symbolicCmap(cmap []byte) (map[byte]uint16, error) {
+	if len(cmap) < 4 {
+		return nil, errInvalidCmap
+	}
+	numTables := int(binary.BigEndian.Uint16(cmap[2:]))
+	if len(cmap) < 4+8*numTables {
+		return nil, errInvalidCmap
+	}
+	var mac, windows []byte // subtables
+	for i := 0; i < numTables; i++ {
+		record := cmap[4+8*i:]
+		offset := binary.BigEndian.Uint32(record[4:])
+		if uint64(offset) >= uint64(len(cmap)) {
+			return nil, errInvalidCmap
+		}
+		switch platform, encoding := binary.BigEndian.Uint16(record), binary.BigEndian.Uint16(record[2:]); {
+		case platform == 3 && encoding == 0:
+			windows = cmap[offset:]
+		case platform == 1 && encoding == 0:
+			mac = cmap[offset:]
+		}
+	}
+
+	if windows != nil {
+		lookup, err := cmapSubtable(windows)
+		if err != nil {
+			return nil, err
+		}
+		out := make(map[byte]uint16)
+		for _, base := range [...]rune{0, 0xF000, 0xF100, 0xF200} {
+			for code := 0; code < 256; code++ {
+				if gid := lookup(base + rune(code)); gid != 0 {
+					out[byte(code)] = gid
+				}
+			}
+			if len(out) != 0 { // use the first valid range
+				return out, nil
+			}
+		}
+		return out, nil
+	}
+	if mac != nil {
+		lookup, err := cmapSubtable(mac)
+		if err != nil {
+			return nil, err
+		}
+		out := make(map[byte]uint16)
+		for code := 0; code < 256; code++ {
+			if gid := lookup(rune(code)); gid != 0 {
+				out[byte(code)] = gid
+			}
+		}
+		return out, nil
+	}
+	return nil, errors.New("missing (3,0) or (1,0) 'cmap' subtable")
+}
+
+// cmapSubtable returns a lookup function for the subtable starting at `sub`,
+// which must use the format 0, 4 or 6
+func cmapSubtable(sub []byte) (func(r rune) uint16, error) {
+	if len(sub) < 6 {
+		return nil, errInvalidCmap
+	}
+	switch format := binary.BigEndian.Uint16(sub); format {
+	case 0: // byte encoding table
+		if len(sub) < 6+256 {
+			return nil, errInvalidCmap
+		}
+		glyphs := sub[6 : 6+256]
+		return func(r rune) uint16 {
+			if r < 0 || r > 255 {
+				return 0
+			}
+			return uint16(glyphs[r])
+		}, nil
+	case 4: // segment mapping to delta values
+		if len(sub) < 14 {
+			return nil, errInvalidCmap
+		}
+		segCount := int(binary.BigEndian.Uint16(sub[6:]) / 2)
+		if len(sub) < 16+8*segCount {
+			return nil, errInvalidCmap
+		}
+		endCodes, startCodes := sub[14:], sub[16+2*segCount:]
+		deltas, rangeOffsets := sub[16+4*segCount:], sub[16+6*segCount:]
+		return func(r rune) uint16 {
+			for i := 0; i < segCount; i++ {
+				end, start := rune(binary.BigEndian.Uint16(endCodes[2*i:])), rune(binary.BigEndian.Uint16(startCodes[2*i:]))
+				if r > end || r < start {
+					continue
+				}
+				delta, rangeOffset := binary.BigEndian.Uint16(deltas[2*i:]), int(binary.BigEndian.Uint16(rangeOffsets[2*i:]))
+				if rangeOffset == 0 {
+					return uint16(r) + delta
+				}
+				// the offset is relative to the rangeOffset entry itself
+				index := 16 + 6*segCount + 2*i + rangeOffset + 2*int(r-start)
+				if index+2 > len(sub) {
+					return 0
+				}
+				if gid := binary.BigEndian.Uint16(sub[index:]); gid != 0 {
+					return gid + delta
+				}
+				return 0
+			}
+			return 0
+		}, nil
+	case 6: // trimmed table mapping
+		if len(sub) < 10 {
+			return nil, errInvalidCmap
+		}
+		first, count := rune(binary.BigEndian.Uint16(sub[6:])), int(binary.BigEndian.Uint16(sub[8:]))
+		if len(sub) < 10+2*count {
+			return nil, errInvalidCmap
+		}
+		return func(r rune) uint16 {
+			if r < first || int(r-first) >= count {
+				return 0
+			}
+			return binary.BigEndian.Uint16(sub[10+2*int(r-first):])
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported 'cmap' subtable format %d", format)
+	}
+}
+
+// builtinTrueTypeEncoding returns the encoding of a symbolic TrueType font,
+// given by the (3,0) or (1,0) 'cmap' subtable of its font program, and the glyph
+// names found in its 'post' table.
+// It returns nil if the font file is missing or invalid.
+// See 9.6.6.4 - Encodings for TrueType Fonts
+func builtinTrueTypeEncoding(desc model.FontDescriptor) *simpleencodings.Encoding {
+	if desc.FontFile == nil {
+		return nil
+	}
+	content, err := desc.FontFile.Decode()
+	if err != nil {
+		model.DefaultLogger().Log(model.LogWarning, "unable to decode embedded font file", "error", err)
+		return nil
+	}
+	cmap, err := sfntTable(content, "cmap")
+	if err != nil {
+		model.DefaultLogger().Log(model.LogWarning, "invalid TrueType embedded font file", "error", err)
+		return nil
+	}
+	codes, err := symbolicCmap(cmap)
+	if err != nil {
+		model.DefaultLogger().Log(model.LogWarning, "invalid TrueType embedded font file", "error", err)
+		return nil
+	}
+	ft, err := sfnt.Parse(content)
+	if err != nil {
+		model.DefaultLogger().Log(model.LogWarning, "invalid TrueType embedded font file", "error", err)
+		return nil
+	}
+	var (
+		out simpleencodings.Encoding
+		buf sfnt.Buffer
+	)
+	for code, gid := range codes {
+		name, err := ft.GlyphName(&buf, sfnt.GlyphIndex(gid))
+		if err != nil || name == "" { // no 'post' table
+			name = fmt.Sprintf("g%d", gid)
+		}
+		out[code] = name
+	}
+	return &out
+}
+
+// EncodingFor returns the encoding (from character codes to glyph names)
+// of the simple font `font` (see ResolveSimpleEncoding).
+// It returns false for Type0 fonts, which do not use simple encodings.
+func EncodingFor(font *model.FontDict) (simpleencodings.Encoding, bool) {
+	if font == nil {
+		return simpleencodings.Encoding{}, false
+	}
+	ft, ok := font.Subtype.(model.FontSimple)
+	if !ok {
+		return simpleencodings.Encoding{}, false
+	}
+	return ResolveSimpleEncoding(ft), true
+}
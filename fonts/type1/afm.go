@@ -83,3 +83,9 @@ func (f AFMFont) CharSet() string {
 	}
 	return v.String()
 }
+
+// IsSymbolic returns true if the font uses a font specific
+// encoding (as opposed to the Adobe standard encoding).
+func (f AFMFont) IsSymbolic() bool {
+	return f.encodingScheme == "FontSpecific"
+}
@@ -0,0 +1,386 @@
+package type1
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	ps "github.com/benoitkugler/pdf/fonts/psinterpreter"
+	"github.com/benoitkugler/pdf/fonts/simpleencodings"
+	tk "github.com/benoitkugler/pstokenizer"
+)
+
+// decrypt applies the decryption algorithm defined in T1_SPEC.pdf
+// section 7 "Encryption", discarding the first `skip` bytes.
+func decrypt(data []byte, key uint16, skip int) []byte {
+	const c1, c2 = 52845, 22719
+	out := make([]byte, len(data))
+	for i, c := range data {
+		out[i] = c ^ byte(key>>8)
+		key = (uint16(c)+key)*c1 + c2
+	}
+	if skip > len(out) {
+		return nil
+	}
+	return out[skip:]
+}
+
+// isHexSegment returns true if the eexec encrypted portion is
+// stored using the hexadecimal form.
+func isHexSegment(segment []byte) bool {
+	if len(segment) < 4 {
+		return false
+	}
+	for _, c := range segment[:4] {
+		isHex := '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+		if !isHex {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeHex decodes the hexadecimal form of the encrypted portion,
+// ignoring whitespaces and stopping at the first invalid character.
+func decodeHex(segment []byte) []byte {
+	digits := make([]byte, 0, len(segment))
+	for _, c := range segment {
+		if tk.IsAsciiWhitespace(c) {
+			continue
+		}
+		isHex := '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+		if !isHex {
+			break
+		}
+		digits = append(digits, c)
+	}
+	out := make([]byte, len(digits)/2)
+	hex.Decode(out, digits[:2*len(out)])
+	return out
+}
+
+// parseBinary decrypts the second segment of a font file, and extracts
+// the subroutines and the charstrings of the Private dictionary, which
+// are also decrypted.
+func parseBinary(segment []byte) (subrs [][]byte, charstrings map[string][]byte, err error) {
+	if isHexSegment(segment) {
+		segment = decodeHex(segment)
+	}
+	segment = decrypt(segment, eexecKey, 4)
+
+	// the content of the Private dictionary is not validated:
+	// we only look for the /lenIV entry, and the
+	// subroutines and charstrings, which are respectively written as
+	//	dup <index> <length> RD <binary> NP
+	//	/<name> <length> RD <binary> ND
+	lexer := newLexer(segment)
+	lenIV := 4
+	charstrings = make(map[string][]byte)
+	var previous [2]tk.Token // two and one tokens back
+	for {
+		token, err := lexer.nextToken()
+		if err != nil {
+			if len(charstrings) != 0 { // ignore the trailing content
+				break
+			}
+			return nil, nil, err
+		}
+		if token.Kind == tk.EOF {
+			break
+		}
+
+		switch {
+		case token.Kind == tk.Integer && previous[1].Kind == tk.Name && string(previous[1].Value) == "lenIV":
+			lenIV, _ = token.Int()
+		case token.Kind == tk.Integer && previous[1].Kind == tk.Name && string(previous[1].Value) == "Subrs":
+			count, _ := token.Int()
+			if count < 0 || count > 1<<16 {
+				return nil, nil, fmt.Errorf("invalid number of subroutines %d", count)
+			}
+			subrs = make([][]byte, count)
+		case token.Kind == tk.CharString && previous[0].Kind == tk.Integer: // subroutine
+			index, _ := previous[0].Int()
+			if 0 <= index && index < len(subrs) {
+				subrs[index] = token.Value
+			}
+		case token.Kind == tk.CharString && previous[0].Kind == tk.Name: // glyph
+			charstrings[string(previous[0].Value)] = token.Value
+		}
+
+		previous[0], previous[1] = previous[1], token
+	}
+
+	if len(charstrings) == 0 {
+		return nil, nil, errors.New("missing charstrings")
+	}
+
+	if lenIV >= 0 { // -1 means no encryption
+		for i, subr := range subrs {
+			subrs[i] = decrypt(subr, CHARSTRING_KEY, lenIV)
+		}
+		for name, charstring := range charstrings {
+			charstrings[name] = decrypt(charstring, CHARSTRING_KEY, lenIV)
+		}
+	}
+	return subrs, charstrings, nil
+}
+
+// GlyphNames returns the names of the glyphs defined in the font.
+func (f *Font) GlyphNames() []string {
+	out := make([]string, 0, len(f.charstrings))
+	for name := range f.charstrings {
+		out = append(out, name)
+	}
+	return out
+}
+
+// LoadGlyph returns the outline of the glyph `name`, in font units.
+func (f *Font) LoadGlyph(name string) ([]ps.Segment, error) {
+	return f.loadGlyph(name, ps.Point{}, true)
+}
+
+// loadGlyph draws the glyph `name`, with its origin at `origin`
+func (f *Font) loadGlyph(name string, origin ps.Point, allowSeac bool) ([]ps.Segment, error) {
+	charstring, ok := f.charstrings[name]
+	if !ok {
+		return nil, fmt.Errorf("missing glyph %s", name)
+	}
+	var (
+		psi     ps.Machine
+		handler = type1CharstringHandler{origin: origin}
+	)
+	if err := psi.Run(charstring, f.subrs, nil, &handler); err != nil {
+		return nil, fmt.Errorf("invalid charstring for glyph %s: %s", name, err)
+	}
+	if handler.seac == nil {
+		return handler.cs.Segments, nil
+	}
+	if !allowSeac {
+		return nil, errors.New("invalid nested accented character")
+	}
+	return f.loadSeac(*handler.seac, handler.lsb)
+}
+
+// loadSeac builds the accented character described by the
+// arguments of the 'seac' operator: asb adx ady bchar achar,
+// where the characters are given by their codes in the standard encoding.
+// `lsb` is the left side bearing of the accented character.
+func (f *Font) loadSeac(args [5]int32, lsb ps.Point) ([]ps.Segment, error) {
+	var names [2]string
+	for i, code := range args[3:] {
+		if code < 0 || code > 255 {
+			return nil, fmt.Errorf("invalid character code %d in accented character", code)
+		}
+		names[i] = simpleencodings.AdobeStandard[code]
+	}
+	out, err := f.loadGlyph(names[0], ps.Point{}, false)
+	if err != nil {
+		return nil, err
+	}
+	// the origin of the accent is relative to the left side bearing of the base
+	origin := ps.Point{X: args[1] + lsb.X - args[0], Y: args[2]}
+	accent, err := f.loadGlyph(names[1], origin, false)
+	if err != nil {
+		return nil, err
+	}
+	return append(out, accent...), nil
+}
+
+// type1CharstringHandler builds the outline of a glyph
+type type1CharstringHandler struct {
+	cs ps.CharstringReader
+
+	origin ps.Point // translation of the glyph
+	lsb    ps.Point // as defined by hsbw or sbw
+
+	isFlexing  bool
+	flexStart  ps.Point
+	flexPoints []ps.Point
+
+	seac *[5]int32 // asb adx ady bchar achar
+}
+
+func (type1CharstringHandler) Context() ps.PsContext { return ps.Type1Charstring }
+
+// Apply implements the operators defined by T1_SPEC.pdf
+// section 6 "CharString Command List"
+func (h *type1CharstringHandler) Apply(op ps.PsOperator, state *ps.Machine) error {
+	args := &state.ArgStack
+	if !op.IsEscaped {
+		switch op.Operator {
+		case 1, 3: // hstem, vstem
+		case 4: // vmoveto
+			if args.Top < 1 {
+				return errInvalidArguments(op)
+			}
+			h.moveTo(0, args.Vals[args.Top-1])
+		case 5: // rlineto
+			if args.Top < 2 {
+				return errInvalidArguments(op)
+			}
+			h.cs.LineTo(args.Vals[args.Top-2], args.Vals[args.Top-1])
+		case 6: // hlineto
+			if args.Top < 1 {
+				return errInvalidArguments(op)
+			}
+			h.cs.LineTo(args.Vals[args.Top-1], 0)
+		case 7: // vlineto
+			if args.Top < 1 {
+				return errInvalidArguments(op)
+			}
+			h.cs.LineTo(0, args.Vals[args.Top-1])
+		case 8: // rrcurveto
+			if args.Top < 6 {
+				return errInvalidArguments(op)
+			}
+			v := args.Vals[args.Top-6 : args.Top]
+			h.cs.CubeTo(v[0], v[1], v[2], v[3], v[4], v[5])
+		case 9: // closepath
+			h.cs.ClosePath()
+		case 10: // callsubr
+			if args.Top < 1 {
+				return errInvalidArguments(op)
+			}
+			return state.CallSubroutine(args.Pop(), true)
+		case 11: // return
+			return state.Return()
+		case 13: // hsbw
+			if args.Top < 2 {
+				return errInvalidArguments(op)
+			}
+			h.setSidebearing(args.Vals[args.Top-2], 0)
+		case 14: // endchar
+			h.cs.ClosePath()
+			return ps.ErrInterrupt
+		case 21: // rmoveto
+			if args.Top < 2 {
+				return errInvalidArguments(op)
+			}
+			h.moveTo(args.Vals[args.Top-2], args.Vals[args.Top-1])
+		case 22: // hmoveto
+			if args.Top < 1 {
+				return errInvalidArguments(op)
+			}
+			h.moveTo(args.Vals[args.Top-1], 0)
+		case 30: // vhcurveto
+			if args.Top < 4 {
+				return errInvalidArguments(op)
+			}
+			v := args.Vals[args.Top-4 : args.Top]
+			h.cs.CubeTo(0, v[0], v[1], v[2], v[3], 0)
+		case 31: // hvcurveto
+			if args.Top < 4 {
+				return errInvalidArguments(op)
+			}
+			v := args.Vals[args.Top-4 : args.Top]
+			h.cs.CubeTo(v[0], 0, v[1], v[2], 0, v[3])
+		default:
+			return fmt.Errorf("invalid operator %s in Type1 charstring", op)
+		}
+	} else {
+		switch op.Operator {
+		case 0, 1, 2: // dotsection, vstem3, hstem3
+		case 6: // seac
+			if args.Top < 5 {
+				return errInvalidArguments(op)
+			}
+			v := args.Vals[args.Top-5 : args.Top]
+			h.seac = &[5]int32{v[0], v[1], v[2], v[3], v[4]}
+			return ps.ErrInterrupt
+		case 7: // sbw
+			if args.Top < 4 {
+				return errInvalidArguments(op)
+			}
+			h.setSidebearing(args.Vals[args.Top-4], args.Vals[args.Top-3])
+		case 12: // div
+			if args.Top < 2 {
+				return errInvalidArguments(op)
+			}
+			num2, num1 := args.Pop(), args.Pop()
+			if num2 == 0 {
+				return errors.New("division by zero in Type1 charstring")
+			}
+			args.Vals[args.Top] = num1 / num2
+			args.Top++
+			return nil
+		case 16: // callothersubr
+			return h.callOtherSubr(args)
+		case 17: // pop
+			// the arguments of the OtherSubrs are kept on the stack (see callOtherSubr)
+			return nil
+		case 33: // setcurrentpoint
+			// only used after flex, where the current point
+			// has already been updated
+		default:
+			return fmt.Errorf("invalid operator %s in Type1 charstring", op)
+		}
+	}
+	args.Clear()
+	return nil
+}
+
+func errInvalidArguments(op ps.PsOperator) error {
+	return fmt.Errorf("invalid number of arguments for %s in Type1 charstring", op)
+}
+
+func (h *type1CharstringHandler) setSidebearing(sbx, sby int32) {
+	h.lsb = ps.Point{X: sbx, Y: sby}
+	h.cs.CurrentPoint = h.origin
+	h.cs.CurrentPoint.Move(sbx, sby)
+}
+
+// moveTo only moves the current point while
+// reading the points of a flex
+func (h *type1CharstringHandler) moveTo(dx, dy int32) {
+	if h.isFlexing {
+		h.cs.CurrentPoint.Move(dx, dy)
+		return
+	}
+	h.cs.MoveTo(dx, dy)
+}
+
+// callOtherSubr implements the flex mechanism, described in T1_SPEC.pdf
+// section 8 "Using Subroutines".
+// The arguments of the OtherSubrs are left on the stack, so that the
+// following pop operators are no-ops: this is the expected result for
+// the hint replacement mechanism ("subr# 1 3 callothersubr pop callsubr"),
+// and for the end of flex ("flexheight x y 3 0 callothersubr pop pop setcurrentpoint").
+func (h *type1CharstringHandler) callOtherSubr(args *ps.ArgStack) error {
+	if args.Top < 2 {
+		return errors.New("invalid number of arguments for callothersubr in Type1 charstring")
+	}
+	othersubr, n := args.Pop(), args.Pop()
+	if n < 0 || n > args.Top {
+		return errors.New("invalid number of arguments for callothersubr in Type1 charstring")
+	}
+
+	switch othersubr {
+	case 0: // end of flex: flexheight x y
+		if n != 3 || len(h.flexPoints) != 7 {
+			return errors.New("invalid flex in Type1 charstring")
+		}
+		h.isFlexing = false
+		h.cs.CurrentPoint = h.flexStart
+		pts := h.flexPoints[1:] // the first point is the reference point
+		prev := h.flexStart
+		var d [6]int32
+		for i := 0; i < 2; i++ {
+			for j := 0; j < 3; j++ {
+				pt := pts[3*i+j]
+				d[2*j], d[2*j+1] = pt.X-prev.X, pt.Y-prev.Y
+				prev = pt
+			}
+			h.cs.CubeTo(d[0], d[1], d[2], d[3], d[4], d[5])
+		}
+	case 1: // start of flex
+		h.isFlexing = true
+		h.flexStart = h.cs.CurrentPoint
+		h.flexPoints = h.flexPoints[:0]
+	case 2: // flex point
+		if !h.isFlexing {
+			return errors.New("invalid flex in Type1 charstring")
+		}
+		h.flexPoints = append(h.flexPoints, h.cs.CurrentPoint)
+	}
+	return nil
+}
@@ -7,10 +7,10 @@ import (
 	"github.com/benoitkugler/pdf/fonts/simpleencodings"
 )
 
-// Parse parses an Adobe Type 1 (.pfb) font file, extracting its builtin
+// ParseEncoding parses an Adobe Type 1 (.pfb) font file, extracting its builtin
 // encoding.
 func ParseEncoding(pfb *bytes.Reader) (*simpleencodings.Encoding, error) {
-	seg1, err := openPfb(pfb)
+	seg1, _, err := openPfb(pfb)
 	if err != nil {
 		return nil, fmt.Errorf("invalid .pfb font file: %s", err)
 	}
@@ -23,3 +23,36 @@ func ParseEncoding(pfb *bytes.Reader) (*simpleencodings.Encoding, error) {
 
 	return out, nil
 }
+
+// Font is a parsed Type1 font program, giving
+// access to its glyph outlines.
+type Font struct {
+	// Encoding is the builtin encoding of the font.
+	Encoding *simpleencodings.Encoding
+
+	charstrings map[string][]byte // decrypted charstrings
+	subrs       [][]byte          // decrypted subroutines
+}
+
+// Parse parses an Adobe Type 1 (.pfb) font file, extracting
+// its builtin encoding and its charstrings.
+func Parse(pfb *bytes.Reader) (*Font, error) {
+	seg1, seg2, err := openPfb(pfb)
+	if err != nil {
+		return nil, fmt.Errorf("invalid .pfb font file: %s", err)
+	}
+
+	p := parser{}
+	enc, err := p.parseASCII(seg1)
+	if err != nil {
+		return nil, fmt.Errorf("invalid .pfb font file: %s", err)
+	}
+
+	out := Font{Encoding: enc}
+	out.subrs, out.charstrings, err = parseBinary(seg2)
+	if err != nil {
+		return nil, fmt.Errorf("invalid .pfb font file: %s", err)
+	}
+
+	return &out, nil
+}
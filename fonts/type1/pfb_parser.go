@@ -25,6 +25,9 @@ const (
 
 	// marker of the ascii segment
 	asciiMarker = 0x01
+
+	// marker of the binary segment
+	binaryMarker = 0x02
 )
 
 func readOneRecord(pfb *bytes.Reader, expectedMarker byte, totalSize int64) ([]byte, error) {
@@ -54,28 +57,37 @@ func readOneRecord(pfb *bytes.Reader, expectedMarker byte, totalSize int64) ([]b
 	return out, nil
 }
 
-// fetchs the first segment of a .pfb font file.
+// fetchs the first and second segments of a .pfb font file.
+// The second segment is still encrypted.
 // see https://www.adobe.com/content/dam/acom/en/devnet/font/pdfs/5040.Download_Fonts.pdf
 // IBM PC format
-func openPfb(pfb *bytes.Reader) (segment1 []byte, err error) {
+func openPfb(pfb *bytes.Reader) (segment1, segment2 []byte, err error) {
 	totalSize, err := pfb.Seek(0, io.SeekEnd)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	_, err = pfb.Seek(0, io.SeekStart)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// ascii record
 	segment1, err = readOneRecord(pfb, asciiMarker, totalSize)
 	if err != nil {
 		// try with the brute force approach for file who have no tag
-		segment1, _, err = seekMarkers(pfb)
-		return segment1, err
+		return seekMarkers(pfb)
+	}
+
+	// the binary segment may be split in several records
+	for {
+		record, err := readOneRecord(pfb, binaryMarker, totalSize)
+		if err != nil {
+			break
+		}
+		segment2 = append(segment2, record...)
 	}
 
-	return segment1, nil
+	return segment1, segment2, nil
 }
 
 // fallback when no binary marker are present:
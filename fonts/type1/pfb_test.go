@@ -57,7 +57,7 @@ func TestTokenize(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	s1, err := openPfb(bytes.NewReader(b))
+	s1, _, err := openPfb(bytes.NewReader(b))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -69,3 +69,43 @@ func TestTokenize(t *testing.T) {
 	}
 	fmt.Println(len(tks))
 }
+
+func TestLoadGlyphs(t *testing.T) {
+	for _, filename := range []string{
+		"../test/c0419bt_.pfb",
+		"../test/CalligrapherRegular.pfb",
+		"../test/Z003-MediumItalic.t1",
+	} {
+		b, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		font, err := Parse(bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range font.GlyphNames() {
+			if _, err := font.LoadGlyph(name); err != nil {
+				t.Fatal(err, "in", filename)
+			}
+		}
+
+		// the outline of an accented character contains its base
+		base, err := font.LoadGlyph("A")
+		if err != nil {
+			t.Fatal(err)
+		}
+		accented, err := font.LoadGlyph("Aacute")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(base) == 0 || len(accented) <= len(base) {
+			t.Fatalf("unexpected outlines with %d and %d segments", len(base), len(accented))
+		}
+
+		if _, err := font.LoadGlyph("<missing>"); err == nil {
+			t.Fatal("expected error for missing glyph")
+		}
+	}
+}
@@ -12,12 +12,31 @@ import (
 	"github.com/benoitkugler/pdf/fonts/simpleencodings"
 )
 
-// Parse parse a .cff font file, extracting its encoding
+// Font is a parsed CFF font program, giving
+// access to its glyph outlines.
+type Font struct {
+	// Encoding is the builtin encoding of the font.
+	Encoding *simpleencodings.Encoding
+
+	charstrings [][]byte // indexed by glyph index
+	charset     []uint16 // glyph index to SID, or CID for CIDFonts
+	strs        userStrings
+
+	globalSubrs [][]byte
+	localSubrs  [][]byte
+
+	// CIDFonts only
+	isCID    bool
+	fdSelect []byte     // glyph index to font index
+	fdSubrs  [][][]byte // local subroutines of each font
+}
+
+// Parse parses a .cff font file.
 // Although CFF enables multiple font or CIDFont programs to be bundled together in a
 // single file, embedded CFF font file in PDF or in TrueType/OpenType fonts
 // shall consist of exactly one font or CIDFont. Thus, this function
 // returns an error if the file contains more than one font.
-func ParseEncoding(file *bytes.Reader) (*simpleencodings.Encoding, error) {
+func Parse(file *bytes.Reader) (*Font, error) {
 	fonts, err := parse(file)
 	if err != nil {
 		return nil, err
@@ -25,10 +44,20 @@ func ParseEncoding(file *bytes.Reader) (*simpleencodings.Encoding, error) {
 	if len(fonts) != 1 {
 		return nil, errors.New("only one CFF font is allowed in embedded files")
 	}
-	return fonts[0], nil
+	return &fonts[0], nil
+}
+
+// ParseEncoding parses a .cff font file, extracting its encoding.
+// See Parse for the restrictions on the file.
+func ParseEncoding(file *bytes.Reader) (*simpleencodings.Encoding, error) {
+	font, err := Parse(file)
+	if err != nil {
+		return nil, err
+	}
+	return font.Encoding, nil
 }
 
-func parse(file *bytes.Reader) ([]*simpleencodings.Encoding, error) {
+func parse(file *bytes.Reader) ([]Font, error) {
 	// read 4 bytes to check if its a supported CFF file
 	var buf [4]byte
 	file.Read(buf[:])
@@ -0,0 +1,207 @@
+package type1c
+
+import (
+	"errors"
+	"fmt"
+
+	ps "github.com/benoitkugler/pdf/fonts/psinterpreter"
+	"github.com/benoitkugler/pdf/fonts/simpleencodings"
+)
+
+// NumGlyphs returns the number of glyphs in the font.
+func (f *Font) NumGlyphs() int { return len(f.charstrings) }
+
+// GlyphIndexByName returns the index of the glyph `name`,
+// as defined by the charset of the font.
+// It always returns false for CIDFonts.
+func (f *Font) GlyphIndexByName(name string) (uint16, bool) {
+	if f.isCID {
+		return 0, false
+	}
+	for gid, sid := range f.charset {
+		if gid >= len(f.charstrings) {
+			break
+		}
+		if glyph, _ := f.strs.getString(sid); glyph == name {
+			return uint16(gid), true
+		}
+	}
+	return 0, false
+}
+
+// GlyphIndexByCID returns the index of the glyph selected by `cid`.
+// For CIDFonts, the charset of the font is used; otherwise,
+// the CID is interpreted as a glyph index.
+func (f *Font) GlyphIndexByCID(cid uint16) (uint16, bool) {
+	if !f.isCID {
+		return cid, int(cid) < len(f.charstrings)
+	}
+	for gid, c := range f.charset {
+		if gid >= len(f.charstrings) {
+			break
+		}
+		if c == cid {
+			return uint16(gid), true
+		}
+	}
+	return 0, false
+}
+
+// LoadGlyph returns the outline of the glyph `gid`, in font units.
+func (f *Font) LoadGlyph(gid uint16) ([]ps.Segment, error) {
+	return f.loadGlyph(gid, true)
+}
+
+func (f *Font) loadGlyph(gid uint16, allowSeac bool) ([]ps.Segment, error) {
+	if int(gid) >= len(f.charstrings) {
+		return nil, fmt.Errorf("invalid glyph index %d", gid)
+	}
+	localSubrs := f.localSubrs
+	if f.isCID {
+		if int(gid) >= len(f.fdSelect) || int(f.fdSelect[gid]) >= len(f.fdSubrs) {
+			return nil, fmt.Errorf("invalid FDSelect for glyph index %d", gid)
+		}
+		localSubrs = f.fdSubrs[f.fdSelect[gid]]
+	}
+	var (
+		psi     ps.Machine
+		handler type2CharstringHandler
+	)
+	if err := psi.Run(f.charstrings[gid], localSubrs, f.globalSubrs, &handler); err != nil {
+		return nil, fmt.Errorf("invalid charstring for glyph index %d: %s", gid, err)
+	}
+	if handler.seac == nil {
+		return handler.cs.Segments, nil
+	}
+	if !allowSeac {
+		return nil, errors.New("invalid nested accented character")
+	}
+	return f.loadSeac(*handler.seac)
+}
+
+// loadSeac builds the accented character described by the
+// arguments of the (deprecated) 'seac' form of the endchar operator:
+// adx ady bchar achar, where the characters are given by their
+// codes in the standard encoding.
+func (f *Font) loadSeac(args [4]int32) ([]ps.Segment, error) {
+	var gids [2]uint16
+	for i, code := range args[2:] {
+		if code < 0 || code > 255 {
+			return nil, fmt.Errorf("invalid character code %d in accented character", code)
+		}
+		var ok bool
+		gids[i], ok = f.GlyphIndexByName(simpleencodings.AdobeStandard[code])
+		if !ok {
+			return nil, fmt.Errorf("missing glyph for character code %d in accented character", code)
+		}
+	}
+	out, err := f.loadGlyph(gids[0], false)
+	if err != nil {
+		return nil, err
+	}
+	accent, err := f.loadGlyph(gids[1], false)
+	if err != nil {
+		return nil, err
+	}
+	for _, seg := range accent {
+		for i := range seg.Args {
+			seg.Args[i].Move(args[0], args[1])
+		}
+		out = append(out, seg)
+	}
+	return out, nil
+}
+
+// type2CharstringHandler builds the outline of a glyph
+type type2CharstringHandler struct {
+	cs   ps.CharstringReader
+	seac *[4]int32 // adx ady bchar achar
+}
+
+func (type2CharstringHandler) Context() ps.PsContext { return ps.Type2Charstring }
+
+// Apply implements the operators defined by 5177.Type2.pdf Appendix A
+// "Type 2 Charstring Command Codes".
+func (h *type2CharstringHandler) Apply(op ps.PsOperator, state *ps.Machine) error {
+	var err error
+	if !op.IsEscaped {
+		switch op.Operator {
+		case 11: // return
+			return state.Return()
+		case 10: // callsubr
+			return h.cs.LocalSubr(state)
+		case 29: // callgsubr
+			return h.cs.GlobalSubr(state)
+		case 1, 3, 18, 23: // hstem, vstem, hstemhm, vstemhm
+			err = h.cs.Stem(state)
+		case 19, 20: // hintmask, cntrmask
+			err = h.cs.HintMask(state)
+		case 4:
+			err = h.cs.Vmoveto(state)
+		case 5:
+			err = h.cs.Rlineto(state)
+		case 6:
+			err = h.cs.Hlineto(state)
+		case 7:
+			err = h.cs.Vlineto(state)
+		case 8:
+			err = h.cs.Rrcurveto(state)
+		case 14: // endchar
+			return h.endchar(state)
+		case 21:
+			err = h.cs.Rmoveto(state)
+		case 22:
+			err = h.cs.Hmoveto(state)
+		case 24:
+			err = h.cs.Rcurveline(state)
+		case 25:
+			err = h.cs.Rlinecurve(state)
+		case 26:
+			err = h.cs.Vvcurveto(state)
+		case 27:
+			err = h.cs.Hhcurveto(state)
+		case 30:
+			err = h.cs.Vhcurveto(state)
+		case 31:
+			err = h.cs.Hvcurveto(state)
+		default:
+			return fmt.Errorf("invalid operator %s in Type2 charstring", op)
+		}
+	} else {
+		switch op.Operator {
+		case 0: // dotsection, deprecated
+		case 34:
+			err = h.cs.Hflex(state)
+		case 35:
+			err = h.cs.Flex(state)
+		case 36:
+			err = h.cs.Hflex1(state)
+		case 37:
+			err = h.cs.Flex1(state)
+		default: // arithmetic and storage operators are not supported
+			return fmt.Errorf("unsupported operator %s in Type2 charstring", op)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	state.ArgStack.Clear()
+	return nil
+}
+
+func (h *type2CharstringHandler) endchar(state *ps.Machine) error {
+	if state.ArgStack.Top >= 4 { // accented character
+		h.cs.ReadWidth(state, 4)
+	} else {
+		h.cs.ReadWidth(state, 0)
+	}
+	switch state.ArgStack.Top {
+	case 0:
+	case 4:
+		h.seac = &[4]int32{state.ArgStack.Vals[0], state.ArgStack.Vals[1], state.ArgStack.Vals[2], state.ArgStack.Vals[3]}
+	default:
+		return fmt.Errorf("invalid number of arguments for endchar: %d", state.ArgStack.Top)
+	}
+	h.cs.ClosePath()
+	return ps.ErrInterrupt
+}
@@ -82,7 +82,7 @@ type cffParser struct {
 	offset int    // current position
 }
 
-func (p *cffParser) parse() ([]*simpleencodings.Encoding, error) {
+func (p *cffParser) parse() ([]Font, error) {
 	// header was checked prior to this call
 
 	// Parse the Name INDEX.
@@ -108,40 +108,152 @@ func (p *cffParser) parse() ([]*simpleencodings.Encoding, error) {
 		return nil, err
 	}
 
-	out := make([]*simpleencodings.Encoding, len(topDicts))
+	out := make([]Font, len(topDicts))
 
 	// Parse the Global Subrs [Subroutines] INDEX,
 	// shared among all fonts.
-	_, err = p.parseIndex()
+	globalSubrs, err := p.parseIndex()
 	if err != nil {
 		return nil, err
 	}
 
 	for i, topDict := range topDicts {
+		font := &out[i]
+		font.globalSubrs = globalSubrs
+		font.strs = strs
+		font.isCID = topDict.isCID
+
 		// Parse the CharStrings INDEX, whose location was found in the Top DICT.
 		if err = p.seek(topDict.charStringsOffset); err != nil {
 			return nil, err
 		}
-		charstrings, err := p.parseIndex()
+		font.charstrings, err = p.parseIndex()
 		if err != nil {
 			return nil, err
 		}
-		numGlyphs := uint16(len(charstrings))
+		numGlyphs := uint16(len(font.charstrings))
 
-		charset, err := p.parseCharset(topDict.charsetOffset, numGlyphs)
+		font.charset, err = p.parseCharset(topDict.charsetOffset, numGlyphs)
 		if err != nil {
 			return nil, err
 		}
 
-		out[i], err = p.parseEncoding(topDict.encodingOffset, numGlyphs, charset, strs)
+		font.Encoding, err = p.parseEncoding(topDict.encodingOffset, numGlyphs, font.charset, strs)
 		if err != nil {
 			return nil, err
 		}
+
+		if topDict.isCID {
+			// the local subroutines are defined by the Private DICT
+			// of each font in the FDArray
+			font.fdSelect, err = p.parseFDSelect(topDict.fdSelect, numGlyphs)
+			if err != nil {
+				return nil, err
+			}
+			font.fdSubrs, err = p.parseFDArray(topDict.fdArray)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			font.localSubrs, err = p.parsePrivateDict(topDict.privateDictOffset, topDict.privateDictLength)
+			if err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	return out, nil
 }
 
+// parseFDArray parses the Font DICT INDEX of CID fonts,
+// and returns the local subroutines of each font.
+func (p *cffParser) parseFDArray(offset int32) ([][][]byte, error) {
+	if err := p.seek(offset); err != nil {
+		return nil, err
+	}
+	fontDicts, err := p.parseTopDicts()
+	if err != nil {
+		return nil, err
+	}
+	out := make([][][]byte, len(fontDicts))
+	for i, fontDict := range fontDicts {
+		out[i], err = p.parsePrivateDict(fontDict.privateDictOffset, fontDict.privateDictLength)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// parsePrivateDict parses the Private DICT, whose location was found in
+// a Top DICT (or a Font DICT), and returns its local subroutines.
+func (p *cffParser) parsePrivateDict(offset, length int32) ([][]byte, error) {
+	if length == 0 {
+		return nil, nil
+	}
+	if err := p.seek(offset); err != nil {
+		return nil, err
+	}
+	buf, err := p.read(int(length))
+	if err != nil {
+		return nil, err
+	}
+	var (
+		privateDict privateDictData
+		psi         ps.Machine
+	)
+	if err = psi.Run(buf, nil, nil, &privateDict); err != nil {
+		return nil, err
+	}
+	if privateDict.subrsOffset == 0 {
+		return nil, nil
+	}
+	// the Subrs offset is relative to the start of the Private DICT
+	if err = p.seek(offset + privateDict.subrsOffset); err != nil {
+		return nil, err
+	}
+	return p.parseIndex()
+}
+
+// parseFDSelect parses the FDSelect data, mapping each glyph
+// to a font of the FDArray.
+func (p *cffParser) parseFDSelect(offset int32, numGlyphs uint16) ([]byte, error) {
+	if err := p.seek(offset); err != nil {
+		return nil, err
+	}
+	buf, err := p.read(1)
+	if err != nil {
+		return nil, err
+	}
+	switch format := buf[0]; format {
+	case 0:
+		return p.read(int(numGlyphs))
+	case 3:
+		buf, err = p.read(2)
+		if err != nil {
+			return nil, err
+		}
+		nRanges := int(be.Uint16(buf))
+		buf, err = p.read(3*nRanges + 2) // ranges and sentinel
+		if err != nil {
+			return nil, err
+		}
+		out := make([]byte, numGlyphs)
+		for i := 0; i < nRanges; i++ {
+			first, fd, next := int(be.Uint16(buf[3*i:])), buf[3*i+2], int(be.Uint16(buf[3*i+3:]))
+			if first > next || next > int(numGlyphs) {
+				return nil, errors.New("invalid FDSelect ranges")
+			}
+			for gid := first; gid < next; gid++ {
+				out[gid] = fd
+			}
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("invalid FDSelect format %d", format)
+	}
+}
+
 func (p *cffParser) parseTopDicts() ([]topDictData, error) {
 	// Parse the Top DICT INDEX.
 	instructions, err := p.parseIndex()
@@ -452,6 +564,11 @@ type topDictData struct {
 
 	privateDictOffset int32
 	privateDictLength int32
+
+	// CID fonts
+	isCID    bool
+	fdArray  int32
+	fdSelect int32
 }
 
 func (topDict *topDictData) Context() ps.PsContext { return ps.TopDict }
@@ -542,14 +659,44 @@ var topDictOperators = [2][]topDictOperator{
 		21: {topDictNoOp, +1 /*PostScript*/},
 		22: {topDictNoOp, +1 /*BaseFontName*/},
 		23: {topDictNoOp, -2 /*BaseFontBlend*/},
-		30: {topDictNoOp, +3 /*ROS*/},
+		30: {func(t *topDictData, _ *ps.Machine) error {
+			t.isCID = true
+			return nil
+		}, +3 /*ROS*/},
 		31: {topDictNoOp, +1 /*CIDFontVersion*/},
 		32: {topDictNoOp, +1 /*CIDFontRevision*/},
 		33: {topDictNoOp, +1 /*CIDFontType*/},
 		34: {topDictNoOp, +1 /*CIDCount*/},
 		35: {topDictNoOp, +1 /*UIDBase*/},
-		36: {topDictNoOp, +1 /*FDArray*/},
-		37: {topDictNoOp, +1 /*FDSelect*/},
+		36: {func(t *topDictData, s *ps.Machine) error {
+			t.fdArray = s.ArgStack.Vals[s.ArgStack.Top-1]
+			return nil
+		}, +1 /*FDArray*/},
+		37: {func(t *topDictData, s *ps.Machine) error {
+			t.fdSelect = s.ArgStack.Vals[s.ArgStack.Top-1]
+			return nil
+		}, +1 /*FDSelect*/},
 		38: {topDictNoOp, +1 /*FontName*/},
 	},
 }
+
+// privateDictData contains fields specific to the Private DICT context.
+type privateDictData struct {
+	subrsOffset int32
+}
+
+func (privateDict *privateDictData) Context() ps.PsContext { return ps.PrivateDict }
+
+// The Private DICT operators are defined by 5176.CFF.pdf Table 23 "Private
+// DICT Operators". Only the Subrs operator is used: the hinting
+// parameters and the widths are ignored.
+func (privateDict *privateDictData) Apply(op ps.PsOperator, state *ps.Machine) error {
+	if !op.IsEscaped && op.Operator == 19 { // Subrs
+		if state.ArgStack.Top < 1 {
+			return fmt.Errorf("invalid number of arguments for operator %s in Private Dict", op)
+		}
+		privateDict.subrsOffset = state.ArgStack.Vals[state.ArgStack.Top-1]
+	}
+	state.ArgStack.Clear()
+	return nil
+}
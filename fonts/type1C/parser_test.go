@@ -40,7 +40,37 @@ func TestBulk(t *testing.T) {
 				i := rand.Intn(len(b))
 				b[i] = byte(rand.Intn(256))
 			}
-			_, _ = ParseEncoding(bytes.NewReader(b)) // we just check for crashes
+			// we just check for crashes
+			font, err := Parse(bytes.NewReader(b))
+			if err != nil {
+				continue
+			}
+			for gid := 0; gid < font.NumGlyphs(); gid++ {
+				_, _ = font.LoadGlyph(uint16(gid))
+			}
+		}
+	}
+}
+
+func TestLoadGlyphs(t *testing.T) {
+	for _, file := range []string{
+		"../test/AAAPKB+SourceSansPro-Bold.cff",
+		"../test/AdobeMingStd-Light-Identity-H.cff",
+		"../test/YPTQCA+CMR17.cff",
+	} {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		font, err := Parse(bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for gid := 0; gid < font.NumGlyphs(); gid++ {
+			_, err := font.LoadGlyph(uint16(gid))
+			if err != nil {
+				t.Fatal(err, "in", file)
+			}
 		}
 	}
 }
@@ -4,7 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"image/color"
-	"log"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -25,6 +25,7 @@ var defaultFont = &model.FontDict{
 
 type filler struct {
 	fontCache map[model.ObjName]fonts.BuiltFont
+	theme     *model.Theme // optional, default styling
 }
 
 func newFiller() filler {
@@ -81,7 +82,8 @@ func rotate(r model.Rectangle) model.Rectangle {
 	return model.Rectangle{Llx: r.Lly, Lly: r.Llx, Urx: r.Ury, Ury: r.Urx}
 }
 
-func (ac *filler) buildAppearance(formResources model.ResourcesDict, fields model.FormFieldInheritable, widget model.FormFieldWidget, text string) (*model.XObjectForm, int, error) {
+// `style` is optional and overrides the field DA and Q entries
+func (ac *filler) buildAppearance(formResources model.ResourcesDict, fields model.FormFieldInheritable, widget model.FormFieldWidget, text string, style *Style) (*model.XObjectForm, int, error) {
 	appBuilder := fieldAppearanceBuilder{}
 
 	// the text size and color
@@ -106,7 +108,7 @@ func (ac *filler) buildAppearance(formResources model.ResourcesDict, fields mode
 			} else { // build and cache
 				fd := formResources.Font[dab.font]
 				if fd == nil { // safely default to a standard font
-					log.Printf("can't resolve font %s -> using default", dab.font)
+					model.DefaultLogger().Log(model.LogWarning, "can't resolve font, using default", "font", dab.font)
 					fd = defaultFont
 				}
 				font, err = fonts.BuildFont(fd)
@@ -116,7 +118,7 @@ func (ac *filler) buildAppearance(formResources model.ResourcesDict, fields mode
 				ac.fontCache[dab.font] = font
 			}
 		} else {
-			log.Println("no font specified in DA string -> using default")
+			model.DefaultLogger().Log(model.LogWarning, "no font specified in DA string, using default")
 			// use a default font
 			font, err = fonts.BuildFont(defaultFont)
 			if err != nil {
@@ -126,20 +128,7 @@ func (ac *filler) buildAppearance(formResources model.ResourcesDict, fields mode
 		}
 	}
 
-	var annot model.AnnotationWidget
-	if widget.AnnotationDict != nil {
-		annot, _ = widget.Subtype.(model.AnnotationWidget)
-	}
-
-	// rotation, border and backgound color
-	if annot.MK != nil {
-		appBuilder.borderColor = annot.MK.BC.Color()
-		if appBuilder.borderColor != nil {
-			appBuilder.borderWidth = 1
-		}
-		appBuilder.backgroundColor = annot.MK.BG.Color()
-		appBuilder.rotation = annot.MK.R.Degrees()
-	}
+	appBuilder.setupWidget(widget, ac.theme)
 
 	// multiline
 
@@ -151,30 +140,24 @@ func (ac *filler) buildAppearance(formResources model.ResourcesDict, fields mode
 	// alignment
 	appBuilder.alignment = fields.Q
 
-	// border styles
-	if annot.BS != nil {
-		if bw, ok := annot.BS.W.(model.ObjFloat); ok {
-			appBuilder.borderWidth = Fl(bw)
-		}
-		appBuilder.borderStyle = annot.BS.S
-	} else if widget.AnnotationDict != nil {
-		if bd := widget.AnnotationDict.Border; bd != nil {
-			appBuilder.borderWidth = bd.BorderWidth
-			if bd.DashArray != nil {
-				appBuilder.borderStyle = "D"
+	if style != nil {
+		if style.FontSize != 0 {
+			fontSize = style.FontSize
+		}
+		if style.Color != nil {
+			appBuilder.textColor = style.Color
+		}
+		if style.Alignment != nil {
+			appBuilder.alignment = *style.Alignment
+		}
+		if style.Font != nil {
+			var err error
+			font, err = fonts.BuildFont(style.Font)
+			if err != nil {
+				return nil, 0, err
 			}
 		}
 	}
-	// rect
-	var rect model.Rectangle
-	if widget.AnnotationDict != nil {
-		rect = widget.AnnotationDict.Rect
-	}
-	box := getNormalizedRectangle(rect)
-	if appBuilder.rotation == 90 || appBuilder.rotation == 270 {
-		box = rotate(box)
-	}
-	appBuilder.box = box
 
 	switch fieldType := fields.FT.(type) {
 	case model.FormFieldText:
@@ -206,16 +189,17 @@ func (ac *filler) buildAppearance(formResources model.ResourcesDict, fields mode
 			appBuilder.text = text
 			return appBuilder.buildAppearance(font, fontSize), 0, nil
 		}
-		var idx int
-		for k, choiceExp := range choicesExp {
-			if text == choiceExp {
-				idx = k
-				break
+		selection := fieldType.I
+		if len(selection) == 0 { // use the text as single selection
+			for k, choiceExp := range choicesExp {
+				if text == choiceExp {
+					selection = []int{k}
+					break
+				}
 			}
 		}
 		appBuilder.choices = choices
-		// tx.choiceExports = choicesExp
-		appBuilder.choiceSelection = idx
+		appBuilder.choiceSelection = selection
 		app, topFirst := appBuilder.getListAppearance(font, fontSize)
 		return app, topFirst, nil
 	default:
@@ -223,15 +207,73 @@ func (ac *filler) buildAppearance(formResources model.ResourcesDict, fields mode
 	}
 }
 
+// setupWidget uses the rectangle, the border and the characteristics
+// of `widget` to setup the appearance builder.
+// The optional `theme` provides the colors and border missing in `widget`.
+func (b *fieldAppearanceBuilder) setupWidget(widget model.FormFieldWidget, theme *model.Theme) {
+	var annot model.AnnotationWidget
+	if widget.AnnotationDict != nil {
+		annot, _ = widget.Subtype.(model.AnnotationWidget)
+	}
+
+	// rotation, border and backgound color
+	if annot.MK != nil {
+		b.borderColor = annot.MK.BC.Color()
+		if b.borderColor != nil {
+			b.borderWidth = 1
+		}
+		b.backgroundColor = annot.MK.BG.Color()
+		b.rotation = annot.MK.R.Degrees()
+	}
+	if theme != nil {
+		if b.borderColor == nil && theme.BorderColor != nil {
+			b.borderColor = theme.BorderColor.Color()
+			b.borderWidth = 1
+		}
+		if b.backgroundColor == nil {
+			b.backgroundColor = theme.BackgroundColor.Color()
+		}
+	}
+
+	// border styles
+	if annot.BS != nil {
+		if bw, ok := annot.BS.W.(model.ObjFloat); ok {
+			b.borderWidth = Fl(bw)
+		}
+		b.borderStyle = annot.BS.S
+	} else if widget.AnnotationDict != nil && widget.AnnotationDict.Border != nil {
+		bd := widget.AnnotationDict.Border
+		b.borderWidth = bd.BorderWidth
+		if bd.DashArray != nil {
+			b.borderStyle = "D"
+		}
+	} else if theme != nil && theme.Border != nil {
+		if bw, ok := theme.Border.W.(model.ObjFloat); ok {
+			b.borderWidth = Fl(bw)
+		}
+		b.borderStyle = theme.Border.S
+	}
+	// rect
+	var rect model.Rectangle
+	if widget.AnnotationDict != nil {
+		rect = widget.AnnotationDict.Rect
+	}
+	box := getNormalizedRectangle(rect)
+	if b.rotation == 90 || b.rotation == 270 {
+		box = rotate(box)
+	}
+	b.box = box
+}
+
 // buildWidgets update item
-func (ac filler) buildWidgets(formResources model.ResourcesDict, field model.FormFieldInherited, display string) (int, error) {
+func (ac filler) buildWidgets(formResources model.ResourcesDict, field model.FormFieldInherited, display string, style *Style) (int, error) {
 	var topFirst int
 	for _, widget := range field.Field.Widgets {
 		var (
 			app *model.XObjectForm
 			err error
 		)
-		app, topFirst, err = ac.buildAppearance(formResources, field.Merged, widget, display)
+		app, topFirst, err = ac.buildAppearance(formResources, field.Merged, widget, display, style)
 		if err != nil {
 			return 0, err
 		}
@@ -250,6 +292,14 @@ func (ac filler) setField(formResources model.ResourcesDict, field model.FormFie
 	field.Field.RV = values.RV
 	switch type_ := field.Merged.FT.(type) {
 	case model.FormFieldText:
+		style := mergeStyles(themeStyle(ac.theme), values.Style)
+		if field.Merged.Ff&model.RichText != 0 {
+			if values.V == nil && values.RV != "" {
+				values.V = FDFText(richTextToPlain(values.RV))
+			}
+			// approximate the rich text default style
+			style = mergeStyles(mergeStyles(themeStyle(ac.theme), parseDefaultStyle(field.Field.DS)), values.Style)
+		}
 		value, ok := values.V.(FDFText)
 		if !ok {
 			return fmt.Errorf("unexpected value type for text field: expected FDFText, got %T", values.V)
@@ -259,25 +309,54 @@ func (ac filler) setField(formResources model.ResourcesDict, field model.FormFie
 			value = FDFText(asRunes[0:min(int(ml), len(asRunes))])
 		}
 		type_.V = string(value)
-		_, err := ac.buildWidgets(formResources, field, string(value))
+		isBarcode, err := buildBarcodeWidgets(field.Field, type_, string(value), ac.theme)
 		if err != nil {
 			return err
 		}
+		if !isBarcode {
+			if _, err = ac.buildWidgets(formResources, field, string(value), style); err != nil {
+				return err
+			}
+		}
 		field.Field.FT = type_ // update
 	case model.FormFieldChoice:
+		var selected []string
 		switch value := values.V.(type) {
 		case FDFChoices:
-			type_.V = []string(value)
+			selected = value
 		case FDFText: // only one selection
-			type_.V = []string{string(value)}
+			selected = []string{string(value)}
 		default:
 			return fmt.Errorf("unexpected value type for choices field: expected FDFChoices or FDFText, got %T", values.V)
 		}
+		flags := field.Merged.Ff
+		if len(selected) > 1 && flags&model.MultiSelect == 0 {
+			return fmt.Errorf("multiple values %v for a choice field without multiple selection", selected)
+		}
 		// ssteward; it might disagree w/ V in a Ch widget
-		// PDF spec this shouldn't matter, but Reader 9 gives I precedence over V
-		type_.I = nil
-		display := strings.Join(type_.V, ", ")
-		topFirst, err := ac.buildWidgets(formResources, field, display)
+		// PDF spec this shouldn't matter, but Reader 9 gives I precedence over V,
+		// so that I is always updated to match V
+		editable := flags&model.Combo != 0 && flags&model.Edit != 0
+		type_.V, type_.I = nil, nil
+		displays := make([]string, len(selected))
+		for i, value := range selected {
+			index := optionIndex(type_.Opt, value)
+			if index == -1 {
+				if len(type_.Opt) != 0 && !editable {
+					return fmt.Errorf("invalid value %s for choice field (expected one of %v)", value, exportValues(type_.Opt))
+				}
+				type_.V = append(type_.V, value)
+				displays[i] = value
+				continue
+			}
+			opt := type_.Opt[index]
+			type_.V = append(type_.V, exportValue(opt))
+			type_.I = append(type_.I, index)
+			displays[i] = opt.Name
+		}
+		sort.Ints(type_.I)
+		field.Merged.FT = type_ // the appearance uses the selected indices
+		topFirst, err := ac.buildWidgets(formResources, field, strings.Join(displays, ", "), mergeStyles(themeStyle(ac.theme), values.Style))
 		if err != nil {
 			return err
 		}
@@ -292,7 +371,11 @@ func (ac filler) setField(formResources model.ResourcesDict, field model.FormFie
 		if (flags & model.Pushbutton) != 0 {
 			return nil
 		}
-		v := model.ObjName(value)
+		addOffAppearances(field.Field, ac.theme)
+		v, ok := matchState(field.Field, model.ObjName(value), type_.Opt)
+		if !ok {
+			return InvalidStateErr{Value: model.ObjName(value), Valid: validStates(field.Field)}
+		}
 		if (flags & model.Radio) == 0 {
 			type_.V = v
 			setStateAS(field.Field, v)
@@ -315,6 +398,106 @@ func (ac filler) setField(formResources model.ResourcesDict, field model.FormFie
 	return nil
 }
 
+// InvalidStateErr is returned when the value provided
+// for a check box or a radio button does not match
+// any of the appearance states of the field widgets.
+type InvalidStateErr struct {
+	Field string       // fully qualified name
+	Value model.Name   // the invalid value
+	Valid []model.Name // the accepted states
+}
+
+func (is InvalidStateErr) Error() string {
+	return fmt.Sprintf("invalid state %s for button field %s (expected one of %v)", is.Value, is.Field, is.Valid)
+}
+
+// normalizeState decodes the #xx escape sequences and removes
+// the trailing dots, which are sometimes inconsistently used
+// by PDF writers.
+func normalizeState(state model.Name) string {
+	var out []byte
+	for i := 0; i < len(state); i++ {
+		if state[i] == '#' && i+2 < len(state) {
+			if c, err := strconv.ParseUint(string(state[i+1:i+3]), 16, 8); err == nil {
+				out = append(out, byte(c))
+				i += 2
+				continue
+			}
+		}
+		out = append(out, state[i])
+	}
+	return strings.TrimRight(string(out), ".")
+}
+
+// validStates returns the 'on' states of the field widgets
+func validStates(field *model.FormFieldDict) []model.Name {
+	var out []model.Name
+	for _, key := range field.AppearanceKeys() {
+		if key != "" && key != "Off" {
+			out = append(out, key)
+		}
+	}
+	return out
+}
+
+// matchState returns the appearance state matching `value`, and false
+// if `value` is not a valid state for the field.
+// The Off state and the export values in `opts` are always accepted,
+// as well as any value when the widgets have no appearance states.
+func matchState(field *model.FormFieldDict, value model.Name, opts []string) (model.Name, bool) {
+	if value == "Off" {
+		return value, true
+	}
+	for _, opt := range opts {
+		if opt == string(value) {
+			return value, true
+		}
+	}
+	valid := validStates(field)
+	if len(valid) == 0 {
+		return value, true
+	}
+	normalized := normalizeState(value)
+	for _, state := range valid {
+		if state == value || normalizeState(state) == normalized {
+			return state, true
+		}
+	}
+	return value, false
+}
+
+// exportValue returns the value used in V for the option
+func exportValue(opt model.Option) string {
+	if opt.Export != "" {
+		return opt.Export
+	}
+	return opt.Name
+}
+
+func exportValues(opts []model.Option) []string {
+	out := make([]string, len(opts))
+	for i, opt := range opts {
+		out[i] = exportValue(opt)
+	}
+	return out
+}
+
+// optionIndex returns the index of the option with export value `value`,
+// using the displayed text as fallback, or -1 if not found.
+func optionIndex(opts []model.Option, value string) int {
+	for i, opt := range opts {
+		if exportValue(opt) == value {
+			return i
+		}
+	}
+	for i, opt := range opts {
+		if opt.Name == value {
+			return i
+		}
+	}
+	return -1
+}
+
 func setStateAS(field *model.FormFieldDict, state model.ObjName) {
 	for _, widget := range field.Widgets {
 		if isInAP(widget, state) {
@@ -325,6 +508,27 @@ func setStateAS(field *model.FormFieldDict, state model.ObjName) {
 	}
 }
 
+// addOffAppearances adds an Off appearance to the widgets
+// of `field` which only define "on" states, so that
+// unchecking the field renders correctly.
+// The synthesized appearance only draws the background and the border.
+// Widgets with a single appearance stream (without states) are left unchanged.
+func addOffAppearances(field *model.FormFieldDict, theme *model.Theme) {
+	for _, widget := range field.Widgets {
+		if widget.AnnotationDict == nil || widget.AP == nil || len(widget.AP.N) == 0 || widget.AP.N.IsStream() || widget.AP.N["Off"] != nil {
+			continue
+		}
+		var b fieldAppearanceBuilder
+		b.setupWidget(widget, theme)
+		app := b.getBorderAppearance()
+		off := app.ToXFormObject(true)
+		widget.AP.N["Off"] = off
+		if len(widget.AP.D) != 0 && widget.AP.D["Off"] == nil {
+			widget.AP.D["Off"] = off
+		}
+	}
+}
+
 func isInAP(widget model.FormFieldWidget, check model.ObjName) bool {
 	if widget.AP == nil {
 		return false
@@ -344,6 +548,10 @@ func (ac filler) fillForm(acro *model.AcroForm, fdf FDFDict, lockForm bool) erro
 		if acroValue, ok := fields[fullName]; ok {
 			// match with value, do fill the field
 			err := ac.setField(acro.DR, acroValue, fdfValue)
+			if stateErr, ok := err.(InvalidStateErr); ok {
+				stateErr.Field = fullName
+				return stateErr
+			}
 			if err != nil {
 				return err
 			}
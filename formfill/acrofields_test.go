@@ -3,7 +3,11 @@ package formfill
 import (
 	"image/color"
 	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/benoitkugler/pdf/model"
+	"github.com/benoitkugler/pdf/reader"
 )
 
 func TestDAParse(t *testing.T) {
@@ -29,3 +33,252 @@ func TestDAParse(t *testing.T) {
 		}
 	}
 }
+
+func TestFillStyle(t *testing.T) {
+	doc, _, err := reader.ParsePDFFile("test/sample1.pdf", reader.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	field := doc.Catalog.AcroForm.Flatten()["z1"]
+	da := field.Merged.DA
+
+	centered := model.Centered
+	style := &Style{FontSize: 5.5, Color: color.NRGBA{R: 255, A: 255}, Alignment: &centered}
+	err = FillForm(&doc, FDFDict{Fields: []FDFField{
+		{T: "z1", Values: Values{V: FDFText("879-sde9-898"), Style: style}},
+	}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if field.Merged.DA != da || field.Field.DA != da {
+		t.Fatalf("DA should not be modified")
+	}
+	app := string(field.Field.Widgets[0].AP.N[""].Content)
+	if !strings.Contains(app, " 5.5 Tf") || !strings.Contains(app, "1 0 0 rg") {
+		t.Fatalf("style not applied: %s", app)
+	}
+}
+
+func TestButtonStates(t *testing.T) {
+	if s := normalizeState("AUT#20CAS."); s != "AUT CAS" {
+		t.Fatalf("unexpected normalized state %s", s)
+	}
+
+	doc, _, err := reader.ParsePDFFile("test/sample4.pdf", reader.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = FillForm(&doc, FDFDict{Fields: []FDFField{
+		{T: "MED.3", Values: Values{V: FDFName("AUT CAS")}},
+		{T: "SOR B", Values: Values{V: FDFName("Oui.")}},
+		{T: "INI.4", Values: Values{V: FDFName("Off")}},
+	}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := doc.Catalog.AcroForm.Flatten()
+	if v := fields["MED.3"].Field.FT.(model.FormFieldButton).V; v != "AUT#20CAS" {
+		t.Fatalf("unexpected value %s", v)
+	}
+	if v := fields["SOR B"].Field.FT.(model.FormFieldButton).V; v != "Oui" {
+		t.Fatalf("unexpected value %s", v)
+	}
+
+	err = FillForm(&doc, FDFDict{Fields: []FDFField{
+		{T: "SOR B", Values: Values{V: FDFName("Maybe")}},
+	}}, false)
+	stateErr, ok := err.(InvalidStateErr)
+	if !ok {
+		t.Fatalf("expected InvalidStateErr, got %v", err)
+	}
+	if stateErr.Field != "SOR B" || !reflect.DeepEqual(stateErr.Valid, []model.Name{"NON", "Oui"}) {
+		t.Fatalf("unexpected error %v", stateErr)
+	}
+}
+
+func TestFillChoices(t *testing.T) {
+	opts := []model.Option{{Name: "Red"}, {Export: "g", Name: "Green"}, {Export: "b", Name: "Blue"}}
+	newField := func(name string, flags model.FormFlag) *model.FormFieldDict {
+		return &model.FormFieldDict{
+			T: name,
+			FormFieldInheritable: model.FormFieldInheritable{
+				FT: model.FormFieldChoice{Opt: opts}, Ff: flags, DA: "/Helv 10 Tf 0 g",
+			},
+			Widgets: []model.FormFieldWidget{{AnnotationDict: &model.AnnotationDict{
+				BaseAnnotation: model.BaseAnnotation{Rect: model.Rectangle{Urx: 100, Ury: 60}},
+				Subtype:        model.AnnotationWidget{},
+			}}},
+		}
+	}
+	list, combo := newField("list", model.MultiSelect), newField("combo", model.Combo|model.Edit)
+	var doc model.Document
+	doc.Catalog.AcroForm.Fields = []*model.FormFieldDict{list, combo, newField("single", 0)}
+	doc.Catalog.AcroForm.DR.Font = map[model.ObjName]*model.FontDict{"Helv": defaultFont}
+
+	err := FillForm(&doc, FDFDict{Fields: []FDFField{
+		{T: "list", Values: Values{V: FDFChoices{"b", "Red"}}},
+		{T: "combo", Values: Values{V: FDFText("Purple")}},
+	}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := list.FT.(model.FormFieldChoice)
+	if !reflect.DeepEqual(got.V, []string{"b", "Red"}) || !reflect.DeepEqual(got.I, []int{0, 2}) {
+		t.Fatalf("unexpected values %v %v", got.V, got.I)
+	}
+	// both selected rows are highlighted
+	content, err := list.Widgets[0].AP.N[""].Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if app := string(content); strings.Count(app, " re") != 3 { // clip and two selections
+		t.Fatalf("unexpected list appearance %s", app)
+	}
+	if got := combo.FT.(model.FormFieldChoice); !reflect.DeepEqual(got.V, []string{"Purple"}) || len(got.I) != 0 {
+		t.Fatalf("unexpected values %v %v", got.V, got.I)
+	}
+
+	// invalid inputs
+	for _, field := range []FDFField{
+		{T: "single", Values: Values{V: FDFChoices{"b", "g"}}},
+		{T: "single", Values: Values{V: FDFText("Purple")}},
+	} {
+		if err := FillForm(&doc, FDFDict{Fields: []FDFField{field}}, false); err == nil {
+			t.Fatalf("expected error for %v", field.Values.V)
+		}
+	}
+}
+
+func TestFillRichText(t *testing.T) {
+	field := &model.FormFieldDict{
+		T:  "rich",
+		DS: "font: bold 14pt 'Times New Roman', serif; color: #FF0000; text-align: center",
+		FormFieldInheritable: model.FormFieldInheritable{
+			FT: model.FormFieldText{}, Ff: model.RichText | model.Multiline, DA: "/Helv 10 Tf 0 g",
+		},
+		Widgets: []model.FormFieldWidget{{AnnotationDict: &model.AnnotationDict{
+			BaseAnnotation: model.BaseAnnotation{Rect: model.Rectangle{Urx: 200, Ury: 60}},
+			Subtype:        model.AnnotationWidget{},
+		}}},
+	}
+	var doc model.Document
+	doc.Catalog.AcroForm.Fields = []*model.FormFieldDict{field}
+	doc.Catalog.AcroForm.DR.Font = map[model.ObjName]*model.FontDict{"Helv": defaultFont}
+
+	rv := `<?xml version="1.0"?><body xmlns="http://www.w3.org/1999/xhtml"><p>Hello <b>rich</b> &amp; bold</p><p>second<br/>line</p></body>`
+	err := FillForm(&doc, FDFDict{Fields: []FDFField{{T: "rich", Values: Values{RV: rv}}}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if field.RV != rv {
+		t.Fatalf("unexpected RV %s", field.RV)
+	}
+	if v := field.FT.(model.FormFieldText).V; v != "Hello rich & bold\nsecond\nline" {
+		t.Fatalf("unexpected plain text %q", v)
+	}
+
+	app := field.Widgets[0].AP.N[""]
+	content, err := app.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(content); !strings.Contains(s, " 14 Tf") || !strings.Contains(s, "1 0 0 rg") {
+		t.Fatalf("unexpected appearance %s", s)
+	}
+	if len(app.Resources.Font) != 1 {
+		t.Fatalf("unexpected fonts %v", app.Resources.Font)
+	}
+	for _, font := range app.Resources.Font {
+		if got := font.Subtype.(model.FontType1).BaseFont; got != "Times-Bold" {
+			t.Fatalf("unexpected font %s", got)
+		}
+	}
+}
+
+func TestOffAppearance(t *testing.T) {
+	on := &model.XObjectForm{}
+	widget := &model.AnnotationDict{
+		BaseAnnotation: model.BaseAnnotation{
+			Rect: model.Rectangle{Urx: 20, Ury: 20},
+			AP:   &model.AppearanceDict{N: model.AppearanceEntry{"Yes": on}},
+		},
+		Subtype: model.AnnotationWidget{MK: &model.AppearanceCharacteristics{BC: []Fl{1, 0, 0}}},
+	}
+	field := &model.FormFieldDict{
+		T:                    "check",
+		FormFieldInheritable: model.FormFieldInheritable{FT: model.FormFieldButton{}},
+		Widgets:              []model.FormFieldWidget{{AnnotationDict: widget}},
+	}
+	var doc model.Document
+	doc.Catalog.AcroForm.Fields = []*model.FormFieldDict{field}
+
+	err := FillForm(&doc, FDFDict{Fields: []FDFField{{T: "check", Values: Values{V: FDFName("Off")}}}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if widget.AS != "Off" {
+		t.Fatalf("unexpected state %s", widget.AS)
+	}
+	off := widget.AP.N["Off"]
+	if off == nil || widget.AP.N["Yes"] != on {
+		t.Fatalf("unexpected appearances %v", widget.AP.N)
+	}
+	content, err := off.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "1 0 0 RG") { // border only
+		t.Fatalf("unexpected Off appearance %s", content)
+	}
+}
+
+func TestFillTheme(t *testing.T) {
+	widget := &model.AnnotationDict{
+		BaseAnnotation: model.BaseAnnotation{Rect: model.Rectangle{Urx: 100, Ury: 20}},
+		Subtype:        model.AnnotationWidget{},
+	}
+	field := &model.FormFieldDict{
+		T: "name",
+		FormFieldInheritable: model.FormFieldInheritable{
+			FT: model.FormFieldText{},
+			DA: "/Helv 0 Tf 0 g",
+		},
+		Widgets: []model.FormFieldWidget{{AnnotationDict: widget}},
+	}
+	var doc model.Document
+	doc.Catalog.AcroForm.Fields = []*model.FormFieldDict{field}
+	doc.Theme = &model.Theme{
+		FontSize:        9,
+		TextColor:       model.Color{0, 0, 1},
+		BorderColor:     model.Color{1, 0, 0},
+		BackgroundColor: model.Color{0, 1, 0},
+	}
+
+	err := FillForm(&doc, FDFDict{Fields: []FDFField{{T: "name", Values: Values{V: FDFText("Alice")}}}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := widget.AP.N[""].Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, exp := range []string{"1 0 0 RG", "0 1 0 rg", "0 0 1 rg", " 9 Tf"} {
+		if !strings.Contains(string(content), exp) {
+			t.Fatalf("expected %s in appearance %s", exp, content)
+		}
+	}
+
+	// explicit styles take precedence
+	err = FillForm(&doc, FDFDict{Fields: []FDFField{{T: "name", Values: Values{V: FDFText("Alice"), Style: &Style{FontSize: 7}}}}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err = widget.AP.N[""].Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), " 7 Tf") {
+		t.Fatalf("unexpected appearance %s", content)
+	}
+}
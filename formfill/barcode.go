@@ -0,0 +1,160 @@
+package formfill
+
+import (
+	"errors"
+	"fmt"
+
+	cs "github.com/benoitkugler/pdf/contentstream"
+	"github.com/benoitkugler/pdf/fonts"
+	"github.com/benoitkugler/pdf/formfill/barcode"
+	"github.com/benoitkugler/pdf/model"
+)
+
+var errUnsupportedSymbology = errors.New("unsupported barcode symbology")
+
+// captionSize is the font size used for the barcode captions
+const captionSize = 8
+
+// encodeBarcode encodes `value` (as UTF-8) with the symbology of `pmd`
+func encodeBarcode(pmd model.PaperMetaData, value string) (barcode.Matrix, error) {
+	switch pmd.Symbology {
+	case model.SymbologyQRCode:
+		level := barcode.QRMedium
+		if ecc, ok := pmd.ECC.(model.ObjInt); ok && 0 <= ecc && ecc <= 3 {
+			level = barcode.QRLevel(ecc)
+		}
+		return barcode.QRCode([]byte(value), level)
+	case model.SymbologyDataMatrix:
+		return barcode.DataMatrix([]byte(value))
+	default: // PDF417 is not supported
+		return barcode.Matrix{}, errUnsupportedSymbology
+	}
+}
+
+// barcodeAppearance draws `matrix` centered in the widget,
+// using the module size of `pmd` if it fits, and the value as caption if required.
+func barcodeAppearance(widget model.FormFieldWidget, pmd model.PaperMetaData, matrix barcode.Matrix, value string, theme *model.Theme) (*model.XObjectForm, error) {
+	var builder fieldAppearanceBuilder
+	builder.setupWidget(widget, theme)
+	app := builder.getBorderAppearance()
+
+	margin := maxF(builder.borderWidth, 1)
+	if builder.borderStyle == "B" || builder.borderStyle == "I" {
+		margin *= 2
+	}
+	width, height := builder.box.Width()-2*margin, builder.box.Height()-2*margin
+
+	var (
+		font    fonts.BuiltFont
+		caption []rune
+	)
+	if pmd.Caption && value != "" {
+		var err error
+		font, err = fonts.BuildFont(defaultFont)
+		if err != nil {
+			return nil, err
+		}
+		caption = []rune(value)
+		height -= captionSize * 1.2
+	}
+
+	// the quiet zone is 4 modules for QR codes and 1 for Data Matrix
+	quiet := 1
+	if pmd.Symbology == model.SymbologyQRCode {
+		quiet = 4
+	}
+	module := minF(width/Fl(matrix.Width+2*quiet), height/Fl(matrix.Height+2*quiet))
+	if x, ok := pmd.X.(model.ObjInt); ok && x > 0 {
+		resolution := Fl(300)
+		if r, ok := pmd.Resolution.(model.ObjInt); ok && r > 0 {
+			resolution = Fl(r)
+		}
+		module = minF(module, Fl(x)*72/resolution)
+	}
+	if module <= 0 {
+		return app.ToXFormObject(true), nil
+	}
+
+	x0 := margin + (width-module*Fl(matrix.Width))/2
+	y0 := builder.box.Height() - margin - (height-module*Fl(matrix.Height))/2 // top of the barcode
+	app.SaveState()
+	app.Ops(cs.OpSetFillGray{})
+	for y := 0; y < matrix.Height; y++ {
+		for x := 0; x < matrix.Width; {
+			if !matrix.At(x, y) {
+				x++
+				continue
+			}
+			// merge the horizontal runs of dark modules
+			start := x
+			for x < matrix.Width && matrix.At(x, y) {
+				x++
+			}
+			app.Ops(cs.OpRectangle{
+				X: x0 + Fl(start)*module, Y: y0 - Fl(y+1)*module,
+				W: Fl(x-start) * module, H: module,
+			})
+		}
+	}
+	app.Ops(cs.OpFill{})
+
+	if caption != nil {
+		textWidth := fonts.TextWidth(font, caption, captionSize)
+		app.BeginText()
+		app.SetFontAndSize(font, captionSize)
+		app.MoveText(maxF(margin, (builder.box.Width()-textWidth)/2), margin-font.Desc().Descent*captionSize/1000)
+		if err := app.ShowText(value); err != nil {
+			return nil, err
+		}
+		app.EndText()
+	}
+	_ = app.RestoreState() // the calls are balanced
+	return app.ToXFormObject(true), nil
+}
+
+// buildBarcodeWidgets updates the appearances of the widgets of `field`,
+// a barcode field, so that they display `value`.
+// It returns false if `text` is not a barcode field, or if its symbology is not supported.
+func buildBarcodeWidgets(field *model.FormFieldDict, text model.FormFieldText, value string, theme *model.Theme) (bool, error) {
+	if text.PMD == nil {
+		return false, nil
+	}
+	matrix, err := encodeBarcode(*text.PMD, value)
+	if err == errUnsupportedSymbology {
+		model.DefaultLogger().Log(model.LogWarning, "unsupported barcode symbology, using a text appearance",
+			"symbology", text.PMD.Symbology)
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	for _, widget := range field.Widgets {
+		app, err := barcodeAppearance(widget, *text.PMD, matrix, value, theme)
+		if err != nil {
+			return false, err
+		}
+		appDic := widget.AP
+		if appDic == nil {
+			appDic = new(model.AppearanceDict)
+		}
+		appDic.N = model.AppearanceEntry{"": app}
+		widget.AP = appDic // update the model
+	}
+	return true, nil
+}
+
+// UpdateBarcodes regenerates the appearances of the barcode fields of `doc`
+// (text fields with a PMD entry, see model.PaperMetaData) from their current value.
+// QR codes and Data Matrix are supported: the fields using
+// another symbology (PDF417) are left unchanged.
+func UpdateBarcodes(doc *model.Document) error {
+	for name, field := range doc.Catalog.AcroForm.Flatten() {
+		text, ok := field.Merged.FT.(model.FormFieldText)
+		if !ok || text.PMD == nil {
+			continue
+		}
+		if _, err := buildBarcodeWidgets(field.Field, text, text.V, doc.Theme); err != nil {
+			return fmt.Errorf("barcode field %s: %s", name, err)
+		}
+	}
+	return nil
+}
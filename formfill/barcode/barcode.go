@@ -0,0 +1,114 @@
+// Package barcode encodes data into 2D barcodes, as used by the barcode
+// form fields (see model.PaperMetaData).
+//
+// QR Code (ISO/IEC 18004) and Data Matrix ECC 200 (ISO/IEC 16022) are supported,
+// using respectively the byte mode and the ASCII encodation.
+// PDF417 is not supported.
+package barcode
+
+import "errors"
+
+// ErrTooLong is returned when the data does not fit in the largest symbol
+var ErrTooLong = errors.New("data too long for the barcode capacity")
+
+// Matrix is a 2D barcode, made of square modules,
+// without the surrounding quiet zone.
+type Matrix struct {
+	Width, Height int
+	modules       []bool // row major, true for dark modules
+}
+
+func newMatrix(width, height int) Matrix {
+	return Matrix{Width: width, Height: height, modules: make([]bool, width*height)}
+}
+
+// At returns true if the module at column `x` and row `y`
+// (starting from the top left corner) is dark.
+func (m Matrix) At(x, y int) bool {
+	return m.modules[y*m.Width+x]
+}
+
+func (m Matrix) set(x, y int, dark bool) {
+	m.modules[y*m.Width+x] = dark
+}
+
+// String returns a text representation of the matrix,
+// with '#' for dark modules, mainly for debugging purposes.
+func (m Matrix) String() string {
+	out := make([]byte, 0, (m.Width+1)*m.Height)
+	for y := 0; y < m.Height; y++ {
+		for x := 0; x < m.Width; x++ {
+			if m.At(x, y) {
+				out = append(out, '#')
+			} else {
+				out = append(out, ' ')
+			}
+		}
+		out = append(out, '\n')
+	}
+	return string(out)
+}
+
+// ------------------------- Reed-Solomon -------------------------
+
+// galoisField is the finite field GF(256) defined by a primitive polynomial
+type galoisField struct {
+	exp [510]byte
+	log [256]byte
+}
+
+func newGaloisField(poly int) *galoisField {
+	var gf galoisField
+	x := 1
+	for i := 0; i < 255; i++ {
+		gf.exp[i] = byte(x)
+		gf.log[x] = byte(i)
+		x <<= 1
+		if x >= 256 {
+			x ^= poly
+		}
+	}
+	for i := 255; i < len(gf.exp); i++ {
+		gf.exp[i] = gf.exp[i-255]
+	}
+	return &gf
+}
+
+func (gf *galoisField) mul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gf.exp[int(gf.log[a])+int(gf.log[b])]
+}
+
+// generator returns the coefficients (highest degree first, without the leading 1)
+// of the generator polynomial with roots α^first, ..., α^(first+degree-1)
+func (gf *galoisField) generator(degree, first int) []byte {
+	out := make([]byte, degree)
+	out[degree-1] = 1
+	root := gf.exp[first]
+	for i := 0; i < degree; i++ {
+		for j := range out {
+			out[j] = gf.mul(out[j], root)
+			if j+1 < len(out) {
+				out[j] ^= out[j+1]
+			}
+		}
+		root = gf.mul(root, 2)
+	}
+	return out
+}
+
+// remainder returns the error correction codewords of `data`
+func (gf *galoisField) remainder(data, generator []byte) []byte {
+	out := make([]byte, len(generator))
+	for _, b := range data {
+		factor := b ^ out[0]
+		copy(out, out[1:])
+		out[len(out)-1] = 0
+		for i, g := range generator {
+			out[i] ^= gf.mul(g, factor)
+		}
+	}
+	return out
+}
@@ -0,0 +1,186 @@
+package barcode
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// example of the QR code specification (1-M, "HELLO WORLD")
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	ecc := qrField.remainder(data, qrField.generator(10, 0))
+	if exp := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}; !bytes.Equal(ecc, exp) {
+		t.Fatalf("expected %v, got %v", exp, ecc)
+	}
+
+	// example of the Data Matrix specification ("123456")
+	data = dmEncodeASCII([]byte("123456"))
+	if exp := []byte{142, 164, 186}; !bytes.Equal(data, exp) {
+		t.Fatalf("expected %v, got %v", exp, data)
+	}
+	ecc = dataMatrixField.remainder(data, dataMatrixField.generator(5, 1))
+	if exp := []byte{114, 25, 5, 88, 102}; !bytes.Equal(ecc, exp) {
+		t.Fatalf("expected %v, got %v", exp, ecc)
+	}
+}
+
+func TestQRFormat(t *testing.T) {
+	if bits := qrFormatBits(QRLow, 0); bits != 0x77C4 {
+		t.Fatalf("unexpected format bits %015b", bits)
+	}
+	if bits := qrFormatBits(QRHigh, 7); bits != 0x083B {
+		t.Fatalf("unexpected format bits %015b", bits)
+	}
+	for version := 1; version <= 40; version++ {
+		if qrRawModules(version)%8 > 7 || qrDataCodewords(version, QRHigh) <= 0 {
+			t.Fatalf("invalid capacity for version %d", version)
+		}
+	}
+	if n := qrDataCodewords(40, QRLow); n != 2956 {
+		t.Fatalf("unexpected capacity %d", n)
+	}
+}
+
+// qrReadCodewords reads back the (interleaved) codewords of `m`
+func qrReadCodewords(t *testing.T, m Matrix, level QRLevel) []byte {
+	version := (m.Width - 17) / 4
+	qr := qrSymbol{Matrix: newMatrix(m.Width, m.Height), isFunction: make([]bool, m.Width*m.Height)}
+	qr.drawFunctionPatterns(version)
+
+	// read the format, around the top left finder
+	var bits int
+	for i := 0; i <= 5; i++ {
+		if m.At(8, i) {
+			bits |= 1 << uint(i)
+		}
+	}
+	for i, pos := range [3][2]int{{8, 7}, {8, 8}, {7, 8}} {
+		if m.At(pos[0], pos[1]) {
+			bits |= 1 << uint(6+i)
+		}
+	}
+	for i := 9; i < 15; i++ {
+		if m.At(14-i, 8) {
+			bits |= 1 << uint(i)
+		}
+	}
+	mask := -1
+	for candidate := 0; candidate < 8; candidate++ {
+		if qrFormatBits(level, candidate) == bits {
+			mask = candidate
+		}
+	}
+	if mask == -1 {
+		t.Fatalf("invalid format bits %015b", bits)
+	}
+
+	var out bitBuffer
+	size := m.Width
+	for right := size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < size; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = size - 1 - vert
+				}
+				if !qr.isFunction[y*size+x] {
+					out = append(out, m.At(x, y) != qrMask(mask, x, y))
+				}
+			}
+		}
+	}
+	return out.bytes()[:qrRawModules(version)/8]
+}
+
+func TestQRCode(t *testing.T) {
+	for _, level := range []QRLevel{QRLow, QRMedium, QRQuartile, QRHigh} {
+		for _, data := range [][]byte{
+			[]byte("https://github.com/benoitkugler/pdf"),
+			bytes.Repeat([]byte("barcode field value "), 30),
+		} {
+			m, err := QRCode(data, level)
+			if err != nil {
+				t.Fatal(err)
+			}
+			version := (m.Width - 17) / 4
+			if m.Width != m.Height || m.Width != 4*version+17 {
+				t.Fatalf("invalid size %d", m.Width)
+			}
+			codewords, _, _ := qrEncodeData(data, level)
+			exp := qrAddECC(codewords, version, level)
+			if got := qrReadCodewords(t, m, level); !bytes.Equal(got, exp) {
+				t.Fatalf("invalid codewords for version %d", version)
+			}
+		}
+	}
+
+	m, _ := QRCode([]byte("01234"), QRMedium)
+	if m.Width != 21 {
+		t.Fatalf("expected version 1, got size %d", m.Width)
+	}
+	if _, err := QRCode(make([]byte, 3000), QRLow); err != ErrTooLong {
+		t.Fatalf("expected error for too long data, got %v", err)
+	}
+	if _, err := QRCode(nil, 5); err == nil {
+		t.Fatal("expected error for invalid level")
+	}
+}
+
+func TestDataMatrixPlacement(t *testing.T) {
+	// every module of the mapping matrix must be set
+	for _, symbol := range dmSymbols {
+		nrow := symbol.regions() * symbol.regionSize
+		p := dmPlacement{nrow: nrow, ncol: nrow, codewords: make([]byte, symbol.dataLen+symbol.eccLen)}
+		p.modules = make([]int8, nrow*nrow)
+		for i := range p.modules {
+			p.modules[i] = -1
+		}
+		p.place()
+		for i, v := range p.modules {
+			if v == -1 {
+				t.Fatalf("module %d not set in symbol %d", i, symbol.size)
+			}
+		}
+		if capacity := nrow * nrow / 8; capacity != symbol.dataLen+symbol.eccLen {
+			t.Fatalf("invalid capacity for symbol %d: %d", symbol.size, capacity)
+		}
+	}
+}
+
+func TestDataMatrix(t *testing.T) {
+	m, err := DataMatrix([]byte("123456"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Width != 10 || m.Height != 10 {
+		t.Fatalf("unexpected size %d", m.Width)
+	}
+	// finder and timing patterns
+	for i := 0; i < 10; i++ {
+		if !m.At(0, i) || !m.At(i, 9) || m.At(i, 0) != (i%2 == 0) || m.At(9, i) != (i%2 == 1) {
+			t.Fatalf("invalid patterns\n%s", m)
+		}
+	}
+
+	if got := dmPad([]byte{1}, 4); !reflect.DeepEqual(got, []byte{1, 129, 70, 220}) {
+		t.Fatalf("unexpected padding %v", got)
+	}
+	if got := dmEncodeASCII([]byte("a1é")); !bytes.Equal(got, []byte{98, 50, 235, 68, 235, 42}) {
+		t.Fatalf("unexpected encoding %v", got)
+	}
+
+	m, err = DataMatrix(bytes.Repeat([]byte("A"), 200))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Width != 52 {
+		t.Fatalf("unexpected size %d", m.Width)
+	}
+	if _, err = DataMatrix(make([]byte, 5000)); err != ErrTooLong {
+		t.Fatalf("expected error for too long data, got %v", err)
+	}
+}
@@ -0,0 +1,247 @@
+package barcode
+
+var dataMatrixField = newGaloisField(0x12D)
+
+// dmSymbol describes a square Data Matrix ECC 200 symbol
+type dmSymbol struct {
+	size       int // including the finder patterns
+	regionSize int // data modules per side of a region
+	dataLen    int // number of data codewords
+	eccLen     int // total number of error correction codewords
+	blocks     int // number of interleaved blocks
+}
+
+// the square symbols, by increasing size (see Table 7 of the specification)
+var dmSymbols = [...]dmSymbol{
+	{10, 8, 3, 5, 1},
+	{12, 10, 5, 7, 1},
+	{14, 12, 8, 10, 1},
+	{16, 14, 12, 12, 1},
+	{18, 16, 18, 14, 1},
+	{20, 18, 22, 18, 1},
+	{22, 20, 30, 20, 1},
+	{24, 22, 36, 24, 1},
+	{26, 24, 44, 28, 1},
+	{32, 14, 62, 36, 1},
+	{36, 16, 86, 42, 1},
+	{40, 18, 114, 48, 1},
+	{44, 20, 144, 56, 1},
+	{48, 22, 174, 68, 1},
+	{52, 24, 204, 84, 2},
+	{64, 14, 280, 112, 2},
+	{72, 16, 368, 144, 4},
+	{80, 18, 456, 192, 4},
+	{88, 20, 576, 224, 4},
+	{96, 22, 696, 272, 4},
+	{104, 24, 816, 336, 6},
+	{120, 18, 1050, 408, 6},
+	{132, 20, 1304, 496, 8},
+	{144, 22, 1558, 620, 10},
+}
+
+// regions returns the number of data regions per side
+func (s dmSymbol) regions() int { return s.size / (s.regionSize + 2) }
+
+// dmEncodeASCII uses the ASCII encodation, compacting the pairs of digits
+func dmEncodeASCII(data []byte) []byte {
+	var out []byte
+	isDigit := func(b byte) bool { return '0' <= b && b <= '9' }
+	for i := 0; i < len(data); i++ {
+		b := data[i]
+		switch {
+		case isDigit(b) && i+1 < len(data) && isDigit(data[i+1]):
+			out = append(out, 130+(b-'0')*10+(data[i+1]-'0'))
+			i++
+		case b < 128:
+			out = append(out, b+1)
+		default: // upper shift
+			out = append(out, 235, b-127)
+		}
+	}
+	return out
+}
+
+// dmPad completes `codewords` up to `length`
+func dmPad(codewords []byte, length int) []byte {
+	if len(codewords) < length {
+		codewords = append(codewords, 129)
+	}
+	for len(codewords) < length {
+		pos := len(codewords) + 1
+		pad := 129 + (149*pos)%253 + 1
+		if pad > 254 {
+			pad -= 254
+		}
+		codewords = append(codewords, byte(pad))
+	}
+	return codewords
+}
+
+// dmAddECC computes the error correction codewords of each block,
+// and returns the interleaved codewords
+func dmAddECC(data []byte, symbol dmSymbol) []byte {
+	eccLen := symbol.eccLen / symbol.blocks
+	generator := dataMatrixField.generator(eccLen, 1)
+	out := make([]byte, len(data)+symbol.eccLen)
+	copy(out, data)
+	for b := 0; b < symbol.blocks; b++ {
+		var block []byte
+		for i := b; i < len(data); i += symbol.blocks {
+			block = append(block, data[i])
+		}
+		for i, ecc := range dataMatrixField.remainder(block, generator) {
+			out[len(data)+b+i*symbol.blocks] = ecc
+		}
+	}
+	return out
+}
+
+// dmPlacement implements the placement algorithm of the codewords
+// in the mapping matrix (see Annex F of the specification)
+type dmPlacement struct {
+	nrow, ncol int
+	modules    []int8 // -1 for unset, 0 or 1
+	codewords  []byte
+}
+
+func (p *dmPlacement) module(row, col, chr, bit int) {
+	if row < 0 {
+		row += p.nrow
+		col += 4 - (p.nrow+4)%8
+	}
+	if col < 0 {
+		col += p.ncol
+		row += 4 - (p.ncol+4)%8
+	}
+	var v int8
+	if chr < len(p.codewords) && p.codewords[chr]&(1<<uint(8-bit)) != 0 {
+		v = 1
+	}
+	p.modules[row*p.ncol+col] = v
+}
+
+func (p *dmPlacement) isSet(row, col int) bool { return p.modules[row*p.ncol+col] != -1 }
+
+func (p *dmPlacement) utah(row, col, chr int) {
+	p.module(row-2, col-2, chr, 1)
+	p.module(row-2, col-1, chr, 2)
+	p.module(row-1, col-2, chr, 3)
+	p.module(row-1, col-1, chr, 4)
+	p.module(row-1, col, chr, 5)
+	p.module(row, col-2, chr, 6)
+	p.module(row, col-1, chr, 7)
+	p.module(row, col, chr, 8)
+}
+
+// corner places the 8 bits of `chr` at the given positions
+func (p *dmPlacement) corner(chr int, positions [8][2]int) {
+	for i, pos := range positions {
+		p.module(pos[0], pos[1], chr, i+1)
+	}
+}
+
+func (p *dmPlacement) place() {
+	nrow, ncol := p.nrow, p.ncol
+	chr, row, col := 0, 4, 0
+	for {
+		if row == nrow && col == 0 {
+			p.corner(chr, [8][2]int{{nrow - 1, 0}, {nrow - 1, 1}, {nrow - 1, 2}, {0, ncol - 2}, {0, ncol - 1}, {1, ncol - 1}, {2, ncol - 1}, {3, ncol - 1}})
+			chr++
+		}
+		if row == nrow-2 && col == 0 && ncol%4 != 0 {
+			p.corner(chr, [8][2]int{{nrow - 3, 0}, {nrow - 2, 0}, {nrow - 1, 0}, {0, ncol - 4}, {0, ncol - 3}, {0, ncol - 2}, {0, ncol - 1}, {1, ncol - 1}})
+			chr++
+		}
+		if row == nrow-2 && col == 0 && ncol%8 == 4 {
+			p.corner(chr, [8][2]int{{nrow - 3, 0}, {nrow - 2, 0}, {nrow - 1, 0}, {0, ncol - 2}, {0, ncol - 1}, {1, ncol - 1}, {2, ncol - 1}, {3, ncol - 1}})
+			chr++
+		}
+		if row == nrow+4 && col == 2 && ncol%8 == 0 {
+			p.corner(chr, [8][2]int{{nrow - 1, 0}, {nrow - 1, ncol - 1}, {0, ncol - 3}, {0, ncol - 2}, {0, ncol - 1}, {1, ncol - 3}, {1, ncol - 2}, {1, ncol - 1}})
+			chr++
+		}
+		// sweep upward diagonally
+		for {
+			if row < nrow && col >= 0 && !p.isSet(row, col) {
+				p.utah(row, col, chr)
+				chr++
+			}
+			row -= 2
+			col += 2
+			if !(row >= 0 && col < ncol) {
+				break
+			}
+		}
+		row++
+		col += 3
+		// sweep downward diagonally
+		for {
+			if row >= 0 && col < ncol && !p.isSet(row, col) {
+				p.utah(row, col, chr)
+				chr++
+			}
+			row += 2
+			col -= 2
+			if !(row < nrow && col >= 0) {
+				break
+			}
+		}
+		row += 3
+		col++
+		if !(row < nrow || col < ncol) {
+			break
+		}
+	}
+	// fixed pattern in the lower right corner
+	if !p.isSet(nrow-1, ncol-1) {
+		p.modules[(nrow-1)*ncol+ncol-1] = 1
+		p.modules[(nrow-2)*ncol+ncol-2] = 1
+		p.modules[(nrow-1)*ncol+ncol-2] = 0
+		p.modules[(nrow-2)*ncol+ncol-1] = 0
+	}
+}
+
+// DataMatrix encodes `data` as a Data Matrix (ECC 200) symbol,
+// using the smallest square symbol with enough capacity.
+func DataMatrix(data []byte) (Matrix, error) {
+	codewords := dmEncodeASCII(data)
+	var symbol dmSymbol
+	for _, s := range dmSymbols {
+		if len(codewords) <= s.dataLen {
+			symbol = s
+			break
+		}
+	}
+	if symbol.size == 0 {
+		return Matrix{}, ErrTooLong
+	}
+	codewords = dmAddECC(dmPad(codewords, symbol.dataLen), symbol)
+
+	regions, rs := symbol.regions(), symbol.regionSize
+	p := dmPlacement{nrow: regions * rs, ncol: regions * rs, codewords: codewords}
+	p.modules = make([]int8, p.nrow*p.ncol)
+	for i := range p.modules {
+		p.modules[i] = -1
+	}
+	p.place()
+
+	out := newMatrix(symbol.size, symbol.size)
+	for ry := 0; ry < regions; ry++ {
+		for rx := 0; rx < regions; rx++ {
+			x0, y0 := rx*(rs+2), ry*(rs+2)
+			// finder (left and bottom) and timing (top and right) patterns
+			for i := 0; i < rs+2; i++ {
+				out.set(x0, y0+i, true)
+				out.set(x0+i, y0+rs+1, true)
+				out.set(x0+i, y0, i%2 == 0)
+				out.set(x0+rs+1, y0+i, i%2 == 1)
+			}
+			for y := 0; y < rs; y++ {
+				for x := 0; x < rs; x++ {
+					out.set(x0+1+x, y0+1+y, p.modules[(ry*rs+y)*p.ncol+rx*rs+x] == 1)
+				}
+			}
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,430 @@
+package barcode
+
+import "fmt"
+
+// QRLevel is the error correction level of a QR code
+type QRLevel uint8
+
+const (
+	QRLow      QRLevel = iota // recovers 7% of the data
+	QRMedium                  // recovers 15% of the data
+	QRQuartile                // recovers 25% of the data
+	QRHigh                    // recovers 30% of the data
+)
+
+var qrField = newGaloisField(0x11D)
+
+// indexed by level and version
+var (
+	qrECCPerBlock = [4][41]int{
+		{-1, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+		{-1, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+		{-1, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+		{-1, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	}
+	qrBlocks = [4][41]int{
+		{-1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+		{-1, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+		{-1, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+		{-1, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+	}
+	// format indicators of the levels
+	qrLevelBits = [4]int{1, 0, 3, 2}
+)
+
+// qrRawModules returns the number of modules available for the data
+// (and the error correction) in a symbol of the given version,
+// that is excluding the function patterns and the format and version information.
+func qrRawModules(version int) int {
+	out := (16*version+128)*version + 64
+	if version >= 2 {
+		nbAlign := version/7 + 2
+		out -= (25*nbAlign-10)*nbAlign - 55
+		if version >= 7 {
+			out -= 36
+		}
+	}
+	return out
+}
+
+// qrDataCodewords returns the number of data codewords
+func qrDataCodewords(version int, level QRLevel) int {
+	return qrRawModules(version)/8 - qrECCPerBlock[level][version]*qrBlocks[level][version]
+}
+
+func qrAlignmentPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+	nbAlign := version/7 + 2
+	size := 4*version + 17
+	step := 26
+	if version != 32 {
+		step = (version*4 + nbAlign*2 + 1) / (nbAlign*2 - 2) * 2
+	}
+	out := make([]int, nbAlign)
+	out[0] = 6
+	for i, pos := nbAlign-1, size-7; i >= 1; i, pos = i-1, pos-step {
+		out[i] = pos
+	}
+	return out
+}
+
+type bitBuffer []bool
+
+func (bb *bitBuffer) append(value, length int) {
+	for i := length - 1; i >= 0; i-- {
+		*bb = append(*bb, (value>>uint(i))&1 != 0)
+	}
+}
+
+func (bb bitBuffer) bytes() []byte {
+	out := make([]byte, (len(bb)+7)/8)
+	for i, bit := range bb {
+		if bit {
+			out[i/8] |= 0x80 >> uint(i%8)
+		}
+	}
+	return out
+}
+
+// qrEncodeData returns the data codewords encoding `data` in byte mode,
+// for the smallest version with enough capacity.
+func qrEncodeData(data []byte, level QRLevel) (codewords []byte, version int, err error) {
+	for version = 1; version <= 40; version++ {
+		countBits := 8
+		if version >= 10 {
+			countBits = 16
+		}
+		capacity := qrDataCodewords(version, level) * 8
+		if 4+countBits+8*len(data) <= capacity {
+			var bb bitBuffer
+			bb.append(0x4, 4) // byte mode
+			bb.append(len(data), countBits)
+			for _, b := range data {
+				bb.append(int(b), 8)
+			}
+			// terminator and padding to a byte boundary
+			terminator := capacity - len(bb)
+			if terminator > 4 {
+				terminator = 4
+			}
+			bb.append(0, terminator)
+			bb.append(0, (8-len(bb)%8)%8)
+			codewords = bb.bytes()
+			for pad := byte(0xEC); len(codewords) < capacity/8; pad ^= 0xEC ^ 0x11 {
+				codewords = append(codewords, pad)
+			}
+			return codewords, version, nil
+		}
+	}
+	return nil, 0, ErrTooLong
+}
+
+// qrAddECC splits `data` in blocks, computes their error correction codewords,
+// and interleaves the result.
+func qrAddECC(data []byte, version int, level QRLevel) []byte {
+	nbBlocks, eccLen := qrBlocks[level][version], qrECCPerBlock[level][version]
+	rawCodewords := qrRawModules(version) / 8
+	nbShortBlocks := nbBlocks - rawCodewords%nbBlocks
+	shortBlockLen := rawCodewords / nbBlocks
+
+	generator := qrField.generator(eccLen, 0)
+	blocks := make([][]byte, nbBlocks)
+	for i, k := 0, 0; i < nbBlocks; i++ {
+		dataLen := shortBlockLen - eccLen
+		if i >= nbShortBlocks {
+			dataLen++
+		}
+		blockData := data[k : k+dataLen]
+		k += dataLen
+		block := append([]byte(nil), blockData...)
+		if i < nbShortBlocks { // padding, skipped when interleaving
+			block = append(block, 0)
+		}
+		blocks[i] = append(block, qrField.remainder(blockData, generator)...)
+	}
+
+	out := make([]byte, 0, rawCodewords)
+	for j := 0; j <= shortBlockLen; j++ {
+		for i, block := range blocks {
+			if j != shortBlockLen-eccLen || i >= nbShortBlocks {
+				out = append(out, block[j])
+			}
+		}
+	}
+	return out
+}
+
+type qrSymbol struct {
+	Matrix
+	isFunction []bool
+}
+
+func (qr qrSymbol) setFunction(x, y int, dark bool) {
+	qr.set(x, y, dark)
+	qr.isFunction[y*qr.Width+x] = true
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func (qr qrSymbol) drawFunctionPatterns(version int) {
+	size := qr.Width
+	for i := 0; i < size; i++ { // timing patterns
+		qr.setFunction(6, i, i%2 == 0)
+		qr.setFunction(i, 6, i%2 == 0)
+	}
+	// finder patterns, with their separators
+	for _, center := range [3][2]int{{3, 3}, {size - 4, 3}, {3, size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := center[0]+dx, center[1]+dy
+				if 0 <= x && x < size && 0 <= y && y < size {
+					dist := maxInt(abs(dx), abs(dy))
+					qr.setFunction(x, y, dist != 2 && dist != 4)
+				}
+			}
+		}
+	}
+	positions := qrAlignmentPositions(version)
+	last := len(positions) - 1
+	for i, x := range positions {
+		for j, y := range positions {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue // finder patterns
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					qr.setFunction(x+dx, y+dy, maxInt(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+	// reserve the format information
+	qr.drawFormat(QRLow, 0)
+
+	if version >= 7 {
+		rem := version
+		for i := 0; i < 12; i++ {
+			rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+		}
+		bits := version<<12 | rem
+		for i := 0; i < 18; i++ {
+			dark := (bits>>uint(i))&1 != 0
+			a, b := size-11+i%3, i/3
+			qr.setFunction(a, b, dark)
+			qr.setFunction(b, a, dark)
+		}
+	}
+}
+
+// qrFormatBits returns the 15 bits of the format information
+func qrFormatBits(level QRLevel, mask int) int {
+	data := qrLevelBits[level]<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+func (qr qrSymbol) drawFormat(level QRLevel, mask int) {
+	bits := qrFormatBits(level, mask)
+	bit := func(i int) bool { return (bits>>uint(i))&1 != 0 }
+	size := qr.Width
+	// first copy, around the top left finder
+	for i := 0; i <= 5; i++ {
+		qr.setFunction(8, i, bit(i))
+	}
+	qr.setFunction(8, 7, bit(6))
+	qr.setFunction(8, 8, bit(7))
+	qr.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		qr.setFunction(14-i, 8, bit(i))
+	}
+	// second copy
+	for i := 0; i < 8; i++ {
+		qr.setFunction(size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		qr.setFunction(8, size-15+i, bit(i))
+	}
+	qr.setFunction(8, size-8, true) // dark module
+}
+
+// drawCodewords places the codewords in the zigzag order
+func (qr qrSymbol) drawCodewords(codewords []byte) {
+	size := qr.Width
+	i := 0
+	for right := size - 1; right >= 1; right -= 2 {
+		if right == 6 { // skip the vertical timing pattern
+			right = 5
+		}
+		for vert := 0; vert < size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 { // upward
+					y = size - 1 - vert
+				}
+				if !qr.isFunction[y*size+x] && i < len(codewords)*8 {
+					qr.set(x, y, (codewords[i/8]>>uint(7-i%8))&1 != 0)
+					i++
+				}
+			}
+		}
+	}
+}
+
+func qrMask(mask, x, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	default:
+		return ((x+y)%2+x*y%3)%2 == 0
+	}
+}
+
+// applyMask xors the data modules with the mask (applying it twice undoes it)
+func (qr qrSymbol) applyMask(mask int) {
+	for y := 0; y < qr.Height; y++ {
+		for x := 0; x < qr.Width; x++ {
+			if !qr.isFunction[y*qr.Width+x] && qrMask(mask, x, y) {
+				qr.set(x, y, !qr.At(x, y))
+			}
+		}
+	}
+}
+
+// penalty evaluates the readability of the symbol (lower is better),
+// following the rules of the specification (7.8.3)
+func (qr qrSymbol) penalty() int {
+	size := qr.Width
+	out := 0
+	// runs of same color modules and finder like patterns
+	finderLike := func(line []bool) int {
+		count := 0
+		pattern := [...]bool{true, false, true, true, true, false, true}
+		for start := 0; start+len(pattern) <= len(line); start++ {
+			match := true
+			for k, p := range pattern {
+				if line[start+k] != p {
+					match = false
+					break
+				}
+			}
+			if !match {
+				continue
+			}
+			lightBefore, lightAfter := true, true
+			for k := 1; k <= 4; k++ {
+				if start-k >= 0 && line[start-k] {
+					lightBefore = false
+				}
+				if end := start + len(pattern) - 1 + k; end < len(line) && line[end] {
+					lightAfter = false
+				}
+			}
+			if lightBefore || lightAfter {
+				count++
+			}
+		}
+		return count
+	}
+	line := make([]bool, size)
+	for horizontal := 0; horizontal < 2; horizontal++ {
+		for a := 0; a < size; a++ {
+			for b := 0; b < size; b++ {
+				if horizontal == 0 {
+					line[b] = qr.At(b, a)
+				} else {
+					line[b] = qr.At(a, b)
+				}
+			}
+			run := 1
+			for b := 1; b <= size; b++ {
+				if b < size && line[b] == line[b-1] {
+					run++
+					continue
+				}
+				if run >= 5 {
+					out += run - 2
+				}
+				run = 1
+			}
+			out += 40 * finderLike(line)
+		}
+	}
+	// 2x2 blocks
+	dark := 0
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			c := qr.At(x, y)
+			if c {
+				dark++
+			}
+			if x+1 < size && y+1 < size && c == qr.At(x+1, y) && c == qr.At(x, y+1) && c == qr.At(x+1, y+1) {
+				out += 3
+			}
+		}
+	}
+	// balance of dark modules
+	total := size * size
+	out += abs(dark*20-total*10) / total * 10
+	return out
+}
+
+// QRCode encodes `data` (in byte mode) as a QR code, using the
+// smallest version (size) compatible with the error correction `level`.
+func QRCode(data []byte, level QRLevel) (Matrix, error) {
+	if level > QRHigh {
+		return Matrix{}, fmt.Errorf("invalid QR code error correction level %d", level)
+	}
+	codewords, version, err := qrEncodeData(data, level)
+	if err != nil {
+		return Matrix{}, err
+	}
+	codewords = qrAddECC(codewords, version, level)
+
+	size := 4*version + 17
+	qr := qrSymbol{Matrix: newMatrix(size, size), isFunction: make([]bool, size*size)}
+	qr.drawFunctionPatterns(version)
+	qr.drawCodewords(codewords)
+
+	bestMask, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		qr.applyMask(mask)
+		qr.drawFormat(level, mask)
+		if p := qr.penalty(); bestPenalty == -1 || p < bestPenalty {
+			bestMask, bestPenalty = mask, p
+		}
+		qr.applyMask(mask) // undo
+	}
+	qr.applyMask(bestMask)
+	qr.drawFormat(level, bestMask)
+	return qr.Matrix, nil
+}
@@ -0,0 +1,96 @@
+package formfill
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/benoitkugler/pdf/model"
+)
+
+func decodeAppearance(t *testing.T, app *model.XObjectForm) string {
+	content, err := app.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func newBarcodeDocument(pmd model.PaperMetaData) (model.Document, *model.FormFieldDict) {
+	widget := &model.AnnotationDict{
+		BaseAnnotation: model.BaseAnnotation{Rect: model.Rectangle{Llx: 10, Lly: 10, Urx: 110, Ury: 130}},
+		Subtype:        model.AnnotationWidget{},
+	}
+	field := &model.FormFieldDict{
+		FormFieldInheritable: model.FormFieldInheritable{FT: model.FormFieldText{PMD: &pmd}, DA: "/Helv 10 Tf 0 g"},
+		T:                    "code",
+		Widgets:              []model.FormFieldWidget{{AnnotationDict: widget}},
+	}
+	var doc model.Document
+	doc.Catalog.Pages.Kids = []model.PageNode{&model.PageObject{Annots: []*model.AnnotationDict{widget}}}
+	doc.Catalog.AcroForm.Fields = []*model.FormFieldDict{field}
+	return doc, field
+}
+
+func TestFillBarcode(t *testing.T) {
+	for _, symbology := range []model.Name{model.SymbologyQRCode, model.SymbologyDataMatrix} {
+		doc, field := newBarcodeDocument(model.PaperMetaData{Symbology: symbology, Caption: true})
+		err := FillForm(&doc, FDFDict{Fields: []FDFField{
+			{T: "code", Values: Values{V: FDFText("INVOICE-2023-0042")}},
+		}}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := field.FT.(model.FormFieldText).V; v != "INVOICE-2023-0042" {
+			t.Fatalf("unexpected value %s", v)
+		}
+		app := field.Widgets[0].AP.N[""]
+		content := decodeAppearance(t, app)
+		if strings.Count(content, " re") < 20 || !strings.Contains(content, "0042)]TJ") { // kerned caption
+			t.Fatalf("unexpected barcode appearance %s", content)
+		}
+		if app.BBox.Width() != 100 || app.BBox.Height() != 120 {
+			t.Fatalf("unexpected bounding box %v", app.BBox)
+		}
+
+		var b bytes.Buffer
+		if err = doc.Write(&b, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// PDF417 is not supported: a text appearance is used
+	doc, field := newBarcodeDocument(model.PaperMetaData{Symbology: model.SymbologyPDF417})
+	err := FillForm(&doc, FDFDict{Fields: []FDFField{
+		{T: "code", Values: Values{V: FDFText("INVOICE")}},
+	}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content := decodeAppearance(t, field.Widgets[0].AP.N[""]); !strings.Contains(content, "(OICE)]TJ") || strings.Contains(content, " re f") {
+		t.Fatalf("unexpected text appearance %s", content)
+	}
+}
+
+func TestUpdateBarcodes(t *testing.T) {
+	doc, field := newBarcodeDocument(model.PaperMetaData{
+		Symbology: model.SymbologyQRCode, ECC: model.ObjInt(3), X: model.ObjInt(1), Resolution: model.ObjInt(72),
+	})
+	text := field.FT.(model.FormFieldText)
+	text.V = "https://example.com"
+	field.FT = text
+	if err := UpdateBarcodes(&doc); err != nil {
+		t.Fatal(err)
+	}
+	app := field.Widgets[0].AP.N[""]
+	// the module size is limited to 1 point
+	if content := decodeAppearance(t, app); !strings.Contains(content, " 1 1 re") {
+		t.Fatalf("unexpected module size: %s", content)
+	}
+
+	text.V = strings.Repeat("x", 3000)
+	field.FT = text
+	if err := UpdateBarcodes(&doc); err == nil {
+		t.Fatal("expected error for too long value")
+	}
+}
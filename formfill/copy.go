@@ -0,0 +1,119 @@
+package formfill
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/benoitkugler/pdf/model"
+)
+
+// fieldValues returns the current values of `field`,
+// or false if it is not filled
+func fieldValues(field model.FormFieldInherited) (Values, bool) {
+	switch ft := field.Merged.FT.(type) {
+	case model.FormFieldText:
+		if ft.V == "" && field.Field.RV == "" {
+			return Values{}, false
+		}
+		out := Values{RV: field.Field.RV}
+		if ft.V != "" || field.Merged.Ff&model.RichText == 0 {
+			out.V = FDFText(ft.V)
+		}
+		return out, true
+	case model.FormFieldChoice:
+		if len(ft.V) == 0 {
+			return Values{}, false
+		}
+		return Values{V: FDFChoices(append([]string(nil), ft.V...))}, true
+	case model.FormFieldButton:
+		if ft.V == "" || field.Merged.Ff&model.Pushbutton != 0 {
+			return Values{}, false
+		}
+		return Values{V: FDFName(ft.V)}, true
+	}
+	return Values{}, false // signatures are not copied
+}
+
+// CopyValues imports the values of the fields of `src`, a filled copy
+// of the same template as `dst`, into `dst`, which is typically a fresh template.
+// The fields are matched by their fully qualified name, and ignored if the
+// types (FT entry) of the two fields differ.
+// The V entry of text, choice and button fields is copied, as well as
+// the selected indices (I) of choice fields and the appearance state (AS) of the widgets.
+//
+// If `copyAppearances` is true, the appearance streams of the widgets of `src` are also
+// copied (and shared between the two documents), so that `dst` renders exactly as `src`.
+// Otherwise, the appearances are generated as in FillForm, using the styles of `dst`.
+// In both cases, the fields of `src` without value are left unchanged in `dst`.
+func CopyValues(src, dst *model.Document, copyAppearances bool) error {
+	srcFields := src.Catalog.AcroForm.Flatten()
+	dstFields := dst.Catalog.AcroForm.Flatten()
+
+	// use a deterministic order, for errors
+	names := make([]string, 0, len(srcFields))
+	for name := range srcFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	filler := newFiller()
+	filler.theme = dst.Theme
+	for _, name := range names {
+		srcField := srcFields[name]
+		dstField, ok := dstFields[name]
+		if !ok || reflect.TypeOf(srcField.Merged.FT) != reflect.TypeOf(dstField.Merged.FT) {
+			continue
+		}
+		values, ok := fieldValues(srcField)
+		if !ok {
+			continue
+		}
+		if copyAppearances {
+			copyField(srcField, dstField)
+			continue
+		}
+		err := filler.setField(dst.Catalog.AcroForm.DR, dstField, values)
+		if stateErr, ok := err.(InvalidStateErr); ok {
+			stateErr.Field = name
+			return stateErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if !copyAppearances {
+		dst.Catalog.AcroForm.NeedAppearances = false
+	}
+	return nil
+}
+
+// copyField copies the values and the widget appearances of `src`
+// into `dst`, which are assumed to have the same type.
+func copyField(src, dst model.FormFieldInherited) {
+	switch ft := src.Merged.FT.(type) {
+	case model.FormFieldText:
+		dstFt := dst.Merged.FT.(model.FormFieldText)
+		dstFt.V = ft.V
+		dst.Field.FT = dstFt
+		dst.Field.RV = src.Field.RV
+	case model.FormFieldChoice:
+		dstFt := dst.Merged.FT.(model.FormFieldChoice)
+		dstFt.V = append([]string(nil), ft.V...)
+		dstFt.I = append([]int(nil), ft.I...)
+		dstFt.TI = ft.TI
+		dst.Field.FT = dstFt
+	case model.FormFieldButton:
+		dstFt := dst.Merged.FT.(model.FormFieldButton)
+		dstFt.V = ft.V
+		dst.Field.FT = dstFt
+	}
+	// widgets are matched by index
+	srcWidgets := src.Field.Widgets
+	for i, widget := range dst.Field.Widgets {
+		if i >= len(srcWidgets) || widget.AnnotationDict == nil || srcWidgets[i].AnnotationDict == nil {
+			break
+		}
+		widget.AS = srcWidgets[i].AS
+		widget.AP = srcWidgets[i].AP
+	}
+}
@@ -0,0 +1,97 @@
+package formfill
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/benoitkugler/pdf/model"
+	"github.com/benoitkugler/pdf/reader"
+)
+
+func TestCopyValues(t *testing.T) {
+	values := FDFDict{Fields: []FDFField{
+		{T: "MED.3", Values: Values{V: FDFName("AUT CAS")}},
+		{T: "SOR B", Values: Values{V: FDFName("Oui.")}},
+	}}
+	for _, copyAppearances := range [...]bool{false, true} {
+		src, _, err := reader.ParsePDFFile("test/sample4.pdf", reader.Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = FillForm(&src, values, false); err != nil {
+			t.Fatal(err)
+		}
+		dst, _, err := reader.ParsePDFFile("test/sample4.pdf", reader.Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = CopyValues(&src, &dst, copyAppearances); err != nil {
+			t.Fatal(err)
+		}
+		srcFields, dstFields := src.Catalog.AcroForm.Flatten(), dst.Catalog.AcroForm.Flatten()
+		for _, name := range [...]string{"MED.3", "SOR B"} {
+			srcField, dstField := srcFields[name].Field, dstFields[name].Field
+			if !reflect.DeepEqual(srcField.FT, dstField.FT) {
+				t.Fatalf("unexpected value for %s: %v", name, dstField.FT)
+			}
+			for i, widget := range dstField.Widgets {
+				if widget.AS != srcField.Widgets[i].AS {
+					t.Fatalf("unexpected state for %s: %s", name, widget.AS)
+				}
+				if copyAppearances && widget.AP != srcField.Widgets[i].AP {
+					t.Fatal("appearances should be copied")
+				}
+			}
+		}
+	}
+}
+
+func TestCopyValuesText(t *testing.T) {
+	newForm := func() model.Document {
+		var doc model.Document
+		doc.Catalog.AcroForm.DR.Font = map[model.ObjName]*model.FontDict{"Helv": defaultFont}
+		newField := func(name string, ft model.FormField) *model.FormFieldDict {
+			return &model.FormFieldDict{
+				T:                    name,
+				FormFieldInheritable: model.FormFieldInheritable{FT: ft, DA: "/Helv 10 Tf 0 g"},
+				Widgets: []model.FormFieldWidget{{AnnotationDict: &model.AnnotationDict{
+					BaseAnnotation: model.BaseAnnotation{Rect: model.Rectangle{Urx: 100, Ury: 20}},
+					Subtype:        model.AnnotationWidget{},
+				}}},
+			}
+		}
+		opts := []model.Option{{Name: "Red"}, {Export: "g", Name: "Green"}}
+		doc.Catalog.AcroForm.Fields = []*model.FormFieldDict{
+			newField("name", model.FormFieldText{}),
+			newField("color", model.FormFieldChoice{Opt: opts}),
+			newField("other", model.FormFieldText{}),
+		}
+		return doc
+	}
+	src, dst := newForm(), newForm()
+	err := FillForm(&src, FDFDict{Fields: []FDFField{
+		{T: "name", Values: Values{V: FDFText("Dupont")}},
+		{T: "color", Values: Values{V: FDFText("g")}},
+	}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.Catalog.AcroForm.Fields[2].FT = model.FormFieldChoice{V: []string{"a"}} // type mismatch
+	if err = CopyValues(&src, &dst, false); err != nil {
+		t.Fatal(err)
+	}
+	fields := dst.Catalog.AcroForm.Fields
+	if v := fields[0].FT.(model.FormFieldText).V; v != "Dupont" {
+		t.Fatalf("unexpected value %s", v)
+	}
+	if ch := fields[1].FT.(model.FormFieldChoice); !reflect.DeepEqual(ch.V, []string{"g"}) || !reflect.DeepEqual(ch.I, []int{1}) {
+		t.Fatalf("unexpected choice %v", ch)
+	}
+	if fields[0].Widgets[0].AP == nil || fields[0].Widgets[0].AP == src.Catalog.AcroForm.Fields[0].Widgets[0].AP {
+		t.Fatal("appearance should be generated")
+	}
+	if _, isText := fields[2].FT.(model.FormFieldText); !isText {
+		t.Fatal("fields with different types should be ignored")
+	}
+}
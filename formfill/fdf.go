@@ -5,6 +5,7 @@ package formfill
 
 import (
 	"errors"
+	"image/color"
 	"strconv"
 
 	"github.com/benoitkugler/pdf/model"
@@ -30,8 +31,26 @@ type FDFText string
 type FDFChoices []string
 
 type Values struct {
-	V  FDFValue
+	V FDFValue
+	// RV is an optional rich text value (XHTML).
+	// For text fields with the RichText flag, it is used to deduce
+	// the plain text value when V is nil.
 	RV string
+
+	// Style is an optional override of the field text appearance,
+	// which is not supported by FDF files.
+	Style *Style
+}
+
+// Style overrides the text appearance defined by a field
+// (DA and Q entries, and DS for rich text fields), when generating its appearance streams.
+// It is useful for long values which need a smaller text.
+// The DA and Q entries of the field are not modified.
+type Style struct {
+	FontSize  Fl              // if non zero, overrides the font size
+	Color     color.Color     // if not nil, overrides the text color
+	Alignment *model.Quadding // if not nil, overrides the alignment
+	Font      *model.FontDict // if not nil, overrides the font
 }
 
 type FDFField struct {
@@ -74,9 +93,14 @@ func (f FDFDict) resolve() map[string]Values {
 // FillForm fill the AcroForm contained in the document
 // using the value in `fdf`.
 // If `lockForm` is true, all the fields are set ReadOnly (even the ones not filled).
+// The values of check boxes and radio buttons are checked against the appearance states
+// of their widgets, and an InvalidStateErr is returned for unknown states.
+// The document Theme, if any, provides the default text style, colors and border
+// of the generated appearances.
 // See FillFormFromFDF to use a FDF file as value input.
 func FillForm(doc *model.Document, fdf FDFDict, lockForm bool) error {
 	filler := newFiller()
+	filler.theme = doc.Theme
 	return filler.fillForm(&doc.Catalog.AcroForm, fdf, lockForm)
 }
 
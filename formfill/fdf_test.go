@@ -3,6 +3,7 @@ package formfill
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
@@ -80,7 +81,7 @@ func TestFill1(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	out, err := os.Create("test/sample1_filled.pdf")
+	out, err := os.Create(filepath.Join(t.TempDir(), "sample1_filled.pdf"))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -125,7 +126,7 @@ func TestFillPDFjs(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if err = doc.WriteFile("test/sample2_filled.pdf", nil); err != nil {
+	if err = doc.WriteFile(filepath.Join(t.TempDir(), "sample2_filled.pdf"), nil); err != nil {
 		t.Fatal(err)
 	}
 }
@@ -156,7 +157,7 @@ func TestFill3(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if err = doc.WriteFile("test/sample3_filled.pdf", nil); err != nil {
+	if err = doc.WriteFile(filepath.Join(t.TempDir(), "sample3_filled.pdf"), nil); err != nil {
 		t.Fatal(err)
 	}
 }
@@ -196,7 +197,7 @@ func TestFill4(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if err = doc.WriteFile("test/sample4_filled.pdf", nil); err != nil {
+	if err = doc.WriteFile(filepath.Join(t.TempDir(), "sample4_filled.pdf"), nil); err != nil {
 		t.Fatal(err)
 	}
 }
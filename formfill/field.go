@@ -32,8 +32,8 @@ type fieldAppearanceBuilder struct {
 	// Holds value of property choices.
 	choices []string
 
-	// Holds value of property choiceSelection.
-	choiceSelection int
+	// Holds the indices of the selected choices, sorted
+	choiceSelection []int
 
 	topFirst int
 }
@@ -159,119 +159,12 @@ func (b fieldAppearanceBuilder) getBorderAppearance() contentstream.GraphicStrea
 	return app
 }
 
-func getHardBreaks(text string) (arr []string) {
-	cs := []rune(text)
-	var buf strings.Builder
-	for k := 0; k < len(cs); k++ {
-		c := cs[k]
-		if c == '\r' {
-			if k+1 < len(cs) && cs[k+1] == '\n' {
-				k++
-			}
-			arr = append(arr, buf.String())
-			buf.Reset()
-		} else if c == '\n' {
-			arr = append(arr, buf.String())
-			buf.Reset()
-		} else {
-			buf.WriteRune(c)
-		}
-	}
-	arr = append(arr, buf.String())
-	return arr
-}
-
-func breakLines(breaks []string, font fonts.Font, fontSize, width Fl) (lines []string) {
-	var buf []rune
-	for _, break_ := range breaks {
-		buf = buf[:0]
-		var w Fl
-		cs := []rune(break_)
-		// 0 inline first, 1 inline, 2 spaces
-		state := 0
-		lastspace := -1
-		refk := 0
-		for k := 0; k < len(cs); k++ {
-			c := cs[k]
-			switch state {
-			case 0:
-				w += font.GetWidth(c, fontSize)
-				buf = append(buf, c)
-				if w > width {
-					w = 0
-					if len(buf) > 1 {
-						k--
-						buf = buf[:len(buf)-1]
-					}
-					lines = append(lines, string(buf))
-					buf = buf[:0]
-					refk = k
-					if c == ' ' {
-						state = 2
-					} else {
-						state = 1
-					}
-				} else {
-					if c != ' ' {
-						state = 1
-					}
-				}
-			case 1:
-				w += font.GetWidth(c, fontSize)
-				buf = append(buf, c)
-				if c == ' ' {
-					lastspace = k
-				}
-				if w > width {
-					w = 0
-					if lastspace >= 0 {
-						k = lastspace
-						buf = buf[:lastspace-refk]
-						lines = append(lines, strings.TrimRight(string(buf), " "))
-						buf = buf[:0]
-						refk = k
-						lastspace = -1
-						state = 2
-					} else {
-						if len(buf) > 1 {
-							k--
-							buf = buf[:len(buf)-1]
-						}
-						lines = append(lines, string(buf))
-						buf = buf[:0]
-						refk = k
-						if c == ' ' {
-							state = 2
-						}
-					}
-				}
-			case 2:
-				if c != ' ' {
-					w = 0
-					k--
-					state = 1
-				}
-			}
-		}
-		lines = append(lines, strings.TrimRight(string(buf), " "))
-	}
-	return lines
-}
-
 // extra margins could be use in text fields to better mimic the Acrobat layout.
 const (
 	extraMarginLeft = 0
 	extraMarginTop  = 0
 )
 
-func stringSize(s string, ft fonts.Font, size Fl) Fl {
-	var out Fl
-	for _, r := range s {
-		out += ft.GetWidth(r, size)
-	}
-	return out
-}
-
 func (t fieldAppearanceBuilder) buildAppearance(ufont fonts.BuiltFont, fontSize Fl) *model.XObjectForm {
 	app := t.getBorderAppearance()
 	app.BeginVariableText()
@@ -313,83 +206,37 @@ func (t fieldAppearanceBuilder) buildAppearance(ufont fonts.BuiltFont, fontSize
 	if (t.options & model.Password) != 0 {
 		ptext = strings.Repeat("*", len(ptextRunes))
 	}
-	if (t.options & model.Multiline) != 0 {
-		usize := fontSize
-		width := t.box.Width() - 3*offsetX - extraMarginLeft
-		breaks := getHardBreaks(ptext)
-		lines := breaks
-		factor := (fd.FontBBox.Urx - fd.FontBBox.Lly) / 1000
-		if usize == 0 {
-			usize = h / Fl(len(breaks)) / factor
-			if usize > 4 {
-				if usize > 12 {
-					usize = 12
-				}
-				step := maxF((usize-4)/10, 0.2)
-				for ; usize > 4; usize -= step {
-					lines = breakLines(breaks, ufont, usize, width)
-					if Fl(len(lines))*usize*factor <= h {
-						break
-					}
-				}
-			}
-			if usize <= 4 {
-				usize = 4
-				lines = breakLines(breaks, ufont, usize, width)
-			}
-		} else {
-			lines = breakLines(breaks, ufont, usize, width)
-		}
-		app.SetFontAndSize(ufont, usize)
-		app.SetLeading(usize * factor)
+	layout := fonts.TextLayout{
+		Font:      ufont,
+		Width:     t.box.Width() - 4*offsetX - extraMarginLeft,
+		Height:    h,
+		Quadding:  t.alignment,
+		Multiline: (t.options & model.Multiline) != 0,
+	}
+	originX := extraMarginLeft + 2*offsetX
+	usize := fontSize
+	if usize == 0 {
+		usize = layout.FitSize(ptext)
+	}
+	app.SetFontAndSize(ufont, usize)
+	if layout.Multiline {
+		leading := layout.LineHeight(usize)
+		app.SetLeading(leading)
 		offsetY := offsetX + h - fd.FontBBox.Ury*usize/1000
-		nt := lines[0]
-		switch t.alignment {
-		case model.RightJustified:
-			wd := stringSize(nt, ufont, usize)
-			app.MoveText(extraMarginLeft+t.box.Width()-2*offsetX-wd, offsetY)
-		case model.Centered:
-			nt = strings.TrimSpace(nt)
-			wd := stringSize(nt, ufont, usize)
-			app.MoveText(extraMarginLeft+t.box.Width()/2-wd/2, offsetY)
-		default:
-			app.MoveText(extraMarginLeft+2*offsetX, offsetY)
-		}
-		_ = app.ShowText(nt) // its clear font size was set
-		maxline := int(h/usize/factor) + 1
+		lines := layout.Lines(ptext, usize)
+		maxline := int(h/leading) + 1
 		if maxline > len(lines) {
 			maxline = len(lines)
 		}
-		for k := 1; k < maxline; k++ {
-			nt := lines[k]
-			if t.alignment == model.RightJustified {
-				wd := stringSize(nt, ufont, usize)
-				app.MoveText(extraMarginLeft+t.box.Width()-2*offsetX-wd-app.State.XTLM, 0)
-			} else if t.alignment == model.Centered {
-				nt = strings.TrimSpace(nt)
-				wd := stringSize(nt, ufont, usize)
-				app.MoveText(extraMarginLeft+t.box.Width()/2-wd/2-app.State.XTLM, 0)
+		app.MoveText(originX+lines[0].X, offsetY)
+		_ = app.ShowText(lines[0].Text) // its clear font size was set
+		for _, line := range lines[1:maxline] {
+			if dx := originX + line.X - app.State.XTLM; dx != 0 {
+				app.MoveText(dx, 0)
 			}
-			app.NewlineShowText(nt)
+			app.NewlineShowText(line.Text)
 		}
 	} else {
-		usize := fontSize
-		if usize == 0 {
-			maxCalculatedSize := h / ((fd.FontBBox.Urx - fd.FontBBox.Lly) / 1000)
-			wd := stringSize(ptext, ufont, 1)
-			if wd == 0 {
-				usize = maxCalculatedSize
-			} else {
-				usize = (t.box.Width() - extraMarginLeft - 2*offsetX) / wd
-			}
-			if usize > maxCalculatedSize {
-				usize = maxCalculatedSize
-			}
-			if usize < 4 {
-				usize = 4
-			}
-		}
-		app.SetFontAndSize(ufont, usize)
 		offsetY := offX + ((t.box.Height()-2*offX)-(fd.Ascent*usize/1000))/2
 		if offsetY < offX {
 			offsetY = offX
@@ -400,34 +247,16 @@ func (t fieldAppearanceBuilder) buildAppearance(ufont fonts.BuiltFont, fontSize
 			offsetY = minF(ny, maxF(offsetY, dy))
 		}
 		if maxL, _ := t.maxCharacterLength.(model.ObjInt); (t.options&model.Comb) != 0 && maxL > 0 {
-			textLen := min(int(maxL), len(ptextRunes))
-			var position Fl
-			if t.alignment == model.RightJustified {
-				position = Fl(int(maxL) - textLen)
-			} else if t.alignment == model.Centered {
-				position = Fl(int(maxL)-textLen) / 2
-			}
-			step := (t.box.Width() - extraMarginLeft) / Fl(int(maxL))
-			start := step/2 + position*step
-			for k := 0; k < textLen; k++ {
-				c := ptextRunes[k]
-				wd := ufont.GetWidth(c, usize)
-				app.SetTextMatrix(1, 0, 0, 1, extraMarginLeft+start-wd/2, offsetY-extraMarginTop)
-				_ = app.ShowText(string(c)) // its clear font size was set
-				start += step
+			// the cells use the whole width
+			layout.Width, layout.MaxLen = t.box.Width()-extraMarginLeft, int(maxL)
+			for _, cell := range layout.Comb(ptext, usize) {
+				app.SetTextMatrix(1, 0, 0, 1, extraMarginLeft+cell.X, offsetY-extraMarginTop)
+				_ = app.ShowText(cell.Text) // its clear font size was set
 			}
 		} else {
-			switch t.alignment {
-			case model.RightJustified:
-				wd := stringSize(ptext, ufont, usize)
-				app.MoveText(extraMarginLeft+t.box.Width()-2*offsetX-wd, offsetY-extraMarginTop)
-			case model.Centered:
-				wd := stringSize(ptext, ufont, usize)
-				app.MoveText(extraMarginLeft+t.box.Width()/2-wd/2, offsetY-extraMarginTop)
-			default:
-				app.MoveText(extraMarginLeft+2*offsetX, offsetY-extraMarginTop)
-			}
-			_ = app.ShowText(ptext) // its clear font size was set
+			line := layout.Lines(ptext, usize)[0]
+			app.MoveText(originX+line.X, offsetY-extraMarginTop)
+			_ = app.ShowText(line.Text) // its clear font size was set
 		}
 	}
 	app.EndText()
@@ -443,7 +272,10 @@ func (tx *fieldAppearanceBuilder) getListAppearance(ufont fonts.BuiltFont, fontS
 		app.EndVariableText()
 		return app.ToXFormObject(true), tx.topFirst
 	}
-	topChoice := tx.choiceSelection
+	topChoice := 0 // the window is centered on the first selected item
+	if len(tx.choiceSelection) != 0 {
+		topChoice = tx.choiceSelection[0]
+	}
 	if topChoice >= len(tx.choices) {
 		topChoice = len(tx.choices) - 1
 	}
@@ -492,16 +324,25 @@ func (tx *fieldAppearanceBuilder) getListAppearance(ufont fonts.BuiltFont, fontS
 	if mColor == nil {
 		mColor = color.Gray{}
 	}
+	selected := map[int]bool{}
 	app.SetColorFill(color.NRGBA{R: 10, G: 36, B: 106, A: 255})
-	app.Ops(contentstream.OpRectangle{X: offsetX, Y: offsetX + h - Fl(topChoice-first+1)*leading, W: tx.box.Width() - 2*offsetX, H: leading})
-	app.Ops(contentstream.OpFill{})
+	for _, idx := range tx.choiceSelection {
+		if idx < first || idx >= last {
+			continue
+		}
+		selected[idx] = true
+		app.Ops(contentstream.OpRectangle{X: offsetX, Y: offsetX + h - Fl(idx-first+1)*leading, W: tx.box.Width() - 2*offsetX, H: leading})
+	}
+	if len(selected) != 0 {
+		app.Ops(contentstream.OpFill{})
+	}
 	app.BeginText()
 	app.SetFontAndSize(ufont, usize)
 	app.SetLeading(leading)
 	app.MoveText(offsetX*2, offsetX+h-fd.FontBBox.Ury*usize/1000+leading)
 	app.SetColorFill(mColor)
 	for idx := first; idx < last; idx++ {
-		if idx == topChoice {
+		if selected[idx] {
 			app.Ops(contentstream.OpSetFillGray{G: 1})
 			_ = app.NewlineShowText(tx.choices[idx]) // font was setup
 			app.SetColorFill(mColor)
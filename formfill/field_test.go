@@ -16,7 +16,10 @@ func TestBreaks(t *testing.T) {
 	if err != nil {
 		t.Errorf("can't built standard font: %s", err)
 	}
-	fmt.Println(strings.Join(breakLines(getHardBreaks(s), font, 8, 50), "\n"))
+	layout := fonts.TextLayout{Font: font, Width: 50, Multiline: true}
+	for _, line := range layout.Lines(s, 8) {
+		fmt.Println(line.Text)
+	}
 
 	fmt.Println(font.GetWidth('i', 10), font.GetWidth('8', 10))
 }
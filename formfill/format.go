@@ -0,0 +1,115 @@
+package formfill
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/benoitkugler/pdf/model"
+)
+
+// NumberSeparator selects the digit grouping and decimal
+// separators used by the Acrobat AFNumber_Format function.
+type NumberSeparator uint8
+
+const (
+	SeparatorCommaDot      NumberSeparator = iota // 1,234.56
+	SeparatorNoneDot                              // 1234.56
+	SeparatorDotComma                             // 1.234,56
+	SeparatorNoneComma                            // 1234,56
+	SeparatorApostropheDot                        // 1'234.56
+)
+
+// NegativeStyle selects how negative numbers are displayed
+// by the Acrobat AFNumber_Format function.
+type NegativeStyle uint8
+
+const (
+	NegativeMinus     NegativeStyle = iota // -1234.56
+	NegativeRed                            // 1234.56, in red
+	NegativeParens                         // (1234.56)
+	NegativeParensRed                      // (1234.56), in red
+)
+
+// NumberFormat are the parameters of the Acrobat
+// AFNumber_Format and AFNumber_Keystroke functions.
+type NumberFormat struct {
+	Decimals  int // number of digits after the decimal separator
+	Separator NumberSeparator
+	Negative  NegativeStyle
+	// Currency is an optional symbol, such as "$" or "€",
+	// including the potential space separating it from the number.
+	Currency string
+	// CurrencyAfter writes the currency symbol after the number,
+	// instead of before.
+	CurrencyAfter bool
+}
+
+func (nf NumberFormat) args() (string, error) {
+	if nf.Decimals < 0 {
+		return "", fmt.Errorf("invalid number of decimals %d", nf.Decimals)
+	}
+	if nf.Separator > SeparatorApostropheDot {
+		return "", fmt.Errorf("invalid separator style %d", nf.Separator)
+	}
+	if nf.Negative > NegativeParensRed {
+		return "", fmt.Errorf("invalid negative style %d", nf.Negative)
+	}
+	// the currency style argument is not used by Acrobat
+	return fmt.Sprintf("%d, %d, %d, 0, %s, %t", nf.Decimals, nf.Separator, nf.Negative,
+		jsString(nf.Currency), !nf.CurrencyAfter), nil
+}
+
+// SetNumberFormat attaches to `field` the standard
+// format and keystroke actions restricting its value to
+// a number, displayed according to `format`.
+// An error is returned if the parameters are invalid, or if `field` is not
+// a text field.
+func SetNumberFormat(field *model.FormFieldDict, format NumberFormat) error {
+	args, err := format.args()
+	if err != nil {
+		return err
+	}
+	return setFormatActions(field, "AFNumber_Format("+args+");", "AFNumber_Keystroke("+args+");")
+}
+
+// SetPercentFormat is the same as SetNumberFormat, but displays
+// the value as a percentage, using the Acrobat AFPercent_Format function.
+func SetPercentFormat(field *model.FormFieldDict, decimals int, separator NumberSeparator) error {
+	if _, err := (NumberFormat{Decimals: decimals, Separator: separator}).args(); err != nil {
+		return err
+	}
+	args := fmt.Sprintf("%d, %d", decimals, separator)
+	return setFormatActions(field, "AFPercent_Format("+args+");", "AFPercent_Keystroke("+args+");")
+}
+
+// SetDateFormat attaches to `field` the standard format and
+// keystroke actions restricting its value to a date, displayed
+// according to `pattern`, such as "mm/dd/yyyy" or "dd mmm yyyy HH:MM".
+// See the Acrobat AFDate_FormatEx function for the supported patterns.
+func SetDateFormat(field *model.FormFieldDict, pattern string) error {
+	if pattern == "" {
+		return errors.New("missing date pattern")
+	}
+	arg := jsString(pattern)
+	return setFormatActions(field, "AFDate_FormatEx("+arg+");", "AFDate_KeystrokeEx("+arg+");")
+}
+
+func setFormatActions(field *model.FormFieldDict, format, keystroke string) error {
+	ft := field.FT
+	for parent := field.Parent; ft == nil && parent != nil; parent = parent.Parent { // inherited type
+		ft = parent.FT
+	}
+	if _, ok := ft.(model.FormFieldText); !ok {
+		return fmt.Errorf("formatting actions require a text field, got %T", ft)
+	}
+	field.AA.F = model.Action{ActionType: model.ActionJavaScript{JS: format}}
+	field.AA.K = model.Action{ActionType: model.ActionJavaScript{JS: keystroke}}
+	return nil
+}
+
+// jsString returns a JavaScript string literal
+func jsString(s string) string {
+	b, _ := json.Marshal(s) // a string can't fail
+	return string(b)
+}
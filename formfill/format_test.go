@@ -0,0 +1,57 @@
+package formfill
+
+import (
+	"testing"
+
+	"github.com/benoitkugler/pdf/model"
+)
+
+func TestSetFormat(t *testing.T) {
+	parent := &model.FormFieldDict{FormFieldInheritable: model.FormFieldInheritable{FT: model.FormFieldText{}}}
+	field := &model.FormFieldDict{Parent: parent} // inherited type
+
+	err := SetNumberFormat(field, NumberFormat{Decimals: 2, Separator: SeparatorDotComma, Negative: NegativeParens, Currency: " €", CurrencyAfter: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := `AFNumber_Format(2, 2, 2, 0, " €", false);`; javascript(field.AA.F) != exp {
+		t.Fatalf("expected %s, got %s", exp, javascript(field.AA.F))
+	}
+	if exp := `AFNumber_Keystroke(2, 2, 2, 0, " €", false);`; javascript(field.AA.K) != exp {
+		t.Fatalf("expected %s, got %s", exp, javascript(field.AA.K))
+	}
+	if hint := parseFormatHint(field.AA); hint == nil || hint.Kind != "number" || hint.Decimals != 2 {
+		t.Fatalf("unexpected format hint %v", hint)
+	}
+
+	if err = SetPercentFormat(field, 1, SeparatorNoneDot); err != nil {
+		t.Fatal(err)
+	}
+	if hint := parseFormatHint(field.AA); hint == nil || hint.Kind != "percent" || hint.Decimals != 1 {
+		t.Fatalf("unexpected format hint %v", hint)
+	}
+
+	if err = SetDateFormat(field, `dd/mm/yyyy "at" HH:MM`); err != nil {
+		t.Fatal(err)
+	}
+	if exp := `AFDate_FormatEx("dd/mm/yyyy \"at\" HH:MM");`; javascript(field.AA.F) != exp {
+		t.Fatalf("expected %s, got %s", exp, javascript(field.AA.F))
+	}
+	if hint := parseFormatHint(model.FormFielAdditionalActions{K: field.AA.K}); hint == nil || hint.Kind != "date" {
+		t.Fatalf("unexpected format hint %v", hint)
+	}
+
+	for _, err := range []error{
+		SetNumberFormat(field, NumberFormat{Decimals: -1}),
+		SetNumberFormat(field, NumberFormat{Separator: 8}),
+		SetNumberFormat(field, NumberFormat{Negative: 4}),
+		SetPercentFormat(field, 0, 5),
+		SetDateFormat(field, ""),
+		SetDateFormat(&model.FormFieldDict{FormFieldInheritable: model.FormFieldInheritable{FT: model.FormFieldButton{}}}, "mm/dd/yyyy"),
+		SetNumberFormat(&model.FormFieldDict{}, NumberFormat{}),
+	} {
+		if err == nil {
+			t.Fatal("expected error for invalid parameters")
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package formfill
+
+import (
+	"fmt"
+	"image/color"
+
+	cs "github.com/benoitkugler/pdf/contentstream"
+	"github.com/benoitkugler/pdf/fonts"
+	"github.com/benoitkugler/pdf/model"
+)
+
+var (
+	previewStroke = color.RGBA{R: 0, G: 70, B: 200, A: 255}
+	previewFill   = color.RGBA{R: 210, G: 225, B: 255, A: 255}
+)
+
+const (
+	previewFillAlpha   = 0.5
+	previewMaxFontSize = 8
+	previewMinFontSize = 4
+)
+
+// fieldTypeLabel returns a short description of the field type
+func fieldTypeLabel(field model.FormFieldInheritable) string {
+	switch field.FT.(type) {
+	case model.FormFieldText:
+		return "Text"
+	case model.FormFieldButton:
+		if field.Ff&model.Pushbutton != 0 {
+			return "Push button"
+		} else if field.Ff&model.Radio != 0 {
+			return "Radio"
+		}
+		return "Check box"
+	case model.FormFieldChoice:
+		if field.Ff&model.Combo != 0 {
+			return "Combo box"
+		}
+		return "List box"
+	case model.FormFieldSignature:
+		return "Signature"
+	default:
+		return "Unknown"
+	}
+}
+
+// collect the pages, with their (inherited) resources
+func pagesWithResources(node model.PageNode, inherited *model.ResourcesDict, out map[*model.PageObject]*model.ResourcesDict) {
+	switch node := node.(type) {
+	case *model.PageTree:
+		if node.Resources != nil {
+			inherited = node.Resources
+		}
+		for _, kid := range node.Kids {
+			pagesWithResources(kid, inherited, out)
+		}
+	case *model.PageObject:
+		if node.Resources != nil {
+			inherited = node.Resources
+		}
+		out[node] = inherited
+	}
+}
+
+// ExportFieldsPreview returns a static copy of `doc`, where the form fields
+// are removed, and each of their widgets is replaced by its bounding box,
+// labeled with the field fully qualified name and its type.
+// It may be used to map the field names of a form, before filling it.
+// `doc` is not modified.
+func ExportFieldsPreview(doc *model.Document) (model.Document, error) {
+	out := doc.Clone()
+
+	font, err := fonts.BuildFont(defaultFont)
+	if err != nil {
+		return model.Document{}, err
+	}
+
+	type label struct {
+		rect model.Rectangle
+		text string
+	}
+	// widget -> label
+	labels := make(map[*model.AnnotationDict]label)
+	for name, field := range out.Catalog.AcroForm.Flatten() {
+		text := fmt.Sprintf("%s (%s)", name, fieldTypeLabel(field.Merged))
+		for _, widget := range field.Field.Widgets {
+			labels[widget.AnnotationDict] = label{rect: getNormalizedRectangle(widget.Rect), text: text}
+		}
+	}
+
+	resources := make(map[*model.PageObject]*model.ResourcesDict)
+	pagesWithResources(&out.Catalog.Pages, nil, resources)
+
+	for _, page := range out.Catalog.Pages.Flatten() {
+		var (
+			annots     []*model.AnnotationDict
+			pageLabels []label
+		)
+		for _, annot := range page.Annots {
+			if l, isField := labels[annot]; isField {
+				pageLabels = append(pageLabels, l)
+			} else if _, isWidget := annot.Subtype.(model.AnnotationWidget); !isWidget {
+				annots = append(annots, annot)
+			}
+		}
+		page.Annots = annots
+		if len(pageLabels) == 0 {
+			continue
+		}
+
+		var bbox model.Rectangle
+		if page.MediaBox != nil {
+			bbox = *page.MediaBox
+		} else if out.Catalog.Pages.MediaBox != nil {
+			bbox = *out.Catalog.Pages.MediaBox
+		}
+		app := cs.NewGraphicStream(bbox)
+		for _, l := range pageLabels {
+			if err = drawFieldPreview(&app, font, l.rect, l.text); err != nil {
+				return model.Document{}, err
+			}
+		}
+
+		// add the overlay, without modifying the (potentially shared) resources
+		var res model.ResourcesDict
+		if inherited := resources[page]; inherited != nil {
+			res = inherited.ShallowCopy()
+		} else {
+			res = model.NewResourcesDict()
+		}
+		xObjectName := model.Name("FieldsPreview")
+		for i := 1; res.XObject[xObjectName] != nil; i++ {
+			xObjectName = model.Name(fmt.Sprintf("FieldsPreview%d", i))
+		}
+		res.XObject[xObjectName] = app.ToXFormObject(true)
+		page.Resources = &res
+
+		// isolate the original content from the overlay
+		page.Contents = append([]model.ContentStream{{Stream: model.Stream{Content: cs.WriteOperations(cs.OpSave{})}}}, page.Contents...)
+		page.Contents = append(page.Contents, model.ContentStream{Stream: model.Stream{
+			Content: cs.WriteOperations(cs.OpRestore{}, cs.OpSave{}, cs.OpXObject{XObject: xObjectName}, cs.OpRestore{}),
+		}})
+	}
+
+	out.Catalog.AcroForm = model.AcroForm{}
+
+	return out, nil
+}
+
+// drawFieldPreview draws the rectangle of a widget, with the given label
+func drawFieldPreview(app *cs.GraphicStream, font fonts.BuiltFont, rect model.Rectangle, text string) error {
+	app.SaveState()
+	app.SetFillAlpha(previewFillAlpha)
+	app.SetColorFill(previewFill)
+	app.SetColorStroke(previewStroke)
+	app.Ops(
+		cs.OpSetLineWidth{W: 0.5},
+		cs.OpRectangle{X: rect.Llx, Y: rect.Lly, W: rect.Width(), H: rect.Height()},
+		cs.OpFillStroke{},
+	)
+	if err := app.RestoreState(); err != nil {
+		return err
+	}
+
+	fontSize := minF(previewMaxFontSize, rect.Height()*0.7)
+	fontSize = maxF(previewMinFontSize, fontSize)
+	app.SaveState()
+	app.SetColorFill(previewStroke)
+	app.BeginText()
+	app.SetFontAndSize(font, fontSize)
+	app.MoveText(rect.Llx+2, rect.Lly+(rect.Height()-fontSize)/2+0.2*fontSize)
+	if err := app.ShowText(text); err != nil {
+		return err
+	}
+	app.EndText()
+	return app.RestoreState()
+}
@@ -0,0 +1,51 @@
+package formfill
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/benoitkugler/pdf/model"
+	"github.com/benoitkugler/pdf/reader"
+)
+
+func TestExportFieldsPreview(t *testing.T) {
+	doc, _, err := reader.ParsePDFFile("test/sample3.pdf", reader.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nbFields := len(doc.Catalog.AcroForm.Flatten())
+	if nbFields == 0 {
+		t.Fatal("expected form fields")
+	}
+
+	preview, err := ExportFieldsPreview(&doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Catalog.AcroForm.Flatten()) != nbFields {
+		t.Fatal("original document should not be modified")
+	}
+	if len(preview.Catalog.AcroForm.Fields) != 0 {
+		t.Fatal("form fields should be removed")
+	}
+
+	hasPreview := false
+	for _, page := range preview.Catalog.Pages.Flatten() {
+		for _, annot := range page.Annots {
+			if _, isWidget := annot.Subtype.(model.AnnotationWidget); isWidget {
+				t.Fatal("widgets should be removed")
+			}
+		}
+		if page.Resources != nil && page.Resources.XObject["FieldsPreview"] != nil {
+			hasPreview = true
+		}
+	}
+	if !hasPreview {
+		t.Fatal("missing fields preview")
+	}
+
+	var buf bytes.Buffer
+	if err = preview.Write(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+}
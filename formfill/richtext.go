@@ -0,0 +1,246 @@
+package formfill
+
+import (
+	"encoding/xml"
+	"image/color"
+	"strconv"
+	"strings"
+
+	"github.com/benoitkugler/pdf/fonts/standardfonts"
+	"github.com/benoitkugler/pdf/model"
+)
+
+// richTextToPlain extracts the text content of the XHTML rich value `rv`,
+// to be used as plain text value (V entry) and displayed in appearance streams.
+// Paragraphs and line breaks are converted to new lines.
+// Malformed input is processed up to the first error.
+func richTextToPlain(rv string) string {
+	dec := xml.NewDecoder(strings.NewReader(rv))
+	dec.Strict = false
+	dec.AutoClose = xml.HTMLAutoClose
+	dec.Entity = xml.HTMLEntity
+	var b strings.Builder
+	for {
+		tok, err := dec.Token()
+		if err != nil { // io.EOF or invalid input
+			break
+		}
+		switch tok := tok.(type) {
+		case xml.CharData:
+			// ignore the indentation between tags
+			if strings.TrimSpace(string(tok)) == "" && strings.ContainsAny(string(tok), "\r\n") {
+				continue
+			}
+			b.Write(tok)
+		case xml.StartElement:
+			if tok.Name.Local == "br" {
+				b.WriteByte('\n')
+			}
+		case xml.EndElement:
+			switch tok.Name.Local {
+			case "p", "div", "li":
+				b.WriteByte('\n')
+			}
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// parseDefaultStyle approximates the CSS declarations of a
+// default style string (DS entry), such as "font: 12pt Helvetica; color:#FF0000".
+// Only the font (mapped to a standard font), size, color and alignment are supported.
+// It returns nil if no supported declaration is found.
+func parseDefaultStyle(ds string) *Style {
+	var (
+		out            Style
+		family         string
+		bold, italic   bool
+		hasFontChanges bool
+	)
+	for _, decl := range strings.Split(ds, ";") {
+		colon := strings.IndexByte(decl, ':')
+		if colon == -1 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(decl[:colon]))
+		value := strings.TrimSpace(decl[colon+1:])
+		switch key {
+		case "font":
+			// font: [style] [weight] size family
+			fields := strings.Fields(value)
+			for i, field := range fields {
+				if size, ok := parseFontSize(field); ok {
+					out.FontSize = size
+					family = strings.Join(fields[i+1:], " ")
+					break
+				}
+				switch strings.ToLower(field) {
+				case "bold", "bolder", "600", "700", "800", "900":
+					bold = true
+				case "italic", "oblique":
+					italic = true
+				}
+			}
+			hasFontChanges = true
+		case "font-family":
+			family = value
+			hasFontChanges = true
+		case "font-size":
+			if size, ok := parseFontSize(value); ok {
+				out.FontSize = size
+			}
+		case "font-weight":
+			switch strings.ToLower(value) {
+			case "bold", "bolder", "600", "700", "800", "900":
+				bold = true
+			}
+			hasFontChanges = true
+		case "font-style":
+			switch strings.ToLower(value) {
+			case "italic", "oblique":
+				italic = true
+			}
+			hasFontChanges = true
+		case "color":
+			if c, ok := parseCSSColor(value); ok {
+				out.Color = c
+			}
+		case "text-align":
+			var q model.Quadding
+			switch strings.ToLower(value) {
+			case "center":
+				q = model.Centered
+			case "right":
+				q = model.RightJustified
+			}
+			out.Alignment = &q
+		}
+	}
+	if hasFontChanges {
+		out.Font = &model.FontDict{Subtype: standardFont(family, bold, italic).WesternType1Font()}
+	}
+	if out == (Style{}) {
+		return nil
+	}
+	return &out
+}
+
+// parseFontSize parses a CSS length in points, such as 12pt or 10.5
+func parseFontSize(s string) (Fl, bool) {
+	s = strings.TrimSuffix(strings.ToLower(s), "pt")
+	size, err := strconv.ParseFloat(s, 64)
+	if err != nil || size <= 0 {
+		return 0, false
+	}
+	return Fl(size), true
+}
+
+// parseCSSColor supports the #rgb, #rrggbb and rgb(r, g, b) syntaxes
+func parseCSSColor(s string) (color.Color, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if strings.HasPrefix(s, "rgb(") && strings.HasSuffix(s, ")") {
+		comps := strings.Split(s[4:len(s)-1], ",")
+		if len(comps) != 3 {
+			return nil, false
+		}
+		var rgb [3]uint8
+		for i, comp := range comps {
+			v, err := strconv.ParseUint(strings.TrimSpace(comp), 10, 8)
+			if err != nil {
+				return nil, false
+			}
+			rgb[i] = uint8(v)
+		}
+		return color.RGBA{R: rgb[0], G: rgb[1], B: rgb[2], A: 0xff}, true
+	}
+	if !strings.HasPrefix(s, "#") {
+		return nil, false
+	}
+	hex := s[1:]
+	if len(hex) == 3 { // expand #rgb
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return nil, false
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil, false
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, true
+}
+
+// standardFont maps a CSS font family list to the closest standard font
+func standardFont(family string, bold, italic bool) standardfonts.Metrics {
+	first := strings.Split(family, ",")[0]
+	first = strings.ToLower(strings.Trim(strings.TrimSpace(first), `"'`))
+	switch {
+	case strings.Contains(first, "courier") || strings.Contains(first, "mono"):
+		switch {
+		case bold && italic:
+			return standardfonts.Courier_BoldOblique
+		case bold:
+			return standardfonts.Courier_Bold
+		case italic:
+			return standardfonts.Courier_Oblique
+		}
+		return standardfonts.Courier
+	case strings.Contains(first, "times") || (strings.Contains(first, "serif") && !strings.Contains(first, "sans")):
+		switch {
+		case bold && italic:
+			return standardfonts.Times_BoldItalic
+		case bold:
+			return standardfonts.Times_Bold
+		case italic:
+			return standardfonts.Times_Italic
+		}
+		return standardfonts.Times_Roman
+	default:
+		switch {
+		case bold && italic:
+			return standardfonts.Helvetica_BoldOblique
+		case bold:
+			return standardfonts.Helvetica_Bold
+		case italic:
+			return standardfonts.Helvetica_Oblique
+		}
+		return standardfonts.Helvetica
+	}
+}
+
+// themeStyle returns the text style defined by `theme`, or nil
+func themeStyle(theme *model.Theme) *Style {
+	if theme == nil {
+		return nil
+	}
+	out := Style{FontSize: theme.FontSize, Color: theme.TextColor.Color(), Font: theme.Font}
+	if out == (Style{}) {
+		return nil
+	}
+	return &out
+}
+
+// mergeStyles returns `override` completed by `base`,
+// one of them possibly being nil
+func mergeStyles(base, override *Style) *Style {
+	if base == nil {
+		return override
+	}
+	if override == nil {
+		return base
+	}
+	out := *base
+	if override.FontSize != 0 {
+		out.FontSize = override.FontSize
+	}
+	if override.Color != nil {
+		out.Color = override.Color
+	}
+	if override.Alignment != nil {
+		out.Alignment = override.Alignment
+	}
+	if override.Font != nil {
+		out.Font = override.Font
+	}
+	return &out
+}
@@ -0,0 +1,193 @@
+package formfill
+
+import (
+	"encoding/json"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/benoitkugler/pdf/model"
+)
+
+// FieldSchema describes the constraints a value must
+// satisfy to be valid for a terminal field.
+// It is designed to be serialized as JSON, so that
+// applications (such as web frontends) may validate user input
+// without processing the PDF file.
+type FieldSchema struct {
+	Name  string `json:"name"`            // fully qualified name
+	Label string `json:"label,omitempty"` // alternate name (TU entry)
+	// Type is one of text, checkbox, radio, pushbutton,
+	// combo, list or signature
+	Type     string `json:"type"`
+	Required bool   `json:"required,omitempty"`
+	ReadOnly bool   `json:"readOnly,omitempty"`
+
+	MaxLength int `json:"maxLength,omitempty"` // for text fields, 0 means no limit
+	// Options are the accepted values for choice and button fields
+	// (export values or appearance states)
+	Options     []string `json:"options,omitempty"`
+	Editable    bool     `json:"editable,omitempty"`    // combo box accepting values outside Options
+	MultiSelect bool     `json:"multiSelect,omitempty"` // list box accepting several values
+
+	Format *FormatHint `json:"format,omitempty"`
+}
+
+// FormatHint is a declarative version of the formatting
+// and validation scripts commonly used by PDF writers
+// (such as the Acrobat AFNumber_Format function).
+type FormatHint struct {
+	// Kind is one of number, percent, date, time,
+	// zip, zip4, phone, ssn or mask
+	Kind     string `json:"kind,omitempty"`
+	Decimals int    `json:"decimals,omitempty"` // for number and percent
+	// Pattern is the date or time format (such as mm/dd/yyyy),
+	// or the arbitrary mask (9 for a digit, A for a letter, X for any character)
+	Pattern string   `json:"pattern,omitempty"`
+	Min     *float64 `json:"min,omitempty"` // inclusive range, from AFRange_Validate
+	Max     *float64 `json:"max,omitempty"`
+}
+
+var (
+	reNumberFormat  = regexp.MustCompile(`AF(Number|Percent)_(?:Format|Keystroke)\(\s*(\d+)`)
+	reDateFormat    = regexp.MustCompile(`AF(Date|Time)_(?:Format|Keystroke)Ex\(\s*"([^"]*)"`)
+	reSpecialFormat = regexp.MustCompile(`AFSpecial_(?:Format|Keystroke)\(\s*(\d)`)
+	reSpecialMask   = regexp.MustCompile(`AFSpecial_KeystrokeEx\(\s*"([^"]*)"`)
+	reRange         = regexp.MustCompile(`AFRange_Validate\(\s*(true|false)\s*,\s*([-+.\d]+)\s*,\s*(true|false)\s*,\s*([-+.\d]+)`)
+)
+
+var specialFormats = [...]string{"zip", "zip4", "phone", "ssn"}
+
+// javascript returns the script of `action`, or an empty string
+func javascript(action model.Action) string {
+	js, _ := action.ActionType.(model.ActionJavaScript)
+	return js.JS
+}
+
+// parseFormatHint inspects the format, keystroke and validation
+// scripts of `aa`, and returns nil if no known function is found.
+func parseFormatHint(aa model.FormFielAdditionalActions) *FormatHint {
+	var out FormatHint
+	for _, script := range [...]string{javascript(aa.F), javascript(aa.K)} {
+		if out.Kind != "" {
+			break
+		}
+		if m := reNumberFormat.FindStringSubmatch(script); m != nil {
+			out.Kind = "number"
+			if m[1] == "Percent" {
+				out.Kind = "percent"
+			}
+			out.Decimals, _ = strconv.Atoi(m[2])
+		} else if m := reDateFormat.FindStringSubmatch(script); m != nil {
+			out.Kind = "date"
+			if m[1] == "Time" {
+				out.Kind = "time"
+			}
+			out.Pattern = m[2]
+		} else if m := reSpecialFormat.FindStringSubmatch(script); m != nil {
+			if i, _ := strconv.Atoi(m[1]); i < len(specialFormats) {
+				out.Kind = specialFormats[i]
+			}
+		} else if m := reSpecialMask.FindStringSubmatch(script); m != nil {
+			out.Kind, out.Pattern = "mask", m[1]
+		}
+	}
+	if m := reRange.FindStringSubmatch(javascript(aa.V)); m != nil {
+		if m[1] == "true" {
+			if v, err := strconv.ParseFloat(m[2], 64); err == nil {
+				out.Min = &v
+			}
+		}
+		if m[3] == "true" {
+			if v, err := strconv.ParseFloat(m[4], 64); err == nil {
+				out.Max = &v
+			}
+		}
+	}
+	if out == (FormatHint{}) {
+		return nil
+	}
+	return &out
+}
+
+func newFieldSchema(name string, field model.FormFieldInherited) FieldSchema {
+	flags := field.Merged.Ff
+	out := FieldSchema{
+		Name:     name,
+		Label:    field.Field.TU,
+		Required: flags&model.Required != 0,
+		ReadOnly: flags&model.ReadOnly != 0,
+		Format:   parseFormatHint(field.Field.AA),
+	}
+	switch ft := field.Merged.FT.(type) {
+	case model.FormFieldText:
+		out.Type = "text"
+		if maxLen, ok := ft.MaxLen.(model.ObjInt); ok {
+			out.MaxLength = int(maxLen)
+		}
+	case model.FormFieldButton:
+		switch {
+		case flags&model.Pushbutton != 0:
+			out.Type = "pushbutton"
+		case flags&model.Radio != 0:
+			out.Type = "radio"
+		default:
+			out.Type = "checkbox"
+		}
+		if out.Type != "pushbutton" {
+			for _, state := range field.Field.AppearanceKeys() {
+				if state != "Off" {
+					out.Options = append(out.Options, string(state))
+				}
+			}
+		}
+	case model.FormFieldChoice:
+		out.Type = "list"
+		if flags&model.Combo != 0 {
+			out.Type = "combo"
+			out.Editable = flags&model.Edit != 0
+		} else {
+			out.MultiSelect = flags&model.MultiSelect != 0
+		}
+		for _, opt := range ft.Opt {
+			if opt.Export != "" {
+				out.Options = append(out.Options, opt.Export)
+			} else {
+				out.Options = append(out.Options, opt.Name)
+			}
+		}
+	case model.FormFieldSignature:
+		out.Type = "signature"
+	}
+	return out
+}
+
+// ValidationSchema returns the validation constraints
+// of the terminal fields of `form`, sorted by name.
+// The constraints are deduced from the field flags, types and options,
+// and from the usual formatting scripts, which are not executed.
+// Non terminal fields are ignored.
+func ValidationSchema(form model.AcroForm) []FieldSchema {
+	fields := form.Flatten()
+	out := make([]FieldSchema, 0, len(fields))
+	for name, field := range fields {
+		if len(field.Field.Kids) != 0 {
+			continue
+		}
+		out = append(out, newFieldSchema(name, field))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// WriteValidationSchema writes the JSON encoding of
+// the validation schema of `form` (see ValidationSchema),
+// as an object with a "fields" entry.
+func WriteValidationSchema(form model.AcroForm, w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", " ")
+	return enc.Encode(struct {
+		Fields []FieldSchema `json:"fields"`
+	}{Fields: ValidationSchema(form)})
+}
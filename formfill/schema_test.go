@@ -0,0 +1,68 @@
+package formfill
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/benoitkugler/pdf/model"
+)
+
+func jsAction(js string) model.Action {
+	return model.Action{ActionType: model.ActionJavaScript{JS: js}}
+}
+
+func TestValidationSchema(t *testing.T) {
+	checkbox := model.FormFieldWidget{AnnotationDict: &model.AnnotationDict{
+		BaseAnnotation: model.BaseAnnotation{AP: &model.AppearanceDict{N: model.AppearanceEntry{"Yes": nil, "Off": nil}}},
+	}}
+	section := &model.FormFieldDict{T: "section", FormFieldInheritable: model.FormFieldInheritable{Ff: model.Required}}
+	section.Kids = []*model.FormFieldDict{
+		{
+			T: "amount", Parent: section, TU: "Amount",
+			FormFieldInheritable: model.FormFieldInheritable{FT: model.FormFieldText{MaxLen: model.ObjInt(8)}},
+			AA: model.FormFielAdditionalActions{
+				F: jsAction(`AFNumber_Format(2, 0, 0, 0, "", true);`),
+				V: jsAction(`AFRange_Validate(true, 0, true, 1000);`),
+			},
+		},
+		{
+			T: "date", Parent: section,
+			FormFieldInheritable: model.FormFieldInheritable{FT: model.FormFieldText{}},
+			AA:                   model.FormFielAdditionalActions{K: jsAction(`AFDate_KeystrokeEx("mm/dd/yyyy");`)},
+		},
+	}
+	form := model.AcroForm{Fields: []*model.FormFieldDict{
+		section,
+		{T: "zip", FormFieldInheritable: model.FormFieldInheritable{FT: model.FormFieldText{}}, AA: model.FormFielAdditionalActions{F: jsAction("AFSpecial_Format(0);")}},
+		{T: "accept", FormFieldInheritable: model.FormFieldInheritable{FT: model.FormFieldButton{}, Ff: model.Required}, Widgets: []model.FormFieldWidget{checkbox}},
+		{T: "color", FormFieldInheritable: model.FormFieldInheritable{FT: model.FormFieldChoice{Opt: []model.Option{{Name: "Red"}, {Export: "b", Name: "Blue"}}}, Ff: model.Combo | model.Edit}},
+	}}
+
+	min, max := 0., 1000.
+	expected := []FieldSchema{
+		{Name: "accept", Type: "checkbox", Required: true, Options: []string{"Yes"}},
+		{Name: "color", Type: "combo", Options: []string{"Red", "b"}, Editable: true},
+		{Name: "section.amount", Label: "Amount", Type: "text", Required: true, MaxLength: 8, Format: &FormatHint{Kind: "number", Decimals: 2, Min: &min, Max: &max}},
+		{Name: "section.date", Type: "text", Required: true, Format: &FormatHint{Kind: "date", Pattern: "mm/dd/yyyy"}},
+		{Name: "zip", Type: "text", Format: &FormatHint{Kind: "zip"}},
+	}
+	if got := ValidationSchema(form); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteValidationSchema(form, &buf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Fields []FieldSchema `json:"fields"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.Fields, expected) {
+		t.Fatalf("expected %v, got %v", expected, decoded.Fields)
+	}
+}
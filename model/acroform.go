@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -67,6 +68,44 @@ func (f FormFieldInheritable) merge(parent FormFieldInheritable) FormFieldInheri
 	return f
 }
 
+// Kind returns a description of the type of the field, using
+// its flags: "text", "multiline text", "password", "file select",
+// "checkbox", "radio", "pushbutton", "combo box", "list box" or "signature".
+// An empty string is returned if the type is unknown.
+func (f FormFieldInheritable) Kind() string {
+	switch f.FT.(type) {
+	case FormFieldText:
+		switch {
+		case f.Ff&Password != 0:
+			return "password"
+		case f.Ff&FileSelect != 0:
+			return "file select"
+		case f.Ff&Multiline != 0:
+			return "multiline text"
+		default:
+			return "text"
+		}
+	case FormFieldButton:
+		switch {
+		case f.Ff&Pushbutton != 0:
+			return "pushbutton"
+		case f.Ff&Radio != 0:
+			return "radio"
+		default:
+			return "checkbox"
+		}
+	case FormFieldChoice:
+		if f.Ff&Combo != 0 {
+			return "combo box"
+		}
+		return "list box"
+	case FormFieldSignature:
+		return "signature"
+	default:
+		return ""
+	}
+}
+
 // FormFieldInherited associate to a field
 // the values resolved from its ancestors
 type FormFieldInherited struct {
@@ -236,6 +275,9 @@ func (f *FormFieldDict) pdfString(pdf pdfWriter) (content string, writeObject bo
 		}
 		kids = writeRefArray(refs)
 	}
+	if kids == "" { // a field without widgets
+		return fmt.Sprintf("<<%s>>", fields), true
+	}
 
 	return fmt.Sprintf("<<%s /Kids %s>>", fields, kids), true
 }
@@ -299,6 +341,10 @@ type FormField interface {
 type FormFieldText struct {
 	V      string   // text string, may be written in PDF as a stream
 	MaxLen MaybeInt // optional
+
+	// PMD is not nil for barcode fields, whose value
+	// is displayed as a barcode.
+	PMD *PaperMetaData // optional
 }
 
 func (f FormFieldText) formFieldAttrs(pdf pdfWriter, fieldRef Reference) string {
@@ -306,10 +352,69 @@ func (f FormFieldText) formFieldAttrs(pdf pdfWriter, fieldRef Reference) string
 	if f.MaxLen != nil {
 		out += fmt.Sprintf("/MaxLen %d", f.MaxLen.(ObjInt))
 	}
+	if f.PMD != nil {
+		out += "/PMD " + f.PMD.pdfString()
+	}
 	return out
 }
 
-func (f FormFieldText) clone(cloneCache) FormField { return f }
+func (f FormFieldText) clone(cloneCache) FormField {
+	out := f
+	if f.PMD != nil {
+		pmd := *f.PMD
+		out.PMD = &pmd
+	}
+	return out
+}
+
+// Barcode symbologies, used in PaperMetaData
+const (
+	SymbologyPDF417     Name = "PDF417"
+	SymbologyQRCode     Name = "QRCode"
+	SymbologyDataMatrix Name = "DataMatrix"
+)
+
+// PaperMetaData describes a barcode field, as created by Acrobat:
+// a text field whose value is encoded and displayed as a 2D barcode.
+// It is written in PDF as the /PMD entry of the field.
+type PaperMetaData struct {
+	Symbology Name // one of PDF417, QRCode or DataMatrix (see the Symbology constants)
+	// ECC is the error correction level: 0 to 8 for PDF417,
+	// 0 to 3 (L, M, Q, H) for QRCode; it is ignored for DataMatrix.
+	ECC MaybeInt // optional
+	// NCodeWordRow and NCodeWordCol are the number of rows and columns
+	// of codewords of PDF417 barcodes.
+	NCodeWordRow, NCodeWordCol MaybeInt // optional
+	// X is the width of a module, in pixels at the given Resolution,
+	// and Y is the ratio between the height and the width of the modules
+	// of PDF417 barcodes.
+	X, Y       MaybeInt // optional
+	Resolution MaybeInt // optional, in dots per inch
+	// Caption is true to display the value of the
+	// field below the barcode.
+	Caption bool
+}
+
+func (pmd PaperMetaData) pdfString() string {
+	b := newBuffer()
+	b.fmt("<</Symbology %s", pmd.Symbology)
+	for _, entry := range [...]struct {
+		key   string
+		value MaybeInt
+	}{
+		{"ECC", pmd.ECC}, {"nCodeWordRow", pmd.NCodeWordRow}, {"nCodeWordCol", pmd.NCodeWordCol},
+		{"X", pmd.X}, {"Y", pmd.Y}, {"Resolution", pmd.Resolution},
+	} {
+		if entry.value != nil {
+			b.fmt("/%s %d", entry.key, entry.value.(ObjInt))
+		}
+	}
+	if pmd.Caption {
+		b.fmt("/Caption true")
+	}
+	b.fmt(">>")
+	return b.String()
+}
 
 // FormFieldButton represent interactive controls on the screen
 // that the user can manipulate with the mouse.
@@ -410,7 +515,7 @@ type FormFieldSignature struct {
 func (f FormFieldSignature) formFieldAttrs(pdf pdfWriter, fieldRef Reference) string {
 	out := "/FT/Sig"
 	if f.V != nil {
-		out += fmt.Sprintf("/V %s", f.V.pdfString(pdf, fieldRef))
+		out += fmt.Sprintf("/V %s", pdf.addItem(f.V))
 	}
 	if lock := f.Lock; lock != nil {
 		ref := pdf.addObject(f.Lock.pdfString(pdf, fieldRef))
@@ -425,12 +530,16 @@ func (f FormFieldSignature) formFieldAttrs(pdf pdfWriter, fieldRef Reference) st
 
 func (f FormFieldSignature) clone(cache cloneCache) FormField {
 	out := f
-	out.V = f.V.Clone()
+	if f.V != nil {
+		out.V = cache.checkOrClone(f.V).(*SignatureDict)
+	}
 	out.Lock = f.Lock.Clone()
 	out.SV = f.SV.Clone()
 	return out
 }
 
+// SignatureDict is a digital signature, used by signature fields
+// and in the document permissions (see `Perms`).
 type SignatureDict struct {
 	Filter      Name               // optional
 	SubFilter   Name               // optional
@@ -461,7 +570,7 @@ func (s SignatureDict) pdfString(pdf pdfWriter, fieldRef Reference) string {
 		b.fmt("/Filter %s", s.Filter)
 	}
 	if s.SubFilter != "" {
-		b.fmt("/SubFiler %s", s.SubFilter)
+		b.fmt("/SubFilter %s", s.SubFilter)
 	}
 	b.fmt("/Contents %s", pdf.EncodeString(s.Contents, HexString, fieldRef))
 	if len(s.Cert) != 0 {
@@ -515,6 +624,12 @@ func (s SignatureDict) pdfString(pdf pdfWriter, fieldRef Reference) string {
 	return b.String()
 }
 
+func (s *SignatureDict) pdfContent(pdf pdfWriter, ref Reference) (StreamHeader, string, []byte) {
+	return StreamHeader{}, s.pdfString(pdf, ref), nil
+}
+
+func (s *SignatureDict) clone(cloneCache) Referenceable { return s.Clone() }
+
 // Clone returns a deep copy
 func (s *SignatureDict) Clone() *SignatureDict {
 	if s == nil {
@@ -535,6 +650,46 @@ func (s *SignatureDict) Clone() *SignatureDict {
 	return &out
 }
 
+// Perms specifies the permissions granted for the document
+// by signatures. See 12.8.4 - Permissions
+// Since signatures are bound to the exact bytes of a file, writing a
+// document invalidates them: see `WriteOptions.StripUsageRights`.
+type Perms struct {
+	// optional, certification signature, which must also
+	// be referenced by a signature field
+	DocMDP *SignatureDict
+	// optional, usage rights signature, used to enable
+	// additional features in Adobe Reader ("Reader extended" documents)
+	UR3 *SignatureDict
+}
+
+func (p Perms) pdfString(pdf pdfWriter) string {
+	b := newBuffer()
+	b.WriteString("<<")
+	if p.DocMDP != nil {
+		b.fmt("/DocMDP %s", pdf.addItem(p.DocMDP))
+	}
+	if p.UR3 != nil {
+		b.fmt("/UR3 %s", pdf.addItem(p.UR3))
+	}
+	b.WriteString(">>")
+	return b.String()
+}
+
+func (p *Perms) clone(cache cloneCache) *Perms {
+	if p == nil {
+		return nil
+	}
+	var out Perms
+	if p.DocMDP != nil {
+		out.DocMDP = cache.checkOrClone(p.DocMDP).(*SignatureDict)
+	}
+	if p.UR3 != nil {
+		out.UR3 = cache.checkOrClone(p.UR3).(*SignatureDict)
+	}
+	return &out
+}
+
 // SignatureRefDict is a signature reference dictionary
 // Note: The SPEC does not restrict the Data attribute, but
 // we, as other libraries, do: we only allow it to point to the Catalog.
@@ -546,8 +701,12 @@ type SignatureRefDict struct {
 }
 
 func (s SignatureRefDict) pdfString(pdf pdfWriter, ref Reference) string {
-	return fmt.Sprintf("<</TransformMethod %s/TransformParams %s/DigestMethod %s ∕Data %s>>",
-		s.TransformMethod, s.TransformParams.transformParamsDict(pdf, ref), s.DigestMethod, pdf.catalog)
+	digest := ""
+	if s.DigestMethod != "" {
+		digest = "/DigestMethod " + s.DigestMethod.String()
+	}
+	return fmt.Sprintf("<</TransformMethod %s/TransformParams %s%s/Data %s>>",
+		s.TransformMethod, s.TransformParams.transformParamsDict(pdf, ref), digest, pdf.catalog)
 }
 
 // Clone returns a deep copy
@@ -899,6 +1058,371 @@ func (a AcroForm) Flatten() map[string]FormFieldInherited {
 	return out
 }
 
+// SetVisibility updates the visibility of all the widgets of the field,
+// including the ones of its descendants (see BaseAnnotation.SetVisibility).
+func (f *FormFieldDict) SetVisibility(v Visibility) {
+	for _, widget := range f.Widgets {
+		if widget.AnnotationDict != nil {
+			widget.SetVisibility(v)
+		}
+	}
+	for _, kid := range f.Kids {
+		kid.SetVisibility(v)
+	}
+}
+
+// SetFieldsVisibility updates the visibility of the terminal fields
+// for which `match` returns true (see FormFieldDict.SetVisibility).
+// `match` is called with the fully qualified field name (see Flatten).
+// It returns the number of fields updated.
+func (a AcroForm) SetFieldsVisibility(v Visibility, match func(name string, field FormFieldInherited) bool) int {
+	count := 0
+	for name, field := range a.Flatten() {
+		if match(name, field) {
+			field.Field.SetVisibility(v)
+			count++
+		}
+	}
+	return count
+}
+
+// siblings returns the list containing `field`:
+// either the Kids of its parent, or the top level fields.
+func (a *AcroForm) siblings(field *FormFieldDict) *[]*FormFieldDict {
+	if field.Parent != nil {
+		return &field.Parent.Kids
+	}
+	return &a.Fields
+}
+
+// lookupField returns the field with fully qualified name `name`
+func (a *AcroForm) lookupField(name string) (*FormFieldDict, error) {
+	field, ok := a.Flatten()[name]
+	if !ok {
+		return nil, newError(ErrInvalidArgument, "field %s not found", name)
+	}
+	return field.Field, nil
+}
+
+// checkPartialName returns an error if `name` is not a valid
+// partial name for a new field in `siblings`.
+func checkPartialName(name string, siblings []*FormFieldDict, ignore *FormFieldDict) error {
+	if name == "" || strings.ContainsRune(name, '.') {
+		return newError(ErrInvalidArgument, "invalid partial field name %q", name)
+	}
+	for _, sibling := range siblings {
+		if sibling != ignore && sibling.T == name {
+			return newError(ErrInvalidArgument, "a field named %s already exists", name)
+		}
+	}
+	return nil
+}
+
+// updateFieldReferences replaces `oldName` by `newName` in the
+// field names used by signature fields (Lock dictionaries and FieldMDP transforms).
+// The names of the descendants of `oldName` are also updated.
+// If `newName` is empty, these names are removed.
+func (a *AcroForm) updateFieldReferences(oldName, newName string) {
+	update := func(names []string) []string {
+		out := names[:0]
+		for _, name := range names {
+			if name == oldName || strings.HasPrefix(name, oldName+".") {
+				if newName == "" {
+					continue
+				}
+				name = newName + strings.TrimPrefix(name, oldName)
+			}
+			out = append(out, name)
+		}
+		if len(out) == 0 {
+			return nil
+		}
+		return out
+	}
+	for _, field := range a.Flatten() {
+		sig, ok := field.Field.FT.(FormFieldSignature)
+		if !ok {
+			continue
+		}
+		if sig.Lock != nil {
+			sig.Lock.Fields = update(sig.Lock.Fields)
+		}
+		if sig.V != nil {
+			for i, ref := range sig.V.Reference {
+				if mdp, ok := ref.TransformParams.(TransformFieldMDP); ok {
+					mdp.Fields = update(mdp.Fields)
+					sig.V.Reference[i].TransformParams = mdp
+				}
+			}
+		}
+	}
+}
+
+// RenameField changes the partial name of the field with fully qualified name `name`
+// (see Flatten), so that its fully qualified name (and the ones of its descendants) becomes
+// the one of its parent, followed by `newPartialName`.
+// The field names referenced by the signature fields (Lock and FieldMDP) are updated accordingly.
+// An error is returned if the field is not found, or if `newPartialName` is invalid or
+// already used by a sibling.
+func (a *AcroForm) RenameField(name, newPartialName string) error {
+	field, err := a.lookupField(name)
+	if err != nil {
+		return err
+	}
+	if err = checkPartialName(newPartialName, *a.siblings(field), field); err != nil {
+		return err
+	}
+	field.T = newPartialName
+	newName := newPartialName
+	if i := strings.LastIndexByte(name, '.'); i != -1 {
+		newName = name[:i+1] + newPartialName
+	}
+	a.updateFieldReferences(name, newName)
+	return nil
+}
+
+// RemoveField removes the field with fully qualified name `name`
+// (see Flatten) and its descendants from the form, including
+// the calculation order (CO) and the field names referenced by
+// the signature fields (Lock and FieldMDP).
+// The widgets of the removed fields are also removed from the annotations of `pages`.
+func (a *AcroForm) RemoveField(name string, pages PageTree) error {
+	field, err := a.lookupField(name)
+	if err != nil {
+		return err
+	}
+	siblings := a.siblings(field)
+	for i, sibling := range *siblings {
+		if sibling == field {
+			*siblings = append((*siblings)[:i:i], (*siblings)[i+1:]...)
+			break
+		}
+	}
+
+	// collect the removed fields and widgets
+	removed := make(map[*FormFieldDict]bool)
+	widgets := make(map[*AnnotationDict]bool)
+	var walk func(f *FormFieldDict)
+	walk = func(f *FormFieldDict) {
+		removed[f] = true
+		for _, widget := range f.Widgets {
+			widgets[widget.AnnotationDict] = true
+		}
+		for _, kid := range f.Kids {
+			walk(kid)
+		}
+	}
+	walk(field)
+
+	co := a.CO[:0:0]
+	for _, f := range a.CO {
+		if !removed[f] {
+			co = append(co, f)
+		}
+	}
+	if a.CO != nil {
+		a.CO = co
+	}
+	a.updateFieldReferences(name, "")
+
+	for _, page := range pages.Flatten() {
+		annots := page.Annots[:0:0]
+		for _, annot := range page.Annots {
+			if !widgets[annot] {
+				annots = append(annots, annot)
+			}
+		}
+		if len(annots) != len(page.Annots) {
+			page.Annots = annots
+		}
+	}
+	return nil
+}
+
+// MoveField moves the field with fully qualified name `name` (see Flatten)
+// to the kids of the field `newParent`, or to the top level fields if `newParent` is empty.
+// The field names referenced by the signature fields (Lock and FieldMDP) are updated accordingly.
+// Note that the inherited attributes of the moved field may change.
+// An error is returned if one of the fields is not found, if the new parent is
+// a terminal field (with widgets) or a descendant of the field, or if its kids already
+// contain a field with the same partial name.
+func (a *AcroForm) MoveField(name, newParent string) error {
+	field, err := a.lookupField(name)
+	if err != nil {
+		return err
+	}
+	var parent *FormFieldDict
+	if newParent != "" {
+		parent, err = a.lookupField(newParent)
+		if err != nil {
+			return err
+		}
+		if len(parent.Widgets) != 0 {
+			return newError(ErrInvalidArgument, "field %s has widgets and can't have kids", newParent)
+		}
+		for p := parent; p != nil; p = p.Parent {
+			if p == field {
+				return newError(ErrInvalidArgument, "field %s can't be moved to its descendant %s", name, newParent)
+			}
+		}
+	}
+	if parent == field.Parent {
+		return nil
+	}
+
+	newSiblings := &a.Fields
+	if parent != nil {
+		newSiblings = &parent.Kids
+	}
+	if err = checkPartialName(field.T, *newSiblings, nil); err != nil {
+		return err
+	}
+
+	siblings := a.siblings(field)
+	for i, sibling := range *siblings {
+		if sibling == field {
+			*siblings = append((*siblings)[:i:i], (*siblings)[i+1:]...)
+			break
+		}
+	}
+	field.Parent = parent
+	*newSiblings = append(*newSiblings, field)
+
+	newName := field.T
+	if newParent != "" {
+		newName = newParent + "." + field.T
+	}
+	a.updateFieldReferences(name, newName)
+	return nil
+}
+
+// SetCalculationOrder sets the calculation order (CO) of the form, from
+// the fully qualified names (see Flatten) of the fields with calculation actions.
+// An error is returned if one of the fields is not found or is repeated.
+func (a *AcroForm) SetCalculationOrder(names []string) error {
+	fields := a.Flatten()
+	co := make([]*FormFieldDict, len(names))
+	seen := make(map[*FormFieldDict]bool)
+	for i, name := range names {
+		field, ok := fields[name]
+		if !ok {
+			return newError(ErrInvalidArgument, "field %s not found", name)
+		}
+		if seen[field.Field] {
+			return newError(ErrInvalidArgument, "field %s is repeated in the calculation order", name)
+		}
+		seen[field.Field] = true
+		co[i] = field.Field
+	}
+	a.CO = co
+	return nil
+}
+
+// CalculationOrder returns the fully qualified names (see Flatten)
+// of the fields of the calculation order (CO).
+// The fields which are not in the form are ignored.
+func (a AcroForm) CalculationOrder() []string {
+	names := make(map[*FormFieldDict]string)
+	for name, field := range a.Flatten() {
+		names[field.Field] = name
+	}
+	var out []string
+	for _, field := range a.CO {
+		if name, ok := names[field]; ok {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// SetWidgetsOrder reorders the widgets of `page` so that the widgets of the fields
+// `names` (fully qualified, see Flatten) follow the given order, which is used by viewers
+// for keyboard navigation when the page tab order (Tabs) is not set.
+// The reordered widgets take the places they occupied in the page annotations,
+// so that the other annotations are not moved.
+// An error is returned if one of the fields is not found, repeated, or has no widget on `page`.
+func (a *AcroForm) SetWidgetsOrder(page *PageObject, names []string) error {
+	fields := a.Flatten()
+	positions := make(map[*AnnotationDict]int)
+	for i, annot := range page.Annots {
+		positions[annot] = i
+	}
+	var (
+		ordered []*AnnotationDict
+		indices []int
+	)
+	seen := make(map[*FormFieldDict]bool)
+	for _, name := range names {
+		field, ok := fields[name]
+		if !ok {
+			return newError(ErrInvalidArgument, "field %s not found", name)
+		}
+		if seen[field.Field] {
+			return newError(ErrInvalidArgument, "field %s is repeated", name)
+		}
+		seen[field.Field] = true
+		onPage := false
+		for _, widget := range field.Field.Widgets {
+			if index, ok := positions[widget.AnnotationDict]; ok {
+				ordered = append(ordered, widget.AnnotationDict)
+				indices = append(indices, index)
+				onPage = true
+			}
+		}
+		if !onPage {
+			return newError(ErrInvalidArgument, "field %s has no widget on the page", name)
+		}
+	}
+	sort.Ints(indices)
+	for i, index := range indices {
+		page.Annots[index] = ordered[i]
+	}
+	return nil
+}
+
+// pruneWidgets removes the widgets not in `widgets`, and the fields
+// left without widgets or kids.
+// The fields which had no widgets nor kids are preserved.
+func (a *AcroForm) pruneWidgets(widgets map[*AnnotationDict]bool) {
+	kept := make(map[*FormFieldDict]bool)
+	var prune func(fields []*FormFieldDict) []*FormFieldDict
+	prune = func(fields []*FormFieldDict) []*FormFieldDict {
+		var out []*FormFieldDict
+		for _, field := range fields {
+			if len(field.Widgets) != 0 {
+				var ws []FormFieldWidget
+				for _, widget := range field.Widgets {
+					if widgets[widget.AnnotationDict] {
+						ws = append(ws, widget)
+					}
+				}
+				if len(ws) == 0 {
+					continue
+				}
+				field.Widgets = ws
+			}
+			if len(field.Kids) != 0 {
+				field.Kids = prune(field.Kids)
+				if len(field.Kids) == 0 && len(field.Widgets) == 0 {
+					continue
+				}
+			}
+			kept[field] = true
+			out = append(out, field)
+		}
+		return out
+	}
+	a.Fields = prune(a.Fields)
+
+	var co []*FormFieldDict
+	for _, field := range a.CO {
+		if kept[field] {
+			co = append(co, field)
+		}
+	}
+	a.CO = co
+}
+
 func (a AcroForm) toBeMerged() map[*AnnotationDict]*FormFieldDict {
 	out := make(map[*AnnotationDict]*FormFieldDict)
 
@@ -116,3 +116,188 @@ func TestAppearanceKeys(t *testing.T) {
 		t.Error()
 	}
 }
+
+func TestVisibility(t *testing.T) {
+	for _, v := range [...]Visibility{VVisible, VHidden, VNoPrint, VNoView} {
+		ba := BaseAnnotation{F: ALocked | AHidden | ANoView}
+		ba.SetVisibility(v)
+		if ba.Visibility() != v {
+			t.Errorf("expected %d, got %d", v, ba.Visibility())
+		}
+		if ba.F&ALocked == 0 {
+			t.Error("other flags should be preserved")
+		}
+	}
+
+	w1, w2, w3 := &AnnotationDict{}, &AnnotationDict{}, &AnnotationDict{}
+	parent := &FormFieldDict{T: "section"}
+	parent.Kids = []*FormFieldDict{
+		{T: "a", Parent: parent, Widgets: []FormFieldWidget{{w1}, {w2}}},
+		{T: "b", Parent: parent, Widgets: []FormFieldWidget{{w3}}},
+	}
+	form := AcroForm{Fields: []*FormFieldDict{parent}}
+	n := form.SetFieldsVisibility(VHidden, func(name string, _ FormFieldInherited) bool { return name == "section.a" })
+	if n != 1 {
+		t.Fatalf("expected 1 field, got %d", n)
+	}
+	if w1.Visibility() != VHidden || w2.Visibility() != VHidden || w3.Visibility() == VHidden {
+		t.Errorf("unexpected flags %d %d %d", w1.F, w2.F, w3.F)
+	}
+	parent.SetVisibility(VNoPrint)
+	if w1.F != 0 || w3.F != 0 {
+		t.Errorf("unexpected flags %d %d", w1.F, w3.F)
+	}
+
+	pages := PageTree{Kids: []PageNode{
+		&PageObject{Annots: []*AnnotationDict{w1}},
+		&PageObject{Annots: []*AnnotationDict{w2, w3}},
+	}}
+	n = pages.SetAnnotationsVisibility(VNoView, func(i int, _ *AnnotationDict) bool { return i == 1 })
+	if n != 2 || w1.Visibility() != VNoPrint || w3.Visibility() != VNoView {
+		t.Errorf("unexpected flags %d %d", w1.F, w3.F)
+	}
+}
+
+func TestEditFields(t *testing.T) {
+	widget := func() FormFieldWidget {
+		return FormFieldWidget{AnnotationDict: &AnnotationDict{Subtype: AnnotationWidget{}}}
+	}
+	address := &FormFieldDict{T: "address"}
+	street := &FormFieldDict{T: "street", Parent: address, Widgets: []FormFieldWidget{widget()}}
+	city := &FormFieldDict{T: "city", Parent: address, Widgets: []FormFieldWidget{widget(), widget()}}
+	address.Kids = []*FormFieldDict{street, city}
+	name := &FormFieldDict{T: "name", Widgets: []FormFieldWidget{widget()}}
+	lock := &LockDict{Action: "Include", Fields: []string{"address.city", "name"}}
+	sig := &FormFieldDict{T: "sig", FormFieldInheritable: FormFieldInheritable{FT: FormFieldSignature{
+		Lock: lock,
+		V: &SignatureDict{Reference: []SignatureRefDict{
+			{TransformMethod: "FieldMDP", TransformParams: TransformFieldMDP{Action: "Include", Fields: []string{"address"}}},
+		}},
+	}}}
+	form := AcroForm{Fields: []*FormFieldDict{address, name, sig}, CO: []*FormFieldDict{city, name}}
+	page := &PageObject{Annots: []*AnnotationDict{
+		street.Widgets[0].AnnotationDict, city.Widgets[0].AnnotationDict, city.Widgets[1].AnnotationDict, name.Widgets[0].AnnotationDict,
+	}}
+	pages := PageTree{Kids: []PageNode{page}}
+
+	if err := form.RenameField("address", "home"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := form.Flatten()["home.city"]; !ok {
+		t.Fatalf("unexpected fields %v", form.Flatten())
+	}
+	mdp := sig.FT.(FormFieldSignature).V.Reference[0].TransformParams.(TransformFieldMDP)
+	if !reflect.DeepEqual(lock.Fields, []string{"home.city", "name"}) || !reflect.DeepEqual(mdp.Fields, []string{"home"}) {
+		t.Fatalf("unexpected references %v %v", lock.Fields, mdp.Fields)
+	}
+
+	if err := form.MoveField("name", "home"); err != nil {
+		t.Fatal(err)
+	}
+	if f := form.Flatten()["home.name"].Field; f != name || name.Parent != address || len(form.Fields) != 2 {
+		t.Fatalf("unexpected fields %v", form.Flatten())
+	}
+	if !reflect.DeepEqual(lock.Fields, []string{"home.city", "home.name"}) {
+		t.Fatalf("unexpected references %v", lock.Fields)
+	}
+
+	if err := form.RemoveField("home.city", pages); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := form.Flatten()["home.city"]; ok || len(address.Kids) != 2 {
+		t.Fatalf("unexpected fields %v", form.Flatten())
+	}
+	if len(page.Annots) != 2 || !reflect.DeepEqual(form.CO, []*FormFieldDict{name}) {
+		t.Fatalf("unexpected annotations %v or calculation order %v", page.Annots, form.CO)
+	}
+	mdp = sig.FT.(FormFieldSignature).V.Reference[0].TransformParams.(TransformFieldMDP)
+	if !reflect.DeepEqual(lock.Fields, []string{"home.name"}) || !reflect.DeepEqual(mdp.Fields, []string{"home"}) {
+		t.Fatalf("unexpected references %v %v", lock.Fields, mdp.Fields)
+	}
+
+	// invalid edits
+	for _, err := range []error{
+		form.RenameField("home.street", "name"), // conflict
+		form.RenameField("home.street", "a.b"),  // invalid name
+		form.RenameField("unknown", "other"),    // not found
+		form.MoveField("home", "home.street"),   // descendant
+		form.MoveField("sig", "home.street"),    // terminal field
+		form.RemoveField("home.unknown", pages), // not found
+	} {
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestFieldOrders(t *testing.T) {
+	widget := func() FormFieldWidget {
+		return FormFieldWidget{AnnotationDict: &AnnotationDict{Subtype: AnnotationWidget{}}}
+	}
+	total := &FormFieldDict{T: "total", Widgets: []FormFieldWidget{widget()}}
+	tax := &FormFieldDict{T: "tax", Widgets: []FormFieldWidget{widget()}}
+	name := &FormFieldDict{T: "name", Widgets: []FormFieldWidget{widget(), widget()}}
+	form := AcroForm{Fields: []*FormFieldDict{total, tax, name}}
+	link := &AnnotationDict{Subtype: AnnotationLink{}}
+	page := &PageObject{Annots: []*AnnotationDict{
+		total.Widgets[0].AnnotationDict, link, tax.Widgets[0].AnnotationDict, name.Widgets[0].AnnotationDict,
+	}}
+
+	if err := form.SetCalculationOrder([]string{"tax", "total"}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(form.CO, []*FormFieldDict{tax, total}) || !reflect.DeepEqual(form.CalculationOrder(), []string{"tax", "total"}) {
+		t.Fatalf("unexpected calculation order %v", form.CO)
+	}
+
+	if err := form.SetWidgetsOrder(page, []string{"name", "tax", "total"}); err != nil {
+		t.Fatal(err)
+	}
+	exp := []*AnnotationDict{name.Widgets[0].AnnotationDict, link, tax.Widgets[0].AnnotationDict, total.Widgets[0].AnnotationDict}
+	if !reflect.DeepEqual(page.Annots, exp) {
+		t.Fatalf("unexpected annotations order %v", page.Annots)
+	}
+
+	if err := page.SetTabOrder(TabsRow); err != nil || page.Tabs != "R" {
+		t.Fatal(err)
+	}
+
+	// invalid orders
+	for _, err := range []error{
+		form.SetCalculationOrder([]string{"tax", "unknown"}),
+		form.SetCalculationOrder([]string{"tax", "tax"}),
+		form.SetWidgetsOrder(page, []string{"unknown"}),
+		form.SetWidgetsOrder(page, []string{"name", "name"}),
+		form.SetWidgetsOrder(&PageObject{}, []string{"name"}),
+		page.SetTabOrder("X"),
+	} {
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+	if !reflect.DeepEqual(form.CO, []*FormFieldDict{tax, total}) || page.Tabs != "R" {
+		t.Fatal("invalid orders should not modify the form")
+	}
+}
+
+func TestFieldKind(t *testing.T) {
+	for _, test := range []struct {
+		field FormFieldInheritable
+		kind  string
+	}{
+		{FormFieldInheritable{}, ""},
+		{FormFieldInheritable{FT: FormFieldText{}}, "text"},
+		{FormFieldInheritable{FT: FormFieldText{}, Ff: Multiline | Required}, "multiline text"},
+		{FormFieldInheritable{FT: FormFieldText{}, Ff: Password}, "password"},
+		{FormFieldInheritable{FT: FormFieldButton{}}, "checkbox"},
+		{FormFieldInheritable{FT: FormFieldButton{}, Ff: Radio}, "radio"},
+		{FormFieldInheritable{FT: FormFieldButton{}, Ff: Pushbutton}, "pushbutton"},
+		{FormFieldInheritable{FT: FormFieldChoice{}, Ff: Combo}, "combo box"},
+		{FormFieldInheritable{FT: FormFieldChoice{}}, "list box"},
+		{FormFieldInheritable{FT: FormFieldSignature{}}, "signature"},
+	} {
+		if got := test.field.Kind(); got != test.kind {
+			t.Fatalf("expected %q, got %q", test.kind, got)
+		}
+	}
+}
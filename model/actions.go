@@ -15,16 +15,22 @@ type Action struct {
 }
 
 func (a Action) pdfString(pdf pdfWriter, context Reference) string {
-	subtype := a.ActionType.actionParams(pdf, context)
-	next := ""
-	if len(a.Next) != 0 {
-		chunks := make([]string, len(a.Next))
-		for i, n := range a.Next {
-			chunks[i] = n.pdfString(pdf, context)
+	b := newBuffer()
+	b.WriteString("<<")
+	b.WriteString(a.ActionType.actionParams(pdf, context))
+	b.WriteByte(' ')
+	var chunks []string
+	for _, n := range a.Next {
+		if n.ActionType == nil { // invalid action
+			continue
 		}
-		next = fmt.Sprintf("/Next [%s]", strings.Join(chunks, " "))
+		chunks = append(chunks, n.pdfString(pdf, context))
+	}
+	if len(chunks) != 0 {
+		b.entry("Next", "["+strings.Join(chunks, " ")+"]")
 	}
-	return fmt.Sprintf("<<%s %s>>", subtype, next)
+	b.WriteString(">>")
+	return b.String()
 }
 
 func (a Action) clone(cache cloneCache) Action {
@@ -41,18 +47,39 @@ func (a Action) clone(cache cloneCache) Action {
 	return out
 }
 
+// Walk calls `f` for the action and its following actions,
+// in execution order (that is, depth first), and stops
+// as soon as `f` returns false.
+// It returns false if the traversal was interrupted.
+func (a Action) Walk(f func(Action) bool) bool {
+	if !f(a) {
+		return false
+	}
+	for _, n := range a.Next {
+		if !n.Walk(f) {
+			return false
+		}
+	}
+	return true
+}
+
+// Chain returns the action and its following actions,
+// in execution order (see Walk).
+// The returned actions still have their Next field.
+func (a Action) Chain() []Action {
+	var out []Action
+	a.Walk(func(ac Action) bool {
+		out = append(out, ac)
+		return true
+	})
+	return out
+}
+
 // ActionType specialize the action (see Table 198 – Action types).
 // Many PDF actions are supported, excepted:
 //   - Thread
 //   - Sound
 //   - Movie
-//
-// TODO - SubmitForm
-// TODO - ResetForm
-// TODO - ImportData
-//   - SetOCGState
-//   - Rendition
-//   - Trans
 //   - GoTo3DView
 type ActionType interface {
 	// actionParams returns the fields of dictionary defining the action
@@ -78,8 +105,7 @@ type ActionURI struct {
 }
 
 func (uri ActionURI) actionParams(pdf pdfWriter, ref Reference) string {
-	return fmt.Sprintf("/S/URI/URI %s/IsMap %v",
-		pdf.EncodeString(string(uri.URI), ByteString, ref), uri.IsMap)
+	return "/S/URI/URI " + pdf.EncodeString(string(uri.URI), ByteString, ref) + "/IsMap " + strconv.FormatBool(uri.IsMap)
 }
 
 func (uri ActionURI) clone(cache cloneCache) ActionType { return uri }
@@ -176,10 +202,10 @@ func (d DestinationExplicitIntern) pdfDestination(pdf pdfWriter, _ Reference) st
 // concrete type is DestinationExplicitIntern
 func (d DestinationExplicitIntern) clone(cache cloneCache) Destination {
 	out := d
-	if d.Page != nil {
-		out.Page = cache.pages[d.Page].(*PageObject)
+	if page, ok := cache.pages[d.Page].(*PageObject); ok { // dangling pages are not cloned
+		out.Page = page
 	}
-	return d
+	return out
 }
 
 // DestinationExplicitExtern is an explicit destination to a page
@@ -407,6 +433,202 @@ func (a ActionNamed) actionParams(pdfWriter, Reference) string {
 
 func (ac ActionNamed) clone(cache cloneCache) ActionType { return ac }
 
+// SubmitFormFlag specifies the behaviour of a submit-form action.
+// See Table 237 – Flags for submit-form actions
+type SubmitFormFlag uint32
+
+const (
+	SubmitExclude              SubmitFormFlag = 1 << (1 - 1) // Include/Exclude
+	SubmitIncludeNoValueFields SubmitFormFlag = 1 << (2 - 1)
+	SubmitExportFormat         SubmitFormFlag = 1 << (3 - 1)
+	SubmitGetMethod            SubmitFormFlag = 1 << (4 - 1)
+	SubmitCoordinates          SubmitFormFlag = 1 << (5 - 1)
+	SubmitXFDF                 SubmitFormFlag = 1 << (6 - 1)
+	SubmitIncludeAppendSaves   SubmitFormFlag = 1 << (7 - 1)
+	SubmitIncludeAnnotations   SubmitFormFlag = 1 << (8 - 1)
+	SubmitPDF                  SubmitFormFlag = 1 << (9 - 1)
+	SubmitCanonicalFormat      SubmitFormFlag = 1 << (10 - 1)
+	SubmitExclNonUserAnnots    SubmitFormFlag = 1 << (11 - 1)
+	SubmitExclFKey             SubmitFormFlag = 1 << (12 - 1)
+	SubmitEmbedForm            SubmitFormFlag = 1 << (14 - 1)
+)
+
+// writeFieldNames returns the Fields entry of the form actions, or
+// an empty string
+func writeFieldNames(fields []string, pdf pdfWriter, ref Reference) string {
+	if len(fields) == 0 {
+		return ""
+	}
+	return "/Fields " + writeStringsArray(fields, pdf, TextString, ref)
+}
+
+// ActionSubmitForm transmits the names and values of the
+// interactive form fields to an URL.
+// See 12.7.5.2 - Submit-Form Action
+type ActionSubmitForm struct {
+	URL string // required, written in PDF as an URL file specification
+	// optional, fully qualified names of the fields to submit (or to
+	// exclude, depending on Flags)
+	// A nil slice means all the fields
+	Fields []string
+	Flags  SubmitFormFlag // optional
+}
+
+func (a ActionSubmitForm) actionParams(pdf pdfWriter, ref Reference) string {
+	out := fmt.Sprintf("/S/SubmitForm/F <</FS/URL/F %s>>", pdf.EncodeString(a.URL, ByteString, ref))
+	out += writeFieldNames(a.Fields, pdf, ref)
+	if a.Flags != 0 {
+		out += fmt.Sprintf("/Flags %d", a.Flags)
+	}
+	return out
+}
+
+func (a ActionSubmitForm) clone(cloneCache) ActionType {
+	out := a
+	out.Fields = append([]string(nil), a.Fields...)
+	return out
+}
+
+// ActionResetForm resets selected interactive form fields
+// to their default values.
+// See 12.7.5.3 - Reset-Form Action
+type ActionResetForm struct {
+	// optional, fully qualified names of the fields to reset (or to
+	// exclude, if Exclude is true)
+	// A nil slice means all the fields
+	Fields  []string
+	Exclude bool // optional, written in PDF as the bit 1 of the Flags entry
+}
+
+func (a ActionResetForm) actionParams(pdf pdfWriter, ref Reference) string {
+	out := "/S/ResetForm" + writeFieldNames(a.Fields, pdf, ref)
+	if a.Exclude {
+		out += "/Flags 1"
+	}
+	return out
+}
+
+func (a ActionResetForm) clone(cloneCache) ActionType {
+	out := a
+	out.Fields = append([]string(nil), a.Fields...)
+	return out
+}
+
+// ActionImportData imports field values from a file.
+// See 12.7.5.4 - Import-Data Action
+type ActionImportData struct {
+	F *FileSpec // required, FDF file
+}
+
+func (a ActionImportData) actionParams(pdf pdfWriter, ref Reference) string {
+	out := "/S/ImportData"
+	if a.F != nil {
+		out += "/F " + pdf.addItem(a.F).String()
+	}
+	return out
+}
+
+func (a ActionImportData) clone(cache cloneCache) ActionType {
+	out := a
+	if a.F != nil {
+		out.F = cache.checkOrClone(a.F).(*FileSpec)
+	}
+	return out
+}
+
+// OCGStateChange applies the same change to several
+// optional content groups
+type OCGStateChange struct {
+	State Name // one of ON, OFF, Toggle
+	OCGs  []*OptionalContentGroup
+}
+
+// ActionSetOCGState sets the state of optional content groups.
+// See 12.6.4.12 - Set-OCG-State Actions
+type ActionSetOCGState struct {
+	State      []OCGStateChange // required, applied in order
+	PreserveRB MaybeBool        // optional, default to true
+}
+
+func (a ActionSetOCGState) actionParams(pdf pdfWriter, ref Reference) string {
+	var chunks []string
+	for _, change := range a.State {
+		chunks = append(chunks, change.State.String())
+		for _, oc := range change.OCGs {
+			chunks = append(chunks, pdf.addItem(oc).String())
+		}
+	}
+	out := "/S/SetOCGState/State [" + strings.Join(chunks, " ") + "]"
+	if rb, ok := a.PreserveRB.(ObjBool); ok {
+		out += fmt.Sprintf("/PreserveRB %v", rb)
+	}
+	return out
+}
+
+func (a ActionSetOCGState) clone(cache cloneCache) ActionType {
+	out := a
+	if a.State != nil { // preserve nil
+		out.State = make([]OCGStateChange, len(a.State))
+		for i, change := range a.State {
+			out.State[i] = OCGStateChange{State: change.State, OCGs: cloneOCGArray(change.OCGs, cache)}
+		}
+	}
+	return out
+}
+
+// Transition describes the visual effect used when moving
+// from a page to another.
+// See Table 162 – Entries in a transition dictionary
+type Transition struct {
+	S  Name       // optional, transition style, default to R
+	D  MaybeFloat // optional, duration in seconds, default to 1
+	Dm Name       // optional, H or V, for Split and Blinds styles
+	M  Name       // optional, I or O, for Split, Box and Fly styles
+	Di MaybeInt   // optional, direction in degrees
+	SS MaybeFloat // optional, for Fly style, default to 1
+	B  bool       // optional, for Fly style
+}
+
+func (t Transition) pdfString() string {
+	b := newBuffer()
+	b.WriteString("<<")
+	if t.S != "" {
+		b.fmt("/S %s", t.S)
+	}
+	if t.D != nil {
+		b.fmt("/D %s", writeMaybeFloat(t.D))
+	}
+	if t.Dm != "" {
+		b.fmt("/Dm %s", t.Dm)
+	}
+	if t.M != "" {
+		b.fmt("/M %s", t.M)
+	}
+	if di, ok := t.Di.(ObjInt); ok {
+		b.fmt("/Di %d", di)
+	}
+	if t.SS != nil {
+		b.fmt("/SS %s", writeMaybeFloat(t.SS))
+	}
+	if t.B {
+		b.fmt("/B true")
+	}
+	b.WriteString(">>")
+	return b.String()
+}
+
+// ActionTrans updates the display of a document, using a transition.
+// See 12.6.4.14 - Transition Actions
+type ActionTrans struct {
+	Trans Transition
+}
+
+func (a ActionTrans) actionParams(pdfWriter, Reference) string {
+	return "/S/Trans/Trans " + a.Trans.pdfString()
+}
+
+func (a ActionTrans) clone(cloneCache) ActionType { return a }
+
 // All actions are optional and must be JavaScript actions.
 // See Table 196 – Entries in a form field’s additional-actions dictionary
 type FormFielAdditionalActions struct {
@@ -450,6 +672,85 @@ func (ff FormFielAdditionalActions) clone(cache cloneCache) FormFielAdditionalAc
 	return a
 }
 
+// DocumentAdditionalActions are triggered by document events.
+// All actions are optional and should be JavaScript actions.
+// See Table 197 – Entries in the document catalog’s additional-actions dictionary
+type DocumentAdditionalActions struct {
+	WC Action // before closing the document
+	WS Action // before saving the document
+	DS Action // after saving the document
+	WP Action // before printing the document
+	DP Action // after printing the document
+}
+
+// IsEmpty returns `true` if it contains no action.
+func (d DocumentAdditionalActions) IsEmpty() bool {
+	return d.WC.ActionType == nil && d.WS.ActionType == nil &&
+		d.DS.ActionType == nil && d.WP.ActionType == nil && d.DP.ActionType == nil
+}
+
+func (d DocumentAdditionalActions) pdfString(pdf pdfWriter, ref Reference) string {
+	b := newBuffer()
+	b.WriteString("<<")
+	if d.WC.ActionType != nil {
+		b.line("/WC %s", d.WC.pdfString(pdf, ref))
+	}
+	if d.WS.ActionType != nil {
+		b.line("/WS %s", d.WS.pdfString(pdf, ref))
+	}
+	if d.DS.ActionType != nil {
+		b.line("/DS %s", d.DS.pdfString(pdf, ref))
+	}
+	if d.WP.ActionType != nil {
+		b.line("/WP %s", d.WP.pdfString(pdf, ref))
+	}
+	if d.DP.ActionType != nil {
+		b.line("/DP %s", d.DP.pdfString(pdf, ref))
+	}
+	b.fmt(">>")
+	return b.String()
+}
+
+func (d DocumentAdditionalActions) clone(cache cloneCache) DocumentAdditionalActions {
+	var a DocumentAdditionalActions
+	a.WC = d.WC.clone(cache)
+	a.WS = d.WS.clone(cache)
+	a.DS = d.DS.clone(cache)
+	a.WP = d.WP.clone(cache)
+	a.DP = d.DP.clone(cache)
+	return a
+}
+
+// PageAdditionalActions are triggered when a page is opened or closed.
+// All actions are optional.
+// See Table 195 – Entries in a page object’s additional-actions dictionary
+type PageAdditionalActions struct {
+	O Action // the page is opened
+	C Action // the page is closed
+}
+
+// IsEmpty returns `true` if it contains no action.
+func (p PageAdditionalActions) IsEmpty() bool {
+	return p.O.ActionType == nil && p.C.ActionType == nil
+}
+
+func (p PageAdditionalActions) pdfString(pdf pdfWriter, ref Reference) string {
+	b := newBuffer()
+	b.WriteString("<<")
+	if p.O.ActionType != nil {
+		b.line("/O %s", p.O.pdfString(pdf, ref))
+	}
+	if p.C.ActionType != nil {
+		b.line("/C %s", p.C.pdfString(pdf, ref))
+	}
+	b.fmt(">>")
+	return b.String()
+}
+
+func (p PageAdditionalActions) clone(cache cloneCache) PageAdditionalActions {
+	return PageAdditionalActions{O: p.O.clone(cache), C: p.C.clone(cache)}
+}
+
 // All actions are optional
 // See Table 194 – Entries in an annotation’s additional-actions dictionary.
 type AnnotationAdditionalActions struct {
@@ -35,6 +35,7 @@ import (
 // 	Watermark      AnnotationType = "Watermark"
 // 	ThreeD         AnnotationType = "3D"
 // 	Redact         AnnotationType = "Redact"
+// 	RichMedia      AnnotationType = "RichMedia"
 // )
 
 // Border is written in PDF as an array of 3 or 4 elements
@@ -150,6 +151,57 @@ const (
 	ALockedContents AnnotationFlag = 1 << (10 - 1)
 )
 
+// Visibility is a convenient way of setting the AHidden, ANoView and APrint
+// flags, following the display values used by form scripts.
+type Visibility uint8
+
+const (
+	VVisible Visibility = iota // displayed and printed
+	VHidden                    // neither displayed nor printed
+	VNoPrint                   // displayed but not printed
+	VNoView                    // printed but not displayed
+)
+
+// visibilityFlags are the flags controlled by Visibility
+const visibilityFlags = AHidden | ANoView | APrint
+
+// flags returns the flags for v
+func (v Visibility) flags() AnnotationFlag {
+	switch v {
+	case VHidden:
+		return AHidden
+	case VNoPrint:
+		return 0
+	case VNoView:
+		return ANoView | APrint
+	default:
+		return APrint
+	}
+}
+
+// Visibility returns the visibility described by the annotation flags.
+func (ba BaseAnnotation) Visibility() Visibility {
+	switch {
+	case ba.F&AHidden != 0:
+		return VHidden
+	case ba.F&ANoView != 0:
+		if ba.F&APrint != 0 {
+			return VNoView
+		}
+		return VHidden
+	case ba.F&APrint != 0:
+		return VVisible
+	default:
+		return VNoPrint
+	}
+}
+
+// SetVisibility updates the AHidden, ANoView and APrint flags,
+// preserving the other ones.
+func (ba *BaseAnnotation) SetVisibility(v Visibility) {
+	ba.F = ba.F&^visibilityFlags | v.flags()
+}
+
 type BaseAnnotation struct {
 	M            time.Time       // optional
 	StructParent MaybeInt        // required if the annotation is a structural content item
@@ -163,17 +215,25 @@ type BaseAnnotation struct {
 	AS   Name
 	C    []Fl // 0, 1, 3 or 4 numbers in the range 0.0 to 1.0
 	Rect Rectangle
-	F    AnnotationFlag // optional
+	F    AnnotationFlag  // optional
+	OC   OptionalContent // optional
+	AF   []*FileSpec     // optional, associated files (PDF 2.0, PDF/A-3)
+
+	// Custom stores the additional entries, such as
+	// private keys, which are written as is.
+	// It is filled by the reader with the unknown entries of the source file.
+	Custom ObjDict // optional
 }
 
 func (ba BaseAnnotation) fields(pdf pdfWriter, ref Reference) string {
 	b := newBuffer()
-	b.fmt("/Type/Annot /Rect %s", ba.Rect)
+	b.WriteString("/Type/Annot ")
+	b.rect("Rect", ba.Rect)
 	if ba.Contents != "" {
-		b.fmt("/Contents %s", pdf.EncodeString(ba.Contents, TextString, ref))
+		b.entry("Contents", pdf.EncodeString(ba.Contents, TextString, ref))
 	}
 	if ba.NM != "" {
-		b.fmt("/NM %s", pdf.EncodeString(ba.NM, TextString, ref))
+		b.entry("NM", pdf.EncodeString(ba.NM, TextString, ref))
 	}
 	if !ba.M.IsZero() {
 		b.fmt("/M %s", pdf.dateString(ba.M, ref))
@@ -185,17 +245,24 @@ func (ba BaseAnnotation) fields(pdf pdfWriter, ref Reference) string {
 		b.fmt("/AS %s", as)
 	}
 	if f := ba.F; f != 0 {
-		b.fmt("/F %d", f)
+		b.int("F", int(f))
 	}
 	if bo := ba.Border; bo != nil {
 		b.fmt("/Border %s", bo.pdfString())
 	}
 	if len(ba.C) != 0 {
-		b.fmt("/C %s", writeFloatArray(ba.C))
+		b.floats("C", ba.C)
 	}
 	if ba.StructParent != nil {
-		b.fmt("/StructParent %d", ba.StructParent.(ObjInt))
+		b.int("StructParent", int(ba.StructParent.(ObjInt)))
+	}
+	if ba.OC != nil {
+		b.fmt("/OC %s", writeOptionalContent(pdf, ba.OC))
 	}
+	if len(ba.AF) != 0 {
+		b.fmt("/AF %s", writeFileSpecArray(pdf, ba.AF))
+	}
+	b.custom(pdf, ba.Custom, ref)
 	return b.String()
 }
 
@@ -206,6 +273,9 @@ func (ba BaseAnnotation) clone(cache cloneCache) BaseAnnotation {
 	if ba.C != nil {
 		out.C = append([]Fl(nil), ba.C...)
 	}
+	out.OC = cloneOptionalContent(ba.OC, cache)
+	out.AF = cloneFileSpecArray(ba.AF, cache)
+	out.Custom = cloneCustom(ba.Custom)
 	return out
 }
 
@@ -228,7 +298,7 @@ func (ba BaseAnnotation) clone(cache cloneCache) BaseAnnotation {
 //   - Ink
 //   - FileAttachment
 //   - Sound
-//   - Redact
+//   - Redact (see AnnotationRedact)
 type AnnotationMarkup struct {
 	T            string           // optional
 	Popup        *AnnotationPopup // optional, written as an indirect reference
@@ -318,7 +388,7 @@ func (a *AnnotationDict) pdfContent(pdf pdfWriter, ref Reference) (StreamHeader,
 		form = field.mergedFields(pdf, ref)
 	}
 
-	return StreamHeader{}, fmt.Sprintf("<<%s %s %s>>", base, subtype, form), nil
+	return StreamHeader{}, "<<" + base + " " + subtype + " " + form + ">>", nil
 }
 
 func (a *AnnotationDict) clone(cache cloneCache) Referenceable {
@@ -367,19 +437,49 @@ func (ap *AppearanceDict) clone(cache cloneCache) *AppearanceDict {
 // AppearanceEntry is either a Dictionary, or a subDictionary
 // containing multiple appearances
 // In the first case, the map is of length 1, with the empty string as key
+// (see IsStream), and the entry is written back as a single stream.
 type AppearanceEntry map[Name]*XObjectForm
 
+// IsStream returns true if the entry is a single appearance stream,
+// instead of a subdictionary of appearance states.
+func (ap AppearanceEntry) IsStream() bool {
+	return len(ap) == 1 && ap[""] != nil
+}
+
+// Appearance returns the appearance for the given state (usually the AS entry
+// of the annotation), or nil.
+// A single appearance stream is returned for any state, and for a subdictionary with only one
+// state, this state is returned when `state` is empty (AS entry missing).
+func (ap AppearanceEntry) Appearance(state Name) *XObjectForm {
+	if ap.IsStream() {
+		return ap[""]
+	}
+	if form := ap[state]; form != nil {
+		return form
+	}
+	if state == "" && len(ap) == 1 {
+		for _, form := range ap {
+			return form
+		}
+	}
+	return nil
+}
+
 // pdfString returns the Dictionary for the appearance
 // or the reference to the only stream
 // `pdf` is used to write the form XObjects
+// nil appearances are ignored.
 func (ap AppearanceEntry) pdfString(pdf pdfWriter) string {
 	// case of only one stream
-	if st := ap[""]; len(ap) == 1 && st != nil {
-		ref := pdf.addItem(st)
+	if ap.IsStream() {
+		ref := pdf.addItem(ap[""])
 		return ref.String()
 	}
 	chunks := make([]string, 0, len(ap))
 	for n, f := range ap {
+		if f == nil {
+			continue
+		}
 		ref := pdf.addItem(f)
 		chunks = append(chunks, fmt.Sprintf("%s %s", n, ref))
 	}
@@ -393,6 +493,10 @@ func (ap AppearanceEntry) clone(cache cloneCache) AppearanceEntry {
 	}
 	out := make(AppearanceEntry, len(ap))
 	for name, form := range ap {
+		if form == nil {
+			out[name] = nil
+			continue
+		}
 		out[name] = cache.checkOrClone(form).(*XObjectForm)
 	}
 	return out
@@ -467,25 +571,26 @@ type AnnotationLink struct {
 }
 
 func (l AnnotationLink) annotationFields(pdf pdfWriter, ref Reference) string {
-	out := "/Subtype/Link"
+	b := newBuffer()
+	b.WriteString("/Subtype/Link")
 	if l.A.ActionType != nil {
-		out += "/A " + l.A.pdfString(pdf, ref)
+		b.entry("A", l.A.pdfString(pdf, ref))
 	} else if l.Dest != nil {
-		out += "/Dest " + l.Dest.pdfDestination(pdf, ref)
+		b.entry("Dest", l.Dest.pdfDestination(pdf, ref))
 	}
 	if l.H != "" {
-		out += "/H " + Name(l.H).String()
+		b.entry("H", Name(l.H).String())
 	}
 	if l.PA.ActionType != nil {
-		out += "/PA " + l.PA.pdfString(pdf, ref)
+		b.entry("PA", l.PA.pdfString(pdf, ref))
 	}
 	if len(l.QuadPoints) != 0 {
-		out += "/QuadPoints " + writeFloatArray(l.QuadPoints)
+		b.floats("QuadPoints", l.QuadPoints)
 	}
 	if l.BS != nil {
-		out += "/BS " + l.BS.String()
+		b.entry("BS", l.BS.String())
 	}
-	return out
+	return b.String()
 }
 
 func (l AnnotationLink) clone(cache cloneCache) Annotation {
@@ -575,8 +680,9 @@ type AnnotationLine struct {
 	LLO MaybeFloat   // optional
 	CP  Name         // optional
 	CO  [2]Fl        // optional
-	// TODO: support measure dictionary
-	// Measure *MeasureDict // optional
+	// Measure is an alternate coordinate system used
+	// to display the length of the line
+	Measure Measure // optional
 }
 
 func (f AnnotationLine) annotationFields(pdf pdfWriter, ref Reference) string {
@@ -608,6 +714,9 @@ func (f AnnotationLine) annotationFields(pdf pdfWriter, ref Reference) string {
 	if f.CO != ([2]Fl{}) {
 		b.WriteString(fmt.Sprintf("/CO %s", writeFloatArray(f.CO[:])))
 	}
+	if f.Measure != nil {
+		b.fmt("/Measure %s", f.Measure.measureString(pdf, ref))
+	}
 	return b.String()
 }
 
@@ -616,6 +725,9 @@ func (f AnnotationLine) clone(cache cloneCache) Annotation {
 	out.AnnotationMarkup = f.AnnotationMarkup.clone(cache)
 	out.BS = f.BS.Clone()
 	out.IC = append([]Fl(nil), f.IC...)
+	if f.Measure != nil {
+		out.Measure = f.Measure.cloneMeasure()
+	}
 	return out
 }
 
@@ -680,6 +792,491 @@ func (f AnnotationCircle) clone(cache cloneCache) Annotation {
 
 // -------------------------------------------------------------------------
 
+// AnnotationRedact identifies content that is intended to be removed from the document.
+// See 12.5.6.23 - Redaction Annotations
+type AnnotationRedact struct {
+	AnnotationMarkup
+	QuadPoints  []Fl         // optional, length 8 x n. If empty, Rect is used
+	IC          []Fl         // optional, interior color of the overlay
+	RO          *XObjectForm // optional, overlay appearance, taking precedence over IC, OverlayText, DA and Q
+	OverlayText string       // optional, text string
+	Repeat      bool         // optional
+	DA          string       // required if OverlayText is present
+	Q           Quadding     // optional
+}
+
+func (f AnnotationRedact) annotationFields(pdf pdfWriter, ref Reference) string {
+	b := newBuffer()
+	b.fmt("/Subtype/Redact %s", f.AnnotationMarkup.pdfFields(pdf, ref))
+	if len(f.QuadPoints) != 0 {
+		b.fmt("/QuadPoints %s", writeFloatArray(f.QuadPoints))
+	}
+	if len(f.IC) != 0 {
+		b.fmt("/IC %s", writeFloatArray(f.IC))
+	}
+	if f.RO != nil {
+		b.fmt("/RO %s", pdf.addItem(f.RO))
+	}
+	if f.OverlayText != "" {
+		b.fmt("/OverlayText %s", pdf.EncodeString(f.OverlayText, TextString, ref))
+	}
+	if f.Repeat {
+		b.fmt("/Repeat true")
+	}
+	if f.DA != "" {
+		b.fmt("/DA %s", pdf.EncodeString(f.DA, ByteString, ref))
+	}
+	if f.Q != 0 {
+		b.fmt("/Q %d", f.Q)
+	}
+	return b.String()
+}
+
+func (f AnnotationRedact) clone(cache cloneCache) Annotation {
+	out := f
+	out.AnnotationMarkup = f.AnnotationMarkup.clone(cache)
+	out.QuadPoints = append([]Fl(nil), f.QuadPoints...)
+	out.IC = append([]Fl(nil), f.IC...)
+	if f.RO != nil {
+		out.RO = cache.checkOrClone(f.RO).(*XObjectForm)
+	}
+	return out
+}
+
+// Areas returns the regions covered by the annotation: the quadrilaterals
+// given by QuadPoints (as bounding boxes), or Rect.
+func (f AnnotationRedact) Areas(rect Rectangle) []Rectangle {
+	if len(f.QuadPoints) < 8 {
+		return []Rectangle{rect}
+	}
+	quads := Quads(f.QuadPoints)
+	out := make([]Rectangle, len(quads))
+	for i, quad := range quads {
+		out[i] = quad.Bounds()
+	}
+	return out
+}
+
+func minFl(a, b Fl) Fl {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxFl(a, b Fl) Fl {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// -------------------------------------------------------------------------
+
+// AnnotationPolygon displays a closed polygon on the page.
+// See Table 178 – Additional entries specific to a polygon or polyline annotation
+type AnnotationPolygon struct {
+	AnnotationMarkup
+	Vertices []Fl          // required, alternating horizontal and vertical coordinates
+	LE       [2]Name       // optional, only used by polyline annotations
+	BS       *BorderStyle  // optional
+	IC       []Fl          // optional
+	BE       *BorderEffect // optional, only used by polygon annotations
+	Measure  Measure       // optional
+}
+
+// shared with AnnotationPolyLine
+func (f AnnotationPolygon) annotationFieldsExt(pdf pdfWriter, ref Reference, subtype Name) string {
+	b := newBuffer()
+	b.fmt("/Subtype%s %s", subtype, f.AnnotationMarkup.pdfFields(pdf, ref))
+	b.fmt("/Vertices %s", writeFloatArray(f.Vertices))
+	if f.LE != ([2]Name{}) {
+		b.fmt("/LE %s", writeNameArray(f.LE[:]))
+	}
+	if f.BS != nil {
+		b.WriteString("/BS " + f.BS.String())
+	}
+	if len(f.IC) != 0 {
+		b.WriteString("/IC " + writeFloatArray(f.IC))
+	}
+	if f.BE != nil {
+		b.WriteString("/BE " + f.BE.String())
+	}
+	if f.Measure != nil {
+		b.fmt("/Measure %s", f.Measure.measureString(pdf, ref))
+	}
+	return b.String()
+}
+
+func (f AnnotationPolygon) annotationFields(pdf pdfWriter, ref Reference) string {
+	return f.annotationFieldsExt(pdf, ref, "Polygon")
+}
+
+func (f AnnotationPolygon) clone(cache cloneCache) Annotation {
+	out := f
+	out.AnnotationMarkup = f.AnnotationMarkup.clone(cache)
+	out.Vertices = append([]Fl(nil), f.Vertices...)
+	out.BS = f.BS.Clone()
+	out.IC = append([]Fl(nil), f.IC...)
+	out.BE = f.BE.Clone()
+	if f.Measure != nil {
+		out.Measure = f.Measure.cloneMeasure()
+	}
+	return out
+}
+
+// AnnotationPolyLine is similar to AnnotationPolygon,
+// except that the first and last vertex are not implicitly connected.
+type AnnotationPolyLine AnnotationPolygon
+
+func (f AnnotationPolyLine) annotationFields(pdf pdfWriter, ref Reference) string {
+	return AnnotationPolygon(f).annotationFieldsExt(pdf, ref, "PolyLine")
+}
+
+func (f AnnotationPolyLine) clone(cache cloneCache) Annotation {
+	return AnnotationPolyLine(AnnotationPolygon(f).clone(cache).(AnnotationPolygon))
+}
+
+// -------------------------------------------------------------------------
+
+// AnnotationHighlight highlights text on the page.
+// See Table 179 – Additional entries specific to text markup annotations
+type AnnotationHighlight struct {
+	AnnotationMarkup
+	QuadPoints []Fl // required, length 8 x n
+}
+
+// shared with the other text markup annotations
+func (f AnnotationHighlight) annotationFieldsExt(pdf pdfWriter, ref Reference, subtype Name) string {
+	return fmt.Sprintf("/Subtype%s %s/QuadPoints %s", subtype, f.AnnotationMarkup.pdfFields(pdf, ref), writeFloatArray(f.QuadPoints))
+}
+
+func (f AnnotationHighlight) annotationFields(pdf pdfWriter, ref Reference) string {
+	return f.annotationFieldsExt(pdf, ref, "Highlight")
+}
+
+func (f AnnotationHighlight) clone(cache cloneCache) Annotation {
+	out := f
+	out.AnnotationMarkup = f.AnnotationMarkup.clone(cache)
+	out.QuadPoints = append([]Fl(nil), f.QuadPoints...)
+	return out
+}
+
+// AnnotationUnderline underlines text on the page.
+type AnnotationUnderline AnnotationHighlight
+
+func (f AnnotationUnderline) annotationFields(pdf pdfWriter, ref Reference) string {
+	return AnnotationHighlight(f).annotationFieldsExt(pdf, ref, "Underline")
+}
+
+func (f AnnotationUnderline) clone(cache cloneCache) Annotation {
+	return AnnotationUnderline(AnnotationHighlight(f).clone(cache).(AnnotationHighlight))
+}
+
+// AnnotationSquiggly displays a jagged underline.
+type AnnotationSquiggly AnnotationHighlight
+
+func (f AnnotationSquiggly) annotationFields(pdf pdfWriter, ref Reference) string {
+	return AnnotationHighlight(f).annotationFieldsExt(pdf, ref, "Squiggly")
+}
+
+func (f AnnotationSquiggly) clone(cache cloneCache) Annotation {
+	return AnnotationSquiggly(AnnotationHighlight(f).clone(cache).(AnnotationHighlight))
+}
+
+// AnnotationStrikeOut strikes out text on the page.
+type AnnotationStrikeOut AnnotationHighlight
+
+func (f AnnotationStrikeOut) annotationFields(pdf pdfWriter, ref Reference) string {
+	return AnnotationHighlight(f).annotationFieldsExt(pdf, ref, "StrikeOut")
+}
+
+func (f AnnotationStrikeOut) clone(cache cloneCache) Annotation {
+	return AnnotationStrikeOut(AnnotationHighlight(f).clone(cache).(AnnotationHighlight))
+}
+
+// -------------------------------------------------------------------------
+
+// AnnotationCaret is a visual symbol that indicates the presence of text edits.
+// See Table 180 – Additional entries specific to a caret annotation
+type AnnotationCaret struct {
+	AnnotationMarkup
+	RD Rectangle // optional
+	Sy Name      // optional, P or None
+}
+
+func (f AnnotationCaret) annotationFields(pdf pdfWriter, ref Reference) string {
+	b := newBuffer()
+	b.fmt("/Subtype/Caret %s", f.AnnotationMarkup.pdfFields(pdf, ref))
+	if f.RD != (Rectangle{}) {
+		b.WriteString("/RD " + f.RD.String())
+	}
+	if f.Sy != "" {
+		b.fmt("/Sy %s", f.Sy)
+	}
+	return b.String()
+}
+
+func (f AnnotationCaret) clone(cache cloneCache) Annotation {
+	out := f
+	out.AnnotationMarkup = f.AnnotationMarkup.clone(cache)
+	return out
+}
+
+// AnnotationStamp displays text or graphics intended to look
+// as if they were stamped on the page with a rubber stamp.
+// See Table 181 – Additional entries specific to a rubber stamp annotation
+type AnnotationStamp struct {
+	AnnotationMarkup
+	Name Name // optional, default to Draft
+}
+
+func (f AnnotationStamp) annotationFields(pdf pdfWriter, ref Reference) string {
+	out := "/Subtype/Stamp " + f.AnnotationMarkup.pdfFields(pdf, ref)
+	if f.Name != "" {
+		out += "/Name " + f.Name.String()
+	}
+	return out
+}
+
+func (f AnnotationStamp) clone(cache cloneCache) Annotation {
+	out := f
+	out.AnnotationMarkup = f.AnnotationMarkup.clone(cache)
+	return out
+}
+
+// AnnotationInk represents a freehand “scribble” composed of one or more disjoint paths.
+// See Table 182 – Additional entries specific to an ink annotation
+type AnnotationInk struct {
+	AnnotationMarkup
+	InkList [][]Fl       // required, each path is a list of alternating horizontal and vertical coordinates
+	BS      *BorderStyle // optional
+}
+
+func (f AnnotationInk) annotationFields(pdf pdfWriter, ref Reference) string {
+	b := newBuffer()
+	b.fmt("/Subtype/Ink %s/InkList [", f.AnnotationMarkup.pdfFields(pdf, ref))
+	for _, path := range f.InkList {
+		b.WriteString(writeFloatArray(path))
+	}
+	b.WriteString("]")
+	if f.BS != nil {
+		b.WriteString("/BS " + f.BS.String())
+	}
+	return b.String()
+}
+
+func (f AnnotationInk) clone(cache cloneCache) Annotation {
+	out := f
+	out.AnnotationMarkup = f.AnnotationMarkup.clone(cache)
+	if f.InkList != nil {
+		out.InkList = make([][]Fl, len(f.InkList))
+		for i, path := range f.InkList {
+			out.InkList[i] = append([]Fl(nil), path...)
+		}
+	}
+	out.BS = f.BS.Clone()
+	return out
+}
+
+// -------------------------------------------------------------------------
+
+// AnnotationSound is analogous to a text annotation
+// except that instead of a text note, it contains sound.
+// See Table 185 – Additional entries specific to a sound annotation
+type AnnotationSound struct {
+	AnnotationMarkup
+	Sound *SoundStream // required
+	Name  Name         // optional, Speaker or Mic
+}
+
+func (f AnnotationSound) annotationFields(pdf pdfWriter, ref Reference) string {
+	out := "/Subtype/Sound " + f.AnnotationMarkup.pdfFields(pdf, ref)
+	if f.Sound != nil {
+		out += "/Sound " + pdf.addItem(f.Sound).String()
+	}
+	if f.Name != "" {
+		out += "/Name " + f.Name.String()
+	}
+	return out
+}
+
+func (f AnnotationSound) clone(cache cloneCache) Annotation {
+	out := f
+	out.AnnotationMarkup = f.AnnotationMarkup.clone(cache)
+	if f.Sound != nil {
+		out.Sound = cache.checkOrClone(f.Sound).(*SoundStream)
+	}
+	return out
+}
+
+// AnnotationMovie contains animated graphics and sound to be
+// presented on the computer screen and through the speakers.
+// See Table 186 – Additional entries specific to a movie annotation
+type AnnotationMovie struct {
+	T     string           // optional
+	Movie Movie            // required
+	A     *MovieActivation // optional, nil means the default activation
+	// NoPlay is written as the value false for A,
+	// meaning that the movie shall not be played.
+	NoPlay bool
+}
+
+func (f AnnotationMovie) annotationFields(pdf pdfWriter, ref Reference) string {
+	b := newBuffer()
+	b.fmt("/Subtype/Movie/Movie %s", f.Movie.pdfString(pdf, ref))
+	if f.T != "" {
+		b.fmt("/T %s", pdf.EncodeString(f.T, TextString, ref))
+	}
+	if f.NoPlay {
+		b.WriteString("/A false")
+	} else if f.A != nil {
+		b.WriteString("/A " + f.A.String())
+	}
+	return b.String()
+}
+
+func (f AnnotationMovie) clone(cache cloneCache) Annotation {
+	out := f
+	out.Movie = f.Movie.clone(cache)
+	if f.A != nil {
+		a := *f.A
+		out.A = &a
+	}
+	return out
+}
+
+// -------------------------------------------------------------------------
+
+// AnnotationWatermark is used to represent graphics that shall be printed at a fixed
+// size and position on a page, regardless of the dimensions of the printed page.
+// See Table 190 – Additional entries specific to a watermark annotation
+type AnnotationWatermark struct {
+	FixedPrint *FixedPrint // optional
+}
+
+func (f AnnotationWatermark) annotationFields(pdf pdfWriter, ref Reference) string {
+	out := "/Subtype/Watermark"
+	if f.FixedPrint != nil {
+		out += "/FixedPrint " + f.FixedPrint.String()
+	}
+	return out
+}
+
+func (f AnnotationWatermark) clone(cache cloneCache) Annotation {
+	out := f
+	if f.FixedPrint != nil {
+		fp := *f.FixedPrint
+		out.FixedPrint = &fp
+	}
+	return out
+}
+
+// FixedPrint specifies how a watermark annotation shall be drawn
+// relative to the dimensions of the target media.
+// See Table 191 – Entries in a fixed print dictionary
+type FixedPrint struct {
+	Matrix Matrix // optional, default to identity
+	H, V   Fl     // optional, translation as a fraction of the target media
+}
+
+// String returns the PDF dictionary.
+func (f FixedPrint) String() string {
+	b := newBuffer()
+	b.WriteString("<</Type/FixedPrint")
+	if f.Matrix != (Matrix{}) {
+		b.fmt("/Matrix %s", f.Matrix)
+	}
+	if f.H != 0 {
+		b.fmt("/H %s", FmtFloat(f.H))
+	}
+	if f.V != 0 {
+		b.fmt("/V %s", FmtFloat(f.V))
+	}
+	b.WriteString(">>")
+	return b.String()
+}
+
+// -------------------------------------------------------------------------
+
+// Annotation3D displays 3D artwork, whose content is described
+// by a 3D stream.
+// See 13.6.2 - 3D Annotations
+type Annotation3D struct {
+	DD *Stream3D     // required, written as 3DD (3D reference dictionaries are not supported)
+	DV View3DSelect  // optional, default view, written as 3DV
+	DA *Activation3D // optional, written as 3DA
+	DI MaybeBool     // optional, interactive mode, default to true, written as 3DI
+	DB *Rectangle    // optional, view box in the annotation form space, written as 3DB
+}
+
+func (f Annotation3D) annotationFields(pdf pdfWriter, ref Reference) string {
+	b := newBuffer()
+	b.WriteString("/Subtype/3D")
+	if f.DD != nil {
+		b.fmt("/3DD %s", pdf.addItem(f.DD))
+	}
+	if f.DV != nil {
+		b.fmt("/3DV %s", f.DV.view3DString(pdf, ref))
+	}
+	if f.DA != nil {
+		b.fmt("/3DA %s", f.DA)
+	}
+	if di, ok := f.DI.(ObjBool); ok {
+		b.fmt("/3DI %v", di)
+	}
+	if f.DB != nil {
+		b.fmt("/3DB %s", f.DB)
+	}
+	return b.String()
+}
+
+func (f Annotation3D) clone(cache cloneCache) Annotation {
+	out := f
+	if f.DD != nil {
+		out.DD = cache.checkOrClone(f.DD).(*Stream3D)
+	}
+	if f.DV != nil {
+		out.DV = f.DV.cloneView3D()
+	}
+	if f.DA != nil {
+		da := *f.DA
+		out.DA = &da
+	}
+	if f.DB != nil {
+		db := *f.DB
+		out.DB = &db
+	}
+	return out
+}
+
+// AnnotationRichMedia embeds rich media content (3D, video, sound, Flash),
+// as specified in the Adobe Supplement to ISO 32000 (Extension Level 3).
+type AnnotationRichMedia struct {
+	RichMediaSettings *RichMediaSettings // optional
+	RichMediaContent  RichMediaContent   // required
+}
+
+func (f AnnotationRichMedia) annotationFields(pdf pdfWriter, ref Reference) string {
+	out := "/Subtype/RichMedia"
+	if f.RichMediaSettings != nil {
+		out += "/RichMediaSettings " + f.RichMediaSettings.String()
+	}
+	return out + "/RichMediaContent " + f.RichMediaContent.pdfString(pdf, ref)
+}
+
+func (f AnnotationRichMedia) clone(cache cloneCache) Annotation {
+	out := f
+	if f.RichMediaSettings != nil {
+		settings := *f.RichMediaSettings
+		out.RichMediaSettings = &settings
+	}
+	out.RichMediaContent = f.RichMediaContent.clone(cache)
+	return out
+}
+
+// -------------------------------------------------------------------------
+
 // TODO: add and check the remaining annotation
 
 type AnnotationFileAttachment struct {
@@ -911,3 +1508,18 @@ func (w AnnotationScreen) clone(cache cloneCache) Annotation {
 	}
 	return out
 }
+
+// AnnotationCustom is an annotation whose subtype is not
+// supported by this package, such as a vendor-specific annotation.
+// Its specific entries are stored in the Custom field of the
+// BaseAnnotation, and may use user defined Object implementations
+// to control how they are written (see reader.Options.CustomObjectResolver).
+type AnnotationCustom struct {
+	Subtype Name
+}
+
+func (an AnnotationCustom) annotationFields(pdfWriter, Reference) string {
+	return "/Subtype" + an.Subtype.String()
+}
+
+func (an AnnotationCustom) clone(cloneCache) Annotation { return an }
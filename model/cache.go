@@ -0,0 +1,159 @@
+package model
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// StreamCache retains the decoded content of streams, so that
+// streams used repeatedly (such as forms or Type3 glyphs shared
+// between pages) are only decoded once. It is used by Document.DecodeStream,
+// and by the packages interpreting content streams (raster, textextract and optimize).
+//
+// The streams are identified by their encoded content and their filters
+// (including their parameters), so that identical streams share the same entry,
+// and modifying a stream in place never returns stale data.
+//
+// It also accounts for the memory used by the decoded contents:
+// when Limit is positive, the least recently used entries are evicted
+// to keep the retained bytes under it. Only the decoded contents
+// are dropped: the encoded `Stream.Content` is never modified.
+//
+// A StreamCache is safe for concurrent use, and may be shared
+// between several documents, so that long-running servers can bound
+// the memory used by all their open documents.
+// The zero value is an empty cache, without limit.
+type StreamCache struct {
+	// Limit is the maximum number of decoded bytes retained.
+	// Zero or negative means no limit.
+	Limit int
+
+	mu      sync.Mutex
+	entries map[streamKey]*list.Element
+	lru     list.List // of *cacheEntry, the most recent first
+	size    int
+}
+
+// streamKey identifies a stream by the hash of its (encoded) content
+// and its filters
+type streamKey [sha256.Size]byte
+
+type cacheEntry struct {
+	key     streamKey
+	decoded []byte
+}
+
+func newStreamKey(s Stream) streamKey {
+	h := sha256.New()
+	for _, f := range s.Filter {
+		fmt.Fprintf(h, "%s<<", f.Name)
+		keys := make([]string, 0, len(f.DecodeParms))
+		for k := range f.DecodeParms {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(h, "/%s %d", k, f.DecodeParms[k])
+		}
+		fmt.Fprint(h, ">>")
+	}
+	h.Write(s.Content)
+	var key streamKey
+	h.Sum(key[:0])
+	return key
+}
+
+// Decode returns the decoded content of `s`, using the cached
+// value if any. The returned slice is shared and must not be mutated.
+// Errors are not cached.
+func (c *StreamCache) Decode(s Stream) ([]byte, error) {
+	if len(s.Content) == 0 {
+		return s.Decode()
+	}
+	key := newStreamKey(s)
+
+	c.mu.Lock()
+	if elem, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(elem)
+		c.mu.Unlock()
+		return elem.Value.(*cacheEntry).decoded, nil
+	}
+	c.mu.Unlock()
+
+	// do not hold the lock while decoding
+	decoded, err := s.Decode()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Limit > 0 && len(decoded) > c.Limit { // too big to be retained
+		return decoded, nil
+	}
+	if _, ok := c.entries[key]; ok { // concurrently added
+		return decoded, nil
+	}
+	if c.entries == nil {
+		c.entries = make(map[streamKey]*list.Element)
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, decoded: decoded})
+	c.size += len(decoded)
+	if c.Limit > 0 {
+		c.evict(c.Limit)
+	}
+	return decoded, nil
+}
+
+// Size returns the number of decoded bytes currently retained.
+func (c *StreamCache) Size() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// Len returns the number of streams currently retained.
+func (c *StreamCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// Evict drops the least recently used entries until
+// at most `target` decoded bytes are retained, and returns
+// the number of bytes released.
+func (c *StreamCache) Evict(target int) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.evict(target)
+}
+
+// Clear drops all the decoded contents, and returns
+// the number of bytes released.
+func (c *StreamCache) Clear() int {
+	return c.Evict(0)
+}
+
+func (c *StreamCache) evict(target int) int {
+	released := 0
+	for c.size > target {
+		elem := c.lru.Back()
+		entry := c.lru.Remove(elem).(*cacheEntry)
+		delete(c.entries, entry.key)
+		c.size -= len(entry.decoded)
+		released += len(entry.decoded)
+	}
+	return released
+}
+
+// DecodeStream decodes `s`, using the document `Cache` if it is not nil.
+// The returned slice may be shared and must not be mutated.
+func (doc *Document) DecodeStream(s Stream) ([]byte, error) {
+	if doc.Cache == nil {
+		return s.Decode()
+	}
+	return doc.Cache.Decode(s)
+}
@@ -0,0 +1,90 @@
+package model
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestStreamCache(t *testing.T) {
+	data1 := bytes.Repeat([]byte("0 0 m 10 10 l S\n"), 100)
+	data2 := bytes.Repeat([]byte("BT (Hello) Tj ET\n"), 100)
+	s1, s2 := NewCompressedStream(data1), NewCompressedStream(data2)
+
+	var cache StreamCache
+	for range [2]int{} {
+		out, err := cache.Decode(s1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(out, data1) {
+			t.Fatal("invalid decoded content")
+		}
+	}
+	if cache.Len() != 1 || cache.Size() != len(data1) {
+		t.Fatalf("unexpected cache state: %d entries, %d bytes", cache.Len(), cache.Size())
+	}
+
+	// identical streams share the cache entry
+	cache.Decode(s1.Clone())
+	if cache.Len() != 1 {
+		t.Fatal("cloned stream should use the cached value")
+	}
+	// the filter parameters are taken into account
+	withParams := s1.Clone()
+	withParams.Filter[0].DecodeParms = map[string]int{"Predictor": 1}
+	cache.Decode(withParams)
+	if cache.Len() != 2 {
+		t.Fatal("stream with other parameters should be cached separately")
+	}
+	// modifying the content in place does not return stale data
+	buffer := make([]byte, len(s1.Content)+len(s2.Content))
+	rewritten := Stream{Content: buffer[:copy(buffer, s1.Content)], Filter: s1.Filter}
+	cache.Decode(rewritten)
+	rewritten.Content = buffer[:copy(buffer, s2.Content)]
+	if out, err := cache.Decode(rewritten); err != nil || !bytes.Equal(out, data2) {
+		t.Fatal("stale cached content")
+	}
+	cache.Clear()
+
+	// the limit evicts the least recently used entries
+	cache.Limit = len(data1) + len(data2) - 1
+	cache.Decode(s1)
+	cache.Decode(s2)
+	if cache.Len() != 1 || cache.Size() != len(data2) {
+		t.Fatalf("unexpected cache state: %d entries, %d bytes", cache.Len(), cache.Size())
+	}
+	if released := cache.Clear(); released != len(data2) || cache.Size() != 0 {
+		t.Fatalf("unexpected released bytes %d", released)
+	}
+	// encoded contents are kept
+	if out, _ := s2.Decode(); !bytes.Equal(out, data2) {
+		t.Fatal("encoded content should be preserved")
+	}
+
+	// too big entries are not retained
+	cache.Limit = 10
+	if out, err := cache.Decode(s1); err != nil || !bytes.Equal(out, data1) {
+		t.Fatal("invalid decoded content")
+	}
+	if cache.Len() != 0 {
+		t.Fatal("entry bigger than the limit should not be retained")
+	}
+
+	// errors are not cached
+	invalid := Stream{Content: []byte("invalid"), Filter: Filters{{Name: Flate}}}
+	if _, err := cache.Decode(invalid); err == nil {
+		t.Fatal("expected error for invalid stream")
+	}
+
+	// a document shares its cache with its clones
+	doc := Document{Cache: &StreamCache{}}
+	doc.DecodeStream(s1)
+	clone := doc.Clone()
+	clone.DecodeStream(s2)
+	if doc.Cache.Len() != 2 {
+		t.Fatal("cache should be shared between clones")
+	}
+	if out, err := (&Document{}).DecodeStream(s2); err != nil || !bytes.Equal(out, data2) {
+		t.Fatal("invalid decoded content without cache")
+	}
+}
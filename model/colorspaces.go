@@ -24,7 +24,7 @@ func (res ResourcesColorSpace) Resolve(cs ColorSpaceName) (ColorSpace, error) {
 	default:
 		c := res[cs]
 		if c == nil {
-			return nil, fmt.Errorf("missing color space for name %s", cs)
+			return nil, newError(ErrMissingEntry, "missing color space for name %s", cs)
 		}
 		return c, nil
 	}
@@ -97,7 +97,7 @@ func NewNameColorSpace(cs string) (ColorSpaceName, error) {
 	case ColorSpaceRGB, ColorSpaceGray, ColorSpaceCMYK, ColorSpacePattern:
 		return c, nil
 	default:
-		return "", fmt.Errorf("invalid named color space %s", cs)
+		return "", newError(ErrType, "invalid named color space %s", cs)
 	}
 }
 
@@ -0,0 +1,225 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+)
+
+// UnresolvedLink is a link whose destination has been
+// removed by Document.UpdateDestinations.
+type UnresolvedLink struct {
+	// Location describes where the link was found, using the same
+	// convention as JavaScriptCode.Location, such as "Outlines[1]", "Outlines[1]/Dest",
+	// "Pages[0]/Annots[2]/A", "Pages[0]/Annots[2]/Dest", "Dests/intro" or "Names/Dests/intro".
+	Location    string
+	Destination Destination
+}
+
+// destinationFixer rewrites the internal destinations of a document
+type destinationFixer struct {
+	mapping map[*PageObject]*PageObject
+	pages   map[*PageObject]bool // pages of the page tree
+
+	// names defined in the Dests entries
+	names   map[Name]bool
+	strings map[DestinationString]bool
+
+	unresolved []UnresolvedLink
+}
+
+// fixExplicit returns the updated destination, or false if it is dangling
+func (f *destinationFixer) fixExplicit(dest DestinationExplicit) (DestinationExplicit, bool) {
+	intern, ok := dest.(DestinationExplicitIntern)
+	if !ok { // external destinations are not modified
+		return dest, true
+	}
+	if page, ok := f.mapping[intern.Page]; ok {
+		intern.Page = page
+	}
+	return intern, intern.Page != nil && f.pages[intern.Page]
+}
+
+// fix returns the updated destination, or false if it is dangling,
+// in which case it is reported
+func (f *destinationFixer) fix(location string, dest Destination) (Destination, bool) {
+	var (
+		out = dest
+		ok  bool
+	)
+	switch dest := dest.(type) {
+	case DestinationExplicitIntern:
+		out, ok = f.fixExplicit(dest)
+	case DestinationName: // be lenient and also accept the name tree
+		ok = f.names[Name(dest)] || f.strings[DestinationString(dest)]
+	case DestinationString:
+		ok = f.strings[dest] || f.names[Name(dest)]
+	default:
+		ok = true
+	}
+	if !ok {
+		f.unresolved = append(f.unresolved, UnresolvedLink{Location: location, Destination: dest})
+	}
+	return out, ok
+}
+
+// fixAction updates the GoTo actions, removing the dangling ones
+// (including their chained actions).
+func (f *destinationFixer) fixAction(location string, action *Action) {
+	if goTo, ok := action.ActionType.(ActionGoTo); ok && goTo.D != nil {
+		dest, ok := f.fix(location, goTo.D)
+		if !ok {
+			*action = Action{}
+			return
+		}
+		goTo.D = dest
+		action.ActionType = goTo
+	}
+	if action.Next == nil {
+		return
+	}
+	next := action.Next[:0]
+	for _, n := range action.Next {
+		f.fixAction(location, &n)
+		if n.ActionType != nil {
+			next = append(next, n)
+		}
+	}
+	action.Next = next
+}
+
+// filter returns the tree with only the entries for which `keep` returns true,
+// removing the empty kids. `keep` may modify the entry.
+func (d DestTree) filter(keep func(entry *NameToDest) bool) DestTree {
+	var out DestTree
+	for _, kid := range d.Kids {
+		if kid = kid.filter(keep); !kid.IsEmpty() {
+			out.Kids = append(out.Kids, kid)
+		}
+	}
+	for _, entry := range d.Names {
+		if keep(&entry) {
+			out.Names = append(out.Names, entry)
+		}
+	}
+	return out
+}
+
+func sortedDestNames(dests map[Name]DestinationExplicit) []Name {
+	out := make([]Name, 0, len(dests))
+	for name := range dests {
+		out = append(out, name)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+// UpdateDestinations rewrites the internal destinations of the document (found in
+// the named destinations, the GoTo actions, the link annotations and the outline items),
+// typically after pages have been deleted, reordered or imported from an other document.
+//
+// A destination to a page found in `mapping` (which may be nil) is redirected to the associated page.
+// Then, the destinations to a page which is not in the page tree are dangling, and removed:
+// named destinations are deleted, GoTo actions are removed (with their chained actions),
+// and link annotations and outline items are kept without destination.
+// The links using a named destination which is not defined anymore are also removed.
+// The removed links are returned.
+func (doc *Document) UpdateDestinations(mapping map[*PageObject]*PageObject) []UnresolvedLink {
+	cat := &doc.Catalog
+	f := destinationFixer{
+		mapping: mapping,
+		pages:   make(map[*PageObject]bool),
+		names:   make(map[Name]bool),
+		strings: make(map[DestinationString]bool),
+	}
+	pages := cat.Pages.Flatten()
+	for _, page := range pages {
+		f.pages[page] = true
+	}
+
+	// start with the named destinations, which are needed to check the links
+	for _, name := range sortedDestNames(cat.Dests) {
+		location := "Dests/" + string(name)
+		dest, ok := f.fixExplicit(cat.Dests[name])
+		if !ok {
+			f.unresolved = append(f.unresolved, UnresolvedLink{Location: location, Destination: cat.Dests[name]})
+			delete(cat.Dests, name)
+			continue
+		}
+		cat.Dests[name] = dest
+		f.names[name] = true
+	}
+	if !cat.Names.Dests.IsEmpty() {
+		cat.Names.Dests = cat.Names.Dests.filter(func(entry *NameToDest) bool {
+			dest, ok := f.fixExplicit(entry.Destination)
+			if !ok {
+				f.unresolved = append(f.unresolved, UnresolvedLink{Location: "Names/Dests/" + string(entry.Name), Destination: entry.Destination})
+				return false
+			}
+			entry.Destination = dest
+			f.strings[entry.Name] = true
+			return true
+		})
+	}
+
+	doc.walkActions(f.fixAction)
+
+	seen := make(map[*AnnotationDict]bool)
+	for i, page := range pages {
+		for j, annot := range page.Annots {
+			if annot == nil || seen[annot] {
+				continue
+			}
+			seen[annot] = true
+			if link, ok := annot.Subtype.(AnnotationLink); ok && link.Dest != nil {
+				if link.Dest, ok = f.fix(fmt.Sprintf("Pages[%d]/Annots[%d]/Dest", i, j), link.Dest); !ok {
+					link.Dest = nil
+				}
+				annot.Subtype = link
+			}
+		}
+	}
+
+	if cat.Outlines != nil {
+		for i, item := range cat.Outlines.Flatten() {
+			if item.Dest == nil {
+				continue
+			}
+			var ok bool
+			if item.Dest, ok = f.fix(fmt.Sprintf("Outlines[%d]/Dest", i), item.Dest); !ok {
+				item.Dest = nil
+			}
+		}
+	}
+	return f.unresolved
+}
+
+// RemapPages rearranges the pages of the document, so that
+// the i-th page is the former page with index newOrder[i] (0-based).
+// The pages not listed are removed, and invalid or repeated indices are ignored.
+// The inherited resources and media boxes are resolved, and the interactive form is
+// pruned as in ExtractPages.
+// Finally, the destinations are updated (see UpdateDestinations),
+// and the removed links are returned.
+func (doc *Document) RemapPages(newOrder []int) []UnresolvedLink {
+	pages := doc.Catalog.Pages.Flatten()
+	inherited := doc.Catalog.Pages.FlattenInherit()
+
+	var tree PageTree
+	seen := make(map[int]bool)
+	widgets := make(map[*AnnotationDict]bool)
+	for _, index := range newOrder {
+		if index < 0 || index >= len(pages) || seen[index] {
+			continue
+		}
+		seen[index] = true
+		page := pages[index]
+		page.Resources, page.MediaBox = inherited[index].Resources, inherited[index].MediaBox
+		tree.Kids = append(tree.Kids, page)
+		for _, annot := range page.Annots {
+			widgets[annot] = true
+		}
+	}
+	doc.Catalog.Pages = tree
+	doc.Catalog.AcroForm.pruneWidgets(widgets)
+	return doc.UpdateDestinations(nil)
+}
@@ -0,0 +1,113 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRemapPages(t *testing.T) {
+	p1, p2, p3 := &PageObject{}, &PageObject{}, &PageObject{}
+	to := func(page *PageObject) DestinationExplicitIntern {
+		return DestinationExplicitIntern{Page: page, Location: DestinationLocationFit("Fit")}
+	}
+	link := &AnnotationDict{Subtype: AnnotationLink{Dest: to(p2)}}
+	namedLink := &AnnotationDict{Subtype: AnnotationLink{Dest: DestinationString("second")}}
+	p1.Annots = []*AnnotationDict{link, namedLink}
+	p3.Annots = []*AnnotationDict{{Subtype: AnnotationLink{A: Action{ActionType: ActionGoTo{D: to(p1)}}}}}
+
+	var doc Document
+	doc.Catalog.Pages.Kids = []PageNode{p1, p2, p3}
+	doc.Catalog.Names.Dests = DestTree{Kids: []DestTree{
+		{Names: []NameToDest{{Name: "first", Destination: to(p1)}}},
+		{Names: []NameToDest{{Name: "second", Destination: to(p2)}}},
+	}}
+	doc.Catalog.OpenAction = Action{
+		ActionType: ActionGoTo{D: to(p3)},
+		Next:       []Action{{ActionType: ActionGoTo{D: to(p2)}}, {ActionType: ActionJavaScript{JS: "a"}}},
+	}
+	doc.Catalog.Outlines = &Outline{}
+	item1 := &OutlineItem{Title: "1", Parent: doc.Catalog.Outlines, Dest: to(p2)}
+	item2 := &OutlineItem{Title: "2", Parent: doc.Catalog.Outlines, A: Action{ActionType: ActionGoTo{D: DestinationName("old")}}}
+	item1.Next = item2
+	doc.Catalog.Outlines.First = item1
+
+	// reverse the first and last pages, and remove the second one
+	unresolved := doc.RemapPages([]int{2, 0})
+	if pages := doc.Catalog.Pages.Flatten(); len(pages) != 2 || pages[0] != p3 || pages[1] != p1 {
+		t.Fatalf("unexpected pages %v", pages)
+	}
+
+	exp := []string{"Names/Dests/second", "OpenAction", "Outlines[1]", "Pages[1]/Annots[0]/Dest", "Pages[1]/Annots[1]/Dest", "Outlines[0]/Dest"}
+	var got []string
+	for _, u := range unresolved {
+		got = append(got, u.Location)
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Fatalf("expected %v, got %v", exp, got)
+	}
+
+	if tree := doc.Catalog.Names.Dests; len(tree.Kids) != 1 || len(tree.LookupTable()) != 1 {
+		t.Fatalf("unexpected Dests %v", tree)
+	}
+	if next := doc.Catalog.OpenAction.Next; len(next) != 1 {
+		t.Fatalf("unexpected chained actions %v", next)
+	}
+	if link.Subtype.(AnnotationLink).Dest != nil || namedLink.Subtype.(AnnotationLink).Dest != nil {
+		t.Fatal("dangling links should be removed")
+	}
+	if goTo := p3.Annots[0].Subtype.(AnnotationLink).A.ActionType; goTo.(ActionGoTo).D.(DestinationExplicitIntern).Page != p1 {
+		t.Fatal("valid link should be preserved")
+	}
+	if item1.Dest != nil || item2.A.ActionType != nil {
+		t.Fatal("dangling outline destinations should be removed")
+	}
+}
+
+func TestUpdateDestinations(t *testing.T) {
+	old, moved := &PageObject{}, &PageObject{}
+	var doc Document
+	doc.Catalog.Pages.Kids = []PageNode{moved}
+	doc.Catalog.Dests = map[Name]DestinationExplicit{
+		"a": DestinationExplicitIntern{Page: old},
+		"b": DestinationExplicitExtern{Page: 4},
+	}
+	link := &AnnotationDict{Subtype: AnnotationLink{Dest: DestinationName("a")}}
+	moved.Annots = []*AnnotationDict{link}
+
+	if unresolved := doc.UpdateDestinations(map[*PageObject]*PageObject{old: moved}); len(unresolved) != 0 {
+		t.Fatalf("unexpected unresolved links %v", unresolved)
+	}
+	if doc.Catalog.Dests["a"].(DestinationExplicitIntern).Page != moved {
+		t.Fatal("destination not redirected")
+	}
+	if len(doc.Catalog.Dests) != 2 || link.Subtype.(AnnotationLink).Dest == nil {
+		t.Fatal("valid destinations should be preserved")
+	}
+
+	// the page is now dangling
+	unresolved := doc.UpdateDestinations(map[*PageObject]*PageObject{moved: nil})
+	if len(unresolved) != 2 || unresolved[0].Location != "Dests/a" || unresolved[1].Location != "Pages[0]/Annots[0]/Dest" {
+		t.Fatalf("unexpected unresolved links %v", unresolved)
+	}
+}
+
+func TestExtractPagesDestinations(t *testing.T) {
+	p1, p2 := &PageObject{}, &PageObject{}
+	var doc Document
+	doc.Catalog.Pages.Kids = []PageNode{p1, p2}
+	doc.Catalog.Outlines = &Outline{}
+	doc.Catalog.Outlines.First = &OutlineItem{Title: "2", Parent: doc.Catalog.Outlines, Dest: DestinationExplicitIntern{Page: p2}}
+	p1.Annots = []*AnnotationDict{{Subtype: AnnotationLink{Dest: DestinationExplicitIntern{Page: p1}}}}
+
+	out := doc.ExtractPages([]int{0})
+	if out.Catalog.Outlines.First.Dest != nil {
+		t.Fatal("destination to removed page should be removed")
+	}
+	page := out.Catalog.Pages.Flatten()[0]
+	if page.Annots[0].Subtype.(AnnotationLink).Dest.(DestinationExplicitIntern).Page != page {
+		t.Fatal("destination should target the cloned page")
+	}
+	if doc.Catalog.Outlines.First.Dest == nil {
+		t.Fatal("original document modified")
+	}
+}
@@ -111,6 +111,22 @@ func validationSalt(bb []byte) []byte { return bb[32:40] }
 
 func keySalt(bb []byte) []byte { return bb[40:] }
 
+// EncryptionKey computes the file encryption key from the (owner or user) `password`
+// and the O, U, OE and UE entries of `enc`, as described in
+// Algorithm 2.A: Retrieving the file encryption key from an encrypted document (revision 5).
+// It returns `false` if the password is incorrect.
+// For AES-256, the file encryption key is used for every object (see ObjectEncryptionKey).
+func (s *AESSecurityHandler) EncryptionKey(password string, enc EncryptionStandard) ([]byte, bool) {
+	key, ok := s.authOwnerPassword(password, enc.O, enc.U, enc.OE)
+	if !ok {
+		key, ok = s.authUserPassword(password, enc.O, enc.U, enc.UE)
+	}
+	if !ok {
+		return nil, false
+	}
+	return key[:], true
+}
+
 // AuthenticatePasswords compare the given passwords to the hash found in a PDF file, returning
 // `true` if one of the password is correct, as well as the encryption key.
 func (s *AESSecurityHandler) AuthenticatePasswords(ownerPassword, userPassword string, enc EncryptionStandard) ([]byte, bool) {
@@ -243,9 +243,9 @@ func (d Document) UseStandardEncryptionHandler(enc Encrypt, ownerPassword, userP
 	out.R = s.revision
 	out.DontEncryptMetadata = s.dontEncryptMetadata
 
-	out.O = s.generateOwnerHash(userPassword, ownerPassword)
-	out.encryptionKey = s.generateEncryptionKey(userPassword, out.O)
-	out.U = s.generateUserHash(out.encryptionKey)
+	out.O = s.OwnerHash(userPassword, ownerPassword)
+	out.encryptionKey = s.EncryptionKey(userPassword, out.O)
+	out.U = s.UserHash(out.encryptionKey)
 
 	enc.EncryptionHandler = out
 
@@ -276,20 +276,27 @@ func (e EncryptionStandard) Clone() EncryptionHandler {
 // with the RC4 algorithm.
 func (p EncryptionStandard) crypt(n Reference, data []byte) ([]byte, error) {
 	out := make([]byte, len(data))
-	rc4cipher, _ := rc4.NewCipher(objectEncrytionKey(p.encryptionKey, n, false))
+	rc4cipher, _ := rc4.NewCipher(ObjectEncryptionKey(p.encryptionKey, int(n), 0, false))
 	rc4cipher.XORKeyStream(out, data)
 	return out, nil
 }
 
-func objectEncrytionKey(baseKey []byte, n Reference, aes bool) []byte {
-	var nbuf [4]byte
-	binary.LittleEndian.PutUint32(nbuf[:], uint32(n))
-	b := append(baseKey, nbuf[0], nbuf[1], nbuf[2], 0, 0) // copy and padding (generation number is 0)
+// ObjectEncryptionKey computes the key used to encrypt the strings and streams
+// of the object identified by `objectNumber` and `generation`, from the
+// file encryption key `fileKey` (see RC4SecurityHandler.EncryptionKey).
+// `aes` must be true for the AESV2 crypt filter.
+// It implements the steps a) to d) of Algorithm 1 in the PDF SPEC, and is not
+// used by security handlers of revision 5, which directly use the file encryption key.
+// The returned key is 5 to 16 bytes long.
+func ObjectEncryptionKey(fileKey []byte, objectNumber, generation int, aes bool) []byte {
+	b := append([]byte(nil), fileKey...) // copy to preserve fileKey
+	b = append(b, byte(objectNumber), byte(objectNumber>>8), byte(objectNumber>>16),
+		byte(generation), byte(generation>>8))
 	if aes {
 		b = append(b, 0x73, 0x41, 0x6C, 0x54) // append sAlT
 	}
 	s := md5.Sum(b)
-	size := len(baseKey) + 5
+	size := len(fileKey) + 5
 	if size > 16 {
 		size = 16
 	}
@@ -37,8 +37,11 @@ func (e *Encrypt) NewRC4SecurityHandler(fileID string, revision uint8, dontEncry
 	}
 }
 
-// Algorithm 2: Computing an encryption key
-func (s RC4SecurityHandler) generateEncryptionKey(password string, ownerHash [48]byte) []byte {
+// EncryptionKey computes the file encryption key from the (user) `password`
+// and the O entry of the encryption dictionary, as described in
+// Algorithm 2: Computing an encryption key.
+// The key of each object is then derived with ObjectEncryptionKey.
+func (s RC4SecurityHandler) EncryptionKey(password string, ownerHash [48]byte) []byte {
 	pass := padPassword(password)
 	keyLength := s.keyLength()
 
@@ -61,7 +64,7 @@ func (s RC4SecurityHandler) generateEncryptionKey(password string, ownerHash [48
 }
 
 // Algorithm 3, steps a) -> d)
-func (s *RC4SecurityHandler) generateOwnerEncryptionKey(ownerPassword string) []byte {
+func (s RC4SecurityHandler) generateOwnerEncryptionKey(ownerPassword string) []byte {
 	ownerPass := padPassword(ownerPassword)
 
 	keyLength := s.keyLength()
@@ -76,8 +79,10 @@ func (s *RC4SecurityHandler) generateOwnerEncryptionKey(ownerPassword string) []
 	return tmp[0:keyLength]
 }
 
-// Algorithm 3: Computing the encryption dictionary’s O (owner password) value
-func (s *RC4SecurityHandler) generateOwnerHash(userPassword, ownerPassword string) (v [48]byte) {
+// OwnerHash computes the O entry of the encryption dictionary, as described in
+// Algorithm 3: Computing the encryption dictionary’s O (owner password) value.
+// Only the first 32 bytes are used.
+func (s RC4SecurityHandler) OwnerHash(userPassword, ownerPassword string) (v [48]byte) {
 	firstEncKey := s.generateOwnerEncryptionKey(ownerPassword)
 
 	userPass := padPassword(userPassword)
@@ -91,11 +96,14 @@ func (s *RC4SecurityHandler) generateOwnerHash(userPassword, ownerPassword strin
 	return v
 }
 
+// UserHash computes the U entry of the encryption dictionary from the
+// file encryption key (see EncryptionKey), as described in
 // Algorithm 4: Computing the encryption dictionary’s U (user password) value (Security handlers of
-// revision 2)
+// revision 2) and
 // Algorithm 5: Computing the encryption dictionary’s U (user password) value (Security handlers of
-// revision 3 or greater)
-func (s RC4SecurityHandler) generateUserHash(encryptionKey []byte) (v [48]byte) {
+// revision 3 or greater).
+// Only the first 32 bytes are used, and for revision 3 or greater, only the first 16 bytes are significant.
+func (s RC4SecurityHandler) UserHash(encryptionKey []byte) (v [48]byte) {
 	c, _ := rc4.NewCipher(encryptionKey)
 	if s.revision >= 3 {
 		buf := padding[:]
@@ -114,8 +122,8 @@ func (s RC4SecurityHandler) generateUserHash(encryptionKey []byte) (v [48]byte)
 // It returns the encryption key and `true` if the password is correct, or `false`.
 // See - Algorithm 6: Authenticating the user password
 func (s *RC4SecurityHandler) authUserPassword(password string, ownerHash, userHash [48]byte) ([]byte, bool) {
-	encryptionKey := s.generateEncryptionKey(password, ownerHash)
-	gotHash := s.generateUserHash(encryptionKey)
+	encryptionKey := s.EncryptionKey(password, ownerHash)
+	gotHash := s.UserHash(encryptionKey)
 
 	// Quoting the SPEC : comparing on the first 16 bytes in the case of security handlers of revision 3 or greater
 	var ok bool
@@ -2,7 +2,11 @@ package model_test
 
 import (
 	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
 	"crypto/rc4"
+	"crypto/sha256"
+	"encoding/hex"
 	"os"
 	"strings"
 	"testing"
@@ -72,3 +76,111 @@ func TestRC4Basic(t *testing.T) {
 		}
 	}
 }
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// the expected values have been computed with an independent
+// implementation of the algorithms described in the PDF SPEC
+func TestRC4KeyDerivation(t *testing.T) {
+	fileID := string(mustHex("000102030405060708090a0b0c0d0e0f"))
+	for _, test := range []struct {
+		revision          uint8
+		length            uint8
+		o, key, u         string
+		objKey, objKeyAES string
+	}{
+		{
+			2, 5,
+			"94e8094419662a774442fb072e3d9f19e9d130ec09a4d0061e78fe920f7ab62f",
+			"7fca5cfcc5",
+			"13f520c882d052bf57b416b747c13979bded7ea31240fe41928852aca3894c49",
+			"f207d8ae66749212800c", "6e330aacde3b021cdde0",
+		},
+		{
+			3, 16,
+			"0ba3835f88f90388e74e54584125ce142be0de24c6b0d37746e075b891756671",
+			"ebc53cf170c71152a5ba9925bd0fefc3",
+			"b8d04c0b647956d75df3b1f5a437ef9700000000000000000000000000000000",
+			"c04f7fcaf7c597813958964926b93471", "ac8aecfdb875dbf2b833585ebeb631ce",
+		},
+	} {
+		enc := mo.Encrypt{P: mo.UserPermissions(0xFFFFF0C0), Length: test.length}
+		s := enc.NewRC4SecurityHandler(fileID, test.revision, false)
+
+		o := s.OwnerHash("user", "owner")
+		if got := hex.EncodeToString(o[:32]); got != test.o {
+			t.Errorf("revision %d: expected O %s, got %s", test.revision, test.o, got)
+		}
+		key := s.EncryptionKey("user", o)
+		if got := hex.EncodeToString(key); got != test.key {
+			t.Errorf("revision %d: expected key %s, got %s", test.revision, test.key, got)
+		}
+		u := s.UserHash(key)
+		if got := hex.EncodeToString(u[:32]); got != test.u {
+			t.Errorf("revision %d: expected U %s, got %s", test.revision, test.u, got)
+		}
+		if got := hex.EncodeToString(mo.ObjectEncryptionKey(key, 12, 0, false)); got != test.objKey {
+			t.Errorf("revision %d: expected object key %s, got %s", test.revision, test.objKey, got)
+		}
+		if got := hex.EncodeToString(mo.ObjectEncryptionKey(key, 300, 2, true)); got != test.objKeyAES {
+			t.Errorf("revision %d: expected AES object key %s, got %s", test.revision, test.objKeyAES, got)
+		}
+
+		// the key is retrieved from both passwords
+		for _, password := range [...]string{"user", "owner"} {
+			got, ok := s.AuthenticatePasswords(password, password, mo.EncryptionStandard{O: o, U: u})
+			if !ok || !bytes.Equal(got, key) {
+				t.Errorf("revision %d: invalid authentication for %s", test.revision, password)
+			}
+		}
+	}
+}
+
+// aesEncryptKey encrypts `key` with AES-256, with no padding and a zero IV,
+// as described in Algorithm 8 and 9 (revision 5)
+func aesEncryptKey(password, salt, key []byte) (out [32]byte) {
+	h := sha256.Sum256(append(append([]byte(nil), password...), salt...))
+	c, _ := aes.NewCipher(h[:])
+	cipher.NewCBCEncrypter(c, make([]byte, 16)).CryptBlocks(out[:], key)
+	return out
+}
+
+func TestAESKeyDerivation(t *testing.T) {
+	fileKey := mustHex("6ff2ce5ec6fd3dc58a7d7f3ac60bf5e42fc01fb5c92d4b90cc54bf4c0d70ae48")
+	user, owner := []byte("user"), []byte("owner")
+	var enc mo.EncryptionStandard
+	enc.R = 5
+
+	// Algorithm 8: computing U and UE
+	userValidation, userKey := []byte("uvsalt01"), []byte("uksalt01")
+	h := sha256.Sum256(append(append([]byte(nil), user...), userValidation...))
+	copy(enc.U[:], h[:])
+	copy(enc.U[32:], userValidation)
+	copy(enc.U[40:], userKey)
+	enc.UE = aesEncryptKey(user, userKey, fileKey)
+
+	// Algorithm 9: computing O and OE
+	ownerValidation, ownerKey := []byte("ovsalt01"), []byte("oksalt01")
+	h = sha256.Sum256(append(append(append([]byte(nil), owner...), ownerValidation...), enc.U[:]...))
+	copy(enc.O[:], h[:])
+	copy(enc.O[32:], ownerValidation)
+	copy(enc.O[40:], ownerKey)
+	enc.OE = aesEncryptKey(append(append([]byte(nil), owner...), ownerKey...), enc.U[:], fileKey)
+
+	s := (&mo.Encrypt{V: mo.EaAES}).NewAESSecurityHandler("", 5, false)
+	for _, password := range [...]string{"user", "owner"} {
+		key, ok := s.EncryptionKey(password, enc)
+		if !ok || !bytes.Equal(key, fileKey) {
+			t.Errorf("invalid key for %s: %x", password, key)
+		}
+	}
+	if _, ok := s.EncryptionKey("other", enc); ok {
+		t.Error("expected invalid password")
+	}
+}
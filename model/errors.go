@@ -0,0 +1,56 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The errors returned by this package may be inspected with errors.Is
+// and errors.As, to distinguish between invalid data (ErrType, ErrMissingEntry),
+// invalid use of the API (ErrInvalidArgument) and unsupported features (ErrUnsupportedFeature).
+var (
+	// ErrType is returned for values with an unexpected type or format,
+	// usually found in corrupted files.
+	ErrType = errors.New("invalid type")
+	// ErrMissingEntry is returned when a required entry is missing.
+	ErrMissingEntry = errors.New("missing entry")
+	// ErrInvalidArgument is returned when the arguments
+	// of a function or a method are not valid.
+	ErrInvalidArgument = errors.New("invalid argument")
+)
+
+// ErrUnsupportedFeature is returned when a valid document
+// uses a feature not (yet) supported by this package.
+type ErrUnsupportedFeature struct {
+	Feature string // such as "filter JBIG2Decode"
+	Err     error  // optional, the underlying error
+}
+
+func (e ErrUnsupportedFeature) Error() string { return "unsupported " + e.Feature }
+
+func (e ErrUnsupportedFeature) Unwrap() error { return e.Err }
+
+// kindError is an error matching (see errors.Is) one of
+// the error variables, with a detailed message.
+type kindError struct {
+	kind  error
+	msg   string
+	cause error // optional
+}
+
+func (e kindError) Error() string { return e.msg }
+
+func (e kindError) Is(target error) bool { return target == e.kind }
+
+func (e kindError) Unwrap() error { return e.cause }
+
+// newError returns an error matching `kind`
+func newError(kind error, format string, args ...interface{}) error {
+	return kindError{kind: kind, msg: fmt.Sprintf(format, args...)}
+}
+
+// wrapError returns an error matching `kind`, and wrapping `cause`,
+// whose message is appended to the formatted one.
+func wrapError(kind, cause error, format string, args ...interface{}) error {
+	return kindError{kind: kind, msg: fmt.Sprintf(format, args...) + ": " + cause.Error(), cause: cause}
+}
@@ -0,0 +1,31 @@
+package model
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestErrorKinds(t *testing.T) {
+	page := &PageObject{MediaBox: &Rectangle{Urx: 100, Ury: 100}}
+	if err := page.RotateContent(45); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("expected invalid argument, got %v", err)
+	}
+	if err := (&PageObject{}).RotateContent(90); !errors.Is(err, ErrMissingEntry) {
+		t.Fatalf("expected missing entry, got %v", err)
+	}
+	if _, err := (ResourcesColorSpace{}).Resolve("CS0"); !errors.Is(err, ErrMissingEntry) || errors.Is(err, ErrType) {
+		t.Fatalf("expected missing entry, got %v", err)
+	}
+	if _, err := ParseXMP([]byte("<x:xmpmeta")); !errors.Is(err, ErrType) {
+		t.Fatalf("expected invalid type, got %v", err)
+	}
+
+	var unsupported ErrUnsupportedFeature
+	if _, err := FromNative(map[int]int{1: 2}); !errors.As(err, &unsupported) || unsupported.Feature != "map key type int" {
+		t.Fatalf("expected unsupported feature, got %v", err)
+	}
+	stream := Stream{Content: []byte{1, 2}, Filter: Filters{{Name: JBIG2}}}
+	if _, err := stream.Decode(); !errors.As(err, &unsupported) || err.Error() != "unsupported filter JBIG2Decode" {
+		t.Fatalf("expected unsupported feature, got %v", err)
+	}
+}
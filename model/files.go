@@ -1,8 +1,13 @@
 package model
 
 import (
+	"compress/zlib"
 	"crypto/md5"
 	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -13,6 +18,16 @@ type FileSpec struct {
 	UF   string // optional
 	EF   *EmbeddedFileStream
 	Desc string // optional
+
+	// CI is the collection item dictionary, used to
+	// display the file in a portable collection (see Collection).
+	// It is written as an indirect object.
+	CI map[Name]CollectionItem // optional
+
+	// AFRelationship specifies the relationship between the
+	// file and the object it is associated with (see Catalog.AF),
+	// one of Source, Data, Alternative, Supplement, EncryptedPayload, FormData, Schema or Unspecified
+	AFRelationship Name // optional
 }
 
 // returns the dictionnay, with a nil content `pdf` is used
@@ -32,6 +47,14 @@ func (f *FileSpec) pdfContent(pdf pdfWriter, ref Reference) (StreamHeader, strin
 	if f.Desc != "" {
 		b.fmt("/Desc %s", pdf.EncodeString(f.Desc, TextString, ref))
 	}
+	if len(f.CI) != 0 {
+		ciRef := pdf.CreateObject()
+		pdf.WriteObject(collectionItemString(f.CI, pdf, ciRef), ciRef)
+		b.fmt("/CI %s", ciRef)
+	}
+	if f.AFRelationship != "" {
+		b.fmt("/AFRelationship %s", f.AFRelationship)
+	}
 	b.fmt(">>")
 	return StreamHeader{}, b.String(), nil
 }
@@ -42,9 +65,38 @@ func (f *FileSpec) clone(cache cloneCache) Referenceable {
 	}
 	out := *f
 	out.EF = cache.checkOrClone(f.EF).(*EmbeddedFileStream)
+	if f.CI != nil {
+		out.CI = make(map[Name]CollectionItem, len(f.CI))
+		for k, v := range f.CI {
+			out.CI[k] = v
+		}
+	}
 	return &out
 }
 
+// writeFileSpecArray writes the file specifications
+// as an array of references
+func writeFileSpecArray(pdf pdfWriter, fs []*FileSpec) string {
+	refs := make([]Reference, len(fs))
+	for i, f := range fs {
+		refs[i] = pdf.addItem(f)
+	}
+	return writeRefArray(refs)
+}
+
+func cloneFileSpecArray(fs []*FileSpec, cache cloneCache) []*FileSpec {
+	if fs == nil {
+		return nil
+	}
+	out := make([]*FileSpec, len(fs))
+	for i, f := range fs {
+		if f != nil {
+			out[i] = cache.checkOrClone(f).(*FileSpec)
+		}
+	}
+	return out
+}
+
 type EmbeddedFileParams struct {
 	CreationDate time.Time // optional
 	ModDate      time.Time // optional
@@ -81,16 +133,59 @@ func (params EmbeddedFileParams) pdfString(pdf pdfWriter, ref Reference) string
 
 type EmbeddedFileStream struct {
 	Stream
-	Params EmbeddedFileParams // optional
+	// optional, the MIME type of the file, such as "text/xml"
+	// It is written as a name, and must be an ASCII string.
+	Subtype string
+	Params  EmbeddedFileParams // optional
+}
+
+// NewEmbeddedFileStream returns an embedded file for `content`, which must be the original
+// (not encoded) data, with Params Size and CheckSum computed accordingly. The CreationDate and ModDate
+// are set to the current time, and may be adjusted by the caller.
+// `mimeType` is optional (see `EmbeddedFileStream.Subtype`).
+// `compressionLevel` is one of the compress/zlib level constants. As a special case,
+// `zlib.NoCompression` stores the content with no filter at all.
+func NewEmbeddedFileStream(content []byte, mimeType string, compressionLevel int) *EmbeddedFileStream {
+	out := EmbeddedFileStream{Subtype: mimeType}
+	out.Params.SetChecksumAndSize(content)
+	now := time.Now()
+	out.Params.CreationDate, out.Params.ModDate = now, now
+	if compressionLevel == zlib.NoCompression {
+		out.Content = append([]byte(nil), content...)
+	} else {
+		out.Stream = newFlateStream(content, compressionLevel)
+	}
+	return &out
 }
 
 func (emb *EmbeddedFileStream) pdfContent(pdf pdfWriter, ref Reference) (StreamHeader, string, []byte) {
 	args := emb.PDFCommonFields(true)
 	args.Fields["Type"] = "/EmbeddedFile"
+	if emb.Subtype != "" {
+		args.Fields["Subtype"] = escapeName(emb.Subtype)
+	}
 	args.Fields["Params"] = emb.Params.pdfString(pdf, ref)
 	return args, "", emb.Content
 }
 
+// escapeName returns the PDF representation of the name `s`,
+// escaping the delimiters and the non regular characters (such as the "/" of MIME types)
+// with the #xx notation.
+func escapeName(s string) string {
+	var b strings.Builder
+	b.WriteByte('/')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c < '!' || c > '~' || strings.IndexByte("#%()/<>[]{}", c) != -1:
+			fmt.Fprintf(&b, "#%02X", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
 // clone returns a deep copy, with concrete type `*EmbeddedFileStream`
 func (emb *EmbeddedFileStream) clone(cloneCache) Referenceable {
 	if emb == nil {
@@ -100,3 +195,281 @@ func (emb *EmbeddedFileStream) clone(cloneCache) Referenceable {
 	out.Stream = emb.Stream.Clone()
 	return &out
 }
+
+// ------------------------------- Portable collections -------------------------------
+
+// Collection specifies the presentation of the embedded files (see NameDictionary.EmbeddedFiles)
+// of a portable collection, also called portfolio.
+// See Table 153 – Entries in a collection dictionary
+type Collection struct {
+	Schema map[Name]CollectionField // optional
+	D      string                   // optional, the name of the initial document, in the embedded files tree
+	View   Name                     // optional, D (details mode, default), T (tile mode) or H (hidden)
+	Sort   *CollectionSort          // optional
+}
+
+func (c Collection) pdfString(pdf pdfWriter, ref Reference) string {
+	b := newBuffer()
+	b.WriteString("<</Type/Collection")
+	if len(c.Schema) != 0 {
+		names := make([]Name, 0, len(c.Schema))
+		for name := range c.Schema {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+		b.WriteString("/Schema <</Type/CollectionSchema")
+		for _, name := range names {
+			b.fmt("%s %s", name, c.Schema[name].pdfString(pdf, ref))
+		}
+		b.WriteString(">>")
+	}
+	if c.D != "" {
+		b.fmt("/D %s", pdf.EncodeString(c.D, ByteString, ref))
+	}
+	if c.View != "" {
+		b.fmt("/View %s", c.View)
+	}
+	if c.Sort != nil {
+		b.fmt("/Sort %s", c.Sort.pdfString())
+	}
+	b.WriteString(">>")
+	return b.String()
+}
+
+func (c *Collection) clone() *Collection {
+	if c == nil {
+		return nil
+	}
+	out := *c
+	if c.Schema != nil {
+		out.Schema = make(map[Name]CollectionField, len(c.Schema))
+		for k, v := range c.Schema {
+			out.Schema[k] = v
+		}
+	}
+	if c.Sort != nil {
+		cs := c.Sort.clone()
+		out.Sort = &cs
+	}
+	return &out
+}
+
+// CollectionField describes a field of a collection schema,
+// that is a column of the portfolio view.
+// See Table 155 – Entries in a collection field dictionary
+type CollectionField struct {
+	// Subtype is the type of data stored in the field:
+	// S (text), D (date), N (number), or a file related data:
+	// F (file name), Desc (description), ModDate, CreationDate or Size
+	Subtype Name
+	N       string    // textual field name presented to the user
+	O       MaybeInt  // optional, order of the field
+	V       MaybeBool // optional, default to true, visibility of the field
+	E       bool      // optional, true if the field should be editable
+}
+
+func (f CollectionField) pdfString(pdf pdfWriter, ref Reference) string {
+	b := newBuffer()
+	b.fmt("<</Type/CollectionField/Subtype %s/N %s", f.Subtype, pdf.EncodeString(f.N, TextString, ref))
+	if o, ok := f.O.(ObjInt); ok {
+		b.fmt("/O %d", o)
+	}
+	if v, ok := f.V.(ObjBool); ok {
+		b.fmt("/V %v", v)
+	}
+	if f.E {
+		b.WriteString("/E true")
+	}
+	b.WriteString(">>")
+	return b.String()
+}
+
+// CollectionSort specifies the order of the files in a collection.
+// See Table 158 – Entries in a collection sort dictionary
+type CollectionSort struct {
+	S []Name // names of the fields used to sort, written as a name if there is only one
+	A []bool // optional, ascending order for each field in S, written as a boolean if there is only one
+}
+
+func (s CollectionSort) pdfString() string {
+	b := newBuffer()
+	b.WriteString("<</Type/CollectionSort")
+	if len(s.S) == 1 {
+		b.fmt("/S %s", s.S[0])
+	} else {
+		b.fmt("/S %s", writeNameArray(s.S))
+	}
+	switch len(s.A) {
+	case 0:
+	case 1:
+		b.fmt("/A %v", s.A[0])
+	default:
+		chunks := make([]string, len(s.A))
+		for i, a := range s.A {
+			chunks[i] = strconv.FormatBool(a)
+		}
+		b.fmt("/A [%s]", strings.Join(chunks, " "))
+	}
+	b.WriteString(">>")
+	return b.String()
+}
+
+func (s CollectionSort) clone() CollectionSort {
+	out := s
+	out.S = append([]Name(nil), s.S...)
+	out.A = append([]bool(nil), s.A...)
+	return out
+}
+
+// CollectionValue is the data of a collection item,
+// one of CollectionText, CollectionDate or CollectionNumber.
+type CollectionValue interface {
+	collectionValueString(pdf pdfWriter, ref Reference) string
+}
+
+// CollectionText is a text string
+type CollectionText string
+
+// CollectionDate is written as a date string
+type CollectionDate time.Time
+
+// CollectionNumber is a numeric value
+type CollectionNumber Fl
+
+func (c CollectionText) collectionValueString(pdf pdfWriter, ref Reference) string {
+	return pdf.EncodeString(string(c), TextString, ref)
+}
+
+func (c CollectionDate) collectionValueString(pdf pdfWriter, ref Reference) string {
+	return pdf.dateString(time.Time(c), ref)
+}
+
+func (c CollectionNumber) collectionValueString(pdf pdfWriter, ref Reference) string {
+	return FmtFloat(Fl(c))
+}
+
+// CollectionItem is the value of a field of the collection schema
+// for an embedded file, with an optional prefix.
+// See Table 156 – Entries in a collection item dictionary and
+// Table 157 – Entries in a collection subitem dictionary
+type CollectionItem struct {
+	Value CollectionValue
+	// Prefix is a text string displayed before the value, but not used for sorting.
+	// If not empty, the item is written as a collection subitem dictionary.
+	Prefix string // optional
+}
+
+func collectionItemString(items map[Name]CollectionItem, pdf pdfWriter, ref Reference) string {
+	names := make([]Name, 0, len(items))
+	for name := range items {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	b := newBuffer()
+	b.WriteString("<</Type/CollectionItem")
+	for _, name := range names {
+		item := items[name]
+		if item.Value == nil {
+			continue
+		}
+		value := item.Value.collectionValueString(pdf, ref)
+		if item.Prefix != "" {
+			value = fmt.Sprintf("<</Type/CollectionSubitem/D %s/P %s>>", value, pdf.EncodeString(item.Prefix, TextString, ref))
+		}
+		b.fmt("%s %s", name, value)
+	}
+	b.WriteString(">>")
+	return b.String()
+}
+
+// ------------------------------- Attachments -------------------------------
+
+// AttachFile embeds `content` in the document, with the given file name,
+// and registers it in the embedded files tree (see NameDictionary.EmbeddedFiles),
+// replacing an existing entry with the same name.
+// The file content is compressed and its size, checksum and dates are set.
+// `mimeType` and `description` are optional.
+// The returned file specification may be used to further customize the attachment.
+func (doc *Document) AttachFile(name string, content []byte, mimeType, description string) *FileSpec {
+	fs := &FileSpec{
+		UF:   name,
+		EF:   NewEmbeddedFileStream(content, mimeType, zlib.DefaultCompression),
+		Desc: description,
+	}
+	files := doc.Catalog.Names.EmbeddedFiles
+	index := sort.Search(len(files), func(i int) bool { return files[i].Name >= name })
+	if index < len(files) && files[index].Name == name {
+		files[index].FileSpec = fs
+	} else { // insert, preserving the order
+		files = append(files, NameToFile{})
+		copy(files[index+1:], files[index:])
+		files[index] = NameToFile{Name: name, FileSpec: fs}
+	}
+	doc.Catalog.Names.EmbeddedFiles = files
+	return fs
+}
+
+// AddAssociatedFile embeds `content` as a file associated with the whole document,
+// as required for instance by PDF/A-3 electronic invoices (see also pdfa.EmbedInvoice).
+// The file is attached (see AttachFile) and added to Catalog.AF, replacing an
+// existing associated file with the same name.
+// `relationship` is one of Source, Data, Alternative, Supplement or Unspecified (the default).
+func (doc *Document) AddAssociatedFile(name string, content []byte, mimeType, description string, relationship Name) *FileSpec {
+	if relationship == "" {
+		relationship = "Unspecified"
+	}
+	fs := doc.AttachFile(name, content, mimeType, description)
+	fs.AFRelationship = relationship
+	for i, af := range doc.Catalog.AF {
+		if af != nil && af.UF == name {
+			doc.Catalog.AF[i] = fs
+			return fs
+		}
+	}
+	doc.Catalog.AF = append(doc.Catalog.AF, fs)
+	return fs
+}
+
+// Attachment is a file embedded in a document.
+type Attachment struct {
+	// Name is the key in the embedded files tree, or the file name
+	// for files attached to annotations.
+	Name     string
+	FileSpec *FileSpec
+	Content  []byte // decoded content
+}
+
+// Attachments returns the files embedded in the document,
+// either in the embedded files tree, or by file attachment annotations.
+// File specifications without content are ignored.
+func (doc *Document) Attachments() ([]Attachment, error) {
+	var out []Attachment
+	seen := make(map[*FileSpec]bool)
+	add := func(name string, fs *FileSpec) error {
+		if fs == nil || fs.EF == nil || seen[fs] {
+			return nil
+		}
+		seen[fs] = true
+		content, err := fs.EF.Decode()
+		if err != nil {
+			return fmt.Errorf("invalid embedded file %s: %w", name, err)
+		}
+		out = append(out, Attachment{Name: name, FileSpec: fs, Content: content})
+		return nil
+	}
+	for _, file := range doc.Catalog.Names.EmbeddedFiles {
+		if err := add(file.Name, file.FileSpec); err != nil {
+			return nil, err
+		}
+	}
+	for _, page := range doc.Catalog.Pages.Flatten() {
+		for _, annot := range page.Annots {
+			if fa, ok := annot.Subtype.(AnnotationFileAttachment); ok && fa.FS != nil {
+				if err := add(fa.FS.UF, fa.FS); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return out, nil
+}
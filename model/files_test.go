@@ -0,0 +1,73 @@
+package model
+
+import (
+	"bytes"
+	"compress/zlib"
+	"reflect"
+	"testing"
+)
+
+func TestNewEmbeddedFileStream(t *testing.T) {
+	content := []byte("<invoice></invoice>")
+	for _, level := range []int{zlib.NoCompression, zlib.BestSpeed, zlib.BestCompression} {
+		emb := NewEmbeddedFileStream(content, "text/xml", level)
+		if emb.Params.Size != len(content) || len(emb.Params.CheckSum) != 32 {
+			t.Fatalf("invalid params %v", emb.Params)
+		}
+		decoded, err := emb.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(decoded, content) {
+			t.Fatalf("expected %s, got %s", content, decoded)
+		}
+		if (level == zlib.NoCompression) != (len(emb.Filter) == 0) {
+			t.Fatalf("unexpected filters %v for level %d", emb.Filter, level)
+		}
+	}
+}
+
+func TestEscapeName(t *testing.T) {
+	for name, exp := range map[string]string{
+		"text/xml":      "/text#2Fxml",
+		"application/x": "/application#2Fx",
+		"A B#":          "/A#20B#23",
+		"Plain":         "/Plain",
+	} {
+		if got := escapeName(name); got != exp {
+			t.Errorf("expected %s, got %s", exp, got)
+		}
+	}
+}
+
+func TestAttachFile(t *testing.T) {
+	var doc Document
+	doc.AttachFile("b.txt", []byte("B"), "text/plain", "")
+	doc.AttachFile("c.xml", []byte("<c/>"), "text/xml", "C file")
+	doc.AttachFile("a.txt", []byte("A"), "", "")
+	fs := doc.AttachFile("b.txt", []byte("B2"), "text/plain", "updated") // replace
+
+	if names := doc.Catalog.Names.EmbeddedFiles.names(); !reflect.DeepEqual(names, []string{"a.txt", "b.txt", "c.xml"}) {
+		t.Fatalf("unexpected embedded files %v", names)
+	}
+	if fs.Desc != "updated" || fs.EF.Params.Size != 2 || fs.EF.Params.CreationDate.IsZero() {
+		t.Fatalf("unexpected file spec %v", fs)
+	}
+
+	doc.Catalog.Pages.Kids = []PageNode{&PageObject{Annots: []*AnnotationDict{
+		{Subtype: AnnotationFileAttachment{FS: &FileSpec{UF: "d.txt", EF: NewEmbeddedFileStream([]byte("D"), "", 0)}}},
+		{Subtype: AnnotationFileAttachment{FS: fs}}, // already listed
+	}}}
+
+	attachments, err := doc.Attachments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, att := range attachments {
+		got = append(got, att.Name+":"+string(att.Content))
+	}
+	if exp := []string{"a.txt:A", "b.txt:B2", "c.xml:<c/>", "d.txt:D"}; !reflect.DeepEqual(got, exp) {
+		t.Fatalf("expected %v, got %v", exp, got)
+	}
+}
@@ -162,16 +162,110 @@ func (c UnicodeCMap) pdfString(pdf pdfWriter) string {
 type FontDict struct {
 	Subtype   Font
 	ToUnicode *UnicodeCMap
+
+	// Custom stores the additional entries, such as
+	// private keys, which are written as is.
+	// It is filled by the reader with the unknown entries of the source file.
+	Custom ObjDict // optional
 }
 
-func (f *FontDict) pdfContent(pdf pdfWriter, _ Reference) (StreamHeader, string, []byte) {
+func (f *FontDict) pdfContent(pdf pdfWriter, ref Reference) (StreamHeader, string, []byte) {
 	sub := f.Subtype.fontPDFFields(pdf)
 	if f.ToUnicode != nil {
 		sub += "/ToUnicode " + f.ToUnicode.pdfString(pdf)
 	}
+	if len(f.Custom) != 0 {
+		b := newBuffer()
+		b.custom(pdf, f.Custom, ref)
+		sub += b.String()
+	}
 	return StreamHeader{}, "<<" + sub + ">>", nil
 }
 
+// IsEmbedded returns true if the font program is included in the file,
+// which is always the case for Type3 fonts.
+func (f *FontDict) IsEmbedded() bool {
+	switch ft := f.Subtype.(type) {
+	case FontType1:
+		return ft.FontDescriptor.FontFile != nil
+	case FontTrueType:
+		return ft.FontDescriptor.FontFile != nil
+	case FontType0:
+		return ft.DescendantFonts.FontDescriptor.FontFile != nil
+	case FontType3:
+		return true
+	default:
+		return false
+	}
+}
+
+// Fonts returns the fonts used in the document, without duplicates,
+// in the order they are found: the fonts of the pages (including the ones used
+// by the forms XObjects, the Type3 glyphs and the annotation appearances), followed by
+// the default fonts of the interactive form.
+func (doc *Document) Fonts() []*FontDict {
+	var (
+		out     []*FontDict
+		seen    = make(map[*FontDict]bool)
+		visited = make(map[*XObjectForm]bool)
+		walk    func(res ResourcesDict)
+	)
+	walkForm := func(form *XObjectForm) {
+		if form != nil && !visited[form] {
+			visited[form] = true
+			walk(form.Resources)
+		}
+	}
+	walk = func(res ResourcesDict) {
+		names := make([]string, 0, len(res.Font))
+		for name := range res.Font {
+			names = append(names, string(name))
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			font := res.Font[Name(name)]
+			if font == nil || seen[font] {
+				continue
+			}
+			seen[font] = true
+			out = append(out, font)
+			if t3, ok := font.Subtype.(FontType3); ok {
+				walk(t3.Resources)
+			}
+		}
+		names = names[:0]
+		for name := range res.XObject {
+			names = append(names, string(name))
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			switch xo := res.XObject[Name(name)].(type) {
+			case *XObjectForm:
+				walkForm(xo)
+			case *XObjectTransparencyGroup:
+				walkForm(&xo.XObjectForm)
+			}
+		}
+	}
+	for _, page := range doc.Catalog.Pages.FlattenInherit() {
+		if page.Resources != nil {
+			walk(*page.Resources)
+		}
+		for _, annot := range page.Annots {
+			if annot.AP == nil {
+				continue
+			}
+			for _, entry := range [3]AppearanceEntry{annot.AP.N, annot.AP.R, annot.AP.D} {
+				for _, form := range entry {
+					walkForm(form)
+				}
+			}
+		}
+	}
+	walk(doc.Catalog.AcroForm.DR)
+	return out
+}
+
 // clone returns a deep copy, with concrete type `*Font`
 func (f *FontDict) clone(cache cloneCache) Referenceable {
 	if f == nil {
@@ -182,6 +276,7 @@ func (f *FontDict) clone(cache cloneCache) Referenceable {
 		out.Subtype = f.Subtype.clone(cache)
 	}
 	out.ToUnicode = f.ToUnicode.Clone()
+	out.Custom = cloneCustom(f.Custom)
 	return &out
 }
 
@@ -308,8 +403,12 @@ func (f FontType3) fontPDFFields(pdf pdfWriter) string {
 	b.line("/Type/Font/Subtype/Type3/FontBBox %s/FontMatrix %s",
 		f.FontBBox.String(), f.FontMatrix.String())
 	chunks := make([]string, 0, len(f.CharProcs))
-	for name, stream := range f.CharProcs {
-		ref := pdf.addStream(stream.PDFContent())
+	names := make([]Name, 0, len(f.CharProcs))
+	for name := range f.CharProcs {
+		names = append(names, name)
+	}
+	for _, name := range sortNames(names) {
+		ref := pdf.addStream(f.CharProcs[name].PDFContent())
 		chunks = append(chunks, fmt.Sprintf("%s %s", name, ref))
 	}
 	b.line("/CharProcs <<%s>>", strings.Join(chunks, ""))
@@ -44,3 +44,33 @@ func TestCloneFont(t *testing.T) {
 		}
 	}
 }
+
+func TestDocumentFonts(t *testing.T) {
+	embedded := &FontDict{Subtype: FontTrueType{BaseFont: "ABCDEF+Arial", FontDescriptor: FontDescriptor{FontFile: &FontFile{}}}}
+	standard := &FontDict{Subtype: FontType1{BaseFont: "Helvetica"}}
+	glyph := &FontDict{Subtype: FontType1{BaseFont: "Symbol"}}
+	type3 := &FontDict{Subtype: FontType3{Resources: ResourcesDict{Font: map[Name]*FontDict{"G": glyph}}}}
+	form := &XObjectForm{Resources: ResourcesDict{Font: map[Name]*FontDict{"F1": standard, "F2": type3}}}
+	appearance := &XObjectForm{Resources: ResourcesDict{Font: map[Name]*FontDict{"F": embedded}}}
+
+	var doc Document
+	doc.Catalog.Pages.Resources = &ResourcesDict{
+		Font:    map[Name]*FontDict{"F1": embedded},
+		XObject: map[Name]XObject{"Fm": form},
+	}
+	doc.Catalog.Pages.Kids = []PageNode{
+		&PageObject{Annots: []*AnnotationDict{{BaseAnnotation: BaseAnnotation{AP: &AppearanceDict{N: AppearanceEntry{"": appearance}}}}}},
+		&PageObject{},
+	}
+	doc.Catalog.AcroForm.DR.Font = map[Name]*FontDict{"Helv": standard, "ZaDb": {Subtype: FontType1{BaseFont: "ZapfDingbats"}}}
+
+	fonts := doc.Fonts()
+	if len(fonts) != 5 || fonts[0] != embedded || fonts[1] != standard || fonts[2] != type3 || fonts[3] != glyph {
+		t.Fatalf("unexpected fonts %v", fonts)
+	}
+	for _, font := range fonts {
+		if exp := font == embedded || font == type3; font.IsEmbedded() != exp {
+			t.Fatalf("unexpected embedding for %v", font.Subtype.FontName())
+		}
+	}
+}
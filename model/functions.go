@@ -218,16 +218,17 @@ func (m Matrix) String() string {
 	return writeFloatArray(m[:])
 }
 
-// Multiply returns the product m * m2
+// Multiply returns the product m * m2, that is the transformation
+// applying `m` first, then `m2`.
 func (m Matrix) Multiply(m2 Matrix) Matrix {
 	a, b, c, d, e, f := m[0], m[1], m[2], m[3], m[4], m[5]
 	a2, b2, c2, d2, e2, f2 := m2[0], m2[1], m2[2], m2[3], m2[4], m2[5]
 	var out Matrix
-	out[0] = a*a2 + c*b2
-	out[1] = b*a2 + d*b2
-	out[2] = a*c2 + c*d2
-	out[3] = b*c2 + d*d2
-	out[4] = e*a2 + f*b2 + e2
-	out[5] = e*c2 + f*d2 + f2
+	out[0] = a*a2 + b*c2
+	out[1] = a*b2 + b*d2
+	out[2] = c*a2 + d*c2
+	out[3] = c*b2 + d*d2
+	out[4] = e*a2 + f*c2 + e2
+	out[5] = e*b2 + f*d2 + f2
 	return out
 }
@@ -0,0 +1,210 @@
+package model
+
+// Normalize returns the rectangle with Llx <= Urx and Lly <= Ury
+func (r Rectangle) Normalize() Rectangle {
+	if r.Llx > r.Urx {
+		r.Llx, r.Urx = r.Urx, r.Llx
+	}
+	if r.Lly > r.Ury {
+		r.Lly, r.Ury = r.Ury, r.Lly
+	}
+	return r
+}
+
+// Union returns the smallest (normalized) rectangle containing `r` and `other`.
+func (r Rectangle) Union(other Rectangle) Rectangle {
+	r, other = r.Normalize(), other.Normalize()
+	r.Llx, r.Lly = minFl(r.Llx, other.Llx), minFl(r.Lly, other.Lly)
+	r.Urx, r.Ury = maxFl(r.Urx, other.Urx), maxFl(r.Ury, other.Ury)
+	return r
+}
+
+// Intersect returns the (normalized) intersection of `r` and `other`,
+// or false if they do not overlap.
+// Rectangles only sharing an edge are considered as overlapping.
+func (r Rectangle) Intersect(other Rectangle) (Rectangle, bool) {
+	r, other = r.Normalize(), other.Normalize()
+	r.Llx, r.Lly = maxFl(r.Llx, other.Llx), maxFl(r.Lly, other.Lly)
+	r.Urx, r.Ury = minFl(r.Urx, other.Urx), minFl(r.Ury, other.Ury)
+	if r.Llx > r.Urx || r.Lly > r.Ury {
+		return Rectangle{}, false
+	}
+	return r, true
+}
+
+// Contains returns true if the point (x, y) is inside `r`,
+// boundary included.
+func (r Rectangle) Contains(x, y Fl) bool {
+	r = r.Normalize()
+	return r.Llx <= x && x <= r.Urx && r.Lly <= y && y <= r.Ury
+}
+
+// Transform returns the bounding box of the image of `r` by `m`.
+func (r Rectangle) Transform(m Matrix) Rectangle {
+	return m.TransformRect(r)
+}
+
+// Quad returns the quadrilateral with the corners of `r`,
+// in the order used by the QuadPoints arrays.
+func (r Rectangle) Quad() Quad {
+	return Quad{r.Llx, r.Ury, r.Urx, r.Ury, r.Llx, r.Lly, r.Urx, r.Lly}
+}
+
+// Quad is a quadrilateral, given by the (x, y) coordinates of
+// its four points, as found in QuadPoints arrays.
+// Since the order of the points is not consistent among PDF writers,
+// the quadrilateral is interpreted as the convex hull of its points.
+type Quad [8]Fl
+
+// Quads splits a QuadPoints array, ignoring the trailing values.
+func Quads(points []Fl) []Quad {
+	out := make([]Quad, len(points)/8)
+	for i := range out {
+		copy(out[i][:], points[8*i:])
+	}
+	return out
+}
+
+// Bounds returns the bounding box of the quadrilateral.
+func (q Quad) Bounds() Rectangle {
+	box := Rectangle{Llx: q[0], Lly: q[1], Urx: q[0], Ury: q[1]}
+	for j := 2; j < 8; j += 2 {
+		box.Llx, box.Urx = minFl(box.Llx, q[j]), maxFl(box.Urx, q[j])
+		box.Lly, box.Ury = minFl(box.Lly, q[j+1]), maxFl(box.Ury, q[j+1])
+	}
+	return box
+}
+
+// Contains returns true if the point (x, y) is inside the
+// convex hull of `q`, boundary included.
+func (q Quad) Contains(x, y Fl) bool {
+	// the convex hull is covered by the triangles
+	// formed by any three of the points
+	for _, t := range [4][3]int{{0, 1, 2}, {0, 1, 3}, {0, 2, 3}, {1, 2, 3}} {
+		if inTriangle(x, y, q[2*t[0]], q[2*t[0]+1], q[2*t[1]], q[2*t[1]+1], q[2*t[2]], q[2*t[2]+1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Transform returns the image of `q` by `m`.
+func (q Quad) Transform(m Matrix) Quad {
+	var out Quad
+	for i := 0; i < 8; i += 2 {
+		out[i], out[i+1] = m.TransformPoint(q[i], q[i+1])
+	}
+	return out
+}
+
+// cross returns the z component of (b - a) x (p - a)
+func cross(px, py, ax, ay, bx, by Fl) Fl {
+	return (bx-ax)*(py-ay) - (by-ay)*(px-ax)
+}
+
+// inTriangle returns true if p is inside the (possibly degenerate) triangle abc
+func inTriangle(px, py, ax, ay, bx, by, cx, cy Fl) bool {
+	d1, d2, d3 := cross(px, py, ax, ay, bx, by), cross(px, py, bx, by, cx, cy), cross(px, py, cx, cy, ax, ay)
+	hasNeg := d1 < 0 || d2 < 0 || d3 < 0
+	hasPos := d1 > 0 || d2 > 0 || d3 > 0
+	if hasNeg && hasPos {
+		return false
+	}
+	if hasNeg || hasPos {
+		return true
+	}
+	// degenerate triangle: check the bounding box
+	return minFl(ax, minFl(bx, cx)) <= px && px <= maxFl(ax, maxFl(bx, cx)) &&
+		minFl(ay, minFl(by, cy)) <= py && py <= maxFl(ay, maxFl(by, cy))
+}
+
+// quadPoints returns the QuadPoints of the annotations defining them
+func quadPoints(annot Annotation) []Fl {
+	switch annot := annot.(type) {
+	case AnnotationLink:
+		return annot.QuadPoints
+	case AnnotationRedact:
+		return annot.QuadPoints
+	case AnnotationHighlight:
+		return annot.QuadPoints
+	case AnnotationUnderline:
+		return annot.QuadPoints
+	case AnnotationSquiggly:
+		return annot.QuadPoints
+	case AnnotationStrikeOut:
+		return annot.QuadPoints
+	default:
+		return nil
+	}
+}
+
+// Contains returns true if the point (x, y), in default user space,
+// is inside the area covered by the annotation: its QuadPoints
+// for the annotations defining them, or its Rect.
+func (a *AnnotationDict) Contains(x, y Fl) bool {
+	if !a.Rect.Contains(x, y) {
+		return false
+	}
+	quads := Quads(quadPoints(a.Subtype))
+	if len(quads) == 0 {
+		return true
+	}
+	for _, quad := range quads {
+		if quad.Contains(x, y) {
+			return true
+		}
+	}
+	return false
+}
+
+// AnnotationsAt returns the annotations of the page containing
+// the point (x, y) (see AnnotationDict.Contains), the top-most first.
+// The hidden annotations are ignored.
+func (p *PageObject) AnnotationsAt(x, y Fl) []*AnnotationDict {
+	var out []*AnnotationDict
+	for i := len(p.Annots) - 1; i >= 0; i-- {
+		annot := p.Annots[i]
+		if annot == nil || annot.F&AHidden != 0 {
+			continue
+		}
+		if annot.Contains(x, y) {
+			out = append(out, annot)
+		}
+	}
+	return out
+}
+
+// WidgetsAt is the same as AnnotationsAt, but only returns
+// the widget annotations.
+func (p *PageObject) WidgetsAt(x, y Fl) []*AnnotationDict {
+	var out []*AnnotationDict
+	for _, annot := range p.AnnotationsAt(x, y) {
+		if _, isWidget := annot.Subtype.(AnnotationWidget); isWidget {
+			out = append(out, annot)
+		}
+	}
+	return out
+}
+
+// FieldsAt returns the fully qualified names of the fields
+// with a widget on `page` containing the point (x, y),
+// the top-most first (see PageObject.WidgetsAt).
+func (a AcroForm) FieldsAt(page *PageObject, x, y Fl) []string {
+	widgets := page.WidgetsAt(x, y)
+	if len(widgets) == 0 {
+		return nil
+	}
+	fields := make(map[*AnnotationDict]string)
+	for name, field := range a.Flatten() {
+		for _, widget := range field.Field.Widgets {
+			fields[widget.AnnotationDict] = name
+		}
+	}
+	var out []string
+	for _, widget := range widgets {
+		if name, ok := fields[widget]; ok {
+			out = append(out, name)
+		}
+	}
+	return out
+}
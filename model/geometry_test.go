@@ -0,0 +1,86 @@
+package model
+
+import "testing"
+
+func TestRectangleGeometry(t *testing.T) {
+	r1 := Rectangle{Llx: 10, Lly: 10, Urx: 0, Ury: 0}
+	r2 := Rectangle{Llx: 5, Lly: 5, Urx: 20, Ury: 15}
+	if n := r1.Normalize(); n != (Rectangle{0, 0, 10, 10}) {
+		t.Fatalf("unexpected normalized rectangle %v", n)
+	}
+	if u := r1.Union(r2); u != (Rectangle{0, 0, 20, 15}) {
+		t.Fatalf("unexpected union %v", u)
+	}
+	if i, ok := r1.Intersect(r2); !ok || i != (Rectangle{5, 5, 10, 10}) {
+		t.Fatalf("unexpected intersection %v", i)
+	}
+	if _, ok := r1.Intersect(Rectangle{Llx: 11, Lly: 0, Urx: 12, Ury: 1}); ok {
+		t.Fatal("expected no intersection")
+	}
+	if !r1.Contains(10, 0) || r1.Contains(-1, 5) {
+		t.Fatal("invalid Contains")
+	}
+	if tr := r2.Transform(Matrix{0, 1, -1, 0, 0, 0}); tr != (Rectangle{-15, 5, -5, 20}) {
+		t.Fatalf("unexpected transformed rectangle %v", tr)
+	}
+}
+
+func TestQuad(t *testing.T) {
+	quads := Quads([]Fl{0, 10, 10, 10, 0, 0, 10, 0, 1, 2}) // trailing values are ignored
+	if len(quads) != 1 {
+		t.Fatalf("expected 1 quad, got %d", len(quads))
+	}
+	q := quads[0]
+	if q != (Rectangle{0, 0, 10, 10}).Quad() || q.Bounds() != (Rectangle{0, 0, 10, 10}) {
+		t.Fatalf("unexpected quad %v", q)
+	}
+	if !q.Contains(5, 5) || !q.Contains(0, 0) || q.Contains(11, 5) {
+		t.Fatal("invalid Contains")
+	}
+
+	// a rotated square (diamond), with points in cyclic order
+	diamond := Quad{5, 0, 10, 5, 5, 10, 0, 5}
+	if !diamond.Contains(5, 5) || diamond.Contains(1, 1) || diamond.Contains(9, 9) {
+		t.Fatal("invalid Contains for diamond")
+	}
+	if moved := q.Transform(Matrix{1, 0, 0, 1, 100, 0}); moved.Bounds() != (Rectangle{100, 0, 110, 10}) {
+		t.Fatalf("unexpected transformed quad %v", moved)
+	}
+}
+
+func TestAnnotationsAt(t *testing.T) {
+	square := &AnnotationDict{BaseAnnotation: BaseAnnotation{Rect: Rectangle{0, 0, 100, 100}}, Subtype: AnnotationSquare{}}
+	// the highlight only covers the top-left part of its Rect
+	quad := Rectangle{0, 50, 50, 100}.Quad()
+	highlight := &AnnotationDict{
+		BaseAnnotation: BaseAnnotation{Rect: Rectangle{0, 0, 100, 100}},
+		Subtype:        AnnotationHighlight{QuadPoints: quad[:]},
+	}
+	hidden := &AnnotationDict{BaseAnnotation: BaseAnnotation{Rect: Rectangle{0, 0, 100, 100}, F: AHidden}, Subtype: AnnotationSquare{}}
+	widget := &AnnotationDict{BaseAnnotation: BaseAnnotation{Rect: Rectangle{10, 10, 20, 20}}, Subtype: AnnotationWidget{}}
+	page := &PageObject{Annots: []*AnnotationDict{square, highlight, hidden, widget}}
+
+	if annots := page.AnnotationsAt(25, 75); len(annots) != 2 || annots[0] != highlight || annots[1] != square {
+		t.Fatalf("unexpected annotations %v", annots)
+	}
+	if annots := page.AnnotationsAt(75, 25); len(annots) != 1 || annots[0] != square {
+		t.Fatalf("unexpected annotations %v", annots)
+	}
+	if annots := page.AnnotationsAt(200, 200); len(annots) != 0 {
+		t.Fatalf("unexpected annotations %v", annots)
+	}
+	if widgets := page.WidgetsAt(15, 15); len(widgets) != 1 || widgets[0] != widget {
+		t.Fatalf("unexpected widgets %v", widgets)
+	}
+
+	field := &FormFieldDict{T: "name", Widgets: []FormFieldWidget{{AnnotationDict: widget}}}
+	parent := &FormFieldDict{T: "root", Kids: []*FormFieldDict{field}}
+	field.Parent = parent
+	form := AcroForm{Fields: []*FormFieldDict{parent}}
+	if names := form.FieldsAt(page, 15, 15); len(names) != 1 || names[0] != "root.name" {
+		t.Fatalf("unexpected fields %v", names)
+	}
+	if names := form.FieldsAt(page, 50, 50); len(names) != 0 {
+		t.Fatalf("unexpected fields %v", names)
+	}
+}
@@ -0,0 +1,122 @@
+package model
+
+// GradientStop defines the color of a gradient
+// at a given position.
+type GradientStop struct {
+	Offset Fl    // position along the gradient, between 0 and 1
+	Color  Color // with 1 (gray), 3 (RGB) or 4 (CMYK) components
+}
+
+// NewAxialGradient returns a shading blending the colors of `stops`
+// along the axis going from `from` to `to` (given as (x, y) points), which correspond
+// to the offsets 0 and 1. The colors are extended beyond the end points.
+// The stops must be sorted by offset, and must use the same number of
+// color components, which defines the color space (DeviceGray, DeviceRGB or DeviceCMYK).
+// Each pair of consecutive stops is linearly interpolated, using one exponential
+// function, stitched together when there are more than two stops.
+// Setting two stops at the same offset creates a sharp color transition.
+func NewAxialGradient(stops []GradientStop, from, to [2]Fl) (*ShadingDict, error) {
+	gradient, cs, err := newBaseGradient(stops)
+	if err != nil {
+		return nil, err
+	}
+	return &ShadingDict{
+		ColorSpace: cs,
+		ShadingType: ShadingAxial{
+			BaseGradient: gradient,
+			Coords:       [4]Fl{from[0], from[1], to[0], to[1]},
+		},
+	}, nil
+}
+
+// NewRadialGradient returns a shading blending the colors of `stops`
+// from the circle with center `from` and radius `fromRadius` (offset 0)
+// to the circle with center `to` and radius `toRadius` (offset 1).
+// See NewAxialGradient for the requirements on `stops`.
+func NewRadialGradient(stops []GradientStop, from [2]Fl, fromRadius Fl, to [2]Fl, toRadius Fl) (*ShadingDict, error) {
+	if fromRadius < 0 || toRadius < 0 {
+		return nil, newError(ErrInvalidArgument, "negative gradient radius")
+	}
+	gradient, cs, err := newBaseGradient(stops)
+	if err != nil {
+		return nil, err
+	}
+	return &ShadingDict{
+		ColorSpace: cs,
+		ShadingType: ShadingRadial{
+			BaseGradient: gradient,
+			Coords:       [6]Fl{from[0], from[1], fromRadius, to[0], to[1], toRadius},
+		},
+	}, nil
+}
+
+// newBaseGradient builds the color function of the gradient,
+// defined on [0, 1] and extended.
+func newBaseGradient(stops []GradientStop) (BaseGradient, ColorSpaceName, error) {
+	if len(stops) == 0 {
+		return BaseGradient{}, "", newError(ErrInvalidArgument, "missing gradient stops")
+	}
+	var cs ColorSpaceName
+	switch len(stops[0].Color) {
+	case 1:
+		cs = ColorSpaceGray
+	case 3:
+		cs = ColorSpaceRGB
+	case 4:
+		cs = ColorSpaceCMYK
+	default:
+		return BaseGradient{}, "", newError(ErrInvalidArgument, "invalid number of color components %d", len(stops[0].Color))
+	}
+	for i, stop := range stops {
+		if len(stop.Color) != len(stops[0].Color) {
+			return BaseGradient{}, "", newError(ErrInvalidArgument, "inconsistent number of color components for stop %d", i)
+		}
+		if stop.Offset < 0 || stop.Offset > 1 {
+			return BaseGradient{}, "", newError(ErrInvalidArgument, "invalid offset %g for stop %d", stop.Offset, i)
+		}
+		if i > 0 && stop.Offset < stops[i-1].Offset {
+			return BaseGradient{}, "", newError(ErrInvalidArgument, "unsorted offset %g for stop %d", stop.Offset, i)
+		}
+	}
+
+	// make sure the whole [0, 1] domain is covered
+	if first := stops[0]; first.Offset != 0 {
+		stops = append([]GradientStop{{Offset: 0, Color: first.Color}}, stops...)
+	}
+	if last := stops[len(stops)-1]; last.Offset != 1 || len(stops) == 1 {
+		stops = append(stops, GradientStop{Offset: 1, Color: last.Color})
+	}
+
+	functions := make([]FunctionDict, len(stops)-1)
+	for i := range functions {
+		functions[i] = FunctionDict{
+			Domain: []Range{{0, 1}},
+			FunctionType: FunctionExpInterpolation{
+				C0: append([]Fl(nil), stops[i].Color...),
+				C1: append([]Fl(nil), stops[i+1].Color...),
+				N:  1, // linear
+			},
+		}
+	}
+
+	fn := functions[0]
+	if len(functions) > 1 {
+		bounds := make([]Fl, len(stops)-2)
+		for i := range bounds {
+			bounds[i] = stops[i+1].Offset
+		}
+		fn = FunctionDict{
+			Domain: []Range{{0, 1}},
+			FunctionType: FunctionStitching{
+				Functions: functions,
+				Bounds:    bounds,
+				Encode:    FunctionEncodeRepeat(len(functions)),
+			},
+		}
+	}
+
+	return BaseGradient{
+		Function: []FunctionDict{fn},
+		Extend:   [2]bool{true, true},
+	}, cs, nil
+}
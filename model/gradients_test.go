@@ -0,0 +1,98 @@
+package model
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestNewAxialGradient(t *testing.T) {
+	red, green, blue := Color{1, 0, 0}, Color{0, 1, 0}, Color{0, 0, 1}
+
+	sh, err := NewAxialGradient([]GradientStop{{0, red}, {1, blue}}, [2]Fl{0, 0}, [2]Fl{100, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sh.ColorSpace != ColorSpaceRGB {
+		t.Fatalf("unexpected color space %v", sh.ColorSpace)
+	}
+	axial := sh.ShadingType.(ShadingAxial)
+	if axial.Coords != [4]Fl{0, 0, 100, 0} || axial.Extend != [2]bool{true, true} {
+		t.Fatalf("unexpected shading %v", axial)
+	}
+	exp := FunctionExpInterpolation{C0: red, C1: blue, N: 1}
+	if got := axial.Function[0].FunctionType; !reflect.DeepEqual(got, exp) {
+		t.Fatalf("expected %v, got %v", exp, got)
+	}
+
+	// the stops are padded to cover [0, 1]
+	sh, err = NewAxialGradient([]GradientStop{{0.2, red}, {0.5, green}, {0.5, blue}}, [2]Fl{0, 0}, [2]Fl{0, 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := sh.ShadingType.(ShadingAxial).Function[0].FunctionType.(FunctionStitching)
+	if len(st.Functions) != 4 || len(st.Encode) != 4 {
+		t.Fatalf("unexpected stitching function %v", st)
+	}
+	if !reflect.DeepEqual(st.Bounds, []Fl{0.2, 0.5, 0.5}) {
+		t.Fatalf("unexpected bounds %v", st.Bounds)
+	}
+	if first := st.Functions[0].FunctionType.(FunctionExpInterpolation); !reflect.DeepEqual(first.C0, first.C1) {
+		t.Fatalf("expected constant padding, got %v", first)
+	}
+
+	// a single stop is a solid color
+	sh, err = NewAxialGradient([]GradientStop{{0, Color{0.5}}}, [2]Fl{0, 0}, [2]Fl{0, 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sh.ColorSpace != ColorSpaceGray {
+		t.Fatalf("unexpected color space %v", sh.ColorSpace)
+	}
+	if _, ok := sh.ShadingType.(ShadingAxial).Function[0].FunctionType.(FunctionExpInterpolation); !ok {
+		t.Fatal("expected an exponential function")
+	}
+}
+
+func TestNewRadialGradient(t *testing.T) {
+	stops := []GradientStop{{0, Color{0, 0, 0, 1}}, {0.3, Color{1, 0, 0, 0}}, {1, Color{0, 1, 0, 0}}}
+	sh, err := NewRadialGradient(stops, [2]Fl{50, 50}, 0, [2]Fl{50, 50}, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	radial := sh.ShadingType.(ShadingRadial)
+	if sh.ColorSpace != ColorSpaceCMYK || radial.Coords != [6]Fl{50, 50, 0, 50, 50, 40} {
+		t.Fatalf("unexpected shading %v", sh)
+	}
+	if st := radial.Function[0].FunctionType.(FunctionStitching); len(st.Functions) != 2 {
+		t.Fatalf("unexpected stitching function %v", st)
+	}
+	// the output is valid
+	var doc Document
+	doc.Catalog.Pages.Kids = []PageNode{&PageObject{
+		Resources: &ResourcesDict{Shading: map[Name]*ShadingDict{"Sh": sh}},
+	}}
+	if err = doc.Write(io.Discard, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGradientErrors(t *testing.T) {
+	red, blue := Color{1, 0, 0}, Color{0, 0, 1}
+	for _, stops := range [][]GradientStop{
+		nil,
+		{{0, Color{1, 0}}},
+		{{0, red}, {1, Color{1}}},
+		{{-0.5, red}, {1, blue}},
+		{{0, red}, {1.5, blue}},
+		{{0.8, red}, {0.2, blue}},
+	} {
+		if _, err := NewAxialGradient(stops, [2]Fl{}, [2]Fl{1, 1}); !errors.Is(err, ErrInvalidArgument) {
+			t.Fatalf("expected invalid argument error for %v, got %v", stops, err)
+		}
+	}
+	if _, err := NewRadialGradient([]GradientStop{{0, red}}, [2]Fl{}, -1, [2]Fl{}, 1); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("expected invalid argument error, got %v", err)
+	}
+}
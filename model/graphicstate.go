@@ -3,6 +3,7 @@ package model
 import (
 	"fmt"
 	"strconv"
+	"strings"
 )
 
 type DashPattern struct {
@@ -145,14 +146,224 @@ func (s SoftMaskDict) clone(cache cloneCache) SoftMaskDict {
 	return out
 }
 
+// Soft mask subtypes, used as SoftMaskDict.S
+const (
+	// the mask values are derived from the luminosity
+	// of the group: white is opaque, black is transparent
+	SoftMaskLuminosity Name = "Luminosity"
+	// the mask values are derived from the opacity of the group
+	SoftMaskAlpha Name = "Alpha"
+)
+
+// NewSoftMask returns a graphic state which, once set,
+// applies `mask` as soft mask to the subsequent painting operations,
+// which is useful for fading effects.
+// `subtype` is either SoftMaskLuminosity or SoftMaskAlpha.
+// For luminosity masks, the form is composited as a DeviceGray
+// transparency group on a black backdrop, so that the area
+// outside of the form is fully transparent.
+// The form is expressed in the user space in effect when the state is set.
+func NewSoftMask(mask *XObjectForm, subtype Name) (*GraphicState, error) {
+	if mask == nil {
+		return nil, newError(ErrInvalidArgument, "missing soft mask form")
+	}
+	group := XObjectTransparencyGroup{XObjectForm: *mask}
+	switch subtype {
+	case SoftMaskLuminosity:
+		group.Group.CS = ColorSpaceGray
+	case SoftMaskAlpha:
+	default:
+		return nil, newError(ErrInvalidArgument, "invalid soft mask subtype %s", subtype)
+	}
+	return &GraphicState{SMask: SoftMaskDict{S: subtype, G: &group}}, nil
+}
+
+// NewImageSoftMask is the same as NewSoftMask, but uses `img`,
+// drawn in `rect` (expressed in user space), as the content of the mask.
+// A grayscale image should be used for luminosity masks.
+func NewImageSoftMask(img *XObjectImage, rect Rectangle, subtype Name) (*GraphicState, error) {
+	if img == nil {
+		return nil, newError(ErrInvalidArgument, "missing soft mask image")
+	}
+	content := []byte(fmt.Sprintf("q %s 0 0 %s %s %s cm /Im Do Q", FmtFloat(rect.Width()), FmtFloat(rect.Height()),
+		FmtFloat(rect.Llx), FmtFloat(rect.Lly)))
+	form := XObjectForm{
+		ContentStream: ContentStream{Stream: Stream{Content: content}},
+		BBox:          rect,
+		Resources:     ResourcesDict{XObject: map[Name]XObject{"Im": img}},
+	}
+	return NewSoftMask(&form, subtype)
+}
+
+// GraphicStateFunction is either a name (like /Identity or /Default)
+// or one or four functions (one per colorant), as found in
+// graphics state and halftone dictionaries.
+// The zero value means 'not specified'.
+type GraphicStateFunction struct {
+	Name      Name            // if not empty, Functions is ignored
+	Functions []*FunctionDict // 1 function, or 4 for transfer functions
+}
+
+func (f GraphicStateFunction) isSet() bool {
+	return f.Name != "" || len(f.Functions) != 0
+}
+
+func (f GraphicStateFunction) pdfString(pdf pdfWriter) string {
+	if f.Name != "" {
+		return f.Name.String()
+	}
+	refs := make([]string, len(f.Functions))
+	for i, fn := range f.Functions {
+		refs[i] = pdf.addItem(fn).String()
+	}
+	if len(refs) == 1 {
+		return refs[0]
+	}
+	return "[" + strings.Join(refs, " ") + "]"
+}
+
+func (f GraphicStateFunction) clone(cache cloneCache) GraphicStateFunction {
+	out := f
+	if f.Functions != nil {
+		out.Functions = make([]*FunctionDict, len(f.Functions))
+		for i, fn := range f.Functions {
+			out.Functions[i] = cache.checkOrClone(fn).(*FunctionDict)
+		}
+	}
+	return out
+}
+
+// HalftoneType identifies the kind of a halftone.
+// See Table 129 – Types of halftones
+type HalftoneType uint8
+
+const (
+	HalftoneDefault     HalftoneType = 0  // the /Default name, not a dictionary
+	HalftoneScreen      HalftoneType = 1  // frequency, angle and spot function
+	HalftoneComposite   HalftoneType = 5  // one halftone per colorant
+	HalftoneThreshold   HalftoneType = 6  // threshold array, 8-bit
+	HalftoneTwoSquares  HalftoneType = 10 // threshold array, defined by two squares
+	HalftoneThreshold16 HalftoneType = 16 // threshold array, 16-bit, one or two rectangles
+)
+
+// IsIdentity returns true if `f` is the /Identity name.
+func (f GraphicStateFunction) IsIdentity() bool { return f.Name == "Identity" }
+
+// HalftoneDict defines a halftone screen.
+// It is either a dictionary (types 1 and 5) or
+// a stream (types 6, 10 and 16, defining a threshold array).
+// See 10.5 - Halftones
+type HalftoneDict struct {
+	// HalftoneDefault is used for the /Default name,
+	// in which case the other fields are ignored
+	HalftoneType HalftoneType
+	HalftoneName string // optional, byte string
+
+	// for type 1, 6, 10 and 16, optional
+	TransferFunction GraphicStateFunction
+
+	// type 1
+	Frequency       Fl
+	Angle           Fl
+	SpotFunction    GraphicStateFunction // predefined name or function
+	AccurateScreens bool                 // optional
+
+	// type 5: the halftones for each colorant, including the required Default entry
+	Colorants map[Name]*HalftoneDict
+
+	// threshold array, for types 6, 10 and 16
+	Stream
+	Width, Height   int // types 6 and 16
+	Width2, Height2 int // type 16, optional
+	Xsquare         int // type 10
+	Ysquare         int // type 10
+}
+
+// isThreshold returns true for the halftones written as a stream
+func (h *HalftoneDict) isThreshold() bool {
+	return h.HalftoneType == HalftoneThreshold || h.HalftoneType == HalftoneTwoSquares || h.HalftoneType == HalftoneThreshold16
+}
+
+// Colorant returns the halftone used for the given colorant:
+// for composite halftones, it is the colorant entry or the Default one
+// (which may be nil for invalid halftones); otherwise it is `h`.
+func (h *HalftoneDict) Colorant(colorant Name) *HalftoneDict {
+	if h.HalftoneType != HalftoneComposite {
+		return h
+	}
+	if c := h.Colorants[colorant]; c != nil {
+		return c
+	}
+	return h.Colorants["Default"]
+}
+
+func (h *HalftoneDict) pdfContent(pdf pdfWriter, ref Reference) (StreamHeader, string, []byte) {
+	fields := map[Name]string{
+		"Type":         "/Halftone",
+		"HalftoneType": strconv.Itoa(int(h.HalftoneType)),
+	}
+	if h.HalftoneName != "" {
+		fields["HalftoneName"] = pdf.EncodeString(h.HalftoneName, ByteString, ref)
+	}
+	if h.TransferFunction.isSet() && h.HalftoneType != HalftoneComposite {
+		fields["TransferFunction"] = h.TransferFunction.pdfString(pdf)
+	}
+	switch h.HalftoneType {
+	case HalftoneScreen:
+		fields["Frequency"] = FmtFloat(h.Frequency)
+		fields["Angle"] = FmtFloat(h.Angle)
+		if h.SpotFunction.isSet() {
+			fields["SpotFunction"] = h.SpotFunction.pdfString(pdf)
+		}
+		if h.AccurateScreens {
+			fields["AccurateScreens"] = "true"
+		}
+	case HalftoneComposite:
+		for name, colorant := range h.Colorants {
+			if colorant != nil {
+				fields[name] = pdf.addItem(colorant).String()
+			}
+		}
+	case HalftoneThreshold, HalftoneThreshold16:
+		fields["Width"] = strconv.Itoa(h.Width)
+		fields["Height"] = strconv.Itoa(h.Height)
+		if h.HalftoneType == HalftoneThreshold16 && h.Width2 != 0 {
+			fields["Width2"] = strconv.Itoa(h.Width2)
+			fields["Height2"] = strconv.Itoa(h.Height2)
+		}
+	case HalftoneTwoSquares:
+		fields["Xsquare"] = strconv.Itoa(h.Xsquare)
+		fields["Ysquare"] = strconv.Itoa(h.Ysquare)
+	}
+
+	if h.isThreshold() {
+		header := h.Stream.PDFCommonFields(true)
+		header.updateWith(fields)
+		return header, "", h.Content
+	}
+	header := StreamHeader{Fields: fields}
+	return StreamHeader{}, string(header.PDFContent()), nil
+}
+
+func (h *HalftoneDict) clone(cache cloneCache) Referenceable {
+	if h == nil {
+		return h
+	}
+	out := *h
+	out.TransferFunction = h.TransferFunction.clone(cache)
+	out.SpotFunction = h.SpotFunction.clone(cache)
+	if h.Colorants != nil {
+		out.Colorants = make(map[Name]*HalftoneDict, len(h.Colorants))
+		for name, colorant := range h.Colorants {
+			out.Colorants[name] = cache.checkOrClone(colorant).(*HalftoneDict)
+		}
+	}
+	out.Stream = h.Stream.Clone()
+	return &out
+}
+
 // GraphicState precises parameters in the graphics state.
 // See Table 58 – Entries in a Graphics State Parameter Dictionary
-// TODO: The following entries are not yet supported:
-//   - OP, op, OPM
-//   - BG, BG2, UCR, UCR2, TR, TR2
-//   - HT
-//   - FL
-//   - TK
 type GraphicState struct {
 	LW   Fl
 	LC   MaybeInt // optional, >= 0
@@ -171,6 +382,22 @@ type GraphicState struct {
 	CA    MaybeFloat   // stroking, optional, >= 0
 	Ca    MaybeFloat   // non-stroking, optional, >= 0
 	AIS   bool
+
+	// Overprint control
+	OP  MaybeBool // stroking, optional
+	Op  MaybeBool // non-stroking, optional, default to OP (written as /op)
+	OPM MaybeInt  // optional, 0 or 1
+
+	// Color conversion and device control
+	BG   GraphicStateFunction // black generation, optional
+	BG2  GraphicStateFunction // optional, function or /Default
+	UCR  GraphicStateFunction // undercolor removal, optional
+	UCR2 GraphicStateFunction // optional, function or /Default
+	TR   GraphicStateFunction // transfer function, optional, 1 or 4 functions or /Identity
+	TR2  GraphicStateFunction // optional, as TR or /Default
+	HT   *HalftoneDict        // optional
+	FL   MaybeFloat           // flatness tolerance, optional
+	TK   MaybeBool            // text knockout, optional
 }
 
 func (g *GraphicState) pdfContent(pdf pdfWriter, _ Reference) (StreamHeader, string, []byte) {
@@ -220,6 +447,36 @@ func (g *GraphicState) pdfContent(pdf pdfWriter, _ Reference) (StreamHeader, str
 	if g.AIS {
 		b.fmt("/AIS %v", g.AIS)
 	}
+	if g.OP != nil {
+		b.fmt("/OP %v", g.OP.(ObjBool))
+	}
+	if g.Op != nil {
+		b.fmt("/op %v", g.Op.(ObjBool))
+	}
+	if g.OPM != nil {
+		b.fmt("/OPM %d", g.OPM.(ObjInt))
+	}
+	for _, entry := range [...]struct {
+		key Name
+		fn  GraphicStateFunction
+	}{{"BG", g.BG}, {"BG2", g.BG2}, {"UCR", g.UCR}, {"UCR2", g.UCR2}, {"TR", g.TR}, {"TR2", g.TR2}} {
+		if entry.fn.isSet() {
+			b.fmt("%s %s", entry.key, entry.fn.pdfString(pdf))
+		}
+	}
+	if g.HT != nil {
+		if g.HT.HalftoneType == HalftoneDefault {
+			b.WriteString("/HT /Default")
+		} else {
+			b.fmt("/HT %s", pdf.addItem(g.HT))
+		}
+	}
+	if g.FL != nil {
+		b.fmt("/FL %s", FmtFloat(Fl(g.FL.(ObjFloat))))
+	}
+	if g.TK != nil {
+		b.fmt("/TK %v", g.TK.(ObjBool))
+	}
 	b.WriteString(">>")
 	return StreamHeader{}, b.String(), nil
 }
@@ -233,6 +490,15 @@ func (g *GraphicState) clone(cache cloneCache) Referenceable {
 	out.Font = g.Font.clone(cache)
 	out.BM = append([]Name(nil), g.BM...)
 	out.SMask = g.SMask.clone(cache)
+	out.BG = g.BG.clone(cache)
+	out.BG2 = g.BG2.clone(cache)
+	out.UCR = g.UCR.clone(cache)
+	out.UCR2 = g.UCR2.clone(cache)
+	out.TR = g.TR.clone(cache)
+	out.TR2 = g.TR2.clone(cache)
+	if g.HT != nil {
+		out.HT = cache.checkOrClone(g.HT).(*HalftoneDict)
+	}
 	return &out
 }
 
@@ -43,3 +43,50 @@ func TestWriteColorSpace(t *testing.T) {
 		t.Error(pdf.err)
 	}
 }
+
+func TestNewSoftMask(t *testing.T) {
+	form := &XObjectForm{BBox: Rectangle{0, 0, 100, 100}}
+	state, err := NewSoftMask(form, SoftMaskLuminosity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.SMask.S != SoftMaskLuminosity || state.SMask.G.Group.CS != ColorSpaceGray {
+		t.Fatalf("unexpected soft mask %v", state.SMask)
+	}
+	state, err = NewSoftMask(form, SoftMaskAlpha)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.SMask.S != SoftMaskAlpha || state.SMask.G.Group.CS != nil {
+		t.Fatalf("unexpected soft mask %v", state.SMask)
+	}
+	if _, err = NewSoftMask(form, "None"); err == nil {
+		t.Fatal("expected error for invalid subtype")
+	}
+	if _, err = NewSoftMask(nil, SoftMaskAlpha); err == nil {
+		t.Fatal("expected error for missing form")
+	}
+
+	img := &XObjectImage{Image: Image{Width: 2, Height: 2, BitsPerComponent: 8}, ColorSpace: ColorSpaceGray}
+	img.Content = []byte{0, 80, 160, 255}
+	state, err = NewImageSoftMask(img, Rectangle{10, 20, 110, 70}, SoftMaskLuminosity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	group := state.SMask.G
+	if exp := "q 100 0 0 50 10 20 cm /Im Do Q"; string(group.Content) != exp {
+		t.Fatalf("expected %s, got %s", exp, group.Content)
+	}
+	if group.Resources.XObject["Im"] != img {
+		t.Fatal("missing image in resources")
+	}
+
+	pdf := newWriter(new(bytes.Buffer), nil)
+	_, out, _ := state.pdfContent(pdf, 0)
+	if pdf.err != nil {
+		t.Fatal(pdf.err)
+	}
+	if !bytes.Contains([]byte(out), []byte("/SMask <</S/Luminosity/G ")) {
+		t.Fatalf("unexpected graphic state %s", out)
+	}
+}
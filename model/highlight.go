@@ -0,0 +1,67 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// NewHighlight returns a Highlight annotation covering the
+// given areas (typically one per line of text), with the author `author`
+// and the color `color` (defaulting to yellow).
+// The QuadPoints are written in the order used by most viewers
+// (upper left, upper right, lower left, lower right) and an appearance stream
+// is generated, filling the areas in Multiply blend mode so that the text stays readable.
+// It returns nil if `areas` is empty.
+func NewHighlight(areas []Rectangle, author string, color Color) *AnnotationDict {
+	if len(areas) == 0 {
+		return nil
+	}
+	if colorOperator(color, true) == "" {
+		color = Color{1, 1, 0}
+	}
+	var (
+		rect    = areas[0].Normalize()
+		quads   = make([]Fl, 0, 8*len(areas))
+		content strings.Builder
+	)
+	content.WriteString("q /GS0 gs " + colorOperator(color, true) + " ")
+	for _, area := range areas {
+		area = area.Normalize()
+		rect = rect.Union(area)
+		quads = append(quads, area.Llx, area.Ury, area.Urx, area.Ury, area.Llx, area.Lly, area.Urx, area.Lly)
+		content.WriteString(FmtFloat(area.Llx) + " " + FmtFloat(area.Lly) + " " +
+			FmtFloat(area.Width()) + " " + FmtFloat(area.Height()) + " re ")
+	}
+	content.WriteString("f Q")
+
+	form := &XObjectForm{
+		ContentStream: ContentStream{Stream: Stream{Content: []byte(content.String())}},
+		BBox:          rect, // the areas are drawn in page coordinates
+		Resources: ResourcesDict{ExtGState: map[Name]*GraphicState{
+			"GS0": {BM: []Name{"Multiply"}},
+		}},
+	}
+	highlight := AnnotationHighlight{QuadPoints: quads}
+	highlight.T = author
+	highlight.CreationDate = time.Now()
+	return &AnnotationDict{
+		BaseAnnotation: BaseAnnotation{
+			Rect: rect,
+			C:    append([]Fl(nil), color...),
+			F:    APrint,
+			AP:   &AppearanceDict{N: AppearanceEntry{"": form}},
+		},
+		Subtype: highlight,
+	}
+}
+
+// AddHighlight creates a Highlight annotation (see NewHighlight)
+// and appends it to the annotations of the page.
+// It returns the new annotation, or nil if `areas` is empty.
+func (p *PageObject) AddHighlight(areas []Rectangle, author string, color Color) *AnnotationDict {
+	annot := NewHighlight(areas, author, color)
+	if annot != nil {
+		p.Annots = append(p.Annots, annot)
+	}
+	return annot
+}
@@ -0,0 +1,45 @@
+package model
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestNewHighlight(t *testing.T) {
+	if NewHighlight(nil, "", nil) != nil {
+		t.Fatal("expected nil annotation for empty areas")
+	}
+
+	var page PageObject
+	annot := page.AddHighlight([]Rectangle{
+		{Llx: 100, Lly: 700, Urx: 300, Ury: 712},
+		{Llx: 150, Lly: 686, Urx: 100, Ury: 698}, // not normalized
+	}, "Author", Color{0, 1, 0})
+	if len(page.Annots) != 1 || page.Annots[0] != annot {
+		t.Fatal("annotation not added to the page")
+	}
+	if annot.Rect != (Rectangle{Llx: 100, Lly: 686, Urx: 300, Ury: 712}) {
+		t.Fatalf("unexpected Rect %v", annot.Rect)
+	}
+	highlight := annot.Subtype.(AnnotationHighlight)
+	exp := []Fl{100, 712, 300, 712, 100, 700, 300, 700, 100, 698, 150, 698, 100, 686, 150, 686}
+	if !reflect.DeepEqual(highlight.QuadPoints, exp) {
+		t.Fatalf("unexpected QuadPoints %v", highlight.QuadPoints)
+	}
+	if highlight.T != "Author" || highlight.CreationDate.IsZero() {
+		t.Fatalf("unexpected markup fields %v", highlight.AnnotationMarkup)
+	}
+
+	form := annot.AP.N[""]
+	if form.BBox != annot.Rect || form.Resources.ExtGState["GS0"].BM[0] != "Multiply" {
+		t.Fatalf("unexpected appearance %v", form)
+	}
+	if !bytes.Contains(form.Content, []byte("0 1 0 rg 100 700 200 12 re 100 686 50 12 re f")) {
+		t.Fatalf("unexpected content %s", form.Content)
+	}
+
+	if annot := NewHighlight([]Rectangle{{Urx: 10, Ury: 10}}, "", nil); !reflect.DeepEqual(annot.C, []Fl{1, 1, 0}) {
+		t.Fatalf("expected default color, got %v", annot.C)
+	}
+}
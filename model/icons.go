@@ -0,0 +1,222 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Predefined icons of Text annotations (see AnnotationText.Name).
+const (
+	IconComment      Name = "Comment"
+	IconKey          Name = "Key"
+	IconNote         Name = "Note"
+	IconHelp         Name = "Help"
+	IconNewParagraph Name = "NewParagraph"
+	IconParagraph    Name = "Paragraph"
+	IconInsert       Name = "Insert"
+)
+
+// iconSize is the size of the box used to draw the note icons
+const iconSize = 20
+
+// circlePath returns a closed path approximating a circle,
+// with four Bézier curves
+func circlePath(cx, cy, r Fl) string {
+	k := r * 0.5523 // control point distance
+	return fmt.Sprintf("%s %s m %s %s %s %s %s %s c %s %s %s %s %s %s c %s %s %s %s %s %s c %s %s %s %s %s %s c h ",
+		FmtFloat(cx+r), FmtFloat(cy),
+		FmtFloat(cx+r), FmtFloat(cy+k), FmtFloat(cx+k), FmtFloat(cy+r), FmtFloat(cx), FmtFloat(cy+r),
+		FmtFloat(cx-k), FmtFloat(cy+r), FmtFloat(cx-r), FmtFloat(cy+k), FmtFloat(cx-r), FmtFloat(cy),
+		FmtFloat(cx-r), FmtFloat(cy-k), FmtFloat(cx-k), FmtFloat(cy-r), FmtFloat(cx), FmtFloat(cy-r),
+		FmtFloat(cx+k), FmtFloat(cy-r), FmtFloat(cx+r), FmtFloat(cy-k), FmtFloat(cx+r), FmtFloat(cy))
+}
+
+// iconPaths maps the predefined icons to their drawing operations,
+// in a 20 x 20 box, filled with the current fill color and stroked in black.
+var iconPaths = map[Name]string{
+	IconComment: "1.5 5.5 m 1.5 18.5 l 18.5 18.5 l 18.5 5.5 l 9 5.5 l 5 1.5 l 6 5.5 l h B " +
+		"4.5 15 m 15.5 15 l 4.5 12 m 15.5 12 l 4.5 9 m 11.5 9 l S",
+	IconKey: circlePath(6, 13, 4.5) + "B " +
+		"10.5 14.5 m 18.5 14.5 l 18.5 11.5 l 17 11.5 l 17 8.5 l 15 8.5 l 15 11.5 l 10.5 11.5 l h B " +
+		circlePath(5, 13, 1.5) + "S",
+	IconNote: "3.5 1.5 m 3.5 18.5 l 13 18.5 l 16.5 15 l 16.5 1.5 l h B " +
+		"13 18.5 m 13 15 l 16.5 15 l S 6 12 m 13 12 l 6 9 m 14 9 l 6 6 m 14 6 l S",
+	IconHelp: circlePath(10, 10, 8.5) + "B " +
+		"2 w 7 12.5 m 7 16 13 16 13 12.5 c 13 10.5 10 10.5 10 8 c S 0 g 9 3.5 2 2 re f",
+	IconNewParagraph: "10 18.5 m 2.5 6.5 l 17.5 6.5 l h B 2.5 1.5 15 2.5 re B",
+	IconParagraph: "9 18.5 m 5.5 18.5 3 16.5 3 13.5 c 3 10.5 5.5 8.5 9 8.5 c h B " +
+		"9 18.5 m 9 1.5 l 13 18.5 m 13 1.5 l 9 18.5 m 16 18.5 l S",
+	IconInsert: "2 2 m 10 18 l 18 2 l 14 2 l 10 10 l 6 2 l h B",
+}
+
+// colorOperator returns the operator setting `c` as fill or stroke color,
+// or an empty string for an invalid color
+func colorOperator(c Color, fill bool) string {
+	var op string
+	switch len(c) {
+	case 1:
+		op = "g"
+	case 3:
+		op = "rg"
+	case 4:
+		op = "k"
+	default:
+		return ""
+	}
+	if !fill {
+		op = strings.ToUpper(op)
+	}
+	args := make([]string, len(c))
+	for i, v := range c {
+		args[i] = FmtFloat(v)
+	}
+	return strings.Join(args, " ") + " " + op
+}
+
+// NewTextIconAppearance returns an appearance stream drawing the
+// predefined icon `icon` (unknown names default to Note), filled with `color`
+// (defaulting to yellow) in a 20 x 20 box.
+func NewTextIconAppearance(icon Name, color Color) *XObjectForm {
+	path, ok := iconPaths[icon]
+	if !ok {
+		path = iconPaths[IconNote]
+	}
+	fill := colorOperator(color, true)
+	if fill == "" {
+		fill = "1 1 0 rg"
+	}
+	content := fmt.Sprintf("q 1 w 1 j 0 G %s %s Q", fill, path)
+	return &XObjectForm{
+		ContentStream: ContentStream{Stream: Stream{Content: []byte(content)}},
+		BBox:          Rectangle{Urx: iconSize, Ury: iconSize},
+	}
+}
+
+// popup layout
+const (
+	popupFontSize  Fl = 9
+	popupTitleSize Fl = 14
+	popupMargin    Fl = 3
+	// approximate width of the Helvetica glyphs, relative to the font size
+	popupCharWidth Fl = 0.55
+)
+
+// winAnsiText encodes `s` with WinAnsiEncoding, for the
+// (Latin) characters it shares with Unicode, replacing the others by '?'
+func winAnsiText(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r < 0x20 || (0x7F <= r && r < 0xA0) || r > 0xFF {
+			r = '?'
+		}
+		out = append(out, byte(r))
+	}
+	return out
+}
+
+// wrapLines splits `text` into lines of at most `maxChars` characters,
+// breaking at spaces when possible
+func wrapLines(text string, maxChars int) []string {
+	if maxChars < 1 {
+		maxChars = 1
+	}
+	var out []string
+	for _, paragraph := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			for len([]rune(word)) > maxChars { // split long words
+				if line != "" {
+					out = append(out, line)
+					line = ""
+				}
+				runes := []rune(word)
+				out = append(out, string(runes[:maxChars]))
+				word = string(runes[maxChars:])
+			}
+			if line == "" {
+				line = word
+			} else if len([]rune(line))+1+len([]rune(word)) <= maxChars {
+				line += " " + word
+			} else {
+				out = append(out, line)
+				line = word
+			}
+		}
+		out = append(out, line)
+	}
+	return out
+}
+
+// newPopupAppearance returns an appearance stream for a popup
+// of size `width` x `height`, showing the author `title` in a bar
+// filled with `color` and the text `contents` below.
+// The standard Helvetica fonts are used (without being embedded),
+// so that only Latin text is supported.
+func newPopupAppearance(width, height Fl, color Color, title, contents string) *XObjectForm {
+	res := NewResourcesDict()
+	res.Font["Helv"] = &FontDict{Subtype: FontType1{BaseFont: "Helvetica", Encoding: WinAnsiEncoding}}
+	res.Font["HeBo"] = &FontDict{Subtype: FontType1{BaseFont: "Helvetica-Bold", Encoding: WinAnsiEncoding}}
+
+	fill := colorOperator(color, true)
+	if fill == "" {
+		fill = "1 1 0 rg"
+	}
+	b := newBuffer()
+	b.line("q 1 g 0 G 0.5 w 0.25 0.25 %s %s re B", FmtFloat(width-0.5), FmtFloat(height-0.5))
+	b.line("%s 0.25 %s %s %s re B", fill, FmtFloat(height-popupTitleSize), FmtFloat(width-0.5), FmtFloat(popupTitleSize-0.25))
+	// clip the text to the popup
+	b.line("%s %s %s %s re W n", FmtFloat(popupMargin), FmtFloat(popupMargin), FmtFloat(width-2*popupMargin), FmtFloat(height-2*popupMargin))
+	b.line("BT 0 g /HeBo %s Tf %s %s Td %s Tj ET", FmtFloat(popupFontSize), FmtFloat(popupMargin),
+		FmtFloat(height-popupTitleSize+(popupTitleSize-popupFontSize)/2+1), EscapeByteString(winAnsiText(title)))
+	maxChars := int((width - 2*popupMargin) / (popupFontSize * popupCharWidth))
+	leading := popupFontSize * 1.2
+	b.line("BT 0 g /Helv %s Tf %s TL %s %s Td", FmtFloat(popupFontSize), FmtFloat(leading),
+		FmtFloat(popupMargin), FmtFloat(height-popupTitleSize-popupMargin-popupFontSize))
+	for i, line := range wrapLines(contents, maxChars) {
+		if i != 0 {
+			b.fmt("T* ")
+		}
+		b.line("%s Tj", EscapeByteString(winAnsiText(line)))
+	}
+	b.fmt("ET Q")
+	return &XObjectForm{
+		ContentStream: ContentStream{Stream: Stream{Content: b.Bytes()}},
+		BBox:          Rectangle{Urx: width, Ury: height},
+		Resources:     res,
+	}
+}
+
+// GenerateNoteAppearance generates the normal appearance of a Text annotation
+// (a sticky note), drawing its icon (see NewTextIconAppearance) with
+// the color of the annotation, so that it is visible in viewers which do not synthesize icons.
+// An empty annotation rectangle is replaced by a 20 x 20 square, with the same upper-left corner.
+// The appearance of the popup associated to the note, if any, is also generated,
+// showing the author (T) and the text (Contents) of the note.
+// Appearances which are already set are left unchanged.
+// It returns false if `annot` is not a Text annotation.
+func (annot *AnnotationDict) GenerateNoteAppearance() bool {
+	text, ok := annot.Subtype.(AnnotationText)
+	if !ok {
+		return false
+	}
+	if annot.AP == nil {
+		if annot.Rect.Width() == 0 || annot.Rect.Height() == 0 {
+			annot.Rect = Rectangle{Llx: annot.Rect.Llx, Lly: annot.Rect.Ury - iconSize, Urx: annot.Rect.Llx + iconSize, Ury: annot.Rect.Ury}
+		}
+		annot.AP = &AppearanceDict{N: AppearanceEntry{"": NewTextIconAppearance(text.Name, annot.C)}}
+	}
+	if popup := text.Popup; popup != nil && popup.AP == nil {
+		width, height := popup.Rect.Width(), popup.Rect.Height()
+		if width < 0 {
+			width = -width
+		}
+		if height < 0 {
+			height = -height
+		}
+		if width != 0 && height != 0 {
+			form := newPopupAppearance(width, height, annot.C, text.T, annot.Contents)
+			popup.AP = &AppearanceDict{N: AppearanceEntry{"": form}}
+		}
+	}
+	return true
+}
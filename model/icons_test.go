@@ -0,0 +1,71 @@
+package model
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestTextIconAppearance(t *testing.T) {
+	for _, icon := range []Name{IconComment, IconKey, IconNote, IconHelp, IconNewParagraph, IconParagraph, IconInsert, "Unknown"} {
+		form := NewTextIconAppearance(icon, Color{0, 0, 1})
+		if form.BBox != (Rectangle{Urx: 20, Ury: 20}) {
+			t.Fatalf("unexpected BBox %v", form.BBox)
+		}
+		if !bytes.Contains(form.Content, []byte("0 0 1 rg")) || !bytes.Contains(form.Content, []byte(" B")) {
+			t.Fatalf("unexpected content %s", form.Content)
+		}
+	}
+	if form := NewTextIconAppearance(IconNote, nil); !bytes.Contains(form.Content, []byte("1 1 0 rg")) {
+		t.Fatalf("expected default color, got %s", form.Content)
+	}
+}
+
+func TestWrapLines(t *testing.T) {
+	got := wrapLines("a long text to wrap\nnext averyveryverylongword", 10)
+	exp := []string{"a long", "text to", "wrap", "next", "averyveryv", "erylongwor", "d"}
+	if !reflect.DeepEqual(got, exp) {
+		t.Fatalf("expected %q, got %q", exp, got)
+	}
+}
+
+func TestGenerateNoteAppearance(t *testing.T) {
+	popup := &AnnotationPopup{BaseAnnotation: BaseAnnotation{Rect: Rectangle{Llx: 120, Lly: 600, Urx: 300, Ury: 700}}}
+	note := AnnotationText{Name: IconComment}
+	note.T = "Author"
+	note.Popup = popup
+	annot := &AnnotationDict{
+		BaseAnnotation: BaseAnnotation{Rect: Rectangle{Llx: 100, Ury: 700, Lly: 700, Urx: 100}, Contents: "A comment (with parenthesis)"},
+		Subtype:        note,
+	}
+	if !annot.GenerateNoteAppearance() {
+		t.Fatal("expected Text annotation")
+	}
+	if annot.Rect != (Rectangle{Llx: 100, Lly: 680, Urx: 120, Ury: 700}) {
+		t.Fatalf("unexpected Rect %v", annot.Rect)
+	}
+	if annot.AP == nil || annot.AP.N[""] == nil {
+		t.Fatal("missing appearance")
+	}
+	if popup.AP == nil {
+		t.Fatal("missing popup appearance")
+	}
+	form := popup.AP.N[""]
+	if form.BBox != (Rectangle{Urx: 180, Ury: 100}) || len(form.Resources.Font) != 2 {
+		t.Fatalf("unexpected popup appearance %v", form)
+	}
+	if !bytes.Contains(form.Content, []byte(`(Author) Tj`)) || !bytes.Contains(form.Content, []byte(`\(with`)) {
+		t.Fatalf("unexpected popup content %s", form.Content)
+	}
+
+	// existing appearances are preserved
+	ap := annot.AP
+	annot.GenerateNoteAppearance()
+	if annot.AP != ap {
+		t.Fatal("appearance should not be modified")
+	}
+
+	if (&AnnotationDict{Subtype: AnnotationLink{}}).GenerateNoteAppearance() {
+		t.Fatal("expected false for non Text annotations")
+	}
+}
@@ -0,0 +1,225 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+)
+
+// JavaScriptCode is a JavaScript code found in a document,
+// either in a JavaScript action or in a Rendition action.
+type JavaScriptCode struct {
+	// Location describes where the action triggering the script
+	// is found, such as "OpenAction", "AA/WC", "Names/JavaScript/init",
+	// "Pages[0]/AA/O", "Pages[0]/Annots[2]/A", "AcroForm/address.city/AA/K" or "Outlines[1]".
+	// Pages, annotations and outline items are referenced by their (0-based) index.
+	// The scripts of chained actions (see Action.Next) share the location of the first action.
+	Location string
+	JS       string
+}
+
+// locatedAction is a pointer to an action of the document,
+// with its location
+type locatedAction struct {
+	location string
+	action   *Action
+}
+
+func (a *DocumentAdditionalActions) actions(prefix string) []locatedAction {
+	return []locatedAction{
+		{prefix + "/WC", &a.WC}, {prefix + "/WS", &a.WS}, {prefix + "/DS", &a.DS},
+		{prefix + "/WP", &a.WP}, {prefix + "/DP", &a.DP},
+	}
+}
+
+func (a *PageAdditionalActions) actions(prefix string) []locatedAction {
+	return []locatedAction{{prefix + "/O", &a.O}, {prefix + "/C", &a.C}}
+}
+
+func (a *FormFielAdditionalActions) actions(prefix string) []locatedAction {
+	return []locatedAction{
+		{prefix + "/K", &a.K}, {prefix + "/F", &a.F}, {prefix + "/V", &a.V}, {prefix + "/C", &a.C},
+	}
+}
+
+func (a *AnnotationAdditionalActions) actions(prefix string) []locatedAction {
+	return []locatedAction{
+		{prefix + "/E", &a.E}, {prefix + "/X", &a.X}, {prefix + "/D", &a.D}, {prefix + "/U", &a.U},
+		{prefix + "/Fo", &a.Fo}, {prefix + "/Bl", &a.Bl}, {prefix + "/PO", &a.PO},
+		{prefix + "/PC", &a.PC}, {prefix + "/PV", &a.PV}, {prefix + "/PI", &a.PI},
+	}
+}
+
+// walkActions calls `f` for every action of the document
+// (excepted the chained actions), which may be modified in place.
+func (doc *Document) walkActions(f func(location string, action *Action)) {
+	cat := &doc.Catalog
+	f("OpenAction", &cat.OpenAction)
+	for _, la := range cat.AA.actions("AA") {
+		f(la.location, la.action)
+	}
+	for i := range cat.Names.JavaScript {
+		f("Names/JavaScript/"+cat.Names.JavaScript[i].Name, &cat.Names.JavaScript[i].Action)
+	}
+
+	// the widgets are usually found both in the page annotations
+	// and in the form fields
+	seen := make(map[*AnnotationDict]bool)
+	walkAnnotation := func(prefix string, annot *AnnotationDict) {
+		if annot == nil || seen[annot] {
+			return
+		}
+		seen[annot] = true
+		switch st := annot.Subtype.(type) {
+		case AnnotationLink:
+			f(prefix+"/A", &st.A)
+			f(prefix+"/PA", &st.PA)
+			annot.Subtype = st
+		case AnnotationWidget:
+			f(prefix+"/A", &st.A)
+			for _, la := range st.AA.actions(prefix + "/AA") {
+				f(la.location, la.action)
+			}
+			annot.Subtype = st
+		case AnnotationScreen:
+			f(prefix+"/A", &st.A)
+			for _, la := range st.AA.actions(prefix + "/AA") {
+				f(la.location, la.action)
+			}
+			annot.Subtype = st
+		}
+	}
+	for i, page := range cat.Pages.Flatten() {
+		prefix := fmt.Sprintf("Pages[%d]", i)
+		for _, la := range page.AA.actions(prefix + "/AA") {
+			f(la.location, la.action)
+		}
+		for j, annot := range page.Annots {
+			walkAnnotation(fmt.Sprintf("%s/Annots[%d]", prefix, j), annot)
+		}
+	}
+
+	fields := cat.AcroForm.Flatten()
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		field, prefix := fields[name], "AcroForm/"+name
+		for _, la := range field.Field.AA.actions(prefix + "/AA") {
+			f(la.location, la.action)
+		}
+		for j, widget := range field.Field.Widgets {
+			walkAnnotation(fmt.Sprintf("%s/Widgets[%d]", prefix, j), widget.AnnotationDict)
+		}
+	}
+
+	if cat.Outlines != nil {
+		for i, item := range cat.Outlines.Flatten() {
+			f(fmt.Sprintf("Outlines[%d]", i), &item.A)
+		}
+	}
+}
+
+// Scripts returns the JavaScript codes found in the document:
+// in the open action, the additional actions of the document, pages, annotations and form fields,
+// the actions of annotations and outline items, and the document-level scripts (Names.JavaScript).
+func (doc *Document) Scripts() []JavaScriptCode {
+	var out []JavaScriptCode
+	doc.walkActions(func(location string, action *Action) {
+		if action.ActionType == nil {
+			return
+		}
+		action.Walk(func(ac Action) bool {
+			switch ac := ac.ActionType.(type) {
+			case ActionJavaScript:
+				out = append(out, JavaScriptCode{Location: location, JS: ac.JS})
+			case ActionRendition:
+				if ac.JS != "" {
+					out = append(out, JavaScriptCode{Location: location, JS: ac.JS})
+				}
+			}
+			return true
+		})
+	})
+	return out
+}
+
+// replaceScripts applies `replace` to the action chain `ac`,
+// and returns the number of scripts modified or removed.
+// Removed JavaScript actions are replaced by their following actions.
+func replaceScripts(ac *Action, location string, replace func(location, js string) (string, bool)) int {
+	count := 0
+	switch st := ac.ActionType.(type) {
+	case ActionJavaScript:
+		if js, keep := replace(location, st.JS); !keep {
+			ac.ActionType = nil
+			count++
+		} else if js != st.JS {
+			ac.ActionType = ActionJavaScript{JS: js}
+			count++
+		}
+	case ActionRendition:
+		if st.JS != "" {
+			js, keep := replace(location, st.JS)
+			if !keep {
+				js = ""
+			}
+			if js != st.JS {
+				st.JS = js
+				ac.ActionType = st
+				count++
+			}
+		}
+	}
+
+	var next []Action
+	for i := range ac.Next {
+		count += replaceScripts(&ac.Next[i], location, replace)
+		if ac.Next[i].ActionType != nil {
+			next = append(next, ac.Next[i])
+		}
+	}
+	if len(next) != len(ac.Next) {
+		ac.Next = next
+	}
+
+	if ac.ActionType == nil && len(ac.Next) != 0 {
+		// promote the following actions, preserving the execution order
+		first := ac.Next[0]
+		first.Next = append(first.Next, ac.Next[1:]...)
+		*ac = first
+	}
+	return count
+}
+
+// ReplaceScripts calls `replace` for each script of the document (see Scripts),
+// and updates it with the returned code, or removes it if `keep` is false.
+// When a JavaScript action is removed, the actions chained after it are kept.
+// Document-level scripts (Names.JavaScript) left without action are removed.
+// It returns the number of scripts modified or removed.
+func (doc *Document) ReplaceScripts(replace func(location, js string) (newJS string, keep bool)) int {
+	count := 0
+	doc.walkActions(func(location string, action *Action) {
+		if action.ActionType != nil {
+			count += replaceScripts(action, location, replace)
+		}
+	})
+
+	var scripts JavaScriptTree
+	for _, js := range doc.Catalog.Names.JavaScript {
+		if js.Action.ActionType != nil {
+			scripts = append(scripts, js)
+		}
+	}
+	if len(scripts) != len(doc.Catalog.Names.JavaScript) {
+		doc.Catalog.Names.JavaScript = scripts
+	}
+	return count
+}
+
+// StripScripts removes all the scripts of the document (see ReplaceScripts),
+// and returns the number of scripts removed.
+func (doc *Document) StripScripts() int {
+	return doc.ReplaceScripts(func(string, string) (string, bool) { return "", false })
+}
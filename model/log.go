@@ -0,0 +1,106 @@
+package model
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+)
+
+// LogLevel is the severity of a diagnostic message.
+type LogLevel uint8
+
+const (
+	LogDebug LogLevel = iota
+	LogInfo
+	LogWarning // invalid or unsupported content, which is ignored or repaired
+	LogError
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LogDebug:
+		return "debug"
+	case LogInfo:
+		return "info"
+	case LogWarning:
+		return "warning"
+	case LogError:
+		return "error"
+	default:
+		return fmt.Sprintf("<level %d>", l)
+	}
+}
+
+// Logger receives the diagnostics emitted by the library, such as
+// the warnings about invalid content found when reading a file.
+//
+// The default logger writes to the standard log package: see SetLogger
+// to change it for the whole library, or the Logger fields of
+// reader.Options and WriteOptions to change it for one call.
+type Logger interface {
+	// Log reports a message, with an optional context given as
+	// alternating keys and values, such as "object", 12.
+	Log(level LogLevel, msg string, keysAndValues ...interface{})
+}
+
+// FormatLog returns a one line description of the message,
+// with its context written as "key=value".
+// It may be used by Logger implementations.
+func FormatLog(level LogLevel, msg string, keysAndValues ...interface{}) string {
+	var b strings.Builder
+	b.WriteString(level.String())
+	b.WriteString(": ")
+	b.WriteString(msg)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		if i+1 < len(keysAndValues) {
+			fmt.Fprintf(&b, " %v=%v", keysAndValues[i], keysAndValues[i+1])
+		} else {
+			fmt.Fprintf(&b, " %v", keysAndValues[i])
+		}
+	}
+	return b.String()
+}
+
+// stdLogger uses the standard log package
+type stdLogger struct{}
+
+func (stdLogger) Log(level LogLevel, msg string, keysAndValues ...interface{}) {
+	log.Println(FormatLog(level, msg, keysAndValues...))
+}
+
+type discardLogger struct{}
+
+func (discardLogger) Log(LogLevel, string, ...interface{}) {}
+
+// DiscardLogger ignores all the messages.
+var DiscardLogger Logger = discardLogger{}
+
+// loggerBox is used to store interfaces with different
+// concrete types in an atomic.Value
+type loggerBox struct{ Logger }
+
+var defaultLogger atomic.Value
+
+func init() { defaultLogger.Store(loggerBox{stdLogger{}}) }
+
+// SetLogger changes the logger used by default by the library.
+// Passing nil restores the default logger, which uses the standard log package.
+// It is safe to call SetLogger concurrently with the library functions.
+func SetLogger(logger Logger) {
+	if logger == nil {
+		logger = stdLogger{}
+	}
+	defaultLogger.Store(loggerBox{logger})
+}
+
+// DefaultLogger returns the logger set by SetLogger.
+func DefaultLogger() Logger { return defaultLogger.Load().(loggerBox).Logger }
+
+// LoggerOrDefault returns `logger`, or the default logger if it is nil.
+func LoggerOrDefault(logger Logger) Logger {
+	if logger == nil {
+		return DefaultLogger()
+	}
+	return logger
+}
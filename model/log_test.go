@@ -0,0 +1,48 @@
+package model
+
+import (
+	"bytes"
+	"testing"
+)
+
+type recordLogger struct{ messages []string }
+
+func (r *recordLogger) Log(level LogLevel, msg string, keysAndValues ...interface{}) {
+	r.messages = append(r.messages, FormatLog(level, msg, keysAndValues...))
+}
+
+func TestLogger(t *testing.T) {
+	if s := FormatLog(LogWarning, "invalid object", "object", 12, "extra"); s != "warning: invalid object object=12 extra" {
+		t.Fatalf("unexpected format %s", s)
+	}
+
+	doc := Document{Catalog: Catalog{Perms: &Perms{UR3: &SignatureDict{}}}}
+
+	// per call logger
+	var perCall recordLogger
+	if err := doc.WriteWithOptions(&bytes.Buffer{}, nil, WriteOptions{Logger: &perCall}); err != nil {
+		t.Fatal(err)
+	}
+	if len(perCall.messages) != 1 {
+		t.Fatalf("expected one warning, got %v", perCall.messages)
+	}
+
+	// package level logger
+	var global recordLogger
+	SetLogger(&global)
+	defer SetLogger(nil)
+	if err := doc.Write(&bytes.Buffer{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(global.messages) != 1 {
+		t.Fatalf("expected one warning, got %v", global.messages)
+	}
+	if LoggerOrDefault(nil) != Logger(&global) || LoggerOrDefault(DiscardLogger) != DiscardLogger {
+		t.Fatal("unexpected logger")
+	}
+
+	SetLogger(nil)
+	if _, ok := DefaultLogger().(stdLogger); !ok {
+		t.Fatal("expected the default logger to be restored")
+	}
+}
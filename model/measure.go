@@ -0,0 +1,268 @@
+package model
+
+// Measure specifies an alternate coordinate system for a region of a page,
+// and is one of MeasureRL or MeasureGEO.
+// See 12.9 - Measurement Properties
+type Measure interface {
+	measureString(pdf pdfWriter, ref Reference) string
+	// returns a deep copy, preserving the concrete type
+	cloneMeasure() Measure
+}
+
+// MeasureRL is a rectilinear coordinate system,
+// with a constant scale factor in the two directions.
+// See Table 261 – Additional entries in a rectilinear measure dictionary
+type MeasureRL struct {
+	R   string         // required, scale ratio, such as "1 in = 1 mi"
+	X   []NumberFormat // required, measurement of the X axis
+	Y   []NumberFormat // optional, measurement of the Y axis, default to X
+	D   []NumberFormat // required, measurement of distances
+	A   []NumberFormat // required, measurement of areas
+	T   []NumberFormat // optional, measurement of angles
+	S   []NumberFormat // optional, measurement of slopes
+	O   *[2]Fl         // optional, origin of the measurement coordinate system, default to [0 0]
+	CYX MaybeFloat     // optional, Y to X conversion factor
+}
+
+func writeNumberFormats(pdf pdfWriter, ref Reference, nfs []NumberFormat) string {
+	b := newBuffer()
+	b.WriteString("[")
+	for _, nf := range nfs {
+		b.WriteString(nf.pdfString(pdf, ref))
+	}
+	b.WriteString("]")
+	return b.String()
+}
+
+func cloneNumberFormats(nfs []NumberFormat) []NumberFormat {
+	return append([]NumberFormat(nil), nfs...)
+}
+
+func (m MeasureRL) measureString(pdf pdfWriter, ref Reference) string {
+	b := newBuffer()
+	b.fmt("<</Type/Measure/Subtype/RL/R %s", pdf.EncodeString(m.R, TextString, ref))
+	b.fmt("/X %s", writeNumberFormats(pdf, ref, m.X))
+	if len(m.Y) != 0 {
+		b.fmt("/Y %s", writeNumberFormats(pdf, ref, m.Y))
+	}
+	b.fmt("/D %s/A %s", writeNumberFormats(pdf, ref, m.D), writeNumberFormats(pdf, ref, m.A))
+	if len(m.T) != 0 {
+		b.fmt("/T %s", writeNumberFormats(pdf, ref, m.T))
+	}
+	if len(m.S) != 0 {
+		b.fmt("/S %s", writeNumberFormats(pdf, ref, m.S))
+	}
+	if m.O != nil {
+		b.fmt("/O %s", writeFloatArray(m.O[:]))
+	}
+	if m.CYX != nil {
+		b.fmt("/CYX %s", FmtFloat(Fl(m.CYX.(ObjFloat))))
+	}
+	b.WriteString(">>")
+	return b.String()
+}
+
+func (m MeasureRL) cloneMeasure() Measure {
+	out := m
+	out.X = cloneNumberFormats(m.X)
+	out.Y = cloneNumberFormats(m.Y)
+	out.D = cloneNumberFormats(m.D)
+	out.A = cloneNumberFormats(m.A)
+	out.T = cloneNumberFormats(m.T)
+	out.S = cloneNumberFormats(m.S)
+	if m.O != nil {
+		o := *m.O
+		out.O = &o
+	}
+	return out
+}
+
+// NumberFormat specifies how a measurement value shall be
+// converted and displayed.
+// See Table 262 – Entries in a number format dictionary
+type NumberFormat struct {
+	U  string // required, label for the units
+	C  Fl     // required, conversion factor from the previous unit
+	F  Name   // optional, D (decimal), F (fraction), R (round) or T (truncate), default to D
+	D  int    // optional, precision or denominator, default to 100
+	FD bool   // optional, if true, fractions are not reduced
+	RT string // optional, thousands separator, default to ","
+	RD string // optional, decimal separator, default to "."
+	PS string // optional, prefix spacing, default to a single space
+	SS string // optional, suffix spacing, default to a single space
+	O  Name   // optional, S (suffix) or P (prefix), default to S
+}
+
+func (nf NumberFormat) pdfString(pdf pdfWriter, ref Reference) string {
+	b := newBuffer()
+	b.fmt("<</Type/NumberFormat/U %s/C %s", pdf.EncodeString(nf.U, TextString, ref), FmtFloat(nf.C))
+	if nf.F != "" {
+		b.fmt("/F %s", nf.F)
+	}
+	if nf.D != 0 {
+		b.fmt("/D %d", nf.D)
+	}
+	if nf.FD {
+		b.WriteString("/FD true")
+	}
+	if nf.RT != "" {
+		b.fmt("/RT %s", pdf.EncodeString(nf.RT, TextString, ref))
+	}
+	if nf.RD != "" {
+		b.fmt("/RD %s", pdf.EncodeString(nf.RD, TextString, ref))
+	}
+	if nf.PS != "" {
+		b.fmt("/PS %s", pdf.EncodeString(nf.PS, TextString, ref))
+	}
+	if nf.SS != "" {
+		b.fmt("/SS %s", pdf.EncodeString(nf.SS, TextString, ref))
+	}
+	if nf.O != "" {
+		b.fmt("/O %s", nf.O)
+	}
+	b.WriteString(">>")
+	return b.String()
+}
+
+// MeasureGEO is a geospatial coordinate system, mapping
+// points of the page to points of the earth.
+// See 12.10 - Geospatial Features (PDF 2.0)
+type MeasureGEO struct {
+	Bounds []Fl              // optional, polygon delimiting the region, in unit square coordinates
+	GCS    CoordinateSystem  // required
+	DCS    *CoordinateSystem // optional, display coordinate system
+	PDU    [3]Name           // optional, preferred units for linear, area and angular measurements
+	GPTS   []Fl              // required, pairs of latitude and longitude
+	LPTS   []Fl              // optional, points in unit square coordinates, corresponding to GPTS
+}
+
+func (m MeasureGEO) measureString(pdf pdfWriter, ref Reference) string {
+	b := newBuffer()
+	b.WriteString("<</Type/Measure/Subtype/GEO")
+	if len(m.Bounds) != 0 {
+		b.fmt("/Bounds %s", writeFloatArray(m.Bounds))
+	}
+	b.fmt("/GCS %s", m.GCS.pdfString(pdf, ref))
+	if m.DCS != nil {
+		b.fmt("/DCS %s", m.DCS.pdfString(pdf, ref))
+	}
+	if m.PDU != ([3]Name{}) {
+		b.fmt("/PDU %s", writeNameArray(m.PDU[:]))
+	}
+	b.fmt("/GPTS %s", writeFloatArray(m.GPTS))
+	if len(m.LPTS) != 0 {
+		b.fmt("/LPTS %s", writeFloatArray(m.LPTS))
+	}
+	b.WriteString(">>")
+	return b.String()
+}
+
+func (m MeasureGEO) cloneMeasure() Measure {
+	out := m
+	out.Bounds = append([]Fl(nil), m.Bounds...)
+	if m.DCS != nil {
+		dcs := *m.DCS
+		out.DCS = &dcs
+	}
+	out.GPTS = append([]Fl(nil), m.GPTS...)
+	out.LPTS = append([]Fl(nil), m.LPTS...)
+	return out
+}
+
+// CoordinateSystem is either a geographic (GEOGCS)
+// or a projected (PROJCS) coordinate system.
+// At least one of EPSG or WKT should be provided.
+// See Table 264 – Entries in a geographic coordinate system dictionary
+type CoordinateSystem struct {
+	Projected bool   // true for PROJCS, false for GEOGCS
+	EPSG      int    // optional, EPSG reference code
+	WKT       string // optional, Well Known Text description (ASCII string)
+}
+
+func (cs CoordinateSystem) pdfString(pdf pdfWriter, ref Reference) string {
+	b := newBuffer()
+	if cs.Projected {
+		b.WriteString("<</Type/PROJCS")
+	} else {
+		b.WriteString("<</Type/GEOGCS")
+	}
+	if cs.EPSG != 0 {
+		b.fmt("/EPSG %d", cs.EPSG)
+	}
+	if cs.WKT != "" {
+		b.fmt("/WKT %s", pdf.EncodeString(cs.WKT, ByteString, ref))
+	}
+	b.WriteString(">>")
+	return b.String()
+}
+
+// Viewport is a rectangular region of a page, which may
+// be associated with a coordinate system.
+// See Table 260 – Entries in a viewport dictionary
+type Viewport struct {
+	BBox    Rectangle // required, in default user space
+	Name    string    // optional, text string
+	Measure Measure   // optional
+	// TODO: support PtData
+}
+
+func (vp Viewport) pdfString(pdf pdfWriter, ref Reference) string {
+	b := newBuffer()
+	b.fmt("<</Type/Viewport/BBox %s", vp.BBox)
+	if vp.Name != "" {
+		b.fmt("/Name %s", pdf.EncodeString(vp.Name, TextString, ref))
+	}
+	if vp.Measure != nil {
+		b.fmt("/Measure %s", vp.Measure.measureString(pdf, ref))
+	}
+	b.WriteString(">>")
+	return b.String()
+}
+
+func (vp Viewport) clone() Viewport {
+	out := vp
+	if vp.Measure != nil {
+		out.Measure = vp.Measure.cloneMeasure()
+	}
+	return out
+}
+
+// Viewports is a list of viewports, written
+// in PDF as the VP entry of a page.
+type Viewports []Viewport
+
+func (vps Viewports) pdfString(pdf pdfWriter, ref Reference) string {
+	b := newBuffer()
+	b.WriteString("[")
+	for _, vp := range vps {
+		b.WriteString(vp.pdfString(pdf, ref))
+	}
+	b.WriteString("]")
+	return b.String()
+}
+
+// At returns the viewport containing the point (x, y)
+// (in default user space), or nil if not found.
+// As specified, when several viewports overlap,
+// the last one in the list is returned.
+func (vps Viewports) At(x, y Fl) *Viewport {
+	for i := len(vps) - 1; i >= 0; i-- {
+		box := vps[i].BBox
+		if x >= minFl(box.Llx, box.Urx) && x <= maxFl(box.Llx, box.Urx) &&
+			y >= minFl(box.Lly, box.Ury) && y <= maxFl(box.Lly, box.Ury) {
+			return &vps[i]
+		}
+	}
+	return nil
+}
+
+func (vps Viewports) clone() Viewports {
+	if vps == nil { // preserve reflect.DeepEqual
+		return nil
+	}
+	out := make(Viewports, len(vps))
+	for i, vp := range vps {
+		out[i] = vp.clone()
+	}
+	return out
+}
@@ -0,0 +1,485 @@
+package model
+
+import "math"
+
+// This file implements the decoding and encoding of the vertex data
+// of the mesh shadings (types 4 to 7).
+// See 8.7.4.5.5 to 8.7.4.5.8 in the SPEC.
+
+// MeshVertex is a point of a mesh, with its color.
+type MeshVertex struct {
+	X, Y Fl
+	// Color has one value per color component, or
+	// one parametric value if the shading has a Function.
+	Color []Fl
+}
+
+// MeshTriangle is a triangle of a free-form or lattice-form mesh.
+type MeshTriangle [3]MeshVertex
+
+// MeshPatch is a patch of a Coons or tensor-product mesh.
+type MeshPatch struct {
+	// Points are the control points, in the order used in a PDF
+	// stream: the 12 boundary points, starting at the
+	// lower left corner and turning counterclockwise, followed by the
+	// 4 internal points for tensor-product patches.
+	Points [][2]Fl
+	// Colors are the colors of the 4 corners, in the same order.
+	Colors [4][]Fl
+}
+
+// default number of bits used when encoding meshes
+const (
+	defaultBitsPerCoordinate = 32
+	defaultBitsPerComponent  = 16
+	defaultBitsPerFlag       = 8
+)
+
+var errMeshData = newError(ErrType, "invalid mesh shading data")
+
+// meshReader reads the packed values of a mesh shading stream,
+// from the higher-order bits
+type meshReader struct {
+	data   []byte
+	pos    int // in bits
+	decode [][2]Fl
+
+	bitsPerCoordinate, bitsPerComponent, bitsPerFlag uint8
+}
+
+func (ss ShadingStream) newMeshReader(bitsPerFlag uint8) (meshReader, error) {
+	if len(ss.Decode) < 3 {
+		return meshReader{}, newError(ErrType, "invalid Decode array for mesh shading: %v", ss.Decode)
+	}
+	if ss.BitsPerCoordinate == 0 || ss.BitsPerCoordinate > 32 || ss.BitsPerComponent == 0 || ss.BitsPerComponent > 16 {
+		return meshReader{}, newError(ErrType, "invalid bits per value for mesh shading: %d %d", ss.BitsPerCoordinate, ss.BitsPerComponent)
+	}
+	data, err := ss.Stream.Decode()
+	if err != nil {
+		return meshReader{}, err
+	}
+	return meshReader{
+		data: data, decode: ss.Decode, bitsPerCoordinate: ss.BitsPerCoordinate,
+		bitsPerComponent: ss.BitsPerComponent, bitsPerFlag: bitsPerFlag,
+	}, nil
+}
+
+// hasMore returns true if a full item of `bits` length may still be read
+func (r *meshReader) hasMore(bits int) bool { return r.pos+bits <= 8*len(r.data) }
+
+func (r *meshReader) readBits(n uint8) uint32 {
+	var out uint32
+	for i := uint8(0); i < n; i++ {
+		b := r.data[r.pos/8] >> (7 - uint(r.pos%8)) & 1
+		out = out<<1 | uint32(b)
+		r.pos++
+	}
+	return out
+}
+
+// align skips the remaining bits of the current byte
+func (r *meshReader) align() { r.pos = (r.pos + 7) / 8 * 8 }
+
+// interpolate maps the raw value on the given range
+func interpolate(raw uint32, bits uint8, rg [2]Fl) Fl {
+	max := float64(uint64(1)<<bits - 1)
+	return rg[0] + Fl(float64(raw)/max*float64(rg[1]-rg[0]))
+}
+
+func (r *meshReader) point() [2]Fl {
+	x := interpolate(r.readBits(r.bitsPerCoordinate), r.bitsPerCoordinate, r.decode[0])
+	y := interpolate(r.readBits(r.bitsPerCoordinate), r.bitsPerCoordinate, r.decode[1])
+	return [2]Fl{x, y}
+}
+
+func (r *meshReader) color() []Fl {
+	out := make([]Fl, len(r.decode)-2)
+	for i := range out {
+		out[i] = interpolate(r.readBits(r.bitsPerComponent), r.bitsPerComponent, r.decode[2+i])
+	}
+	return out
+}
+
+// vertexBits returns the length of one vertex
+func (r *meshReader) vertexBits() int {
+	return int(r.bitsPerFlag) + 2*int(r.bitsPerCoordinate) + (len(r.decode)-2)*int(r.bitsPerComponent)
+}
+
+// vertex reads one vertex and aligns the position
+func (r *meshReader) vertex() (flag uint32, v MeshVertex) {
+	flag = r.readBits(r.bitsPerFlag)
+	p := r.point()
+	v = MeshVertex{X: p[0], Y: p[1], Color: r.color()}
+	r.align()
+	return flag, v
+}
+
+// meshWriter packs values into a mesh shading stream
+type meshWriter struct {
+	data   []byte
+	pos    int // in bits
+	decode [][2]Fl
+
+	bitsPerCoordinate, bitsPerComponent, bitsPerFlag uint8
+}
+
+func (w *meshWriter) writeBits(v uint32, n uint8) {
+	for i := int(n) - 1; i >= 0; i-- {
+		if w.pos%8 == 0 {
+			w.data = append(w.data, 0)
+		}
+		w.data[len(w.data)-1] |= byte(v>>uint(i)&1) << (7 - uint(w.pos%8))
+		w.pos++
+	}
+}
+
+func (w *meshWriter) align() { w.pos = (w.pos + 7) / 8 * 8 }
+
+// quantize is the inverse of interpolate, clamping the value
+func quantize(v Fl, bits uint8, rg [2]Fl) uint32 {
+	max := float64(uint64(1)<<bits - 1)
+	if rg[1] == rg[0] {
+		return 0
+	}
+	t := float64(v-rg[0]) / float64(rg[1]-rg[0])
+	if t < 0 {
+		t = 0
+	} else if t > 1 {
+		t = 1
+	}
+	return uint32(math.Round(t * max))
+}
+
+func (w *meshWriter) point(p [2]Fl) {
+	w.writeBits(quantize(p[0], w.bitsPerCoordinate, w.decode[0]), w.bitsPerCoordinate)
+	w.writeBits(quantize(p[1], w.bitsPerCoordinate, w.decode[1]), w.bitsPerCoordinate)
+}
+
+func (w *meshWriter) color(c []Fl) {
+	for i, rg := range w.decode[2:] {
+		var v Fl
+		if i < len(c) {
+			v = c[i]
+		}
+		w.writeBits(quantize(v, w.bitsPerComponent, rg), w.bitsPerComponent)
+	}
+}
+
+func (w *meshWriter) vertex(flag uint32, v MeshVertex) {
+	w.writeBits(flag, w.bitsPerFlag)
+	w.point([2]Fl{v.X, v.Y})
+	w.color(v.Color)
+	w.align()
+}
+
+// newMeshWriter applies the default values to the bits per value and
+// the Decode array of `ss`, which is computed from the bounds of
+// `points` and `colors` when empty.
+func (ss *ShadingStream) newMeshWriter(bitsPerFlag *uint8, points [][2]Fl, colors [][]Fl) *meshWriter {
+	if ss.BitsPerCoordinate == 0 {
+		ss.BitsPerCoordinate = defaultBitsPerCoordinate
+	}
+	if ss.BitsPerComponent == 0 {
+		ss.BitsPerComponent = defaultBitsPerComponent
+	}
+	if bitsPerFlag != nil && *bitsPerFlag == 0 {
+		*bitsPerFlag = defaultBitsPerFlag
+	}
+	if len(ss.Decode) == 0 {
+		ss.Decode = meshBounds(points, colors)
+	}
+	w := &meshWriter{decode: ss.Decode, bitsPerCoordinate: ss.BitsPerCoordinate, bitsPerComponent: ss.BitsPerComponent}
+	if bitsPerFlag != nil {
+		w.bitsPerFlag = *bitsPerFlag
+	}
+	return w
+}
+
+// meshBounds returns a Decode array containing all the values
+func meshBounds(points [][2]Fl, colors [][]Fl) [][2]Fl {
+	nbComps := 0
+	for _, c := range colors {
+		if len(c) > nbComps {
+			nbComps = len(c)
+		}
+	}
+	out := make([][2]Fl, 2+nbComps)
+	for i := range out {
+		out[i] = [2]Fl{math.MaxFloat32, -math.MaxFloat32}
+	}
+	update := func(i int, v Fl) {
+		if v < out[i][0] {
+			out[i][0] = v
+		}
+		if v > out[i][1] {
+			out[i][1] = v
+		}
+	}
+	for _, p := range points {
+		update(0, p[0])
+		update(1, p[1])
+	}
+	for _, c := range colors {
+		for i, v := range c {
+			update(2+i, v)
+		}
+	}
+	for i, rg := range out {
+		if rg[0] > rg[1] { // no values
+			out[i] = [2]Fl{0, 1}
+		}
+	}
+	return out
+}
+
+// setContent compresses the encoded data into the stream
+func (ss *ShadingStream) setContent(w *meshWriter) {
+	ss.Stream = NewCompressedStream(w.data)
+}
+
+func verticesBounds(vertices []MeshVertex) ([][2]Fl, [][]Fl) {
+	points, colors := make([][2]Fl, len(vertices)), make([][]Fl, len(vertices))
+	for i, v := range vertices {
+		points[i], colors[i] = [2]Fl{v.X, v.Y}, v.Color
+	}
+	return points, colors
+}
+
+// Triangles decodes the stream data into triangles, resolving the
+// vertices shared with the previous triangles.
+// The number of color components is deduced from the Decode array.
+func (sh ShadingFreeForm) Triangles() ([]MeshTriangle, error) {
+	if sh.BitsPerFlag == 0 || sh.BitsPerFlag > 8 {
+		return nil, newError(ErrType, "invalid BitsPerFlag for free-form shading: %d", sh.BitsPerFlag)
+	}
+	r, err := sh.ShadingStream.newMeshReader(sh.BitsPerFlag)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		out     []MeshTriangle
+		pending []MeshVertex // vertices of a new triangle
+	)
+	for r.hasMore(r.vertexBits()) {
+		flag, v := r.vertex()
+		if len(pending) != 0 { // the flag is ignored
+			pending = append(pending, v)
+			if len(pending) == 3 {
+				out = append(out, MeshTriangle{pending[0], pending[1], pending[2]})
+				pending = pending[:0]
+			}
+			continue
+		}
+		switch flag {
+		case 0:
+			pending = append(pending, v)
+		case 1, 2:
+			if len(out) == 0 {
+				return nil, errMeshData
+			}
+			prev := out[len(out)-1]
+			if flag == 1 {
+				out = append(out, MeshTriangle{prev[1], prev[2], v})
+			} else {
+				out = append(out, MeshTriangle{prev[0], prev[2], v})
+			}
+		default:
+			return nil, newError(ErrType, "invalid flag in free-form shading: %d", flag)
+		}
+	}
+	return out, nil
+}
+
+// SetTriangles encodes `triangles` in the stream, without sharing vertices.
+// Zero values of BitsPerCoordinate, BitsPerComponent and BitsPerFlag
+// are replaced by 32, 16 and 8, and an empty Decode array is computed from the data.
+func (sh *ShadingFreeForm) SetTriangles(triangles []MeshTriangle) {
+	var vertices []MeshVertex
+	for _, tr := range triangles {
+		vertices = append(vertices, tr[:]...)
+	}
+	points, colors := verticesBounds(vertices)
+	w := sh.ShadingStream.newMeshWriter(&sh.BitsPerFlag, points, colors)
+	for _, v := range vertices {
+		w.vertex(0, v)
+	}
+	sh.ShadingStream.setContent(w)
+}
+
+// Grid decodes the stream data into rows of VerticesPerRow vertices.
+// The number of color components is deduced from the Decode array.
+func (sh ShadingLattice) Grid() ([][]MeshVertex, error) {
+	if sh.VerticesPerRow < 2 {
+		return nil, newError(ErrType, "invalid VerticesPerRow for lattice-form shading: %d", sh.VerticesPerRow)
+	}
+	r, err := sh.ShadingStream.newMeshReader(0)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		out [][]MeshVertex
+		row []MeshVertex
+	)
+	for r.hasMore(r.vertexBits()) {
+		_, v := r.vertex()
+		row = append(row, v)
+		if len(row) == sh.VerticesPerRow {
+			out = append(out, row)
+			row = nil
+		}
+	}
+	return out, nil
+}
+
+// Triangles splits the grid returned by Grid into triangles.
+func (sh ShadingLattice) Triangles() ([]MeshTriangle, error) {
+	grid, err := sh.Grid()
+	if err != nil {
+		return nil, err
+	}
+	var out []MeshTriangle
+	for i := 0; i+1 < len(grid); i++ {
+		for j := 0; j+1 < len(grid[i]); j++ {
+			out = append(out,
+				MeshTriangle{grid[i][j], grid[i][j+1], grid[i+1][j]},
+				MeshTriangle{grid[i][j+1], grid[i+1][j+1], grid[i+1][j]})
+		}
+	}
+	return out, nil
+}
+
+// SetGrid encodes `rows` in the stream, and updates VerticesPerRow.
+// All the rows must have the same length.
+// Zero values of BitsPerCoordinate and BitsPerComponent
+// are replaced by 32 and 16, and an empty Decode array is computed from the data.
+func (sh *ShadingLattice) SetGrid(rows [][]MeshVertex) error {
+	var vertices []MeshVertex
+	for _, row := range rows {
+		if len(row) != len(rows[0]) {
+			return newError(ErrInvalidArgument, "the rows of a lattice-form shading must have the same length")
+		}
+		vertices = append(vertices, row...)
+	}
+	if len(rows) != 0 {
+		sh.VerticesPerRow = len(rows[0])
+	}
+	points, colors := verticesBounds(vertices)
+	w := sh.ShadingStream.newMeshWriter(nil, points, colors)
+	for _, v := range vertices {
+		w.vertex(0, v)
+	}
+	sh.ShadingStream.setContent(w)
+	return nil
+}
+
+// decodePatches is shared by Coons (12 points) and
+// tensor-product (16 points) shadings
+func (sh ShadingFreeForm) decodePatches(nbPoints int) ([]MeshPatch, error) {
+	if sh.BitsPerFlag == 0 || sh.BitsPerFlag > 8 {
+		return nil, newError(ErrType, "invalid BitsPerFlag for patch mesh shading: %d", sh.BitsPerFlag)
+	}
+	r, err := sh.ShadingStream.newMeshReader(sh.BitsPerFlag)
+	if err != nil {
+		return nil, err
+	}
+	colorBits := (len(r.decode) - 2) * int(r.bitsPerComponent)
+	var out []MeshPatch
+	for r.hasMore(int(r.bitsPerFlag)) {
+		start := r.pos
+		flag := r.readBits(r.bitsPerFlag)
+		var patch MeshPatch
+		nbImplicit := 0
+		if flag != 0 {
+			if flag > 3 || len(out) == 0 {
+				return nil, newError(ErrType, "invalid flag in patch mesh shading: %d", flag)
+			}
+			prev := out[len(out)-1]
+			// the shared edge and its colors, see Table 85 – Data values in a Coons patch mesh
+			switch flag {
+			case 1:
+				patch.Points = append(patch.Points, prev.Points[3:7]...)
+				patch.Colors[0], patch.Colors[1] = prev.Colors[1], prev.Colors[2]
+			case 2:
+				patch.Points = append(patch.Points, prev.Points[6:10]...)
+				patch.Colors[0], patch.Colors[1] = prev.Colors[2], prev.Colors[3]
+			case 3:
+				patch.Points = append(patch.Points, prev.Points[9], prev.Points[10], prev.Points[11], prev.Points[0])
+				patch.Colors[0], patch.Colors[1] = prev.Colors[3], prev.Colors[0]
+			}
+			nbImplicit = 4
+		}
+		nbColors := 4
+		if nbImplicit != 0 {
+			nbColors = 2
+		}
+		if !r.hasMore((nbPoints-nbImplicit)*2*int(r.bitsPerCoordinate) + nbColors*colorBits) {
+			r.pos = start // truncated patch
+			break
+		}
+		for i := nbImplicit; i < nbPoints; i++ {
+			patch.Points = append(patch.Points, r.point())
+		}
+		for i := 4 - nbColors; i < 4; i++ {
+			patch.Colors[i] = r.color()
+		}
+		r.align()
+		out = append(out, patch)
+	}
+	return out, nil
+}
+
+// encodePatches writes the patches, without sharing edges
+func (sh *ShadingFreeForm) encodePatches(patches []MeshPatch, nbPoints int) {
+	var (
+		points [][2]Fl
+		colors [][]Fl
+	)
+	for _, patch := range patches {
+		points = append(points, patch.Points...)
+		colors = append(colors, patch.Colors[:]...)
+	}
+	w := sh.ShadingStream.newMeshWriter(&sh.BitsPerFlag, points, colors)
+	for _, patch := range patches {
+		w.writeBits(0, w.bitsPerFlag)
+		for i := 0; i < nbPoints; i++ {
+			var p [2]Fl
+			if i < len(patch.Points) {
+				p = patch.Points[i]
+			}
+			w.point(p)
+		}
+		for _, c := range patch.Colors {
+			w.color(c)
+		}
+		w.align()
+	}
+	sh.ShadingStream.setContent(w)
+}
+
+// Patches decodes the stream data into patches of 12 points, resolving the
+// edges shared with the previous patches.
+// The number of color components is deduced from the Decode array.
+func (sh ShadingCoons) Patches() ([]MeshPatch, error) {
+	return ShadingFreeForm(sh).decodePatches(12)
+}
+
+// SetPatches encodes `patches` in the stream, without sharing edges.
+// Zero values of BitsPerCoordinate, BitsPerComponent and BitsPerFlag
+// are replaced by 32, 16 and 8, and an empty Decode array is computed from the data.
+func (sh *ShadingCoons) SetPatches(patches []MeshPatch) {
+	(*ShadingFreeForm)(sh).encodePatches(patches, 12)
+}
+
+// Patches decodes the stream data into patches of 16 points, resolving the
+// edges shared with the previous patches.
+// The number of color components is deduced from the Decode array.
+func (sh ShadingTensorProduct) Patches() ([]MeshPatch, error) {
+	return ShadingFreeForm(sh).decodePatches(16)
+}
+
+// SetPatches encodes `patches` in the stream, without sharing edges.
+// Zero values of BitsPerCoordinate, BitsPerComponent and BitsPerFlag
+// are replaced by 32, 16 and 8, and an empty Decode array is computed from the data.
+func (sh *ShadingTensorProduct) SetPatches(patches []MeshPatch) {
+	(*ShadingFreeForm)(sh).encodePatches(patches, 16)
+}
@@ -0,0 +1,176 @@
+package model
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// byteStream uses 8 bits per values, with a Decode array
+// mapping the raw bytes to themselves
+func byteStream(nbComps int, data []byte) ShadingStream {
+	decode := make([][2]Fl, 2+nbComps)
+	for i := range decode {
+		decode[i] = [2]Fl{0, 255}
+	}
+	return ShadingStream{Stream: Stream{Content: data}, BitsPerCoordinate: 8, BitsPerComponent: 8, Decode: decode}
+}
+
+func TestFreeFormTriangles(t *testing.T) {
+	sh := ShadingFreeForm{ShadingStream: byteStream(1, []byte{
+		0, 0, 0, 10,
+		0, 10, 0, 20,
+		0, 0, 10, 30,
+		1, 10, 10, 40, // (b, c, d)
+		2, 20, 20, 50, // (b, d, e)
+		9, 1, 1, // truncated, ignored
+	}), BitsPerFlag: 8}
+	got, err := sh.Triangles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := func(x, y, c Fl) MeshVertex { return MeshVertex{X: x, Y: y, Color: []Fl{c}} }
+	exp := []MeshTriangle{
+		{v(0, 0, 10), v(10, 0, 20), v(0, 10, 30)},
+		{v(10, 0, 20), v(0, 10, 30), v(10, 10, 40)},
+		{v(10, 0, 20), v(10, 10, 40), v(20, 20, 50)},
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Fatalf("expected %v, got %v", exp, got)
+	}
+
+	sh.Content[0] = 1 // no previous triangle
+	if _, err = sh.Triangles(); !errors.Is(err, ErrType) {
+		t.Fatalf("expected error for invalid flag, got %v", err)
+	}
+}
+
+func TestPackedValues(t *testing.T) {
+	// 4 bits per flag, coordinate and component: each vertex uses 2 bytes
+	// and is aligned
+	sh := ShadingLattice{ShadingStream: ShadingStream{
+		Stream:            Stream{Content: []byte{0x12, 0x30, 0xf0, 0xf0}},
+		BitsPerCoordinate: 4, BitsPerComponent: 4,
+		Decode: [][2]Fl{{0, 15}, {0, 150}, {0, 1}},
+	}, VerticesPerRow: 2}
+	grid, err := sh.Grid()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := [][]MeshVertex{{{X: 1, Y: 20, Color: []Fl{0.2}}, {X: 15, Y: 0, Color: []Fl{1}}}}
+	if !reflect.DeepEqual(grid, exp) {
+		t.Fatalf("expected %v, got %v", exp, grid)
+	}
+}
+
+func TestCoonsSharedEdge(t *testing.T) {
+	data := []byte{0}
+	for i := 0; i < 12; i++ {
+		data = append(data, byte(i), byte(i))
+	}
+	data = append(data, 1, 2, 3, 4)
+	// flag 1: 8 points and 2 colors
+	data = append(data, 1)
+	for i := 0; i < 8; i++ {
+		data = append(data, byte(100+i), byte(100+i))
+	}
+	data = append(data, 5, 6)
+	sh := ShadingCoons{ShadingStream: byteStream(1, data), BitsPerFlag: 8}
+	patches, err := sh.Patches()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patches) != 2 {
+		t.Fatalf("expected 2 patches, got %d", len(patches))
+	}
+	second := patches[1]
+	if len(second.Points) != 12 || second.Points[0] != [2]Fl{3, 3} || second.Points[3] != [2]Fl{6, 6} || second.Points[4] != [2]Fl{100, 100} {
+		t.Fatalf("unexpected points %v", second.Points)
+	}
+	if exp := [4][]Fl{{2}, {3}, {5}, {6}}; !reflect.DeepEqual(second.Colors, exp) {
+		t.Fatalf("expected %v, got %v", exp, second.Colors)
+	}
+}
+
+func closeVertices(a, b MeshVertex) bool {
+	if math.Abs(float64(a.X-b.X)) > 1e-3 || math.Abs(float64(a.Y-b.Y)) > 1e-3 || len(a.Color) != len(b.Color) {
+		return false
+	}
+	for i := range a.Color {
+		if math.Abs(float64(a.Color[i]-b.Color[i])) > 1e-3 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMeshesRoundTrip(t *testing.T) {
+	red, green, blue := []Fl{1, 0, 0}, []Fl{0, 1, 0}, []Fl{0, 0, 1}
+	triangles := []MeshTriangle{
+		{{X: 0, Y: 0, Color: red}, {X: 100, Y: 0, Color: green}, {X: 50, Y: 80.5, Color: blue}},
+		{{X: 100, Y: 0, Color: green}, {X: 50, Y: 80.5, Color: blue}, {X: 150, Y: 80, Color: red}},
+	}
+	var ff ShadingFreeForm
+	ff.SetTriangles(triangles)
+	if ff.BitsPerFlag != 8 || ff.BitsPerCoordinate != 32 || len(ff.Decode) != 5 || ff.Decode[0] != [2]Fl{0, 150} {
+		t.Fatalf("unexpected shading fields %v", ff)
+	}
+	got, err := ff.Triangles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(triangles) {
+		t.Fatalf("expected %d triangles, got %d", len(triangles), len(got))
+	}
+	for i, tr := range got {
+		for j := range tr {
+			if !closeVertices(tr[j], triangles[i][j]) {
+				t.Fatalf("expected %v, got %v", triangles[i][j], tr[j])
+			}
+		}
+	}
+
+	grid := [][]MeshVertex{
+		{{X: 0, Y: 0, Color: []Fl{0}}, {X: 10, Y: 0, Color: []Fl{0.5}}, {X: 20, Y: 0, Color: []Fl{1}}},
+		{{X: 0, Y: 10, Color: []Fl{1}}, {X: 10, Y: 10, Color: []Fl{0.5}}, {X: 20, Y: 10, Color: []Fl{0}}},
+	}
+	lattice := ShadingLattice{ShadingStream: ShadingStream{BitsPerComponent: 8}}
+	if err = lattice.SetGrid(grid); err != nil {
+		t.Fatal(err)
+	}
+	gotGrid, err := lattice.Grid()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lattice.VerticesPerRow != 3 || len(gotGrid) != 2 || !closeVertices(gotGrid[1][2], grid[1][2]) {
+		t.Fatalf("unexpected grid %v", gotGrid)
+	}
+	if trs, _ := lattice.Triangles(); len(trs) != 4 {
+		t.Fatalf("unexpected triangles %v", trs)
+	}
+	if err = lattice.SetGrid([][]MeshVertex{grid[0], grid[1][:1]}); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("expected error for invalid rows, got %v", err)
+	}
+
+	points := make([][2]Fl, 16)
+	for i := range points {
+		points[i] = [2]Fl{Fl(i), Fl(2 * i)}
+	}
+	patches := []MeshPatch{{Points: points, Colors: [4][]Fl{{0}, {0.25}, {0.5}, {1}}}}
+	var tensor ShadingTensorProduct
+	tensor.SetPatches(patches)
+	gotPatches, err := tensor.Patches()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gotPatches) != 1 || len(gotPatches[0].Points) != 16 || gotPatches[0].Points[15] != [2]Fl{15, 30} {
+		t.Fatalf("unexpected patches %v", gotPatches)
+	}
+
+	var coons ShadingCoons
+	coons.SetPatches([]MeshPatch{{Points: points[:12], Colors: patches[0].Colors}})
+	if gotPatches, _ = coons.Patches(); len(gotPatches) != 1 || len(gotPatches[0].Points) != 12 {
+		t.Fatalf("unexpected patches %v", gotPatches)
+	}
+}
@@ -15,9 +15,12 @@
 package model
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
 	"io"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -33,6 +36,18 @@ type Document struct {
 	Trailer Trailer
 	Catalog Catalog
 
+	// Theme is an optional default styling, used when generating
+	// annotations and form fields appearances. It is not written
+	// in the PDF file, and is shared (not copied) by Clone.
+	Theme *Theme
+
+	// Cache is an optional cache of decoded streams, used by DecodeStream,
+	// and to decode the forms and Type3 glyphs when rendering pages,
+	// extracting text or optimizing the document.
+	// It is not written in the PDF file, and is shared (not copied)
+	// by Clone, so that several documents may use the same memory budget.
+	Cache *StreamCache
+
 	// // UserPassword, OwnerPassword are not directly part
 	// // of the PDF document, but are used to protect (encrypt)
 	// // the contentstream.
@@ -54,28 +69,143 @@ func (doc *Document) Clone() Document {
 	return out
 }
 
+// ExtractPages returns a copy of the document, containing only the pages with
+// the given (0-based) indices, in the given order.
+// Invalid or repeated indices are ignored, and `doc` is not modified.
+// The inherited resources and media boxes are resolved.
+// The interactive form is pruned accordingly: the widgets which are
+// not found in the annotations of the extracted pages are removed, as well as the
+// fields left without widgets or kids.
+// The destinations to removed pages (such as outline destinations) are also removed:
+// see RemapPages to get the list of the removed links.
+func (doc *Document) ExtractPages(pageIndices []int) Document {
+	out := doc.Clone()
+	out.RemapPages(pageIndices)
+	return out
+}
+
 // Write walks the entire document and writes its content
 // into `output`, producing a valid PDF file.
 // `encryption` is an optional encryption dictionary,
 // returned by `UseStandardEncryptionHandler`.
 func (doc *Document) Write(output io.Writer, encryption *Encrypt) error {
+	return doc.WriteWithOptions(output, encryption, WriteOptions{})
+}
+
+// WriteOptions provides low-level control on the file structure
+// produced by `WriteWithOptions`.
+type WriteOptions struct {
+	// XRefStream writes the cross-reference section as a
+	// compressed stream (PDF 1.5) instead of a classic table.
+	XRefStream bool
+
+	// TrailerHook, if not nil, is called before writing the
+	// cross-reference section, and returns additional trailer entries,
+	// which take precedence over `Trailer.Custom`.
+	// `w` may be used to create indirect objects. Note that
+	// strings in the trailer are never encrypted.
+	// The entries describing the file structure (Size, Root, Info, Encrypt, ID, etc...)
+	// are ignored.
+	TrailerHook func(w PDFWritter) map[Name]string
+
+	// StripUsageRights removes the usage rights signature (UR3) of
+	// "Reader extended" documents, which is otherwise written back, but invalid.
+	StripUsageRights bool
+
+	// SyncMetadata makes the Info dictionary and the XMP metadata consistent:
+	// the missing Info entries are filled from the XMP packet,
+	// which is then updated (or created) from the Info entries.
+	// The other XMP properties are preserved.
+	SyncMetadata bool
+
+	// ObjectNumbers, if not nil, gives the object numbers to use
+	// for the shared objects implementing Referenceable (fonts, images, forms,
+	// annotations, file specifications, etc...), such as the ones recorded when
+	// reading the document (see reader.Options.ObjectNumbers).
+	// Other objects are numbered after the largest preserved number, and the
+	// unused numbers are written as free entries.
+	// Note that the objects which are not Referenceable, such as the catalog,
+	// the page tree nodes, the content streams, the outline items, the form fields
+	// and the structure elements, are not preserved: they are renumbered on each
+	// write, so that only the numbers of the shared objects are stable between rewrites.
+	ObjectNumbers map[Referenceable]Reference
+
+	// BalancePageTree, if positive, writes the pages in a balanced tree,
+	// with at most BalancePageTree kids per node (see PageTree.Balance).
+	// The page tree of the document is not modified, but the inherited
+	// resources and media boxes are resolved and set on the page objects.
+	BalancePageTree int
+
+	// TreeShape is the shape of the name trees represented as flat lists
+	// (like EmbeddedFileTree), which are split when written.
+	// It is also used to rebalance the Dests tree when one of its nodes has more than
+	// MaxKids kids, one of its leaves more than MaxEntries entries, or when it is deeper
+	// than MaxDepth (or 32 if MaxDepth is zero).
+	// The zero value is a sensible default.
+	TreeShape TreeShape
+
+	// Logger, if not nil, receives the warnings emitted while writing,
+	// instead of the default logger (see SetLogger).
+	Logger Logger
+}
+
+// WriteWithOptions is the same as `Write`, with additional control
+// on the cross-reference section and trailer.
+func (doc *Document) WriteWithOptions(output io.Writer, encryption *Encrypt, options WriteOptions) error {
+	// the objects are written with many small writes
+	var bw *bufio.Writer
+	if _, isBuffer := output.(*bytes.Buffer); !isBuffer {
+		bw = bufio.NewWriterSize(output, 64*1024)
+		output = bw
+	}
 	wr := newWriter(output, encryption)
+	wr.treeShape = options.TreeShape
+	if options.ObjectNumbers != nil {
+		wr.preserveNumbers(options.ObjectNumbers)
+	}
 
 	wr.writeHeader()
 
-	doc.Catalog.setupWriter(&wr)
-	wr.WriteObject(doc.Catalog.pdfString(wr), wr.catalog)
+	catalog := doc.Catalog // shallow copy, to strip usage rights, sync metadata and balance the pages
+	if options.BalancePageTree > 0 {
+		catalog.Pages.Balance(options.BalancePageTree)
+	}
+	if catalog.HasUsageRights() {
+		if options.StripUsageRights {
+			catalog.Perms = &Perms{DocMDP: catalog.Perms.DocMDP}
+			if catalog.Perms.DocMDP == nil {
+				catalog.Perms = nil
+			}
+		} else {
+			LoggerOrDefault(options.Logger).Log(LogWarning, "writing a document with usage rights, which will be invalid: see WriteOptions.StripUsageRights")
+		}
+	}
+
+	trailer := doc.Trailer // shallow copy, to sync metadata
+	if options.SyncMetadata {
+		var err error
+		trailer.Info, catalog.Metadata, err = syncMetadata(trailer.Info, catalog.Metadata)
+		if err != nil {
+			return err
+		}
+	}
+
+	catalog.setupWriter(&wr)
+	wr.WriteObject(catalog.pdfString(wr), wr.catalog)
 
 	info := wr.CreateObject()
-	wr.WriteObject(doc.Trailer.Info.pdfString(wr, info), info)
+	wr.WriteObject(trailer.Info.pdfString(wr, info), info)
 
 	var encRef Reference
 	if encryption != nil {
 		encRef = wr.addObject(encryption.pdfString())
 	}
 
-	wr.writeFooter(doc.Trailer, wr.catalog, info, encRef)
+	wr.writeFooter(trailer, wr.catalog, info, encRef, options)
 
+	if wr.err == nil && bw != nil {
+		wr.err = bw.Flush()
+	}
 	return wr.err
 }
 
@@ -84,7 +214,7 @@ func (doc *Document) Write(output io.Writer, encryption *Encrypt) error {
 func (doc *Document) WriteFile(filename string, encryption *Encrypt) error {
 	f, err := os.Create(filename)
 	if err != nil {
-		return fmt.Errorf("can't create PDF file output: %s", err)
+		return fmt.Errorf("can't create PDF file output: %w", err)
 	}
 	err = doc.Write(f, encryption)
 	if err != nil {
@@ -92,7 +222,7 @@ func (doc *Document) WriteFile(filename string, encryption *Encrypt) error {
 	}
 	err = f.Close()
 	if err != nil {
-		return fmt.Errorf("can't close PDF file output: %s", err)
+		return fmt.Errorf("can't close PDF file output: %w", err)
 	}
 	return nil
 }
@@ -116,8 +246,34 @@ type Catalog struct {
 	// optional. A simple GoTo action to a direct destination
 	// may be found as an array in a PDF file.
 	OpenAction Action
-	URI        string // optional, ASCII string, written in PDF as a dictionary
+	AA         DocumentAdditionalActions // optional
+	URI        string                    // optional, ASCII string, written in PDF as a dictionary
 	Lang       string
+
+	Metadata      *MetadataStream // optional, XMP metadata of the document
+	OutputIntents []OutputIntent  // optional
+
+	OCProperties *OptionalContentProperties // optional, required if the document uses optional content
+
+	Perms *Perms // optional
+
+	Collection *Collection // optional, for portable collections (portfolios)
+
+	// AF are the files associated with the whole document (PDF 2.0, PDF/A-3),
+	// such as the XML data of an electronic invoice.
+	// See Document.AddAssociatedFile.
+	AF []*FileSpec // optional
+
+	// Custom stores the additional entries, such as
+	// private keys, which are written as is.
+	// It is filled by the reader with the unknown entries of the source file.
+	Custom ObjDict // optional
+}
+
+// HasUsageRights returns true for "Reader extended" documents,
+// that is documents with a usage rights signature.
+func (cat *Catalog) HasUsageRights() bool {
+	return cat.Perms != nil && cat.Perms.UR3 != nil
 }
 
 func (cat *Catalog) setupWriter(pdf *pdfWriter) {
@@ -196,9 +352,35 @@ func (cat *Catalog) pdfString(pdf pdfWriter) string {
 	if cat.OpenAction.ActionType != nil {
 		b.line("/OpenAction %s", cat.OpenAction.pdfString(pdf, pdf.catalog))
 	}
+	if !cat.AA.IsEmpty() {
+		b.line("/AA %s", cat.AA.pdfString(pdf, pdf.catalog))
+	}
 	if cat.Lang != "" {
 		b.fmt("/Lang " + pdf.EncodeString(cat.Lang, TextString, pdf.catalog))
 	}
+	if cat.Metadata != nil {
+		b.line("/Metadata %s", cat.Metadata.Write(pdf, pdf.catalog))
+	}
+	if oc := cat.OCProperties; oc != nil {
+		b.line("/OCProperties %s", oc.pdfString(pdf, pdf.catalog))
+	}
+	if cat.Perms != nil {
+		b.line("/Perms %s", cat.Perms.pdfString(pdf))
+	}
+	if cat.Collection != nil {
+		b.line("/Collection %s", cat.Collection.pdfString(pdf, pdf.catalog))
+	}
+	if len(cat.AF) != 0 {
+		b.line("/AF %s", writeFileSpecArray(pdf, cat.AF))
+	}
+	if len(cat.OutputIntents) != 0 {
+		chunks := make([]string, len(cat.OutputIntents))
+		for i, o := range cat.OutputIntents {
+			chunks[i] = o.pdfString(pdf, pdf.catalog)
+		}
+		b.line("/OutputIntents [%s]", strings.Join(chunks, " "))
+	}
+	b.custom(pdf, cat.Custom, pdf.catalog)
 	b.fmt(">>")
 
 	return b.String()
@@ -250,10 +432,7 @@ func (cat Catalog) Clone() Catalog {
 	outPage := cat.Pages.clone(cache).(*PageTree)
 	out.Pages = *outPage
 	out.Names = cat.Names.clone(cache)
-	if cat.ViewerPreferences != nil {
-		v := *cat.ViewerPreferences
-		out.ViewerPreferences = &v
-	}
+	out.ViewerPreferences = cat.ViewerPreferences.clone()
 	out.AcroForm = cat.AcroForm.clone(cache)
 	if cat.Dests != nil {
 		out.Dests = make(map[Name]DestinationExplicit, len(cat.Dests))
@@ -273,6 +452,22 @@ func (cat Catalog) Clone() Catalog {
 		out.MarkInfo = &m
 	}
 	out.OpenAction = cat.OpenAction.clone(cache)
+	out.AA = cat.AA.clone(cache)
+	if cat.Metadata != nil {
+		m := cat.Metadata.Clone().(MetadataStream)
+		out.Metadata = &m
+	}
+	out.OCProperties = cat.OCProperties.clone(cache)
+	out.Perms = cat.Perms.clone(cache)
+	out.Collection = cat.Collection.clone()
+	out.AF = cloneFileSpecArray(cat.AF, cache)
+	out.Custom = cloneCustom(cat.Custom)
+	if cat.OutputIntents != nil {
+		out.OutputIntents = make([]OutputIntent, len(cat.OutputIntents))
+		for i, o := range cat.OutputIntents {
+			out.OutputIntents[i] = o.clone(cache)
+		}
+	}
 	return out
 }
 
@@ -285,12 +480,15 @@ type NameDictionary struct {
 	AP            AppearanceTree
 	Pages         TemplateTree
 	Templates     TemplateTree
+	JavaScript    JavaScriptTree // document-level scripts
 }
 
 func (n NameDictionary) pdfString(pdf pdfWriter) string {
 	b := newBuffer()
 	b.WriteString("<<")
 	if dests := n.Dests; !dests.IsEmpty() {
+		// avoid trees too large or too deep for readers
+		dests = dests.balanceIfNeeded(pdf.treeShape)
 		ref := pdf.CreateObject()
 		pdf.WriteObject(dests.pdfString(pdf, ref), ref)
 		b.fmt("/Dests %s", ref)
@@ -315,6 +513,11 @@ func (n NameDictionary) pdfString(pdf pdfWriter) string {
 		pdf.WriteObject(templates.pdfString(pdf, ref), ref)
 		b.fmt("/Templates %s", ref)
 	}
+	if js := n.JavaScript; len(js) != 0 {
+		ref := pdf.CreateObject()
+		pdf.WriteObject(js.pdfString(pdf, ref), ref)
+		b.fmt("/JavaScript %s", ref)
+	}
 	b.WriteString(">>")
 	return b.String()
 }
@@ -326,18 +529,38 @@ func (n NameDictionary) clone(cache cloneCache) NameDictionary {
 	out.AP = n.AP.clone(cache)
 	out.Pages = n.Pages.clone(cache)
 	out.Templates = n.Templates.clone(cache)
+	out.JavaScript = n.JavaScript.clone(cache)
 	return out
 }
 
 // ViewerPreferences specifies the way the document shall be
-// displayed on the screen.
-// TODO: ViewerPreferences extend the fields
+// displayed on the screen, or printed.
+// See Table 150 – Entries in a viewer preferences dictionary
 type ViewerPreferences struct {
-	FitWindow    bool
-	CenterWindow bool
+	HideToolbar     bool // optional
+	HideMenubar     bool // optional
+	HideWindowUI    bool // optional
+	FitWindow       bool
+	CenterWindow    bool
+	DisplayDocTitle bool // optional
+	// optional, page mode used when exiting full screen mode
+	// one of UseNone (default), UseOutlines, UseThumbs, UseOC
+	NonFullScreenPageMode Name
 	// right to left: determine the relative positioning
 	// of pages when displayed side by side or printed n-up
 	DirectionRTL bool
+
+	// optional, page boundaries (such as CropBox) used when
+	// viewing or printing
+	ViewArea, ViewClip, PrintArea, PrintClip Name
+
+	PrintScaling      Name      // optional, one of None, AppDefault (default)
+	Duplex            Name      // optional, one of Simplex, DuplexFlipShortEdge, DuplexFlipLongEdge
+	PickTrayByPDFSize MaybeBool // optional
+	// optional, pairs of (1-based) first and last pages of
+	// the ranges to print
+	PrintPageRange []int
+	NumCopies      int // optional, ignored if 0
 }
 
 func (p ViewerPreferences) pdfString(pdf pdfWriter) string {
@@ -345,7 +568,148 @@ func (p ViewerPreferences) pdfString(pdf pdfWriter) string {
 	if p.DirectionRTL {
 		direction = "R2L"
 	}
-	return fmt.Sprintf("<</FitWindow %v /CenterWindow %v /Direction %s>>", p.FitWindow, p.CenterWindow, direction)
+	b := newBuffer()
+	b.fmt("<</FitWindow %v /CenterWindow %v /Direction %s", p.FitWindow, p.CenterWindow, direction)
+	if p.HideToolbar {
+		b.fmt("/HideToolbar true")
+	}
+	if p.HideMenubar {
+		b.fmt("/HideMenubar true")
+	}
+	if p.HideWindowUI {
+		b.fmt("/HideWindowUI true")
+	}
+	if p.DisplayDocTitle {
+		b.fmt("/DisplayDocTitle true")
+	}
+	if p.NonFullScreenPageMode != "" {
+		b.fmt("/NonFullScreenPageMode %s", p.NonFullScreenPageMode)
+	}
+	for _, area := range [...]struct {
+		key   string
+		value Name
+	}{{"ViewArea", p.ViewArea}, {"ViewClip", p.ViewClip}, {"PrintArea", p.PrintArea}, {"PrintClip", p.PrintClip}} {
+		if area.value != "" {
+			b.fmt("/%s %s", area.key, area.value)
+		}
+	}
+	if p.PrintScaling != "" {
+		b.fmt("/PrintScaling %s", p.PrintScaling)
+	}
+	if p.Duplex != "" {
+		b.fmt("/Duplex %s", p.Duplex)
+	}
+	if pick, ok := p.PickTrayByPDFSize.(ObjBool); ok {
+		b.fmt("/PickTrayByPDFSize %v", pick)
+	}
+	if len(p.PrintPageRange) != 0 {
+		b.fmt("/PrintPageRange %s", writeIntArray(p.PrintPageRange))
+	}
+	if p.NumCopies != 0 {
+		b.fmt("/NumCopies %d", p.NumCopies)
+	}
+	b.fmt(">>")
+	return b.String()
+}
+
+func (p *ViewerPreferences) clone() *ViewerPreferences {
+	if p == nil {
+		return nil
+	}
+	out := *p
+	out.PrintPageRange = append([]int(nil), p.PrintPageRange...)
+	return &out
+}
+
+// Page layouts, used as Catalog.PageLayout
+const (
+	PageLayoutSinglePage     Name = "SinglePage"     // one page at a time (default)
+	PageLayoutOneColumn      Name = "OneColumn"      // the pages in one column
+	PageLayoutTwoColumnLeft  Name = "TwoColumnLeft"  // the pages in two columns, odd-numbered pages on the left
+	PageLayoutTwoColumnRight Name = "TwoColumnRight" // the pages in two columns, odd-numbered pages on the right
+	PageLayoutTwoPageLeft    Name = "TwoPageLeft"    // two pages at a time, odd-numbered pages on the left
+	PageLayoutTwoPageRight   Name = "TwoPageRight"   // two pages at a time, odd-numbered pages on the right
+)
+
+// Page modes, used as Catalog.PageMode
+const (
+	PageModeUseNone        Name = "UseNone"        // neither outline nor thumbnail visible (default)
+	PageModeUseOutlines    Name = "UseOutlines"    // outline visible
+	PageModeUseThumbs      Name = "UseThumbs"      // thumbnail images visible
+	PageModeFullScreen     Name = "FullScreen"     // full screen mode
+	PageModeUseOC          Name = "UseOC"          // optional content group panel visible
+	PageModeUseAttachments Name = "UseAttachments" // attachments panel visible
+)
+
+// SetPageLayout sets the page layout used when the document is opened,
+// one of the PageLayoutXXX constants.
+func (doc *Document) SetPageLayout(layout Name) { doc.Catalog.PageLayout = layout }
+
+// SetPageMode sets how the document is displayed when opened,
+// one of the PageModeXXX constants.
+func (doc *Document) SetPageMode(mode Name) { doc.Catalog.PageMode = mode }
+
+// SetViewerPreferences stores a copy of `prefs` in the catalog.
+func (doc *Document) SetViewerPreferences(prefs ViewerPreferences) {
+	doc.Catalog.ViewerPreferences = prefs.clone()
+}
+
+// OutputIntent describes the final destination device
+// of the document, used to reproduce its colors (see 14.11.5 - Output Intents).
+type OutputIntent struct {
+	S                         Name                // required, GTS_PDFA1 for PDF/A, GTS_PDFX for PDF/X
+	OutputCondition           string              // optional, text string
+	OutputConditionIdentifier string              // required, text string
+	RegistryName              string              // optional, text string
+	Info                      string              // required if OutputConditionIdentifier is not a standard condition, text string
+	DestOutputProfile         *ColorSpaceICCBased // required if OutputConditionIdentifier is not a standard condition
+}
+
+func (o OutputIntent) pdfString(pdf pdfWriter, context Reference) string {
+	b := newBuffer()
+	b.fmt("<</Type/OutputIntent/S %s", o.S)
+	if o.OutputCondition != "" {
+		b.fmt("/OutputCondition %s", pdf.EncodeString(o.OutputCondition, TextString, context))
+	}
+	b.fmt("/OutputConditionIdentifier %s", pdf.EncodeString(o.OutputConditionIdentifier, TextString, context))
+	if o.RegistryName != "" {
+		b.fmt("/RegistryName %s", pdf.EncodeString(o.RegistryName, TextString, context))
+	}
+	if o.Info != "" {
+		b.fmt("/Info %s", pdf.EncodeString(o.Info, TextString, context))
+	}
+	if o.DestOutputProfile != nil {
+		ref := pdf.addItem(o.DestOutputProfile)
+		b.fmt("/DestOutputProfile %s", ref)
+	}
+	b.fmt(">>")
+	return b.String()
+}
+
+// Output intent subtypes
+const (
+	OutputIntentPDFX Name = "GTS_PDFX"
+	OutputIntentPDFA Name = "GTS_PDFA1"
+)
+
+// NewOutputIntent returns an output intent with subtype `s` (such as OutputIntentPDFX),
+// embedding the ICC `profile`, which has `n` color components.
+// `condition` identifies the profile, and is used as OutputConditionIdentifier and Info.
+func NewOutputIntent(s Name, condition string, profile []byte, n int) OutputIntent {
+	return OutputIntent{
+		S:                         s,
+		OutputConditionIdentifier: condition,
+		Info:                      condition,
+		DestOutputProfile:         &ColorSpaceICCBased{Stream: NewCompressedStream(profile), N: n},
+	}
+}
+
+func (o OutputIntent) clone(cache cloneCache) OutputIntent {
+	out := o
+	if o.DestOutputProfile != nil {
+		out.DestOutputProfile = cache.checkOrClone(o.DestOutputProfile).(*ColorSpaceICCBased)
+	}
+	return out
 }
 
 type Trailer struct {
@@ -353,11 +717,17 @@ type Trailer struct {
 	// Encrypt Encrypt
 	Info Info
 	ID   [2]string // optional (must be not crypted, direct objects)
+
+	// Custom stores the additional entries, such as
+	// private keys, which are written as is (without encryption).
+	// It is filled by the reader with the unknown entries of the source file.
+	Custom ObjDict // optional
 }
 
 func (t Trailer) Clone() Trailer {
 	out := t
 	// out.Encrypt = t.Encrypt.Clone()
+	out.Custom = cloneCustom(t.Custom)
 	return out
 }
 
@@ -90,3 +90,148 @@ func TestOpenAction(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestActionChain(t *testing.T) {
+	js := func(code string) Action { return Action{ActionType: ActionJavaScript{JS: code}} }
+	first := js("1")
+	first.Next = []Action{js("2"), js("4")}
+	first.Next[0].Next = []Action{js("3")}
+
+	var codes []string
+	for _, ac := range first.Chain() {
+		codes = append(codes, ac.ActionType.(ActionJavaScript).JS)
+	}
+	if exp := []string{"1", "2", "3", "4"}; !reflect.DeepEqual(codes, exp) {
+		t.Fatalf("expected %v, got %v", exp, codes)
+	}
+
+	var visited int
+	completed := first.Walk(func(ac Action) bool {
+		visited++
+		return ac.ActionType.(ActionJavaScript).JS != "2"
+	})
+	if completed || visited != 2 {
+		t.Fatalf("unexpected interrupted walk: %v %d", completed, visited)
+	}
+
+	if clone := first.clone(newCloneCache()); !reflect.DeepEqual(clone, first) {
+		t.Fatalf("expected %v, got %v", first, clone)
+	}
+}
+
+func TestScripts(t *testing.T) {
+	js := func(code string) Action { return Action{ActionType: ActionJavaScript{JS: code}} }
+
+	var doc Document
+	doc.Catalog.OpenAction = js("open")
+	doc.Catalog.OpenAction.Next = []Action{{ActionType: ActionNamed("NextPage")}, js("open2")}
+	doc.Catalog.AA.WC = js("close")
+	doc.Catalog.Names.JavaScript = JavaScriptTree{{Name: "init", Action: js("init")}}
+
+	widget := &AnnotationDict{Subtype: AnnotationWidget{AA: AnnotationAdditionalActions{Fo: js("focus")}}}
+	link := &AnnotationDict{Subtype: AnnotationLink{A: Action{ActionType: ActionURI{URI: "http://example.com"}}}}
+	page := &PageObject{Annots: []*AnnotationDict{link, widget}, AA: PageAdditionalActions{O: js("page")}}
+	doc.Catalog.Pages.Kids = []PageNode{page}
+	doc.Catalog.AcroForm.Fields = []*FormFieldDict{{
+		T:       "field",
+		AA:      FormFielAdditionalActions{K: js("keystroke")},
+		Widgets: []FormFieldWidget{{AnnotationDict: widget}},
+	}}
+
+	scripts := doc.Scripts()
+	exp := []JavaScriptCode{
+		{"OpenAction", "open"},
+		{"OpenAction", "open2"},
+		{"AA/WC", "close"},
+		{"Names/JavaScript/init", "init"},
+		{"Pages[0]/AA/O", "page"},
+		{"Pages[0]/Annots[1]/AA/Fo", "focus"},
+		{"AcroForm/field/AA/K", "keystroke"},
+	}
+	if !reflect.DeepEqual(scripts, exp) {
+		t.Fatalf("expected %v, got %v", exp, scripts)
+	}
+
+	n := doc.ReplaceScripts(func(location, js string) (string, bool) {
+		if location == "AA/WC" {
+			return "app.alert('bye');", true
+		}
+		return js, true
+	})
+	if n != 1 || doc.Catalog.AA.WC.ActionType.(ActionJavaScript).JS != "app.alert('bye');" {
+		t.Fatalf("unexpected replacement: %d %v", n, doc.Catalog.AA.WC)
+	}
+
+	if n := doc.StripScripts(); n != len(exp) {
+		t.Fatalf("expected %d removed scripts, got %d", len(exp), n)
+	}
+	if scripts := doc.Scripts(); len(scripts) != 0 {
+		t.Fatalf("unexpected scripts %v", scripts)
+	}
+	// non JavaScript actions are preserved
+	if exp := (Action{ActionType: ActionNamed("NextPage")}); !reflect.DeepEqual(doc.Catalog.OpenAction, exp) {
+		t.Fatalf("expected %v, got %v", exp, doc.Catalog.OpenAction)
+	}
+	if doc.Catalog.Names.JavaScript != nil {
+		t.Fatalf("unexpected document scripts %v", doc.Catalog.Names.JavaScript)
+	}
+	if _, ok := link.Subtype.(AnnotationLink).A.ActionType.(ActionURI); !ok {
+		t.Fatal("link action should be preserved")
+	}
+
+	var b bytes.Buffer
+	if err := doc.Write(&b, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestApplyTheme(t *testing.T) {
+	font := &FontDict{Subtype: FontType1{BaseFont: "Helvetica"}}
+	doc := Document{Theme: &Theme{
+		Font:           font,
+		FontSize:       10,
+		TextColor:      Color{0.5},
+		BorderColor:    Color{1, 0, 0},
+		Border:         &BorderStyle{W: ObjFloat(2), S: "D"},
+		HighlightColor: Color{1, 1, 0},
+	}}
+
+	link := &AnnotationDict{Subtype: AnnotationLink{}}
+	doc.ApplyTheme(link)
+	if bs := link.Subtype.(AnnotationLink).BS; bs == nil || bs.S != "D" || bs == doc.Theme.Border {
+		t.Fatalf("unexpected border style %v", bs)
+	}
+	if !reflect.DeepEqual(link.C, []Fl{1, 0, 0}) {
+		t.Fatalf("unexpected color %v", link.C)
+	}
+
+	highlight := &AnnotationDict{BaseAnnotation: BaseAnnotation{C: []Fl{0, 1, 0}}, Subtype: AnnotationHighlight{}}
+	doc.ApplyTheme(highlight)
+	if !reflect.DeepEqual(highlight.C, []Fl{0, 1, 0}) { // explicit color is preserved
+		t.Fatalf("unexpected color %v", highlight.C)
+	}
+	underline := &AnnotationDict{Subtype: AnnotationUnderline{}}
+	doc.ApplyTheme(underline)
+	if !reflect.DeepEqual(underline.C, []Fl{1, 1, 0}) {
+		t.Fatalf("unexpected color %v", underline.C)
+	}
+
+	freeText := &AnnotationDict{Subtype: AnnotationFreeText{}}
+	doc.ApplyTheme(freeText)
+	if da := freeText.Subtype.(AnnotationFreeText).DA; da != "/ThemeFont 10 Tf 0.5 g" {
+		t.Fatalf("unexpected DA %s", da)
+	}
+	if doc.Catalog.AcroForm.DR.Font["ThemeFont"] != font {
+		t.Fatal("theme font should be registered in the form resources")
+	}
+	// the font is registered once
+	doc.ApplyTheme(&AnnotationDict{Subtype: AnnotationFreeText{}})
+	if len(doc.Catalog.AcroForm.DR.Font) != 1 {
+		t.Fatalf("unexpected resources %v", doc.Catalog.AcroForm.DR.Font)
+	}
+	// the resource name is not already used
+	doc.Catalog.AcroForm.DR.Font["ThemeFont"] = &FontDict{}
+	if name := doc.themeFont(); name != "ThemeFont1" {
+		t.Fatalf("unexpected font name %s", name)
+	}
+}
@@ -569,3 +569,131 @@ func (m *MediaScreenFloatingWindow) Clone() *MediaScreenFloatingWindow {
 	out.TT = m.TT.Clone()
 	return &out
 }
+
+// SoundStream is a stream containing sample values that define a sound.
+// See 13.3 - Sounds
+type SoundStream struct {
+	Stream
+
+	R  Fl   // required, sampling rate, in samples per second
+	C  int  // optional, number of sound channels, default to 1
+	B  int  // optional, number of bits per sample value per channel, default to 8
+	E  Name // optional, encoding format for the sample data, default to Raw
+	CO Name // optional, sound compression format
+}
+
+func (s *SoundStream) pdfContent(pdf pdfWriter, ref Reference) (StreamHeader, string, []byte) {
+	args := s.PDFCommonFields(true)
+	args.Fields["Type"] = "/Sound"
+	args.Fields["R"] = FmtFloat(s.R)
+	if s.C != 0 {
+		args.Fields["C"] = fmt.Sprintf("%d", s.C)
+	}
+	if s.B != 0 {
+		args.Fields["B"] = fmt.Sprintf("%d", s.B)
+	}
+	if s.E != "" {
+		args.Fields["E"] = s.E.String()
+	}
+	if s.CO != "" {
+		args.Fields["CO"] = s.CO.String()
+	}
+	return args, "", s.Content
+}
+
+// clone returns a deep copy, with concrete type `*SoundStream`
+func (s *SoundStream) clone(cloneCache) Referenceable {
+	if s == nil {
+		return s
+	}
+	out := *s
+	out.Stream = s.Stream.Clone()
+	return &out
+}
+
+// Movie specifies a movie file, used in a movie annotation.
+// See 13.4 - Movies
+type Movie struct {
+	F      *FileSpec // required
+	Aspect [2]int    // optional, width and height of the movie’s bounding box, in pixels
+	Rotate int       // optional, multiple of 90
+	// Poster is either a boolean (true to retrieve the poster image from the movie file)
+	// or a stream containing the image.
+	// Optional
+	Poster       bool
+	PosterStream *XObjectImage
+}
+
+func (m Movie) pdfString(pdf pdfWriter, ref Reference) string {
+	b := newBuffer()
+	b.WriteString("<<")
+	if m.F != nil {
+		b.fmt("/F %s", pdf.addItem(m.F))
+	}
+	if m.Aspect != ([2]int{}) {
+		b.fmt("/Aspect [%d %d]", m.Aspect[0], m.Aspect[1])
+	}
+	if m.Rotate != 0 {
+		b.fmt("/Rotate %d", m.Rotate)
+	}
+	if m.PosterStream != nil {
+		b.fmt("/Poster %s", pdf.addItem(m.PosterStream))
+	} else if m.Poster {
+		b.WriteString("/Poster true")
+	}
+	b.WriteString(">>")
+	return b.String()
+}
+
+func (m Movie) clone(cache cloneCache) Movie {
+	out := m
+	if m.F != nil {
+		out.F = cache.checkOrClone(m.F).(*FileSpec)
+	}
+	if m.PosterStream != nil {
+		out.PosterStream = cache.checkOrClone(m.PosterStream).(*XObjectImage)
+	}
+	return out
+}
+
+// MovieActivation specifies how a movie is played.
+// See Table 296 – Entries in a movie activation dictionary
+type MovieActivation struct {
+	Rate         Fl     // optional, default to 1
+	Volume       Fl     // optional, default to 1
+	ShowControls bool   // optional
+	Mode         Name   // optional, one of Once, Open, Repeat, Palindrome
+	Synchronous  bool   // optional
+	FWScale      [2]int // optional, floating window magnification
+	FWPosition   [2]Fl  // optional, floating window position, default to [0.5 0.5]
+	// TODO: support Start and Duration
+}
+
+// String returns the PDF dictionary.
+func (m MovieActivation) String() string {
+	b := newBuffer()
+	b.WriteString("<<")
+	if m.Rate != 0 {
+		b.fmt("/Rate %s", FmtFloat(m.Rate))
+	}
+	if m.Volume != 0 {
+		b.fmt("/Volume %s", FmtFloat(m.Volume))
+	}
+	if m.ShowControls {
+		b.WriteString("/ShowControls true")
+	}
+	if m.Mode != "" {
+		b.fmt("/Mode %s", m.Mode)
+	}
+	if m.Synchronous {
+		b.WriteString("/Synchronous true")
+	}
+	if m.FWScale != ([2]int{}) {
+		b.fmt("/FWScale [%d %d]", m.FWScale[0], m.FWScale[1])
+	}
+	if m.FWPosition != ([2]Fl{}) {
+		b.fmt("/FWPosition %s", writeFloatArray(m.FWPosition[:]))
+	}
+	b.WriteString(">>")
+	return b.String()
+}
@@ -0,0 +1,113 @@
+package model
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// ToNative converts `o` (and its children) to Go native types,
+// which may be easier to process for open-ended objects
+// such as property lists or signature build properties.
+// The following rules are used:
+//   - ObjDict is converted to map[string]interface{}
+//   - ObjArray is converted to []interface{}
+//   - ObjName is converted to Name, so that names and strings stay distinct
+//   - ObjStringLiteral and ObjHexLiteral are converted to string
+//     (text strings are not decoded)
+//   - ObjInt is converted to int, ObjFloat to Fl and ObjBool to bool
+//   - ObjNull is converted to nil
+//   - the other objects (indirect references, streams or custom types) are returned as it is
+func ToNative(o Object) interface{} {
+	switch o := o.(type) {
+	case ObjDict:
+		out := make(map[string]interface{}, len(o))
+		for k, v := range o {
+			out[string(k)] = ToNative(v)
+		}
+		return out
+	case ObjArray:
+		out := make([]interface{}, len(o))
+		for i, v := range o {
+			out[i] = ToNative(v)
+		}
+		return out
+	case ObjName:
+		return Name(o)
+	case ObjStringLiteral:
+		return string(o)
+	case ObjHexLiteral:
+		return string(o)
+	case ObjInt:
+		return int(o)
+	case ObjFloat:
+		return Fl(o)
+	case ObjBool:
+		return bool(o)
+	case ObjNull, nil:
+		return nil
+	default:
+		return o
+	}
+}
+
+// FromNative is the inverse of ToNative, building a PDF object from `v`.
+// The following rules are used:
+//   - nil is converted to ObjNull
+//   - Object values (including Name) are returned as it is
+//   - string is converted to ObjStringLiteral, and []byte to ObjHexLiteral
+//   - bool is converted to ObjBool
+//   - integers are converted to ObjInt and floats to ObjFloat
+//   - slices and arrays are converted to ObjArray
+//   - maps with string keys are converted to ObjDict
+//
+// An error is returned for other types.
+func FromNative(v interface{}) (Object, error) {
+	switch v := v.(type) {
+	case nil:
+		return ObjNull{}, nil
+	case Object:
+		return v, nil
+	case string:
+		return ObjStringLiteral(v), nil
+	case []byte:
+		return ObjHexLiteral(v), nil
+	case bool:
+		return ObjBool(v), nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return ObjInt(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return ObjInt(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return ObjFloat(rv.Float()), nil
+	case reflect.Slice, reflect.Array:
+		out := make(ObjArray, rv.Len())
+		for i := range out {
+			item, err := FromNative(rv.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			out[i] = item
+		}
+		return out, nil
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, ErrUnsupportedFeature{Feature: fmt.Sprintf("map key type %s", rv.Type().Key())}
+		}
+		out := make(ObjDict, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			item, err := FromNative(iter.Value().Interface())
+			if err != nil {
+				return nil, err
+			}
+			out[Name(iter.Key().String())] = item
+		}
+		return out, nil
+	default:
+		return nil, ErrUnsupportedFeature{Feature: fmt.Sprintf("type %T", v)}
+	}
+}
@@ -0,0 +1,60 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNative(t *testing.T) {
+	obj := ObjDict{
+		"Name":       ObjName("Adobe.PPKLite"),
+		"Date":       ObjStringLiteral("D:20200101"),
+		"Hex":        ObjHexLiteral("\x01\x02"),
+		"R":          ObjInt(131104),
+		"Scale":      ObjFloat(0.5),
+		"PreRelease": ObjBool(false),
+		"Null":       ObjNull{},
+		"Ref":        ObjIndirectRef{ObjectNumber: 4},
+		"Kids":       ObjArray{ObjInt(1), ObjArray{ObjName("A")}, ObjDict{"V": ObjStringLiteral("x")}},
+	}
+	native := ToNative(obj)
+	expected := map[string]interface{}{
+		"Name":       Name("Adobe.PPKLite"),
+		"Date":       "D:20200101",
+		"Hex":        "\x01\x02",
+		"R":          131104,
+		"Scale":      Fl(0.5),
+		"PreRelease": false,
+		"Null":       nil,
+		"Ref":        ObjIndirectRef{ObjectNumber: 4},
+		"Kids":       []interface{}{1, []interface{}{Name("A")}, map[string]interface{}{"V": "x"}},
+	}
+	if !reflect.DeepEqual(native, expected) {
+		t.Fatalf("expected %v, got %v", expected, native)
+	}
+
+	back, err := FromNative(native)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// hex strings are converted to regular strings
+	obj["Hex"] = ObjStringLiteral("\x01\x02")
+	if !reflect.DeepEqual(back, obj) {
+		t.Fatalf("expected %v, got %v", obj, back)
+	}
+
+	back, err = FromNative(map[string][]float64{"Matrix": {1, 0, 0, 1, 0, 0}, "Empty": nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := (ObjDict{"Matrix": ObjArray{ObjFloat(1), ObjFloat(0), ObjFloat(0), ObjFloat(1), ObjFloat(0), ObjFloat(0)}, "Empty": ObjArray{}}); !reflect.DeepEqual(back, exp) {
+		t.Fatalf("expected %v, got %v", exp, back)
+	}
+
+	if _, err = FromNative(map[int]string{}); err == nil {
+		t.Fatal("expected error for invalid map key")
+	}
+	if _, err = FromNative(struct{}{}); err == nil {
+		t.Fatal("expected error for invalid type")
+	}
+}
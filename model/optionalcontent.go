@@ -0,0 +1,260 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OptionalContent is either an *OptionalContentGroup or an *OptionalContentMembership,
+// and is used to control the visibility of content (see 8.11 - Optional Content).
+type OptionalContent interface {
+	Referenceable
+	isOptionalContent()
+}
+
+func (*OptionalContentGroup) isOptionalContent()      {}
+func (*OptionalContentMembership) isOptionalContent() {}
+
+var (
+	_ OptionalContent = (*OptionalContentGroup)(nil)
+	_ OptionalContent = (*OptionalContentMembership)(nil)
+)
+
+// OptionalContentGroup (OCG) is a collection of graphics that can be
+// made visible or invisible dynamically, often called a layer.
+// See 8.11.2 - Optional Content Groups
+// TODO: support the Usage dictionary
+type OptionalContentGroup struct {
+	Name   string // required, text string
+	Intent []Name // optional, default to [/View]
+}
+
+func (oc *OptionalContentGroup) pdfContent(pdf pdfWriter, ref Reference) (StreamHeader, string, []byte) {
+	b := newBuffer()
+	b.fmt("<</Type/OCG/Name %s", pdf.EncodeString(oc.Name, TextString, ref))
+	if len(oc.Intent) != 0 {
+		b.fmt("/Intent %s", writeNameArray(oc.Intent))
+	}
+	b.fmt(">>")
+	return StreamHeader{}, b.String(), nil
+}
+
+func (oc *OptionalContentGroup) clone(cache cloneCache) Referenceable {
+	if oc == nil {
+		return oc
+	}
+	out := *oc
+	out.Intent = append([]Name(nil), oc.Intent...)
+	return &out
+}
+
+// OptionalContentMembership (OCMD) expresses complex visibility policies,
+// based on several optional content groups.
+// See 8.11.2.2 - Optional Content Membership Dictionaries
+// TODO: support the visibility expressions (VE)
+type OptionalContentMembership struct {
+	OCGs []*OptionalContentGroup // optional
+	P    Name                    // optional, one of AllOn, AnyOn (default), AnyOff, AllOff
+}
+
+func writeOCGArray(pdf pdfWriter, ocgs []*OptionalContentGroup) string {
+	chunks := make([]string, len(ocgs))
+	for i, oc := range ocgs {
+		chunks[i] = pdf.addItem(oc).String()
+	}
+	return "[" + strings.Join(chunks, " ") + "]"
+}
+
+func cloneOCGArray(ocgs []*OptionalContentGroup, cache cloneCache) []*OptionalContentGroup {
+	if ocgs == nil { // preserve nil
+		return nil
+	}
+	out := make([]*OptionalContentGroup, len(ocgs))
+	for i, oc := range ocgs {
+		out[i] = cache.checkOrClone(oc).(*OptionalContentGroup)
+	}
+	return out
+}
+
+func (oc *OptionalContentMembership) pdfContent(pdf pdfWriter, ref Reference) (StreamHeader, string, []byte) {
+	b := newBuffer()
+	b.fmt("<</Type/OCMD")
+	if len(oc.OCGs) != 0 {
+		b.fmt("/OCGs %s", writeOCGArray(pdf, oc.OCGs))
+	}
+	if oc.P != "" {
+		b.fmt("/P %s", oc.P)
+	}
+	b.fmt(">>")
+	return StreamHeader{}, b.String(), nil
+}
+
+func (oc *OptionalContentMembership) clone(cache cloneCache) Referenceable {
+	if oc == nil {
+		return oc
+	}
+	out := *oc
+	out.OCGs = cloneOCGArray(oc.OCGs, cache)
+	return &out
+}
+
+// OptionalContentProperties lists the optional content groups
+// of the document, and their configurations.
+// It is written in PDF in the /OCProperties entry of the catalog.
+type OptionalContentProperties struct {
+	OCGs    []*OptionalContentGroup // required, all the groups used in the document
+	D       OptionalContentConfig   // required, default configuration
+	Configs []OptionalContentConfig // optional, alternate configurations
+}
+
+func (o OptionalContentProperties) pdfString(pdf pdfWriter, context Reference) string {
+	b := newBuffer()
+	b.fmt("<</OCGs %s/D %s", writeOCGArray(pdf, o.OCGs), o.D.pdfString(pdf, context))
+	if len(o.Configs) != 0 {
+		chunks := make([]string, len(o.Configs))
+		for i, c := range o.Configs {
+			chunks[i] = c.pdfString(pdf, context)
+		}
+		b.fmt("/Configs [%s]", strings.Join(chunks, " "))
+	}
+	b.fmt(">>")
+	return b.String()
+}
+
+func (o *OptionalContentProperties) clone(cache cloneCache) *OptionalContentProperties {
+	if o == nil {
+		return nil
+	}
+	out := *o
+	out.OCGs = cloneOCGArray(o.OCGs, cache)
+	out.D = o.D.clone(cache)
+	if o.Configs != nil {
+		out.Configs = make([]OptionalContentConfig, len(o.Configs))
+		for i, c := range o.Configs {
+			out.Configs[i] = c.clone(cache)
+		}
+	}
+	return &out
+}
+
+// OptionalContentConfig sets the initial state of the optional content groups.
+// See 8.11.4.3 - Optional Content Configuration Dictionaries
+type OptionalContentConfig struct {
+	Name      string                    // optional, text string
+	Creator   string                    // optional, text string
+	BaseState Name                      // optional, one of ON (default), OFF, Unchanged
+	ON, OFF   []*OptionalContentGroup   // optional
+	Intent    []Name                    // optional, default to [/View]
+	Order     []OptionalContentOrder    // optional
+	ListMode  Name                      // optional, one of AllPages (default), VisiblePages
+	RBGroups  [][]*OptionalContentGroup // optional, radio button groups
+	Locked    []*OptionalContentGroup   // optional
+}
+
+func (c OptionalContentConfig) pdfString(pdf pdfWriter, context Reference) string {
+	b := newBuffer()
+	b.fmt("<<")
+	if c.Name != "" {
+		b.fmt("/Name %s", pdf.EncodeString(c.Name, TextString, context))
+	}
+	if c.Creator != "" {
+		b.fmt("/Creator %s", pdf.EncodeString(c.Creator, TextString, context))
+	}
+	if c.BaseState != "" {
+		b.fmt("/BaseState %s", c.BaseState)
+	}
+	if len(c.ON) != 0 {
+		b.fmt("/ON %s", writeOCGArray(pdf, c.ON))
+	}
+	if len(c.OFF) != 0 {
+		b.fmt("/OFF %s", writeOCGArray(pdf, c.OFF))
+	}
+	if len(c.Intent) != 0 {
+		b.fmt("/Intent %s", writeNameArray(c.Intent))
+	}
+	if len(c.Order) != 0 {
+		b.fmt("/Order %s", writeOCOrder(pdf, c.Order, context))
+	}
+	if c.ListMode != "" {
+		b.fmt("/ListMode %s", c.ListMode)
+	}
+	if len(c.RBGroups) != 0 {
+		chunks := make([]string, len(c.RBGroups))
+		for i, group := range c.RBGroups {
+			chunks[i] = writeOCGArray(pdf, group)
+		}
+		b.fmt("/RBGroups [%s]", strings.Join(chunks, " "))
+	}
+	if len(c.Locked) != 0 {
+		b.fmt("/Locked %s", writeOCGArray(pdf, c.Locked))
+	}
+	b.fmt(">>")
+	return b.String()
+}
+
+func (c OptionalContentConfig) clone(cache cloneCache) OptionalContentConfig {
+	out := c
+	out.ON = cloneOCGArray(c.ON, cache)
+	out.OFF = cloneOCGArray(c.OFF, cache)
+	out.Intent = append([]Name(nil), c.Intent...)
+	out.Order = cloneOCOrder(c.Order, cache)
+	if c.RBGroups != nil {
+		out.RBGroups = make([][]*OptionalContentGroup, len(c.RBGroups))
+		for i, group := range c.RBGroups {
+			out.RBGroups[i] = cloneOCGArray(group, cache)
+		}
+	}
+	out.Locked = cloneOCGArray(c.Locked, cache)
+	return out
+}
+
+// OptionalContentOrder is an item of the Order array, used
+// by viewers to present the groups. It is either a group (when `Group` is not nil)
+// or a (optionally labeled) list of items.
+type OptionalContentOrder struct {
+	Group *OptionalContentGroup
+	Label string // optional, text string
+	Kids  []OptionalContentOrder
+}
+
+func writeOCOrder(pdf pdfWriter, items []OptionalContentOrder, context Reference) string {
+	chunks := make([]string, 0, len(items)+1)
+	for _, item := range items {
+		if item.Group != nil {
+			chunks = append(chunks, pdf.addItem(item.Group).String())
+			continue
+		}
+		sub := writeOCOrder(pdf, item.Kids, context)
+		if item.Label != "" {
+			sub = fmt.Sprintf("[%s %s", pdf.EncodeString(item.Label, TextString, context), sub[1:])
+		}
+		chunks = append(chunks, sub)
+	}
+	return "[" + strings.Join(chunks, " ") + "]"
+}
+
+func cloneOCOrder(items []OptionalContentOrder, cache cloneCache) []OptionalContentOrder {
+	if items == nil { // preserve nil
+		return nil
+	}
+	out := make([]OptionalContentOrder, len(items))
+	for i, item := range items {
+		out[i] = OptionalContentOrder{Label: item.Label, Kids: cloneOCOrder(item.Kids, cache)}
+		if item.Group != nil {
+			out[i].Group = cache.checkOrClone(item.Group).(*OptionalContentGroup)
+		}
+	}
+	return out
+}
+
+// writeOptionalContent returns the reference to `oc`
+func writeOptionalContent(pdf pdfWriter, oc OptionalContent) string {
+	return pdf.addItem(oc).String()
+}
+
+func cloneOptionalContent(oc OptionalContent, cache cloneCache) OptionalContent {
+	if oc == nil {
+		return nil
+	}
+	return cache.checkOrClone(oc).(OptionalContent)
+}
@@ -1,7 +1,12 @@
 package model
 
 import (
+	"bytes"
 	"fmt"
+	"reflect"
+	"sort"
+
+	tkn "github.com/benoitkugler/pstokenizer"
 )
 
 // PageNode is either a `PageTree` or a `PageObject`
@@ -58,6 +63,23 @@ func (p PageTree) Flatten() []*PageObject {
 	return out
 }
 
+// SetAnnotationsVisibility updates the visibility of the annotations
+// for which `match` returns true (see BaseAnnotation.SetVisibility).
+// `match` is called with the (0-based) index of the page containing the annotation.
+// It returns the number of annotations updated.
+func (p PageTree) SetAnnotationsVisibility(v Visibility, match func(pageIndex int, annot *AnnotationDict) bool) int {
+	count := 0
+	for i, page := range p.Flatten() {
+		for _, annot := range page.Annots {
+			if match(i, annot) {
+				annot.SetVisibility(v)
+				count++
+			}
+		}
+	}
+	return count
+}
+
 // FlattenInherit returns all the leaf of the tree,
 // respecting the indexing convention for pages (0-based):
 // the page with index i is FlattenInherit()[i].
@@ -74,7 +96,7 @@ func (p PageTree) FlattenInherit() []PageObject {
 				copied.Resources = p.Resources
 			}
 			if copied.MediaBox == nil {
-				copied.MediaBox = nil
+				copied.MediaBox = p.MediaBox
 			}
 			out = append(out, copied.FlattenInherit()...)
 		case *PageObject:
@@ -84,7 +106,7 @@ func (p PageTree) FlattenInherit() []PageObject {
 				copied.Resources = p.Resources
 			}
 			if copied.MediaBox == nil {
-				copied.MediaBox = nil
+				copied.MediaBox = p.MediaBox
 			}
 			out = append(out, copied)
 		}
@@ -92,6 +114,71 @@ func (p PageTree) FlattenInherit() []PageObject {
 	return out
 }
 
+// Normalize simplifies the structure of the tree, without changing
+// the pages nor their inherited attributes:
+//   - the intermediate nodes without pages are removed,
+//   - the chains of nodes with only one kid are collapsed.
+//
+// Note that the Count entries are always computed when writing.
+func (p *PageTree) Normalize() {
+	kids := p.Kids[:0]
+	for _, kid := range p.Kids {
+		if tree, ok := kid.(*PageTree); ok {
+			tree.Normalize()
+			if len(tree.Kids) == 0 {
+				continue
+			}
+		}
+		kids = append(kids, kid)
+	}
+	p.Kids = kids
+
+	for len(p.Kids) == 1 {
+		child, ok := p.Kids[0].(*PageTree)
+		if !ok {
+			break
+		}
+		// the attributes of the child override the inherited ones
+		if child.Resources != nil {
+			p.Resources = child.Resources
+		}
+		if child.MediaBox != nil {
+			p.MediaBox = child.MediaBox
+		}
+		p.Kids = child.Kids
+	}
+}
+
+// Balance rebuilds the tree so that each node has at most `maxKids` kids
+// and all the pages have the same depth, which speeds up the
+// page access for viewers. If `maxKids` is less than 2, 16 is used.
+// The order of the pages is preserved, and their inherited resources and
+// media boxes are resolved: the page objects are updated accordingly.
+func (p *PageTree) Balance(maxKids int) {
+	if maxKids < 2 {
+		maxKids = 16
+	}
+	pages := p.Flatten()
+	inherited := p.FlattenInherit()
+	nodes := make([]PageNode, len(pages))
+	for i, page := range pages {
+		page.Resources, page.MediaBox = inherited[i].Resources, inherited[i].MediaBox
+		nodes[i] = page
+	}
+	for len(nodes) > maxKids {
+		var level []PageNode
+		for start := 0; start < len(nodes); start += maxKids {
+			end := start + maxKids
+			if end > len(nodes) {
+				end = len(nodes)
+			}
+			level = append(level, &PageTree{Kids: nodes[start:end:end]})
+		}
+		nodes = level
+	}
+	*p = PageTree{Kids: nodes}
+}
+
 // walk to associate an object number to each page nodes
 // in the `pages` attribute of `pdf`
 // also build up the parent to simplify the writing
@@ -159,6 +246,10 @@ func (p *PageTree) clone(cache cloneCache) PageNode {
 		res := p.Resources.clone(cache)
 		out.Resources = &res
 	}
+	if p.MediaBox != nil {
+		r := *p.MediaBox
+		out.MediaBox = &r
+	}
 	if p.Kids != nil { // preserve reflect.DeepEqual
 		out.Kids = make([]PageNode, len(p.Kids))
 	}
@@ -182,30 +273,99 @@ type PageObject struct {
 	// If nil, will be inherited from the parent.
 	// Only multiple of 90 are allowed (see the constants)
 	Rotate        Rotation
-	Group         *TransparencyGroup // optional
-	Annots        []*AnnotationDict  // optional, should not contain annotation widget
-	Contents      []ContentStream    // array of stream (often of length 1)
-	StructParents MaybeInt           // Required if the page contains structural content items
-	Tabs          Name               // optional, one of R , C or S
+	Group         *TransparencyGroup    // optional
+	Annots        []*AnnotationDict     // optional, should not contain annotation widget
+	Contents      []ContentStream       // array of stream (often of length 1)
+	StructParents MaybeInt              // Required if the page contains structural content items
+	Tabs          Name                  // optional, one of R , C or S (see the Tabs constants)
+	VP            Viewports             // optional
+	AA            PageAdditionalActions // optional
+	AF            []*FileSpec           // optional, associated files (PDF 2.0, PDF/A-3)
+	Thumb         *XObjectImage         // optional, thumbnail image (see Document.RemoveThumbnails)
+
+	// Custom stores the additional entries, such as
+	// private keys, which are written as is.
+	// It is filled by the reader with the unknown entries of the source file.
+	Custom ObjDict // optional
 
 	// cache, set up during pre-allocation
 	// a nil value indicates a template page
 	parent *PageTree
 }
 
+// Tab orders, used in PageObject.Tabs to define
+// the order of the annotations for keyboard navigation
+const (
+	TabsRow       Name = "R"
+	TabsColumn    Name = "C"
+	TabsStructure Name = "S"
+	TabsAnnots    Name = "A" // PDF 2.0, order of the Annots array
+	TabsWidgets   Name = "W" // PDF 2.0, order of the widgets, then of the other annotations
+)
+
+// SetTabOrder sets the tab order (Tabs) of the page, which must be
+// one of the Tabs constants, or empty to remove it.
+func (p *PageObject) SetTabOrder(tabs Name) error {
+	switch tabs {
+	case "", TabsRow, TabsColumn, TabsStructure, TabsAnnots, TabsWidgets:
+		p.Tabs = tabs
+		return nil
+	default:
+		return newError(ErrInvalidArgument, "invalid tab order %s", tabs)
+	}
+}
+
 // DecodeAllContents read each content stream and returns the
 // aggregated one.
 func (p *PageObject) DecodeAllContents() ([]byte, error) {
-	var totalPageContent []byte
+	return p.DecodeAllContentsTo(nil)
+}
+
+// DecodeAllContentsTo is the same as DecodeAllContents, but appends
+// the content to `dst` (see Stream.DecodeTo).
+func (p *PageObject) DecodeAllContentsTo(dst []byte) ([]byte, error) {
 	for _, ct := range p.Contents {
-		ctContent, err := ct.Decode()
+		var err error
+		dst, err = ct.DecodeTo(dst)
 		if err != nil {
 			return nil, err
 		}
-		totalPageContent = append(totalPageContent, ctContent...)
 	}
+	return dst, nil
+}
+
+// AppendContent draws `ops` on top of the existing content of the page,
+// using the resources `res`, which are merged into the page resources.
+// The resources whose name is already used in the page (for another object)
+// are renamed, and `ops` is updated accordingly.
+// The existing content and `ops` are isolated by save/restore operators,
+// so that the graphics state of one does not affect the other.
+// Note that pages inheriting their resources should be resolved first
+// (see PageTree.FlattenInherit), since only the resources of the page are considered.
+func (p *PageObject) AppendContent(ops []byte, res ResourcesDict) error {
+	var pageRes ResourcesDict
+	if p.Resources != nil {
+		pageRes = p.Resources.ShallowCopy()
+	} else {
+		pageRes = NewResourcesDict()
+	}
+	renames := pageRes.merge(res)
+	ops, err := renameNames(ops, renames)
+	if err != nil {
+		return wrapError(ErrType, err, "invalid content stream")
+	}
+	p.Resources = &pageRes
 
-	return totalPageContent, nil
+	content := append([]byte("Q\nq\n"), ops...)
+	content = append(content, "\nQ"...)
+	if len(p.Contents) == 0 {
+		content = content[2:] // no need to restore
+	} else {
+		// isolate the existing content, which may not restore the graphic state
+		p.Contents = append([]ContentStream{{Stream: Stream{Content: []byte("q\n")}}}, p.Contents...)
+	}
+	p.Contents = append(p.Contents, ContentStream{Stream: Stream{Content: content}})
+	return nil
 }
 
 // the pdf page map is used to fetch the object number
@@ -219,59 +379,83 @@ func (p *PageObject) pdfString(pdf pdfWriter) string {
 	} else {
 		parentReference := pdf.pages[p.parent]
 		b.line("/Type/Page")
-		b.line("/Parent %s", parentReference)
+		b.ref("Parent", parentReference)
+		b.WriteByte('\n')
 	}
 	if !p.Resources.IsEmpty() {
 		refResources := pdf.CreateObject()
 		pdf.WriteObject(p.Resources.pdfString(pdf, refResources), refResources)
-		b.line("/Resources %s", refResources)
-	}
-	if p.MediaBox != nil {
-		b.line("/MediaBox %s", p.MediaBox.String())
-	}
-	if p.CropBox != nil {
-		b.line("/CropBox %s", p.CropBox.String())
-	}
-	if p.BleedBox != nil {
-		b.line("/BleedBox %s", p.BleedBox.String())
-	}
-	if p.TrimBox != nil {
-		b.line("/TrimBox %s", p.TrimBox.String())
-	}
-	if p.ArtBox != nil {
-		b.line("/ArtBox %s", p.ArtBox.String())
+		b.ref("Resources", refResources)
+		b.WriteByte('\n')
+	}
+	for _, box := range [...]struct {
+		key  Name
+		rect *Rectangle
+	}{{"MediaBox", p.MediaBox}, {"CropBox", p.CropBox}, {"BleedBox", p.BleedBox}, {"TrimBox", p.TrimBox}, {"ArtBox", p.ArtBox}} {
+		if box.rect != nil {
+			b.rect(box.key, *box.rect)
+			b.WriteByte('\n')
+		}
 	}
 	if p.Rotate != Unset {
-		b.line("/Rotate %d", p.Rotate.Degrees())
+		b.int("Rotate", p.Rotate.Degrees())
+		b.WriteByte('\n')
 	}
 	if p.Group != nil {
-		parentReference := pdf.pages[p.parent]
-		b.line("/Group %s", p.Group.pdfString(pdf, parentReference, false))
+		b.line("/Group %s", p.Group.pdfString(pdf, pdf.pages[p], false))
 	}
 	if len(p.Annots) != 0 {
 		annots := make([]Reference, len(p.Annots))
 		for i, a := range p.Annots {
 			annots[i] = pdf.addItem(a)
 		}
-		b.line("/Annots %s", writeRefArray(annots))
+		b.refs("Annots", annots)
+		b.WriteByte('\n')
 	}
 	contents := make([]Reference, len(p.Contents))
 	for i, c := range p.Contents {
 		contents[i] = pdf.addStream(c.PDFContent())
 	}
 	if len(contents) != 0 {
-		b.line("/Contents %s", writeRefArray(contents))
+		b.refs("Contents", contents)
+		b.WriteByte('\n')
 	}
 	if p.StructParents != nil {
-		b.fmt("/StructParents %d", p.StructParents.(ObjInt))
+		b.int("StructParents", int(p.StructParents.(ObjInt)))
 	}
 	if p.Tabs != "" {
-		b.fmt("/Tabs %s", p.Tabs)
+		b.entry("Tabs", p.Tabs.String())
+	}
+	if len(p.VP) != 0 {
+		b.fmt("/VP %s", p.VP.pdfString(pdf, pdf.pages[p]))
+	}
+	if !p.AA.IsEmpty() {
+		b.fmt("/AA %s", p.AA.pdfString(pdf, pdf.pages[p]))
+	}
+	if len(p.AF) != 0 {
+		b.fmt("/AF %s", writeFileSpecArray(pdf, p.AF))
+	}
+	if p.Thumb != nil {
+		b.ref("Thumb", pdf.addItem(p.Thumb))
 	}
+	b.custom(pdf, p.Custom, pdf.pages[p])
 	b.WriteString(">>")
 	return b.String()
 }
 
+// RemoveThumbnails removes the thumbnail images of the pages,
+// returning the number of thumbnails removed.
+func (doc *Document) RemoveThumbnails() int {
+	count := 0
+	for _, page := range doc.Catalog.Pages.Flatten() {
+		if page.Thumb != nil {
+			page.Thumb = nil
+			count++
+		}
+	}
+	return count
+}
+
 // Count return the number of PageObject-that is 1
 func (*PageObject) Count() int { return 1 }
 
@@ -328,6 +512,13 @@ func (po *PageObject) clone(cache cloneCache) PageNode {
 	for i, c := range po.Contents {
 		out.Contents[i] = c.Clone()
 	}
+	out.VP = po.VP.clone()
+	out.AA = po.AA.clone(cache)
+	out.AF = cloneFileSpecArray(po.AF, cache)
+	if po.Thumb != nil {
+		out.Thumb = cache.checkOrClone(po.Thumb).(*XObjectImage)
+	}
+	out.Custom = cloneCustom(po.Custom)
 	return out
 }
 
@@ -340,6 +531,11 @@ type ResourcesDict struct {
 	Font       map[Name]*FontDict     // optional
 	XObject    map[Name]XObject       // optional
 	Properties map[Name]PropertyList  // optional
+
+	// OptionalContents are the optional content groups or memberships
+	// referenced by marked-content sequences. They are written in PDF under
+	// the /Properties key, along with the other property lists.
+	OptionalContents map[Name]OptionalContent // optional
 }
 
 // NewResourcesDict initialize the maps
@@ -352,6 +548,8 @@ func NewResourcesDict() ResourcesDict {
 		Font:       make(map[Name]*FontDict),
 		XObject:    make(map[Name]XObject),
 		Properties: make(map[Name]PropertyList),
+
+		OptionalContents: make(map[Name]OptionalContent),
 	}
 }
 
@@ -363,7 +561,8 @@ func (r *ResourcesDict) IsEmpty() bool {
 	}
 	return len(r.ExtGState) == 0 && len(r.ColorSpace) == 0 &&
 		len(r.Shading) == 0 && len(r.Pattern) == 0 &&
-		len(r.Font) == 0 && len(r.XObject) == 0 && len(r.Properties) == 0
+		len(r.Font) == 0 && len(r.XObject) == 0 && len(r.Properties) == 0 &&
+		len(r.OptionalContents) == 0
 }
 
 // ShallowCopy returns a new resources dict,
@@ -401,23 +600,238 @@ func (r ResourcesDict) ShallowCopy() ResourcesDict {
 	for n, v := range r.Properties {
 		out.Properties[n] = v
 	}
+	out.OptionalContents = make(map[Name]OptionalContent, len(r.OptionalContents))
+	for n, v := range r.OptionalContents {
+		out.OptionalContents[n] = v
+	}
+	return out
+}
+
+// names returns the names used in all the categories of `r`
+func (r ResourcesDict) names() map[Name]bool {
+	out := make(map[Name]bool)
+	for n := range r.ExtGState {
+		out[n] = true
+	}
+	for n := range r.ColorSpace {
+		out[Name(n)] = true
+	}
+	for n := range r.Shading {
+		out[n] = true
+	}
+	for n := range r.Pattern {
+		out[n] = true
+	}
+	for n := range r.Font {
+		out[n] = true
+	}
+	for n := range r.XObject {
+		out[n] = true
+	}
+	for n := range r.Properties {
+		out[n] = true
+	}
+	for n := range r.OptionalContents {
+		out[n] = true
+	}
 	return out
 }
 
+// UnusedName returns the first name made of `prefix` followed by
+// a number (starting at 0) which is not used in any category of `r`.
+func (r ResourcesDict) UnusedName(prefix string) Name {
+	used := r.names()
+	for i := 0; ; i++ {
+		name := Name(fmt.Sprintf("%s%d", prefix, i))
+		if !used[name] {
+			return name
+		}
+	}
+}
+
+// sameResource returns true if `a` and `b` are the same object
+func sameResource(a, b interface{}) bool {
+	ta := reflect.TypeOf(a)
+	return ta == reflect.TypeOf(b) && ta != nil && ta.Comparable() && a == b
+}
+
+// merge adds the resources of `other` into `r`, whose maps must be initialized.
+// The resources of `other` using a name already used by another object in `r`
+// are renamed, and the new names are returned.
+func (r *ResourcesDict) merge(other ResourcesDict) map[Name]Name {
+	var conflicts []Name
+	check := func(name Name, existing, value interface{}, has bool) {
+		if has && !sameResource(existing, value) {
+			conflicts = append(conflicts, name)
+		}
+	}
+	for n, v := range other.ExtGState {
+		e, has := r.ExtGState[n]
+		check(n, e, v, has)
+	}
+	for n, v := range other.ColorSpace {
+		e, has := r.ColorSpace[n]
+		check(Name(n), e, v, has)
+	}
+	for n, v := range other.Shading {
+		e, has := r.Shading[n]
+		check(n, e, v, has)
+	}
+	for n, v := range other.Pattern {
+		e, has := r.Pattern[n]
+		check(n, e, v, has)
+	}
+	for n, v := range other.Font {
+		e, has := r.Font[n]
+		check(n, e, v, has)
+	}
+	for n, v := range other.XObject {
+		e, has := r.XObject[n]
+		check(n, e, v, has)
+	}
+	for n, v := range other.Properties {
+		e, has := r.Properties[n]
+		check(n, e, v, has)
+	}
+	for n, v := range other.OptionalContents {
+		e, has := r.OptionalContents[n]
+		check(n, e, v, has)
+	}
+
+	// the same name may be used in several categories:
+	// it is renamed consistently
+	renames := make(map[Name]Name)
+	used := r.names()
+	for n := range other.names() {
+		used[n] = true
+	}
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i] < conflicts[j] })
+	for _, name := range conflicts {
+		if _, done := renames[name]; done {
+			continue
+		}
+		newName := name
+		for i := 1; used[newName]; i++ {
+			newName = Name(fmt.Sprintf("%s_%d", string(name), i))
+		}
+		used[newName] = true
+		renames[name] = newName
+	}
+	rename := func(name Name) Name {
+		if newName, has := renames[name]; has {
+			return newName
+		}
+		return name
+	}
+
+	for n, v := range other.ExtGState {
+		r.ExtGState[rename(n)] = v
+	}
+	for n, v := range other.ColorSpace {
+		r.ColorSpace[ColorSpaceName(rename(Name(n)))] = v
+	}
+	for n, v := range other.Shading {
+		r.Shading[rename(n)] = v
+	}
+	for n, v := range other.Pattern {
+		r.Pattern[rename(n)] = v
+	}
+	for n, v := range other.Font {
+		r.Font[rename(n)] = v
+	}
+	for n, v := range other.XObject {
+		r.XObject[rename(n)] = v
+	}
+	for n, v := range other.Properties {
+		r.Properties[rename(n)] = v
+	}
+	for n, v := range other.OptionalContents {
+		r.OptionalContents[rename(n)] = v
+	}
+	return renames
+}
+
+// renameNames returns a copy of the content stream `content`,
+// where the names found in `renames` are replaced.
+func renameNames(content []byte, renames map[Name]Name) ([]byte, error) {
+	if len(renames) == 0 {
+		return content, nil
+	}
+	var out bytes.Buffer
+	tk := tkn.NewTokenizer(content)
+	written := 0 // position of the content not yet written
+	for {
+		token, err := tk.NextToken()
+		if err != nil {
+			return nil, err
+		}
+		if token.Kind == tkn.EOF {
+			break
+		}
+		end := tk.CurrentPosition()
+		if token.Kind == tkn.Name {
+			newName, has := renames[Name(token.Value)]
+			if !has {
+				continue
+			}
+			// a name can't contain a slash: the last one starts the token
+			start := bytes.LastIndexByte(content[:end], '/')
+			out.Write(content[written:start])
+			out.WriteString(newName.String())
+			written = end
+		} else if token.IsOther("ID") {
+			// skip the inline image data, which the tokenizer does not handle
+			index := inlineImageEnd(content[end:])
+			if index == -1 {
+				break
+			}
+			tk.SetPosition(end + index)
+		}
+	}
+	out.Write(content[written:])
+	return out.Bytes(), nil
+}
+
+// inlineImageEnd returns the position of the EI operator
+// ending the inline image data starting `data`, or -1
+func inlineImageEnd(data []byte) int {
+	for i := 1; i+2 <= len(data); i++ {
+		if data[i] == 'E' && data[i+1] == 'I' && tkn.IsAsciiWhitespace(data[i-1]) &&
+			(i+2 == len(data) || tkn.IsAsciiWhitespace(data[i+2])) {
+			return i
+		}
+	}
+	return -1
+}
+
+// sortNames sorts `names` in place, for a deterministic output
+func sortNames(names []Name) []Name {
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
 func (r *ResourcesDict) pdfString(pdf pdfWriter, context Reference) string {
 	b := newBuffer()
 	b.line("<<")
 	if len(r.ExtGState) != 0 {
 		b.fmt("/ExtGState <<")
-		for n, item := range r.ExtGState {
-			ref := pdf.addItem(item)
-			b.fmt("%s %s", n, ref)
+		keys := make([]Name, 0, len(r.ExtGState))
+		for n := range r.ExtGState {
+			keys = append(keys, n)
+		}
+		for _, n := range sortNames(keys) {
+			b.ref(n, pdf.addItem(r.ExtGState[n]))
 		}
 		b.line(">>")
 	}
 	if len(r.ColorSpace) != 0 {
 		b.fmt("/ColorSpace <<")
-		for n, item := range r.ColorSpace {
+		keys := make([]Name, 0, len(r.ColorSpace))
+		for n := range r.ColorSpace {
+			keys = append(keys, Name(n))
+		}
+		for _, n := range sortNames(keys) {
+			item := r.ColorSpace[ColorSpaceName(n)]
 			if item == nil {
 				continue
 			}
@@ -427,43 +841,66 @@ func (r *ResourcesDict) pdfString(pdf pdfWriter, context Reference) string {
 	}
 	if len(r.Shading) != 0 {
 		b.fmt("/Shading <<")
-		for n, item := range r.Shading {
-			ref := pdf.addItem(item)
-			b.fmt("%s %s", n, ref)
+		keys := make([]Name, 0, len(r.Shading))
+		for n := range r.Shading {
+			keys = append(keys, n)
+		}
+		for _, n := range sortNames(keys) {
+			b.ref(n, pdf.addItem(r.Shading[n]))
 		}
 		b.line(">>")
 	}
 	if len(r.Pattern) != 0 {
 		b.fmt("/Pattern <<")
-		for n, item := range r.Pattern {
-			ref := pdf.addItem(item)
-			b.fmt("%s %s", n, ref)
+		keys := make([]Name, 0, len(r.Pattern))
+		for n := range r.Pattern {
+			keys = append(keys, n)
+		}
+		for _, n := range sortNames(keys) {
+			b.ref(n, pdf.addItem(r.Pattern[n]))
 		}
 		b.line(">>")
 	}
 	if len(r.Font) != 0 {
 		b.fmt("/Font <<")
-		for n, item := range r.Font {
-			ref := pdf.addItem(item)
-			b.fmt("%s %s", n, ref)
+		keys := make([]Name, 0, len(r.Font))
+		for n := range r.Font {
+			keys = append(keys, n)
+		}
+		for _, n := range sortNames(keys) {
+			b.ref(n, pdf.addItem(r.Font[n]))
 		}
 		b.line(">>")
 	}
 	if len(r.XObject) != 0 {
 		b.fmt("/XObject <<")
-		for n, item := range r.XObject {
-			ref := pdf.addItem(item)
-			b.fmt("%s %s", n, ref)
+		keys := make([]Name, 0, len(r.XObject))
+		for n := range r.XObject {
+			keys = append(keys, n)
+		}
+		for _, n := range sortNames(keys) {
+			b.ref(n, pdf.addItem(r.XObject[n]))
 		}
 		b.line(">>")
 	}
-	if len(r.Properties) != 0 {
+	if len(r.Properties) != 0 || len(r.OptionalContents) != 0 {
 		b.fmt("/Properties <<")
-		for n, item := range r.Properties {
+		keys := make([]Name, 0, len(r.Properties))
+		for n := range r.Properties {
+			keys = append(keys, n)
+		}
+		for _, n := range sortNames(keys) {
 			ref := pdf.CreateObject()
-			pdf.WriteObject(item.Write(pdf, ref), ref)
+			pdf.WriteObject(r.Properties[n].Write(pdf, ref), ref)
 			b.fmt("%s %s", n, ref)
 		}
+		keys = keys[:0]
+		for n := range r.OptionalContents {
+			keys = append(keys, n)
+		}
+		for _, n := range sortNames(keys) {
+			b.fmt("%s %s", n, writeOptionalContent(pdf, r.OptionalContents[n]))
+		}
 		b.line(">>")
 	}
 	b.fmt(">>")
@@ -516,6 +953,12 @@ func (r ResourcesDict) clone(cache cloneCache) ResourcesDict {
 			out.Properties[n] = v.Clone().(ObjDict)
 		}
 	}
+	if r.OptionalContents != nil {
+		out.OptionalContents = make(map[Name]OptionalContent, len(r.OptionalContents))
+		for n, v := range r.OptionalContents {
+			out.OptionalContents[n] = cloneOptionalContent(v, cache)
+		}
+	}
 	return out
 }
 
@@ -544,6 +987,9 @@ func (o *Outline) Count() int {
 
 // Flatten return all the leaf objects (open or not)
 func (o *Outline) Flatten() []*OutlineItem {
+	if o.First == nil {
+		return nil
+	}
 	return o.First.flatten()
 }
 
@@ -650,6 +1096,86 @@ func (p *OutlineItem) flatten() []*OutlineItem {
 	return out
 }
 
+// bookmarkDest returns a destination to `page`, at the vertical position `y`,
+// keeping the current horizontal position and zoom.
+func bookmarkDest(page *PageObject, y Fl) DestinationExplicitIntern {
+	return DestinationExplicitIntern{Page: page, Location: DestinationLocationXYZ{Top: ObjFloat(y)}}
+}
+
+// appendItem adds `item` as the last child of `parent`
+func appendItem(parent OutlineNode, item *OutlineItem) *OutlineItem {
+	item.Parent = parent
+	if last := last(parent); last != nil {
+		last.Next = item
+		return item
+	}
+	switch parent := parent.(type) {
+	case *Outline:
+		parent.First = item
+	case *OutlineItem:
+		parent.First = item
+	}
+	return item
+}
+
+// AddBookmark appends a new top-level item to the outline, pointing to the
+// vertical position `y` of `page` (in default user space), and returns it.
+// Nested items may be added with OutlineItem.AddChild and OutlineItem.AddSibling.
+func (o *Outline) AddBookmark(title string, page *PageObject, y Fl) *OutlineItem {
+	return appendItem(o, &OutlineItem{Title: title, Dest: bookmarkDest(page, y)})
+}
+
+// AddChild appends a new item as the last child of `o`, pointing to the
+// vertical position `y` of `page`, and returns it.
+func (o *OutlineItem) AddChild(title string, page *PageObject, y Fl) *OutlineItem {
+	return appendItem(o, &OutlineItem{Title: title, Dest: bookmarkDest(page, y)})
+}
+
+// AddSibling inserts a new item right after `o`, at the same level,
+// pointing to the vertical position `y` of `page`, and returns it.
+// `o` must have a parent.
+func (o *OutlineItem) AddSibling(title string, page *PageObject, y Fl) *OutlineItem {
+	item := &OutlineItem{Title: title, Dest: bookmarkDest(page, y), Parent: o.Parent, Next: o.Next}
+	o.Next = item
+	return item
+}
+
+// Heading is an entry of a table of contents, used to build an outline.
+type Heading struct {
+	// Level is the nesting level of the heading, starting at 1 for top-level headings.
+	Level int
+	Title string
+	Dest  Destination
+}
+
+// NewOutlineFromHeadings builds an outline from a flat list of headings,
+// in document order: each heading is nested below the last heading with a lower level.
+// Levels skipped (such as a level 3 heading following a level 1 heading)
+// are ignored, and levels less than 1 are treated as 1.
+func NewOutlineFromHeadings(headings []Heading) *Outline {
+	out := new(Outline)
+	// parents[i] is the last item with level i+1
+	var parents []*OutlineItem
+	for _, heading := range headings {
+		level := heading.Level
+		if level < 1 {
+			level = 1
+		}
+		if level > len(parents)+1 {
+			level = len(parents) + 1
+		}
+		parents = parents[:level-1]
+		item := &OutlineItem{Title: heading.Title, Dest: heading.Dest}
+		if level == 1 {
+			appendItem(out, item)
+		} else {
+			appendItem(parents[level-2], item)
+		}
+		parents = append(parents, item)
+	}
+	return out
+}
+
 // convenience function to write an item only once, and return
 // its reference afterwards
 func (pdf pdfWriter) addOutlineItem(item *OutlineItem, parent Reference) Reference {
@@ -50,3 +50,270 @@ func TestShallowClone(t *testing.T) {
 		t.Error("expected impact")
 	}
 }
+
+func TestViewports(t *testing.T) {
+	vps := Viewports{
+		{BBox: Rectangle{Urx: 100, Ury: 100}, Name: "a", Measure: MeasureRL{R: "1:1"}},
+		{BBox: Rectangle{Llx: 50, Lly: 50, Urx: 150, Ury: 150}, Name: "b"},
+	}
+	if vp := vps.At(10, 10); vp == nil || vp.Name != "a" {
+		t.Errorf("unexpected viewport %v", vp)
+	}
+	if vp := vps.At(75, 75); vp == nil || vp.Name != "b" {
+		t.Errorf("unexpected viewport %v", vp)
+	}
+	if vp := vps.At(200, 10); vp != nil {
+		t.Errorf("unexpected viewport %v", vp)
+	}
+
+	page := &PageObject{VP: vps}
+	cloned := page.clone(newCloneCache()).(*PageObject)
+	if !reflect.DeepEqual(cloned.VP, vps) {
+		t.Errorf("expected %v, got %v", vps, cloned.VP)
+	}
+}
+
+func TestExtractPages(t *testing.T) {
+	widget := func() FormFieldWidget {
+		return FormFieldWidget{AnnotationDict: &AnnotationDict{Subtype: AnnotationWidget{}}}
+	}
+	// "group.a" has widgets on pages 0 and 2, "group.b" only on page 1
+	a := &FormFieldDict{T: "a", Widgets: []FormFieldWidget{widget(), widget()}}
+	b := &FormFieldDict{T: "b", Widgets: []FormFieldWidget{widget()}}
+	group := &FormFieldDict{T: "group", Kids: []*FormFieldDict{a, b}}
+	a.Parent, b.Parent = group, group
+	hidden := &FormFieldDict{T: "hidden"}
+
+	resources := &ResourcesDict{Font: map[Name]*FontDict{"F": {}}}
+	var doc Document
+	doc.Catalog.AcroForm = AcroForm{Fields: []*FormFieldDict{group, hidden}, CO: []*FormFieldDict{b, a}}
+	doc.Catalog.Pages = PageTree{
+		MediaBox:  &Rectangle{Urx: 100, Ury: 100},
+		Resources: resources,
+		Kids: []PageNode{
+			&PageObject{Annots: []*AnnotationDict{a.Widgets[0].AnnotationDict}},
+			&PageObject{Annots: []*AnnotationDict{b.Widgets[0].AnnotationDict}},
+			&PageObject{Annots: []*AnnotationDict{a.Widgets[1].AnnotationDict}},
+		},
+	}
+
+	out := doc.ExtractPages([]int{2, 0, 2, 5})
+	pages := out.Catalog.Pages.Flatten()
+	if len(pages) != 2 {
+		t.Fatalf("expected 2 pages, got %d", len(pages))
+	}
+	if pages[0].MediaBox == nil || pages[0].Resources == nil || pages[0].Resources.Font["F"] == nil {
+		t.Fatal("missing inherited attributes")
+	}
+	fields := out.Catalog.AcroForm.Flatten()
+	if len(fields) != 3 {
+		t.Fatalf("unexpected fields %v", fields)
+	}
+	if f := fields["group.a"].Field; len(f.Widgets) != 2 || len(out.Catalog.AcroForm.CO) != 1 || out.Catalog.AcroForm.CO[0] != f {
+		t.Fatalf("unexpected field %v", f)
+	}
+	if _, ok := fields["hidden"]; !ok {
+		t.Fatal("field without widgets should be preserved")
+	}
+
+	out = doc.ExtractPages([]int{1})
+	fields = out.Catalog.AcroForm.Flatten()
+	if len(fields) != 3 || len(fields["group.b"].Field.Widgets) != 1 {
+		t.Fatalf("unexpected fields %v", fields)
+	}
+	out = doc.ExtractPages(nil)
+	if fields = out.Catalog.AcroForm.Flatten(); len(fields) != 1 {
+		t.Fatalf("unexpected fields %v", fields)
+	}
+
+	// the original document is not modified
+	if len(doc.Catalog.Pages.Flatten()) != 3 || len(a.Widgets) != 2 || len(group.Kids) != 2 {
+		t.Fatal("original document modified")
+	}
+}
+
+func TestRenameNames(t *testing.T) {
+	renames := map[Name]Name{"F1": "F1_1", "Im1": "Im1_2"}
+	for _, test := range [][2]string{
+		{"BT /F1 12 Tf (/F1) Tj ET", "BT /F1_1 12 Tf (/F1) Tj ET"},
+		{"/F1 12 Tf/Im1 Do /F12 Tf", "/F1_1 12 Tf/Im1_2 Do /F12 Tf"},
+		{"/F1", "/F1_1"},
+		{"BI /W 2 /H 1 ID \x00/F1 EI /Im1 Do", "BI /W 2 /H 1 ID \x00/F1 EI /Im1_2 Do"},
+		{"q /GS0 gs Q", "q /GS0 gs Q"},
+	} {
+		got, err := renameNames([]byte(test[0]), renames)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != test[1] {
+			t.Errorf("expected %q, got %q", test[1], got)
+		}
+	}
+}
+
+func TestAppendContent(t *testing.T) {
+	font1, font2 := &FontDict{Subtype: FontType1{BaseFont: "Helvetica"}}, &FontDict{Subtype: FontType1{BaseFont: "Courier"}}
+	img := &XObjectImage{Image: Image{Width: 1, Height: 1}}
+	page := PageObject{
+		Resources: &ResourcesDict{Font: map[Name]*FontDict{"F1": font1}},
+		Contents:  []ContentStream{{Stream: Stream{Content: []byte("1 0 0 1 50 50 cm BT /F1 10 Tf (a) Tj ET")}}},
+	}
+	original := page.Resources
+
+	err := page.AppendContent([]byte("BT /F1 10 Tf (b) Tj ET /F2 Do /X Do"), ResourcesDict{
+		Font:    map[Name]*FontDict{"F1": font2},
+		XObject: map[Name]XObject{"F2": img, "X": img},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(original.Font) != 1 || original.XObject != nil {
+		t.Fatal("original resources should not be modified")
+	}
+	if res := page.Resources; res.Font["F1"] != font1 || res.Font["F1_1"] != font2 || res.XObject["F2"] != img || res.XObject["X"] != img {
+		t.Fatalf("unexpected resources %v %v", res.Font, res.XObject)
+	}
+	if len(page.Contents) != 3 {
+		t.Fatalf("unexpected contents %v", page.Contents)
+	}
+	if got := string(page.Contents[2].Content); got != "Q\nq\nBT /F1_1 10 Tf (b) Tj ET /F2 Do /X Do\nQ" {
+		t.Fatalf("unexpected content %q", got)
+	}
+
+	// identical resources are shared
+	err = page.AppendContent([]byte("/X Do"), ResourcesDict{XObject: map[Name]XObject{"X": img}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Resources.XObject) != 2 || string(page.Contents[4].Content) != "Q\nq\n/X Do\nQ" {
+		t.Fatalf("unexpected resources %v", page.Resources.XObject)
+	}
+
+	var empty PageObject
+	if err = empty.AppendContent([]byte("0 0 10 10 re f"), ResourcesDict{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(empty.Contents) != 1 || string(empty.Contents[0].Content) != "q\n0 0 10 10 re f\nQ" {
+		t.Fatalf("unexpected contents %v", empty.Contents)
+	}
+
+	// the names of all categories are considered
+	res := ResourcesDict{Font: map[Name]*FontDict{"X0": font1}, XObject: map[Name]XObject{"X1": img}}
+	if name := res.UnusedName("X"); name != "X2" {
+		t.Fatalf("unexpected name %s", name)
+	}
+}
+
+func TestBookmarks(t *testing.T) {
+	page := new(PageObject)
+	var outline Outline
+	if len(outline.Flatten()) != 0 {
+		t.Fatal("expected empty outline")
+	}
+	chap1 := outline.AddBookmark("Chapter 1", page, 700)
+	chap3 := outline.AddBookmark("Chapter 3", page, 500)
+	sec1 := chap1.AddChild("Section 1.1", page, 650)
+	sec2 := chap1.AddChild("Section 1.2", page, 600)
+	chap2 := chap1.AddSibling("Chapter 2", page, 550)
+
+	if outline.First != chap1 || outline.Last() != chap3 || chap1.Next != chap2 || chap2.Next != chap3 {
+		t.Fatal("unexpected top-level items")
+	}
+	if chap3.Prev() != chap2 || chap2.Parent != &outline || chap1.Last() != sec2 || sec2.Prev() != sec1 || sec1.Parent != chap1 {
+		t.Fatal("unexpected links")
+	}
+	if dest := sec2.Dest.(DestinationExplicitIntern); dest.Page != page || dest.Location.(DestinationLocationXYZ).Top != ObjFloat(600) {
+		t.Fatalf("unexpected destination %v", dest)
+	}
+	chap1.Open = true
+	if outline.Count() != 5 || len(outline.Flatten()) != 5 {
+		t.Fatalf("unexpected count %d", outline.Count())
+	}
+}
+
+func TestOutlineFromHeadings(t *testing.T) {
+	outline := NewOutlineFromHeadings([]Heading{
+		{Level: 1, Title: "1"},
+		{Level: 2, Title: "1.1"},
+		{Level: 4, Title: "1.1.1"}, // skipped level
+		{Level: 2, Title: "1.2"},
+		{Level: 1, Title: "2"},
+		{Level: 0, Title: "3"},
+		{Level: 2, Title: "3.1", Dest: DestinationString("d")},
+	})
+	var titles []string
+	for _, item := range outline.Flatten() {
+		titles = append(titles, item.Title)
+	}
+	if exp := []string{"1", "1.1", "1.1.1", "1.2", "2", "3", "3.1"}; !reflect.DeepEqual(titles, exp) {
+		t.Fatalf("expected %v, got %v", exp, titles)
+	}
+	first := outline.First
+	if first.First.Title != "1.1" || first.First.First.Title != "1.1.1" || first.Last().Title != "1.2" {
+		t.Fatal("unexpected nesting")
+	}
+	if last := outline.Last(); last.Title != "3" || last.First.Dest != DestinationString("d") || last.First.Parent != last {
+		t.Fatal("unexpected last item")
+	}
+}
+
+func TestNormalizeBalance(t *testing.T) {
+	box1, box2 := &Rectangle{0, 0, 100, 100}, &Rectangle{0, 0, 200, 200}
+	p1, p2, p3 := &PageObject{}, &PageObject{}, &PageObject{MediaBox: box1}
+	// root -> chain -> chain (box2) -> [p1, p2, empty, p3]
+	tree := PageTree{MediaBox: box1, Kids: []PageNode{
+		&PageTree{Kids: []PageNode{
+			&PageTree{MediaBox: box2, Kids: []PageNode{p1, p2, &PageTree{}, p3}},
+		}},
+	}}
+	before := tree.FlattenInherit()
+
+	tree.Normalize()
+	if len(tree.Kids) != 3 || tree.MediaBox != box2 {
+		t.Fatalf("unexpected normalized tree %v", tree)
+	}
+	if after := tree.FlattenInherit(); !reflect.DeepEqual(before, after) {
+		t.Fatal("inherited attributes should be preserved")
+	}
+
+	var pages []*PageObject
+	for i := 0; i < 40; i++ {
+		pages = append(pages, &PageObject{})
+	}
+	tree = PageTree{MediaBox: box1}
+	for _, page := range pages {
+		tree.Kids = append(tree.Kids, page)
+	}
+	tree.Balance(4)
+	if len(tree.Kids) > 4 || tree.Count() != 40 {
+		t.Fatalf("unexpected balanced tree with %d kids", len(tree.Kids))
+	}
+	for i, page := range tree.Flatten() {
+		if page != pages[i] {
+			t.Fatal("page order should be preserved")
+		}
+		if page.MediaBox != box1 {
+			t.Fatal("inherited media box should be resolved")
+		}
+	}
+	var checkDepth func(node *PageTree, depth int)
+	leafDepth := -1
+	checkDepth = func(node *PageTree, depth int) {
+		for _, kid := range node.Kids {
+			switch kid := kid.(type) {
+			case *PageTree:
+				if len(kid.Kids) > 4 {
+					t.Fatalf("node with %d kids", len(kid.Kids))
+				}
+				checkDepth(kid, depth+1)
+			case *PageObject:
+				if leafDepth == -1 {
+					leafDepth = depth
+				} else if leafDepth != depth {
+					t.Fatal("pages should have the same depth")
+				}
+			}
+		}
+	}
+	checkDepth(&tree, 0)
+}
@@ -3,10 +3,12 @@ package model
 import (
 	"bytes"
 	"compress/zlib"
+	"errors"
 	"fmt"
 	"io"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/benoitkugler/pdf/reader/parser/filters"
 )
@@ -47,7 +49,12 @@ func (fi Filter) DecodeReader(r io.Reader) (io.Reader, error) {
 		return r, nil
 	}
 
-	return filters.NewFilter(string(fi.Name), fi.DecodeParms, r)
+	out, err := filters.NewFilter(string(fi.Name), fi.DecodeParms, r)
+	var unsupported filters.UnsupportedFilterErr
+	if errors.As(err, &unsupported) {
+		return nil, ErrUnsupportedFeature{Feature: "filter " + string(fi.Name), Err: err}
+	}
+	return out, err
 }
 
 // // NewFilter validate `s` and returns
@@ -102,8 +109,14 @@ type Stream struct {
 // NewCompressedStream compress the given content using
 // zlib, and return the corresponding Stream.
 func NewCompressedStream(content []byte) Stream {
+	return newFlateStream(content, zlib.BestSpeed)
+}
+
+// newFlateStream compress the given content using
+// zlib with the given level, which must be valid.
+func newFlateStream(content []byte, level int) Stream {
 	var buf bytes.Buffer
-	cmp, _ := zlib.NewWriterLevel(&buf, zlib.BestSpeed)
+	cmp, _ := zlib.NewWriterLevel(&buf, level)
 	cmp.Write(content)
 	cmp.Close()
 	return Stream{
@@ -122,6 +135,123 @@ func (s Stream) Decode() ([]byte, error) {
 	return io.ReadAll(r)
 }
 
+// DecodeTo is the same as Decode, but appends the decoded content
+// to `dst`, returning the extended slice. Passing a slice
+// with enough capacity avoids allocations, which is useful when
+// decoding many streams (see also BufferPool).
+func (s Stream) DecodeTo(dst []byte) ([]byte, error) {
+	r, err := s.Filter.DecodeReader(bytes.NewReader(s.Content))
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.NewBuffer(dst)
+	_, err = buf.ReadFrom(r)
+	return buf.Bytes(), err
+}
+
+// maxPooledBuffer is the capacity above which
+// the buffers are not retained by BufferPool
+const maxPooledBuffer = 4 << 20
+
+// BufferPool stores byte slices to be reused with Stream.DecodeTo,
+// reducing the allocations (and the GC pressure) when decoding
+// many temporary streams, such as page contents.
+// The zero value is ready to use, and it is safe for concurrent use.
+type BufferPool struct {
+	pool sync.Pool
+}
+
+// Get returns an empty slice, whose capacity may be used
+// to store decoded content.
+func (bp *BufferPool) Get() []byte {
+	if b, ok := bp.pool.Get().(*[]byte); ok {
+		return (*b)[:0]
+	}
+	return nil
+}
+
+// Put makes `b` available for the next calls to Get.
+// `b` (and the slices sharing its memory) must not be used afterwards.
+func (bp *BufferPool) Put(b []byte) {
+	if cap(b) == 0 || cap(b) > maxPooledBuffer {
+		return
+	}
+	bp.pool.Put(&b)
+}
+
+// PartialDecodeErr is returned by DecodePartial when
+// some filters are not supported.
+type PartialDecodeErr struct {
+	// Remaining are the filters which have not been applied,
+	// starting by the first unsupported one.
+	Remaining Filters
+}
+
+func (p PartialDecodeErr) Error() string {
+	names := make([]string, len(p.Remaining))
+	for i, fi := range p.Remaining {
+		names[i] = string(fi.Name)
+	}
+	return fmt.Sprintf("unsupported filters: %s", strings.Join(names, ", "))
+}
+
+// DecodePartial is the same as Decode, but, when a filter is not supported,
+// the filters preceding it are still applied, and the intermediate
+// content is returned alongside a PartialDecodeErr.
+// This is useful to delegate the remaining filters (such as JBIG2Decode or JPXDecode)
+// to external decoders.
+func (s Stream) DecodePartial() ([]byte, error) {
+	var r io.Reader = bytes.NewReader(s.Content)
+	for i, fi := range s.Filter {
+		next, err := fi.DecodeReader(r)
+		var unsupported filters.UnsupportedFilterErr
+		if errors.As(err, &unsupported) {
+			content, err := io.ReadAll(r)
+			if err != nil {
+				return nil, err
+			}
+			return content, PartialDecodeErr{Remaining: s.Filter[i:]}
+		} else if err != nil {
+			return nil, err
+		}
+		r = next
+	}
+	return io.ReadAll(r)
+}
+
+// Encode applies the given filters on the content of `s`, returning
+// a new stream whose Filter entry starts with `names`, followed by the
+// current filters of `s`.
+// `names` are given in decoding order, as in the Filter entry, so
+// that they are actually applied in reverse order.
+// Only Flate, LZW and RunLength are supported.
+func (s Stream) Encode(names ...Name) (Stream, error) {
+	content := s.Content
+	for i := len(names) - 1; i >= 0; i-- {
+		var buf bytes.Buffer
+		w, err := filters.NewEncoder(string(names[i]), &buf)
+		if err != nil {
+			return Stream{}, err
+		}
+		if _, err = w.Write(content); err != nil {
+			return Stream{}, err
+		}
+		if err = w.Close(); err != nil {
+			return Stream{}, err
+		}
+		content = buf.Bytes()
+	}
+
+	out := Stream{Content: content, Filter: make(Filters, 0, len(names)+len(s.Filter))}
+	for _, name := range names {
+		out.Filter = append(out.Filter, Filter{Name: name})
+	}
+	for _, f := range s.Filter {
+		out.Filter = append(out.Filter, f.Clone())
+	}
+	return out, nil
+}
+
 func (c Stream) Length() int { return len(c.Content) }
 
 // Clone returns a deep copy of the stream
@@ -231,6 +361,8 @@ type XObjectForm struct {
 	// not both.
 	// Optional
 	StructParent, StructParents MaybeInt
+
+	OC OptionalContent // optional
 }
 
 // GetStructParent implements StructParentObject
@@ -254,6 +386,9 @@ func (f XObjectForm) commonFields(pdf pdfWriter, ref Reference) StreamHeader {
 	} else if f.StructParents != nil {
 		args.Fields["StructParents"] = f.StructParents.(ObjInt).Write(nil, 0)
 	}
+	if f.OC != nil {
+		args.Fields["OC"] = writeOptionalContent(pdf, f.OC)
+	}
 	return args
 }
 
@@ -271,6 +406,7 @@ func (f *XObjectForm) clone(cache cloneCache) Referenceable {
 	out := *f
 	out.ContentStream = f.ContentStream.Clone()
 	out.Resources = f.Resources.clone(cache)
+	out.OC = cloneOptionalContent(f.OC, cache)
 	return &out
 }
 
@@ -404,6 +540,7 @@ type XObjectImage struct {
 	SMask        *ImageSMask      // optional
 	SMaskInData  uint8            // optional, 0, 1 or 2
 	StructParent MaybeInt         // required if the image is a structural content item
+	OC           OptionalContent  // optional
 }
 
 // GetStructParent implements StructParentObject
@@ -438,6 +575,9 @@ func (f *XObjectImage) pdfContent(pdf pdfWriter, ref Reference) (StreamHeader, s
 	if f.StructParent != nil {
 		base.Fields["StructParent"] = f.StructParent.(ObjInt).Write(nil, 0)
 	}
+	if f.OC != nil {
+		base.Fields["OC"] = writeOptionalContent(pdf, f.OC)
+	}
 	return base, "", f.Content
 }
 
@@ -459,6 +599,7 @@ func (img *XObjectImage) clone(cache cloneCache) Referenceable {
 		out.Alternates[i] = alt.clone(cache)
 	}
 	out.SMask = cache.checkOrClone(img.SMask).(*ImageSMask)
+	out.OC = cloneOptionalContent(img.OC, cache)
 	return &out
 }
 
@@ -1 +1,116 @@
 package model
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestStreamEncode(t *testing.T) {
+	data := bytes.Repeat([]byte("BT /F1 12 Tf (Hello) Tj ET\n"), 50)
+	for _, source := range []Stream{{Content: data}, NewCompressedStream(data)} {
+		encoded, err := source.Encode(LZW, RunLength)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if L := len(encoded.Filter); L != 2+len(source.Filter) || encoded.Filter[0].Name != LZW {
+			t.Fatalf("unexpected filters %v", encoded.Filter)
+		}
+		decoded, err := encoded.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(decoded, data) {
+			t.Fatalf("invalid roundtrip for %v", encoded.Filter)
+		}
+	}
+
+	if _, err := (Stream{}).Encode(DCT); err == nil {
+		t.Fatal("expected error for unsupported filter")
+	}
+}
+
+func TestDecodePartial(t *testing.T) {
+	data := []byte("some JBIG2 encoded data")
+	stream := NewCompressedStream(data)
+	stream.Filter = append(stream.Filter, Filter{Name: JBIG2, DecodeParms: map[string]int{"K": 1}})
+
+	if _, err := stream.Decode(); err == nil {
+		t.Fatal("expected error for unsupported filter")
+	}
+	content, err := stream.DecodePartial()
+	var partial PartialDecodeErr
+	if !errors.As(err, &partial) {
+		t.Fatalf("expected partial decoding error, got %v", err)
+	}
+	if !bytes.Equal(content, data) {
+		t.Fatalf("unexpected intermediate content %s", content)
+	}
+	if len(partial.Remaining) != 1 || partial.Remaining[0].Name != JBIG2 {
+		t.Fatalf("unexpected remaining filters %v", partial.Remaining)
+	}
+
+	stream = NewCompressedStream(data)
+	content, err = stream.DecodePartial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(content, data) {
+		t.Fatalf("unexpected content %s", content)
+	}
+}
+
+func TestDecodeTo(t *testing.T) {
+	data := bytes.Repeat([]byte("0 0 m 100 100 l S\n"), 100)
+	stream := NewCompressedStream(data)
+
+	out, err := stream.DecodeTo([]byte("prefix "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, append([]byte("prefix "), data...)) {
+		t.Fatal("invalid decoded content")
+	}
+
+	page := PageObject{Contents: []ContentStream{{Stream: stream}, {Stream: Stream{Content: []byte("Q")}}}}
+	var pool BufferPool
+	buf := pool.Get()
+	all, err := page.DecodeAllContentsTo(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(all, append(data, 'Q')) {
+		t.Fatal("invalid decoded page content")
+	}
+	pool.Put(all)
+	if buf = pool.Get(); len(buf) != 0 {
+		t.Fatalf("expected empty buffer, got length %d", len(buf))
+	}
+
+	if _, err = (Stream{Content: data, Filter: Filters{{Name: DCT}}}).DecodeTo(nil); err == nil {
+		t.Fatal("expected error for unsupported filter")
+	}
+}
+
+func BenchmarkDecodeTo(b *testing.B) {
+	stream := NewCompressedStream(bytes.Repeat([]byte("BT /F1 12 Tf (Hello) Tj ET\n"), 2000))
+	b.Run("Decode", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := stream.Decode(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("DecodeTo pool", func(b *testing.B) {
+		var pool BufferPool
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			content, err := stream.DecodeTo(pool.Get())
+			if err != nil {
+				b.Fatal(err)
+			}
+			pool.Put(content)
+		}
+	})
+}
@@ -110,12 +110,15 @@ func (s *StructureTree) BuildParentTree() {
 	//	- the map is then transformed into a tree
 	tmp := make(map[int]NumToParent)
 
-	var walk func(se *StructureElement)
-	walk = func(se *StructureElement) {
+	var walk func(se *StructureElement, page *PageObject)
+	walk = func(se *StructureElement, page *PageObject) {
+		if se.Pg != nil {
+			page = se.Pg
+		}
 		for _, kid := range se.K {
 			switch kid := kid.(type) {
 			case *StructureElement:
-				walk(kid) // recursion
+				walk(kid, page) // recursion
 			case ContentItemMarkedReference:
 				var structParents MaybeInt
 				switch ct := kid.Container.(type) {
@@ -123,12 +126,18 @@ func (s *StructureTree) BuildParentTree() {
 					structParents = ct.StructParents
 				case *XObjectForm:
 					structParents = ct.StructParents
-				case nil: // default to the structure element
-					structParents = se.Pg.StructParents
+				case nil: // default to the (inherited) page of the structure element
+					if page != nil {
+						structParents = page.StructParents
+					}
 				}
-				if sp, ok := structParents.(ObjInt); ok {
+				if sp, ok := structParents.(ObjInt); ok && kid.MCID >= 0 {
+					// the array is indexed by MCID
 					a := tmp[int(sp)]
-					a.Parents = append(a.Parents, se)
+					for len(a.Parents) <= kid.MCID {
+						a.Parents = append(a.Parents, nil)
+					}
+					a.Parents[kid.MCID] = se
 					tmp[int(sp)] = a
 				}
 			case ContentItemObjectReference:
@@ -142,12 +151,47 @@ func (s *StructureTree) BuildParentTree() {
 		}
 	}
 	for _, se := range s.K {
-		walk(se)
+		walk(se, nil)
 	}
 
 	s.ParentTree = NewParentTree(tmp)
 }
 
+// MarkedContentElements returns the structure elements
+// enclosing the marked-content sequences of `container`, a page or a form XObject,
+// indexed by marked-content identifier (MCID).
+// Marked-content references without explicit container are attributed
+// to the page of the nearest structure element ancestor.
+// Together with contentstream.MarkedContents, it may be used to
+// associate the content of a page with its logical structure.
+func (s StructureTree) MarkedContentElements(container ContentMarkedContainer) map[int]*StructureElement {
+	out := make(map[int]*StructureElement)
+	var walk func(se *StructureElement, page *PageObject)
+	walk = func(se *StructureElement, page *PageObject) {
+		if se.Pg != nil {
+			page = se.Pg
+		}
+		for _, kid := range se.K {
+			switch kid := kid.(type) {
+			case *StructureElement:
+				walk(kid, page)
+			case ContentItemMarkedReference:
+				kidContainer := kid.Container
+				if kidContainer == nil && page != nil {
+					kidContainer = page
+				}
+				if kidContainer == container {
+					out[kid.MCID] = se
+				}
+			}
+		}
+	}
+	for _, se := range s.K {
+		walk(se, nil)
+	}
+	return out
+}
+
 func (s *StructureTree) clone(cache cloneCache) *StructureTree {
 	if s == nil {
 		return nil
@@ -192,7 +236,7 @@ func (s StructureTree) pdfString(pdf pdfWriter, ref Reference) string {
 	refs := make([]Reference, len(s.K))
 	for i, k := range s.K {
 		kidRef := pdf.CreateObject()
-		pdf.WriteObject(k.pdfString(pdf, kidRef, 0), kidRef)
+		pdf.WriteObject(k.pdfString(pdf, kidRef, ref), kidRef)
 		refs[i] = kidRef
 	}
 
@@ -220,6 +264,70 @@ func (s StructureTree) pdfString(pdf pdfWriter, ref Reference) string {
 	return b.String()
 }
 
+// StandardType follows the RoleMap to return the standard structure type
+// to which `t` is mapped. If `t` is not mapped, it is returned as it is.
+// Cycles in the RoleMap are detected and stop the resolution.
+func (s StructureTree) StandardType(t Name) Name {
+	seen := map[Name]bool{t: true}
+	for {
+		next, ok := s.RoleMap[t]
+		if !ok || seen[next] {
+			return t
+		}
+		seen[next] = true
+		t = next
+	}
+}
+
+// Attributes returns the attributes of `se`, in increasing priority order:
+// the attributes from the classes of `se` (resolved using the ClassMap) come first,
+// followed by the attributes directly defined by `se`.
+func (s StructureTree) Attributes(se *StructureElement) []AttributeObject {
+	var out []AttributeObject
+	for _, class := range se.C {
+		out = append(out, s.ClassMap[class.Name]...)
+	}
+	return append(out, se.A...)
+}
+
+// Attribute returns the value of the attribute `key`, defined by the
+// owner `owner` (see `AttributeOwnerLayout` for example), taking into account
+// the precedence rules of 14.7.5.2 - Attribute Classes.
+// If the attribute is not found, `nil` is returned.
+func (s StructureTree) Attribute(se *StructureElement, owner, key Name) Object {
+	attrs := s.Attributes(se)
+	for i := len(attrs) - 1; i >= 0; i-- {
+		attr := attrs[i]
+		if attr.O != owner {
+			continue
+		}
+		if v, ok := attr.Attributes[key]; ok {
+			return v
+		}
+	}
+	return nil
+}
+
+// Walk performs a depth-first walk through the structure elements
+// of the tree, calling `fn` for each of them. If `fn` returns false, the
+// kids of the element are skipped.
+func (s StructureTree) Walk(fn func(se *StructureElement) bool) {
+	var walk func(se *StructureElement)
+	walk = func(se *StructureElement) {
+		if !fn(se) {
+			return
+		}
+		for _, kid := range se.K {
+			if kid, ok := kid.(*StructureElement); ok {
+				walk(kid)
+			}
+		}
+	}
+	for _, se := range s.K {
+		walk(se)
+	}
+}
+
 type ClassName struct {
 	Name           Name
 	RevisionNumber int // optional, default to 0
@@ -250,11 +358,28 @@ type StructureElement struct {
 	ActualText string            // optional, text string
 }
 
+// MarkedContents returns the marked-content sequences directly
+// referenced by `se`. The containers of the items are resolved, meaning that
+// items without an explicit container are attributed to the page `se.Pg`.
+func (se *StructureElement) MarkedContents() []ContentItemMarkedReference {
+	var out []ContentItemMarkedReference
+	for _, kid := range se.K {
+		if kid, ok := kid.(ContentItemMarkedReference); ok {
+			if kid.Container == nil && se.Pg != nil {
+				kid.Container = se.Pg
+			}
+			out = append(out, kid)
+		}
+	}
+	return out
+}
+
 // `own` reference is needed to encrypt, and for the kids
+// `parent` is either the parent structure element or the structure tree root
 func (s *StructureElement) pdfString(pdf pdfWriter, own, parent Reference) string {
 	b := newBuffer()
 	b.fmt("<</S%s", s.S)
-	if s.P != nil {
+	if parent != 0 {
 		b.fmt("/P %s", parent)
 	}
 	if s.ID != "" {
@@ -456,6 +581,22 @@ func (c ContentItemObjectReference) clone(cache cloneCache) ContentItem {
 	return out
 }
 
+// Standard attribute owners, used as AttributeObject.O
+// See 14.8.5 - Standard Structure Attributes
+const (
+	AttributeOwnerLayout     Name = "Layout"
+	AttributeOwnerList       Name = "List"
+	AttributeOwnerPrintField Name = "PrintField"
+	AttributeOwnerTable      Name = "Table"
+	AttributeOwnerXML        Name = "XML-1.00"
+	AttributeOwnerHTML       Name = "HTML-3.20"
+	AttributeOwnerHTML4      Name = "HTML-4.01"
+	AttributeOwnerOEB        Name = "OEB-1.00"
+	AttributeOwnerRTF        Name = "RTF-1.05"
+	AttributeOwnerCSS1       Name = "CSS-1.00"
+	AttributeOwnerCSS2       Name = "CSS-2.00"
+)
+
 // AttributeObject is represented by a single or a pair of array
 // elements, the first or only element shall contain the attribute object itself
 // and the second (when present) shall contain the integer revision number
@@ -0,0 +1,114 @@
+package model
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestStructureRoleMap(t *testing.T) {
+	tree := StructureTree{RoleMap: map[Name]Name{"Title": "Heading", "Heading": "H1", "Loop1": "Loop2", "Loop2": "Loop1"}}
+	if st := tree.StandardType("Title"); st != "H1" {
+		t.Fatalf("unexpected type %s", st)
+	}
+	if st := tree.StandardType("P"); st != "P" {
+		t.Fatalf("unexpected type %s", st)
+	}
+	if st := tree.StandardType("Loop1"); st != "Loop2" {
+		t.Fatalf("unexpected type %s", st)
+	}
+}
+
+func TestStructureAttributes(t *testing.T) {
+	tree := StructureTree{ClassMap: map[Name][]AttributeObject{
+		"Red": {{O: AttributeOwnerLayout, Attributes: map[Name]Object{"Color": ObjArray{ObjInt(1), ObjInt(0), ObjInt(0)}, "Placement": Name("Block")}}},
+	}}
+	page := &PageObject{}
+	se := &StructureElement{
+		S:  "P",
+		Pg: page,
+		C:  []ClassName{{Name: "Red"}},
+		A:  []AttributeObject{{O: AttributeOwnerLayout, Attributes: map[Name]Object{"Placement": Name("Inline")}}},
+		K:  []ContentItem{ContentItemMarkedReference{MCID: 2}},
+	}
+	tree.K = []*StructureElement{{S: "Document", K: []ContentItem{se}}}
+	se.P = tree.K[0]
+
+	if v := tree.Attribute(se, AttributeOwnerLayout, "Placement"); v != Name("Inline") {
+		t.Fatalf("unexpected attribute %v", v)
+	}
+	if v := tree.Attribute(se, AttributeOwnerLayout, "Color"); v == nil {
+		t.Fatal("missing class attribute")
+	}
+	if v := tree.Attribute(se, AttributeOwnerTable, "Color"); v != nil {
+		t.Fatalf("unexpected attribute %v", v)
+	}
+
+	if mcs := se.MarkedContents(); len(mcs) != 1 || mcs[0].Container != page {
+		t.Fatalf("unexpected marked contents %v", mcs)
+	}
+
+	var types []string
+	tree.Walk(func(se *StructureElement) bool {
+		types = append(types, string(se.S))
+		return true
+	})
+	if s := strings.Join(types, " "); s != "Document P" {
+		t.Fatalf("unexpected walk %s", s)
+	}
+
+	doc := Document{}
+	doc.Catalog.Pages.Kids = []PageNode{page}
+	doc.Catalog.StructTreeRoot = &tree
+	var b bytes.Buffer
+	if err := doc.Write(&b, nil); err != nil {
+		t.Fatal(err)
+	}
+	// both elements must have a parent
+	if n := strings.Count(b.String(), "/P "); n != 2 {
+		t.Fatalf("expected 2 /P entries, got %d", n)
+	}
+}
+
+func TestMarkedContentElements(t *testing.T) {
+	page1, page2 := &PageObject{StructParents: ObjInt(0)}, &PageObject{StructParents: ObjInt(1)}
+	form := &XObjectForm{StructParents: ObjInt(2)}
+	p1 := &StructureElement{S: "P", K: []ContentItem{ContentItemMarkedReference{MCID: 3}}}
+	p2 := &StructureElement{S: "P", K: []ContentItem{ContentItemMarkedReference{MCID: 1}, ContentItemMarkedReference{MCID: 0, Container: form}}}
+	p3 := &StructureElement{S: "P", Pg: page2, K: []ContentItem{ContentItemMarkedReference{MCID: 0, Container: page1}}}
+	tree := StructureTree{K: []*StructureElement{
+		{S: "Sect", Pg: page1, K: []ContentItem{p1, p2}}, // the page is inherited
+		{S: "Sect", Pg: page2, K: []ContentItem{p3}},
+	}}
+
+	if m := tree.MarkedContentElements(page1); len(m) != 3 || m[0] != p3 || m[1] != p2 || m[3] != p1 {
+		t.Fatalf("unexpected elements %v", m)
+	}
+	if m := tree.MarkedContentElements(page2); len(m) != 0 {
+		t.Fatalf("unexpected elements %v", m)
+	}
+	if m := tree.MarkedContentElements(form); len(m) != 1 || m[0] != p2 {
+		t.Fatalf("unexpected elements %v", m)
+	}
+
+	tree.BuildParentTree()
+	parents := tree.ParentTree.LookupTable()
+	// the array is indexed by MCID
+	if got := parents[0].Parents; len(got) != 4 || got[0] != p3 || got[1] != p2 || got[2] != nil || got[3] != p1 {
+		t.Fatalf("unexpected parents %v", got)
+	}
+	if got := parents[2].Parents; len(got) != 1 || got[0] != p2 {
+		t.Fatalf("unexpected parents %v", got)
+	}
+
+	doc := Document{}
+	doc.Catalog.Pages.Kids = []PageNode{page1, page2}
+	doc.Catalog.StructTreeRoot = &tree
+	var b bytes.Buffer
+	if err := doc.Write(&b, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), " null ") {
+		t.Fatal("expected null entry for unused MCID")
+	}
+}
@@ -0,0 +1,135 @@
+package model
+
+import "fmt"
+
+// Theme gathers default styling options, used when generating
+// annotations and form field appearances, so that applications may
+// enforce a consistent styling without repeating options for each annotation.
+// It is not written in the PDF file, and only defines defaults: the
+// entries already set in annotations or fields (or explicitly requested) take precedence.
+// See Document.Theme and Document.ApplyTheme.
+type Theme struct {
+	Font      *FontDict // optional, default font for text
+	FontSize  Fl        // optional, default font size for text (0 for automatic size in form fields)
+	TextColor Color     // optional, default text color
+
+	BorderColor     Color        // optional, for links, shapes and form fields
+	BackgroundColor Color        // optional, interior color of shapes and form fields
+	Border          *BorderStyle // optional, for links, free texts, shapes and form fields
+
+	// HighlightColor is used by the text markup annotations
+	// (highlight, underline, squiggly and strike out)
+	HighlightColor Color // optional
+}
+
+// themeFontName is the resource name used to register the theme font
+// in the interactive form resources
+const themeFontName ObjName = "ThemeFont"
+
+// da returns a default appearance string using the text font size and color
+func (t *Theme) da(font ObjName) string {
+	b := newBuffer()
+	b.fmt("%s %s Tf", font, FmtFloat(t.FontSize))
+	switch c := t.TextColor; len(c) {
+	case 1:
+		b.fmt(" %s g", FmtFloat(c[0]))
+	case 3:
+		b.fmt(" %s %s %s rg", FmtFloat(c[0]), FmtFloat(c[1]), FmtFloat(c[2]))
+	case 4:
+		b.fmt(" %s %s %s %s k", FmtFloat(c[0]), FmtFloat(c[1]), FmtFloat(c[2]), FmtFloat(c[3]))
+	}
+	return b.String()
+}
+
+// themeFont returns the name of the theme font in the interactive form resources,
+// adding it if needed.
+func (doc *Document) themeFont() ObjName {
+	dr := &doc.Catalog.AcroForm.DR
+	for name, font := range dr.Font {
+		if font == doc.Theme.Font {
+			return name
+		}
+	}
+	if dr.Font == nil {
+		dr.Font = make(map[ObjName]*FontDict)
+	}
+	name := themeFontName
+	for i := 1; dr.Font[name] != nil; i++ {
+		name = ObjName(fmt.Sprintf("%s%d", string(themeFontName), i))
+	}
+	dr.Font[name] = doc.Theme.Font
+	return name
+}
+
+// ApplyTheme completes the styling entries of `annot` which are not
+// already set, using the document Theme (it is a no-op if the theme is nil):
+//   - the border style of links, free texts, lines, squares, circles, polygons, polylines and inks
+//   - the color (C entry) of links, lines, squares, circles, polygons, polylines and inks,
+//     and the highlight color of text markup annotations
+//   - the interior color of squares, circles and polygons
+//   - the default appearance of free texts, which requires a theme font, added
+//     to the interactive form resources
+//
+// Appearance streams are not generated.
+func (doc *Document) ApplyTheme(annot *AnnotationDict) {
+	theme := doc.Theme
+	if theme == nil || annot == nil {
+		return
+	}
+	setColor := func(c Color) {
+		if annot.C == nil && c != nil {
+			annot.C = append([]Fl(nil), c...)
+		}
+	}
+	setBorder := func(bs **BorderStyle) {
+		if *bs == nil {
+			*bs = theme.Border.Clone()
+		}
+	}
+	setInterior := func(ic *[]Fl) {
+		if *ic == nil && theme.BackgroundColor != nil {
+			*ic = append([]Fl(nil), theme.BackgroundColor...)
+		}
+	}
+	switch st := annot.Subtype.(type) {
+	case AnnotationLink:
+		setColor(theme.BorderColor)
+		setBorder(&st.BS)
+		annot.Subtype = st
+	case AnnotationFreeText:
+		setBorder(&st.BS)
+		if st.DA == "" && theme.Font != nil {
+			st.DA = theme.da(doc.themeFont())
+		}
+		annot.Subtype = st
+	case AnnotationLine:
+		setColor(theme.BorderColor)
+		setBorder(&st.BS)
+		annot.Subtype = st
+	case AnnotationSquare:
+		setColor(theme.BorderColor)
+		setBorder(&st.BS)
+		setInterior(&st.IC)
+		annot.Subtype = st
+	case AnnotationCircle:
+		setColor(theme.BorderColor)
+		setBorder(&st.BS)
+		setInterior(&st.IC)
+		annot.Subtype = st
+	case AnnotationPolygon:
+		setColor(theme.BorderColor)
+		setBorder(&st.BS)
+		setInterior(&st.IC)
+		annot.Subtype = st
+	case AnnotationPolyLine:
+		setColor(theme.BorderColor)
+		setBorder(&st.BS)
+		annot.Subtype = st
+	case AnnotationInk:
+		setColor(theme.BorderColor)
+		setBorder(&st.BS)
+		annot.Subtype = st
+	case AnnotationHighlight, AnnotationUnderline, AnnotationSquiggly, AnnotationStrikeOut:
+		setColor(theme.HighlightColor)
+	}
+}
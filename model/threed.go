@@ -0,0 +1,408 @@
+package model
+
+import "fmt"
+
+// Stream3D contains the 3D artwork of a 3D annotation,
+// either in the U3D or in the PRC format.
+// The artwork content is stored as is, so that it
+// is preserved when the document is written back.
+// See 13.6.3 - 3D Streams
+type Stream3D struct {
+	Stream
+
+	Subtype Name         // required, U3D or PRC
+	VA      []View3D     // optional, list of named views
+	DV      View3DSelect // optional, default view, nil means the first entry of VA (or the view specified in the artwork)
+}
+
+func (s *Stream3D) pdfContent(pdf pdfWriter, ref Reference) (StreamHeader, string, []byte) {
+	args := s.PDFCommonFields(true)
+	args.Fields["Type"] = "/3D"
+	args.Fields["Subtype"] = s.Subtype.String()
+	if len(s.VA) != 0 {
+		b := newBuffer()
+		b.WriteString("[")
+		for _, view := range s.VA {
+			b.WriteString(view.pdfString(pdf, ref))
+		}
+		b.WriteString("]")
+		args.Fields["VA"] = b.String()
+	}
+	if s.DV != nil {
+		args.Fields["DV"] = s.DV.view3DString(pdf, ref)
+	}
+	return args, "", s.Content
+}
+
+// clone returns a deep copy, with concrete type `*Stream3D`
+func (s *Stream3D) clone(cache cloneCache) Referenceable {
+	if s == nil {
+		return s
+	}
+	out := *s
+	out.Stream = s.Stream.Clone()
+	if s.VA != nil {
+		out.VA = make([]View3D, len(s.VA))
+		for i, v := range s.VA {
+			out.VA[i] = v.clone()
+		}
+	}
+	if s.DV != nil {
+		out.DV = s.DV.cloneView3D()
+	}
+	return &out
+}
+
+// View3DSelect selects a 3D view, and is one of
+// View3DIndex, View3DName, View3DInternalName or *View3D
+type View3DSelect interface {
+	view3DString(pdf pdfWriter, ref Reference) string
+	cloneView3D() View3DSelect
+}
+
+// View3DIndex is an index in the views of the 3D stream (see Stream3D.VA).
+type View3DIndex int
+
+// View3DName is one of F (first view), L (last view) or D (default view).
+type View3DName Name
+
+// View3DInternalName refers to the view with the given internal name (see View3D.IN).
+type View3DInternalName string
+
+func (v View3DIndex) view3DString(pdfWriter, Reference) string { return fmt.Sprintf("%d", v) }
+func (v View3DName) view3DString(pdfWriter, Reference) string  { return Name(v).String() }
+func (v View3DInternalName) view3DString(pdf pdfWriter, ref Reference) string {
+	return pdf.EncodeString(string(v), TextString, ref)
+}
+func (v *View3D) view3DString(pdf pdfWriter, ref Reference) string { return v.pdfString(pdf, ref) }
+
+func (v View3DIndex) cloneView3D() View3DSelect        { return v }
+func (v View3DName) cloneView3D() View3DSelect         { return v }
+func (v View3DInternalName) cloneView3D() View3DSelect { return v }
+func (v *View3D) cloneView3D() View3DSelect {
+	if v == nil {
+		return v
+	}
+	out := v.clone()
+	return &out
+}
+
+// View3D specifies parameters to be applied to the virtual camera
+// associated with a 3D annotation.
+// See Table 315 – Entries in a 3D view dictionary
+type View3D struct {
+	XN      string        // required, external name
+	IN      string        // optional, internal name
+	MS      Name          // optional, matrix specification: M or U3D
+	C2W     []Fl          // optional, 12 elements 3D transformation matrix (camera to world), used when MS is M
+	U3DPath []string      // text strings, path to the camera node, required when MS is U3D
+	CO      Fl            // optional, distance to the center of orbit
+	P       *Projection3D // optional
+	BG      *Background3D // optional
+
+	// The render mode, lighting scheme, cross sections and node
+	// dictionaries are stored as is.
+	RM ObjDict  // optional, render mode dictionary
+	LS ObjDict  // optional, lighting scheme dictionary
+	SA ObjArray // optional, array of cross section dictionaries
+	NA ObjArray // optional, array of 3D node dictionaries
+}
+
+func (v View3D) pdfString(pdf pdfWriter, ref Reference) string {
+	b := newBuffer()
+	b.fmt("<</Type/3DView/XN %s", pdf.EncodeString(v.XN, TextString, ref))
+	if v.IN != "" {
+		b.fmt("/IN %s", pdf.EncodeString(v.IN, TextString, ref))
+	}
+	if v.MS != "" {
+		b.fmt("/MS %s", v.MS)
+	}
+	if len(v.C2W) != 0 {
+		b.fmt("/C2W %s", writeFloatArray(v.C2W))
+	}
+	if len(v.U3DPath) == 1 {
+		b.fmt("/U3DPath %s", pdf.EncodeString(v.U3DPath[0], TextString, ref))
+	} else if len(v.U3DPath) != 0 {
+		b.fmt("/U3DPath %s", writeStringsArray(v.U3DPath, pdf, TextString, ref))
+	}
+	if v.CO != 0 {
+		b.fmt("/CO %s", FmtFloat(v.CO))
+	}
+	if v.P != nil {
+		b.fmt("/P %s", v.P)
+	}
+	if v.BG != nil {
+		b.fmt("/BG %s", v.BG)
+	}
+	if v.RM != nil {
+		b.fmt("/RM %s", v.RM.Write(pdf, ref))
+	}
+	if v.LS != nil {
+		b.fmt("/LS %s", v.LS.Write(pdf, ref))
+	}
+	if v.SA != nil {
+		b.fmt("/SA %s", v.SA.Write(pdf, ref))
+	}
+	if v.NA != nil {
+		b.fmt("/NA %s", v.NA.Write(pdf, ref))
+	}
+	b.WriteString(">>")
+	return b.String()
+}
+
+func (v View3D) clone() View3D {
+	out := v
+	out.C2W = append([]Fl(nil), v.C2W...)
+	out.U3DPath = append([]string(nil), v.U3DPath...)
+	if v.P != nil {
+		p := *v.P
+		out.P = &p
+	}
+	if v.BG != nil {
+		bg := v.BG.clone()
+		out.BG = &bg
+	}
+	if v.RM != nil {
+		out.RM = v.RM.Clone().(ObjDict)
+	}
+	if v.LS != nil {
+		out.LS = v.LS.Clone().(ObjDict)
+	}
+	if v.SA != nil {
+		out.SA = v.SA.Clone().(ObjArray)
+	}
+	if v.NA != nil {
+		out.NA = v.NA.Clone().(ObjArray)
+	}
+	return out
+}
+
+// Projection3D specifies the projection of a 3D view.
+// See Table 316 – Entries in a projection dictionary
+type Projection3D struct {
+	Subtype Name // required, O (orthographic) or P (perspective)
+	CS      Name // optional, clipping style: XNF or ANF
+	F       Fl   // optional, far clipping distance
+	N       Fl   // optional, near clipping distance
+	FOV     Fl   // optional, field of view (perspective projection)
+	OS      Fl   // optional, scale factor (orthographic projection)
+	OB      Name // optional, orthographic binding: W, H, Min, Max or Absolute
+}
+
+// String returns the PDF dictionary.
+func (p Projection3D) String() string {
+	b := newBuffer()
+	b.fmt("<</Subtype %s", p.Subtype)
+	if p.CS != "" {
+		b.fmt("/CS %s", p.CS)
+	}
+	if p.F != 0 {
+		b.fmt("/F %s", FmtFloat(p.F))
+	}
+	if p.N != 0 {
+		b.fmt("/N %s", FmtFloat(p.N))
+	}
+	if p.FOV != 0 {
+		b.fmt("/FOV %s", FmtFloat(p.FOV))
+	}
+	if p.OS != 0 {
+		b.fmt("/OS %s", FmtFloat(p.OS))
+	}
+	if p.OB != "" {
+		b.fmt("/OB %s", p.OB)
+	}
+	b.WriteString(">>")
+	return b.String()
+}
+
+// Background3D specifies the background of a 3D view.
+// See Table 317 – Entries in a 3D background dictionary
+type Background3D struct {
+	C  []Fl // optional, DeviceRGB color, default to white
+	EA bool // optional, if true the background applies to the entire annotation
+}
+
+// String returns the PDF dictionary.
+func (bg Background3D) String() string {
+	out := "<</Type/3DBG"
+	if len(bg.C) != 0 {
+		out += "/C " + writeFloatArray(bg.C)
+	}
+	if bg.EA {
+		out += "/EA true"
+	}
+	return out + ">>"
+}
+
+func (bg Background3D) clone() Background3D {
+	out := bg
+	out.C = append([]Fl(nil), bg.C...)
+	return out
+}
+
+// Activation3D specifies when the 3D annotation is activated and deactivated.
+// See Table 299 – Entries in a 3D activation dictionary
+type Activation3D struct {
+	A   Name      // optional, activation: XA, PO or PV
+	AIS Name      // optional, state after activation: I or L
+	D   Name      // optional, deactivation: XD, PC or PI
+	DIS Name      // optional, state after deactivation: U, I or L
+	TB  MaybeBool // optional, toolbar display, default to true
+	NP  bool      // optional, navigation panel display
+}
+
+// String returns the PDF dictionary.
+func (a Activation3D) String() string {
+	b := newBuffer()
+	b.WriteString("<<")
+	if a.A != "" {
+		b.fmt("/A %s", a.A)
+	}
+	if a.AIS != "" {
+		b.fmt("/AIS %s", a.AIS)
+	}
+	if a.D != "" {
+		b.fmt("/D %s", a.D)
+	}
+	if a.DIS != "" {
+		b.fmt("/DIS %s", a.DIS)
+	}
+	if tb, ok := a.TB.(ObjBool); ok {
+		b.fmt("/TB %v", tb)
+	}
+	if a.NP {
+		b.WriteString("/NP true")
+	}
+	b.WriteString(">>")
+	return b.String()
+}
+
+// RichMediaContent stores the assets and the configurations
+// of a RichMedia annotation.
+// See Table 9.51 (Adobe Supplement to ISO 32000, Extension Level 3) – Entries in a RichMediaContent dictionary
+type RichMediaContent struct {
+	Assets         EmbeddedFileTree         // optional
+	Configurations []RichMediaConfiguration // optional
+	Views          []View3D                 // optional
+}
+
+func (r RichMediaContent) pdfString(pdf pdfWriter, ref Reference) string {
+	b := newBuffer()
+	b.WriteString("<<")
+	if len(r.Assets) != 0 {
+		b.fmt("/Assets %s", r.Assets.pdfString(pdf, ref))
+	}
+	if len(r.Configurations) != 0 {
+		b.WriteString("/Configurations [")
+		for _, conf := range r.Configurations {
+			b.WriteString(conf.pdfString(pdf, ref))
+		}
+		b.WriteString("]")
+	}
+	if len(r.Views) != 0 {
+		b.WriteString("/Views [")
+		for _, view := range r.Views {
+			b.WriteString(view.pdfString(pdf, ref))
+		}
+		b.WriteString("]")
+	}
+	b.WriteString(">>")
+	return b.String()
+}
+
+func (r RichMediaContent) clone(cache cloneCache) RichMediaContent {
+	out := r
+	out.Assets = r.Assets.clone(cache)
+	if r.Configurations != nil {
+		out.Configurations = make([]RichMediaConfiguration, len(r.Configurations))
+		for i, conf := range r.Configurations {
+			out.Configurations[i] = conf.clone(cache)
+		}
+	}
+	if r.Views != nil {
+		out.Views = make([]View3D, len(r.Views))
+		for i, v := range r.Views {
+			out.Views[i] = v.clone()
+		}
+	}
+	return out
+}
+
+// RichMediaConfiguration describes a set of instances
+// that are loaded for a given scene configuration.
+type RichMediaConfiguration struct {
+	Subtype   Name   // optional, 3D, Flash, Sound or Video
+	Name      string // optional
+	Instances []RichMediaInstance
+}
+
+func (r RichMediaConfiguration) pdfString(pdf pdfWriter, ref Reference) string {
+	b := newBuffer()
+	b.WriteString("<</Type/RichMediaConfiguration")
+	if r.Subtype != "" {
+		b.fmt("/Subtype %s", r.Subtype)
+	}
+	if r.Name != "" {
+		b.fmt("/Name %s", pdf.EncodeString(r.Name, TextString, ref))
+	}
+	if len(r.Instances) != 0 {
+		b.WriteString("/Instances [")
+		for _, inst := range r.Instances {
+			b.WriteString(inst.pdfString(pdf))
+		}
+		b.WriteString("]")
+	}
+	b.WriteString(">>")
+	return b.String()
+}
+
+func (r RichMediaConfiguration) clone(cache cloneCache) RichMediaConfiguration {
+	out := r
+	if r.Instances != nil {
+		out.Instances = make([]RichMediaInstance, len(r.Instances))
+		for i, inst := range r.Instances {
+			out.Instances[i] = inst
+			if inst.Asset != nil {
+				out.Instances[i].Asset = cache.checkOrClone(inst.Asset).(*FileSpec)
+			}
+		}
+	}
+	return out
+}
+
+// RichMediaInstance is one of the asset of a RichMedia configuration.
+type RichMediaInstance struct {
+	Subtype Name      // optional, 3D, Flash, Sound or Video
+	Asset   *FileSpec // should be one of the file specifications of RichMediaContent.Assets
+	// TODO: support Params
+}
+
+func (r RichMediaInstance) pdfString(pdf pdfWriter) string {
+	out := "<</Type/RichMediaInstance"
+	if r.Subtype != "" {
+		out += "/Subtype " + r.Subtype.String()
+	}
+	if r.Asset != nil {
+		out += "/Asset " + pdf.addItem(r.Asset).String()
+	}
+	return out + ">>"
+}
+
+// RichMediaSettings specifies the activation and deactivation
+// conditions of a RichMedia annotation.
+type RichMediaSettings struct {
+	Activation   Name // optional, XA, PO or PV, written in the Activation dictionary
+	Deactivation Name // optional, XD, PC or PI, written in the Deactivation dictionary
+}
+
+// String returns the PDF dictionary.
+func (r RichMediaSettings) String() string {
+	out := "<</Type/RichMediaSettings"
+	if r.Activation != "" {
+		out += "/Activation <</Type/RichMediaActivation/Condition " + r.Activation.String() + ">>"
+	}
+	if r.Deactivation != "" {
+		out += "/Deactivation <</Type/RichMediaDeactivation/Condition " + r.Deactivation.String() + ">>"
+	}
+	return out + ">>"
+}
@@ -0,0 +1,317 @@
+package model
+
+import (
+	"fmt"
+	"math"
+)
+
+var identityMatrix = Matrix{1, 0, 0, 1, 0, 0}
+
+// errMissingMediaBox is returned when transforming pages whose boxes are inherited
+var errMissingMediaBox = newError(ErrMissingEntry, "missing MediaBox (inherited boxes must be resolved first, see PageTree.FlattenInherit)")
+
+// TransformPoint returns the image of (x, y) by `m`.
+func (m Matrix) TransformPoint(x, y Fl) (Fl, Fl) {
+	return m[0]*x + m[2]*y + m[4], m[1]*x + m[3]*y + m[5]
+}
+
+// Invert returns the inverse of `m`, or false if
+// `m` is not invertible.
+func (m Matrix) Invert() (Matrix, bool) {
+	det := m[0]*m[3] - m[1]*m[2]
+	if det == 0 {
+		return Matrix{}, false
+	}
+	a, b, c, d := m[3]/det, -m[1]/det, -m[2]/det, m[0]/det
+	return Matrix{a, b, c, d, -(m[4]*a + m[5]*c), -(m[4]*b + m[5]*d)}, true
+}
+
+// Decompose returns the counter-clockwise rotation (in degrees) and the
+// scaling factors of `m`, so that the linear part of `m` is a scaling followed
+// by a rotation. The translation and the skew (if any) are ignored.
+// A negative `scaleY` indicates a reflection.
+func (m Matrix) Decompose() (rotation, scaleX, scaleY Fl) {
+	scaleX = Fl(math.Hypot(float64(m[0]), float64(m[1])))
+	if scaleX == 0 {
+		return 0, 0, 0
+	}
+	rotation = Fl(math.Atan2(float64(m[1]), float64(m[0])) * 180 / math.Pi)
+	scaleY = (m[0]*m[3] - m[1]*m[2]) / scaleX
+	return rotation, scaleX, scaleY
+}
+
+// TransformRect returns the bounding box of the image of `r` by `m`.
+func (m Matrix) TransformRect(r Rectangle) Rectangle {
+	x1, y1 := m.TransformPoint(r.Llx, r.Lly)
+	x2, y2 := m.TransformPoint(r.Urx, r.Ury)
+	x3, y3 := m.TransformPoint(r.Llx, r.Ury)
+	x4, y4 := m.TransformPoint(r.Urx, r.Lly)
+	out := Rectangle{Llx: x1, Lly: y1, Urx: x1, Ury: y1}
+	for _, p := range [3][2]Fl{{x2, y2}, {x3, y3}, {x4, y4}} {
+		if p[0] < out.Llx {
+			out.Llx = p[0]
+		}
+		if p[0] > out.Urx {
+			out.Urx = p[0]
+		}
+		if p[1] < out.Lly {
+			out.Lly = p[1]
+		}
+		if p[1] > out.Ury {
+			out.Ury = p[1]
+		}
+	}
+	return out
+}
+
+// transformPoints returns the image of the points
+// given as alternating horizontal and vertical coordinates
+func (m Matrix) transformPoints(points []Fl) []Fl {
+	if points == nil {
+		return nil
+	}
+	out := make([]Fl, len(points))
+	for i := 0; i+1 < len(points); i += 2 {
+		out[i], out[i+1] = m.TransformPoint(points[i], points[i+1])
+	}
+	return out
+}
+
+// UserToDevice returns the matrix mapping the default user space of the page
+// to a device space whose origin is the top-left corner of the visible area
+// (the crop box, or the media box) as displayed, that is after applying
+// the page rotation. The y axis points down, and `dpi` units map one inch
+// (so that 72 gives one unit per point).
+// The media box of the page must be set (not inherited).
+func (p *PageObject) UserToDevice(dpi Fl) (Matrix, error) {
+	box := p.visibleBox()
+	if box == nil {
+		return Matrix{}, errMissingMediaBox
+	}
+	b, s := box.Normalize(), dpi/72
+	switch (p.Rotate.Degrees()%360 + 360) % 360 {
+	case 90:
+		return Matrix{0, s, s, 0, -b.Lly * s, -b.Llx * s}, nil
+	case 180:
+		return Matrix{-s, 0, 0, s, b.Urx * s, -b.Lly * s}, nil
+	case 270:
+		return Matrix{0, -s, -s, 0, b.Ury * s, b.Urx * s}, nil
+	default:
+		return Matrix{s, 0, 0, -s, -b.Llx * s, b.Ury * s}, nil
+	}
+}
+
+// DeviceToUser returns the inverse of UserToDevice, mapping the
+// device space to the default user space of the page.
+func (p *PageObject) DeviceToUser(dpi Fl) (Matrix, error) {
+	m, err := p.UserToDevice(dpi)
+	if err != nil {
+		return m, err
+	}
+	inv, ok := m.Invert()
+	if !ok {
+		return Matrix{}, newError(ErrInvalidArgument, "invalid resolution %v", dpi)
+	}
+	return inv, nil
+}
+
+// visibleBox returns the crop box of the page, defaulting to the media box,
+// or nil if the media box is inherited
+func (p *PageObject) visibleBox() *Rectangle {
+	if p.CropBox != nil {
+		return p.CropBox
+	}
+	return p.MediaBox
+}
+
+// transform maps the content of the page by `m`, by wrapping it in a `cm` operator
+// (preceded by `clip` if not empty), and updates the page boxes and the annotation coordinates.
+func (p *PageObject) transform(m Matrix, clip *Rectangle) {
+	before := fmt.Sprintf("q %s %s %s %s %s %s cm\n", FmtFloat(m[0]), FmtFloat(m[1]), FmtFloat(m[2]),
+		FmtFloat(m[3]), FmtFloat(m[4]), FmtFloat(m[5]))
+	if clip != nil {
+		before += fmt.Sprintf("%s %s %s %s re W n\n", FmtFloat(clip.Llx), FmtFloat(clip.Lly), FmtFloat(clip.Width()), FmtFloat(clip.Height()))
+	}
+	p.Contents = append([]ContentStream{{Stream: Stream{Content: []byte(before)}}}, p.Contents...)
+	p.Contents = append(p.Contents, ContentStream{Stream: Stream{Content: []byte("\nQ")}})
+
+	for _, box := range []**Rectangle{&p.MediaBox, &p.CropBox, &p.BleedBox, &p.TrimBox, &p.ArtBox} {
+		if *box != nil {
+			r := m.TransformRect(**box)
+			*box = &r
+		}
+	}
+
+	// the appearance streams are fitted into the annotation rectangle,
+	// so that only rotations need to be applied
+	linear := Matrix{m[0], m[1], m[2], m[3], 0, 0}
+	isRotation := m[1] != 0 || m[2] != 0 || m[0] < 0 || m[3] < 0
+	for _, annot := range p.Annots {
+		if annot == nil {
+			continue
+		}
+		annot.Rect = m.TransformRect(annot.Rect)
+		annot.transformCoordinates(m)
+		if isRotation && annot.AP != nil {
+			// the appearance forms may be shared with other annotations:
+			// rotate a copy, cloned once per annotation
+			annot.AP = annot.AP.clone(newCloneCache())
+			rotated := make(map[*XObjectForm]bool)
+			for _, entry := range [...]AppearanceEntry{annot.AP.N, annot.AP.R, annot.AP.D} {
+				for _, form := range entry {
+					if form == nil || rotated[form] {
+						continue
+					}
+					rotated[form] = true
+					mat := form.Matrix
+					if mat == (Matrix{}) {
+						mat = identityMatrix
+					}
+					form.Matrix = mat.Multiply(linear)
+				}
+			}
+		}
+	}
+}
+
+// transformCoordinates updates the coordinates specific to
+// the annotation subtype (excepted Rect)
+func (annot *AnnotationDict) transformCoordinates(m Matrix) {
+	switch st := annot.Subtype.(type) {
+	case AnnotationLink:
+		st.QuadPoints = m.transformPoints(st.QuadPoints)
+		annot.Subtype = st
+	case AnnotationFreeText:
+		st.CL = m.transformPoints(st.CL)
+		annot.Subtype = st
+	case AnnotationLine:
+		st.L[0], st.L[1] = m.TransformPoint(st.L[0], st.L[1])
+		st.L[2], st.L[3] = m.TransformPoint(st.L[2], st.L[3])
+		annot.Subtype = st
+	case AnnotationPolygon:
+		st.Vertices = m.transformPoints(st.Vertices)
+		annot.Subtype = st
+	case AnnotationPolyLine:
+		st.Vertices = m.transformPoints(st.Vertices)
+		annot.Subtype = st
+	case AnnotationHighlight:
+		st.QuadPoints = m.transformPoints(st.QuadPoints)
+		annot.Subtype = st
+	case AnnotationUnderline:
+		st.QuadPoints = m.transformPoints(st.QuadPoints)
+		annot.Subtype = st
+	case AnnotationSquiggly:
+		st.QuadPoints = m.transformPoints(st.QuadPoints)
+		annot.Subtype = st
+	case AnnotationStrikeOut:
+		st.QuadPoints = m.transformPoints(st.QuadPoints)
+		annot.Subtype = st
+	case AnnotationRedact:
+		st.QuadPoints = m.transformPoints(st.QuadPoints)
+		annot.Subtype = st
+	case AnnotationInk:
+		if st.InkList != nil {
+			paths := make([][]Fl, len(st.InkList))
+			for i, path := range st.InkList {
+				paths[i] = m.transformPoints(path)
+			}
+			st.InkList = paths
+		}
+		annot.Subtype = st
+	}
+}
+
+// RotateContent rotates the content of the page clockwise by `degrees`, which must be a multiple of 90,
+// by wrapping it in a `cm` operator. The page boxes are rotated, keeping the lower-left
+// corner of the media box unchanged, and the annotations are moved accordingly
+// (their rectangles and coordinates are updated, and their appearance streams are rotated).
+// Unlike the Rotate entry, which is only a display hint, the page dimensions are swapped
+// for quarter turns.
+// The media box of the page must be set (not inherited).
+func (p *PageObject) RotateContent(degrees int) error {
+	if degrees%90 != 0 {
+		return newError(ErrInvalidArgument, "invalid rotation %d (expected a multiple of 90)", degrees)
+	}
+	if p.MediaBox == nil {
+		return errMissingMediaBox
+	}
+	var m Matrix // clockwise rotation
+	switch ((degrees/90)%4 + 4) % 4 {
+	case 0:
+		return nil
+	case 1:
+		m = Matrix{0, -1, 1, 0, 0, 0}
+	case 2:
+		m = Matrix{-1, 0, 0, -1, 0, 0}
+	case 3:
+		m = Matrix{0, 1, -1, 0, 0, 0}
+	}
+	rotated := m.TransformRect(*p.MediaBox)
+	m[4], m[5] = p.MediaBox.Llx-rotated.Llx, p.MediaBox.Lly-rotated.Lly
+	p.transform(m, nil)
+
+	// keep the widget rotation in sync, so that generated appearances are consistent
+	// (MK.R is counterclockwise)
+	for _, annot := range p.Annots {
+		if annot == nil {
+			continue
+		}
+		if widget, ok := annot.Subtype.(AnnotationWidget); ok && widget.MK != nil {
+			mk := *widget.MK
+			mk.R = NewRotation(((mk.R.Degrees()-degrees)%360 + 360) % 360)
+			widget.MK = &mk
+			annot.Subtype = widget
+		}
+	}
+	return nil
+}
+
+// ScaleTo scales the visible content of the page (defined by its crop box, or media box)
+// to fit in a page of size `width` x `height`, preserving the aspect ratio and centering it.
+// The media box is set to (0, 0, width, height), the crop box is removed, and
+// the content outside of the former visible area is clipped.
+// The other page boxes and the annotations are scaled accordingly.
+// The media box of the page must be set (not inherited).
+func (p *PageObject) ScaleTo(width, height Fl) error {
+	if width <= 0 || height <= 0 {
+		return newError(ErrInvalidArgument, "invalid page size %v x %v", width, height)
+	}
+	if p.MediaBox == nil {
+		return errMissingMediaBox
+	}
+	box := *p.visibleBox()
+	if box.Width() == 0 || box.Height() == 0 {
+		return newError(ErrInvalidArgument, "empty page")
+	}
+	box = Rectangle{
+		Llx: minFl(box.Llx, box.Urx), Lly: minFl(box.Lly, box.Ury),
+		Urx: maxFl(box.Llx, box.Urx), Ury: maxFl(box.Lly, box.Ury),
+	}
+	scale := minFl(width/box.Width(), height/box.Height())
+	tx := (width-box.Width()*scale)/2 - box.Llx*scale
+	ty := (height-box.Height()*scale)/2 - box.Lly*scale
+	p.transform(Matrix{scale, 0, 0, scale, tx, ty}, &box)
+	p.MediaBox = &Rectangle{Urx: width, Ury: height}
+	p.CropBox = nil
+	return nil
+}
+
+// Crop reduces the visible area of the page, by removing the given margins
+// from its crop box (defaulting to the media box).
+// The content and the annotations are not modified.
+// The media box of the page must be set (not inherited).
+func (p *PageObject) Crop(left, bottom, right, top Fl) error {
+	if p.MediaBox == nil {
+		return errMissingMediaBox
+	}
+	box := *p.visibleBox()
+	cropped := Rectangle{
+		Llx: minFl(box.Llx, box.Urx) + left, Lly: minFl(box.Lly, box.Ury) + bottom,
+		Urx: maxFl(box.Llx, box.Urx) - right, Ury: maxFl(box.Lly, box.Ury) - top,
+	}
+	if cropped.Urx <= cropped.Llx || cropped.Ury <= cropped.Lly {
+		return newError(ErrInvalidArgument, "margins are larger than the page %s", box)
+	}
+	p.CropBox = &cropped
+	return nil
+}
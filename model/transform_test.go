@@ -0,0 +1,155 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRotateContent(t *testing.T) {
+	form := &XObjectForm{BBox: Rectangle{Urx: 20, Ury: 20}}
+	link := &AnnotationDict{
+		BaseAnnotation: BaseAnnotation{Rect: Rectangle{Llx: 10, Lly: 20, Urx: 30, Ury: 40}},
+		Subtype:        AnnotationLink{QuadPoints: []Fl{10, 20, 30, 20, 30, 40, 10, 40}},
+	}
+	widget := &AnnotationDict{
+		BaseAnnotation: BaseAnnotation{Rect: Rectangle{Llx: 10, Lly: 20, Urx: 30, Ury: 40}, AP: &AppearanceDict{N: AppearanceEntry{"": form}, D: AppearanceEntry{"": form}}},
+		Subtype:        AnnotationWidget{MK: &AppearanceCharacteristics{}},
+	}
+	page := PageObject{
+		MediaBox: &Rectangle{Urx: 200, Ury: 100},
+		Contents: []ContentStream{{Stream: Stream{Content: []byte("0 0 10 10 re f")}}},
+		Annots:   []*AnnotationDict{link, widget},
+	}
+	if err := page.RotateContent(90); err != nil {
+		t.Fatal(err)
+	}
+	if *page.MediaBox != (Rectangle{Urx: 100, Ury: 200}) {
+		t.Fatalf("unexpected media box %v", page.MediaBox)
+	}
+	if len(page.Contents) != 3 || string(page.Contents[0].Content) != "q 0 -1 1 0 0 200 cm\n" {
+		t.Fatalf("unexpected contents %v", page.Contents)
+	}
+	if exp := (Rectangle{Llx: 20, Lly: 170, Urx: 40, Ury: 190}); link.Rect != exp || widget.Rect != exp {
+		t.Fatalf("unexpected rectangles %v %v", link.Rect, widget.Rect)
+	}
+	if qp := link.Subtype.(AnnotationLink).QuadPoints; !reflect.DeepEqual(qp, []Fl{20, 190, 20, 170, 40, 170, 40, 190}) {
+		t.Fatalf("unexpected quad points %v", qp)
+	}
+	if ap := widget.AP; ap.N[""] != ap.D[""] || ap.N[""].Matrix != (Matrix{0, -1, 1, 0, 0, 0}) { // rotated once
+		t.Fatalf("unexpected appearance matrix %v", ap.N[""].Matrix)
+	}
+	if form.Matrix != (Matrix{}) { // may be shared with other pages
+		t.Fatalf("original appearance modified: %v", form.Matrix)
+	}
+	if r := widget.Subtype.(AnnotationWidget).MK.R; r.Degrees() != 270 {
+		t.Fatalf("unexpected widget rotation %d", r.Degrees())
+	}
+
+	if err := page.RotateContent(45); err == nil {
+		t.Fatal("expected error for invalid rotation")
+	}
+	if err := (&PageObject{}).RotateContent(90); err == nil {
+		t.Fatal("expected error for missing media box")
+	}
+}
+
+func TestScaleTo(t *testing.T) {
+	annot := &AnnotationDict{BaseAnnotation: BaseAnnotation{Rect: Rectangle{Llx: 10, Lly: 10, Urx: 20, Ury: 20}}, Subtype: AnnotationSquare{}}
+	page := PageObject{
+		MediaBox: &Rectangle{Llx: -10, Lly: -10, Urx: 110, Ury: 60},
+		CropBox:  &Rectangle{Urx: 100, Ury: 50},
+		Annots:   []*AnnotationDict{annot},
+	}
+	if err := page.ScaleTo(200, 200); err != nil {
+		t.Fatal(err)
+	}
+	if *page.MediaBox != (Rectangle{Urx: 200, Ury: 200}) || page.CropBox != nil {
+		t.Fatalf("unexpected boxes %v %v", page.MediaBox, page.CropBox)
+	}
+	if got := string(page.Contents[0].Content); got != "q 2 0 0 2 0 50 cm\n0 0 100 50 re W n\n" {
+		t.Fatalf("unexpected content %q", got)
+	}
+	if annot.Rect != (Rectangle{Llx: 20, Lly: 70, Urx: 40, Ury: 90}) {
+		t.Fatalf("unexpected rectangle %v", annot.Rect)
+	}
+}
+
+func TestCrop(t *testing.T) {
+	page := PageObject{MediaBox: &Rectangle{Urx: 100, Ury: 50}}
+	if err := page.Crop(10, 5, 20, 5); err != nil {
+		t.Fatal(err)
+	}
+	if *page.CropBox != (Rectangle{Llx: 10, Lly: 5, Urx: 80, Ury: 45}) || *page.MediaBox != (Rectangle{Urx: 100, Ury: 50}) {
+		t.Fatalf("unexpected boxes %v %v", page.CropBox, page.MediaBox)
+	}
+	if err := page.Crop(40, 0, 40, 0); err == nil {
+		t.Fatal("expected error for too large margins")
+	}
+}
+
+func TestMatrix(t *testing.T) {
+	translate, rotate := Matrix{1, 0, 0, 1, 10, 0}, Matrix{0, 1, -1, 0, 0, 0} // quarter turn
+	// translate, then rotate
+	if m := translate.Multiply(rotate); m != (Matrix{0, 1, -1, 0, 0, 10}) {
+		t.Fatalf("unexpected product %v", m)
+	}
+	if x, y := translate.Multiply(rotate).TransformPoint(1, 0); x != 0 || y != 11 {
+		t.Fatalf("unexpected point (%v, %v)", x, y)
+	}
+
+	m := Matrix{2, 0, 0, 3, 10, 20}.Multiply(rotate)
+	inv, ok := m.Invert()
+	if !ok {
+		t.Fatal("expected invertible matrix")
+	}
+	if id := m.Multiply(inv); id != identityMatrix {
+		t.Fatalf("expected identity, got %v", id)
+	}
+	if _, ok := (Matrix{1, 2, 2, 4, 0, 0}).Invert(); ok {
+		t.Fatal("expected singular matrix")
+	}
+
+	if r, sx, sy := m.Decompose(); r != 90 || sx != 2 || sy != 3 {
+		t.Fatalf("unexpected decomposition %v %v %v", r, sx, sy)
+	}
+	if r, sx, sy := (Matrix{1, 0, 0, -1, 0, 0}).Decompose(); r != 0 || sx != 1 || sy != -1 {
+		t.Fatalf("unexpected decomposition %v %v %v", r, sx, sy)
+	}
+	if rect := m.TransformRect(Rectangle{Urx: 1, Ury: 1}); rect != (Rectangle{Llx: -23, Lly: 10, Urx: -20, Ury: 12}) {
+		t.Fatalf("unexpected rectangle %v", rect)
+	}
+}
+
+func TestUserToDevice(t *testing.T) {
+	page := PageObject{MediaBox: &Rectangle{Urx: 200, Ury: 100}, CropBox: &Rectangle{Llx: 10, Lly: 10, Urx: 110, Ury: 60}}
+	for _, test := range []struct {
+		rotation int
+		topLeft  [2]Fl // user space point mapped to the device origin
+		size     [2]Fl
+	}{
+		{0, [2]Fl{10, 60}, [2]Fl{200, 100}},
+		{90, [2]Fl{10, 10}, [2]Fl{100, 200}},
+		{180, [2]Fl{110, 10}, [2]Fl{200, 100}},
+		{270, [2]Fl{110, 60}, [2]Fl{100, 200}},
+	} {
+		page.Rotate = NewRotation(test.rotation)
+		m, err := page.UserToDevice(144)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inv, err := page.DeviceToUser(144)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if x, y := inv.TransformPoint(0, 0); x != test.topLeft[0] || y != test.topLeft[1] {
+			t.Fatalf("rotation %d: unexpected origin (%v, %v)", test.rotation, x, y)
+		}
+		if size := page.CropBox.Transform(m); size != (Rectangle{Urx: test.size[0], Ury: test.size[1]}) {
+			t.Fatalf("rotation %d: unexpected device box %v", test.rotation, size)
+		}
+	}
+
+	if _, err := (&PageObject{}).UserToDevice(72); err == nil {
+		t.Fatal("expected error for missing media box")
+	}
+}
@@ -3,6 +3,7 @@ package model
 import (
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -66,6 +67,121 @@ func limitsName(n nameTree) [2]string {
 	return limits
 }
 
+// TreeShape controls the shape of the balanced
+// name and number trees built by this package.
+// The zero value uses at most 20 kids per node and 50 entries per leaf.
+type TreeShape struct {
+	// MaxKids is the maximum number of kids of an intermediate node.
+	// Values less than 2 are replaced by the default (20).
+	MaxKids int
+	// MaxEntries is the maximum number of entries of a leaf node.
+	// Non positive values are replaced by the default (50).
+	MaxEntries int
+	// MaxDepth is the optional maximum depth of the tree, a single leaf
+	// having depth 1. When needed, the leaves are allowed to hold more than
+	// MaxEntries entries so that this depth is not exceeded.
+	// Zero means no limit.
+	MaxDepth int
+}
+
+// maxTreeDepth is the maximum depth of the Dests tree
+// accepted on write, when WriteOptions.TreeShape.MaxDepth is not set
+const maxTreeDepth = 32
+
+// treeLayout describes a node of a balanced tree,
+// covering the entries [start, end) of a sorted list
+type treeLayout struct {
+	start, end int
+	kids       []treeLayout // nil for leaves
+}
+
+// limits returns the effective fan-out and leaf size
+// to use for a tree with `n` entries
+func (s TreeShape) limits(n int) (maxKids, maxEntries int) {
+	maxKids, maxEntries = s.MaxKids, s.MaxEntries
+	if maxKids < 2 {
+		maxKids = 20
+	}
+	if maxEntries <= 0 {
+		maxEntries = 50
+	}
+	if s.MaxDepth <= 0 {
+		return maxKids, maxEntries
+	}
+	// number of leaves of a full tree of depth MaxDepth
+	leaves := 1
+	for depth := 1; depth < s.MaxDepth && leaves*maxEntries < n; depth++ {
+		leaves *= maxKids
+	}
+	if leaves*maxEntries < n {
+		maxEntries = (n + leaves - 1) / leaves
+	}
+	return maxKids, maxEntries
+}
+
+// layout splits `n` sorted entries into a balanced tree:
+// each intermediate node uses as few kids as possible,
+// and its entries are evenly distributed among them.
+func (s TreeShape) layout(n int) treeLayout {
+	maxKids, maxEntries := s.limits(n)
+	var build func(start, end int) treeLayout
+	build = func(start, end int) treeLayout {
+		size := end - start
+		if size <= maxEntries {
+			return treeLayout{start: start, end: end}
+		}
+		nbKids := (size + maxEntries - 1) / maxEntries
+		if nbKids > maxKids {
+			nbKids = maxKids
+		}
+		out := treeLayout{start: start, end: end, kids: make([]treeLayout, nbKids)}
+		for i := range out.kids {
+			out.kids[i] = build(start+i*size/nbKids, start+(i+1)*size/nbKids)
+		}
+		return out
+	}
+	return build(0, n)
+}
+
+// writeNameTree writes the given entries as a name tree shaped by
+// the writer tree shape, whose root object is `ref`, and returns the root dictionary.
+// `values` are the PDF representation of the values associated to `names`,
+// which must not depend on the object they are written in (typically references).
+func writeNameTree(pdf pdfWriter, ref Reference, names, values []string) string {
+	indices := make([]int, len(names))
+	for i := range indices {
+		indices[i] = i
+	}
+	sort.SliceStable(indices, func(i, j int) bool { return names[indices[i]] < names[indices[j]] })
+
+	var write func(node treeLayout, ref Reference) string
+	write = func(node treeLayout, ref Reference) string {
+		b := newBuffer()
+		b.fmt("<<")
+		if node.end > node.start {
+			b.fmt("/Limits [%s %s] ", pdf.EncodeString(names[indices[node.start]], ByteString, ref),
+				pdf.EncodeString(names[indices[node.end-1]], ByteString, ref))
+		}
+		if node.kids != nil {
+			b.fmt("/Kids [")
+			for _, kid := range node.kids {
+				kidRef := pdf.CreateObject()
+				pdf.WriteObject(write(kid, kidRef), kidRef)
+				b.fmt("%s ", kidRef)
+			}
+			b.fmt("]>>")
+			return b.String()
+		}
+		chunks := make([]string, 0, node.end-node.start)
+		for _, index := range indices[node.start:node.end] {
+			chunks = append(chunks, pdf.EncodeString(names[index], ByteString, ref)+" "+values[index])
+		}
+		b.fmt("/Names [%s]>>", strings.Join(chunks, " "))
+		return b.String()
+	}
+	return write(pdf.treeShape.layout(len(names)), ref)
+}
+
 // NameToDest associate an explicit destination
 // to a name.
 type NameToDest struct {
@@ -137,6 +253,77 @@ func (d DestTree) LookupTable() map[DestinationString]DestinationExplicit {
 	return out
 }
 
+// NewDestTree builds a balanced tree, respecting the given fan-out and depth,
+// from the given entries, which are sorted if needed.
+func NewDestTree(dests []NameToDest, shape TreeShape) DestTree {
+	entries := append([]NameToDest(nil), dests...)
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+
+	var build func(node treeLayout) DestTree
+	build = func(node treeLayout) DestTree {
+		var out DestTree
+		if node.kids == nil {
+			out.Names = entries[node.start:node.end:node.end]
+			return out
+		}
+		out.Kids = make([]DestTree, len(node.kids))
+		for i, kid := range node.kids {
+			out.Kids[i] = build(kid)
+		}
+		return out
+	}
+	return build(shape.layout(len(entries)))
+}
+
+// Balance returns a balanced tree with the same entries as `d`,
+// respecting the given fan-out and depth.
+// It is useful to efficiently store a large (flat) list of destinations.
+func (d DestTree) Balance(shape TreeShape) DestTree {
+	return NewDestTree(d.entries(), shape)
+}
+
+// entries returns the names of the tree, in depth-first order
+func (d DestTree) entries() []NameToDest {
+	out := append([]NameToDest(nil), d.Names...)
+	for _, kid := range d.Kids {
+		out = append(out, kid.entries()...)
+	}
+	return out
+}
+
+// fits returns true if the tree is not deeper than `maxDepth`
+// (a leaf having depth 1), its nodes have at most `maxKids` kids,
+// and its leaves at most `maxEntries` entries.
+func (d DestTree) fits(maxKids, maxEntries, maxDepth int) bool {
+	if len(d.Kids) == 0 {
+		return len(d.Names) <= maxEntries
+	}
+	if maxDepth <= 1 || len(d.Kids) > maxKids || len(d.Names) != 0 {
+		return false
+	}
+	for _, kid := range d.Kids {
+		if !kid.fits(maxKids, maxEntries, maxDepth-1) {
+			return false
+		}
+	}
+	return true
+}
+
+// balanceIfNeeded returns `d`, or a balanced copy if it
+// does not respect `shape`
+func (d DestTree) balanceIfNeeded(shape TreeShape) DestTree {
+	entries := d.entries()
+	maxKids, maxEntries := shape.limits(len(entries))
+	maxDepth := shape.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = maxTreeDepth
+	}
+	if d.fits(maxKids, maxEntries, maxDepth) {
+		return d
+	}
+	return NewDestTree(entries, shape)
+}
+
 func (p DestTree) pdfString(pdf pdfWriter, ref Reference) string {
 	b := newBuffer()
 	limits := p.Limits()
@@ -308,7 +495,9 @@ func (f NameToFile) clone(cache cloneCache) NameToFile {
 // EmbeddedFileTree is written as a Name Tree in PDF,
 // but, since it generally won't be big, is
 // represented here as a flat list.
-// It must be sorted by .Name field.
+// It should be sorted by .Name field.
+// When writing, it is split into a balanced tree
+// according to WriteOptions.TreeShape.
 type EmbeddedFileTree []NameToFile
 
 func (d EmbeddedFileTree) names() []string {
@@ -328,16 +517,12 @@ func (efs EmbeddedFileTree) Limits() [2]string {
 }
 
 func (p EmbeddedFileTree) pdfString(pdf pdfWriter, ref Reference) string {
-	lims := p.Limits()
-	chunks := make([]string, len(p))
+	names, values := make([]string, len(p)), make([]string, len(p))
 	for i, f := range p {
-		fsRef := pdf.addItem(f.FileSpec)
-		chunks[i] = fmt.Sprintf("%s %s",
-			pdf.EncodeString(f.Name, ByteString, ref), fsRef)
+		names[i] = f.Name
+		values[i] = pdf.addItem(f.FileSpec).String()
 	}
-	return fmt.Sprintf("<</Limits [%s %s] /Names [%s]>>",
-		pdf.EncodeString(lims[0], ByteString, ref), pdf.EncodeString(lims[1], ByteString, ref),
-		strings.Join(chunks, " "))
+	return writeNameTree(pdf, ref, names, values)
 }
 
 func (p EmbeddedFileTree) clone(cache cloneCache) EmbeddedFileTree {
@@ -353,6 +538,62 @@ func (p EmbeddedFileTree) clone(cache cloneCache) EmbeddedFileTree {
 
 // -----------------------------------------------------------------------
 
+// NameToJavaScript associates a name to a document-level script.
+type NameToJavaScript struct {
+	Name   string
+	Action Action // should be an ActionJavaScript, written as an indirect object
+}
+
+// JavaScriptTree is written as a Name Tree in PDF,
+// but, since it generally won't be big, is
+// represented here as a flat list.
+// It should be sorted by .Name field.
+// As EmbeddedFileTree, it is split according to WriteOptions.TreeShape when written.
+type JavaScriptTree []NameToJavaScript
+
+func (d JavaScriptTree) names() []string {
+	out := make([]string, len(d))
+	for i, k := range d {
+		out[i] = k.Name
+	}
+	return out
+}
+
+func (d JavaScriptTree) kids() []nameTree {
+	return nil
+}
+
+func (d JavaScriptTree) Limits() [2]string {
+	return limitsName(d)
+}
+
+func (p JavaScriptTree) pdfString(pdf pdfWriter, ref Reference) string {
+	names, values := make([]string, 0, len(p)), make([]string, 0, len(p))
+	for _, js := range p {
+		if js.Action.ActionType == nil {
+			continue
+		}
+		acRef := pdf.CreateObject()
+		pdf.WriteObject(js.Action.pdfString(pdf, acRef), acRef)
+		names = append(names, js.Name)
+		values = append(values, acRef.String())
+	}
+	return writeNameTree(pdf, ref, names, values)
+}
+
+func (p JavaScriptTree) clone(cache cloneCache) JavaScriptTree {
+	if p == nil { // preserve reflect.DeepEqual
+		return p
+	}
+	out := make(JavaScriptTree, len(p))
+	for i, js := range p {
+		out[i] = NameToJavaScript{Name: js.Name, Action: js.Action.clone(cache)}
+	}
+	return out
+}
+
+// -----------------------------------------------------------------------
+
 // PageLabel defines the labelling characteristics for the pages
 // in a range.
 type PageLabel struct {
@@ -374,6 +615,52 @@ func (p PageLabel) pdfString(st PDFWritter, ref Reference) string {
 	return b.String()
 }
 
+// Label returns the label of the page at position `offset` (0-based)
+// in the range defined by `p`.
+func (p PageLabel) Label(offset int) string {
+	n := p.St + offset
+	if p.St < 1 { // St is at least 1
+		n = 1 + offset
+	}
+	switch p.S {
+	case "D":
+		return p.P + strconv.Itoa(n)
+	case "R":
+		return p.P + romanNumeral(n)
+	case "r":
+		return p.P + strings.ToLower(romanNumeral(n))
+	case "A":
+		return p.P + letterNumeral(n)
+	case "a":
+		return p.P + strings.ToLower(letterNumeral(n))
+	default: // no numeric portion
+		return p.P
+	}
+}
+
+// romanNumeral returns the upper case roman numeral for `n` > 0
+func romanNumeral(n int) string {
+	values := [...]int{1000, 900, 500, 400, 100, 90, 50, 40, 10, 9, 5, 4, 1}
+	symbols := [...]string{"M", "CM", "D", "CD", "C", "XC", "L", "XL", "X", "IX", "V", "IV", "I"}
+	var b strings.Builder
+	for i, v := range values {
+		for ; n >= v; n -= v {
+			b.WriteString(symbols[i])
+		}
+	}
+	return b.String()
+}
+
+// letterNumeral returns A to Z for the first 26 pages,
+// then AA to ZZ for the next 26, and so on.
+func letterNumeral(n int) string {
+	if n < 1 {
+		return ""
+	}
+	letter := string(rune('A' + (n-1)%26))
+	return strings.Repeat(letter, (n-1)/26+1)
+}
+
 type NumToPageLabel struct {
 	Num       int
 	PageLabel PageLabel // rather a direct object
@@ -427,6 +714,21 @@ func (d PageLabelsTree) LookupTable() map[int]PageLabel {
 	return out
 }
 
+// Label returns the label of the page with index `pageIndex` (0-based),
+// defaulting to the decimal page number if no range applies.
+func (d PageLabelsTree) Label(pageIndex int) string {
+	start, found := -1, PageLabel{}
+	for num, label := range d.LookupTable() {
+		if num <= pageIndex && num > start {
+			start, found = num, label
+		}
+	}
+	if start == -1 {
+		return strconv.Itoa(pageIndex + 1)
+	}
+	return found.Label(pageIndex - start)
+}
+
 func (p PageLabelsTree) pdfString(pdf pdfWriter, ref Reference, isRoot bool) string {
 	b := newBuffer()
 	b.fmt("<<")
@@ -489,43 +791,41 @@ type IDTree struct {
 	Names []NameToStructureElement
 }
 
-// NewIDTree builds a valid IDTree from the given maping.
+// NewIDTree builds a valid IDTree from the given maping,
+// using the default shape (see TreeShape).
 // The tree should be good enough for most use cases,
 // but you may also build you own.
 func NewIDTree(ids map[string]*StructureElement) IDTree {
+	return NewIDTreeWithShape(ids, TreeShape{})
+}
+
+// NewIDTreeWithShape builds a balanced IDTree from the given maping,
+// respecting the given fan-out and depth.
+func NewIDTreeWithShape(ids map[string]*StructureElement, shape TreeShape) IDTree {
 	// keys must be sorted
-	allKeys := make([]string, 0, len(ids))
+	keys := make([]string, 0, len(ids))
 	for k := range ids {
-		allKeys = append(allKeys, k)
+		keys = append(keys, k)
 	}
-	sort.Strings(allKeys)
-
-	const maxKidLength, maxKeysLength = 20, 50
+	sort.Strings(keys)
 
-	// walk takes a sorted list of keys
-	// and build an IDTree, by splitting if if necessary
-	var walk func(keys []string) IDTree
-	walk = func(keys []string) IDTree {
-		var node IDTree
-
-		if len(keys) <= maxKeysLength {
-			// all names fit into one leaf object
-			node.Names = make([]NameToStructureElement, len(keys))
-			for i, n := range keys {
-				node.Names[i] = NameToStructureElement{Name: n, Structure: ids[n]}
+	var build func(node treeLayout) IDTree
+	build = func(node treeLayout) IDTree {
+		var out IDTree
+		if node.kids == nil {
+			out.Names = make([]NameToStructureElement, 0, node.end-node.start)
+			for _, n := range keys[node.start:node.end] {
+				out.Names = append(out.Names, NameToStructureElement{Name: n, Structure: ids[n]})
 			}
-			return node
+			return out
 		}
-
-		// too many names: we split the list into subtrees
-		sizeChunk := len(keys) / (maxKidLength - 1) // so that we have at most maxKidLength
-		for _, chunk := range splitStrings(keys, sizeChunk) {
-			node.Kids = append(node.Kids, walk(chunk))
+		out.Kids = make([]IDTree, len(node.kids))
+		for i, kid := range node.kids {
+			out.Kids[i] = build(kid)
 		}
-		return node
+		return out
 	}
-
-	return walk(allKeys)
+	return build(shape.layout(len(keys)))
 }
 
 func splitStrings(names []string, sizeChunk int) [][]string {
@@ -652,11 +952,15 @@ func (n NumToParent) pdfString(pdf pdfWriter) string {
 	if n.Parent != nil {
 		parent = pdf.structure[n.Parent].String()
 	} else {
-		refs := make([]Reference, len(n.Parents))
+		refs := make([]string, len(n.Parents))
 		for i, p := range n.Parents {
-			refs[i] = pdf.structure[p]
+			if p == nil { // unused MCID
+				refs[i] = "null"
+			} else {
+				refs[i] = pdf.structure[p].String()
+			}
 		}
-		parent = writeRefArray(refs)
+		parent = "[" + strings.Join(refs, " ") + "]"
 	}
 	return fmt.Sprintf("%d %s", n.Num, parent)
 }
@@ -680,47 +984,46 @@ type ParentTree struct {
 	Nums []NumToParent
 }
 
-// NewParentTree builds a valid ParentTree from the given maping.
+// NewParentTree builds a valid ParentTree from the given maping,
+// using the default shape (see TreeShape).
 // The tree should be good enough for most use cases,
 // but you may also build you own.
 // Note that the field `Num` in the `parents` values are ignored:
 // the key in the map is used instead.
 func NewParentTree(parents map[int]NumToParent) ParentTree {
+	return NewParentTreeWithShape(parents, TreeShape{})
+}
+
+// NewParentTreeWithShape builds a balanced ParentTree from the given maping,
+// respecting the given fan-out and depth.
+// As for NewParentTree, the field `Num` in the `parents` values are ignored.
+func NewParentTreeWithShape(parents map[int]NumToParent, shape TreeShape) ParentTree {
 	// keys must be sorted
-	allKeys := make([]int, 0, len(parents))
+	keys := make([]int, 0, len(parents))
 	for k := range parents {
-		allKeys = append(allKeys, k)
-	}
-	sort.Ints(allKeys)
-
-	const maxKidLength, maxKeysLength = 20, 50
-
-	// walk takes a sorted list of keys
-	// and build an ParentTree, by splitting if if necessary
-	var walk func(keys []int) ParentTree
-	walk = func(keys []int) ParentTree {
-		var node ParentTree
-
-		if len(keys) <= maxKeysLength {
-			// all keys fit into one leaf object
-			node.Nums = make([]NumToParent, len(keys))
-			for i, n := range keys {
-				out := parents[n]
-				out.Num = n
-				node.Nums[i] = out
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+
+	var build func(node treeLayout) ParentTree
+	build = func(node treeLayout) ParentTree {
+		var out ParentTree
+		if node.kids == nil {
+			out.Nums = make([]NumToParent, 0, node.end-node.start)
+			for _, n := range keys[node.start:node.end] {
+				parent := parents[n]
+				parent.Num = n
+				out.Nums = append(out.Nums, parent)
 			}
-			return node
+			return out
 		}
-
-		// too many keys: we split the list into subtrees
-		sizeChunk := len(keys) / (maxKidLength - 1) // so that we have at most maxKidLength
-		for _, chunk := range splitInts(keys, sizeChunk) {
-			node.Kids = append(node.Kids, walk(chunk))
+		out.Kids = make([]ParentTree, len(node.kids))
+		for i, kid := range node.kids {
+			out.Kids[i] = build(kid)
 		}
-		return node
+		return out
 	}
-
-	return walk(allKeys)
+	return build(shape.layout(len(keys)))
 }
 
 // LookupTable walks the tree and accumulate the parents into one map.
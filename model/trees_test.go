@@ -1,6 +1,8 @@
 package model
 
 import (
+	"bytes"
+	"fmt"
 	"reflect"
 	"strings"
 	"testing"
@@ -63,3 +65,164 @@ func TestParentTree(t *testing.T) {
 		t.Errorf("expected %v, got %v", m, m2)
 	}
 }
+
+func TestPageLabels(t *testing.T) {
+	labels := PageLabelsTree{
+		Nums: []NumToPageLabel{
+			{Num: 0, PageLabel: PageLabel{S: "r", St: 1}},
+			{Num: 4, PageLabel: PageLabel{S: "D", St: 1}},
+		},
+		Kids: []PageLabelsTree{
+			{Nums: []NumToPageLabel{{Num: 10, PageLabel: PageLabel{S: "A", P: "A-", St: 26}}}},
+		},
+	}
+	for pageIndex, exp := range map[int]string{
+		0:  "i",
+		3:  "iv",
+		4:  "1",
+		9:  "6",
+		10: "A-Z",
+		11: "A-AA",
+	} {
+		if got := labels.Label(pageIndex); got != exp {
+			t.Errorf("page %d: expected %s, got %s", pageIndex, exp, got)
+		}
+	}
+	if got := (PageLabelsTree{}).Label(2); got != "3" {
+		t.Errorf("expected default label, got %s", got)
+	}
+	if got := romanNumeral(1994); got != "MCMXCIV" {
+		t.Errorf("unexpected roman numeral %s", got)
+	}
+}
+
+// shape returns the maximum depth and fan-out, and the maximum and minimum leaf sizes
+func (d DestTree) shape() (depth, maxKids, maxLeaf, minLeaf int) {
+	if len(d.Kids) == 0 {
+		return 1, 0, len(d.Names), len(d.Names)
+	}
+	maxKids, minLeaf = len(d.Kids), -1
+	for _, kid := range d.Kids {
+		kDepth, kKids, kMax, kMin := kid.shape()
+		if kDepth+1 > depth {
+			depth = kDepth + 1
+		}
+		if kKids > maxKids {
+			maxKids = kKids
+		}
+		if kMax > maxLeaf {
+			maxLeaf = kMax
+		}
+		if minLeaf == -1 || kMin < minLeaf {
+			minLeaf = kMin
+		}
+	}
+	return depth, maxKids, maxLeaf, minLeaf
+}
+
+func TestTreeShape(t *testing.T) {
+	dests := make([]NameToDest, 10000)
+	for i := range dests {
+		// reverse order, to check sorting
+		dests[i] = NameToDest{Name: DestinationString(fmt.Sprintf("dest%05d", len(dests)-i)), Destination: DestinationExplicitIntern{}}
+	}
+
+	for _, test := range []struct {
+		shape                   TreeShape
+		depth, maxKids, maxLeaf int
+	}{
+		{TreeShape{MaxKids: 20, MaxEntries: 50}, 3, 20, 50},
+		{TreeShape{MaxKids: 4, MaxEntries: 10}, 6, 4, 10},
+		{TreeShape{MaxKids: 4, MaxEntries: 10, MaxDepth: 3}, 3, 4, 625},
+		{TreeShape{MaxDepth: 1}, 1, 0, 10000},
+		{TreeShape{}, 3, 20, 50},
+	} {
+		tree := NewDestTree(dests, test.shape)
+		depth, maxKids, maxLeaf, minLeaf := tree.shape()
+		if depth != test.depth || maxKids != test.maxKids || maxLeaf != test.maxLeaf {
+			t.Errorf("shape %v: expected (%d, %d, %d), got (%d, %d, %d)", test.shape,
+				test.depth, test.maxKids, test.maxLeaf, depth, maxKids, maxLeaf)
+		}
+		if maxLeaf-minLeaf > maxLeaf/2 {
+			t.Errorf("unbalanced leaves: %d and %d", minLeaf, maxLeaf)
+		}
+		if len(tree.LookupTable()) != len(dests) {
+			t.Errorf("missing entries")
+		}
+		entries := tree.entries()
+		for i := 1; i < len(entries); i++ {
+			if entries[i-1].Name >= entries[i].Name {
+				t.Fatalf("entries not sorted: %s %s", entries[i-1].Name, entries[i].Name)
+			}
+		}
+	}
+
+	// small trees are not split
+	if tree := NewDestTree(dests[:30], TreeShape{}); len(tree.Kids) != 0 || len(tree.Names) != 30 {
+		t.Errorf("unexpected tree for 30 entries")
+	}
+
+	// the previous splitting could exceed the fan-out
+	m := make(map[int]NumToParent)
+	for i := range [60]int{} {
+		m[i] = NumToParent{Parent: new(StructureElement)}
+	}
+	if tree := NewParentTree(m); len(tree.Kids) > 20 {
+		t.Errorf("too many kids: %d", len(tree.Kids))
+	}
+}
+
+func TestWriteLargeNameTrees(t *testing.T) {
+	var doc Document
+	doc.Catalog.Pages.Kids = []PageNode{&PageObject{}}
+	for i := 0; i < 5000; i++ {
+		doc.Catalog.Names.EmbeddedFiles = append(doc.Catalog.Names.EmbeddedFiles,
+			NameToFile{Name: fmt.Sprintf("file%04d", i), FileSpec: &FileSpec{UF: "file"}})
+	}
+	// a degenerate (linear) tree
+	var dests DestTree
+	for i := 0; i < 100; i++ {
+		dests = DestTree{Kids: []DestTree{dests, {Names: []NameToDest{{Name: DestinationString(fmt.Sprintf("d%03d", i)), Destination: DestinationExplicitIntern{}}}}}}
+	}
+	doc.Catalog.Names.Dests = dests
+
+	var out bytes.Buffer
+	if err := doc.Write(&out, nil); err != nil {
+		t.Fatal(err)
+	}
+	// 5000 entries are split in 20 kids with 5 leaves each
+	if n := bytes.Count(out.Bytes(), []byte("/Kids [")); n < 1+20 {
+		t.Errorf("expected split trees, got %d intermediate nodes", n)
+	}
+}
+
+func TestWriteFlatDests(t *testing.T) {
+	var doc Document
+	doc.Catalog.Pages.Kids = []PageNode{&PageObject{}}
+	for i := 0; i < 1000; i++ {
+		doc.Catalog.Names.Dests.Names = append(doc.Catalog.Names.Dests.Names,
+			NameToDest{Name: DestinationString(fmt.Sprintf("d%04d", i)), Destination: DestinationExplicitIntern{}})
+	}
+
+	for _, test := range []struct {
+		shape TreeShape
+		kids  int
+	}{
+		{TreeShape{}, 1}, // root with 20 leaves
+		{TreeShape{MaxKids: 10, MaxEntries: 100}, 1},    // root with 10 leaves
+		{TreeShape{MaxKids: 4, MaxEntries: 100}, 1 + 4}, // root with 4 nodes of 2 or 3 leaves
+		{TreeShape{MaxEntries: 1000}, 0},                // the flat tree fits
+	} {
+		test.kids++ // the page tree
+		var out bytes.Buffer
+		if err := doc.WriteWithOptions(&out, nil, WriteOptions{TreeShape: test.shape}); err != nil {
+			t.Fatal(err)
+		}
+		if n := bytes.Count(out.Bytes(), []byte("/Kids [")); n != test.kids {
+			t.Errorf("shape %v: expected %d intermediate nodes, got %d", test.shape, test.kids, n)
+		}
+	}
+	if len(doc.Catalog.Names.Dests.Kids) != 0 {
+		t.Error("the document should not be modified")
+	}
+}
@@ -1,9 +1,7 @@
 package model
 
 import (
-	"fmt"
 	"strconv"
-	"strings"
 )
 
 // implements basic types found in PDF files
@@ -41,6 +39,21 @@ type Object interface {
 	Clone() Object
 }
 
+// objectAppender is implemented by the basic objects,
+// which are serialized without intermediate strings.
+type objectAppender interface {
+	// appendPDF appends the same content as Object.Write to `dst`
+	appendPDF(dst []byte, w PDFWritter, r Reference) []byte
+}
+
+// appendObject appends the PDF representation of `o` to `dst`
+func appendObject(dst []byte, o Object, w PDFWritter, r Reference) []byte {
+	if a, ok := o.(objectAppender); ok {
+		return a.appendPDF(dst, w, r)
+	}
+	return append(dst, o.Write(w, r)...)
+}
+
 type ObjNull struct{}
 
 func (ObjNull) String() string { return "<null>" }
@@ -48,6 +61,10 @@ func (ObjNull) String() string { return "<null>" }
 // String returns the PDF representation of a name
 func (ObjNull) Write(PDFWritter, Reference) string { return "null" }
 
+func (ObjNull) appendPDF(dst []byte, _ PDFWritter, _ Reference) []byte {
+	return append(dst, "null"...)
+}
+
 func (n ObjNull) Clone() Object { return n }
 
 // ObjName is a symbol to be referenced,
@@ -66,6 +83,11 @@ func (n ObjName) Write(PDFWritter, Reference) string {
 	return n.String()
 }
 
+func (n ObjName) appendPDF(dst []byte, _ PDFWritter, _ Reference) []byte {
+	dst = append(dst, '/')
+	return append(dst, n...)
+}
+
 // ObjFloat implements MaybeFloat
 type ObjFloat Fl
 
@@ -73,6 +95,10 @@ func (f ObjFloat) Write(PDFWritter, Reference) string {
 	return FmtFloat(float32(f))
 }
 
+func (f ObjFloat) appendPDF(dst []byte, _ PDFWritter, _ Reference) []byte {
+	return appendFloat(dst, Fl(f))
+}
+
 func (f ObjFloat) Clone() Object { return f }
 
 // ObjBool represents a PDF boolean object.
@@ -80,7 +106,11 @@ type ObjBool bool
 
 func (boolean ObjBool) Clone() Object { return boolean }
 func (boolean ObjBool) Write(PDFWritter, Reference) string {
-	return fmt.Sprintf("%v", bool(boolean))
+	return strconv.FormatBool(bool(boolean))
+}
+
+func (boolean ObjBool) appendPDF(dst []byte, _ PDFWritter, _ Reference) []byte {
+	return strconv.AppendBool(dst, bool(boolean))
 }
 
 // ObjInt represents a PDF integer object.
@@ -91,6 +121,10 @@ func (i ObjInt) Write(PDFWritter, Reference) string {
 	return strconv.Itoa(int(i))
 }
 
+func (i ObjInt) appendPDF(dst []byte, _ PDFWritter, _ Reference) []byte {
+	return strconv.AppendInt(dst, int64(i), 10)
+}
+
 // ObjStringLiteral represents a PDF string literal object.
 // When required, text strings must be encoded and encrypted
 // in a first step: the content of ObjStringLiteral will only be escaped.
@@ -132,7 +166,14 @@ type ObjIndirectRef struct {
 func (ir ObjIndirectRef) Clone() Object { return ir }
 
 func (ir ObjIndirectRef) Write(PDFWritter, Reference) string {
-	return fmt.Sprintf("%d %d R", ir.ObjectNumber, ir.GenerationNumber)
+	return string(ir.appendPDF(nil, nil, 0))
+}
+
+func (ir ObjIndirectRef) appendPDF(dst []byte, _ PDFWritter, _ Reference) []byte {
+	dst = strconv.AppendInt(dst, int64(ir.ObjectNumber), 10)
+	dst = append(dst, ' ')
+	dst = strconv.AppendInt(dst, int64(ir.GenerationNumber), 10)
+	return append(dst, " R"...)
 }
 
 // ObjCommand is a PDF operation found in content streams.
@@ -144,6 +185,10 @@ func (cmd ObjCommand) Write(PDFWritter, Reference) string {
 	return string(cmd)
 }
 
+func (cmd ObjCommand) appendPDF(dst []byte, _ PDFWritter, _ Reference) []byte {
+	return append(dst, cmd...)
+}
+
 // ObjArray represents a PDF array object.
 type ObjArray []Object
 
@@ -156,11 +201,18 @@ func (arr ObjArray) Clone() Object {
 }
 
 func (arr ObjArray) Write(w PDFWritter, r Reference) string {
-	chunks := make([]string, len(arr))
+	return string(arr.appendPDF(nil, w, r))
+}
+
+func (arr ObjArray) appendPDF(dst []byte, w PDFWritter, r Reference) []byte {
+	dst = append(dst, '[')
 	for i, o := range arr {
-		chunks[i] = o.Write(w, r)
+		if i != 0 {
+			dst = append(dst, ' ')
+		}
+		dst = appendObject(dst, o, w, r)
 	}
-	return "[" + strings.Join(chunks, " ") + "]"
+	return append(dst, ']')
 }
 
 // ObjDict represents a PDF dict object.
@@ -175,11 +227,18 @@ func (d ObjDict) Clone() Object {
 }
 
 func (d ObjDict) Write(w PDFWritter, r Reference) string {
-	chunks := make([]string, 0, len(d))
-	for i, o := range d {
-		chunks = append(chunks, i.Write(w, r), o.Write(w, r))
+	return string(d.appendPDF(nil, w, r))
+}
+
+func (d ObjDict) appendPDF(dst []byte, w PDFWritter, r Reference) []byte {
+	dst = append(dst, "<<\n"...)
+	for k, o := range d {
+		dst = k.appendPDF(dst, w, r)
+		dst = append(dst, '\n')
+		dst = appendObject(dst, o, w, r)
+		dst = append(dst, '\n')
 	}
-	return "<<\n" + strings.Join(chunks, "\n") + "\n>>"
+	return append(dst, ">>"...)
 }
 
 // ObjStream is a stream
@@ -284,7 +343,19 @@ type Rectangle struct {
 }
 
 func (r Rectangle) String() string {
-	return writeFloatArray([]Fl{r.Llx, r.Lly, r.Urx, r.Ury})
+	return string(r.appendTo(nil))
+}
+
+func (r Rectangle) appendTo(dst []byte) []byte {
+	dst = append(dst, '[')
+	dst = appendFloat(dst, r.Llx)
+	dst = append(dst, ' ')
+	dst = appendFloat(dst, r.Lly)
+	dst = append(dst, ' ')
+	dst = appendFloat(dst, r.Urx)
+	dst = append(dst, ' ')
+	dst = appendFloat(dst, r.Ury)
+	return append(dst, ']')
 }
 
 // Height returns the absolute value of the height of the rectangle.
@@ -2,12 +2,12 @@ package model
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"io"
 	"sort"
 	"strconv"
-	"strings"
 
 	"golang.org/x/text/encoding/unicode"
 )
@@ -22,7 +22,7 @@ type Reference uint32
 
 // String return a string to be used when writing a PDF
 func (r Reference) String() string {
-	return fmt.Sprintf("%d 0 R", r)
+	return string(appendRef(nil, r))
 }
 
 // output implements the logic needed to write object
@@ -34,7 +34,10 @@ type output struct {
 
 	// encode the object numbers as index (starting from 1)
 	// and the byte offsets of objects (starts at 1, [0] is unused)
+	// free object numbers have a 0 offset
 	objOffsets []int
+
+	scratch []byte // reused to serialize object headers
 }
 
 func (w *output) bytes(b []byte) {
@@ -49,6 +52,28 @@ func (w *output) bytes(b []byte) {
 	w.written += n
 }
 
+// string is the same as bytes, but avoid copying `s`
+// when `dst` implements io.StringWriter
+func (w *output) string(s string) {
+	if w.err != nil { // write is now a no-op
+		return
+	}
+	n, err := io.WriteString(w.dst, s)
+	if err != nil {
+		w.err = err
+		return
+	}
+	w.written += n
+}
+
+// objectHeader starts the object `ref`
+func (w *output) objectHeader(ref Reference) {
+	w.objOffsets[ref] = w.written
+	w.scratch = strconv.AppendUint(w.scratch[:0], uint64(ref), 10)
+	w.scratch = append(w.scratch, " 0 obj\n"...)
+	w.bytes(w.scratch)
+}
+
 // CreateObject return a new reference
 // and grow the `objOffsets` accordingly.
 // This is needed to write objects that must reference their "parent".
@@ -58,6 +83,18 @@ func (w *output) CreateObject() Reference {
 	return ref
 }
 
+// nextFree returns the first unused object number after `ref`,
+// or 0, as needed to build the linked list of free entries.
+// Unused numbers only appear when preserving object numbers.
+func (w *output) nextFree(ref int) int {
+	for j := ref + 1; j < len(w.objOffsets); j++ {
+		if w.objOffsets[j] == 0 {
+			return j
+		}
+	}
+	return 0
+}
+
 func (w *output) writeHeader() {
 	w.bytes([]byte("%PDF-1.7\n"))
 	// If a PDF file contains binary data, as most do (see 7.2, "Lexical Conventions"), the header line shall be
@@ -68,15 +105,62 @@ func (w *output) writeHeader() {
 	w.bytes([]byte("\n"))
 }
 
-func (w *output) writeFooter(trailer Trailer, root, info, encrypt Reference) {
+// structuralTrailerKeys are the trailer entries handled by the writer,
+// which can't be overridden by custom entries
+var structuralTrailerKeys = map[Name]bool{
+	"Size": true, "Prev": true, "Root": true, "Encrypt": true, "Info": true, "ID": true,
+	"XRefStm": true, "Type": true, "W": true, "Index": true,
+	"Filter": true, "DecodeParms": true, "Length": true,
+}
+
+// customTrailerEntries returns the additional trailer entries, from
+// `trailer.Custom` and `options.TrailerHook`
+func (w pdfWriter) customTrailerEntries(trailer Trailer, options WriteOptions) map[Name]string {
+	// trailer strings are not encrypted
+	plain := w
+	plain.encrypt = nil
+
+	out := make(map[Name]string)
+	for k, v := range trailer.Custom {
+		if v == nil || structuralTrailerKeys[k] {
+			continue
+		}
+		out[k] = v.Write(plain, 0)
+	}
+	if options.TrailerHook != nil {
+		for k, v := range options.TrailerHook(plain) {
+			if structuralTrailerKeys[k] {
+				continue
+			}
+			out[k] = v
+		}
+	}
+	return out
+}
+
+func (w pdfWriter) writeFooter(trailer Trailer, root, info, encrypt Reference, options WriteOptions) {
+	// custom entries may create new objects, so they must be
+	// written before the cross-reference section
+	custom := w.customTrailerEntries(trailer, options)
+
+	if options.XRefStream {
+		w.writeXRefStream(trailer, root, info, encrypt, custom)
+		return
+	}
+
 	var b bytes.Buffer
 	// Cross-ref
 	o, n := w.written, len(w.objOffsets)-1
 	b.WriteString("xref\n")
 	b.WriteString(fmt.Sprintf("0 %d\n", n+1))
-	b.WriteString("0000000000 65535 f \n")
+	b.Grow(20 * (n + 1))
+	b.Write(appendXrefEntry(w.scratch[:0], w.nextFree(0), "65535 f \n"))
 	for j := 1; j <= n; j++ {
-		b.WriteString(fmt.Sprintf("%010d 00000 n \n", w.objOffsets[j]))
+		if w.objOffsets[j] == 0 {
+			b.Write(appendXrefEntry(w.scratch[:0], w.nextFree(j), "00001 f \n"))
+		} else {
+			b.Write(appendXrefEntry(w.scratch[:0], w.objOffsets[j], "00000 n \n"))
+		}
 	}
 	// Trailer
 	b.WriteString("trailer\n")
@@ -89,6 +173,14 @@ func (w *output) writeFooter(trailer Trailer, root, info, encrypt Reference) {
 		b.WriteString(fmt.Sprintf("/ID [%s %s]\n",
 			EscapeByteString([]byte(trailer.ID[0])), EscapeByteString([]byte(trailer.ID[1]))))
 	}
+	keys := make([]Name, 0, len(custom))
+	for k := range custom {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	for _, k := range keys {
+		b.WriteString(fmt.Sprintf("%s %s\n", k, custom[k]))
+	}
 	b.WriteString(">>\n")
 	b.WriteString("startxref\n")
 	b.WriteString(fmt.Sprintf("%d\n", o))
@@ -96,6 +188,71 @@ func (w *output) writeFooter(trailer Trailer, root, info, encrypt Reference) {
 	w.bytes(b.Bytes())
 }
 
+// appendXrefEntry appends the 10 digits `offset`, followed by `suffix`
+func appendXrefEntry(dst []byte, offset int, suffix string) []byte {
+	var digits [20]byte
+	s := strconv.AppendInt(digits[:0], int64(offset), 10)
+	for i := len(s); i < 10; i++ {
+		dst = append(dst, '0')
+	}
+	dst = append(dst, s...)
+	dst = append(dst, ' ')
+	return append(dst, suffix...)
+}
+
+// writeXRefStream writes the cross-reference section as
+// a stream, which also acts as the trailer (see 7.5.8 - Cross-Reference Streams)
+func (w pdfWriter) writeXRefStream(trailer Trailer, root, info, encrypt Reference, custom map[Name]string) {
+	ref := w.CreateObject()
+	o, n := w.written, len(w.objOffsets)-1
+	w.objOffsets[ref] = o
+
+	// number of bytes needed for the offsets
+	offsetWidth := 1
+	for v := o >> 8; v > 0; v >>= 8 {
+		offsetWidth++
+	}
+	var content bytes.Buffer
+	writeField := func(v, width int) {
+		for i := width - 1; i >= 0; i-- {
+			content.WriteByte(byte(v >> (8 * i)))
+		}
+	}
+	// free head of the linked list
+	writeField(0, 1)
+	writeField(w.nextFree(0), offsetWidth)
+	writeField(65535, 2)
+	for j := 1; j <= n; j++ {
+		if w.objOffsets[j] == 0 {
+			writeField(0, 1)
+			writeField(w.nextFree(j), offsetWidth)
+			writeField(1, 2)
+		} else {
+			writeField(1, 1)
+			writeField(w.objOffsets[j], offsetWidth)
+			writeField(0, 2)
+		}
+	}
+
+	stream := NewCompressedStream(content.Bytes())
+	header := stream.PDFCommonFields(true)
+	header.BypassCrypt = true // the cross-reference stream is never encrypted
+	header.updateWith(custom)
+	header.Fields["Type"] = "/XRef"
+	header.Fields["Size"] = strconv.Itoa(n + 1)
+	header.Fields["W"] = fmt.Sprintf("[1 %d 2]", offsetWidth)
+	header.Fields["Root"] = root.String()
+	header.Fields["Info"] = info.String()
+	if encrypt > 0 {
+		header.Fields["Encrypt"] = encrypt.String()
+		header.Fields["ID"] = fmt.Sprintf("[%s %s]",
+			EscapeByteString([]byte(trailer.ID[0])), EscapeByteString([]byte(trailer.ID[1])))
+	}
+	w.WriteStream(header, stream.Content, ref)
+
+	w.bytes([]byte(fmt.Sprintf("startxref\n%d\n%%%%EOF", o)))
+}
+
 // pdfWriter uses an output and an internal cache
 // to write a Document.
 // The internal cache avoids duplication of indirect object,
@@ -109,12 +266,20 @@ type pdfWriter struct {
 	fields    map[*FormFieldDict]Reference
 	structure map[*StructureElement]Reference
 
+	// optional object numbers to preserve, and the
+	// preserved numbers not used yet
+	numbers  map[Referenceable]Reference
+	reserved map[Reference]bool
+
 	// needed by annotations and accroform,
 	// setup early
 	catalog           Reference
 	mergedAccroFields map[*AnnotationDict]*FormFieldDict
 
 	encrypt *Encrypt
+
+	// shape of the name trees split on write
+	treeShape TreeShape
 }
 
 func newWriter(dest io.Writer, encrypt *Encrypt) pdfWriter {
@@ -138,10 +303,7 @@ const (
 	TextString // one of the PDF encoding: PDFDocEncoding or UTF16-BE
 )
 
-var (
-	replacer = strings.NewReplacer("\\", "\\\\", "(", "\\(", ")", "\\)", "\r", "\\r")
-	utf16Enc = unicode.UTF16(unicode.BigEndian, unicode.UseBOM)
-)
+var utf16Enc = unicode.UTF16(unicode.BigEndian, unicode.UseBOM)
 
 // WrittenObject represents a PDF object to write on a file.
 // This intermediate representation makes to possible to
@@ -153,19 +315,26 @@ type StreamHeader struct {
 }
 
 func (w StreamHeader) PDFContent() []byte {
+	return w.appendPDFContent(nil)
+}
+
+// appendPDFContent appends the dictionary to `dst`
+func (w StreamHeader) appendPDFContent(dst []byte) []byte {
 	// sort for deterministic output
 	keys := make([]Name, 0, len(w.Fields))
 	for k := range w.Fields {
 		keys = append(keys, k)
 	}
 	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
-	var out bytes.Buffer
-	out.WriteString("<<")
+	dst = append(dst, "<<"...)
 	for _, k := range keys {
-		out.WriteString(k.String() + " " + w.Fields[k] + " ")
+		dst = append(dst, '/')
+		dst = append(dst, k...)
+		dst = append(dst, ' ')
+		dst = append(dst, w.Fields[k]...)
+		dst = append(dst, ' ')
 	}
-	out.WriteString(">>")
-	return out.Bytes()
+	return append(dst, ">>"...)
 }
 
 func (w *StreamHeader) updateWith(other map[Name]string) {
@@ -207,8 +376,24 @@ type PDFWritter interface {
 // PDFStringEncoder.EncodeString provides a more general
 // approach, and should be used when implementing custom types.
 func EscapeByteString(sb []byte) string {
-	s := replacer.Replace(string(sb))
-	return "(" + s + ")"
+	return string(appendEscapedByteString(make([]byte, 0, len(sb)+2), sb))
+}
+
+// appendEscapedByteString appends the string litteral `sb`,
+// as returned by EscapeByteString
+func appendEscapedByteString(dst, sb []byte) []byte {
+	dst = append(dst, '(')
+	for _, c := range sb {
+		switch c {
+		case '\\', '(', ')':
+			dst = append(dst, '\\', c)
+		case '\r':
+			dst = append(dst, '\\', 'r')
+		default:
+			dst = append(dst, c)
+		}
+	}
+	return append(dst, ')')
 }
 
 // EspaceHexString return a pdf compatible hex string, by
@@ -235,7 +420,7 @@ func (p pdfWriter) EncodeString(s string, mode PDFStringEncoding, context Refere
 		} else {
 			sb, err = utf16Enc.NewEncoder().Bytes(sb)
 			if err != nil {
-				p.err = fmt.Errorf("invalid text string %s: %w", s, err)
+				p.err = wrapError(ErrInvalidArgument, err, "invalid text string %s", s)
 				return ""
 			}
 		}
@@ -265,18 +450,16 @@ func (p pdfWriter) EncodeString(s string, mode PDFStringEncoding, context Refere
 // and `stream` the inner stream bytes. For other objects, `stream` will be nil.
 // Stream content will be encrypted if needed.
 func (w pdfWriter) WriteObject(content string, ref Reference) {
-	w.objOffsets[ref] = w.written
-	w.bytes([]byte(fmt.Sprintf("%d 0 obj\n", ref)))
-	w.bytes([]byte(content))
-	w.bytes([]byte("\nendobj\n"))
+	w.objectHeader(ref)
+	w.string(content)
+	w.string("\nendobj\n")
 }
 
 // WriteStream write the content of the object `ref`, and update the offsets.
 // This method will be called at most once for each reference.
 // Stream content will be encrypted if needed and the Length field adjusted.
 func (w pdfWriter) WriteStream(content StreamHeader, stream []byte, ref Reference) {
-	w.objOffsets[ref] = w.written
-	w.bytes([]byte(fmt.Sprintf("%d 0 obj\n", ref)))
+	w.objectHeader(ref)
 	// we first need to adjust the Length
 	if w.encrypt != nil && w.encrypt.EncryptionHandler != nil && !content.BypassCrypt {
 		// we must ensure we dont modify the original stream
@@ -285,14 +468,15 @@ func (w pdfWriter) WriteStream(content StreamHeader, stream []byte, ref Referenc
 		w.encrypt.EncryptionHandler.crypt(ref, stream)
 		content.Fields["Length"] = strconv.Itoa(len(stream))
 	}
-	w.bytes(content.PDFContent())
+	w.scratch = content.appendPDFContent(w.scratch[:0])
+	w.bytes(w.scratch)
 	if stream != nil {
-		w.bytes([]byte("\nstream\n"))
+		w.string("\nstream\n")
 		w.bytes(stream)
 		// There should be an end-of-line marker after the data and before endstream
-		w.bytes([]byte("\nendstream"))
+		w.string("\nendstream")
 	}
-	w.bytes([]byte("\nendobj\n"))
+	w.string("\nendobj\n")
 }
 
 // addObject is a convenience shortcut to write `content` into a new object
@@ -333,30 +517,62 @@ type Referenceable interface {
 	pdfContent(pdf pdfWriter, objectRef Reference) (header StreamHeader, content string, stream []byte)
 }
 
-func (*FontDict) IsReferenceable()                 {}
-func (*GraphicState) IsReferenceable()             {}
-func (*SimpleEncodingDict) IsReferenceable()       {}
-func (*AnnotationDict) IsReferenceable()           {}
-func (*FileSpec) IsReferenceable()                 {}
-func (*EmbeddedFileStream) IsReferenceable()       {}
-func (*ShadingDict) IsReferenceable()              {}
-func (*FunctionDict) IsReferenceable()             {}
-func (*PatternTiling) IsReferenceable()            {}
-func (*PatternShading) IsReferenceable()           {}
-func (*ColorSpaceICCBased) IsReferenceable()       {}
-func (*ColorTableStream) IsReferenceable()         {}
-func (*XObjectForm) IsReferenceable()              {}
-func (*XObjectTransparencyGroup) IsReferenceable() {}
-func (*XObjectImage) IsReferenceable()             {}
-func (*ImageSMask) IsReferenceable()               {}
-func (*FontFile) IsReferenceable()                 {}
+func (*FontDict) IsReferenceable()                  {}
+func (*GraphicState) IsReferenceable()              {}
+func (*SimpleEncodingDict) IsReferenceable()        {}
+func (*AnnotationDict) IsReferenceable()            {}
+func (*FileSpec) IsReferenceable()                  {}
+func (*EmbeddedFileStream) IsReferenceable()        {}
+func (*ShadingDict) IsReferenceable()               {}
+func (*FunctionDict) IsReferenceable()              {}
+func (*PatternTiling) IsReferenceable()             {}
+func (*PatternShading) IsReferenceable()            {}
+func (*ColorSpaceICCBased) IsReferenceable()        {}
+func (*ColorTableStream) IsReferenceable()          {}
+func (*XObjectForm) IsReferenceable()               {}
+func (*XObjectTransparencyGroup) IsReferenceable()  {}
+func (*XObjectImage) IsReferenceable()              {}
+func (*ImageSMask) IsReferenceable()                {}
+func (*FontFile) IsReferenceable()                  {}
+func (*OptionalContentGroup) IsReferenceable()      {}
+func (*OptionalContentMembership) IsReferenceable() {}
+func (*HalftoneDict) IsReferenceable()              {}
+func (*SignatureDict) IsReferenceable()             {}
+func (*SoundStream) IsReferenceable()               {}
+func (*Stream3D) IsReferenceable()                  {}
+
+// preserveNumbers reserves the object numbers in `numbers`,
+// so that the objects created afterwards are numbered after them.
+// It must be called before any object is created.
+func (pdf *pdfWriter) preserveNumbers(numbers map[Referenceable]Reference) {
+	pdf.numbers = numbers
+	pdf.reserved = make(map[Reference]bool, len(numbers))
+	max := Reference(0)
+	for _, ref := range numbers {
+		if ref == 0 {
+			continue
+		}
+		pdf.reserved[ref] = true
+		if ref > max {
+			max = ref
+		}
+	}
+	for Reference(len(pdf.objOffsets)) <= max {
+		pdf.objOffsets = append(pdf.objOffsets, 0)
+	}
+}
 
 // check the cache and write a new item if not found
 func (pdf pdfWriter) addItem(item Referenceable) Reference {
 	if ref, has := pdf.cache[item]; has {
 		return ref
 	}
-	ref := pdf.CreateObject()
+	ref, has := pdf.numbers[item]
+	if has && pdf.reserved[ref] {
+		delete(pdf.reserved, ref)
+	} else {
+		ref = pdf.CreateObject()
+	}
 	pdf.cache[item] = ref
 	header, obj, s := item.pdfContent(pdf, ref)
 	if header.Fields != nil {
@@ -366,3 +582,14 @@ func (pdf pdfWriter) addItem(item Referenceable) Reference {
 	}
 	return ref
 }
+
+// Fingerprint returns a hash of the PDF serialization of `item`,
+// including the objects it references. Two items with the same
+// fingerprint are written identically, and may be replaced by
+// one of them (see Referenceable).
+func Fingerprint(item Referenceable) [sha256.Size]byte {
+	var buf bytes.Buffer
+	pdf := newWriter(&buf, nil)
+	pdf.addItem(item)
+	return sha256.Sum256(buf.Bytes())
+}
@@ -2,6 +2,7 @@ package model
 
 import (
 	"fmt"
+	"io"
 	"strings"
 	"testing"
 )
@@ -22,3 +23,64 @@ func BenchmarkString(b *testing.B) {
 		}
 	})
 }
+
+// pagesDocument returns a document with `n` pages,
+// sharing a font and with some annotations
+func pagesDocument(n int) *Document {
+	font := &FontDict{Subtype: FontType1{BaseFont: "Helvetica", FirstChar: 32, Widths: make([]int, 95)}}
+	var doc Document
+	doc.Trailer.Info = Info{Title: "Benchmark", Author: "Writer"}
+	for i := 0; i < n; i++ {
+		page := &PageObject{
+			MediaBox: &Rectangle{Urx: 595.28, Ury: 841.89},
+			Resources: &ResourcesDict{
+				Font:      map[Name]*FontDict{"F1": font},
+				ExtGState: map[Name]*GraphicState{"GS1": {LW: 1.5, CA: ObjFloat(0.5)}},
+			},
+			Contents: []ContentStream{{Stream: Stream{Content: []byte(fmt.Sprintf("BT /F1 12 Tf 72 720 Td (Page %d) Tj ET", i+1))}}},
+		}
+		for j := 0; j < 4; j++ {
+			page.Annots = append(page.Annots, &AnnotationDict{
+				BaseAnnotation: BaseAnnotation{Rect: Rectangle{Llx: 72, Lly: Fl(100 * j), Urx: 200, Ury: Fl(100*j + 20)}, Contents: "A link"},
+				Subtype:        AnnotationLink{A: Action{ActionType: ActionURI{URI: "https://www.example.com"}}},
+			})
+		}
+		doc.Catalog.Pages.Kids = append(doc.Catalog.Pages.Kids, page)
+	}
+	return &doc
+}
+
+func BenchmarkWritePages(b *testing.B) {
+	doc := pagesDocument(1000)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := doc.Write(io.Discard, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	newForm := func(content string) *XObjectForm {
+		return &XObjectForm{
+			ContentStream: ContentStream{Stream: Stream{Content: []byte(content)}},
+			BBox:          Rectangle{Urx: 10, Ury: 10},
+			Resources: ResourcesDict{
+				Font:      map[Name]*FontDict{"F1": {Subtype: FontType1{BaseFont: "Helvetica"}}, "F2": {Subtype: FontType1{BaseFont: "Courier"}}},
+				ExtGState: map[Name]*GraphicState{"G1": {CA: ObjFloat(0.5)}, "G2": {BM: []Name{"Multiply"}}},
+			},
+		}
+	}
+	f1, f2, f3 := newForm("/F1 12 Tf"), newForm("/F1 12 Tf"), newForm("/F2 12 Tf")
+	if Fingerprint(f1) != Fingerprint(f2) {
+		t.Fatal("expected same fingerprints")
+	}
+	for i := 0; i < 10; i++ { // the resources must be written in a deterministic order
+		if Fingerprint(f1) != Fingerprint(f1) {
+			t.Fatal("expected same fingerprints")
+		}
+	}
+	if Fingerprint(f1) == Fingerprint(f3) {
+		t.Fatal("expected different fingerprints")
+	}
+}
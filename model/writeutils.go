@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"math"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -11,15 +12,26 @@ import (
 
 // FmtFloat returns a PDF compatible float representation of `f`.
 func FmtFloat(f Fl) string {
+	return string(appendFloat(nil, f))
+}
+
+// appendFloat appends the representation of `f` returned by FmtFloat
+func appendFloat(dst []byte, f Fl) []byte {
 	// avoid to represent 0 as -0
 	if f == 0 {
-		return "0"
+		return append(dst, '0')
 	}
 	// Round rounds f with 12 digits precision
 	n := math.Pow10(5)
 	f_ := math.Round(float64(f)*n) / n
 
-	return strconv.FormatFloat(f_, 'f', -1, 32)
+	return strconv.AppendFloat(dst, f_, 'f', -1, 32)
+}
+
+// appendRef appends the indirect reference to the object `ref`
+func appendRef(dst []byte, ref Reference) []byte {
+	dst = strconv.AppendUint(dst, uint64(ref), 10)
+	return append(dst, " 0 R"...)
 }
 
 func writeMaybeFloat(f MaybeFloat) string {
@@ -30,59 +42,94 @@ func writeMaybeFloat(f MaybeFloat) string {
 }
 
 func writeIntArray(as []int) string {
-	b := make([]string, len(as))
+	return string(appendIntArray(nil, as))
+}
+
+func appendIntArray(dst []byte, as []int) []byte {
+	dst = append(dst, '[')
 	for i, a := range as {
-		b[i] = strconv.Itoa(a)
+		if i != 0 {
+			dst = append(dst, ' ')
+		}
+		dst = strconv.AppendInt(dst, int64(a), 10)
 	}
-	return fmt.Sprintf("[%s]", strings.Join(b, " "))
+	return append(dst, ']')
 }
 
 func writeFloatArray(as []Fl) string {
-	b := make([]string, len(as))
+	return string(appendFloatArray(nil, as))
+}
+
+func appendFloatArray(dst []byte, as []Fl) []byte {
+	dst = append(dst, '[')
 	for i, a := range as {
-		b[i] = FmtFloat(a)
+		if i != 0 {
+			dst = append(dst, ' ')
+		}
+		dst = appendFloat(dst, a)
 	}
-	return fmt.Sprintf("[%s]", strings.Join(b, " "))
+	return append(dst, ']')
 }
 
 func writeRefArray(as []Reference) string {
-	b := make([]string, len(as))
+	return string(appendRefArray(nil, as))
+}
+
+func appendRefArray(dst []byte, as []Reference) []byte {
+	dst = append(dst, '[')
 	for i, ref := range as {
-		b[i] = ref.String()
+		if i != 0 {
+			dst = append(dst, ' ')
+		}
+		dst = appendRef(dst, ref)
 	}
-	return fmt.Sprintf("[%s]", strings.Join(b, " "))
+	return append(dst, ']')
 }
 
 func writePointArray(rs [][2]Fl) string {
-	b := make([]string, len(rs))
+	return string(appendPointArray(nil, rs))
+}
+
+func appendPointArray(dst []byte, rs [][2]Fl) []byte {
+	dst = append(dst, '[')
 	for i, a := range rs {
-		b[i] = fmt.Sprintf("%s %s ", FmtFloat(a[0]), FmtFloat(a[1]))
+		if i != 0 {
+			dst = append(dst, ' ')
+		}
+		dst = appendFloat(dst, a[0])
+		dst = append(dst, ' ')
+		dst = appendFloat(dst, a[1])
 	}
-	return fmt.Sprintf("[%s]", strings.Join(b, " "))
+	return append(dst, ']')
 }
 
 func writeRangeArray(rs []Range) string {
-	b := make([]string, len(rs))
+	dst := []byte{'['}
 	for i, a := range rs {
-		b[i] = fmt.Sprintf("%s %s", FmtFloat(a[0]), FmtFloat(a[1]))
+		if i != 0 {
+			dst = append(dst, ' ')
+		}
+		dst = appendFloat(dst, a[0])
+		dst = append(dst, ' ')
+		dst = appendFloat(dst, a[1])
 	}
-	return fmt.Sprintf("[%s]", strings.Join(b, " "))
+	return string(append(dst, ']'))
 }
 
 func writePointsArray(rs [][2]Fl) string {
-	b := make([]string, len(rs))
-	for i, a := range rs {
-		b[i] = fmt.Sprintf("%s %s ", FmtFloat(a[0]), FmtFloat(a[1]))
-	}
-	return fmt.Sprintf("[%s]", strings.Join(b, " "))
+	return writePointArray(rs)
 }
 
 func writeNameArray(rs []Name) string {
-	b := make([]string, len(rs))
+	dst := []byte{'['}
 	for i, a := range rs {
-		b[i] = a.String()
+		if i != 0 {
+			dst = append(dst, ' ')
+		}
+		dst = append(dst, '/')
+		dst = append(dst, a...)
 	}
-	return fmt.Sprintf("[%s]", strings.Join(b, " "))
+	return string(append(dst, ']'))
 }
 
 // DateTimeString returns a valid PDF string representation of `t`.
@@ -131,3 +178,78 @@ func (b buffer) line(format string, arg ...interface{}) {
 	b.fmt(format, arg...)
 	b.WriteByte('\n')
 }
+
+// The following methods avoid the cost of fmt,
+// and are used for the most common entries.
+
+func (b buffer) key(key Name) {
+	b.WriteByte('/')
+	b.WriteString(string(key))
+	b.WriteByte(' ')
+}
+
+// entry writes "/key value"
+func (b buffer) entry(key Name, value string) {
+	b.key(key)
+	b.WriteString(value)
+}
+
+// int writes "/key i"
+func (b buffer) int(key Name, i int) {
+	var tmp [20]byte
+	b.key(key)
+	b.Write(strconv.AppendInt(tmp[:0], int64(i), 10))
+}
+
+// ref writes "/key ref 0 R"
+func (b buffer) ref(key Name, ref Reference) {
+	var tmp [24]byte
+	b.key(key)
+	b.Write(appendRef(tmp[:0], ref))
+}
+
+// refs writes "/key [ref1 0 R ...]"
+func (b buffer) refs(key Name, refs []Reference) {
+	var tmp [64]byte
+	b.key(key)
+	b.Write(appendRefArray(tmp[:0], refs))
+}
+
+// floats writes "/key [f1 ...]"
+func (b buffer) floats(key Name, fs []Fl) {
+	var tmp [64]byte
+	b.key(key)
+	b.Write(appendFloatArray(tmp[:0], fs))
+}
+
+// custom writes the entries of `dict`, sorted by key, ignoring
+// nil values. `ref` is the object number of the containing object.
+func (b buffer) custom(pdf pdfWriter, dict ObjDict, ref Reference) {
+	keys := make([]string, 0, len(dict))
+	for k, v := range dict {
+		if v != nil {
+			keys = append(keys, string(k))
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b.key(Name(k))
+		b.WriteString(dict[Name(k)].Write(pdf, ref))
+		b.WriteByte('\n')
+	}
+}
+
+// cloneCustom returns a deep copy of `dict`, preserving nil
+func cloneCustom(dict ObjDict) ObjDict {
+	if dict == nil {
+		return nil
+	}
+	return dict.Clone().(ObjDict)
+}
+
+// rect writes "/key [llx lly urx ury]"
+func (b buffer) rect(key Name, r Rectangle) {
+	var tmp [64]byte
+	b.key(key)
+	b.Write(r.appendTo(tmp[:0]))
+}
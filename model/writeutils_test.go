@@ -19,3 +19,29 @@ func TestFmtFloat(t *testing.T) {
 		}
 	}
 }
+
+func TestWriteArrays(t *testing.T) {
+	tests := []struct {
+		got, want string
+	}{
+		{FmtFloat(0), "0"},
+		{FmtFloat(-0.5), "-0.5"},
+		{writeIntArray([]int{1, -2, 3}), "[1 -2 3]"},
+		{writeFloatArray([]Fl{0, 1.5, -2}), "[0 1.5 -2]"},
+		{writeRefArray([]Reference{3, 12}), "[3 0 R 12 0 R]"},
+		{writePointArray([][2]Fl{{1, 2}, {3, 4}}), "[1 2 3 4]"},
+		{writeRangeArray([]Range{{0, 1}}), "[0 1]"},
+		{writeNameArray([]Name{"A", "B"}), "[/A /B]"},
+		{Rectangle{0, 0, 595.28, 841.89}.String(), "[0 0 595.28 841.89]"},
+		{Reference(7).String(), "7 0 R"},
+		{EscapeByteString([]byte("a(b)\\c\r")), `(a\(b\)\\c\r)`},
+		{string(appendXrefEntry(nil, 1234, "00000 n \n")), "0000001234 00000 n \n"},
+		{ObjArray{ObjInt(1), ObjFloat(0.5), ObjBool(true), ObjName("N"), ObjNull{}, ObjIndirectRef{ObjectNumber: 4}}.Write(nil, 0), "[1 0.5 true /N null 4 0 R]"},
+		{ObjDict{"Key": ObjArray{ObjCommand("cm")}}.Write(nil, 0), "<<\n/Key\n[cm]\n>>"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("expected %q, got %q", tt.want, tt.got)
+		}
+	}
+}
@@ -0,0 +1,414 @@
+package model
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// XMP namespaces
+const (
+	nsRDF = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	nsXML = "http://www.w3.org/XML/1998/namespace"
+	nsDC  = "http://purl.org/dc/elements/1.1/"
+	nsXMP = "http://ns.adobe.com/xap/1.0/"
+	nsPDF = "http://ns.adobe.com/pdf/1.3/"
+)
+
+// XMPProperty identifies an XMP property by its namespace URI and local name.
+type XMPProperty struct {
+	Namespace, Name string
+}
+
+// infoProperties maps the Info entries to their XMP equivalent.
+// See 14.3.2 - Metadata Streams
+var infoProperties = [...]struct {
+	prop   XMPProperty
+	prefix string
+	kind   string // Alt, Seq or empty for simple text
+	text   func(info *Info) *string
+	date   func(info *Info) *time.Time
+}{
+	{prop: XMPProperty{nsDC, "title"}, prefix: "dc", kind: "Alt", text: func(info *Info) *string { return &info.Title }},
+	{prop: XMPProperty{nsDC, "creator"}, prefix: "dc", kind: "Seq", text: func(info *Info) *string { return &info.Author }},
+	{prop: XMPProperty{nsDC, "description"}, prefix: "dc", kind: "Alt", text: func(info *Info) *string { return &info.Subject }},
+	{prop: XMPProperty{nsPDF, "Keywords"}, prefix: "pdf", text: func(info *Info) *string { return &info.Keywords }},
+	{prop: XMPProperty{nsPDF, "Producer"}, prefix: "pdf", text: func(info *Info) *string { return &info.Producer }},
+	{prop: XMPProperty{nsXMP, "CreatorTool"}, prefix: "xmp", text: func(info *Info) *string { return &info.Creator }},
+	{prop: XMPProperty{nsXMP, "CreateDate"}, prefix: "xmp", date: func(info *Info) *time.Time { return &info.CreationDate }},
+	{prop: XMPProperty{nsXMP, "ModifyDate"}, prefix: "xmp", date: func(info *Info) *time.Time { return &info.ModDate }},
+}
+
+func isInfoProperty(prop XMPProperty) bool {
+	for _, p := range infoProperties {
+		if p.prop == prop {
+			return true
+		}
+	}
+	return false
+}
+
+// xmlNode is a generic XML element
+type xmlNode struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Content string     `xml:",chardata"`
+	Nodes   []xmlNode  `xml:",any"`
+}
+
+// value returns the text of a simple property, or the items of
+// an array (rdf:Seq, rdf:Bag), joined by a comma.
+// For language alternatives (rdf:Alt), the default item is returned.
+func (n xmlNode) value() string {
+	for _, child := range n.Nodes {
+		if child.XMLName.Space != nsRDF {
+			continue
+		}
+		switch child.XMLName.Local {
+		case "Alt":
+			for _, li := range child.Nodes {
+				for _, attr := range li.Attrs {
+					if attr.Name.Space == nsXML && attr.Name.Local == "lang" && attr.Value == "x-default" {
+						return strings.TrimSpace(li.Content)
+					}
+				}
+			}
+			if len(child.Nodes) != 0 {
+				return strings.TrimSpace(child.Nodes[0].Content)
+			}
+			return ""
+		case "Seq", "Bag":
+			items := make([]string, len(child.Nodes))
+			for i, li := range child.Nodes {
+				items[i] = strings.TrimSpace(li.Content)
+			}
+			return strings.Join(items, ", ")
+		}
+	}
+	return strings.TrimSpace(n.Content)
+}
+
+// ParseXMP returns the simple properties found in the
+// rdf:Description elements of the given XMP packet.
+// Properties may be written as attributes or as elements.
+func ParseXMP(packet []byte) (map[XMPProperty]string, error) {
+	var root xmlNode
+	if err := xml.Unmarshal(packet, &root); err != nil {
+		return nil, wrapError(ErrType, err, "invalid XMP metadata")
+	}
+	out := make(map[XMPProperty]string)
+	var walk func(node xmlNode)
+	walk = func(node xmlNode) {
+		if node.XMLName.Space == nsRDF && node.XMLName.Local == "Description" {
+			for _, attr := range node.Attrs {
+				switch attr.Name.Space {
+				case "", "xmlns", nsRDF, nsXML:
+					continue
+				}
+				out[XMPProperty{attr.Name.Space, attr.Name.Local}] = attr.Value
+			}
+			for _, child := range node.Nodes {
+				out[XMPProperty{child.XMLName.Space, child.XMLName.Local}] = child.value()
+			}
+			return
+		}
+		for _, child := range node.Nodes {
+			walk(child)
+		}
+	}
+	walk(root)
+	return out, nil
+}
+
+// ParseXMPDate parses the subset of ISO 8601 used by XMP
+func ParseXMPDate(s string) (time.Time, error) {
+	for _, layout := range [...]string{
+		time.RFC3339Nano,
+		"2006-01-02T15:04:05",
+		"2006-01-02T15:04Z07:00",
+		"2006-01-02T15:04",
+		"2006-01-02",
+		"2006-01",
+		"2006",
+	} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, newError(ErrType, "invalid XMP date %s", s)
+}
+
+// InfoFromXMP returns the Info entries defined by the
+// XMP properties `props` (see `ParseXMP`). Invalid dates are ignored.
+func InfoFromXMP(props map[XMPProperty]string) Info {
+	var out Info
+	for _, p := range infoProperties {
+		value, has := props[p.prop]
+		if !has {
+			continue
+		}
+		if p.text != nil {
+			*p.text(&out) = value
+		} else if date, err := ParseXMPDate(value); err == nil {
+			*p.date(&out) = date
+		}
+	}
+	return out
+}
+
+// emptyXMP is the skeleton used when creating a new XMP packet
+const emptyXMP = "<?xpacket begin=\"\ufeff\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n" +
+	"<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n" +
+	" <rdf:RDF xmlns:rdf=\"" + nsRDF + "\">\n" +
+	" </rdf:RDF>\n" +
+	"</x:xmpmeta>\n" +
+	"<?xpacket end=\"w\"?>"
+
+// xmpEdit replaces packet[start:end] by `content`
+type xmpEdit struct {
+	start, end int
+	content    string
+}
+
+// UpdateXMP returns a copy of the XMP `packet`, where the properties equivalent
+// to the Info entries are replaced by the values of `info`. Empty entries remove
+// the properties. The other properties are preserved.
+// If `packet` is empty, a new packet is created.
+func (info Info) UpdateXMP(packet []byte) ([]byte, error) {
+	if len(bytes.TrimSpace(packet)) == 0 {
+		packet = []byte(emptyXMP)
+	}
+
+	var (
+		edits []xmpEdit
+		// namespace scopes, mapping prefix to namespace
+		scopes        []map[string]string
+		inDescription = -1 // depth of the current rdf:Description element
+		foundRDF      bool
+	)
+	resolve := func(prefix string) string {
+		for i := len(scopes) - 1; i >= 0; i-- {
+			if ns, has := scopes[i][prefix]; has {
+				return ns
+			}
+		}
+		return prefix
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(packet))
+	for {
+		start := int(decoder.InputOffset())
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, wrapError(ErrType, err, "invalid XMP metadata")
+		}
+		end := int(decoder.InputOffset())
+		switch token := token.(type) {
+		case xml.StartElement:
+			scope := map[string]string{}
+			for _, attr := range token.Attr {
+				if attr.Name.Space == "xmlns" {
+					scope[attr.Name.Local] = attr.Value
+				} else if attr.Name.Space == "" && attr.Name.Local == "xmlns" {
+					scope[""] = attr.Value
+				}
+			}
+			scopes = append(scopes, scope)
+			depth := len(scopes)
+			name := XMPProperty{resolve(token.Name.Space), token.Name.Local}
+
+			if depth == inDescription+1 && isInfoProperty(name) {
+				// skip the whole property
+				if err = skipElement(decoder); err != nil {
+					return nil, wrapError(ErrType, err, "invalid XMP metadata")
+				}
+				scopes = scopes[:depth-1]
+				edits = append(edits, xmpEdit{start: trimLineStart(packet, start), end: int(decoder.InputOffset())})
+				continue
+			}
+
+			if name == (XMPProperty{nsRDF, "Description"}) {
+				inDescription = depth
+				if tag, modified := removeInfoAttributes(packet[start:end], token, resolve); modified {
+					edits = append(edits, xmpEdit{start: start, end: end, content: tag})
+				}
+			}
+		case xml.EndElement:
+			depth := len(scopes)
+			if depth == 0 {
+				return nil, newError(ErrType, "invalid XMP metadata: unexpected end element %s", token.Name.Local)
+			}
+			if name := (XMPProperty{resolve(token.Name.Space), token.Name.Local}); name == (XMPProperty{nsRDF, "RDF"}) {
+				foundRDF = true
+				edits = append(edits, xmpEdit{start: start, end: start, content: info.xmpDescription()})
+			}
+			if depth == inDescription {
+				inDescription = -1
+			}
+			scopes = scopes[:depth-1]
+		}
+	}
+	if !foundRDF {
+		return nil, newError(ErrMissingEntry, "invalid XMP metadata: missing rdf:RDF element")
+	}
+
+	var out bytes.Buffer
+	last := 0
+	for _, edit := range edits {
+		out.Write(packet[last:edit.start])
+		out.WriteString(edit.content)
+		last = edit.end
+	}
+	out.Write(packet[last:])
+	return out.Bytes(), nil
+}
+
+// skipElement consumes the tokens until the end of the current element.
+// We can't use Decoder.Skip, which does not work with RawToken.
+func skipElement(decoder *xml.Decoder) error {
+	for depth := 1; depth > 0; {
+		token, err := decoder.RawToken()
+		if err != nil {
+			return err
+		}
+		switch token.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			depth--
+		}
+	}
+	return nil
+}
+
+// trimLineStart extends `start` backward to the beginning of the line,
+// if only white spaces are found.
+func trimLineStart(packet []byte, start int) int {
+	i := start
+	for i > 0 && (packet[i-1] == ' ' || packet[i-1] == '\t') {
+		i--
+	}
+	if i > 0 && packet[i-1] == '\n' {
+		return i - 1
+	}
+	return start
+}
+
+// removeInfoAttributes returns the start tag without the attributes
+// equivalent to Info entries, or false if there are none.
+func removeInfoAttributes(raw []byte, token xml.StartElement, resolve func(string) string) (string, bool) {
+	var (
+		attrs   []xml.Attr
+		removed bool
+	)
+	for _, attr := range token.Attr {
+		if isInfoProperty(XMPProperty{resolve(attr.Name.Space), attr.Name.Local}) {
+			removed = true
+			continue
+		}
+		attrs = append(attrs, attr)
+	}
+	if !removed {
+		return "", false
+	}
+	var b bytes.Buffer
+	b.WriteString("<" + rawName(token.Name))
+	for _, attr := range attrs {
+		b.WriteString(" " + rawName(attr.Name) + `="`)
+		xml.EscapeText(&b, []byte(attr.Value))
+		b.WriteString(`"`)
+	}
+	if bytes.HasSuffix(raw, []byte("/>")) {
+		b.WriteString("/>")
+	} else {
+		b.WriteString(">")
+	}
+	return b.String(), true
+}
+
+// rawName returns the name as written in the packet
+func rawName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+// xmpDescription returns a rdf:Description element with the
+// properties equivalent to the Info entries, or an empty string
+// if `info` is empty.
+func (info Info) xmpDescription() string {
+	var b bytes.Buffer
+	for _, p := range infoProperties {
+		tag := p.prefix + ":" + p.prop.Name
+		if p.date != nil {
+			if date := *p.date(&info); !date.IsZero() {
+				fmt.Fprintf(&b, "   <%s>%s</%s>\n", tag, date.Format(time.RFC3339), tag)
+			}
+			continue
+		}
+		value := *p.text(&info)
+		if value == "" {
+			continue
+		}
+		if p.kind == "" {
+			fmt.Fprintf(&b, "   <%s>", tag)
+			xml.EscapeText(&b, []byte(value))
+			fmt.Fprintf(&b, "</%s>\n", tag)
+			continue
+		}
+		lang := ""
+		if p.kind == "Alt" {
+			lang = ` xml:lang="x-default"`
+		}
+		fmt.Fprintf(&b, "   <%s><rdf:%s><rdf:li%s>", tag, p.kind, lang)
+		xml.EscapeText(&b, []byte(value))
+		fmt.Fprintf(&b, "</rdf:li></rdf:%s></%s>\n", p.kind, tag)
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return " <rdf:Description rdf:about=\"\" xmlns:rdf=\"" + nsRDF + "\" xmlns:dc=\"" + nsDC +
+		"\" xmlns:xmp=\"" + nsXMP + "\" xmlns:pdf=\"" + nsPDF + "\">\n" +
+		b.String() + "  </rdf:Description>\n "
+}
+
+// syncMetadata returns the Info entries, completed by the XMP properties of
+// `metadata`, and an updated XMP metadata stream.
+func syncMetadata(info Info, metadata *MetadataStream) (Info, *MetadataStream, error) {
+	var packet []byte
+	if metadata != nil {
+		var err error
+		packet, err = metadata.Decode()
+		if err != nil {
+			return info, nil, fmt.Errorf("can't decode XMP metadata: %w", err)
+		}
+		props, err := ParseXMP(packet)
+		if err != nil {
+			return info, nil, err
+		}
+		// fill the missing entries
+		xmpInfo := InfoFromXMP(props)
+		for _, p := range infoProperties {
+			if p.text != nil {
+				if s := p.text(&info); *s == "" {
+					*s = *p.text(&xmpInfo)
+				}
+			} else if d := p.date(&info); d.IsZero() {
+				*d = *p.date(&xmpInfo)
+			}
+		}
+	}
+	packet, err := info.UpdateXMP(packet)
+	if err != nil {
+		return info, nil, err
+	}
+	// metadata should not be compressed, so that it can be read by any tool
+	return info, &MetadataStream{Stream: Stream{Content: packet}}, nil
+}
@@ -0,0 +1,108 @@
+package model
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const nsPDFAID = "http://www.aiim.org/pdfa/ns/id/"
+
+func TestParseXMP(t *testing.T) {
+	packet := []byte(`<x:xmpmeta xmlns:x="adobe:ns:meta/">
+	<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+	<rdf:Description rdf:about="" xmlns:pdfaid="http://www.aiim.org/pdfa/ns/id/" pdfaid:part="2" pdfaid:conformance="B"/>
+	<rdf:Description rdf:about="" xmlns:dc="http://purl.org/dc/elements/1.1/">
+		<dc:title><rdf:Alt><rdf:li xml:lang="fr">Titre</rdf:li><rdf:li xml:lang="x-default">Title</rdf:li></rdf:Alt></dc:title>
+		<dc:creator><rdf:Seq><rdf:li>A</rdf:li><rdf:li>B</rdf:li></rdf:Seq></dc:creator>
+	</rdf:Description>
+	</rdf:RDF>
+	</x:xmpmeta>`)
+	props, err := ParseXMP(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for prop, exp := range map[XMPProperty]string{
+		{nsPDFAID, "part"}:        "2",
+		{nsPDFAID, "conformance"}: "B",
+		{nsDC, "title"}:           "Title",
+		{nsDC, "creator"}:         "A, B",
+	} {
+		if props[prop] != exp {
+			t.Errorf("for %v, expected %s, got %s", prop, exp, props[prop])
+		}
+	}
+}
+
+func TestUpdateXMP(t *testing.T) {
+	packet := []byte(`<?xpacket begin="" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about="" xmlns:pdfaid="http://www.aiim.org/pdfa/ns/id/" xmlns:pdf="http://ns.adobe.com/pdf/1.3/" pdfaid:part="2" pdf:Producer="Old producer"/>
+  <rdf:Description rdf:about="" xmlns:d="http://purl.org/dc/elements/1.1/">
+   <d:title><rdf:Alt><rdf:li xml:lang="x-default">Old title</rdf:li></rdf:Alt></d:title>
+   <d:format>application/pdf</d:format>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`)
+	info := Info{Title: "New <title>", Producer: "New producer", CreationDate: time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)}
+	updated, err := info.UpdateXMP(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(updated); strings.Contains(s, "Old") {
+		t.Fatalf("old properties should be removed:\n%s", s)
+	}
+	props, err := ParseXMP(updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if props[XMPProperty{nsPDFAID, "part"}] != "2" || props[XMPProperty{nsDC, "format"}] != "application/pdf" {
+		t.Fatalf("other properties should be preserved: %v", props)
+	}
+	if got := InfoFromXMP(props); got != info {
+		t.Fatalf("expected %v, got %v", info, got)
+	}
+
+	created, err := info.UpdateXMP(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	props, err = ParseXMP(created)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := InfoFromXMP(props); got != info {
+		t.Fatalf("expected %v, got %v", info, got)
+	}
+
+	if _, err = info.UpdateXMP([]byte("<x:xmpmeta></x:xmpmeta>")); err == nil {
+		t.Fatal("expected error for missing rdf:RDF")
+	}
+}
+
+func TestSyncMetadata(t *testing.T) {
+	xmp, err := Info{Title: "XMP title", Author: "XMP author"}.UpdateXMP(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metadata := &MetadataStream{Stream: NewCompressedStream(xmp)}
+	info, metadata, err := syncMetadata(Info{Title: "Info title"}, metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Title != "Info title" || info.Author != "XMP author" {
+		t.Fatalf("unexpected Info %v", info)
+	}
+	if len(metadata.Filter) != 0 {
+		t.Fatal("metadata should not be compressed")
+	}
+	props, err := ParseXMP(metadata.Content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := InfoFromXMP(props); got != info {
+		t.Fatalf("expected %v, got %v", info, got)
+	}
+}
@@ -0,0 +1,214 @@
+package optimize
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/benoitkugler/pdf/model"
+)
+
+// fontFile returns the embedded font program of `font`, or nil
+func fontFile(font *model.FontDict) *model.FontFile {
+	switch ft := font.Subtype.(type) {
+	case model.FontType1:
+		return ft.FontDescriptor.FontFile
+	case model.FontTrueType:
+		return ft.FontDescriptor.FontFile
+	case model.FontType0:
+		return ft.DescendantFonts.FontDescriptor.FontFile
+	case model.FontType3:
+		if ft.FontDescriptor != nil {
+			return ft.FontDescriptor.FontFile
+		}
+	}
+	return nil
+}
+
+// setFontFile updates the embedded font program of `font`,
+// which must have one
+func setFontFile(font *model.FontDict, file *model.FontFile) {
+	switch ft := font.Subtype.(type) {
+	case model.FontType1:
+		ft.FontDescriptor.FontFile = file
+		font.Subtype = ft
+	case model.FontTrueType:
+		ft.FontDescriptor.FontFile = file
+		font.Subtype = ft
+	case model.FontType0:
+		ft.DescendantFonts.FontDescriptor.FontFile = file
+		font.Subtype = ft
+	case model.FontType3:
+		ft.FontDescriptor.FontFile = file
+		font.Subtype = ft
+	}
+}
+
+// isTrueType returns true if the font program of `font` is
+// a TrueType font file (FontFile2)
+func isTrueType(font *model.FontDict) bool {
+	switch ft := font.Subtype.(type) {
+	case model.FontTrueType:
+		return ft.FontDescriptor.FontFile.Subtype == ""
+	case model.FontType0:
+		return ft.DescendantFonts.Subtype == "CIDFontType2" && ft.DescendantFonts.FontDescriptor.FontFile.Subtype == ""
+	}
+	return false
+}
+
+// subsetName returns the font name without the
+// subset tag (such as ABCDEF+)
+func subsetName(font *model.FontDict) string {
+	name := string(font.Subtype.FontName())
+	if i := strings.IndexByte(name, '+'); i == 6 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// mergeFontSubsets merges the TrueType subsets of the same font,
+// embedded in `fonts`, when they preserve the glyph IDs of the original font.
+// The fonts then share the merged font program.
+// It returns the number of font programs replaced.
+func mergeFontSubsets(fonts []*model.FontDict) int {
+	// group the font programs by font name
+	var (
+		names  []string
+		groups = make(map[string][]*model.FontFile)
+		users  = make(map[*model.FontFile][]*model.FontDict)
+	)
+	for _, font := range fonts {
+		file := fontFile(font)
+		if file == nil || !isTrueType(font) {
+			continue
+		}
+		name := subsetName(font)
+		if _, seen := users[file]; !seen {
+			if _, has := groups[name]; !has {
+				names = append(names, name)
+			}
+			groups[name] = append(groups[name], file)
+		}
+		users[file] = append(users[file], font)
+	}
+
+	replaced := 0
+	for _, name := range names {
+		files := groups[name]
+		for len(files) > 1 {
+			merged, err := files[0].Decode()
+			if err != nil {
+				break
+			}
+			var members, others []*model.FontFile
+			members = append(members, files[0])
+			for _, file := range files[1:] {
+				content, err := file.Decode()
+				if err != nil {
+					continue // not mergeable
+				}
+				if m, ok := mergeTrueType(merged, content); ok {
+					merged = m
+					members = append(members, file)
+				} else {
+					others = append(others, file)
+				}
+			}
+			if len(members) > 1 {
+				shared := &model.FontFile{Stream: model.NewCompressedStream(merged), Length1: len(merged)}
+				for _, file := range members {
+					for _, font := range users[file] {
+						setFontFile(font, shared)
+					}
+				}
+				replaced += len(members) - 1
+			}
+			files = others
+		}
+	}
+	return replaced
+}
+
+// fontCollector walks the resources to find the fonts
+type fontCollector struct {
+	fonts   []*model.FontDict
+	visited map[model.Referenceable]bool
+}
+
+// collectFonts returns the fonts used in the resources and
+// annotation appearances of the pages, in a deterministic order
+func collectFonts(doc *model.Document) []*model.FontDict {
+	fc := fontCollector{visited: make(map[model.Referenceable]bool)}
+	for _, page := range doc.Catalog.Pages.FlattenInherit() {
+		if page.Resources != nil {
+			fc.processResources(page.Resources)
+		}
+		for _, annot := range page.Annots {
+			if ap := annot.AP; ap != nil {
+				for _, entry := range [...]model.AppearanceEntry{ap.N, ap.R, ap.D} {
+					for _, name := range sortedKeys(entry) {
+						fc.processForm(entry[name])
+					}
+				}
+			}
+		}
+	}
+	return fc.fonts
+}
+
+func (fc *fontCollector) processForm(form *model.XObjectForm) {
+	if form == nil || fc.visited[form] {
+		return
+	}
+	fc.visited[form] = true
+	fc.processResources(&form.Resources)
+}
+
+func (fc *fontCollector) processResources(res *model.ResourcesDict) {
+	names := make([]model.Name, 0, len(res.Font))
+	for name := range res.Font {
+		names = append(names, name)
+	}
+	for _, name := range sortNames(names) {
+		font := res.Font[name]
+		if font == nil || fc.visited[font] {
+			continue
+		}
+		fc.visited[font] = true
+		fc.fonts = append(fc.fonts, font)
+		if type3, ok := font.Subtype.(model.FontType3); ok {
+			fc.processResources(&type3.Resources)
+		}
+	}
+	names = names[:0]
+	for name := range res.XObject {
+		names = append(names, name)
+	}
+	for _, name := range sortNames(names) {
+		if form, ok := res.XObject[name].(*model.XObjectForm); ok {
+			fc.processForm(form)
+		}
+	}
+	names = names[:0]
+	for name := range res.Pattern {
+		names = append(names, name)
+	}
+	for _, name := range sortNames(names) {
+		if pattern, ok := res.Pattern[name].(*model.PatternTiling); ok && pattern != nil && !fc.visited[pattern] {
+			fc.visited[pattern] = true
+			fc.processResources(&pattern.Resources)
+		}
+	}
+}
+
+func sortedKeys(entry model.AppearanceEntry) []model.Name {
+	names := make([]model.Name, 0, len(entry))
+	for name := range entry {
+		names = append(names, name)
+	}
+	return sortNames(names)
+}
+
+func sortNames(names []model.Name) []model.Name {
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
@@ -0,0 +1,134 @@
+package optimize
+
+import (
+	"testing"
+
+	"github.com/benoitkugler/pdf/model"
+	"golang.org/x/image/font/gofont/goregular"
+	xsfnt "golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// subset returns a subset of Go Regular, keeping the glyph IDs
+// but removing the glyphs not in `gids`
+func subset(t *testing.T, gids ...int) []byte {
+	f, err := parseSfnt(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	glyphs, err := f.glyphs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keep := map[int]bool{0: true}
+	for _, gid := range gids {
+		keep[gid] = true
+	}
+	for i := range glyphs {
+		if !keep[i] {
+			glyphs[i] = nil
+		}
+	}
+	f.setGlyphs(glyphs)
+	return f.write()
+}
+
+func glyphLength(t *testing.T, file []byte, gid int) int {
+	f, err := xsfnt.Parse(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	segments, err := f.LoadGlyph(nil, xsfnt.GlyphIndex(gid), fixed.I(1000), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(segments)
+}
+
+func TestMergeTrueType(t *testing.T) {
+	sub1, sub2 := subset(t, 40, 41), subset(t, 50)
+	if glyphLength(t, sub1, 40) == 0 || glyphLength(t, sub1, 50) != 0 {
+		t.Fatal("invalid subset")
+	}
+
+	merged, ok := mergeTrueType(sub1, sub2)
+	if !ok {
+		t.Fatal("expected compatible subsets")
+	}
+	for _, gid := range []int{40, 41, 50} {
+		if glyphLength(t, merged, gid) == 0 {
+			t.Fatalf("missing glyph %d", gid)
+		}
+	}
+	if glyphLength(t, merged, 60) != 0 {
+		t.Fatal("unexpected glyph")
+	}
+
+	// the subsets of other fonts are not merged
+	other, err := parseSfnt(subset(t, 50))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.tables["post"] = append([]byte(nil), other.tables["post"]...)
+	other.tables["post"][5]++
+	if _, ok := mergeTrueType(sub1, other.write()); ok {
+		t.Fatal("expected incompatible fonts")
+	}
+	if _, ok := mergeTrueType(sub1, []byte("invalid")); ok {
+		t.Fatal("expected invalid font")
+	}
+}
+
+func trueTypeFont(name string, file []byte, widths []int) *model.FontDict {
+	return &model.FontDict{Subtype: model.FontTrueType{
+		BaseFont:  model.Name(name),
+		FirstChar: 32,
+		Widths:    widths,
+		FontDescriptor: model.FontDescriptor{
+			FontName: model.Name(name),
+			FontFile: &model.FontFile{Stream: model.NewCompressedStream(file), Length1: len(file)},
+		},
+	}}
+}
+
+func TestOptimizeFonts(t *testing.T) {
+	sub1, sub2 := subset(t, 40), subset(t, 50)
+	f1 := trueTypeFont("AAAAAA+GoRegular", sub1, []int{500})
+	f2 := trueTypeFont("BBBBBB+GoRegular", sub2, []int{600})
+	f3 := trueTypeFont("CCCCCC+GoRegular", sub1, []int{500, 600}) // same font program as f1
+	page := newPage("BT /F1 12 Tf (a) Tj /F2 12 Tf (b) Tj /F3 12 Tf (c) Tj ET", &model.ResourcesDict{
+		Font: map[model.Name]*model.FontDict{"F1": f1, "F2": f2, "F3": f3},
+	})
+	var doc model.Document
+	doc.Catalog.Pages.Kids = []model.PageNode{page}
+
+	report, err := Optimize(&doc, Options{Deduplicate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.SharedObjects != 1 || fontFile(f1) != fontFile(f3) || fontFile(f1) == fontFile(f2) {
+		t.Fatalf("expected shared font file, got %v", report)
+	}
+
+	report, err = Optimize(&doc, All)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.MergedFonts != 1 || report.Saved() <= 0 {
+		t.Fatalf("unexpected report %v", report)
+	}
+	file := fontFile(f1)
+	if fontFile(f2) != file || fontFile(f3) != file {
+		t.Fatal("expected merged font file")
+	}
+	content, err := file.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if glyphLength(t, content, 40) == 0 || glyphLength(t, content, 50) == 0 {
+		t.Fatal("missing glyphs in merged font")
+	}
+	if f2.Subtype.(model.FontTrueType).Widths[0] != 600 {
+		t.Fatal("font dictionaries should be preserved")
+	}
+}
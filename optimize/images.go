@@ -0,0 +1,294 @@
+package optimize
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"math"
+
+	cs "github.com/benoitkugler/pdf/contentstream"
+	"github.com/benoitkugler/pdf/model"
+	"github.com/benoitkugler/pdf/reader/parser"
+	xdraw "golang.org/x/image/draw"
+)
+
+type Fl = model.Fl
+
+const (
+	maxFormDepth = 32
+	jpegQuality  = 85 // used when re-encoding the downsampled JPEG images
+)
+
+var identity = model.Matrix{1, 0, 0, 1, 0, 0}
+
+// displaySizes stores the maximum size (in points) at which
+// the images are painted in the pages
+type displaySizes struct {
+	sizes map[*model.XObjectImage][2]Fl
+	// images which may be painted with an unknown size
+	// (such as in patterns or annotations), which are not downsampled
+	excluded map[*model.XObjectImage]bool
+	visited  map[*model.ResourcesDict]bool // for the exclusion
+}
+
+// analyzeDisplaySizes walks the content of the pages, and the forms they use,
+// to measure the images
+func analyzeDisplaySizes(doc *model.Document) (displaySizes, error) {
+	ds := displaySizes{
+		sizes:    make(map[*model.XObjectImage][2]Fl),
+		excluded: make(map[*model.XObjectImage]bool),
+		visited:  make(map[*model.ResourcesDict]bool),
+	}
+	pages := doc.Catalog.Pages.Flatten()
+	inherited := doc.Catalog.Pages.FlattenInherit()
+	for index, page := range pages {
+		res := inherited[index].Resources
+		if res == nil {
+			res = &model.ResourcesDict{}
+		}
+		content, err := page.DecodeAllContents()
+		if err != nil {
+			return ds, err
+		}
+		if err := ds.measure(content, res, identity, 0); err != nil {
+			return ds, err
+		}
+		for _, annot := range page.Annots {
+			if ap := annot.AP; ap != nil {
+				for _, entry := range [...]model.AppearanceEntry{ap.N, ap.R, ap.D} {
+					for _, form := range entry {
+						if form != nil {
+							ds.exclude(&form.Resources)
+						}
+					}
+				}
+			}
+		}
+	}
+	return ds, nil
+}
+
+// measure records the size of the images painted by `content`
+func (ds displaySizes) measure(content []byte, res *model.ResourcesDict, ctm model.Matrix, depth int) error {
+	if depth > maxFormDepth {
+		return nil
+	}
+	// the size of the images in patterns and Type3 glyphs are not tracked
+	for _, pattern := range res.Pattern {
+		if tiling, ok := pattern.(*model.PatternTiling); ok && tiling != nil {
+			ds.exclude(&tiling.Resources)
+		}
+	}
+	for _, font := range res.Font {
+		if font == nil {
+			continue
+		}
+		if type3, ok := font.Subtype.(model.FontType3); ok {
+			ds.exclude(&type3.Resources)
+		}
+	}
+
+	ops, err := parser.ParseContent(content, res.ColorSpace)
+	if err != nil {
+		return err
+	}
+	var stack []model.Matrix
+	for _, op := range ops {
+		switch op := op.(type) {
+		case cs.OpSave:
+			stack = append(stack, ctm)
+		case cs.OpRestore:
+			if len(stack) != 0 {
+				ctm = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+		case cs.OpConcat:
+			ctm = op.Matrix.Multiply(ctm)
+		case cs.OpXObject:
+			switch xObject := res.XObject[model.Name(op.XObject)].(type) {
+			case *model.XObjectImage:
+				if xObject != nil {
+					ds.record(xObject, ctm)
+				}
+			case *model.XObjectForm:
+				if xObject == nil {
+					continue
+				}
+				formContent, err := xObject.Decode()
+				if err != nil {
+					return err
+				}
+				matrix := xObject.Matrix
+				if matrix == (model.Matrix{}) {
+					matrix = identity
+				}
+				if err := ds.measure(formContent, ownResources(&xObject.Resources, res), matrix.Multiply(ctm), depth+1); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// record the size of the unit square mapped by `ctm`
+func (ds displaySizes) record(img *model.XObjectImage, ctm model.Matrix) {
+	width := Fl(math.Hypot(float64(ctm[0]), float64(ctm[1])))
+	height := Fl(math.Hypot(float64(ctm[2]), float64(ctm[3])))
+	if width == 0 || height == 0 {
+		ds.excluded[img] = true
+		return
+	}
+	size := ds.sizes[img]
+	if width > size[0] {
+		size[0] = width
+	}
+	if height > size[1] {
+		size[1] = height
+	}
+	ds.sizes[img] = size
+}
+
+// exclude marks the images of `res` (and its forms) as not downsampled
+func (ds displaySizes) exclude(res *model.ResourcesDict) {
+	if ds.visited[res] {
+		return
+	}
+	ds.visited[res] = true
+	for _, xObject := range res.XObject {
+		switch xObject := xObject.(type) {
+		case *model.XObjectImage:
+			ds.excluded[xObject] = true
+		case *model.XObjectForm:
+			if xObject != nil {
+				ds.exclude(&xObject.Resources)
+			}
+		}
+	}
+}
+
+// downsampleImages reduces the resolution of the images
+// displayed with more than `maxDPI`, returning the number of
+// downsampled images
+func (ds displaySizes) downsampleImages(maxDPI Fl) int {
+	count := 0
+	for img, size := range ds.sizes {
+		if ds.excluded[img] {
+			continue
+		}
+		// the required number of samples, at `maxDPI`
+		width := int(math.Ceil(float64(size[0] * maxDPI / 72)))
+		height := int(math.Ceil(float64(size[1] * maxDPI / 72)))
+		if width >= img.Width && height >= img.Height {
+			continue
+		}
+		if width > img.Width {
+			width = img.Width
+		}
+		if height > img.Height {
+			height = img.Height
+		}
+		if downsample(img, width, height) {
+			count++
+		}
+	}
+	return count
+}
+
+// downsample resizes `img` to `width` x `height` samples, returning false
+// if the image is not supported
+func downsample(img *model.XObjectImage, width, height int) bool {
+	if img.ImageMask || img.Mask != nil || img.ColorSpace == nil {
+		return false
+	}
+	if _, isIndexed := img.ColorSpace.(model.ColorSpaceIndexed); isIndexed {
+		return false
+	}
+	nbComps := img.ColorSpace.NbColorComponents()
+	if isJPEG(img.Filter) {
+		if nbComps != 1 && nbComps != 3 {
+			return false
+		}
+		content, ok := downsampleJPEG(img.Content, width, height)
+		if !ok {
+			return false
+		}
+		img.Content = content
+	} else {
+		if img.BitsPerComponent != 8 || !isLossless(img.Filter) {
+			return false
+		}
+		samples, err := img.Stream.Decode()
+		if err != nil || len(samples) < img.Width*img.Height*nbComps {
+			return false
+		}
+		img.Stream = compress(resample(samples, img.Width, img.Height, nbComps, width, height))
+	}
+
+	// an alpha mask with the same resolution is also resized
+	if sm := img.SMask; sm != nil && sm.Width == img.Width && sm.Height == img.Height &&
+		sm.BitsPerComponent == 8 && isLossless(sm.Filter) {
+		if alpha, err := sm.Stream.Decode(); err == nil && len(alpha) >= sm.Width*sm.Height {
+			sm.Stream = compress(resample(alpha, sm.Width, sm.Height, 1, width, height))
+			sm.Width, sm.Height = width, height
+		}
+	}
+	img.Width, img.Height = width, height
+	img.Alternates = nil // the alternates have the original resolution
+	return true
+}
+
+func isJPEG(filters model.Filters) bool {
+	return len(filters) == 1 && filters[0].Name == model.DCT
+}
+
+// resample averages the samples of the source pixels covered by each destination pixel
+func resample(samples []byte, width, height, nbComps, newWidth, newHeight int) []byte {
+	out := make([]byte, 0, newWidth*newHeight*nbComps)
+	sums := make([]int, nbComps)
+	for y := 0; y < newHeight; y++ {
+		y0, y1 := y*height/newHeight, (y+1)*height/newHeight
+		for x := 0; x < newWidth; x++ {
+			x0, x1 := x*width/newWidth, (x+1)*width/newWidth
+			for i := range sums {
+				sums[i] = 0
+			}
+			for sy := y0; sy < y1; sy++ {
+				row := samples[sy*width*nbComps:]
+				for sx := x0; sx < x1; sx++ {
+					for i := range sums {
+						sums[i] += int(row[sx*nbComps+i])
+					}
+				}
+			}
+			n := (y1 - y0) * (x1 - x0)
+			for _, sum := range sums {
+				out = append(out, byte((sum+n/2)/n))
+			}
+		}
+	}
+	return out
+}
+
+// downsampleJPEG decodes, resizes and re-encodes a JPEG image
+func downsampleJPEG(content []byte, width, height int) ([]byte, bool) {
+	src, err := jpeg.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, false
+	}
+	var dst xdraw.Image
+	switch src.(type) {
+	case *image.Gray:
+		dst = image.NewGray(image.Rect(0, 0, width, height))
+	case *image.YCbCr:
+		dst = image.NewRGBA(image.Rect(0, 0, width, height))
+	default: // such as CMYK images, which would be converted to RGB
+		return nil, false
+	}
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), xdraw.Src, nil)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
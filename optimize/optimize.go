@@ -0,0 +1,580 @@
+// Package optimize reduces the size of a document, by removing
+// the resources not used by the content streams, sharing the
+// identical objects (which is useful after merging or flattening documents),
+// recompressing the streams and downsampling the images.
+//
+// The document is modified in place.
+package optimize
+
+import (
+	"fmt"
+
+	cs "github.com/benoitkugler/pdf/contentstream"
+	"github.com/benoitkugler/pdf/model"
+	"github.com/benoitkugler/pdf/reader/parser"
+)
+
+// Options selects the optimizations performed by Optimize.
+type Options struct {
+	// RemoveUnused removes, from the resources of the pages,
+	// forms, patterns and Type3 fonts, the entries which are not
+	// referenced by the associated content stream.
+	RemoveUnused bool
+	// Deduplicate replaces the identical images, forms, fonts,
+	// patterns, shadings and graphic states by one of them,
+	// so that they are written only once.
+	// The identical embedded font programs are also shared
+	// between fonts with different dictionaries.
+	Deduplicate bool
+	// MergeFontSubsets merges the TrueType subsets of the same font
+	// into one font program, shared by the fonts.
+	// Only the subsets preserving the glyph IDs of the original font
+	// (and differing only by their glyphs) are merged.
+	MergeFontSubsets bool
+
+	// Recompress compresses the uncompressed streams, and
+	// recompresses the Flate streams with the best compression level.
+	// The streams using image filters (such as JPEG) or predictors are not modified.
+	Recompress bool
+	// MaxImageDPI, if positive, downsamples the images painted in
+	// the pages with a higher resolution.
+	// Only the 8-bit images, compressed with general purpose filters or
+	// JPEG (for gray and RGB images) are supported, and the images also used in
+	// patterns, Type3 fonts or annotation appearances are not modified.
+	MaxImageDPI Fl
+	// RemoveAlternates removes the alternate images and
+	// the page thumbnails.
+	RemoveAlternates bool
+}
+
+// All enables all the optimizations, but the image downsampling.
+var All = Options{RemoveUnused: true, Deduplicate: true, MergeFontSubsets: true, Recompress: true, RemoveAlternates: true}
+
+// Report describes the changes performed by Optimize.
+type Report struct {
+	// RemovedResources is the number of entries removed
+	// from the resource dictionaries.
+	RemovedResources int
+	// SharedObjects is the number of objects replaced by an identical one.
+	SharedObjects int
+	// MergedFonts is the number of font programs
+	// removed by merging font subsets.
+	MergedFonts int
+	// RecompressedStreams is the number of streams compressed
+	// with a better level.
+	RecompressedStreams int
+	// DownsampledImages is the number of images with a reduced resolution.
+	DownsampledImages int
+	// RemovedAlternates is the number of alternate images
+	// and thumbnails removed.
+	RemovedAlternates int
+
+	// SizeBefore and SizeAfter are the size of the written
+	// document (without encryption), in bytes.
+	SizeBefore, SizeAfter int
+}
+
+// Saved returns the number of bytes saved by the optimizations.
+func (r Report) Saved() int { return r.SizeBefore - r.SizeAfter }
+
+// Optimize modifies `doc` in place, applying the optimizations selected by `options`.
+// The document is written (in memory) to measure the size savings.
+// An error is only returned if a content stream is invalid, in which
+// case the document is not modified.
+func Optimize(doc *model.Document, options Options) (Report, error) {
+	var (
+		report Report
+		err    error
+	)
+	report.SizeBefore, err = writtenSize(doc)
+	if err != nil {
+		return report, err
+	}
+
+	// analyze the content before modifying the document
+	var (
+		usage Usage
+		sizes displaySizes
+	)
+	if options.RemoveUnused {
+		usage, err = AnalyzeUsage(doc)
+		if err != nil {
+			return report, err
+		}
+	}
+	if options.MaxImageDPI > 0 {
+		sizes, err = analyzeDisplaySizes(doc)
+		if err != nil {
+			return report, err
+		}
+	}
+
+	if options.RemoveUnused {
+		report.RemovedResources = usage.removeUnused()
+	}
+	if options.RemoveAlternates {
+		report.RemovedAlternates = removeAlternates(doc)
+	}
+	if options.MaxImageDPI > 0 {
+		report.DownsampledImages = sizes.downsampleImages(options.MaxImageDPI)
+	}
+	// recompress first, so that identical content is compressed identically
+	if options.Recompress {
+		report.RecompressedStreams = recompressStreams(doc)
+	}
+	if options.Deduplicate {
+		report.SharedObjects = deduplicate(doc)
+	}
+	if options.MergeFontSubsets {
+		report.MergedFonts = mergeFontSubsets(collectFonts(doc))
+	}
+
+	report.SizeAfter, err = writtenSize(doc)
+	return report, err
+}
+
+type counter int
+
+func (c *counter) Write(p []byte) (int, error) {
+	*c += counter(len(p))
+	return len(p), nil
+}
+
+func writtenSize(doc *model.Document) (int, error) {
+	var c counter
+	err := doc.Write(&c, nil)
+	return int(c), err
+}
+
+// ------------------------- usage analysis -------------------------
+
+// Names stores the resource names used by content streams,
+// by category.
+type Names struct {
+	ExtGState  map[model.Name]bool
+	ColorSpace map[model.Name]bool
+	Shading    map[model.Name]bool
+	Pattern    map[model.Name]bool
+	Font       map[model.Name]bool
+	XObject    map[model.Name]bool
+	Properties map[model.Name]bool // property lists and optional contents
+}
+
+func newNames() *Names {
+	return &Names{
+		ExtGState:  make(map[model.Name]bool),
+		ColorSpace: make(map[model.Name]bool),
+		Shading:    make(map[model.Name]bool),
+		Pattern:    make(map[model.Name]bool),
+		Font:       make(map[model.Name]bool),
+		XObject:    make(map[model.Name]bool),
+		Properties: make(map[model.Name]bool),
+	}
+}
+
+// Usage maps the resource dictionaries of a document
+// to the names actually used by the content streams.
+// Only the resources reachable from the pages (and their annotations) are included.
+type Usage map[*model.ResourcesDict]*Names
+
+// Unused returns the number of resources entries not used.
+func (u Usage) Unused() int {
+	count := 0
+	for res, names := range u {
+		count += prune(res, names, false)
+	}
+	return count
+}
+
+func (u Usage) removeUnused() int {
+	count := 0
+	for res, names := range u {
+		count += prune(res, names, true)
+	}
+	return count
+}
+
+// prune counts, and removes if `remove` is true, the entries of `res` not used
+func prune(res *model.ResourcesDict, names *Names, remove bool) int {
+	count := 0
+	for n := range res.ExtGState {
+		if !names.ExtGState[n] {
+			count++
+			if remove {
+				delete(res.ExtGState, n)
+			}
+		}
+	}
+	for n := range res.ColorSpace {
+		switch n {
+		case "DefaultGray", "DefaultRGB", "DefaultCMYK": // implicitly used
+			continue
+		}
+		if !names.ColorSpace[model.Name(n)] {
+			count++
+			if remove {
+				delete(res.ColorSpace, n)
+			}
+		}
+	}
+	for n := range res.Shading {
+		if !names.Shading[n] {
+			count++
+			if remove {
+				delete(res.Shading, n)
+			}
+		}
+	}
+	for n := range res.Pattern {
+		if !names.Pattern[n] {
+			count++
+			if remove {
+				delete(res.Pattern, n)
+			}
+		}
+	}
+	for n := range res.Font {
+		if !names.Font[n] {
+			count++
+			if remove {
+				delete(res.Font, n)
+			}
+		}
+	}
+	for n := range res.XObject {
+		if !names.XObject[n] {
+			count++
+			if remove {
+				delete(res.XObject, n)
+			}
+		}
+	}
+	for n := range res.Properties {
+		if !names.Properties[n] {
+			count++
+			if remove {
+				delete(res.Properties, n)
+			}
+		}
+	}
+	for n := range res.OptionalContents {
+		if !names.Properties[n] {
+			count++
+			if remove {
+				delete(res.OptionalContents, n)
+			}
+		}
+	}
+	return count
+}
+
+// analyzer walks the content streams, following
+// the forms, patterns and Type3 fonts actually used
+type analyzer struct {
+	usage Usage
+	doc   *model.Document // used to decode the streams with its cache
+
+	// visited stores the forms, tiling patterns and Type3 fonts already
+	// scanned, with the resources they were resolved against : an item
+	// without resources of its own records its names into the resources
+	// of each content using it, and must be scanned once per such content
+	visited map[scanKey]bool
+}
+
+// scanKey identifies an item scanned with the given resources
+type scanKey struct {
+	item      interface{}
+	resources *model.ResourcesDict
+}
+
+// markVisited returns true if `item`, with resources `own`, was already
+// scanned in content using `parent`, and records it otherwise
+func (an analyzer) markVisited(item interface{}, own, parent *model.ResourcesDict) bool {
+	key := scanKey{item: item}
+	if own.IsEmpty() {
+		key.resources = parent
+	}
+	if an.visited[key] {
+		return true
+	}
+	an.visited[key] = true
+	return false
+}
+
+// AnalyzeUsage scans the content streams of the pages, the annotation
+// appearances, and the forms, patterns and Type3 fonts they use,
+// and returns the resource names actually used.
+func AnalyzeUsage(doc *model.Document) (Usage, error) {
+	an := analyzer{
+		usage:   make(Usage),
+		doc:     doc,
+		visited: make(map[scanKey]bool),
+	}
+	pages := doc.Catalog.Pages.Flatten()
+	inherited := doc.Catalog.Pages.FlattenInherit()
+	for index, page := range pages {
+		res := inherited[index].Resources
+		if res == nil {
+			res = &model.ResourcesDict{}
+		}
+		content, err := page.DecodeAllContents()
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %s", index, err)
+		}
+		if err := an.scan(content, res); err != nil {
+			return nil, fmt.Errorf("page %d: %s", index, err)
+		}
+		for _, annot := range page.Annots {
+			ap := annot.AP
+			if ap == nil {
+				continue
+			}
+			for _, entry := range [...]model.AppearanceEntry{ap.N, ap.R, ap.D} {
+				for _, form := range entry {
+					if err := an.scanForm(form, res); err != nil {
+						return nil, fmt.Errorf("page %d: annotation appearance: %s", index, err)
+					}
+				}
+			}
+		}
+	}
+	return an.usage, nil
+}
+
+func (an analyzer) names(res *model.ResourcesDict) *Names {
+	names := an.usage[res]
+	if names == nil {
+		names = newNames()
+		an.usage[res] = names
+	}
+	return names
+}
+
+// scan records the names used by `content`, and the content
+// of the forms, patterns and Type3 fonts it references
+func (an analyzer) scan(content []byte, res *model.ResourcesDict) error {
+	ops, err := parser.ParseContent(content, res.ColorSpace)
+	if err != nil {
+		return err
+	}
+	names := an.names(res)
+	for _, op := range ops {
+		switch op := op.(type) {
+		case cs.OpSetExtGState:
+			names.ExtGState[model.Name(op.Dict)] = true
+		case cs.OpSetFillColorSpace:
+			names.ColorSpace[model.Name(op.ColorSpace)] = true
+		case cs.OpSetStrokeColorSpace:
+			names.ColorSpace[model.Name(op.ColorSpace)] = true
+		case cs.OpBeginImage:
+			if space, ok := op.ColorSpace.(cs.ImageColorSpaceName); ok {
+				names.ColorSpace[model.Name(space.ColorSpaceName)] = true
+			}
+		case cs.OpShFill:
+			names.Shading[model.Name(op.Shading)] = true
+		case cs.OpSetFillColorN:
+			if err := an.usePattern(names, model.Name(op.Pattern), res); err != nil {
+				return err
+			}
+		case cs.OpSetStrokeColorN:
+			if err := an.usePattern(names, model.Name(op.Pattern), res); err != nil {
+				return err
+			}
+		case cs.OpSetFont:
+			if err := an.useFont(names, model.Name(op.Font), res); err != nil {
+				return err
+			}
+		case cs.OpXObject:
+			names.XObject[model.Name(op.XObject)] = true
+			if form, ok := res.XObject[model.Name(op.XObject)].(*model.XObjectForm); ok {
+				if err := an.scanForm(form, res); err != nil {
+					return fmt.Errorf("XObject %s: %s", op.XObject, err)
+				}
+			}
+		case cs.OpBeginMarkedContent:
+			if name, ok := op.Properties.(cs.PropertyListName); ok {
+				names.Properties[model.Name(name)] = true
+			}
+		case cs.OpMarkPoint:
+			if name, ok := op.Properties.(cs.PropertyListName); ok {
+				names.Properties[model.Name(name)] = true
+			}
+		}
+	}
+	return nil
+}
+
+func (an analyzer) usePattern(names *Names, name model.Name, res *model.ResourcesDict) error {
+	if name == "" {
+		return nil
+	}
+	names.Pattern[name] = true
+	pattern, ok := res.Pattern[name].(*model.PatternTiling)
+	if !ok || pattern == nil {
+		return nil
+	}
+	patternRes := ownResources(&pattern.Resources, res)
+	if an.markVisited(pattern, &pattern.Resources, res) {
+		return nil
+	}
+	content, err := an.doc.DecodeStream(pattern.Stream)
+	if err != nil {
+		return fmt.Errorf("pattern %s: %s", name, err)
+	}
+	if err := an.scan(content, patternRes); err != nil {
+		return fmt.Errorf("pattern %s: %s", name, err)
+	}
+	return nil
+}
+
+func (an analyzer) useFont(names *Names, name model.Name, res *model.ResourcesDict) error {
+	names.Font[name] = true
+	font := res.Font[name]
+	if font == nil {
+		return nil
+	}
+	type3, ok := font.Subtype.(model.FontType3)
+	if !ok {
+		return nil
+	}
+	// the maps are shared with the font, so that pruning
+	// the copy also prunes the font resources
+	fontRes := ownResources(&type3.Resources, res)
+	if an.markVisited(font, &type3.Resources, res) {
+		return nil
+	}
+	for glyph, proc := range type3.CharProcs {
+		content, err := an.doc.DecodeStream(proc.Stream)
+		if err != nil {
+			return fmt.Errorf("font %s: glyph %s: %s", name, glyph, err)
+		}
+		if err := an.scan(content, fontRes); err != nil {
+			return fmt.Errorf("font %s: glyph %s: %s", name, glyph, err)
+		}
+	}
+	return nil
+}
+
+// scanForm records the names used by `form`, which
+// is used in content using `parent` resources
+func (an analyzer) scanForm(form *model.XObjectForm, parent *model.ResourcesDict) error {
+	if form == nil {
+		return nil
+	}
+	res := ownResources(&form.Resources, parent)
+	if an.markVisited(form, &form.Resources, parent) {
+		return nil
+	}
+	content, err := an.doc.DecodeStream(form.Stream)
+	if err != nil {
+		return err
+	}
+	return an.scan(content, res)
+}
+
+// ownResources returns `res`, or `parent` if `res` is empty,
+// since forms, patterns and Type3 glyphs without resources
+// use the resources of the content in which they are painted
+func ownResources(res, parent *model.ResourcesDict) *model.ResourcesDict {
+	if res.IsEmpty() {
+		return parent
+	}
+	return res
+}
+
+// ------------------------- deduplication -------------------------
+
+// deduplicator replaces the objects by the first
+// object found with the same fingerprint
+type deduplicator struct {
+	canonical map[[32]byte]model.Referenceable
+	visited   map[model.Referenceable]bool
+	replaced  map[model.Referenceable]bool
+}
+
+// deduplicate shares the identical objects used in the resources
+// and annotation appearances of the pages, returning the number of
+// objects replaced
+func deduplicate(doc *model.Document) int {
+	d := deduplicator{
+		canonical: make(map[[32]byte]model.Referenceable),
+		visited:   make(map[model.Referenceable]bool),
+		replaced:  make(map[model.Referenceable]bool),
+	}
+	for _, page := range doc.Catalog.Pages.FlattenInherit() {
+		if page.Resources != nil {
+			d.processResources(page.Resources)
+		}
+		for _, annot := range page.Annots {
+			if ap := annot.AP; ap != nil {
+				for _, entry := range [...]model.AppearanceEntry{ap.N, ap.R, ap.D} {
+					for name, form := range entry {
+						if form != nil {
+							entry[name] = d.share(form).(*model.XObjectForm)
+						}
+					}
+				}
+			}
+		}
+	}
+	return len(d.replaced)
+}
+
+// share processes the resources of `item` and returns
+// the canonical object identical to `item`
+func (d deduplicator) share(item model.Referenceable) model.Referenceable {
+	if !d.visited[item] {
+		d.visited[item] = true
+		switch item := item.(type) {
+		case *model.XObjectForm:
+			d.processResources(&item.Resources)
+		case *model.PatternTiling:
+			d.processResources(&item.Resources)
+		case *model.FontDict:
+			if type3, ok := item.Subtype.(model.FontType3); ok {
+				d.processResources(&type3.Resources) // the maps are shared with the font
+			}
+			if file := fontFile(item); file != nil {
+				setFontFile(item, d.share(file).(*model.FontFile))
+			}
+		}
+	}
+	key := model.Fingerprint(item)
+	canonical, has := d.canonical[key]
+	if !has {
+		d.canonical[key] = item
+		return item
+	}
+	if canonical != item {
+		d.replaced[item] = true
+	}
+	return canonical
+}
+
+func (d deduplicator) processResources(res *model.ResourcesDict) {
+	for n, item := range res.ExtGState {
+		if item != nil {
+			res.ExtGState[n] = d.share(item).(*model.GraphicState)
+		}
+	}
+	for n, item := range res.Shading {
+		if item != nil {
+			res.Shading[n] = d.share(item).(*model.ShadingDict)
+		}
+	}
+	for n, item := range res.Pattern {
+		if item != nil {
+			res.Pattern[n] = d.share(item).(model.Pattern)
+		}
+	}
+	for n, item := range res.Font {
+		if item != nil {
+			res.Font[n] = d.share(item).(*model.FontDict)
+		}
+	}
+	for n, item := range res.XObject {
+		if item != nil {
+			res.XObject[n] = d.share(item).(model.XObject)
+		}
+	}
+}
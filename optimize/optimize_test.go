@@ -0,0 +1,229 @@
+package optimize
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"testing"
+
+	"github.com/benoitkugler/pdf/model"
+)
+
+func newImage() *model.XObjectImage {
+	return &model.XObjectImage{
+		Image: model.Image{
+			Stream:           model.Stream{Content: bytes.Repeat([]byte{0xff}, 300)},
+			BitsPerComponent: 8, Width: 10, Height: 10,
+		},
+		ColorSpace: model.ColorSpaceRGB,
+	}
+}
+
+func newPage(content string, res *model.ResourcesDict) *model.PageObject {
+	return &model.PageObject{
+		MediaBox:  &model.Rectangle{Urx: 100, Ury: 100},
+		Resources: res,
+		Contents:  []model.ContentStream{{Stream: model.Stream{Content: []byte(content)}}},
+	}
+}
+
+func testDocument() (model.Document, *model.XObjectForm) {
+	helvetica := &model.FontDict{Subtype: model.FontType1{BaseFont: "Helvetica"}}
+	courier := &model.FontDict{Subtype: model.FontType1{BaseFont: "Courier"}}
+	form := &model.XObjectForm{
+		ContentStream: model.ContentStream{Stream: model.Stream{Content: []byte("/Im1 Do")}},
+		BBox:          model.Rectangle{Urx: 10, Ury: 10},
+		Resources: model.ResourcesDict{
+			XObject: map[model.Name]model.XObject{"Im1": newImage()},
+			Font:    map[model.Name]*model.FontDict{"F1": courier}, // unused
+		},
+	}
+	page1 := newPage("BT /F1 12 Tf (Hello) Tj ET /Im1 Do /Fm1 Do", &model.ResourcesDict{
+		Font:      map[model.Name]*model.FontDict{"F1": helvetica, "F2": courier}, // F2 is unused
+		XObject:   map[model.Name]model.XObject{"Im1": newImage(), "Fm1": form},
+		ExtGState: map[model.Name]*model.GraphicState{"GS1": {CA: model.ObjFloat(0.5)}}, // unused
+	})
+	page2 := newPage("/Im2 Do", &model.ResourcesDict{
+		XObject: map[model.Name]model.XObject{"Im2": newImage()},
+	})
+	var doc model.Document
+	doc.Catalog.Pages.Kids = []model.PageNode{page1, page2}
+	return doc, form
+}
+
+func TestAnalyzeUsage(t *testing.T) {
+	doc, form := testDocument()
+	usage, err := AnalyzeUsage(&doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(usage) != 3 {
+		t.Fatalf("expected 3 resources, got %d", len(usage))
+	}
+	names := usage[&form.Resources]
+	if !names.XObject["Im1"] || names.Font["F1"] {
+		t.Fatalf("unexpected form usage %v", names)
+	}
+	if u := usage.Unused(); u != 3 {
+		t.Fatalf("expected 3 unused resources, got %d", u)
+	}
+}
+
+func TestOptimize(t *testing.T) {
+	doc, form := testDocument()
+	report, err := Optimize(&doc, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.RemovedResources != 0 || report.SharedObjects != 0 || report.Saved() != 0 {
+		t.Fatalf("unexpected report %v", report)
+	}
+
+	report, err = Optimize(&doc, All)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.RemovedResources != 3 {
+		t.Fatalf("expected 3 removed resources, got %d", report.RemovedResources)
+	}
+	if report.SharedObjects != 2 { // the three images are identical
+		t.Fatalf("expected 2 shared objects, got %d", report.SharedObjects)
+	}
+	if report.Saved() <= 0 {
+		t.Fatalf("expected size savings, got %v", report)
+	}
+
+	pages := doc.Catalog.Pages.Flatten()
+	res1, res2 := pages[0].Resources, pages[1].Resources
+	if len(res1.Font) != 1 || len(res1.ExtGState) != 0 || len(form.Resources.Font) != 0 {
+		t.Fatal("unused resources not removed")
+	}
+	if res1.XObject["Im1"] != res2.XObject["Im2"] || form.Resources.XObject["Im1"] != res2.XObject["Im2"] {
+		t.Fatal("images not shared")
+	}
+
+	var b bytes.Buffer
+	if err := doc.Write(&b, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInvalidContent(t *testing.T) {
+	var doc model.Document
+	res := &model.ResourcesDict{Font: map[model.Name]*model.FontDict{"F1": {Subtype: model.FontType1{BaseFont: "Helvetica"}}}}
+	doc.Catalog.Pages.Kids = []model.PageNode{newPage("BT /F1 12 Tf (Hello Tj ET", res)}
+	if _, err := Optimize(&doc, All); err == nil {
+		t.Fatal("expected error on invalid content")
+	}
+	if len(res.Font) != 1 {
+		t.Fatal("document should not be modified")
+	}
+}
+
+func TestSharedFormWithoutResources(t *testing.T) {
+	// the form uses the resources of each page painting it
+	form := &model.XObjectForm{
+		ContentStream: model.ContentStream{Stream: model.Stream{Content: []byte("BT /F1 12 Tf (Hello) Tj ET")}},
+		BBox:          model.Rectangle{Urx: 10, Ury: 10},
+	}
+	newRes := func() *model.ResourcesDict {
+		return &model.ResourcesDict{
+			Font:    map[model.Name]*model.FontDict{"F1": {Subtype: model.FontType1{BaseFont: "Helvetica"}}},
+			XObject: map[model.Name]model.XObject{"Fm1": form},
+		}
+	}
+	res1, res2 := newRes(), newRes()
+	var doc model.Document
+	doc.Catalog.Pages.Kids = []model.PageNode{newPage("/Fm1 Do", res1), newPage("/Fm1 Do", res2)}
+
+	report, err := Optimize(&doc, Options{RemoveUnused: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.RemovedResources != 0 {
+		t.Fatalf("expected no removed resources, got %d", report.RemovedResources)
+	}
+	if res1.Font["F1"] == nil || res2.Font["F1"] == nil {
+		t.Fatal("font used by the form should not be removed")
+	}
+}
+
+func jpegImage(t *testing.T, width, height int) *model.XObjectImage {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i)
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return &model.XObjectImage{
+		Image: model.Image{
+			Stream:           model.Stream{Content: buf.Bytes(), Filter: model.Filters{{Name: model.DCT}}},
+			BitsPerComponent: 8, Width: width, Height: height,
+		},
+		ColorSpace: model.ColorSpaceGray,
+	}
+}
+
+func TestImagesAndStreams(t *testing.T) {
+	raw := newImage() // 10 x 10
+	raw.SMask = &model.ImageSMask{Image: model.Image{
+		Stream:           model.Stream{Content: bytes.Repeat([]byte{0x80}, 100)},
+		BitsPerComponent: 8, Width: 10, Height: 10,
+	}}
+	raw.Alternates = []model.AlternateImage{{Image: newImage()}}
+	jpg := jpegImage(t, 200, 100)
+	annotImage := newImage()
+	page := newPage("q 5 0 0 4 0 0 cm /Im1 Do Q q 50 0 0 25 0 0 cm /Im2 Do Q /Im3 Do", &model.ResourcesDict{
+		XObject: map[model.Name]model.XObject{"Im1": raw, "Im2": jpg, "Im3": annotImage},
+	})
+	page.Thumb = newImage()
+	page.Annots = []*model.AnnotationDict{{BaseAnnotation: model.BaseAnnotation{AP: &model.AppearanceDict{N: model.AppearanceEntry{"": {
+		ContentStream: model.ContentStream{Stream: model.Stream{Content: []byte("/Im1 Do")}},
+		Resources:     model.ResourcesDict{XObject: map[model.Name]model.XObject{"Im1": annotImage}},
+	}}}}, Subtype: model.AnnotationSquare{}}}
+	var doc model.Document
+	doc.Catalog.Pages.Kids = []model.PageNode{page}
+
+	report, err := Optimize(&doc, Options{MaxImageDPI: 72, Recompress: true, RemoveAlternates: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.DownsampledImages != 2 || report.RemovedAlternates != 2 {
+		t.Fatalf("unexpected report %v", report)
+	}
+	if raw.Width != 5 || raw.Height != 4 || raw.SMask.Width != 5 || raw.SMask.Height != 4 {
+		t.Fatalf("unexpected image size %d x %d", raw.Width, raw.Height)
+	}
+	samples, err := raw.Stream.Decode()
+	if err != nil || len(samples) != 5*4*3 {
+		t.Fatalf("invalid image samples (%s)", err)
+	}
+	if jpg.Width != 50 || jpg.Height != 25 {
+		t.Fatalf("unexpected image size %d x %d", jpg.Width, jpg.Height)
+	}
+	if decoded, err := jpeg.Decode(bytes.NewReader(jpg.Content)); err != nil || decoded.Bounds().Dx() != 50 {
+		t.Fatalf("invalid JPEG image (%s)", err)
+	}
+	if annotImage.Width != 10 {
+		t.Fatal("images used in annotations should not be modified")
+	}
+	if len(raw.Alternates) != 0 || page.Thumb != nil {
+		t.Fatal("alternates not removed")
+	}
+	if report.RecompressedStreams == 0 || len(page.Contents[0].Filter) != 1 || len(annotImage.Filter) != 1 {
+		t.Fatalf("streams not compressed (%v)", report)
+	}
+	if content := pageContent(t, page); content != "q 5 0 0 4 0 0 cm /Im1 Do Q q 50 0 0 25 0 0 cm /Im2 Do Q /Im3 Do" {
+		t.Fatalf("unexpected content %s", content)
+	}
+}
+
+func pageContent(t *testing.T, page *model.PageObject) string {
+	content, err := page.DecodeAllContents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
@@ -0,0 +1,167 @@
+package optimize
+
+import (
+	"bytes"
+	"compress/zlib"
+
+	"github.com/benoitkugler/pdf/model"
+)
+
+// compress returns a Flate stream, with the best compression level
+func compress(content []byte) model.Stream {
+	var buf bytes.Buffer
+	w, _ := zlib.NewWriterLevel(&buf, zlib.BestCompression)
+	w.Write(content)
+	w.Close()
+	return model.Stream{Content: buf.Bytes(), Filter: model.Filters{{Name: model.Flate}}}
+}
+
+// isLossless returns true if `filters` are general purpose
+// filters (excluding the image filters), without parameters
+func isLossless(filters model.Filters) bool {
+	for _, f := range filters {
+		switch f.Name {
+		case model.ASCII85, model.ASCIIHex, model.RunLength, model.LZW, model.Flate:
+			if len(f.DecodeParms) != 0 { // predictors are not preserved
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// recompressor compresses the streams with the best Flate level
+type recompressor struct {
+	visited map[interface{}]bool
+	count   int
+}
+
+// recompress updates `s` if possible
+func (rc *recompressor) recompress(s *model.Stream) {
+	if !isLossless(s.Filter) || len(s.Content) == 0 {
+		return
+	}
+	content, err := s.Decode()
+	if err != nil {
+		return
+	}
+	if out := compress(content); len(out.Content) < len(s.Content) {
+		*s = out
+		rc.count++
+	}
+}
+
+// recompressStreams recompresses the content of the pages, and the streams
+// found in their resources and annotation appearances, returning
+// the number of streams modified
+func recompressStreams(doc *model.Document) int {
+	rc := recompressor{visited: make(map[interface{}]bool)}
+	for _, page := range doc.Catalog.Pages.FlattenInherit() {
+		for i := range page.Contents {
+			rc.recompress(&page.Contents[i].Stream)
+		}
+		if page.Resources != nil {
+			rc.processResources(page.Resources)
+		}
+		for _, annot := range page.Annots {
+			if ap := annot.AP; ap != nil {
+				for _, entry := range [...]model.AppearanceEntry{ap.N, ap.R, ap.D} {
+					for _, form := range entry {
+						rc.processForm(form)
+					}
+				}
+			}
+		}
+	}
+	return rc.count
+}
+
+func (rc *recompressor) processForm(form *model.XObjectForm) {
+	if form == nil || rc.visited[form] {
+		return
+	}
+	rc.visited[form] = true
+	rc.recompress(&form.Stream)
+	rc.processResources(&form.Resources)
+}
+
+func (rc *recompressor) processResources(res *model.ResourcesDict) {
+	if rc.visited[res] {
+		return
+	}
+	rc.visited[res] = true
+	for _, xObject := range res.XObject {
+		switch xObject := xObject.(type) {
+		case *model.XObjectForm:
+			rc.processForm(xObject)
+		case *model.XObjectImage:
+			if xObject == nil || rc.visited[xObject] {
+				continue
+			}
+			rc.visited[xObject] = true
+			rc.recompress(&xObject.Stream)
+			if xObject.SMask != nil {
+				rc.recompress(&xObject.SMask.Stream)
+			}
+		}
+	}
+	for _, pattern := range res.Pattern {
+		if tiling, ok := pattern.(*model.PatternTiling); ok && tiling != nil && !rc.visited[tiling] {
+			rc.visited[tiling] = true
+			rc.recompress(&tiling.Stream)
+			rc.processResources(&tiling.Resources)
+		}
+	}
+	for _, font := range res.Font {
+		if font == nil || rc.visited[font] {
+			continue
+		}
+		rc.visited[font] = true
+		if file := fontFile(font); file != nil && !rc.visited[file] {
+			rc.visited[file] = true
+			rc.recompress(&file.Stream)
+		}
+		if type3, ok := font.Subtype.(model.FontType3); ok {
+			for glyph, proc := range type3.CharProcs {
+				rc.recompress(&proc.Stream)
+				type3.CharProcs[glyph] = proc // the map is shared with the font
+			}
+			rc.processResources(&type3.Resources)
+		}
+	}
+}
+
+// removeAlternates removes the alternate images
+// and the thumbnails of the pages
+func removeAlternates(doc *model.Document) int {
+	count := doc.RemoveThumbnails()
+	visited := make(map[*model.ResourcesDict]bool)
+	var processResources func(res *model.ResourcesDict)
+	processResources = func(res *model.ResourcesDict) {
+		if visited[res] {
+			return
+		}
+		visited[res] = true
+		for _, xObject := range res.XObject {
+			switch xObject := xObject.(type) {
+			case *model.XObjectImage:
+				if xObject != nil && len(xObject.Alternates) != 0 {
+					count += len(xObject.Alternates)
+					xObject.Alternates = nil
+				}
+			case *model.XObjectForm:
+				if xObject != nil {
+					processResources(&xObject.Resources)
+				}
+			}
+		}
+	}
+	for _, page := range doc.Catalog.Pages.FlattenInherit() {
+		if page.Resources != nil {
+			processResources(page.Resources)
+		}
+	}
+	return count
+}
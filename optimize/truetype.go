@@ -0,0 +1,237 @@
+package optimize
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// sfnt is a minimal representation of a TrueType font file,
+// as a set of tables
+type sfnt struct {
+	version uint32
+	tables  map[string][]byte
+}
+
+var errInvalidSfnt = errors.New("invalid TrueType font file")
+
+func parseSfnt(b []byte) (sfnt, error) {
+	if len(b) < 12 {
+		return sfnt{}, errInvalidSfnt
+	}
+	out := sfnt{version: binary.BigEndian.Uint32(b), tables: make(map[string][]byte)}
+	if out.version != 0x00010000 && out.version != 0x74727565 { // 'true'
+		return sfnt{}, fmt.Errorf("unsupported font file version %x", out.version)
+	}
+	numTables := int(binary.BigEndian.Uint16(b[4:]))
+	if len(b) < 12+16*numTables {
+		return sfnt{}, errInvalidSfnt
+	}
+	for i := 0; i < numTables; i++ {
+		record := b[12+16*i:]
+		offset, length := binary.BigEndian.Uint32(record[8:]), binary.BigEndian.Uint32(record[12:])
+		if uint64(offset)+uint64(length) > uint64(len(b)) {
+			return sfnt{}, errInvalidSfnt
+		}
+		out.tables[string(record[:4])] = b[offset : offset+length]
+	}
+	return out, nil
+}
+
+// glyphs returns the glyph data, indexed by glyph ID
+func (f sfnt) glyphs() ([][]byte, error) {
+	head, maxp, loca, glyf := f.tables["head"], f.tables["maxp"], f.tables["loca"], f.tables["glyf"]
+	if len(head) < 54 || len(maxp) < 6 {
+		return nil, errInvalidSfnt
+	}
+	numGlyphs := int(binary.BigEndian.Uint16(maxp[4:]))
+	long := binary.BigEndian.Uint16(head[50:]) == 1
+	offset := func(i int) int {
+		if long {
+			return int(binary.BigEndian.Uint32(loca[4*i:]))
+		}
+		return 2 * int(binary.BigEndian.Uint16(loca[2*i:]))
+	}
+	if (long && len(loca) < 4*(numGlyphs+1)) || (!long && len(loca) < 2*(numGlyphs+1)) {
+		return nil, errInvalidSfnt
+	}
+	out := make([][]byte, numGlyphs)
+	for i := range out {
+		start, end := offset(i), offset(i+1)
+		if start > end || end > len(glyf) {
+			return nil, errInvalidSfnt
+		}
+		out[i] = glyf[start:end]
+	}
+	return out, nil
+}
+
+// setGlyphs updates the glyf, loca and head tables
+func (f sfnt) setGlyphs(glyphs [][]byte) {
+	var glyf []byte
+	offsets := make([]int, len(glyphs)+1)
+	for i, glyph := range glyphs {
+		glyf = append(glyf, glyph...)
+		if len(glyf)%2 != 0 { // required by the short loca format
+			glyf = append(glyf, 0)
+		}
+		offsets[i+1] = len(glyf)
+	}
+	long := len(glyf) > 0x1FFFE
+	var loca []byte
+	for _, offset := range offsets {
+		if long {
+			loca = append(loca, byte(offset>>24), byte(offset>>16), byte(offset>>8), byte(offset))
+		} else {
+			loca = append(loca, byte(offset>>9), byte(offset>>1))
+		}
+	}
+	head := append([]byte(nil), f.tables["head"]...)
+	if long {
+		binary.BigEndian.PutUint16(head[50:], 1)
+	} else {
+		binary.BigEndian.PutUint16(head[50:], 0)
+	}
+	f.tables["glyf"], f.tables["loca"], f.tables["head"] = glyf, loca, head
+}
+
+func tableChecksum(b []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(b); i += 4 {
+		var word [4]byte
+		copy(word[:], b[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
+
+// write serializes the font, updating the checksums
+func (f sfnt) write() []byte {
+	tags := make([]string, 0, len(f.tables))
+	for tag := range f.tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	// the checksum adjustment is computed with a zero value
+	if head := f.tables["head"]; len(head) >= 12 {
+		head = append([]byte(nil), head...)
+		binary.BigEndian.PutUint32(head[8:], 0)
+		f.tables["head"] = head
+	}
+
+	numTables := len(tags)
+	entrySelector := 0
+	for 1<<(entrySelector+1) <= numTables {
+		entrySelector++
+	}
+	searchRange := 16 << entrySelector
+
+	out := make([]byte, 12+16*numTables)
+	binary.BigEndian.PutUint32(out, f.version)
+	binary.BigEndian.PutUint16(out[4:], uint16(numTables))
+	binary.BigEndian.PutUint16(out[6:], uint16(searchRange))
+	binary.BigEndian.PutUint16(out[8:], uint16(entrySelector))
+	binary.BigEndian.PutUint16(out[10:], uint16(16*numTables-searchRange))
+	headOffset := -1
+	for i, tag := range tags {
+		table := f.tables[tag]
+		record := out[12+16*i:]
+		copy(record, tag)
+		binary.BigEndian.PutUint32(record[4:], tableChecksum(table))
+		binary.BigEndian.PutUint32(record[8:], uint32(len(out)))
+		binary.BigEndian.PutUint32(record[12:], uint32(len(table)))
+		if tag == "head" {
+			headOffset = len(out)
+		}
+		out = append(out, table...)
+		for len(out)%4 != 0 {
+			out = append(out, 0)
+		}
+	}
+	if headOffset >= 0 && len(f.tables["head"]) >= 12 {
+		binary.BigEndian.PutUint32(out[headOffset+8:], 0xB1B0AFBA-tableChecksum(out))
+	}
+	return out
+}
+
+// sameHead compares the head tables, ignoring the fields
+// modified by subsetting tools: the checksum adjustment,
+// the creation and modification dates and the loca format
+func sameHead(h1, h2 []byte) bool {
+	if len(h1) != len(h2) || len(h1) < 54 {
+		return false
+	}
+	return bytes.Equal(h1[:8], h2[:8]) && bytes.Equal(h1[12:20], h2[12:20]) &&
+		bytes.Equal(h1[36:50], h2[36:50]) && bytes.Equal(h1[52:], h2[52:])
+}
+
+// mergeTrueType returns a font file containing the glyphs
+// of `file1` and `file2`, which must be subsets of the same font,
+// preserving the glyph IDs of the original font.
+// It returns false if the fonts are not compatible, that is if they differ
+// in any table other than the glyphs, or if a glyph is defined differently.
+func mergeTrueType(file1, file2 []byte) ([]byte, bool) {
+	f1, err := parseSfnt(file1)
+	if err != nil {
+		return nil, false
+	}
+	f2, err := parseSfnt(file2)
+	if err != nil {
+		return nil, false
+	}
+	if f1.version != f2.version || len(f1.tables) != len(f2.tables) {
+		return nil, false
+	}
+	for tag, t1 := range f1.tables {
+		t2, ok := f2.tables[tag]
+		if !ok {
+			return nil, false
+		}
+		switch tag {
+		case "glyf", "loca": // compared below
+		case "head":
+			if !sameHead(t1, t2) {
+				return nil, false
+			}
+		default:
+			if !bytes.Equal(t1, t2) {
+				return nil, false
+			}
+		}
+	}
+	glyphs1, err := f1.glyphs()
+	if err != nil {
+		return nil, false
+	}
+	glyphs2, err := f2.glyphs()
+	if err != nil || len(glyphs1) != len(glyphs2) {
+		return nil, false
+	}
+	merged := make([][]byte, len(glyphs1))
+	for i, g1 := range glyphs1 {
+		g2 := glyphs2[i]
+		switch {
+		case len(g1) == 0:
+			merged[i] = g2
+		case len(g2) == 0:
+			merged[i] = g1
+		case bytes.Equal(trimPadding(g1), trimPadding(g2)):
+			merged[i] = g1
+		default:
+			return nil, false
+		}
+	}
+	f1.setGlyphs(merged)
+	return f1.write(), true
+}
+
+// trimPadding removes the trailing zeros added to align glyph data
+func trimPadding(glyph []byte) []byte {
+	for len(glyph) != 0 && glyph[len(glyph)-1] == 0 {
+		glyph = glyph[:len(glyph)-1]
+	}
+	return glyph
+}
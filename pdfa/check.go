@@ -0,0 +1,248 @@
+// Package pdfa checks documents against the PDF/A-2b
+// requirements (ISO 19005-2, level B), and provides a best-effort
+// conversion fixing the violations which may be automated.
+//
+// Only a subset of the standard is verified: encryption, font embedding,
+// output intents, XMP metadata, JavaScript actions, transfer functions and halftones.
+// PDF/A-3 documents, such as electronic invoices (see EmbedInvoice), are also accepted.
+package pdfa
+
+import (
+	"fmt"
+
+	"github.com/benoitkugler/pdf/model"
+)
+
+// Rule identifies a requirement of the PDF/A-2 standard.
+type Rule uint8
+
+const (
+	_ Rule = iota
+	// 6.1.3 - The document shall not be encrypted.
+	Encryption
+	// 6.2.11.4 - The font programs of all the fonts used shall be embedded.
+	FontEmbedding
+	// 6.2.3 - A PDF/A output intent, with an ICC profile, shall be present.
+	OutputIntent
+	// 6.6.2 - The document shall contain XMP metadata, consistent with the Info dictionary.
+	Metadata
+	// 6.5.1 and 6.6.1 - JavaScript actions shall not be used.
+	JavaScript
+	// 6.2.5 - Graphics states shall not use transfer functions,
+	// except TR2 with the /Default value.
+	TransferFunction
+	// 6.2.5 - Halftones shall have type 1 or 5, without HalftoneName,
+	// and transfer functions only for non primary colorants.
+	Halftone
+)
+
+func (r Rule) String() string {
+	switch r {
+	case Encryption:
+		return "encryption"
+	case FontEmbedding:
+		return "font embedding"
+	case OutputIntent:
+		return "output intent"
+	case Metadata:
+		return "metadata"
+	case JavaScript:
+		return "JavaScript"
+	case TransferFunction:
+		return "transfer function"
+	case Halftone:
+		return "halftone"
+	default:
+		return fmt.Sprintf("<rule %d>", r)
+	}
+}
+
+// Violation describes one failed requirement.
+type Violation struct {
+	Rule    Rule
+	Message string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s", v.Rule, v.Message)
+}
+
+// PDFAIntent is the output intent subtype required by PDF/A.
+const PDFAIntent = model.OutputIntentPDFA
+
+// Check returns the violations of the PDF/A-2b rules found in `doc`.
+// `enc` is the encryption returned when reading the document, and may be nil.
+// Note that the output intent is always required, even if the document
+// does not use device dependent colors.
+func Check(doc *model.Document, enc *model.Encrypt) []Violation {
+	var out []Violation
+	if enc != nil {
+		out = append(out, Violation{Encryption, "the document is encrypted"})
+	}
+	out = append(out, checkFonts(doc)...)
+	out = append(out, checkOutputIntents(doc.Catalog.OutputIntents)...)
+	out = append(out, checkMetadata(doc)...)
+	for _, script := range doc.Scripts() {
+		out = append(out, Violation{JavaScript, fmt.Sprintf("the document contains a JavaScript code (%s)", script.Location)})
+	}
+	walkGraphicStates(doc, func(gs *model.GraphicState) {
+		out = append(out, checkGraphicState(gs)...)
+	})
+	return out
+}
+
+// primaryColorants may not use a transfer function in a composite halftone
+var primaryColorants = map[model.Name]bool{
+	"Default": true, "Cyan": true, "Magenta": true, "Yellow": true, "Black": true,
+	"Red": true, "Green": true, "Blue": true, "Gray": true,
+}
+
+func checkGraphicState(gs *model.GraphicState) []Violation {
+	var out []Violation
+	if gs.TR.Name != "" || gs.TR.Functions != nil {
+		out = append(out, Violation{TransferFunction, "a graphics state uses the TR entry"})
+	}
+	if gs.TR2.Functions != nil || (gs.TR2.Name != "" && gs.TR2.Name != "Default") {
+		out = append(out, Violation{TransferFunction, "a graphics state uses the TR2 entry with a value other than Default"})
+	}
+	if gs.HT != nil {
+		out = append(out, checkHalftone(gs.HT, false)...)
+	}
+	return out
+}
+
+func checkHalftone(ht *model.HalftoneDict, isNonPrimaryColorant bool) []Violation {
+	switch ht.HalftoneType {
+	case model.HalftoneDefault:
+		return nil
+	case model.HalftoneScreen, model.HalftoneComposite:
+	default:
+		return []Violation{{Halftone, fmt.Sprintf("invalid halftone type %d", ht.HalftoneType)}}
+	}
+	var out []Violation
+	if ht.HalftoneName != "" {
+		out = append(out, Violation{Halftone, "a halftone has a HalftoneName entry"})
+	}
+	if tf := ht.TransferFunction; (tf.Name != "" || tf.Functions != nil) && !isNonPrimaryColorant {
+		out = append(out, Violation{Halftone, "a halftone has a TransferFunction entry"})
+	}
+	if ht.HalftoneType == model.HalftoneComposite {
+		for name, colorant := range ht.Colorants {
+			if colorant != nil {
+				out = append(out, checkHalftone(colorant, !primaryColorants[name])...)
+			}
+		}
+	}
+	return out
+}
+
+// returns true if the font program is embedded in the document
+func isEmbedded(font model.Font) bool {
+	switch font := font.(type) {
+	case model.FontType1:
+		return font.FontDescriptor.FontFile != nil
+	case model.FontTrueType:
+		return font.FontDescriptor.FontFile != nil
+	case model.FontType0:
+		return font.DescendantFonts.FontDescriptor.FontFile != nil
+	default: // Type3 fonts are defined by content streams
+		return true
+	}
+}
+
+func checkFonts(doc *model.Document) []Violation {
+	var out []Violation
+	walkFonts(doc, func(font *model.FontDict) {
+		if font.Subtype != nil && !isEmbedded(font.Subtype) {
+			out = append(out, Violation{FontEmbedding, fmt.Sprintf("font %s is not embedded", font.Subtype.FontName())})
+		}
+	})
+	return out
+}
+
+func checkOutputIntents(intents []model.OutputIntent) []Violation {
+	var profile *model.ColorSpaceICCBased
+	for _, intent := range intents {
+		if intent.S != PDFAIntent {
+			continue
+		}
+		if intent.DestOutputProfile == nil {
+			return []Violation{{OutputIntent, "missing DestOutputProfile in the PDF/A output intent"}}
+		}
+		if profile != nil && profile != intent.DestOutputProfile {
+			return []Violation{{OutputIntent, "the output intents use several profiles"}}
+		}
+		profile = intent.DestOutputProfile
+	}
+	if profile == nil {
+		return []Violation{{OutputIntent, "missing PDF/A output intent"}}
+	}
+	return nil
+}
+
+// walkResources calls `fn` once for each resources dictionary used by the pages,
+// their annotations and the form XObjects.
+func walkResources(doc *model.Document, fn func(res model.ResourcesDict)) {
+	seenForms := map[*model.XObjectForm]bool{}
+	var walkRes func(res model.ResourcesDict)
+	walkForm := func(form *model.XObjectForm) {
+		if form == nil || seenForms[form] {
+			return
+		}
+		seenForms[form] = true
+		walkRes(form.Resources)
+	}
+	walkRes = func(res model.ResourcesDict) {
+		fn(res)
+		for _, xObject := range res.XObject {
+			if form, ok := xObject.(*model.XObjectForm); ok {
+				walkForm(form)
+			}
+		}
+	}
+
+	for _, page := range doc.Catalog.Pages.FlattenInherit() {
+		if page.Resources != nil {
+			walkRes(*page.Resources)
+		}
+		for _, annot := range page.Annots {
+			if ap := annot.AP; ap != nil {
+				for _, entry := range [3]model.AppearanceEntry{ap.N, ap.R, ap.D} {
+					for _, form := range entry {
+						walkForm(form)
+					}
+				}
+			}
+		}
+	}
+}
+
+// walkFonts calls `fn` once for each font used by the pages,
+// their annotations and the form XObjects.
+func walkFonts(doc *model.Document, fn func(font *model.FontDict)) {
+	seen := map[*model.FontDict]bool{}
+	walkResources(doc, func(res model.ResourcesDict) {
+		for _, font := range res.Font {
+			if font == nil || seen[font] {
+				continue
+			}
+			seen[font] = true
+			fn(font)
+		}
+	})
+}
+
+// walkGraphicStates calls `fn` once for each graphics state dictionary used
+// by the pages, their annotations and the form XObjects.
+func walkGraphicStates(doc *model.Document, fn func(gs *model.GraphicState)) {
+	seen := map[*model.GraphicState]bool{}
+	walkResources(doc, func(res model.ResourcesDict) {
+		for _, gs := range res.ExtGState {
+			if gs == nil || seen[gs] {
+				continue
+			}
+			seen[gs] = true
+			fn(gs)
+		}
+	})
+}
@@ -0,0 +1,157 @@
+package pdfa
+
+import (
+	"github.com/benoitkugler/pdf/fonts/standardfonts"
+	"github.com/benoitkugler/pdf/model"
+)
+
+// ConvertOptions provides the resources needed to fix
+// some violations, which are not shipped with this package.
+type ConvertOptions struct {
+	// FontFiles provides the font programs to embed, indexed by
+	// font name (BaseFont). It is used for the fonts
+	// (including the standard 14 fonts) which are not embedded.
+	FontFiles map[model.Name]*model.FontFile
+
+	// OutputProfile is an ICC profile, used to add an output intent
+	// when the document has none.
+	OutputProfile []byte
+	// ProfileComponents is the number of color components of OutputProfile,
+	// and defaults to 3 (RGB).
+	ProfileComponents int
+	// OutputCondition identifies the profile, and defaults to "sRGB IEC61966-2.1"
+	OutputCondition string
+}
+
+// Convert modifies `doc` in place to fix the violations which may be automated,
+// and returns the remaining violations. More precisely:
+//   - the missing font programs are embedded, using `options.FontFiles`. The font
+//     descriptor of the standard 14 fonts is also added when needed.
+//   - a PDF/A output intent is added using `options.OutputProfile`, if needed
+//   - the XMP metadata are updated from the Info dictionary, or regenerated if they
+//     do not already identify a PDF/A document (so that the PDF/A-3 packet added by
+//     EmbedInvoice is preserved)
+//   - the JavaScript actions are removed
+//   - the transfer functions are removed from the graphics states and halftones,
+//     and the invalid halftones are replaced by the default one
+//
+// The document must then be written without encryption.
+func Convert(doc *model.Document, options ConvertOptions) []Violation {
+	walkFonts(doc, func(font *model.FontDict) {
+		embedFont(font, options.FontFiles)
+	})
+
+	if checkOutputIntents(doc.Catalog.OutputIntents) != nil && len(options.OutputProfile) != 0 {
+		n := options.ProfileComponents
+		if n == 0 {
+			n = 3
+		}
+		condition := options.OutputCondition
+		if condition == "" {
+			condition = "sRGB IEC61966-2.1"
+		}
+		// remove the invalid PDF/A intents, but keep the others
+		var intents []model.OutputIntent
+		for _, intent := range doc.Catalog.OutputIntents {
+			if intent.S != PDFAIntent {
+				intents = append(intents, intent)
+			}
+		}
+		doc.Catalog.OutputIntents = append(intents, model.NewOutputIntent(PDFAIntent, condition, options.OutputProfile, n))
+	}
+
+	doc.Catalog.Metadata = &model.MetadataStream{Stream: model.Stream{Content: convertMetadata(doc)}}
+
+	doc.StripScripts()
+
+	walkGraphicStates(doc, normalizeGraphicState)
+
+	return Check(doc, nil)
+}
+
+// convertMetadata returns the XMP packet of `doc`, updated from the Info dictionary
+// if it already identifies a PDF/A document, so that the PDF/A part and
+// the extension schemas are kept. Otherwise, a new PDF/A-2b packet is returned.
+func convertMetadata(doc *model.Document) []byte {
+	if metadata := doc.Catalog.Metadata; metadata != nil {
+		content, err := doc.DecodeStream(metadata.Stream)
+		if err == nil {
+			if props, err := model.ParseXMP(content); err == nil && len(checkPart(props)) == 0 {
+				if out, err := doc.Trailer.Info.UpdateXMP(content); err == nil {
+					return out
+				}
+			}
+		}
+	}
+	return NewXMPMetadata(doc.Trailer.Info)
+}
+
+// embedFont adds the font program found in `files`, if needed.
+func embedFont(font *model.FontDict, files map[model.Name]*model.FontFile) {
+	// complete the font descriptor of the standard fonts, if needed
+	completeDescriptor := func(baseFont model.Name, desc *model.FontDescriptor) {
+		if desc.FontName != "" {
+			return
+		}
+		if metrics, ok := standardfonts.Fonts[string(baseFont)]; ok {
+			*desc = metrics.Descriptor
+		}
+	}
+
+	switch ft := font.Subtype.(type) {
+	case model.FontType1:
+		if file := files[ft.BaseFont]; ft.FontDescriptor.FontFile == nil && file != nil {
+			completeDescriptor(ft.BaseFont, &ft.FontDescriptor)
+			ft.FontDescriptor.FontFile = file
+			font.Subtype = ft
+		}
+	case model.FontTrueType:
+		if file := files[ft.BaseFont]; ft.FontDescriptor.FontFile == nil && file != nil {
+			ft.FontDescriptor.FontFile = file
+			font.Subtype = ft
+		}
+	case model.FontType0:
+		desc := ft.DescendantFonts
+		if file := files[desc.BaseFont]; desc.FontDescriptor.FontFile == nil && file != nil {
+			ft.DescendantFonts.FontDescriptor.FontFile = file
+			font.Subtype = ft
+		}
+	}
+}
+
+// normalizeGraphicState removes the device dependent entries
+// forbidden by PDF/A.
+func normalizeGraphicState(gs *model.GraphicState) {
+	gs.TR = model.GraphicStateFunction{}
+	if gs.TR2.Name != "Default" {
+		gs.TR2 = model.GraphicStateFunction{}
+	}
+	if gs.HT != nil && !normalizeHalftone(gs.HT, false) {
+		gs.HT = nil // use the default halftone
+	}
+}
+
+// normalizeHalftone fixes `ht` in place, returning false if it has
+// an invalid type and must be removed.
+func normalizeHalftone(ht *model.HalftoneDict, isNonPrimaryColorant bool) bool {
+	switch ht.HalftoneType {
+	case model.HalftoneDefault:
+		return true
+	case model.HalftoneScreen, model.HalftoneComposite:
+	default:
+		return false
+	}
+	ht.HalftoneName = ""
+	if !isNonPrimaryColorant {
+		ht.TransferFunction = model.GraphicStateFunction{}
+	}
+	for name, colorant := range ht.Colorants {
+		if colorant != nil && !normalizeHalftone(colorant, !primaryColorants[name]) {
+			delete(ht.Colorants, name)
+		}
+	}
+	if ht.HalftoneType == model.HalftoneComposite && ht.Colorants["Default"] == nil {
+		return false // Default is required
+	}
+	return true
+}
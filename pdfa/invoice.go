@@ -0,0 +1,135 @@
+package pdfa
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+
+	"github.com/benoitkugler/pdf/model"
+)
+
+// nsFacturX is the namespace of the Factur-X (and ZUGFeRD 2) XMP schema
+const nsFacturX = "urn:factur-x:pdfa:CrossIndustryDocument:invoice:1p0#"
+
+var (
+	propFxDocumentFileName = model.XMPProperty{Namespace: nsFacturX, Name: "DocumentFileName"}
+	propFxDocumentType     = model.XMPProperty{Namespace: nsFacturX, Name: "DocumentType"}
+	propFxVersion          = model.XMPProperty{Namespace: nsFacturX, Name: "Version"}
+	propFxConformanceLevel = model.XMPProperty{Namespace: nsFacturX, Name: "ConformanceLevel"}
+)
+
+// InvoiceOptions customizes the electronic invoice embedded by EmbedInvoice.
+type InvoiceOptions struct {
+	// FileName is the name of the embedded XML file.
+	// It defaults to "factur-x.xml".
+	FileName string
+	// ConformanceLevel is the Factur-X profile of the invoice,
+	// one of MINIMUM, BASIC WL, BASIC, EN 16931 (the default), EXTENDED or XRECHNUNG.
+	ConformanceLevel string
+	// Version is the version of the Factur-X schema, defaulting to "1.0".
+	Version string
+	// Relationship is the relationship between the XML file and the document.
+	// It defaults to Data for the MINIMUM and BASIC WL profiles,
+	// and to Alternative otherwise.
+	Relationship model.Name
+}
+
+func (opts *InvoiceOptions) setDefaults() {
+	if opts.FileName == "" {
+		opts.FileName = "factur-x.xml"
+	}
+	if opts.ConformanceLevel == "" {
+		opts.ConformanceLevel = "EN 16931"
+	}
+	if opts.Version == "" {
+		opts.Version = "1.0"
+	}
+	if opts.Relationship == "" {
+		switch opts.ConformanceLevel {
+		case "MINIMUM", "BASIC WL":
+			opts.Relationship = "Data"
+		default:
+			opts.Relationship = "Alternative"
+		}
+	}
+}
+
+// EmbedInvoice embeds the XML data `invoice` of an electronic invoice
+// (Factur-X or ZUGFeRD 2) as a file associated with the document (see model.Document.AddAssociatedFile),
+// and replaces the XMP metadata by a packet identifying a PDF/A-3b document,
+// with the Factur-X properties and their extension schema.
+// The other PDF/A requirements are not handled: see Convert.
+func EmbedInvoice(doc *model.Document, invoice []byte, options InvoiceOptions) {
+	options.setDefaults()
+	doc.AddAssociatedFile(options.FileName, invoice, "text/xml", "Factur-X invoice", options.Relationship)
+	doc.Catalog.Metadata = &model.MetadataStream{Stream: model.Stream{Content: newInvoiceXMPMetadata(doc.Trailer.Info, options)}}
+}
+
+func escapeXML(s string) string {
+	var b bytes.Buffer
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// fxProperty describes a property of the Factur-X extension schema
+func fxProperty(name, description string) string {
+	return "         <rdf:li rdf:parseType=\"Resource\">\n" +
+		"          <pdfaProperty:name>" + name + "</pdfaProperty:name>\n" +
+		"          <pdfaProperty:valueType>Text</pdfaProperty:valueType>\n" +
+		"          <pdfaProperty:category>external</pdfaProperty:category>\n" +
+		"          <pdfaProperty:description>" + description + "</pdfaProperty:description>\n" +
+		"         </rdf:li>\n"
+}
+
+// invoiceXMP identifies a PDF/A-3b document with a Factur-X invoice.
+// It expects the file name, document type, version and conformance level.
+const invoiceXMP = "<?xpacket begin=\"\ufeff\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n" +
+	"<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n" +
+	" <rdf:RDF xmlns:rdf=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\">\n" +
+	"  <rdf:Description rdf:about=\"\" xmlns:dc=\"http://purl.org/dc/elements/1.1/\" xmlns:pdfaid=\"" + nsPDFAID + "\">\n" +
+	"   <pdfaid:part>3</pdfaid:part>\n" +
+	"   <pdfaid:conformance>B</pdfaid:conformance>\n" +
+	"   <dc:format>application/pdf</dc:format>\n" +
+	"  </rdf:Description>\n" +
+	"  <rdf:Description rdf:about=\"\" xmlns:fx=\"" + nsFacturX + "\">\n" +
+	"   <fx:DocumentFileName>%s</fx:DocumentFileName>\n" +
+	"   <fx:DocumentType>%s</fx:DocumentType>\n" +
+	"   <fx:Version>%s</fx:Version>\n" +
+	"   <fx:ConformanceLevel>%s</fx:ConformanceLevel>\n" +
+	"  </rdf:Description>\n" +
+	"  <rdf:Description rdf:about=\"\" xmlns:pdfaExtension=\"http://www.aiim.org/pdfa/ns/extension/\"" +
+	" xmlns:pdfaSchema=\"http://www.aiim.org/pdfa/ns/schema#\" xmlns:pdfaProperty=\"http://www.aiim.org/pdfa/ns/property#\">\n" +
+	"   <pdfaExtension:schemas>\n" +
+	"    <rdf:Bag>\n" +
+	"     <rdf:li rdf:parseType=\"Resource\">\n" +
+	"      <pdfaSchema:schema>Factur-X PDFA Extension Schema</pdfaSchema:schema>\n" +
+	"      <pdfaSchema:namespaceURI>" + nsFacturX + "</pdfaSchema:namespaceURI>\n" +
+	"      <pdfaSchema:prefix>fx</pdfaSchema:prefix>\n" +
+	"      <pdfaSchema:property>\n" +
+	"       <rdf:Seq>\n" +
+	"%s" +
+	"       </rdf:Seq>\n" +
+	"      </pdfaSchema:property>\n" +
+	"     </rdf:li>\n" +
+	"    </rdf:Bag>\n" +
+	"   </pdfaExtension:schemas>\n" +
+	"  </rdf:Description>\n" +
+	" </rdf:RDF>\n" +
+	"</x:xmpmeta>\n" +
+	"<?xpacket end=\"w\"?>"
+
+// newInvoiceXMPMetadata returns an XMP packet identifying a PDF/A-3b
+// document embedding a Factur-X invoice, whose properties are consistent with `info`.
+func newInvoiceXMPMetadata(info model.Info, options InvoiceOptions) []byte {
+	properties := fxProperty("DocumentFileName", "The name of the embedded XML document") +
+		fxProperty("DocumentType", "The type of the hybrid document in capital letters, e.g. INVOICE or ORDER") +
+		fxProperty("Version", "The actual version of the standard applying to the embedded XML document") +
+		fxProperty("ConformanceLevel", "The conformance level of the embedded XML document")
+	packet := fmt.Sprintf(invoiceXMP, escapeXML(options.FileName), "INVOICE",
+		escapeXML(options.Version), escapeXML(options.ConformanceLevel), properties)
+	out, err := info.UpdateXMP([]byte(packet))
+	if err != nil { // invoiceXMP is valid
+		panic(err)
+	}
+	return out
+}
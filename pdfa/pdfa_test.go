@@ -0,0 +1,220 @@
+package pdfa
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/benoitkugler/pdf/model"
+	"github.com/benoitkugler/pdf/reader"
+)
+
+func sampleDocument() model.Document {
+	var doc model.Document
+	doc.Trailer.Info = model.Info{
+		Title:        "PDF/A <test>",
+		Author:       "Benoit",
+		Producer:     "pdfa",
+		CreationDate: time.Date(2020, 5, 4, 12, 30, 0, 0, time.UTC),
+	}
+	font := &model.FontDict{Subtype: model.FontType1{BaseFont: "Helvetica"}}
+	page := &model.PageObject{
+		MediaBox:  &model.Rectangle{Urx: 100, Ury: 100},
+		Resources: &model.ResourcesDict{Font: map[model.Name]*model.FontDict{"F1": font}},
+		Contents:  []model.ContentStream{{Stream: model.Stream{Content: []byte("BT /F1 12 Tf (Hello) Tj ET")}}},
+		AA:        model.PageAdditionalActions{O: model.Action{ActionType: model.ActionJavaScript{JS: "app.beep();"}}},
+	}
+	doc.Catalog.AA.WC = model.Action{ActionType: model.ActionJavaScript{JS: "app.beep();"}}
+	doc.Catalog.Names.JavaScript = model.JavaScriptTree{{Name: "init", Action: model.Action{ActionType: model.ActionJavaScript{JS: "var a = 1;"}}}}
+	doc.Catalog.Pages.Kids = []model.PageNode{page}
+	doc.Catalog.OpenAction = model.Action{
+		ActionType: model.ActionJavaScript{JS: "app.alert('Hello');"},
+		Next:       []model.Action{{ActionType: model.ActionURI{URI: "https://example.com"}}},
+	}
+	return doc
+}
+
+func rules(violations []Violation) map[Rule]bool {
+	out := map[Rule]bool{}
+	for _, v := range violations {
+		out[v.Rule] = true
+	}
+	return out
+}
+
+func TestCheck(t *testing.T) {
+	doc := sampleDocument()
+	violations := Check(&doc, &model.Encrypt{})
+	got := rules(violations)
+	for _, rule := range [...]Rule{Encryption, FontEmbedding, OutputIntent, Metadata, JavaScript} {
+		if !got[rule] {
+			t.Errorf("missing violation %s", rule)
+		}
+	}
+	nbScripts := 0
+	for _, v := range violations {
+		if v.Rule == JavaScript {
+			nbScripts++
+		}
+	}
+	if nbScripts != 4 { // OpenAction, AA/WC, Names/JavaScript, page AA/O
+		t.Fatalf("expected 4 JavaScript violations, got %d", nbScripts)
+	}
+
+	// inconsistent metadata
+	doc.Catalog.Metadata = &model.MetadataStream{Stream: model.Stream{Content: NewXMPMetadata(model.Info{Title: "Other"})}}
+	if v := checkMetadata(&doc); len(v) != 4 { // Title, Author, Producer and CreationDate
+		t.Fatalf("unexpected violations %v", v)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	doc := sampleDocument()
+	violations := Convert(&doc, ConvertOptions{
+		FontFiles:     map[model.Name]*model.FontFile{"Helvetica": {Stream: model.Stream{Content: []byte("font program")}}},
+		OutputProfile: []byte("ICC profile"),
+	})
+	if len(violations) != 0 {
+		t.Fatalf("unexpected violations %v", violations)
+	}
+	if _, isURI := doc.Catalog.OpenAction.ActionType.(model.ActionURI); !isURI {
+		t.Fatalf("unexpected open action %v", doc.Catalog.OpenAction)
+	}
+	if len(doc.Scripts()) != 0 || len(doc.Catalog.Names.JavaScript) != 0 {
+		t.Fatal("scripts not removed")
+	}
+
+	var buf bytes.Buffer
+	if err := doc.Write(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	read, enc, err := reader.ParsePDFReader(bytes.NewReader(buf.Bytes()), reader.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if violations = Check(&read, enc); len(violations) != 0 {
+		t.Fatalf("unexpected violations after reading %v", violations)
+	}
+}
+
+func TestGraphicStates(t *testing.T) {
+	fn := &model.FunctionDict{Domain: []model.Range{{0, 1}}, FunctionType: model.FunctionExpInterpolation{N: 1}}
+	doc := sampleDocument()
+	page := doc.Catalog.Pages.Flatten()[0]
+	screen := &model.HalftoneDict{HalftoneType: model.HalftoneScreen, Frequency: 60, SpotFunction: model.GraphicStateFunction{Name: "Round"}}
+	page.Resources.ExtGState = map[model.Name]*model.GraphicState{
+		"GS1": {
+			TR:  model.GraphicStateFunction{Name: "Identity"},
+			TR2: model.GraphicStateFunction{Name: "Default"},
+			HT: &model.HalftoneDict{HalftoneType: model.HalftoneComposite, HalftoneName: "name", Colorants: map[model.Name]*model.HalftoneDict{
+				"Default": screen,
+				"Spot":    {HalftoneType: model.HalftoneScreen, TransferFunction: model.GraphicStateFunction{Functions: []*model.FunctionDict{fn}}},
+				"Cyan":    {HalftoneType: model.HalftoneThreshold},
+			}},
+		},
+		"GS2": {HT: &model.HalftoneDict{HalftoneType: model.HalftoneTwoSquares}},
+	}
+	var nbTransfer, nbHalftone int
+	for _, v := range Check(&doc, nil) {
+		switch v.Rule {
+		case TransferFunction:
+			nbTransfer++
+		case Halftone:
+			nbHalftone++
+		}
+	}
+	if nbTransfer != 1 || nbHalftone != 3 { // TR ; HalftoneName, Cyan, GS2
+		t.Fatalf("unexpected violations: %d %d", nbTransfer, nbHalftone)
+	}
+
+	walkGraphicStates(&doc, normalizeGraphicState)
+	gs1, gs2 := page.Resources.ExtGState["GS1"], page.Resources.ExtGState["GS2"]
+	for _, gs := range []*model.GraphicState{gs1, gs2} {
+		if v := checkGraphicState(gs); len(v) != 0 {
+			t.Fatalf("unexpected violations after normalization: %v", v)
+		}
+	}
+	if gs2.HT != nil || gs1.TR2.Name != "Default" || len(gs1.HT.Colorants) != 2 || gs1.HT.Colorant("Cyan") != screen {
+		t.Fatalf("unexpected normalization %v %v", gs1, gs2)
+	}
+	if len(gs1.HT.Colorants["Spot"].TransferFunction.Functions) != 1 {
+		t.Fatal("transfer function of non primary colorant should be preserved")
+	}
+}
+
+func TestEmbedInvoice(t *testing.T) {
+	doc := sampleDocument()
+	Convert(&doc, ConvertOptions{
+		FontFiles:     map[model.Name]*model.FontFile{"Helvetica": {Stream: model.Stream{Content: []byte("font program")}}},
+		OutputProfile: []byte("ICC profile"),
+	})
+	invoice := []byte("<rsm:CrossIndustryInvoice/>")
+	EmbedInvoice(&doc, invoice, InvoiceOptions{ConformanceLevel: "BASIC WL"})
+
+	var buf bytes.Buffer
+	if err := doc.Write(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	read, enc, err := reader.ParsePDFReader(bytes.NewReader(buf.Bytes()), reader.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if violations := Check(&read, enc); len(violations) != 0 {
+		t.Fatalf("unexpected violations after reading %v", violations)
+	}
+
+	af := read.Catalog.AF
+	if len(af) != 1 || af[0].UF != "factur-x.xml" || af[0].AFRelationship != "Data" {
+		t.Fatalf("unexpected associated files %v", af)
+	}
+	if content, err := af[0].EF.Decode(); err != nil || !bytes.Equal(content, invoice) {
+		t.Fatalf("unexpected invoice content %s (%v)", content, err)
+	}
+
+	props, err := model.ParseXMP(read.Catalog.Metadata.Content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for prop, exp := range map[model.XMPProperty]string{
+		propPart:               "3",
+		propFxDocumentFileName: "factur-x.xml",
+		propFxDocumentType:     "INVOICE",
+		propFxVersion:          "1.0",
+		propFxConformanceLevel: "BASIC WL",
+	} {
+		if props[prop] != exp {
+			t.Errorf("expected %s for %s, got %s", exp, prop.Name, props[prop])
+		}
+	}
+	if props[model.XMPProperty{Namespace: "http://purl.org/dc/elements/1.1/", Name: "title"}] == "" {
+		t.Error("missing title in XMP metadata")
+	}
+}
+
+func TestConvertAfterInvoice(t *testing.T) {
+	doc := sampleDocument()
+	EmbedInvoice(&doc, []byte("<rsm:CrossIndustryInvoice/>"), InvoiceOptions{})
+	doc.Trailer.Info.Title = "Updated title"
+	violations := Convert(&doc, ConvertOptions{
+		FontFiles:     map[model.Name]*model.FontFile{"Helvetica": {Stream: model.Stream{Content: []byte("font program")}}},
+		OutputProfile: []byte("ICC profile"),
+	})
+	if len(violations) != 0 {
+		t.Fatalf("unexpected violations %v", violations)
+	}
+
+	content := doc.Catalog.Metadata.Content
+	props, err := model.ParseXMP(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if props[propPart] != "3" || props[propFxDocumentType] != "INVOICE" {
+		t.Fatalf("invoice properties not preserved: %v", props)
+	}
+	if !bytes.Contains(content, []byte("<pdfaExtension:schemas>")) {
+		t.Fatal("missing extension schema")
+	}
+	if title := model.InfoFromXMP(props).Title; title != "Updated title" {
+		t.Fatalf("unexpected title %s", title)
+	}
+}
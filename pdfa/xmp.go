@@ -0,0 +1,106 @@
+package pdfa
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/benoitkugler/pdf/model"
+)
+
+// nsPDFAID is the namespace of the PDF/A identification schema
+const nsPDFAID = "http://www.aiim.org/pdfa/ns/id/"
+
+var (
+	propPart        = model.XMPProperty{Namespace: nsPDFAID, Name: "part"}
+	propConformance = model.XMPProperty{Namespace: nsPDFAID, Name: "conformance"}
+)
+
+// checkXMPInfo verifies that the entries of the Info dictionary
+// are present and equivalent in the XMP metadata.
+func checkXMPInfo(info model.Info, props map[model.XMPProperty]string) []Violation {
+	xmpInfo := model.InfoFromXMP(props)
+	var out []Violation
+	for _, entry := range [...]struct {
+		key             string
+		value, xmpValue string
+	}{
+		{"Title", info.Title, xmpInfo.Title},
+		{"Author", info.Author, xmpInfo.Author},
+		{"Subject", info.Subject, xmpInfo.Subject},
+		{"Keywords", info.Keywords, xmpInfo.Keywords},
+		{"Creator", info.Creator, xmpInfo.Creator},
+		{"Producer", info.Producer, xmpInfo.Producer},
+	} {
+		if entry.value != "" && entry.xmpValue != entry.value {
+			out = append(out, Violation{Metadata, fmt.Sprintf("Info entry %s is not consistent with the XMP metadata", entry.key)})
+		}
+	}
+	for _, entry := range [...]struct {
+		key             string
+		value, xmpValue time.Time
+	}{
+		{"CreationDate", info.CreationDate, xmpInfo.CreationDate},
+		{"ModDate", info.ModDate, xmpInfo.ModDate},
+	} {
+		if !entry.value.IsZero() && !entry.xmpValue.Truncate(time.Second).Equal(entry.value.Truncate(time.Second)) {
+			out = append(out, Violation{Metadata, fmt.Sprintf("Info entry %s is not consistent with the XMP metadata", entry.key)})
+		}
+	}
+	return out
+}
+
+func checkMetadata(doc *model.Document) []Violation {
+	metadata := doc.Catalog.Metadata
+	if metadata == nil {
+		return []Violation{{Metadata, "missing XMP metadata"}}
+	}
+	if len(metadata.Filter) != 0 {
+		return []Violation{{Metadata, "the metadata stream shall not be filtered"}}
+	}
+	props, err := model.ParseXMP(metadata.Content)
+	if err != nil {
+		return []Violation{{Metadata, err.Error()}}
+	}
+
+	out := checkPart(props)
+	out = append(out, checkXMPInfo(doc.Trailer.Info, props)...)
+	return out
+}
+
+// checkPart verifies the PDF/A identification properties.
+func checkPart(props map[model.XMPProperty]string) []Violation {
+	var out []Violation
+	// PDF/A-3 only differs from PDF/A-2 by allowing arbitrary embedded files
+	if part := props[propPart]; part != "2" && part != "3" {
+		out = append(out, Violation{Metadata, fmt.Sprintf("invalid PDF/A part %q (expected 2 or 3)", part)})
+	}
+	switch conformance := props[propConformance]; conformance {
+	case "A", "B", "U":
+	default:
+		out = append(out, Violation{Metadata, fmt.Sprintf("invalid PDF/A conformance level %q", conformance)})
+	}
+	return out
+}
+
+// pdfaXMP identifies a PDF/A-2b document
+const pdfaXMP = "<?xpacket begin=\"\ufeff\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n" +
+	"<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n" +
+	" <rdf:RDF xmlns:rdf=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\">\n" +
+	"  <rdf:Description rdf:about=\"\" xmlns:dc=\"http://purl.org/dc/elements/1.1/\" xmlns:pdfaid=\"" + nsPDFAID + "\">\n" +
+	"   <pdfaid:part>2</pdfaid:part>\n" +
+	"   <pdfaid:conformance>B</pdfaid:conformance>\n" +
+	"   <dc:format>application/pdf</dc:format>\n" +
+	"  </rdf:Description>\n" +
+	" </rdf:RDF>\n" +
+	"</x:xmpmeta>\n" +
+	"<?xpacket end=\"w\"?>"
+
+// NewXMPMetadata returns an XMP packet identifying a PDF/A-2b
+// document, whose properties are consistent with `info`.
+func NewXMPMetadata(info model.Info) []byte {
+	out, err := info.UpdateXMP([]byte(pdfaXMP))
+	if err != nil { // pdfaXMP is valid
+		panic(err)
+	}
+	return out
+}
@@ -0,0 +1,142 @@
+// Package pdfx checks the requirements of the PDF/X standards
+// (ISO 15930) which are related to the print production:
+// the page boxes and the output intent.
+//
+// The other requirements (such as font embedding or the absence of
+// encryption) are shared with PDF/A: see the pdfa package.
+package pdfx
+
+import (
+	"fmt"
+
+	"github.com/benoitkugler/pdf/model"
+)
+
+// Rule identifies a requirement of the PDF/X standard.
+type Rule uint8
+
+const (
+	_ Rule = iota
+	// Each page shall have a TrimBox or an ArtBox, but not both.
+	TrimBox
+	// The boxes shall be nested: TrimBox and ArtBox inside the BleedBox,
+	// itself inside the MediaBox.
+	PageBoxes
+	// A PDF/X output intent shall be present, with an ICC profile
+	// or a registered output condition.
+	OutputIntent
+)
+
+func (r Rule) String() string {
+	switch r {
+	case TrimBox:
+		return "trim box"
+	case PageBoxes:
+		return "page boxes"
+	case OutputIntent:
+		return "output intent"
+	default:
+		return fmt.Sprintf("<rule %d>", r)
+	}
+}
+
+// Violation describes one failed requirement.
+type Violation struct {
+	Rule    Rule
+	Message string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s", v.Rule, v.Message)
+}
+
+// Check returns the violations of the PDF/X page boxes
+// and output intent rules found in `doc`.
+func Check(doc *model.Document) []Violation {
+	var out []Violation
+	for i, page := range doc.Catalog.Pages.FlattenInherit() {
+		out = append(out, CheckPageBoxes(page, i)...)
+	}
+	out = append(out, checkOutputIntents(doc.Catalog.OutputIntents)...)
+	return out
+}
+
+// CheckPageBoxes checks the consistency of the boxes of `page`,
+// which must have its inherited MediaBox resolved (see model.PageTree.FlattenInherit).
+// `index` is only used in the messages.
+func CheckPageBoxes(page model.PageObject, index int) []Violation {
+	var out []Violation
+	switch {
+	case page.TrimBox == nil && page.ArtBox == nil:
+		out = append(out, Violation{TrimBox, fmt.Sprintf("page %d has no TrimBox nor ArtBox", index)})
+	case page.TrimBox != nil && page.ArtBox != nil:
+		out = append(out, Violation{TrimBox, fmt.Sprintf("page %d has both a TrimBox and an ArtBox", index)})
+	}
+
+	if page.MediaBox == nil {
+		return append(out, Violation{PageBoxes, fmt.Sprintf("page %d has no MediaBox", index)})
+	}
+	media := page.MediaBox.Normalize()
+	// the bleed box defaults to the crop box, which is clipped to the media box,
+	// and itself defaults to the media box
+	bleed := media
+	if page.CropBox != nil {
+		if crop, ok := page.CropBox.Intersect(media); ok {
+			bleed = crop
+		}
+	}
+	if page.BleedBox != nil {
+		bleed = page.BleedBox.Normalize()
+		if !contains(media, bleed) {
+			out = append(out, Violation{PageBoxes, fmt.Sprintf("the BleedBox of page %d exceeds its MediaBox", index)})
+		}
+	}
+	for _, box := range [...]struct {
+		name string
+		rect *model.Rectangle
+	}{{"TrimBox", page.TrimBox}, {"ArtBox", page.ArtBox}} {
+		if box.rect == nil {
+			continue
+		}
+		rect := box.rect.Normalize()
+		if rect.Width() <= 0 || rect.Height() <= 0 {
+			out = append(out, Violation{PageBoxes, fmt.Sprintf("the %s of page %d is empty", box.name, index)})
+		} else if !contains(bleed, rect) {
+			out = append(out, Violation{PageBoxes, fmt.Sprintf("the %s of page %d exceeds its BleedBox", box.name, index)})
+		}
+	}
+	return out
+}
+
+// tolerance accounts for rounding errors in the box coordinates
+const tolerance = 0.01
+
+// contains returns true if `inner` is inside `outer`,
+// both being normalized
+func contains(outer, inner model.Rectangle) bool {
+	return inner.Llx >= outer.Llx-tolerance && inner.Lly >= outer.Lly-tolerance &&
+		inner.Urx <= outer.Urx+tolerance && inner.Ury <= outer.Ury+tolerance
+}
+
+func checkOutputIntents(intents []model.OutputIntent) []Violation {
+	var found bool
+	for _, intent := range intents {
+		if intent.S != model.OutputIntentPDFX {
+			continue
+		}
+		if found {
+			return []Violation{{OutputIntent, "the document has several PDF/X output intents"}}
+		}
+		found = true
+		if intent.OutputConditionIdentifier == "" {
+			return []Violation{{OutputIntent, "missing OutputConditionIdentifier in the PDF/X output intent"}}
+		}
+		if intent.DestOutputProfile == nil && intent.RegistryName == "" {
+			return []Violation{{OutputIntent, "the PDF/X output intent has no DestOutputProfile nor RegistryName"}}
+		}
+	}
+	if !found {
+		return []Violation{{OutputIntent, "missing PDF/X output intent"}}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package pdfx
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/benoitkugler/pdf/model"
+	"github.com/benoitkugler/pdf/reader"
+)
+
+func rules(violations []Violation) map[Rule]int {
+	out := map[Rule]int{}
+	for _, v := range violations {
+		out[v.Rule]++
+	}
+	return out
+}
+
+func TestCheckPageBoxes(t *testing.T) {
+	media := &model.Rectangle{Urx: 200, Ury: 300}
+	for _, test := range []struct {
+		page     model.PageObject
+		expected map[Rule]int
+	}{
+		{model.PageObject{MediaBox: media, TrimBox: &model.Rectangle{Llx: 10, Lly: 10, Urx: 190, Ury: 290}}, map[Rule]int{}},
+		{model.PageObject{MediaBox: media}, map[Rule]int{TrimBox: 1}},
+		{model.PageObject{MediaBox: media, TrimBox: &model.Rectangle{Urx: 10, Ury: 10}, ArtBox: &model.Rectangle{Urx: 10, Ury: 10}}, map[Rule]int{TrimBox: 1}},
+		{ // inverted coordinates are accepted
+			model.PageObject{
+				MediaBox: media,
+				BleedBox: &model.Rectangle{Llx: 195, Lly: 295, Urx: 5, Ury: 5},
+				TrimBox:  &model.Rectangle{Llx: 10, Lly: 10, Urx: 190, Ury: 290},
+			}, map[Rule]int{},
+		},
+		{
+			model.PageObject{
+				MediaBox: media,
+				BleedBox: &model.Rectangle{Llx: 5, Lly: 5, Urx: 195, Ury: 295},
+				TrimBox:  &model.Rectangle{Llx: 0, Lly: 10, Urx: 190, Ury: 290},
+			}, map[Rule]int{PageBoxes: 1},
+		},
+		{
+			model.PageObject{
+				MediaBox: media,
+				BleedBox: &model.Rectangle{Llx: -5, Lly: 5, Urx: 195, Ury: 295},
+				TrimBox:  &model.Rectangle{Llx: 10, Lly: 10, Urx: 10, Ury: 290},
+			}, map[Rule]int{PageBoxes: 2},
+		},
+		{ // the bleed box defaults to the crop box
+			model.PageObject{
+				MediaBox: media,
+				CropBox:  &model.Rectangle{Llx: 20, Lly: 20, Urx: 180, Ury: 280},
+				TrimBox:  &model.Rectangle{Llx: 10, Lly: 10, Urx: 190, Ury: 290},
+			}, map[Rule]int{PageBoxes: 1},
+		},
+		{
+			model.PageObject{
+				MediaBox: media,
+				CropBox:  &model.Rectangle{Llx: 20, Lly: 20, Urx: 180, Ury: 280},
+				TrimBox:  &model.Rectangle{Llx: 30, Lly: 30, Urx: 170, Ury: 270},
+			}, map[Rule]int{},
+		},
+	} {
+		got := rules(CheckPageBoxes(test.page, 0))
+		if len(got) != len(test.expected) {
+			t.Fatalf("expected %v, got %v", test.expected, got)
+		}
+		for rule, n := range test.expected {
+			if got[rule] != n {
+				t.Fatalf("expected %v, got %v", test.expected, got)
+			}
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	var doc model.Document
+	doc.Catalog.Pages.MediaBox = &model.Rectangle{Urx: 200, Ury: 300} // inherited
+	doc.Catalog.Pages.Kids = []model.PageNode{
+		&model.PageObject{TrimBox: &model.Rectangle{Llx: 10, Lly: 10, Urx: 190, Ury: 290}},
+	}
+	if got := rules(Check(&doc)); len(got) != 1 || got[OutputIntent] != 1 {
+		t.Fatalf("unexpected violations %v", got)
+	}
+
+	doc.Catalog.OutputIntents = []model.OutputIntent{{S: model.OutputIntentPDFX, OutputConditionIdentifier: "FOGRA39"}}
+	if got := rules(Check(&doc)); len(got) != 1 || got[OutputIntent] != 1 {
+		t.Fatalf("unexpected violations %v", got)
+	}
+
+	doc.Catalog.OutputIntents = []model.OutputIntent{model.NewOutputIntent(model.OutputIntentPDFX, "FOGRA39", []byte("fake ICC profile"), 4)}
+	if v := Check(&doc); len(v) != 0 {
+		t.Fatalf("unexpected violations %v", v)
+	}
+
+	// the output intent is preserved when writing
+	var b bytes.Buffer
+	if err := doc.Write(&b, nil); err != nil {
+		t.Fatal(err)
+	}
+	doc2, _, err := reader.ParsePDFReader(bytes.NewReader(b.Bytes()), reader.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := Check(&doc2); len(v) != 0 {
+		t.Fatalf("unexpected violations %v", v)
+	}
+	if intent := doc2.Catalog.OutputIntents[0]; intent.DestOutputProfile.N != 4 {
+		t.Fatalf("unexpected output intent %v", intent)
+	}
+}
@@ -0,0 +1,215 @@
+package raster
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+
+	"github.com/benoitkugler/pdf/colors"
+	"github.com/benoitkugler/pdf/model"
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/math/f64"
+)
+
+func clamp(v Fl) uint8 {
+	if v <= 0 {
+		return 0
+	}
+	if v >= 1 {
+		return 0xFF
+	}
+	return uint8(v*0xFF + 0.5)
+}
+
+// resolveColorSpace returns the color space for `name`,
+// defaulting to `name` itself
+func resolveColorSpace(res model.ResourcesDict, name model.ColorSpaceName) model.ColorSpace {
+	space, err := res.ColorSpace.Resolve(name)
+	if err != nil {
+		return name
+	}
+	return space
+}
+
+// initialColor returns the color set by the cs operators
+func initialColor(space model.ColorSpace) color.RGBA {
+	switch space := space.(type) {
+	case model.ColorSpaceName:
+		if space == model.ColorSpaceCMYK {
+			return deviceColor(space, []Fl{0, 0, 0, 1})
+		}
+	case model.ColorSpaceLab:
+		return color.RGBA{A: 0xFF}
+	}
+	return deviceColor(space, nil)
+}
+
+// deviceColor converts `comps`, expressed in `space`, to RGB.
+func deviceColor(space model.ColorSpace, comps []Fl) color.RGBA {
+	return colors.ToRGBA(space, comps)
+}
+
+// samples reads the samples of a raw image
+type samples struct {
+	data   []byte
+	bpc    int
+	stride int // bytes per row
+}
+
+func (s samples) at(row, index int) int {
+	switch s.bpc {
+	case 8:
+		if i := row*s.stride + index; i < len(s.data) {
+			return int(s.data[i])
+		}
+	case 16:
+		if i := row*s.stride + 2*index; i+1 < len(s.data) {
+			return int(s.data[i])<<8 | int(s.data[i+1])
+		}
+	case 1, 2, 4:
+		bit := index * s.bpc
+		if i := row*s.stride + bit/8; i < len(s.data) {
+			shift := 8 - s.bpc - bit%8
+			return int(s.data[i]>>shift) & (1<<s.bpc - 1)
+		}
+	}
+	return 0
+}
+
+// errUnsupportedImage is returned for images which can't be decoded
+var errUnsupportedImage = errors.New("unsupported image")
+
+// rawSamples decodes the content of `img`, or returns a JPEG image
+func rawSamples(img model.Image) (samples, image.Image, error) {
+	data, err := img.DecodePartial()
+	if err != nil {
+		var partial model.PartialDecodeErr
+		if errors.As(err, &partial) && len(partial.Remaining) == 1 && partial.Remaining[0].Name == model.DCT {
+			decoded, err := jpeg.Decode(bytes.NewReader(data))
+			return samples{}, decoded, err
+		}
+		return samples{}, nil, err
+	}
+	bpc := int(img.BitsPerComponent)
+	if img.ImageMask || bpc == 0 {
+		bpc = 1
+	}
+	return samples{data: data, bpc: bpc}, nil, nil
+}
+
+// decodeImage returns `img` as an RGBA image, where ImageMask
+// images are painted with `fill`.
+func decodeImage(img model.Image, space model.ColorSpace, smask *model.ImageSMask, fill color.RGBA) (image.Image, error) {
+	if img.Width <= 0 || img.Height <= 0 {
+		return nil, errUnsupportedImage
+	}
+	raw, decoded, err := rawSamples(img)
+	if err != nil {
+		return nil, err
+	}
+
+	var out *image.NRGBA
+	if decoded != nil {
+		out = image.NewNRGBA(decoded.Bounds())
+		xdraw.Draw(out, out.Bounds(), decoded, decoded.Bounds().Min, xdraw.Src)
+	} else {
+		out = image.NewNRGBA(image.Rect(0, 0, img.Width, img.Height))
+		n := 1
+		if !img.ImageMask {
+			if space == nil {
+				return nil, errUnsupportedImage
+			}
+			n = space.NbColorComponents()
+			if n == 0 {
+				return nil, errUnsupportedImage
+			}
+		}
+		raw.stride = (img.Width*n*raw.bpc + 7) / 8
+		maxValue := Fl(int(1)<<raw.bpc - 1)
+		_, isIndexed := space.(model.ColorSpaceIndexed)
+		converter := colors.NewConverter(space)
+		comps := make([]Fl, n)
+		for y := 0; y < img.Height; y++ {
+			for x := 0; x < img.Width; x++ {
+				for i := range comps {
+					v := Fl(raw.at(y, x*n+i))
+					if !isIndexed && !img.ImageMask {
+						v /= maxValue
+						if i < len(img.Decode) { // map to the decode range
+							d := img.Decode[i]
+							v = d[0] + v*(d[1]-d[0])
+						}
+					}
+					comps[i] = v
+				}
+				var c color.NRGBA
+				if img.ImageMask {
+					// by default, a sample of 0 is painted
+					painted := comps[0] == 0
+					if len(img.Decode) != 0 && img.Decode[0][0] == 1 {
+						painted = !painted
+					}
+					if painted {
+						c = color.NRGBA{fill.R, fill.G, fill.B, 0xFF}
+					}
+				} else {
+					rgb := converter.RGBA(comps)
+					c = color.NRGBA{rgb.R, rgb.G, rgb.B, 0xFF}
+				}
+				out.SetNRGBA(x, y, c)
+			}
+		}
+	}
+
+	if smask != nil && smask.Width > 0 && smask.Height > 0 {
+		alpha, _, err := rawSamples(smask.Image)
+		if err == nil {
+			alpha.stride = (smask.Width*alpha.bpc + 7) / 8
+			maxValue := Fl(int(1)<<alpha.bpc - 1)
+			b := out.Bounds()
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				for x := b.Min.X; x < b.Max.X; x++ {
+					// the mask may have a different resolution
+					sx, sy := (x-b.Min.X)*smask.Width/b.Dx(), (y-b.Min.Y)*smask.Height/b.Dy()
+					i := out.PixOffset(x, y)
+					out.Pix[i+3] = clamp(Fl(alpha.at(sy, sx)) / maxValue)
+				}
+			}
+		}
+	}
+	return out, nil
+}
+
+// drawImage draws `img` in the unit square of the user space.
+// Unsupported images are ignored.
+func (r *renderer) drawImage(img model.Image, space model.ColorSpace, smask *model.ImageSMask, st state) {
+	if st.fillAlpha <= 0 {
+		return
+	}
+	src, err := decodeImage(img, space, smask, st.fill)
+	if err != nil {
+		return
+	}
+	b := src.Bounds()
+	w, h := Fl(b.Dx()), Fl(b.Dy())
+	m := st.ctm
+	// map the image pixels to the unit square (with y going up), then to the device
+	s2d := f64.Aff3{
+		float64(m[0] / w), float64(-m[2] / h), float64(m[2] + m[4]),
+		float64(m[1] / w), float64(-m[3] / h), float64(m[3] + m[5]),
+	}
+	// the source coordinates start at b.Min
+	s2d[2] -= s2d[0]*float64(b.Min.X) + s2d[1]*float64(b.Min.Y)
+	s2d[5] -= s2d[3]*float64(b.Min.X) + s2d[4]*float64(b.Min.Y)
+
+	opts := &xdraw.Options{}
+	if st.clip != nil {
+		opts.DstMask = st.clip
+	}
+	if st.fillAlpha < 1 {
+		opts.SrcMask = image.NewUniform(color.Alpha{A: clamp(st.fillAlpha)})
+	}
+	xdraw.ApproxBiLinear.Transform(r.dst, s2d, src, b, xdraw.Over, opts)
+}
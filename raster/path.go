@@ -0,0 +1,376 @@
+package raster
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/benoitkugler/pdf/model"
+	"golang.org/x/image/vector"
+)
+
+// point is in device space (pixels)
+type point struct{ x, y float64 }
+
+func (p point) add(q point) point     { return point{p.x + q.x, p.y + q.y} }
+func (p point) sub(q point) point     { return point{p.x - q.x, p.y - q.y} }
+func (p point) scale(s float64) point { return point{p.x * s, p.y * s} }
+func (p point) norm() float64         { return math.Hypot(p.x, p.y) }
+func transform(m model.Matrix, x, y Fl) point {
+	dx, dy := m.TransformPoint(x, y)
+	return point{float64(dx), float64(dy)}
+}
+
+type subpath struct {
+	points []point
+	closed bool
+}
+
+// path stores the current path in device space,
+// with the curves flattened into lines.
+type path struct {
+	subpaths []subpath
+}
+
+func (p path) reset() path { return path{subpaths: p.subpaths[:0]} }
+
+func (p *path) last() *subpath {
+	if len(p.subpaths) == 0 {
+		p.subpaths = append(p.subpaths, subpath{points: []point{{}}})
+	}
+	return &p.subpaths[len(p.subpaths)-1]
+}
+
+func (p *path) current() point {
+	sp := p.last()
+	if sp.closed {
+		return sp.points[0]
+	}
+	return sp.points[len(sp.points)-1]
+}
+
+// currentUser returns the current point in user space
+func (p *path) currentUser(ctm model.Matrix) (Fl, Fl) {
+	inv, ok := ctm.Invert()
+	if !ok {
+		return 0, 0
+	}
+	c := p.current()
+	return inv.TransformPoint(Fl(c.x), Fl(c.y))
+}
+
+func (p *path) moveToPoint(pt point) {
+	p.subpaths = append(p.subpaths, subpath{points: []point{pt}})
+}
+
+func (p *path) lineToPoint(pt point) {
+	sp := p.last()
+	if sp.closed { // start a new subpath at the same point
+		p.moveToPoint(sp.points[0])
+		sp = p.last()
+	}
+	sp.points = append(sp.points, pt)
+}
+
+// cubicToPoints flattens the Bézier curve into lines
+func (p *path) cubicToPoints(p1, p2, p3 point) {
+	p0 := p.current()
+	length := p1.sub(p0).norm() + p2.sub(p1).norm() + p3.sub(p2).norm()
+	n := int(math.Ceil(length / 3))
+	if n < 1 {
+		n = 1
+	} else if n > 100 {
+		n = 100
+	}
+	for i := 1; i <= n; i++ {
+		t := float64(i) / float64(n)
+		u := 1 - t
+		a, b, c, d := u*u*u, 3*u*u*t, 3*u*t*t, t*t*t
+		p.lineToPoint(point{
+			a*p0.x + b*p1.x + c*p2.x + d*p3.x,
+			a*p0.y + b*p1.y + c*p2.y + d*p3.y,
+		})
+	}
+}
+
+// quadToPoints elevates the quadratic Bézier curve to a cubic one
+func (p *path) quadToPoints(c, p3 point) {
+	p0 := p.current()
+	p1 := p0.add(c.sub(p0).scale(2. / 3))
+	p2 := p3.add(c.sub(p3).scale(2. / 3))
+	p.cubicToPoints(p1, p2, p3)
+}
+
+func (p *path) moveTo(ctm model.Matrix, x, y Fl) { p.moveToPoint(transform(ctm, x, y)) }
+
+func (p *path) lineTo(ctm model.Matrix, x, y Fl) { p.lineToPoint(transform(ctm, x, y)) }
+
+func (p *path) cubicTo(ctm model.Matrix, x1, y1, x2, y2, x3, y3 Fl) {
+	p.cubicToPoints(transform(ctm, x1, y1), transform(ctm, x2, y2), transform(ctm, x3, y3))
+}
+
+func (p *path) rectangle(ctm model.Matrix, x, y, w, h Fl) {
+	p.moveTo(ctm, x, y)
+	p.lineTo(ctm, x+w, y)
+	p.lineTo(ctm, x+w, y+h)
+	p.lineTo(ctm, x, y+h)
+	p.close()
+}
+
+// close closes the current subpath; the next segment
+// starts a new subpath at the same point
+func (p *path) close() {
+	if len(p.subpaths) == 0 {
+		return
+	}
+	p.last().closed = true
+}
+
+// bounds returns the pixels covered by the path, clipped to `clip`
+func (p path) bounds(clip image.Rectangle) image.Rectangle {
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, sp := range p.subpaths {
+		for _, pt := range sp.points {
+			minX, minY = math.Min(minX, pt.x), math.Min(minY, pt.y)
+			maxX, maxY = math.Max(maxX, pt.x), math.Max(maxY, pt.y)
+		}
+	}
+	if minX > maxX || minY > maxY {
+		return image.Rectangle{}
+	}
+	// avoid overflows
+	minX, minY = math.Max(minX, float64(clip.Min.X)), math.Max(minY, float64(clip.Min.Y))
+	maxX, maxY = math.Min(maxX, float64(clip.Max.X)), math.Min(maxY, float64(clip.Max.Y))
+	r := image.Rect(int(math.Floor(minX)), int(math.Floor(minY)), int(math.Ceil(maxX)), int(math.Ceil(maxY)))
+	return r.Intersect(clip)
+}
+
+// coverage rasterizes the path, clipped to `clip`, and returns its
+// coverage as a mask, or nil if it is empty.
+func (p path) coverage(clip image.Rectangle) *image.Alpha {
+	r := p.bounds(clip)
+	if r.Empty() {
+		return nil
+	}
+	z := vector.NewRasterizer(r.Dx(), r.Dy())
+	ox, oy := float64(r.Min.X), float64(r.Min.Y)
+	for _, sp := range p.subpaths {
+		if len(sp.points) < 2 {
+			continue
+		}
+		z.MoveTo(float32(sp.points[0].x-ox), float32(sp.points[0].y-oy))
+		for _, pt := range sp.points[1:] {
+			z.LineTo(float32(pt.x-ox), float32(pt.y-oy))
+		}
+		z.ClosePath()
+	}
+	mask := image.NewAlpha(r)
+	z.DrawOp = draw.Src
+	z.Draw(mask, r, image.Opaque, image.Point{})
+	return mask
+}
+
+// applyMask multiplies `mask` by `clip` (which may be nil) and `alpha`
+func applyMask(mask, clip *image.Alpha, alpha Fl) {
+	r := mask.Rect
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			i := mask.PixOffset(x, y)
+			v := float64(mask.Pix[i])
+			if clip != nil {
+				v = v * float64(clip.AlphaAt(x, y).A) / 255
+			}
+			mask.Pix[i] = uint8(v*float64(alpha) + 0.5)
+		}
+	}
+}
+
+// fillPath fills the path with `c` (non-zero winding rule)
+func (r *renderer) fillPath(p path, c color.RGBA, alpha Fl, clip *image.Alpha) {
+	if alpha <= 0 {
+		return
+	}
+	mask := p.coverage(r.dst.Bounds())
+	if mask == nil {
+		return
+	}
+	applyMask(mask, clip, alpha)
+	draw.DrawMask(r.dst, mask.Rect, image.NewUniform(c), image.Point{}, mask, mask.Rect.Min, draw.Over)
+}
+
+// clipMask returns the intersection of `clip` (which may be nil)
+// with the area covered by `p`
+func (r *renderer) clipMask(p path, clip *image.Alpha) *image.Alpha {
+	out := image.NewAlpha(r.dst.Bounds())
+	mask := p.coverage(r.dst.Bounds())
+	if mask == nil {
+		return out
+	}
+	applyMask(mask, clip, 1)
+	draw.Draw(out, mask.Rect, mask, mask.Rect.Min, draw.Src)
+	return out
+}
+
+// scaling returns the (average) scaling factor of `m`
+func scaling(m model.Matrix) float64 {
+	return math.Sqrt(math.Abs(float64(m[0]*m[3] - m[1]*m[2])))
+}
+
+// dashed applies the dash pattern to the polyline `pts`
+func dashed(pts []point, dash []float64, phase float64) [][]point {
+	total := 0.
+	for _, d := range dash {
+		total += d
+	}
+	if total <= 0 {
+		return [][]point{pts}
+	}
+	// find the start of the pattern
+	index, on := 0, true
+	phase = math.Mod(phase, total)
+	for phase >= dash[index] {
+		phase -= dash[index]
+		index = (index + 1) % len(dash)
+		on = !on
+	}
+	remaining := dash[index] - phase
+
+	var (
+		out     [][]point
+		current []point
+	)
+	if on {
+		current = []point{pts[0]}
+	}
+	for i := 1; i < len(pts); i++ {
+		start, end := pts[i-1], pts[i]
+		segment := end.sub(start).norm()
+		pos := 0.
+		for segment-pos > remaining {
+			pos += remaining
+			pt := start.add(end.sub(start).scale(pos / segment))
+			if on {
+				out = append(out, append(current, pt))
+				current = nil
+			} else {
+				current = []point{pt}
+			}
+			on = !on
+			index = (index + 1) % len(dash)
+			remaining = dash[index]
+		}
+		remaining -= segment - pos
+		if on {
+			current = append(current, end)
+		}
+	}
+	if on && len(current) > 1 {
+		out = append(out, current)
+	}
+	return out
+}
+
+// disc appends a polygon approximating a disc,
+// with the same orientation as the stroke segments
+func (p *path) disc(center point, radius float64) {
+	n := int(math.Ceil(radius * 2))
+	if n < 8 {
+		n = 8
+	} else if n > 64 {
+		n = 64
+	}
+	for i := 0; i < n; i++ {
+		angle := -2 * math.Pi * float64(i) / float64(n)
+		pt := point{center.x + radius*math.Cos(angle), center.y + radius*math.Sin(angle)}
+		if i == 0 {
+			p.moveToPoint(pt)
+		} else {
+			p.lineToPoint(pt)
+		}
+	}
+}
+
+// strokePolyline appends the outline of the stroked polyline to `out`
+func strokePolyline(out *path, pts []point, closed bool, halfWidth float64, lineCap uint8) {
+	// remove the zero length segments
+	filtered := pts[:1:1]
+	for _, pt := range pts[1:] {
+		if pt.sub(filtered[len(filtered)-1]).norm() > 1e-6 {
+			filtered = append(filtered, pt)
+		}
+	}
+	pts = filtered
+	if len(pts) == 1 {
+		if lineCap == 1 { // dot
+			out.disc(pts[0], halfWidth)
+		}
+		return
+	}
+	for i := 1; i < len(pts); i++ {
+		p0, p1 := pts[i-1], pts[i]
+		d := p1.sub(p0)
+		d = d.scale(1 / d.norm())
+		if lineCap == 2 && !closed { // projecting square caps
+			if i == 1 {
+				p0 = p0.sub(d.scale(halfWidth))
+			}
+			if i == len(pts)-1 {
+				p1 = p1.add(d.scale(halfWidth))
+			}
+		}
+		n := point{-d.y, d.x}.scale(halfWidth)
+		out.moveToPoint(p0.add(n))
+		out.lineToPoint(p1.add(n))
+		out.lineToPoint(p1.sub(n))
+		out.lineToPoint(p0.sub(n))
+	}
+	// round joins
+	start, end := 1, len(pts)-1
+	if closed || lineCap == 1 {
+		start, end = 0, len(pts)
+	}
+	for i := start; i < end; i++ {
+		out.disc(pts[i], halfWidth)
+	}
+}
+
+// strokePath strokes `p` with the current stroke color, line width and dash pattern.
+func (r *renderer) strokePath(p path, st state) {
+	if st.strokeAlpha <= 0 {
+		return
+	}
+	scale := scaling(st.ctm)
+	w := float64(st.lineWidth) * scale
+	if w < 1 { // thinnest line
+		w = 1
+	}
+	var dash []float64
+	for _, d := range st.dash.Array {
+		dash = append(dash, float64(d)*scale)
+	}
+	phase := float64(st.dash.Phase) * scale
+
+	var outline path
+	for _, sp := range p.subpaths {
+		pts := sp.points
+		if len(pts) == 1 && st.lineCap == 1 {
+			strokePolyline(&outline, pts, false, w/2, st.lineCap)
+			continue
+		}
+		if len(pts) < 2 {
+			continue
+		}
+		if sp.closed {
+			pts = append(pts[:len(pts):len(pts)], pts[0])
+		}
+		if len(dash) == 0 {
+			strokePolyline(&outline, pts, sp.closed, w/2, st.lineCap)
+			continue
+		}
+		for _, part := range dashed(pts, dash, phase) {
+			strokePolyline(&outline, part, false, w/2, st.lineCap)
+		}
+	}
+	r.fillPath(outline, st.stroke, st.strokeAlpha, st.clip)
+}
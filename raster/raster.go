@@ -0,0 +1,427 @@
+// Package raster implements a minimal rasterizer, which interprets
+// the content streams of a page and draws them into an image.
+//
+// It is intended to check the output of this library (for instance the
+// appearance streams generated when filling forms) without external tools,
+// and thus favors simplicity over accuracy. In particular:
+//   - paths are filled with the non-zero winding rule (even-odd rule is approximated)
+//     and line joins are always rounded
+//   - only device color spaces (and the spaces with a known number of components)
+//     are supported; patterns and shadings are ignored
+//   - images are drawn for the raw and DCT encoded data only
+//   - glyphs are drawn from the embedded TrueType, OpenType, Type1 and CFF fonts, and from Type3 fonts;
+//     the other fonts are replaced by the Go fonts, using their Unicode values
+//   - transparency groups and blend modes are ignored, only the constant alpha is used
+//
+// Unsupported features are silently ignored, so that a page is always rendered,
+// even partially.
+package raster
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+
+	cs "github.com/benoitkugler/pdf/contentstream"
+	"github.com/benoitkugler/pdf/fonts"
+	"github.com/benoitkugler/pdf/model"
+	"github.com/benoitkugler/pdf/reader/parser"
+)
+
+type Fl = model.Fl
+
+// maximum nesting of form XObjects and Type3 glyphs
+const maxFormDepth = 20
+
+var identity = model.Matrix{1, 0, 0, 1, 0, 0}
+
+// decodeBuffers stores the buffers used to decode
+// the content streams, which are discarded once interpreted
+var decodeBuffers model.BufferPool
+
+// Options controls the rendering of a page.
+type Options struct {
+	// DPI is the resolution of the image, defaulting to 72,
+	// that is one pixel for each user space unit.
+	DPI Fl
+	// Background is the color of the page, defaulting to white.
+	Background color.Color
+	// SkipAnnotations disables the drawing of the normal
+	// appearance of the annotations (including the form widgets).
+	SkipAnnotations bool
+	// Cache is an optional cache used to decode the forms
+	// and Type3 glyphs. DocumentPage defaults to the document cache.
+	Cache *model.StreamCache
+}
+
+// Page renders `page`, whose inherited attributes (resources and media box)
+// must be resolved, as done by model.PageTree.FlattenInherit.
+// The visible area of the page (its crop box) is drawn, taking
+// the rotation of the page into account.
+func Page(page model.PageObject, opts Options) (*image.RGBA, error) {
+	dpi := opts.DPI
+	if dpi <= 0 {
+		dpi = 72
+	}
+	base, err := page.UserToDevice(dpi)
+	if err != nil {
+		return nil, err
+	}
+	box := page.CropBox
+	if box == nil {
+		box = page.MediaBox
+	}
+	size := box.Transform(base) // (0, 0, w, h) in device space
+	w, h := int(size.Width()+0.5), int(size.Height()+0.5)
+	if w <= 0 || h <= 0 {
+		return nil, fmt.Errorf("invalid page box %v", *box)
+	}
+
+	background := opts.Background
+	if background == nil {
+		background = color.White
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+
+	var res model.ResourcesDict
+	if page.Resources != nil {
+		res = *page.Resources
+	}
+	content, err := page.DecodeAllContentsTo(decodeBuffers.Get())
+	if err != nil {
+		return nil, err
+	}
+	r := renderer{dst: dst, fonts: make(map[*model.FontDict]*font), cache: opts.Cache}
+	err = r.processContent(content, res, newState(base), 0)
+	decodeBuffers.Put(content)
+	if err != nil {
+		return nil, err
+	}
+
+	if !opts.SkipAnnotations {
+		for _, annot := range page.Annots {
+			if err = r.drawAnnotation(annot, base); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return dst, nil
+}
+
+// DocumentPage renders the page at `index` (0-based) of `doc`.
+func DocumentPage(doc *model.Document, index int, opts Options) (*image.RGBA, error) {
+	pages := doc.Catalog.Pages.FlattenInherit()
+	if index < 0 || index >= len(pages) {
+		return nil, fmt.Errorf("invalid page index %d (for %d pages)", index, len(pages))
+	}
+	if opts.Cache == nil {
+		opts.Cache = doc.Cache
+	}
+	return Page(pages[index], opts)
+}
+
+// state is the part of the graphic state used for rendering
+type state struct {
+	// maps user space to pixels
+	ctm model.Matrix
+	// clip is the coverage of the clipping path, or nil
+	clip *image.Alpha
+
+	fillSpace, strokeSpace model.ColorSpace
+	fill, stroke           color.RGBA
+	fillAlpha, strokeAlpha Fl
+
+	lineWidth Fl
+	lineCap   uint8
+	dash      model.DashPattern
+
+	font                          *font
+	fontSize                      Fl
+	charSpace, wordSpace, leading Fl
+	scale                         Fl // horizontal scaling, as a fraction
+	rise                          Fl
+	render                        uint8 // text rendering mode
+}
+
+func newState(ctm model.Matrix) state {
+	black := color.RGBA{A: 0xFF}
+	return state{
+		ctm:       ctm,
+		fillSpace: model.ColorSpaceGray, strokeSpace: model.ColorSpaceGray,
+		fill: black, stroke: black,
+		fillAlpha: 1, strokeAlpha: 1,
+		lineWidth: 1,
+		scale:     1,
+	}
+}
+
+// text position, valid between BT and ET
+type textState struct {
+	tm, tlm model.Matrix
+}
+
+func (ts *textState) move(x, y Fl) {
+	ts.tlm = model.Matrix{1, 0, 0, 1, x, y}.Multiply(ts.tlm)
+	ts.tm = ts.tlm
+}
+
+type renderer struct {
+	dst   *image.RGBA
+	fonts map[*model.FontDict]*font
+	cache *model.StreamCache // optional
+}
+
+// decode returns the content of `s`, using the cache if any.
+// `release` must be called once the content is not used anymore.
+func (r *renderer) decode(s model.Stream) (content []byte, release func(), err error) {
+	if r.cache != nil {
+		content, err = r.cache.Decode(s)
+		return content, func() {}, err
+	}
+	content, err = s.DecodeTo(decodeBuffers.Get())
+	return content, func() { decodeBuffers.Put(content) }, err
+}
+
+// setExtGState applies the supported entries of `gs`
+func (st *state) setExtGState(gs *model.GraphicState) {
+	if gs == nil {
+		return
+	}
+	if gs.LW != 0 {
+		st.lineWidth = gs.LW
+	}
+	if lc, ok := gs.LC.(model.ObjInt); ok {
+		st.lineCap = uint8(lc)
+	}
+	if gs.D != nil {
+		st.dash = *gs.D
+	}
+	if ca, ok := gs.CA.(model.ObjFloat); ok {
+		st.strokeAlpha = Fl(ca)
+	}
+	if ca, ok := gs.Ca.(model.ObjFloat); ok {
+		st.fillAlpha = Fl(ca)
+	}
+}
+
+// processContent interprets `content`, starting with the graphic state `st`.
+func (r *renderer) processContent(content []byte, res model.ResourcesDict, st state, depth int) error {
+	ops, err := parser.ParseContent(content, res.ColorSpace)
+	if err != nil {
+		return err
+	}
+
+	var (
+		stack    []state
+		text     textState
+		path     path
+		clipping bool // W or W* has been used
+	)
+	// paint ends the current path
+	paint := func(fill, stroke, close bool) {
+		if close {
+			path.close()
+		}
+		if fill {
+			r.fillPath(path, st.fill, st.fillAlpha, st.clip)
+		}
+		if stroke {
+			r.strokePath(path, st)
+		}
+		if clipping {
+			st.clip = r.clipMask(path, st.clip)
+			clipping = false
+		}
+		path = path.reset()
+	}
+	for _, op := range ops {
+		switch op := op.(type) {
+		case cs.OpSave:
+			stack = append(stack, st)
+		case cs.OpRestore:
+			if len(stack) != 0 {
+				st = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+		case cs.OpConcat:
+			st.ctm = op.Matrix.Multiply(st.ctm)
+		case cs.OpSetExtGState:
+			st.setExtGState(res.ExtGState[op.Dict])
+		case cs.OpSetLineWidth:
+			st.lineWidth = op.W
+		case cs.OpSetLineCap:
+			st.lineCap = op.Style
+		case cs.OpSetDash:
+			st.dash = op.Dash
+
+		// colors
+		case cs.OpSetFillGray:
+			st.fillSpace, st.fill = model.ColorSpaceGray, deviceColor(model.ColorSpaceGray, []Fl{op.G})
+		case cs.OpSetStrokeGray:
+			st.strokeSpace, st.stroke = model.ColorSpaceGray, deviceColor(model.ColorSpaceGray, []Fl{op.G})
+		case cs.OpSetFillRGBColor:
+			st.fillSpace, st.fill = model.ColorSpaceRGB, deviceColor(model.ColorSpaceRGB, []Fl{op.R, op.G, op.B})
+		case cs.OpSetStrokeRGBColor:
+			st.strokeSpace, st.stroke = model.ColorSpaceRGB, deviceColor(model.ColorSpaceRGB, []Fl{op.R, op.G, op.B})
+		case cs.OpSetFillCMYKColor:
+			st.fillSpace, st.fill = model.ColorSpaceCMYK, deviceColor(model.ColorSpaceCMYK, []Fl{op.C, op.M, op.Y, op.K})
+		case cs.OpSetStrokeCMYKColor:
+			st.strokeSpace, st.stroke = model.ColorSpaceCMYK, deviceColor(model.ColorSpaceCMYK, []Fl{op.C, op.M, op.Y, op.K})
+		case cs.OpSetFillColorSpace:
+			st.fillSpace = resolveColorSpace(res, op.ColorSpace)
+			st.fill = initialColor(st.fillSpace)
+		case cs.OpSetStrokeColorSpace:
+			st.strokeSpace = resolveColorSpace(res, op.ColorSpace)
+			st.stroke = initialColor(st.strokeSpace)
+		case cs.OpSetFillColor:
+			st.fill = deviceColor(st.fillSpace, op.Color)
+		case cs.OpSetStrokeColor:
+			st.stroke = deviceColor(st.strokeSpace, op.Color)
+		case cs.OpSetFillColorN:
+			st.fill = deviceColor(st.fillSpace, op.Color)
+		case cs.OpSetStrokeColorN:
+			st.stroke = deviceColor(st.strokeSpace, op.Color)
+
+		// paths
+		case cs.OpMoveTo:
+			path.moveTo(st.ctm, op.X, op.Y)
+		case cs.OpLineTo:
+			path.lineTo(st.ctm, op.X, op.Y)
+		case cs.OpCubicTo:
+			path.cubicTo(st.ctm, op.X1, op.Y1, op.X2, op.Y2, op.X3, op.Y3)
+		case cs.OpCurveTo1:
+			x, y := path.currentUser(st.ctm)
+			path.cubicTo(st.ctm, x, y, op.X2, op.Y2, op.X3, op.Y3)
+		case cs.OpCurveTo:
+			path.cubicTo(st.ctm, op.X1, op.Y1, op.X3, op.Y3, op.X3, op.Y3)
+		case cs.OpRectangle:
+			path.rectangle(st.ctm, op.X, op.Y, op.W, op.H)
+		case cs.OpClosePath:
+			path.close()
+		case cs.OpClip, cs.OpEOClip:
+			clipping = true
+		case cs.OpEndPath:
+			paint(false, false, false)
+		case cs.OpFill, cs.OpEOFill:
+			paint(true, false, false)
+		case cs.OpStroke:
+			paint(false, true, false)
+		case cs.OpCloseStroke:
+			paint(false, true, true)
+		case cs.OpFillStroke, cs.OpEOFillStroke:
+			paint(true, true, false)
+		case cs.OpCloseFillStroke, cs.OpCloseEOFillStroke:
+			paint(true, true, true)
+
+		// text
+		case cs.OpSetFont:
+			st.font = r.font(res.Font[op.Font])
+			st.fontSize = op.Size
+		case cs.OpSetCharSpacing:
+			st.charSpace = op.CharSpace
+		case cs.OpSetWordSpacing:
+			st.wordSpace = op.WordSpace
+		case cs.OpSetTextLeading:
+			st.leading = op.L
+		case cs.OpSetHorizScaling:
+			st.scale = op.Scale / 100
+		case cs.OpSetTextRise:
+			st.rise = op.Rise
+		case cs.OpSetTextRender:
+			st.render = op.Render
+		case cs.OpBeginText:
+			text = textState{tm: identity, tlm: identity}
+		case cs.OpSetTextMatrix:
+			text = textState{tm: op.Matrix, tlm: op.Matrix}
+		case cs.OpTextMove:
+			text.move(op.X, op.Y)
+		case cs.OpTextMoveSet:
+			st.leading = -op.Y
+			text.move(op.X, op.Y)
+		case cs.OpTextNextLine:
+			text.move(0, -st.leading)
+		case cs.OpShowText:
+			err = r.showText(st, &text, res, []fonts.TextSpaced{{CharCodes: []byte(op.Text)}}, depth)
+		case cs.OpShowSpaceText:
+			err = r.showText(st, &text, res, op.Texts, depth)
+		case cs.OpMoveShowText:
+			text.move(0, -st.leading)
+			err = r.showText(st, &text, res, []fonts.TextSpaced{{CharCodes: []byte(op.Text)}}, depth)
+		case cs.OpMoveSetShowText:
+			st.wordSpace, st.charSpace = op.WordSpacing, op.CharacterSpacing
+			text.move(0, -st.leading)
+			err = r.showText(st, &text, res, []fonts.TextSpaced{{CharCodes: []byte(op.Text)}}, depth)
+
+		// external objects
+		case cs.OpXObject:
+			switch obj := res.XObject[op.XObject].(type) {
+			case *model.XObjectForm:
+				err = r.drawForm(obj, st, depth)
+			case *model.XObjectImage:
+				r.drawImage(obj.Image, obj.ColorSpace, obj.SMask, st)
+			}
+		case cs.OpBeginImage:
+			var space model.ColorSpace
+			switch c := op.ColorSpace.(type) {
+			case cs.ImageColorSpaceName:
+				space = resolveColorSpace(res, c.ColorSpaceName)
+			case cs.ImageColorSpaceIndexed:
+				space = c.ToColorSpace()
+			}
+			r.drawImage(op.Image, space, nil, st)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// drawForm renders `form`, clipped to its bounding box
+func (r *renderer) drawForm(form *model.XObjectForm, st state, depth int) error {
+	if depth >= maxFormDepth {
+		return fmt.Errorf("maximum form nesting depth (%d) exceeded", maxFormDepth)
+	}
+	content, release, err := r.decode(form.Stream)
+	if err != nil {
+		return err
+	}
+	defer release()
+	matrix := form.Matrix
+	if matrix == (model.Matrix{}) {
+		matrix = identity
+	}
+	st.ctm = matrix.Multiply(st.ctm)
+	box := form.BBox
+	var bbox path
+	bbox.rectangle(st.ctm, box.Llx, box.Lly, box.Width(), box.Height())
+	st.clip = r.clipMask(bbox, st.clip)
+	return r.processContent(content, form.Resources, st, depth+1)
+}
+
+// drawAnnotation renders the normal appearance of `annot`,
+// as described in 12.5.5 - Appearance Streams
+func (r *renderer) drawAnnotation(annot *model.AnnotationDict, base model.Matrix) error {
+	if annot.AP == nil || annot.F&(model.AHidden|model.ANoView) != 0 {
+		return nil
+	}
+	form := annot.AP.N.Appearance(annot.AS)
+	if form == nil {
+		return nil
+	}
+	matrix := form.Matrix
+	if matrix == (model.Matrix{}) {
+		matrix = identity
+	}
+	// bounding box of the transformed form
+	transformed := form.BBox.Transform(matrix)
+	if transformed.Width() == 0 || transformed.Height() == 0 {
+		return nil
+	}
+	rect := annot.Rect
+	sx, sy := rect.Width()/transformed.Width(), rect.Height()/transformed.Height()
+	a := model.Matrix{sx, 0, 0, sy, rect.Llx - transformed.Llx*sx, rect.Lly - transformed.Lly*sy}
+	return r.drawForm(form, newState(a.Multiply(base)), 0)
+}
@@ -0,0 +1,219 @@
+package raster
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"testing"
+
+	"github.com/benoitkugler/pdf/fonts/standardfonts"
+	"github.com/benoitkugler/pdf/model"
+)
+
+func newPage(content string, res model.ResourcesDict) model.PageObject {
+	return model.PageObject{
+		MediaBox:  &model.Rectangle{Urx: 100, Ury: 100},
+		Resources: &res,
+		Contents:  []model.ContentStream{{Stream: model.Stream{Content: []byte(content)}}},
+	}
+}
+
+func render(t *testing.T, page model.PageObject, opts Options) *image.RGBA {
+	t.Helper()
+	img, err := Page(page, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func assertColor(t *testing.T, img *image.RGBA, x, y int, exp color.RGBA) {
+	t.Helper()
+	if got := img.RGBAAt(x, y); got != exp {
+		t.Fatalf("at (%d, %d): expected %v, got %v", x, y, exp, got)
+	}
+}
+
+var (
+	white = color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}
+	black = color.RGBA{0, 0, 0, 0xFF}
+	red   = color.RGBA{0xFF, 0, 0, 0xFF}
+)
+
+func TestPaths(t *testing.T) {
+	page := newPage(`1 0 0 rg 10 10 30 20 re f
+	5 w 0 0 1 RG 0 80 m 100 80 l S
+	q 60 0 40 40 re W n 0 g 50 0 50 50 re f Q`, model.NewResourcesDict())
+	img := render(t, page, Options{})
+	if img.Bounds() != image.Rect(0, 0, 100, 100) {
+		t.Fatalf("unexpected bounds %v", img.Bounds())
+	}
+	// the y axis goes down in the image
+	assertColor(t, img, 20, 80, red)
+	assertColor(t, img, 20, 60, white)
+	assertColor(t, img, 50, 20, color.RGBA{0, 0, 0xFF, 0xFF})
+	assertColor(t, img, 50, 25, white)
+	// clipped fill
+	assertColor(t, img, 70, 80, black)
+	assertColor(t, img, 55, 80, white)
+	assertColor(t, img, 70, 55, white)
+
+	img = render(t, page, Options{DPI: 144})
+	if img.Bounds() != image.Rect(0, 0, 200, 200) {
+		t.Fatalf("unexpected bounds %v", img.Bounds())
+	}
+	assertColor(t, img, 40, 160, red)
+}
+
+func TestDash(t *testing.T) {
+	page := newPage(`4 w [10 10] 0 d 0 50 m 100 50 l S`, model.NewResourcesDict())
+	img := render(t, page, Options{})
+	assertColor(t, img, 5, 50, black)
+	assertColor(t, img, 15, 50, white)
+	assertColor(t, img, 25, 50, black)
+}
+
+func TestRotation(t *testing.T) {
+	page := newPage(`1 0 0 rg 0 0 10 10 re f`, model.NewResourcesDict())
+	page.MediaBox = &model.Rectangle{Urx: 100, Ury: 50}
+	page.Rotate = model.NewRotation(90)
+	img := render(t, page, Options{})
+	if img.Bounds() != image.Rect(0, 0, 50, 100) {
+		t.Fatalf("unexpected bounds %v", img.Bounds())
+	}
+	// the bottom-left corner is now the top-left corner
+	assertColor(t, img, 5, 5, red)
+	assertColor(t, img, 45, 95, white)
+}
+
+func TestImage(t *testing.T) {
+	res := model.NewResourcesDict()
+	img := &model.XObjectImage{ColorSpace: model.ColorSpaceRGB}
+	img.Width, img.Height, img.BitsPerComponent = 2, 1, 8
+	img.Content = []byte{0xFF, 0, 0, 0, 0, 0xFF}
+	res.XObject["Im"] = img
+	page := newPage(`q 100 0 0 50 0 0 cm /Im Do Q`, res)
+	out := render(t, page, Options{})
+	assertColor(t, out, 10, 75, red)
+	assertColor(t, out, 90, 75, color.RGBA{0, 0, 0xFF, 0xFF})
+	assertColor(t, out, 50, 25, white)
+}
+
+func TestText(t *testing.T) {
+	res := model.NewResourcesDict()
+	res.Font["F1"] = &model.FontDict{Subtype: standardfonts.Helvetica.WesternType1Font()}
+	page := newPage(`BT /F1 80 Tf 10 10 Td (H) Tj ET`, res)
+	img := render(t, page, Options{})
+	// the left stem of the H
+	dark := 0
+	for y := 30; y < 80; y++ {
+		for x := 10; x < 30; x++ {
+			if img.RGBAAt(x, y).R < 0x80 {
+				dark++
+			}
+		}
+	}
+	if dark < 100 {
+		t.Fatalf("text not drawn (%d dark pixels)", dark)
+	}
+
+	// invisible text
+	page = newPage(`BT 3 Tr /F1 80 Tf 10 10 Td (H) Tj ET`, res)
+	img = render(t, page, Options{})
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 100; x++ {
+			if img.RGBAAt(x, y) != white {
+				t.Fatal("invisible text should not be drawn")
+			}
+		}
+	}
+}
+
+func TestEmbeddedText(t *testing.T) {
+	file, err := os.ReadFile("../fonts/test/CalligrapherRegular.pfb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := model.NewResourcesDict()
+	res.Font["F1"] = &model.FontDict{Subtype: model.FontType1{
+		BaseFont:       "CalligrapherRegular",
+		FontDescriptor: model.FontDescriptor{FontFile: &model.FontFile{Stream: model.Stream{Content: file}}},
+	}}
+	page := newPage(`BT /F1 80 Tf 10 10 Td (I) Tj ET`, res)
+	img := render(t, page, Options{})
+	dark := 0
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 100; x++ {
+			if img.RGBAAt(x, y).R < 0x80 {
+				dark++
+			}
+		}
+	}
+	if dark < 100 {
+		t.Fatalf("text not drawn (%d dark pixels)", dark)
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	form := &model.XObjectForm{
+		ContentStream: model.ContentStream{Stream: model.Stream{Content: []byte("1 0 0 rg 0 0 10 10 re f")}},
+		BBox:          model.Rectangle{Urx: 10, Ury: 10},
+	}
+	page := newPage("", model.NewResourcesDict())
+	page.Annots = []*model.AnnotationDict{{
+		BaseAnnotation: model.BaseAnnotation{
+			Rect: model.Rectangle{Llx: 50, Lly: 50, Urx: 70, Ury: 70},
+			AP:   &model.AppearanceDict{N: model.AppearanceEntry{"": form}},
+		},
+		Subtype: model.AnnotationSquare{},
+	}}
+	img := render(t, page, Options{})
+	// the appearance is scaled to the annotation rectangle
+	assertColor(t, img, 52, 48, red)
+	assertColor(t, img, 68, 32, red)
+	assertColor(t, img, 45, 48, white)
+
+	img = render(t, page, Options{SkipAnnotations: true})
+	assertColor(t, img, 60, 40, white)
+
+	// the appearance is decoded once with a cache
+	cache := &model.StreamCache{}
+	for range [2]int{} {
+		img = render(t, page, Options{Cache: cache})
+		assertColor(t, img, 52, 48, red)
+	}
+	if cache.Len() != 1 {
+		t.Fatalf("expected 1 cached stream, got %d", cache.Len())
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	page := newPage(`1 0 0 rg 0 0 100 100 re f`, model.NewResourcesDict())
+	page.MediaBox = &model.Rectangle{Urx: 200, Ury: 100}
+	var doc model.Document
+	doc.Catalog.Pages.Kids = []model.PageNode{&page}
+	if err := AddThumbnails(&doc, 20); err != nil {
+		t.Fatal(err)
+	}
+	thumb := page.Thumb
+	if thumb == nil || thumb.Width != 20 || thumb.Height != 10 {
+		t.Fatalf("unexpected thumbnail %v", thumb)
+	}
+	samples, err := thumb.Stream.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 20*10*3 {
+		t.Fatalf("unexpected samples length %d", len(samples))
+	}
+	// left half is red, right half is white
+	if r := samples[3*(5*20+2) : 3*(5*20+2)+3]; r[0] != 0xFF || r[1] != 0 {
+		t.Fatalf("unexpected color %v", r)
+	}
+	if w := samples[3*(5*20+17) : 3*(5*20+17)+3]; w[0] != 0xFF || w[1] != 0xFF {
+		t.Fatalf("unexpected color %v", w)
+	}
+	if n := doc.RemoveThumbnails(); n != 1 || page.Thumb != nil {
+		t.Fatal("thumbnail not removed")
+	}
+}
@@ -0,0 +1,248 @@
+package raster
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/benoitkugler/pdf/fonts"
+	"github.com/benoitkugler/pdf/fonts/simpleencodings"
+	"github.com/benoitkugler/pdf/model"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/gomonobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// fallback fonts, used when the font is not embedded
+// or not supported
+var (
+	goFontsOnce sync.Once
+	goFonts     map[string]*sfnt.Font
+)
+
+func fallbackFont(fixedPitch, bold, italic bool) *sfnt.Font {
+	goFontsOnce.Do(func() {
+		goFonts = make(map[string]*sfnt.Font)
+		for name, data := range map[string][]byte{
+			"regular": goregular.TTF, "bold": gobold.TTF, "italic": goitalic.TTF, "bolditalic": gobolditalic.TTF,
+			"mono": gomono.TTF, "monobold": gomonobold.TTF,
+		} {
+			goFonts[name], _ = sfnt.Parse(data) // the Go fonts are valid
+		}
+	})
+	if fixedPitch {
+		if bold {
+			return goFonts["monobold"]
+		}
+		return goFonts["mono"]
+	}
+	switch {
+	case bold && italic:
+		return goFonts["bolditalic"]
+	case bold:
+		return goFonts["bold"]
+	case italic:
+		return goFonts["italic"]
+	default:
+		return goFonts["regular"]
+	}
+}
+
+// font caches the information required to draw the glyphs of a font
+type font struct {
+	decoder fonts.Decoder
+
+	// Type3 fonts
+	type3    *model.FontType3
+	encoding simpleencodings.Encoding
+
+	// outlines is the embedded font, or nil
+	outlines *fonts.Outlines
+
+	fallback *sfnt.Font
+	buffer   sfnt.Buffer
+}
+
+func (r *renderer) font(dict *model.FontDict) *font {
+	if f, ok := r.fonts[dict]; ok {
+		return f
+	}
+	f := &font{decoder: fonts.NewDecoder(dict)}
+	var (
+		desc model.FontDescriptor
+		name string
+	)
+	if dict != nil {
+		name = string(dict.Subtype.FontName())
+		switch ft := dict.Subtype.(type) {
+		case model.FontType1:
+			desc = ft.FontDescriptor
+		case model.FontTrueType:
+			desc = ft.FontDescriptor
+		case model.FontType3:
+			f.type3 = &ft
+			f.encoding = fonts.ResolveSimpleEncoding(ft)
+		case model.FontType0:
+			desc = ft.DescendantFonts.FontDescriptor
+		}
+		if f.type3 == nil && desc.FontFile != nil {
+			f.outlines, _ = fonts.LoadOutlines(dict) // nil for invalid or unsupported fonts
+		}
+	}
+	if f.outlines == nil && f.type3 == nil {
+		lower := strings.ToLower(name)
+		fixedPitch := desc.Flags&model.FixedPitch != 0 || strings.Contains(lower, "courier") || strings.Contains(lower, "mono")
+		bold := desc.Flags&model.ForceBold != 0 || strings.Contains(lower, "bold") || strings.Contains(lower, "black")
+		italic := desc.Flags&model.Italic != 0 || desc.ItalicAngle != 0 ||
+			strings.Contains(lower, "italic") || strings.Contains(lower, "oblique")
+		f.fallback = fallbackFont(fixedPitch, bold, italic)
+	}
+	r.fonts[dict] = f
+	return f
+}
+
+// glyphIndex returns the glyph to use in the fallback font for `glyph`
+func (f *font) glyphIndex(glyph fonts.Glyph) sfnt.GlyphIndex {
+	r := firstRune(glyph.Text)
+	if r == 0 {
+		return 0
+	}
+	gid, _ := f.fallback.GlyphIndex(&f.buffer, r)
+	return gid
+}
+
+func firstRune(text []rune) rune {
+	if len(text) == 0 {
+		return 0
+	}
+	return text[0]
+}
+
+// appendGlyph adds the outline of `glyph` to `p`, where
+// `trm` maps the glyph space (with units of 1 em) to the device.
+func (f *font) appendGlyph(p *path, glyph fonts.Glyph, trm model.Matrix) {
+	if f.outlines != nil {
+		segments, _ := f.outlines.Glyph(glyph) // missing glyphs are not drawn
+		appendSegments(p, segments, trm)
+		return
+	}
+	if f.fallback == nil {
+		return
+	}
+	gid := f.glyphIndex(glyph)
+	if gid == 0 {
+		return
+	}
+	upem := f.fallback.UnitsPerEm()
+	// with ppem equal to the units per em, the segments are expressed in font units
+	segments, err := f.fallback.LoadGlyph(&f.buffer, gid, fixed.I(int(upem)), nil)
+	if err != nil {
+		return
+	}
+	scale := 1 / (64 * Fl(upem))
+	pt := func(q fixed.Point26_6) point {
+		return transform(trm, Fl(q.X)*scale, -Fl(q.Y)*scale) // the sfnt Y axis goes down
+	}
+	for _, seg := range segments {
+		switch seg.Op {
+		case sfnt.SegmentOpMoveTo:
+			p.moveToPoint(pt(seg.Args[0]))
+		case sfnt.SegmentOpLineTo:
+			p.lineToPoint(pt(seg.Args[0]))
+		case sfnt.SegmentOpQuadTo:
+			p.quadToPoints(pt(seg.Args[0]), pt(seg.Args[1]))
+		case sfnt.SegmentOpCubeTo:
+			p.cubicToPoints(pt(seg.Args[0]), pt(seg.Args[1]), pt(seg.Args[2]))
+		}
+	}
+}
+
+// appendSegments adds the glyph outline `segments` to `p`, where
+// `trm` maps the glyph space (with units of 1 em) to the device.
+func appendSegments(p *path, segments []fonts.Segment, trm model.Matrix) {
+	pt := func(q fonts.Point) point {
+		return transform(trm, q.X/1000, q.Y/1000) // the outlines use thousandths of em
+	}
+	for _, seg := range segments {
+		switch seg.Op {
+		case fonts.SegmentMoveTo:
+			p.moveToPoint(pt(seg.Args[0]))
+		case fonts.SegmentLineTo:
+			p.lineToPoint(pt(seg.Args[0]))
+		case fonts.SegmentQuadTo:
+			p.quadToPoints(pt(seg.Args[0]), pt(seg.Args[1]))
+		case fonts.SegmentCubeTo:
+			p.cubicToPoints(pt(seg.Args[0]), pt(seg.Args[1]), pt(seg.Args[2]))
+		}
+	}
+}
+
+// showText draws the glyphs of `texts` and updates the text matrix.
+func (r *renderer) showText(st state, text *textState, res model.ResourcesDict, texts []fonts.TextSpaced, depth int) error {
+	if st.font == nil {
+		st.font = r.font(nil)
+	}
+	var glyphs path
+	for _, ts := range texts {
+		for _, glyph := range st.font.decoder.Decode(ts.CharCodes) {
+			trm := model.Matrix{st.fontSize * st.scale, 0, 0, st.fontSize, 0, st.rise}.Multiply(text.tm.Multiply(st.ctm))
+			if st.render != 3 && st.render != 7 { // not invisible
+				if ft := st.font.type3; ft != nil {
+					if err := r.drawType3Glyph(ft, st.font.encoding[glyph.Code[0]], trm, st, res, depth); err != nil {
+						return err
+					}
+				} else {
+					st.font.appendGlyph(&glyphs, glyph, trm)
+				}
+			}
+
+			tx := glyph.Width*st.fontSize/1000 + st.charSpace
+			if len(glyph.Code) == 1 && glyph.Code[0] == ' ' {
+				tx += st.wordSpace
+			}
+			text.tm = model.Matrix{1, 0, 0, 1, tx * st.scale, 0}.Multiply(text.tm)
+		}
+		if ts.SpaceSubtractedAfter != 0 {
+			tx := -Fl(ts.SpaceSubtractedAfter) / 1000 * st.fontSize * st.scale
+			text.tm = model.Matrix{1, 0, 0, 1, tx, 0}.Multiply(text.tm)
+		}
+	}
+
+	switch st.render % 4 {
+	case 0:
+		r.fillPath(glyphs, st.fill, st.fillAlpha, st.clip)
+	case 1:
+		r.strokePath(glyphs, st)
+	case 2:
+		r.fillPath(glyphs, st.fill, st.fillAlpha, st.clip)
+		r.strokePath(glyphs, st)
+	}
+	return nil
+}
+
+// drawType3Glyph interprets the glyph procedure for `name`
+func (r *renderer) drawType3Glyph(ft *model.FontType3, name string, trm model.Matrix, st state, res model.ResourcesDict, depth int) error {
+	proc, ok := ft.CharProcs[model.Name(name)]
+	if !ok {
+		return nil
+	}
+	if depth >= maxFormDepth {
+		return nil
+	}
+	content, release, err := r.decode(proc.Stream)
+	if err != nil {
+		return nil // ignore invalid glyphs
+	}
+	defer release()
+	glyphRes := ft.Resources
+	if glyphRes.Font == nil && glyphRes.XObject == nil && glyphRes.ExtGState == nil {
+		glyphRes = res
+	}
+	st.ctm = ft.FontMatrix.Multiply(trm)
+	st.render = 0
+	return r.processContent(content, glyphRes, st, depth+1)
+}
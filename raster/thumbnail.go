@@ -0,0 +1,72 @@
+package raster
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/benoitkugler/pdf/model"
+)
+
+// Thumbnail renders `page` (see Page) into a thumbnail image, whose
+// largest side is `size` pixels (defaulting to 128).
+// The annotations are not drawn.
+func Thumbnail(page model.PageObject, size int) (*model.XObjectImage, error) {
+	if size <= 0 {
+		size = 128
+	}
+	box := page.CropBox
+	if box == nil {
+		box = page.MediaBox
+	}
+	var dpi Fl = 72
+	if box != nil {
+		side := box.Width()
+		if h := box.Height(); h > side {
+			side = h
+		}
+		if side > 0 {
+			dpi = 72 * Fl(size) / side
+		}
+	}
+	img, err := Page(page, Options{DPI: dpi, SkipAnnotations: true})
+	if err != nil {
+		return nil, err
+	}
+	return newImageRGB(img), nil
+}
+
+// newImageRGB returns a Flate compressed RGB image
+func newImageRGB(img *image.RGBA) *model.XObjectImage {
+	b := img.Bounds()
+	samples := make([]byte, 0, 3*b.Dx()*b.Dy())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := img.RGBAAt(x, y)
+			samples = append(samples, c.R, c.G, c.B)
+		}
+	}
+	return &model.XObjectImage{
+		Image: model.Image{
+			Stream:           model.NewCompressedStream(samples),
+			Width:            b.Dx(),
+			Height:           b.Dy(),
+			BitsPerComponent: 8,
+		},
+		ColorSpace: model.ColorSpaceRGB,
+	}
+}
+
+// AddThumbnails renders the pages of `doc` and stores
+// the result as thumbnail images (see Thumbnail).
+// Use model.Document.RemoveThumbnails to remove them.
+func AddThumbnails(doc *model.Document, size int) error {
+	pages := doc.Catalog.Pages.Flatten()
+	for index, page := range doc.Catalog.Pages.FlattenInherit() {
+		thumb, err := Thumbnail(page, size)
+		if err != nil {
+			return fmt.Errorf("page %d: %s", index, err)
+		}
+		pages[index].Thumb = thumb
+	}
+	return nil
+}
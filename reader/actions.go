@@ -1,15 +1,30 @@
 package reader
 
 import (
-	"log"
+	"strings"
 
 	"github.com/benoitkugler/pdf/model"
 	"github.com/benoitkugler/pdf/reader/file"
 )
 
 // may return nil if `ac` is nil or invalid
-// TODO: more actions
-func (r resolver) processAction(ac model.Object) (out model.Action, err error) {
+func (r resolver) processAction(ac model.Object) (model.Action, error) {
+	return r.processActionChain(ac, make(map[model.ObjIndirectRef]bool))
+}
+
+// processActionChain resolves the action `ac` and its following actions.
+// `chain` stores the indirect actions being processed, to avoid
+// infinite recursion on malformed (circular) chains.
+func (r resolver) processActionChain(ac model.Object, chain map[model.ObjIndirectRef]bool) (out model.Action, err error) {
+	if ref, isRef := ac.(model.ObjIndirectRef); isRef {
+		if chain[ref] {
+			r.logger.Log(model.LogWarning, "circular action chain", "object", ref.ObjectNumber)
+			return out, nil
+		}
+		chain[ref] = true
+		defer delete(chain, ref)
+	}
+
 	action, _ := r.resolve(ac).(model.ObjDict)
 	if action["S"] == nil {
 		return
@@ -92,7 +107,10 @@ func (r resolver) processAction(ac model.Object) (out model.Action, err error) {
 	case "JavaScript":
 		var js string
 		if K, ok := r.resolve(action).(model.ObjDict); ok {
-			js = r.textOrStream(K["JS"])
+			js, err = r.textOrStream(K["JS"])
+			if err != nil {
+				return out, err
+			}
 		}
 		out.ActionType = model.ActionJavaScript{JS: js}
 	case "Rendition":
@@ -110,36 +128,78 @@ func (r resolver) processAction(ac model.Object) (out model.Action, err error) {
 		if op, ok := r.resolveInt(action["OP"]); ok {
 			ac.OP = model.ObjInt(op)
 		}
-		ac.JS = r.textOrStream(action["JS"])
+		ac.JS, err = r.textOrStream(action["JS"])
+		if err != nil {
+			return out, err
+		}
 		out.ActionType = ac
-	default:
-		log.Println("unsupported action:", name)
-		return out, nil
-	}
-
-	if arr, isArray := r.resolveArray(action["Next"]); isArray { // many next actions
-		out.Next = make([]model.Action, len(arr))
-		for i, n := range arr {
-			out.Next[i], err = r.processAction(n)
+	case "SubmitForm":
+		var subac model.ActionSubmitForm
+		subac.URL = r.resolveURLSpec(action["F"])
+		subac.Fields = r.resolveFieldNames(action["Fields"])
+		if flags, ok := r.resolveInt(action["Flags"]); ok {
+			subac.Flags = model.SubmitFormFlag(flags)
+		}
+		out.ActionType = subac
+	case "ResetForm":
+		var subac model.ActionResetForm
+		subac.Fields = r.resolveFieldNames(action["Fields"])
+		flags, _ := r.resolveInt(action["Flags"])
+		subac.Exclude = flags&1 != 0
+		out.ActionType = subac
+	case "ImportData":
+		var subac model.ActionImportData
+		if action["F"] != nil {
+			subac.F, err = r.resolveFileSpec(action["F"])
 			if err != nil {
 				return out, err
 			}
 		}
-	} else { // maybe one next, try it
-		next, err := r.processAction(action["Next"])
+		out.ActionType = subac
+	case "SetOCGState":
+		var subac model.ActionSetOCGState
+		subac.State, err = r.resolveOCGStates(action["State"])
+		if err != nil {
+			return out, err
+		}
+		if rb, ok := r.resolveBool(action["PreserveRB"]); ok {
+			subac.PreserveRB = model.ObjBool(rb)
+		}
+		out.ActionType = subac
+	case "Trans":
+		out.ActionType = model.ActionTrans{Trans: r.resolveTransition(action["Trans"])}
+	default:
+		// the following actions are still processed
+		r.logger.Log(model.LogWarning, "unsupported action", "type", name)
+	}
+
+	// one or many next actions
+	nexts, isArray := r.resolveArray(action["Next"])
+	if !isArray {
+		nexts = model.ObjArray{action["Next"]}
+	}
+	for _, n := range nexts {
+		next, err := r.processActionChain(n, chain)
 		if err != nil {
 			return out, err
 		}
 		if next.ActionType != nil {
-			out.Next = []model.Action{next}
+			out.Next = append(out.Next, next)
 		}
 	}
+	if out.ActionType == nil && len(out.Next) != 0 {
+		// unsupported action : replace it by the following actions,
+		// preserving the execution order
+		first := out.Next[0]
+		first.Next = append(first.Next, out.Next[1:]...)
+		out = first
+	}
 	return out, nil
 }
 
 func (r resolver) resolveOneHideTarget(o model.Object) (model.ActionHideTarget, error) {
 	if st, is := file.IsString(r.resolve(o)); is { // text string
-		return model.HideTargetFormName(DecodeTextString(st)), nil
+		return model.HideTargetFormName(r.decodeTextString(st)), nil
 	}
 	return r.resolveAnnotation(o)
 }
@@ -171,3 +231,87 @@ func (r resolver) resolveEmbeddedTarget(o model.Object) (out *model.EmbeddedTarg
 	out.T, err = r.resolveEmbeddedTarget(o)
 	return out, err
 }
+
+// resolveURLSpec returns the URL of a file specification,
+// either a string or a dictionary
+func (r resolver) resolveURLSpec(o model.Object) string {
+	o = r.resolve(o)
+	if dict, ok := o.(model.ObjDict); ok {
+		o = r.resolve(dict["F"])
+	}
+	url, _ := file.IsString(o)
+	return url
+}
+
+// resolveFieldNames returns the fully qualified names of the fields,
+// given as text strings or field dictionaries
+func (r resolver) resolveFieldNames(o model.Object) []string {
+	array, _ := r.resolveArray(o)
+	if array == nil {
+		return nil
+	}
+	out := make([]string, 0, len(array))
+	for _, item := range array {
+		item = r.resolve(item)
+		if st, ok := file.IsString(item); ok {
+			out = append(out, r.decodeTextString(st))
+		} else if dict, ok := item.(model.ObjDict); ok {
+			out = append(out, r.fullFieldName(dict))
+		}
+	}
+	return out
+}
+
+// fullFieldName walks the parents of the field to build its fully qualified name
+func (r resolver) fullFieldName(field model.ObjDict) string {
+	var names []string
+	// limit the depth to protect against circular parents
+	for depth := 0; field != nil && depth < 100; depth++ {
+		if t, ok := file.IsString(r.resolve(field["T"])); ok {
+			names = append([]string{r.decodeTextString(t)}, names...)
+		}
+		field, _ = r.resolve(field["Parent"]).(model.ObjDict)
+	}
+	return strings.Join(names, ".")
+}
+
+// resolveOCGStates parses the State array of a SetOCGState action,
+// where each state name is followed by the groups it applies to
+func (r resolver) resolveOCGStates(o model.Object) ([]model.OCGStateChange, error) {
+	array, _ := r.resolveArray(o)
+	var out []model.OCGStateChange
+	for _, item := range array {
+		if name, ok := r.resolveName(item); ok {
+			out = append(out, model.OCGStateChange{State: name})
+			continue
+		}
+		if len(out) == 0 || r.resolve(item) == nil { // invalid array
+			continue
+		}
+		oc, err := r.resolveOCG(item)
+		if err != nil {
+			return nil, err
+		}
+		last := &out[len(out)-1]
+		last.OCGs = append(last.OCGs, oc)
+	}
+	return out, nil
+}
+
+func (r resolver) resolveTransition(o model.Object) (out model.Transition) {
+	dict, _ := r.resolve(o).(model.ObjDict)
+	out.S, _ = r.resolveName(dict["S"])
+	if d, ok := r.resolveNumber(dict["D"]); ok {
+		out.D = model.ObjFloat(d)
+	}
+	out.Dm, _ = r.resolveName(dict["Dm"])
+	out.M, _ = r.resolveName(dict["M"])
+	if di, ok := r.resolveInt(dict["Di"]); ok {
+		out.Di = model.ObjInt(di)
+	}
+	if ss, ok := r.resolveNumber(dict["SS"]); ok {
+		out.SS = model.ObjFloat(ss)
+	}
+	out.B, _ = r.resolveBool(dict["B"])
+	return out
+}
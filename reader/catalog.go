@@ -3,11 +3,23 @@ package reader
 import (
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/benoitkugler/pdf/model"
 	"github.com/benoitkugler/pdf/reader/file"
 )
 
+// catalogKeys are the entries handled by the model,
+// or which can't be copied (such as the article threads, referencing the pages)
+var catalogKeys = map[model.Name]bool{
+	"AA": true, "AF": true, "AcroForm": true, "Collection": true, "Dests": true,
+	"Extensions": true, "Lang": true, "MarkInfo": true, "Metadata": true, "Names": true,
+	"OCProperties": true, "OpenAction": true, "Outline": true, "Outlines": true,
+	"OutputIntents": true, "PageLabels": true, "PageLayout": true, "PageMode": true,
+	"Pages": true, "Perms": true, "StructTreeRoot": true, "Threads": true, "Type": true,
+	"URI": true, "Version": true, "ViewerPreferences": true,
+}
+
 func (r resolver) catalog() (model.Catalog, error) {
 	var (
 		out model.Catalog
@@ -87,13 +99,82 @@ func (r resolver) catalog() (model.Catalog, error) {
 	if err != nil {
 		return out, err
 	}
+	out.AA, err = r.resolveDocumentAA(d["AA"])
+	if err != nil {
+		return out, err
+	}
 
 	lang, _ := file.IsString(r.resolve(d["Lang"]))
-	out.Lang = DecodeTextString(lang)
+	out.Lang = r.decodeTextString(lang)
+
+	metadata, ok, err := r.resolveStream(d["Metadata"])
+	if err != nil {
+		return out, fmt.Errorf("invalid Metadata entry: %s", err)
+	}
+	if ok {
+		out.Metadata = &model.MetadataStream{Stream: metadata}
+	}
+
+	out.OutputIntents, err = r.resolveOutputIntents(d["OutputIntents"])
+	if err != nil {
+		return out, err
+	}
+
+	out.OCProperties, err = r.resolveOCProperties(d["OCProperties"])
+	if err != nil {
+		return out, fmt.Errorf("invalid OCProperties entry: %s", err)
+	}
+
+	out.Perms, err = r.resolvePerms(d["Perms"])
+	if err != nil {
+		return out, fmt.Errorf("invalid Perms entry: %s", err)
+	}
+
+	out.Collection = r.resolveCollection(d["Collection"])
+
+	out.AF, err = r.resolveFileSpecArray(d["AF"])
+	if err != nil {
+		return out, fmt.Errorf("invalid AF entry: %s", err)
+	}
+
+	out.Custom, err = r.resolveCustomEntries(d, catalogKeys)
+	if err != nil {
+		return out, fmt.Errorf("invalid Catalog: %s", err)
+	}
 
 	return out, nil
 }
 
+func (r resolver) resolveOutputIntents(obj model.Object) ([]model.OutputIntent, error) {
+	ar, _ := r.resolveArray(obj)
+	var out []model.OutputIntent
+	for _, intent := range ar {
+		dict, ok := r.resolve(intent).(model.ObjDict)
+		if !ok {
+			return nil, errType("OutputIntent", intent)
+		}
+		var oi model.OutputIntent
+		oi.S, _ = r.resolveName(dict["S"])
+		s, _ := file.IsString(r.resolve(dict["OutputCondition"]))
+		oi.OutputCondition = r.decodeTextString(s)
+		s, _ = file.IsString(r.resolve(dict["OutputConditionIdentifier"]))
+		oi.OutputConditionIdentifier = r.decodeTextString(s)
+		s, _ = file.IsString(r.resolve(dict["RegistryName"]))
+		oi.RegistryName = r.decodeTextString(s)
+		s, _ = file.IsString(r.resolve(dict["Info"]))
+		oi.Info = r.decodeTextString(s)
+		if profile := dict["DestOutputProfile"]; profile != nil {
+			var err error
+			oi.DestOutputProfile, err = r.resolveICCStream(profile)
+			if err != nil {
+				return nil, fmt.Errorf("invalid DestOutputProfile: %s", err)
+			}
+		}
+		out = append(out, oi)
+	}
+	return out, nil
+}
+
 func (r resolver) rectangleFromArray(array model.Object) *model.Rectangle {
 	ar, _ := r.resolveArray(array)
 	if len(ar) < 4 {
@@ -121,7 +202,10 @@ func (r resolver) matrixFromArray(array model.Object) *model.Matrix {
 func (r resolver) resolveAppearanceDict(o model.Object) (*model.AppearanceDict, error) {
 	ref, isRef := o.(model.ObjIndirectRef)
 	if isRef {
-		if ff := r.appearanceDicts[ref]; ff != nil {
+		r.lock()
+		ff := r.appearanceDicts[ref]
+		r.unlock()
+		if ff != nil {
 			return ff, nil
 		}
 		o = r.resolve(ref)
@@ -156,38 +240,51 @@ func (r resolver) resolveAppearanceDict(o model.Object) (*model.AppearanceDict,
 		}
 	}
 	if isRef { // write back to the cache
+		r.lock()
+		defer r.unlock()
+		if existing := r.appearanceDicts[ref]; existing != nil { // resolved concurrently
+			return existing, nil
+		}
 		r.appearanceDicts[ref] = &out
 	}
 	return &out, nil
 }
 
+// resolveAppearanceEntry returns nil for a null entry, and
+// uses the empty key for a single stream (see model.AppearanceEntry)
 func (r resolver) resolveAppearanceEntry(obj model.Object) (model.AppearanceEntry, error) {
-	out := make(model.AppearanceEntry)
-
 	// obj might be either a subdictionary or a streamdictionary
-	if subDict, isResolvedDict := r.resolve(obj).(model.ObjDict); isResolvedDict {
-		// subdictionary
-		for name, stream := range subDict {
+	switch resolved := r.resolve(obj).(type) {
+	case nil, model.ObjNull:
+		return nil, nil
+	case model.ObjDict: // subdictionary
+		out := make(model.AppearanceEntry, len(resolved))
+		for name, stream := range resolved {
+			if st := r.resolve(stream); st == nil || st == (model.ObjNull{}) { // tolerate null states
+				continue
+			}
 			formObj, err := r.resolveOneXObjectForm(stream)
 			if err != nil {
 				return nil, err
 			}
 			out[model.ObjName(name)] = formObj
 		}
-	} else { // stream (surely indirect)
+		return out, nil
+	default: // stream (surely indirect)
 		ap, err := r.resolveOneXObjectForm(obj)
 		if err != nil {
 			return nil, err
 		}
-		out = model.AppearanceEntry{"": ap}
+		return model.AppearanceEntry{"": ap}, nil
 	}
-	return out, nil
 }
 
 // return an error if obj is nil
 func (r resolver) resolveOneXObjectForm(obj model.Object) (*model.XObjectForm, error) {
 	xObjRef, isRef := obj.(model.ObjIndirectRef)
+	r.lock()
 	if out := r.xObjectForms[xObjRef]; isRef && out != nil {
+		r.unlock()
 		return out, nil
 	}
 
@@ -197,6 +294,7 @@ func (r resolver) resolveOneXObjectForm(obj model.Object) (*model.XObjectForm, e
 	if isRef {
 		r.xObjectForms[xObjRef] = out
 	}
+	r.unlock()
 
 	err := r.resolveXFormObjectFields(obj, out)
 	if err != nil {
@@ -238,13 +336,22 @@ func (r resolver) resolveXFormObjectFields(obj model.Object, out *model.XObjectF
 	} else if st, ok := r.resolveInt(stream.Args["StructParents"]); ok {
 		out.StructParents = model.ObjInt(st)
 	}
+	if oc := stream.Args["OC"]; oc != nil {
+		var err error
+		out.OC, err = r.resolveOptionalContent(oc)
+		if err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
 
 func (r *resolver) resolveOneXObjectGroup(obj model.Object) (*model.XObjectTransparencyGroup, error) {
 	xObjRef, isRef := obj.(model.ObjIndirectRef)
+	r.lock()
 	if out := r.xObjectsGroups[xObjRef]; isRef && out != nil {
+		r.unlock()
 		return out, nil
 	}
 
@@ -254,6 +361,7 @@ func (r *resolver) resolveOneXObjectGroup(obj model.Object) (*model.XObjectTrans
 	if isRef {
 		r.xObjectsGroups[xObjRef] = out
 	}
+	r.unlock()
 
 	err := r.resolveXFormObjectFields(obj, &out.XObjectForm)
 	if err != nil {
@@ -262,13 +370,19 @@ func (r *resolver) resolveOneXObjectGroup(obj model.Object) (*model.XObjectTrans
 	// here we known resolved obj is a valid StreamDict
 	gDict := r.resolve(obj).(model.ObjStream).Args
 	group, _ := r.resolve(gDict["Group"]).(model.ObjDict)
-	out.Group.CS, err = r.resolveOneColorSpace(group["CS"])
+	out.Group, err = r.resolveTransparencyGroup(group)
+	return out, err
+}
+
+// resolveTransparencyGroup resolves the group attributes
+// of a page or of a form XObject
+func (r resolver) resolveTransparencyGroup(group model.ObjDict) (out model.TransparencyGroup, err error) {
+	out.CS, err = r.resolveOneColorSpace(group["CS"])
 	if err != nil {
 		return out, err
 	}
-	out.Group.I, _ = r.resolveBool(group["I"])
-	out.Group.K, _ = r.resolveBool(group["K"])
-
+	out.I, _ = r.resolveBool(group["I"])
+	out.K, _ = r.resolveBool(group["K"])
 	return out, nil
 }
 
@@ -330,6 +444,13 @@ func (r resolver) processNameDict(entry model.Object) (model.NameDictionary, err
 		}
 	}
 
+	if tree := dict["JavaScript"]; tree != nil {
+		err := r.resolveNameTree(tree, javascriptNameTree{out: &out.JavaScript})
+		if err != nil {
+			return out, err
+		}
+	}
+
 	// TODO: other names
 	return out, nil
 }
@@ -353,6 +474,29 @@ func (r resolver) resolveViewerPreferences(entry model.Object) (*model.ViewerPre
 	if ct, _ := r.resolveName(dict["Direction"]); ct == "R2L" {
 		out.DirectionRTL = true
 	}
+	out.HideToolbar, _ = r.resolveBool(dict["HideToolbar"])
+	out.HideMenubar, _ = r.resolveBool(dict["HideMenubar"])
+	out.HideWindowUI, _ = r.resolveBool(dict["HideWindowUI"])
+	out.DisplayDocTitle, _ = r.resolveBool(dict["DisplayDocTitle"])
+	out.NonFullScreenPageMode, _ = r.resolveName(dict["NonFullScreenPageMode"])
+	out.ViewArea, _ = r.resolveName(dict["ViewArea"])
+	out.ViewClip, _ = r.resolveName(dict["ViewClip"])
+	out.PrintArea, _ = r.resolveName(dict["PrintArea"])
+	out.PrintClip, _ = r.resolveName(dict["PrintClip"])
+	out.PrintScaling, _ = r.resolveName(dict["PrintScaling"])
+	out.Duplex, _ = r.resolveName(dict["Duplex"])
+	if pick, ok := r.resolveBool(dict["PickTrayByPDFSize"]); ok {
+		out.PickTrayByPDFSize = model.ObjBool(pick)
+	}
+	if ranges, _ := r.resolveArray(dict["PrintPageRange"]); len(ranges) != 0 {
+		out.PrintPageRange = make([]int, 0, len(ranges))
+		for _, v := range ranges {
+			if page, ok := r.resolveInt(v); ok {
+				out.PrintPageRange = append(out.PrintPageRange, page)
+			}
+		}
+	}
+	out.NumCopies, _ = r.resolveInt(dict["NumCopies"])
 	return &out, nil
 }
 
@@ -396,7 +540,7 @@ func (r resolver) resolveOutlineItem(object model.Object, parent model.OutlineNo
 		err error
 	)
 	title, _ := file.IsString(r.resolve(dict["Title"]))
-	out.Title = DecodeTextString(title)
+	out.Title = r.decodeTextString(title)
 	out.Parent = parent
 	if first := dict["First"]; first != nil {
 		out.First, err = r.resolveOutlineItem(dict["First"], &out)
@@ -452,17 +596,45 @@ func (r resolver) resolveMarkDict(object model.Object) (*model.MarkDict, error)
 	return &out, nil
 }
 
+func (r resolver) resolveDocumentAA(o model.Object) (out model.DocumentAdditionalActions, err error) {
+	dict, _ := r.resolve(o).(model.ObjDict)
+	if dict == nil {
+		return out, nil
+	}
+	out.WC, err = r.processAction(dict["WC"])
+	if err != nil {
+		return out, err
+	}
+	out.WS, err = r.processAction(dict["WS"])
+	if err != nil {
+		return out, err
+	}
+	out.DS, err = r.processAction(dict["DS"])
+	if err != nil {
+		return out, err
+	}
+	out.WP, err = r.processAction(dict["WP"])
+	if err != nil {
+		return out, err
+	}
+	out.DP, err = r.processAction(dict["DP"])
+	if err != nil {
+		return out, err
+	}
+	return out, nil
+}
+
 func (r resolver) resolveDestinationOrAction(object model.Object) (model.Action, error) {
-	object = r.resolve(object)
-	switch object := object.(type) {
+	switch resolved := r.resolve(object).(type) {
 	case model.ObjArray: // explicit destination
-		dest, err := r.resolveExplicitDestination(object)
+		dest, err := r.resolveExplicitDestination(resolved)
 		if err != nil {
 			return model.Action{}, err
 		}
 		// we see simple destination as GoTo actions
 		return model.Action{ActionType: model.ActionGoTo{D: dest}}, nil
 	case model.ObjDict:
+		// use the unresolved object to detect circular chains
 		return r.processAction(object)
 	}
 	return model.Action{}, nil
@@ -482,3 +654,88 @@ func (r resolver) resolveDests(object model.Object) (map[model.ObjName]model.Des
 	}
 	return out, nil
 }
+
+func (r resolver) resolveCollection(obj model.Object) *model.Collection {
+	dict, ok := r.resolve(obj).(model.ObjDict)
+	if !ok {
+		return nil
+	}
+	var out model.Collection
+	if schema, ok := r.resolve(dict["Schema"]).(model.ObjDict); ok {
+		out.Schema = make(map[model.Name]model.CollectionField, len(schema))
+		for name, field := range schema {
+			fieldDict, ok := r.resolve(field).(model.ObjDict)
+			if !ok { // Type entry
+				continue
+			}
+			var f model.CollectionField
+			f.Subtype, _ = r.resolveName(fieldDict["Subtype"])
+			n, _ := file.IsString(r.resolve(fieldDict["N"]))
+			f.N = r.decodeTextString(n)
+			if o, ok := r.resolveInt(fieldDict["O"]); ok {
+				f.O = model.ObjInt(o)
+			}
+			if v, ok := r.resolveBool(fieldDict["V"]); ok {
+				f.V = model.ObjBool(v)
+			}
+			f.E, _ = r.resolveBool(fieldDict["E"])
+			out.Schema[model.Name(name)] = f
+		}
+	}
+	out.D, _ = file.IsString(r.resolve(dict["D"]))
+	out.View, _ = r.resolveName(dict["View"])
+	if sortDict, ok := r.resolve(dict["Sort"]).(model.ObjDict); ok {
+		var order model.CollectionSort
+		if name, ok := r.resolveName(sortDict["S"]); ok {
+			order.S = []model.Name{name}
+		} else {
+			order.S = r.resolveNames(sortDict["S"])
+		}
+		if a, ok := r.resolveBool(sortDict["A"]); ok {
+			order.A = []bool{a}
+		} else if ar, ok := r.resolveArray(sortDict["A"]); ok {
+			for _, a := range ar {
+				b, _ := r.resolveBool(a)
+				order.A = append(order.A, b)
+			}
+		}
+		out.Sort = &order
+	}
+	return &out
+}
+
+// resolveCollectionItems returns nil for an invalid dictionary
+func (r resolver) resolveCollectionItems(obj model.Object) map[model.Name]model.CollectionItem {
+	dict, ok := r.resolve(obj).(model.ObjDict)
+	if !ok {
+		return nil
+	}
+	out := make(map[model.Name]model.CollectionItem, len(dict))
+	for name, value := range dict {
+		var item model.CollectionItem
+		value = r.resolve(value)
+		if subItem, ok := value.(model.ObjDict); ok {
+			prefix, _ := file.IsString(r.resolve(subItem["P"]))
+			item.Prefix = r.decodeTextString(prefix)
+			value = r.resolve(subItem["D"])
+		}
+		switch value := value.(type) {
+		case model.ObjInt:
+			item.Value = model.CollectionNumber(value)
+		case model.ObjFloat:
+			item.Value = model.CollectionNumber(value)
+		default:
+			s, ok := file.IsString(value)
+			if !ok { // Type entry or invalid value
+				continue
+			}
+			if date, ok := DateTime(s); ok && strings.HasPrefix(s, "D:") {
+				item.Value = model.CollectionDate(date)
+			} else {
+				item.Value = model.CollectionText(r.decodeTextString(s))
+			}
+		}
+		out[model.Name(name)] = item
+	}
+	return out
+}
@@ -1,10 +1,14 @@
 package reader
 
 import (
+	"bytes"
 	"fmt"
 	"os"
+	"reflect"
 	"testing"
+	"time"
 
+	"github.com/benoitkugler/pdf/model"
 	"golang.org/x/text/encoding/unicode"
 )
 
@@ -25,3 +29,438 @@ func TestOutline(t *testing.T) {
 		fmt.Println(enc.Bytes([]byte(o.Title)))
 	}
 }
+
+// buildPDF returns a minimal PDF file with the given objects,
+// numbered from 1, the first one being the catalog.
+func buildPDF(objects []string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, o := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", o)
+	}
+	fmt.Fprintf(&buf, "trailer\n<</Size %d/Root 1 0 R>>\nstartxref\n%d\n%%%%EOF", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+func TestActionChainRoundTrip(t *testing.T) {
+	// object 5 is an unsupported action, and 4 -> 5 -> 4 is a circular chain
+	objects := []string{
+		"<</Type/Catalog/Pages 2 0 R/OpenAction 4 0 R>>",
+		"<</Type/Pages/Kids [3 0 R]/Count 1>>",
+		"<</Type/Page/Parent 2 0 R/MediaBox [0 0 100 100]>>",
+		"<</S/JavaScript/JS (a)/Next 5 0 R>>",
+		"<</S/Unknown/Next [6 0 R 4 0 R 7 0 R]>>",
+		"<</S/JavaScript/JS (b)/Next 7 0 R>>",
+		"<</S/JavaScript/JS (c)>>",
+	}
+	doc, _, err := ParsePDFReader(bytes.NewReader(buildPDF(objects)), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var codes []string
+	for _, ac := range doc.Catalog.OpenAction.Chain() {
+		codes = append(codes, ac.ActionType.(model.ActionJavaScript).JS)
+	}
+	if exp := []string{"a", "b", "c", "c"}; !reflect.DeepEqual(codes, exp) {
+		t.Fatalf("expected %v, got %v", exp, codes)
+	}
+
+	// the chain is preserved when writing
+	var out bytes.Buffer
+	if err = doc.Write(&out, nil); err != nil {
+		t.Fatal(err)
+	}
+	doc2, _, err := ParsePDFReader(bytes.NewReader(out.Bytes()), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(doc2.Catalog.OpenAction, doc.Catalog.OpenAction) {
+		t.Fatalf("expected %v, got %v", doc.Catalog.OpenAction, doc2.Catalog.OpenAction)
+	}
+}
+
+func TestFormActionsRoundTrip(t *testing.T) {
+	ocg := &model.OptionalContentGroup{Name: "layer"}
+	actions := []model.Action{
+		{ActionType: model.ActionSubmitForm{URL: "http://example.com/submit", Fields: []string{"a.b", "c"}, Flags: model.SubmitExclude | model.SubmitXFDF}},
+		{ActionType: model.ActionResetForm{Fields: []string{"d"}, Exclude: true}},
+		{ActionType: model.ActionImportData{F: &model.FileSpec{UF: "data.fdf"}}},
+		{ActionType: model.ActionSetOCGState{
+			State:      []model.OCGStateChange{{State: "OFF", OCGs: []*model.OptionalContentGroup{ocg}}, {State: "Toggle", OCGs: []*model.OptionalContentGroup{ocg}}},
+			PreserveRB: model.ObjBool(false),
+		}},
+		{ActionType: model.ActionTrans{Trans: model.Transition{S: "Fly", D: model.ObjFloat(2.5), Di: model.ObjInt(90), B: true}}},
+		{ActionType: model.ActionNamed("NextPage")},
+	}
+	var doc model.Document
+	doc.Catalog.OpenAction = actions[0]
+	doc.Catalog.OpenAction.Next = actions[1:]
+	doc.Catalog.Pages.Kids = []model.PageNode{&model.PageObject{MediaBox: &model.Rectangle{Urx: 100, Ury: 100}}}
+
+	var out bytes.Buffer
+	if err := doc.Write(&out, nil); err != nil {
+		t.Fatal(err)
+	}
+	doc2, _, err := ParsePDFReader(bytes.NewReader(out.Bytes()), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(doc2.Catalog.OpenAction, doc.Catalog.OpenAction) {
+		t.Fatalf("expected %v, got %v", doc.Catalog.OpenAction, doc2.Catalog.OpenAction)
+	}
+}
+
+func TestFormActionFields(t *testing.T) {
+	// fields may be given by reference
+	objects := []string{
+		"<</Type/Catalog/Pages 2 0 R/OpenAction 4 0 R>>",
+		"<</Type/Pages/Kids [3 0 R]/Count 1>>",
+		"<</Type/Page/Parent 2 0 R/MediaBox [0 0 100 100]>>",
+		"<</S/ResetForm/Fields [5 0 R (other)]>>",
+		"<</T (child)/Parent 6 0 R>>",
+		"<</T (parent)/Kids [5 0 R]>>",
+	}
+	doc, _, err := ParsePDFReader(bytes.NewReader(buildPDF(objects)), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := model.ActionResetForm{Fields: []string{"parent.child", "other"}}
+	if ac := doc.Catalog.OpenAction.ActionType; !reflect.DeepEqual(ac, exp) {
+		t.Fatalf("expected %v, got %v", exp, ac)
+	}
+}
+
+func TestAdditionalActionsRoundTrip(t *testing.T) {
+	var doc model.Document
+	page := &model.PageObject{MediaBox: &model.Rectangle{Urx: 100, Ury: 100}}
+	doc.Catalog.Pages.Kids = []model.PageNode{page}
+	doc.Catalog.AA.WC = model.Action{ActionType: model.ActionSubmitForm{URL: "http://example.com"}}
+	doc.Catalog.AA.WP = model.Action{ActionType: model.ActionResetForm{}}
+	page.AA.O = model.Action{
+		ActionType: model.ActionNamed("FirstPage"),
+		Next:       []model.Action{{ActionType: model.ActionJavaScript{JS: "app.alert('open');"}}},
+	}
+	page.AA.C = model.Action{ActionType: model.ActionGoTo{D: model.DestinationExplicitIntern{Page: page, Location: model.DestinationLocationFit("Fit")}}}
+
+	var out bytes.Buffer
+	if err := doc.Write(&out, nil); err != nil {
+		t.Fatal(err)
+	}
+	doc2, _, err := ParsePDFReader(bytes.NewReader(out.Bytes()), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(doc2.Catalog.AA, doc.Catalog.AA) {
+		t.Fatalf("expected %v, got %v", doc.Catalog.AA, doc2.Catalog.AA)
+	}
+	page2 := doc2.Catalog.Pages.Flatten()[0]
+	if !reflect.DeepEqual(page2.AA.O, page.AA.O) {
+		t.Fatalf("expected %v, got %v", page.AA.O, page2.AA.O)
+	}
+	if goTo := page2.AA.C.ActionType.(model.ActionGoTo); goTo.D.(model.DestinationExplicitIntern).Page != page2 {
+		t.Fatalf("unexpected destination %v", goTo.D)
+	}
+}
+
+func TestViewerPreferencesRoundTrip(t *testing.T) {
+	var doc model.Document
+	doc.Catalog.Pages.Kids = []model.PageNode{&model.PageObject{MediaBox: &model.Rectangle{Urx: 100, Ury: 100}}}
+	doc.SetPageLayout(model.PageLayoutTwoColumnLeft)
+	doc.SetPageMode(model.PageModeUseOutlines)
+	doc.SetViewerPreferences(model.ViewerPreferences{
+		HideToolbar:           true,
+		FitWindow:             true,
+		DisplayDocTitle:       true,
+		NonFullScreenPageMode: model.PageModeUseThumbs,
+		DirectionRTL:          true,
+		ViewArea:              "CropBox",
+		PrintScaling:          "None",
+		Duplex:                "DuplexFlipLongEdge",
+		PickTrayByPDFSize:     model.ObjBool(false),
+		PrintPageRange:        []int{1, 1, 3, 4},
+		NumCopies:             2,
+	})
+
+	var out bytes.Buffer
+	if err := doc.Write(&out, nil); err != nil {
+		t.Fatal(err)
+	}
+	doc2, _, err := ParsePDFReader(bytes.NewReader(out.Bytes()), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc2.Catalog.PageLayout != model.PageLayoutTwoColumnLeft || doc2.Catalog.PageMode != model.PageModeUseOutlines {
+		t.Fatalf("unexpected page layout and mode %s %s", doc2.Catalog.PageLayout, doc2.Catalog.PageMode)
+	}
+	if !reflect.DeepEqual(doc2.Catalog.ViewerPreferences, doc.Catalog.ViewerPreferences) {
+		t.Fatalf("expected %v, got %v", doc.Catalog.ViewerPreferences, doc2.Catalog.ViewerPreferences)
+	}
+}
+
+type recordLogger []string
+
+func (r *recordLogger) Log(level model.LogLevel, msg string, keysAndValues ...interface{}) {
+	*r = append(*r, model.FormatLog(level, msg, keysAndValues...))
+}
+
+func TestLoggerOption(t *testing.T) {
+	objects := []string{
+		"<</Type/Catalog/Pages 2 0 R/OpenAction 4 0 R>>",
+		"<</Type/Pages/Kids [3 0 R]/Count 1>>",
+		"<</Type/Page/Parent 2 0 R/MediaBox [0 0 100 100]>>",
+		"<</S/Unknown/Next 4 0 R>>",
+	}
+	var logger recordLogger
+	_, _, err := ParsePDFReader(bytes.NewReader(buildPDF(objects)), Options{Logger: &logger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := recordLogger{
+		"warning: unsupported action type=/Unknown",
+		"warning: circular action chain object=4",
+	}
+	if !reflect.DeepEqual(logger, exp) {
+		t.Fatalf("expected %v, got %v", exp, logger)
+	}
+}
+
+func TestScriptsRoundTrip(t *testing.T) {
+	js := func(code string) model.Action { return model.Action{ActionType: model.ActionJavaScript{JS: code}} }
+
+	var doc model.Document
+	doc.Catalog.AA.WC = js("close")
+	doc.Catalog.AA.DP = js("print")
+	doc.Catalog.Names.JavaScript = model.JavaScriptTree{{Name: "a", Action: js("init a")}, {Name: "b", Action: js("init b")}}
+	doc.Catalog.Pages.Kids = []model.PageNode{&model.PageObject{
+		MediaBox: &model.Rectangle{Urx: 100, Ury: 100},
+		AA:       model.PageAdditionalActions{O: js("open"), C: js("close page")},
+	}}
+
+	var out bytes.Buffer
+	if err := doc.Write(&out, nil); err != nil {
+		t.Fatal(err)
+	}
+	doc2, _, err := ParsePDFReader(bytes.NewReader(out.Bytes()), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scripts, exp := doc2.Scripts(), doc.Scripts(); !reflect.DeepEqual(scripts, exp) || len(exp) != 6 {
+		t.Fatalf("expected %v, got %v", exp, scripts)
+	}
+}
+
+func TestAppearanceEntries(t *testing.T) {
+	const form = "<</Type/XObject/Subtype/Form/BBox [0 0 10 10]/Length 0>>\nstream\n\nendstream"
+	objects := []string{
+		"<</Type/Catalog/Pages 2 0 R>>",
+		"<</Type/Pages/Kids [3 0 R]/Count 1>>",
+		"<</Type/Page/Parent 2 0 R/MediaBox [0 0 100 100]/Annots [4 0 R 5 0 R 6 0 R 7 0 R 8 0 R]>>",
+		// direct stream, AS unset
+		"<</Type/Annot/Subtype/Widget/Rect [0 0 10 10]/AP <</N 9 0 R>>>>",
+		// states, AS set
+		"<</Type/Annot/Subtype/Widget/Rect [0 0 10 10]/AS/Yes/AP <</N <</Yes 9 0 R/Off 10 0 R>>/D null>>>>",
+		// direct stream, AS set
+		"<</Type/Annot/Subtype/Widget/Rect [0 0 10 10]/AS/Yes/AP <</N 10 0 R>>>>",
+		// only one state, AS unset
+		"<</Type/Annot/Subtype/Widget/Rect [0 0 10 10]/AP <</N <</On 9 0 R>>>>>>",
+		// null state
+		"<</Type/Annot/Subtype/Widget/Rect [0 0 10 10]/AS/Off/AP <</N <</Yes null/Off 10 0 R>>>>>>",
+		form,
+		form,
+	}
+
+	check := func(doc model.Document) {
+		annots := doc.Catalog.Pages.Flatten()[0].Annots
+		if len(annots) != 5 {
+			t.Fatalf("unexpected annotations %v", annots)
+		}
+		expStream := [5]bool{true, false, true, false, false}
+		expStates := [5]int{1, 2, 1, 1, 1}
+		for i, annot := range annots {
+			n := annot.AP.N
+			if n.IsStream() != expStream[i] || len(n) != expStates[i] {
+				t.Fatalf("annotation %d: unexpected appearance entry %v", i, n)
+			}
+			if n.Appearance(annot.AS) == nil {
+				t.Fatalf("annotation %d: missing appearance for state %s", i, annot.AS)
+			}
+		}
+		if annots[1].AP.D != nil {
+			t.Fatalf("unexpected D entry %v", annots[1].AP.D)
+		}
+		if annots[2].AP.N.Appearance("Off") == nil || annots[1].AP.N.Appearance("Other") != nil {
+			t.Fatal("unexpected appearance lookup")
+		}
+	}
+
+	doc, _, err := ParsePDFReader(bytes.NewReader(buildPDF(objects)), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(doc)
+
+	// the shapes are preserved when writing back
+	var out bytes.Buffer
+	if err = doc.Write(&out, nil); err != nil {
+		t.Fatal(err)
+	}
+	doc, _, err = ParsePDFReader(bytes.NewReader(out.Bytes()), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(doc)
+}
+
+func TestCollectionRoundTrip(t *testing.T) {
+	var doc model.Document
+	doc.Catalog.Pages.Kids = []model.PageNode{&model.PageObject{MediaBox: &model.Rectangle{Urx: 100, Ury: 100}}}
+	date := time.Date(2020, 5, 4, 12, 30, 0, 0, time.UTC)
+	fs := doc.AttachFile("report.txt", []byte("some report"), "text/plain", "The report")
+	fs.CI = map[model.Name]model.CollectionItem{
+		"author":  {Value: model.CollectionText("Émile")},
+		"date":    {Value: model.CollectionDate(date)},
+		"version": {Value: model.CollectionNumber(2), Prefix: "v"},
+	}
+	doc.AttachFile("data.csv", []byte("a;b"), "text/csv", "")
+	doc.Catalog.Collection = &model.Collection{
+		Schema: map[model.Name]model.CollectionField{
+			"author":  {Subtype: "S", N: "Author", O: model.ObjInt(1)},
+			"date":    {Subtype: "D", N: "Date", V: model.ObjBool(false)},
+			"version": {Subtype: "N", N: "Version", E: true},
+			"file":    {Subtype: "F", N: "File name"},
+		},
+		D:    "report.txt",
+		View: "T",
+		Sort: &model.CollectionSort{S: []model.Name{"date", "author"}, A: []bool{false, true}},
+	}
+
+	var out bytes.Buffer
+	if err := doc.Write(&out, nil); err != nil {
+		t.Fatal(err)
+	}
+	doc2, _, err := ParsePDFReader(bytes.NewReader(out.Bytes()), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(doc2.Catalog.Collection, doc.Catalog.Collection) {
+		t.Fatalf("expected %v, got %v", doc.Catalog.Collection, doc2.Catalog.Collection)
+	}
+
+	attachments, err := doc2.Attachments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attachments) != 2 || attachments[0].Name != "data.csv" || string(attachments[1].Content) != "some report" {
+		t.Fatalf("unexpected attachments %v", attachments)
+	}
+	ci := attachments[1].FileSpec.CI
+	if len(ci) != 3 || ci["author"] != fs.CI["author"] || ci["version"] != fs.CI["version"] {
+		t.Fatalf("unexpected collection item %v", ci)
+	}
+	if d, ok := ci["date"].Value.(model.CollectionDate); !ok || !time.Time(d).Equal(date) {
+		t.Fatalf("unexpected date %v", ci["date"])
+	}
+}
+
+func TestAssociatedFilesRoundTrip(t *testing.T) {
+	var doc model.Document
+	page := &model.PageObject{MediaBox: &model.Rectangle{Urx: 100, Ury: 100}}
+	doc.Catalog.Pages.Kids = []model.PageNode{page}
+	invoice := doc.AddAssociatedFile("invoice.xml", []byte("<invoice/>"), "text/xml", "", "Alternative")
+	doc.AddAssociatedFile("invoice.xml", []byte("<invoice></invoice>"), "text/xml", "", "Data") // replace
+	if len(doc.Catalog.AF) != 1 || doc.Catalog.AF[0] == invoice || doc.Catalog.AF[0].AFRelationship != "Data" {
+		t.Fatalf("unexpected associated files %v", doc.Catalog.AF)
+	}
+	source := &model.FileSpec{UF: "source.txt", AFRelationship: "Source", EF: model.NewEmbeddedFileStream([]byte("source"), "text/plain", 0)}
+	page.AF = []*model.FileSpec{source}
+	page.Annots = []*model.AnnotationDict{{
+		BaseAnnotation: model.BaseAnnotation{Rect: model.Rectangle{Urx: 10, Ury: 10}, AF: []*model.FileSpec{source, doc.Catalog.AF[0]}},
+		Subtype:        model.AnnotationSquare{},
+	}}
+
+	var out bytes.Buffer
+	if err := doc.Write(&out, nil); err != nil {
+		t.Fatal(err)
+	}
+	doc2, _, err := ParsePDFReader(bytes.NewReader(out.Bytes()), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	af := doc2.Catalog.AF
+	if len(af) != 1 || af[0].UF != "invoice.xml" || af[0].AFRelationship != "Data" || af[0] != doc2.Catalog.Names.EmbeddedFiles[0].FileSpec {
+		t.Fatalf("unexpected catalog associated files %v", af)
+	}
+	page2 := doc2.Catalog.Pages.Flatten()[0]
+	if len(page2.AF) != 1 || page2.AF[0].UF != "source.txt" || page2.AF[0].AFRelationship != "Source" {
+		t.Fatalf("unexpected page associated files %v", page2.AF)
+	}
+	annotAF := page2.Annots[0].AF
+	if len(annotAF) != 2 || annotAF[0] != page2.AF[0] || annotAF[1] != af[0] {
+		t.Fatalf("unexpected annotation associated files %v", annotAF)
+	}
+
+	clone := doc2.Clone()
+	page3 := clone.Catalog.Pages.Flatten()[0]
+	if page3.AF[0] != page3.Annots[0].AF[0] || page3.AF[0] == page2.AF[0] {
+		t.Fatal("clone should preserve the shared file specifications")
+	}
+}
+
+func TestCustomEntriesRoundTrip(t *testing.T) {
+	// object 5 refers back to itself
+	objects := []string{
+		"<</Type/Catalog/Pages 2 0 R/PieceInfo <</ADBE_Ext <</Private 5 0 R>>>>>>",
+		"<</Type/Pages/Kids [3 0 R]/Count 1>>",
+		"<</Type/Page/Parent 2 0 R/MediaBox [0 0 100 100]/Annots [4 0 R]/Resources <</Font <</F1 6 0 R>>>>/LastModified (D:20200101) /UserUnit 2>>",
+		"<</Type/Annot/Subtype/Text/Rect [0 0 10 10]/Contents (note)/ADBE_Data [1 2 null]>>",
+		"<</Self 5 0 R/Value true>>",
+		"<</Type/Font/Subtype/Type1/BaseFont/Helvetica/Private /Value>>",
+	}
+	doc, _, err := ParsePDFReader(bytes.NewReader(buildPDF(objects)), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := doc.Catalog.Pages.Flatten()[0]
+	check := func(doc model.Document) {
+		t.Helper()
+		page := doc.Catalog.Pages.Flatten()[0]
+		if len(doc.Catalog.Custom) != 1 || len(page.Custom) != 2 || len(page.Annots[0].Custom) != 1 {
+			t.Fatalf("unexpected custom entries %v %v %v", doc.Catalog.Custom, page.Custom, page.Annots[0].Custom)
+		}
+		if page.Custom["UserUnit"] != model.ObjInt(2) {
+			t.Fatalf("unexpected page entries %v", page.Custom)
+		}
+		if exp := (model.ObjArray{model.ObjInt(1), model.ObjInt(2), model.ObjNull{}}); !reflect.DeepEqual(page.Annots[0].Custom["ADBE_Data"], exp) {
+			t.Fatalf("expected %v, got %v", exp, page.Annots[0].Custom)
+		}
+		if font := page.Resources.Font["F1"]; font.Custom["Private"] != model.Name("Value") {
+			t.Fatalf("unexpected font entries %v", font.Custom)
+		}
+		private := doc.Catalog.Custom["PieceInfo"].(model.ObjDict)["ADBE_Ext"].(model.ObjDict)["Private"].(model.ObjDict)
+		if private["Value"] != model.ObjBool(true) || private["Self"] != (model.ObjNull{}) {
+			t.Fatalf("unexpected cycle resolution %v", private)
+		}
+	}
+	check(doc)
+
+	if clone := doc.Clone(); !reflect.DeepEqual(clone.Catalog.Pages.Flatten()[0].Custom, page.Custom) {
+		t.Fatalf("clone should preserve the custom entries")
+	}
+
+	var out bytes.Buffer
+	if err = doc.Write(&out, nil); err != nil {
+		t.Fatal(err)
+	}
+	doc2, _, err := ParsePDFReader(bytes.NewReader(out.Bytes()), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(doc2)
+}
@@ -3,7 +3,6 @@ package file
 import (
 	"crypto/aes"
 	"crypto/cipher"
-	"crypto/md5"
 	"crypto/rc4"
 	"fmt"
 
@@ -121,31 +120,10 @@ func isSupportedCryptFilter(d model.CrypFilter) (bool, error) {
 	return cfm == "AESV2" || cfm == "AESV3", nil
 }
 
-// the returned key is 5 to 16 byte long
-func decryptKey(key []byte, objNumber, generationNumber int, useAES bool) []byte {
-	b := append(key,
-		byte(objNumber), byte(objNumber>>8), byte(objNumber>>16),
-		byte(generationNumber), byte(generationNumber>>8),
-	)
-
-	if useAES {
-		b = append(b, "sAlT"...)
-	}
-
-	dk := md5.Sum(b)
-
-	l := len(key) + 5
-	if l < 16 {
-		return dk[:l]
-	}
-
-	return dk[:]
-}
-
 // content may be overwritten
 func decryptBytes(content []byte, ref model.ObjIndirectRef, useAES bool, revision uint8, key []byte) ([]byte, error) {
 	if revision != 5 {
-		key = decryptKey(key, ref.ObjectNumber, ref.GenerationNumber, useAES)
+		key = model.ObjectEncryptionKey(key, ref.ObjectNumber, ref.GenerationNumber, useAES)
 	}
 
 	if useAES {
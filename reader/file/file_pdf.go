@@ -5,7 +5,9 @@
 package file
 
 import (
+	stdcontext "context"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 
@@ -41,6 +43,19 @@ type PDFFile struct {
 
 	// Encryption dictionary found in the trailer. Optionnal.
 	Encrypt *model.Encrypt
+
+	// TrailerEntries are the entries of the trailer
+	// not handled by this package (like private keys), which may
+	// contain indirect references.
+	// For incremental updates, the most recent value is used.
+	TrailerEntries model.ObjDict
+
+	// Warnings lists the non fatal issues encountered
+	// while reading the file, like duplicate object definitions.
+	Warnings []string
+
+	// lazy is not nil when the objects are resolved on demand (see Configuration.Lazy)
+	lazy *context
 }
 
 // IsString return the string and true if o is a StringLitteral (...) or a HexadecimalLitteral <...>.
@@ -62,6 +77,40 @@ type Configuration struct {
 	// TODO: We don't support changing permissions,
 	// so both password acts the same.
 	Password string
+
+	// RepairXref enables a fallback when the xref table
+	// is corrupted (or when its offsets are wrong): the whole
+	// file is then scanned for object declarations to rebuild it.
+	RepairXref bool
+
+	// PreferValidDuplicate changes the resolution of an object
+	// defined several times: by default, the most recent definition is used,
+	// even if it is invalid. If PreferValidDuplicate is true, the other
+	// definitions are tried when the chosen one fails to parse.
+	PreferValidDuplicate bool
+
+	// Logger, if not nil, receives the non fatal issues encountered
+	// while reading, instead of the default logger (see model.SetLogger).
+	Logger model.Logger
+
+	// Lazy, if true, only parses the objects when they are
+	// accessed through PDFFile.ResolveObject, instead of reading the whole file upfront.
+	// The source must then stay available while the PDFFile is used,
+	// which is not safe for concurrent use.
+	// It is ignored when the xref table has to be repaired.
+	Lazy bool
+
+	// Limits protects against malicious files.
+	Limits Limits
+
+	cancel interface{ Err() error } // optional, see ReadContext
+}
+
+func (conf *Configuration) logger() model.Logger {
+	if conf == nil {
+		return model.DefaultLogger()
+	}
+	return model.LoggerOrDefault(conf.Logger)
 }
 
 func NewDefaultConfiguration() *Configuration {
@@ -81,15 +130,39 @@ func ReadFile(file string, conf *Configuration) (PDFFile, error) {
 
 // Read process a PDF file, reading the xref table and loading
 // objects in memory.
+// If `conf.RepairXref` is true and the xref table is invalid,
+// the file is scanned to rebuild it.
 func Read(rs io.ReadSeeker, conf *Configuration) (PDFFile, error) {
+	return read(nil, rs, conf)
+}
+
+func read(cancel stdcontext.Context, rs io.ReadSeeker, conf *Configuration) (PDFFile, error) {
+	if cancel != nil {
+		withCancel := NewDefaultConfiguration()
+		if conf != nil {
+			*withCancel = *conf
+		}
+		withCancel.cancel = cancel
+		conf = withCancel
+	}
 	ctx, err := processPDFFile(rs, conf)
-	if err != nil {
-		return PDFFile{}, err
+	lazy := err == nil && conf != nil && conf.Lazy
+	if err == nil && !lazy {
+		err = ctx.processAllObjects()
 	}
 
-	err = ctx.processAllObjects()
 	if err != nil {
-		return PDFFile{}, err
+		var passwordErr IncorrectPasswordErr
+		if conf == nil || !conf.RepairXref || errors.As(err, &passwordErr) ||
+			errors.Is(err, ErrLimitExceeded) || errors.Is(err, parser.ErrTooDeep) || (conf.cancel != nil && conf.cancel.Err() != nil) {
+			return PDFFile{}, err
+		}
+
+		conf.logger().Log(model.LogWarning, "reading PDF file: trying to repair the xref table", "error", err)
+		ctx, err = repairPDFFile(rs, conf)
+		if err != nil {
+			return PDFFile{}, fmt.Errorf("can't repair xref table: %s", err)
+		}
 	}
 
 	if ctx.trailer.root == nil {
@@ -102,11 +175,17 @@ func Read(rs io.ReadSeeker, conf *Configuration) (PDFFile, error) {
 		AdditionalStreams: ctx.additionalStreams,
 		XrefTable:         make(XrefTable, len(ctx.xrefTable.objects)),
 		Info:              ctx.trailer.info,
+		TrailerEntries:    ctx.trailer.custom,
+		Warnings:          ctx.warnings,
+	}
+
+	if lazy {
+		out.lazy = ctx
 	}
 
 	for k, v := range ctx.xrefTable.objects {
-		// ignore free objects
-		if v.free {
+		// ignore free objects, and the ones not resolved yet in lazy mode
+		if v.free || v.object == nil {
 			continue
 		}
 		out.XrefTable[k.ObjectNumber] = v.object
@@ -146,5 +225,5 @@ func processPDFFile(rs io.ReadSeeker, conf *Configuration) (*context, error) {
 		return nil, err
 	}
 
-	return ctx, nil
+	return ctx, ctx.checkObjects()
 }
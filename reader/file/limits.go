@@ -0,0 +1,87 @@
+package file
+
+import (
+	stdcontext "context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/benoitkugler/pdf/reader/parser"
+	tok "github.com/benoitkugler/pstokenizer"
+)
+
+// ErrLimitExceeded is wrapped by the errors returned
+// when a file exceeds one of the Limits
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+// Limits protects the reading of untrusted files,
+// such as decompression bombs or deeply nested objects.
+// Zero values mean no limit.
+type Limits struct {
+	// MaxObjects is the maximum number of objects
+	// in the cross-reference table.
+	MaxObjects int
+
+	// MaxDepth is the maximum nesting of
+	// arrays and dictionaries (see parser.Parser.MaxDepth).
+	MaxDepth int
+
+	// MaxStreamSize is the maximum size, in bytes,
+	// of the streams decoded while reading the file (object
+	// and cross-reference streams, and the text and JavaScript streams
+	// decoded by the reader package).
+	MaxStreamSize int64
+}
+
+// checkObjects returns an error if the xref table has too many objects
+func (ctx *context) checkObjects() error {
+	if max := ctx.Limits.MaxObjects; max > 0 && len(ctx.xrefTable.objects) > max {
+		return fmt.Errorf("%w: too many objects (%d > %d)", ErrLimitExceeded, len(ctx.xrefTable.objects), max)
+	}
+	return nil
+}
+
+// readDecoded reads `r`, failing if more than MaxStreamSize bytes are decoded
+func (ctx *context) readDecoded(r io.Reader) ([]byte, error) {
+	return ReadLimited(r, ctx.Limits.MaxStreamSize)
+}
+
+// ReadLimited reads `r` until EOF, failing with an error wrapping
+// ErrLimitExceeded if more than `max` bytes are read.
+// A zero or negative `max` means no limit.
+func ReadLimited(r io.Reader, max int64) ([]byte, error) {
+	if max <= 0 {
+		return io.ReadAll(r)
+	}
+	out, err := io.ReadAll(io.LimitReader(r, max+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(out)) > max {
+		return nil, fmt.Errorf("%w: decoded stream exceeds %d bytes", ErrLimitExceeded, max)
+	}
+	return out, nil
+}
+
+// newParser returns a parser using the nesting limit
+func (ctx *context) newParser(tk *tok.Tokenizer) *parser.Parser {
+	p := parser.NewParserFromTokenizer(tk)
+	p.MaxDepth = ctx.Limits.MaxDepth
+	return p
+}
+
+// interrupted returns the error of the context passed to ReadContext,
+// if it has been cancelled
+func (ctx *context) interrupted() error {
+	if ctx.cancel == nil {
+		return nil
+	}
+	return ctx.cancel.Err()
+}
+
+// ReadContext is the same as Read, but stops with the error of `cancel`
+// (see context.Context.Err) as soon as it is cancelled.
+// The cancellation is checked before parsing each object.
+func ReadContext(cancel stdcontext.Context, rs io.ReadSeeker, conf *Configuration) (PDFFile, error) {
+	return read(cancel, rs, conf)
+}
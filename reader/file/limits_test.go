@@ -0,0 +1,56 @@
+package file
+
+import (
+	"bytes"
+	stdcontext "context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// buildNestedPDF returns a file whose object 2 is an array nested `depth` times
+func buildNestedPDF(depth int) []byte {
+	return buildPDF(testObject{1, "<</Type/Catalog>>"}, testObject{2, strings.Repeat("[", depth) + strings.Repeat("]", depth)})
+}
+
+func TestLimits(t *testing.T) {
+	data := buildNestedPDF(50)
+	if _, err := Read(bytes.NewReader(data), &Configuration{}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Read(bytes.NewReader(data), &Configuration{Limits: Limits{MaxObjects: 2}, RepairXref: true})
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expected limit error, got %v", err)
+	}
+	_, err = Read(bytes.NewReader(data), &Configuration{Limits: Limits{MaxDepth: 10}, RepairXref: true})
+	if err == nil || !strings.Contains(err.Error(), "depth") {
+		t.Fatalf("expected depth error, got %v", err)
+	}
+
+	ctx := context{Configuration: Configuration{Limits: Limits{MaxStreamSize: 10}}}
+	if _, err = ctx.readDecoded(bytes.NewReader(make([]byte, 10))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ctx.readDecoded(bytes.NewReader(make([]byte, 11))); !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expected limit error, got %v", err)
+	}
+}
+
+func TestReadContext(t *testing.T) {
+	data := buildNestedPDF(2)
+	file, err := ReadContext(stdcontext.Background(), bytes.NewReader(data), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(file.XrefTable) != 2 {
+		t.Fatalf("unexpected objects %v", file.XrefTable)
+	}
+
+	cancelled, cancel := stdcontext.WithCancel(stdcontext.Background())
+	cancel()
+	_, err = ReadContext(cancelled, bytes.NewReader(data), &Configuration{RepairXref: true})
+	if !errors.Is(err, stdcontext.Canceled) {
+		t.Fatalf("expected cancellation, got %v", err)
+	}
+}
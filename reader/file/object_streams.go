@@ -7,54 +7,59 @@ import (
 
 	"github.com/benoitkugler/pdf/model"
 	"github.com/benoitkugler/pdf/reader/parser"
+	tok "github.com/benoitkugler/pstokenizer"
 )
 
 // parsed version of an object stream
-type objectStream []parser.Object // with length N
+type objectStream struct {
+	numbers []int           // object numbers, with length N
+	objects []parser.Object // with length N
+}
 
 // check the cache and process the given object stream number
 func (ctx *context) processObjectStream(on int) (objectStream, error) {
 	if os, ok := ctx.xrefTable.objectStreams[on]; ok {
 		return os, nil
 	}
+	var out objectStream
 	// process the object stream
 
 	entry, ok := ctx.xrefTable.objects[model.ObjIndirectRef{ObjectNumber: on}]
 	if !ok {
-		return nil, fmt.Errorf("missing object stream for reference %d", on)
+		return out, fmt.Errorf("missing object stream for reference %d", on)
 	}
 
 	streamHeader, err := ctx.parseStreamDictAt(entry.offset)
 	if err != nil {
-		return nil, fmt.Errorf("invalid stream at %d; %s", entry.offset, err)
+		return out, fmt.Errorf("invalid stream at %d; %w", entry.offset, err)
 	}
 
 	filters, err := parser.ParseFilters(streamHeader.dict["Filter"], streamHeader.dict["DecodeParms"], ctx.resolve)
 	if err != nil {
-		return nil, fmt.Errorf("invalid object stream: %s", err)
+		return out, fmt.Errorf("invalid object stream: %w", err)
 	}
 
 	lengthO, err := ctx.resolve(streamHeader.dict["Length"])
 	if err != nil {
-		return nil, fmt.Errorf("invalid object stream Length: %s", err)
+		return out, fmt.Errorf("invalid object stream Length: %w", err)
 	}
 	length, ok := lengthO.(parser.Integer)
 	if !ok {
-		return nil, fmt.Errorf("invalid object stream Length: expected integer, got %T", lengthO)
+		return out, fmt.Errorf("invalid object stream Length: expected integer, got %T", lengthO)
 	}
 
 	// The generation number of an object stream and of any compressed object shall be zero.
 	decoded, err := ctx.decodeStreamContent(model.ObjIndirectRef{ObjectNumber: on}, filters, streamHeader.contentOffset, int(length))
 	if err != nil {
-		return nil, fmt.Errorf("invalid object stream: %s", err)
+		return out, fmt.Errorf("invalid object stream: %w", err)
 	}
 
 	firstObjectOffset, ok := streamHeader.dict["First"].(parser.Integer)
 	if !ok {
-		return nil, fmt.Errorf("invalid object stream First: expected integer, got %T", streamHeader.dict["First"])
+		return out, fmt.Errorf("invalid object stream First: expected integer, got %T", streamHeader.dict["First"])
 	}
 	if int(firstObjectOffset) > len(decoded) {
-		return nil, fmt.Errorf("out of bounds object stream First: %d > %d", firstObjectOffset, len(decoded))
+		return out, fmt.Errorf("out of bounds object stream First: %d > %d", firstObjectOffset, len(decoded))
 	}
 	prolog := decoded[:firstObjectOffset]
 
@@ -66,39 +71,44 @@ func (ctx *context) processObjectStream(on int) (objectStream, error) {
 	prolog = bytes.ReplaceAll(prolog, []byte{0x00}, []byte{0x20})
 	fields := bytes.Fields(prolog)
 	if len(fields)%2 != 0 {
-		return nil, fmt.Errorf("odd number of fields (%d) in object stream prolog", len(fields))
+		return out, fmt.Errorf("odd number of fields (%d) in object stream prolog", len(fields))
 	}
 
 	offsets := make([]int, len(fields)/2)
+	out.numbers = make([]int, len(fields)/2)
 	for i := range offsets {
+		out.numbers[i], err = strconv.Atoi(string(fields[2*i]))
+		if err != nil {
+			return out, fmt.Errorf("invalid object number in object stream: %v", fields[2*i])
+		}
 		offsets[i], err = strconv.Atoi(string(fields[2*i+1]))
 		if err != nil {
-			return nil, fmt.Errorf("invalid object offset in object stream: %v", fields[2*i+1])
+			return out, fmt.Errorf("invalid object offset in object stream: %v", fields[2*i+1])
 		}
 		offsets[i] += int(firstObjectOffset)
 		if offsets[i] > len(decoded) {
-			return nil, fmt.Errorf("invalid object offset in object stream: %d", offsets[i])
+			return out, fmt.Errorf("invalid object offset in object stream: %d", offsets[i])
 		}
 	}
 
-	objects := make(objectStream, len(offsets))
-	for i := range objects {
+	out.objects = make([]parser.Object, len(offsets))
+	for i := range out.objects {
 		start, end := offsets[i], len(decoded)
 		if i+1 < len(offsets) {
 			end = offsets[i+1]
 		}
 
-		objects[i], err = parser.ParseObject(decoded[start:end])
+		out.objects[i], err = ctx.newParser(tok.NewTokenizer(decoded[start:end])).ParseObject()
 		if err != nil {
-			return nil, fmt.Errorf("invalid object in object stream: %s", err)
+			return out, fmt.Errorf("invalid object in object stream: %w", err)
 		}
 	}
 
 	if _, has := streamHeader.dict["Extents"]; has {
-		return nil, fmt.Errorf("unsupported Extents in object stream")
+		return out, fmt.Errorf("unsupported Extents in object stream")
 	}
 
 	// cache it
-	ctx.xrefTable.objectStreams[on] = objects
-	return objects, nil
+	ctx.xrefTable.objectStreams[on] = out
+	return out, nil
 }
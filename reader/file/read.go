@@ -6,7 +6,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"strconv"
 	"strings"
 
@@ -24,6 +23,9 @@ type context struct {
 
 	Configuration
 
+	// non fatal issues
+	warnings []string
+
 	// PDF Version
 	HeaderVersion string // The PDF version the source is claiming to us as per its header.
 	xrefTable     xRefTableContext
@@ -65,6 +67,33 @@ type trailer struct {
 	info *parser.IndirectRef // optional
 	id   parser.Array        // required in encrypted docs
 	size int                 // Object count from PDF trailer dict.
+
+	custom parser.Dict // entries not handled by this package
+}
+
+// structuralTrailerKeys are the trailer (or xref stream) entries
+// describing the file structure, which are not preserved as custom entries
+var structuralTrailerKeys = map[parser.Name]bool{
+	"Size": true, "Prev": true, "Root": true, "Encrypt": true, "Info": true, "ID": true,
+	"XRefStm": true, "Type": true, "W": true, "Index": true,
+	"Filter": true, "DecodeParms": true, "Length": true,
+}
+
+// addCustom registers the unknown entries of `d`, without
+// overriding the existing ones, which are more recent
+func (current *trailer) addCustom(d parser.Dict) {
+	for k, v := range d {
+		if structuralTrailerKeys[k] {
+			continue
+		}
+		if _, has := current.custom[k]; has {
+			continue
+		}
+		if current.custom == nil {
+			current.custom = make(parser.Dict)
+		}
+		current.custom[k] = v
+	}
 }
 
 // allocate a slice with length `size` and read at `offset`
@@ -233,11 +262,12 @@ func (ctx *context) buildXRefTableStartingAt(offset int64) (err error) {
 		} else { // xref stream
 			offset, err = ctx.parseXRefStream(offset)
 			if err != nil {
-				log.Printf("reading PDF file: invalid xref stream (%s), trying fix\n", err)
+				ctx.logger().Log(model.LogWarning, "reading PDF file: invalid xref stream, trying fix", "error", err)
 				// Try fix for corrupt single xref section.
 				return ctx.bypassXrefSection()
 			}
 		}
+		ctx.xrefTable.currentSection++
 	}
 
 	return nil
@@ -248,7 +278,7 @@ func (ctx *context) buildXRefTableStartingAt(offset int64) (err error) {
 func (ctx *context) parseXRefSectionAndTrailer(tk *tok.Tokenizer, ssCount int) (int64, int, error) {
 	// Process all sub sections of this xRef section.
 	for {
-		err := ctx.xrefTable.parseXRefTableSubSection(tk)
+		err := ctx.parseXRefTableSubSection(tk)
 		if err != nil {
 			return 0, 0, err
 		}
@@ -274,7 +304,7 @@ func parseInt(tk *tok.Tokenizer) (int, error) {
 }
 
 // Process xRef table subsection and create corrresponding xRef table entries.
-func (xrefTable *xRefTableContext) parseXRefTableSubSection(tk *tok.Tokenizer) error {
+func (ctx *context) parseXRefTableSubSection(tk *tok.Tokenizer) error {
 	startObjNumber, err := parseInt(tk)
 	if err != nil {
 		return fmt.Errorf("parseXRefTableSubSection: invalid start object number %s", err)
@@ -287,7 +317,7 @@ func (xrefTable *xRefTableContext) parseXRefTableSubSection(tk *tok.Tokenizer) e
 
 	// Process all entries of this subsection into xrefTable entries.
 	for i := 0; i < objCount; i++ {
-		entry, generationNumber, err := xrefTable.parseXRefTableEntry(tk)
+		entry, generationNumber, err := parseXRefTableEntry(tk)
 		if err != nil {
 			return err
 		}
@@ -300,19 +330,16 @@ func (xrefTable *xRefTableContext) parseXRefTableSubSection(tk *tok.Tokenizer) e
 
 		ref := model.ObjIndirectRef{ObjectNumber: objectNumber, GenerationNumber: generationNumber}
 
-		// since we read the last xref table first, we skip potential
-		// older object definition
-		if _, exists := xrefTable.objects[ref]; !exists {
-			xrefTable.objects[ref] = entry
-		}
-
+		// since we read the last xref table first, potential
+		// older object definition are skipped
+		ctx.register(ref, entry)
 	}
 
 	return nil
 }
 
 // Read next subsection entry and generate corresponding xref table entry.
-func (xrefTable xRefTableContext) parseXRefTableEntry(tk *tok.Tokenizer) (*xrefEntry, int, error) {
+func parseXRefTableEntry(tk *tok.Tokenizer) (*xrefEntry, int, error) {
 	offsetTk, err := tk.NextToken()
 	if err != nil {
 		return nil, 0, err
@@ -341,7 +368,7 @@ func (xrefTable xRefTableContext) parseXRefTableEntry(tk *tok.Tokenizer) (*xrefE
 }
 
 func (ctx *context) processTrailer(tk *tok.Tokenizer) (int64, error) {
-	p := parser.NewParserFromTokenizer(tk)
+	p := ctx.newParser(tk)
 	o, err := p.ParseObject()
 	if err != nil {
 		return 0, err
@@ -454,6 +481,8 @@ func (current *trailer) parseTrailerInfo(d parser.Dict) error {
 		current.id = id
 	}
 
+	current.addCustom(d)
+
 	return nil
 }
 
@@ -551,12 +580,12 @@ func (ctx *context) bypassXrefSection() error {
 		} else { // look for a declaration object XXX XX obj
 			objNr, generation, err := parseObjectDeclaration(tk)
 			if err == nil {
-				ctx.xrefTable.objects[model.ObjIndirectRef{ObjectNumber: objNr, GenerationNumber: generation}] = &xrefEntry{
+				ctx.register(model.ObjIndirectRef{ObjectNumber: objNr, GenerationNumber: generation}, &xrefEntry{
 					// we do not account for potential whitespace
 					// is this an issue ?
 					offset: lineOffset,
 					free:   false,
-				}
+				})
 				withinObj = true
 			}
 		}
@@ -0,0 +1,317 @@
+package file
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/benoitkugler/pdf/model"
+	"github.com/benoitkugler/pdf/reader/parser"
+)
+
+// This file implements a repair mode, used when the xref table (or stream)
+// is corrupted, or when its offsets are wrong.
+// As other readers do, the whole file is scanned for object declarations
+// (N G obj), and the trailer is rebuilt from the remaining information.
+
+var (
+	// matches object declarations (N G obj)
+	reObjectDeclaration = regexp.MustCompile(`(\d+)[\x00\t\n\f\r ]+(\d+)[\x00\t\n\f\r ]+obj`)
+	// matches the special dictionaries needed to rebuild the trailer
+	// and to recover compressed objects
+	reSpecialType = regexp.MustCompile(`/Type[\x00\t\n\f\r ]*/(XRef|ObjStm|Catalog)`)
+)
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+
+// return true if `c` may end the "obj" keyword
+func isObjKeywordEnd(c byte) bool {
+	switch c {
+	case 0, '\t', '\n', '\f', '\r', ' ', '<', '[', '(', '/', '%':
+		return true
+	default:
+		return false
+	}
+}
+
+// span of the raw content of streams, used
+// to ignore object declaration in (binary) stream data
+type streamSpan struct {
+	start, end int
+}
+
+// returns the sorted list of stream contents
+func findStreamSpans(data []byte) []streamSpan {
+	var (
+		out    []streamSpan
+		cursor int
+	)
+	for {
+		index := bytes.Index(data[cursor:], []byte("stream"))
+		if index == -1 {
+			return out
+		}
+		start := cursor + index + len("stream")
+		cursor = start
+		// skip "endstream" keywords
+		if isEndstream := start >= len("endstream") && bytes.HasSuffix(data[:start], []byte("endstream")); isEndstream {
+			continue
+		}
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end == -1 { // corrupted stream: do not ignore the end of the file
+			continue
+		}
+		out = append(out, streamSpan{start: start, end: start + end})
+		cursor = start + end + len("endstream")
+	}
+}
+
+// return the index of the span containing `pos`, or -1
+func searchSpan(spans []streamSpan, pos int) int {
+	i := sort.Search(len(spans), func(i int) bool { return spans[i].end > pos })
+	if i < len(spans) && spans[i].start <= pos {
+		return i
+	}
+	return -1
+}
+
+// completeWith fills the missing fields of `current` with the entries
+// found in `d`. As opposed to `parseTrailerInfo`, missing entries
+// are not considered as errors.
+func (current *trailer) completeWith(d parser.Dict) {
+	if enc := d["Encrypt"]; enc != nil && current.encrypt == nil {
+		current.encrypt = enc
+	}
+	if size, ok := d["Size"].(parser.Integer); ok && current.size == 0 {
+		current.size = int(size)
+	}
+	if root, ok := d["Root"].(parser.IndirectRef); ok && current.root == nil {
+		current.root = &root
+	}
+	if info, ok := d["Info"].(parser.IndirectRef); ok && current.info == nil {
+		current.info = &info
+	}
+	if id, ok := d["ID"].(parser.Array); ok && current.id == nil {
+		current.id = id
+	}
+	current.addCustom(d)
+}
+
+// repairXrefTable rebuilds the xref table from scratch, by scanning
+// the whole file for object declarations.
+// When an object number is defined several times, the last definition wins,
+// as with incremental updates.
+// The trailer is rebuilt using the trailer dictionaries and the xref streams found, from the
+// last to the first. As a last resort, the Catalog is used as Root.
+// The object numbers of the object streams found are returned, so that
+// their content may be registered once the encryption is setup (see `registerObjectStreams`).
+func (ctx *context) repairXrefTable() ([]int, error) {
+	ctx.xrefTable = newXRefTable()
+	ctx.trailer = trailer{}
+
+	data, err := ctx.readAt(int(ctx.fileSize), 0)
+	if err != nil {
+		return nil, err
+	}
+
+	spans := findStreamSpans(data)
+
+	// sorted by increasing offset
+	type declaration struct {
+		offset int
+		ref    model.ObjIndirectRef
+	}
+	var declarations []declaration
+
+	for _, match := range reObjectDeclaration.FindAllSubmatchIndex(data, -1) {
+		start, end := match[0], match[1]
+		if start > 0 && isDigit(data[start-1]) { // part of a larger number
+			continue
+		}
+		if end < len(data) && !isObjKeywordEnd(data[end]) { // not a keyword
+			continue
+		}
+		if searchSpan(spans, start) != -1 { // inside a stream
+			continue
+		}
+		objNumber, err := strconv.Atoi(string(data[match[2]:match[3]]))
+		if err != nil {
+			continue
+		}
+		generation, err := strconv.Atoi(string(data[match[4]:match[5]]))
+		if err != nil {
+			continue
+		}
+
+		// the last definition wins: remove the previous one, which may have a
+		// different generation number, keeping it as fallback
+		ref := model.ObjIndirectRef{ObjectNumber: objNumber, GenerationNumber: generation}
+		if previousRef, has := ctx.xrefTable.numbers[objNumber]; has {
+			ctx.xrefTable.duplicates[objNumber] = append(ctx.xrefTable.duplicates[objNumber],
+				duplicateEntry{ref: previousRef, entry: ctx.xrefTable.objects[previousRef]})
+			delete(ctx.xrefTable.objects, previousRef)
+		}
+		ctx.xrefTable.numbers[objNumber] = ref
+		ctx.xrefTable.objects[ref] = &xrefEntry{offset: int64(start)}
+		declarations = append(declarations, declaration{offset: start, ref: ref})
+	}
+
+	if len(declarations) == 0 {
+		return nil, errors.New("repairing xref table: no object found")
+	}
+
+	// trailer dictionaries, from the last
+	for cursor := len(data); ; {
+		index := bytes.LastIndex(data[:cursor], []byte("trailer"))
+		if index == -1 {
+			break
+		}
+		cursor = index
+		if searchSpan(spans, index) != -1 { // inside a stream
+			continue
+		}
+		tk := ctx.tokenizerBytes(data[index+len("trailer"):])
+		o, err := ctx.newParser(tk).ParseObject()
+		if d, isDict := o.(parser.Dict); err == nil && isDict {
+			ctx.trailer.completeWith(d)
+		}
+	}
+
+	// returns the last object declared before `pos`
+	ownerOf := func(pos int) (declaration, bool) {
+		i := sort.Search(len(declarations), func(i int) bool { return declarations[i].offset > pos })
+		if i == 0 {
+			return declaration{}, false
+		}
+		return declarations[i-1], true
+	}
+
+	var (
+		xrefStreams    []int64
+		objectStreams  []int
+		catalog        *model.ObjIndirectRef
+		seenOwners     = make(map[int]bool)
+		specialMatches = reSpecialType.FindAllSubmatchIndex(data, -1)
+	)
+	for _, match := range specialMatches {
+		if searchSpan(spans, match[0]) != -1 { // inside a stream
+			continue
+		}
+		owner, ok := ownerOf(match[0])
+		if !ok || seenOwners[owner.offset] {
+			continue
+		}
+		// only consider objects which have not been redefined later
+		if entry := ctx.xrefTable.objects[owner.ref]; entry == nil || entry.offset != int64(owner.offset) {
+			continue
+		}
+		seenOwners[owner.offset] = true
+		switch string(data[match[2]:match[3]]) {
+		case "XRef":
+			xrefStreams = append(xrefStreams, int64(owner.offset))
+		case "ObjStm":
+			objectStreams = append(objectStreams, owner.ref.ObjectNumber)
+		case "Catalog":
+			ref := owner.ref
+			catalog = &ref
+		}
+	}
+
+	// xref streams, from the last
+	for i := len(xrefStreams) - 1; i >= 0; i-- {
+		header, err := ctx.parseStreamDictAt(xrefStreams[i])
+		if err != nil {
+			continue
+		}
+		ctx.trailer.completeWith(header.dict)
+		// xref streams are not regular objects
+		delete(ctx.xrefTable.objects, header.ref)
+		delete(ctx.xrefTable.numbers, header.ref.ObjectNumber)
+	}
+
+	if ctx.trailer.root == nil && catalog != nil {
+		ctx.trailer.root = catalog
+	}
+
+	return objectStreams, nil
+}
+
+// registerObjectStreams adds the objects compressed in the given
+// object streams, unless they are already defined as regular objects.
+// It must be called after the encryption setup.
+func (ctx *context) registerObjectStreams(objectStreams []int) {
+	for _, on := range objectStreams {
+		ob, err := ctx.processObjectStream(on)
+		if err != nil {
+			ctx.logger().Log(model.LogWarning, "repairing xref table: invalid object stream", "object", on, "error", err)
+			continue
+		}
+		for index, number := range ob.numbers {
+			if _, has := ctx.xrefTable.numbers[number]; has {
+				continue
+			}
+			ref := model.ObjIndirectRef{ObjectNumber: number}
+			ctx.xrefTable.numbers[number] = ref
+			ctx.xrefTable.objects[ref] = &xrefEntry{streamObjectNumber: on, streamObjectIndex: index}
+		}
+	}
+}
+
+// processAllObjectsLenient is the same as `processAllObjects`, but
+// invalid objects are replaced by null instead of failing.
+func (ctx *context) processAllObjectsLenient() error {
+	for on, entry := range ctx.xrefTable.objects {
+		if entry.free {
+			continue
+		}
+		if err := ctx.interrupted(); err != nil {
+			return err
+		}
+
+		_, err := ctx.resolveObjectNumber(on)
+		if err != nil {
+			ctx.logger().Log(model.LogWarning, "repairing xref table: invalid object", "object", on, "error", err)
+			entry.object = model.ObjNull{}
+		}
+	}
+	return nil
+}
+
+// repairPDFFile builds the xref table by scanning the whole file,
+// and setup the encryption.
+func repairPDFFile(rs io.ReadSeeker, conf *Configuration) (*context, error) {
+	ctx, err := newContext(rs, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx.HeaderVersion, err = headerVersion(ctx.rs, "%PDF-")
+	if err != nil {
+		return nil, err
+	}
+
+	objectStreams, err := ctx.repairXrefTable()
+	if err != nil {
+		return nil, err
+	}
+
+	err = ctx.setupEncryption()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx.registerObjectStreams(objectStreams)
+
+	if err = ctx.checkObjects(); err != nil {
+		return nil, err
+	}
+
+	if err = ctx.processAllObjectsLenient(); err != nil {
+		return nil, err
+	}
+
+	return ctx, nil
+}
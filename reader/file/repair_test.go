@@ -0,0 +1,87 @@
+package file
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+
+	"github.com/benoitkugler/pdf/model"
+)
+
+func samplePDF(t *testing.T) []byte {
+	var doc model.Document
+	doc.Trailer.Info.Title = "Repair"
+	doc.Catalog.Pages.Kids = []model.PageNode{
+		&model.PageObject{MediaBox: &model.Rectangle{Urx: 100, Ury: 100}},
+		&model.PageObject{MediaBox: &model.Rectangle{Urx: 200, Ury: 200}},
+	}
+	var buf bytes.Buffer
+	if err := doc.Write(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRepairWrongOffsets(t *testing.T) {
+	data := samplePDF(t)
+	// shift all the offsets
+	data = regexp.MustCompile(`(?m)^0000(\d{6}) 00000 n`).ReplaceAll(data, []byte("0001$1 00000 n"))
+
+	if _, err := Read(bytes.NewReader(data), nil); err == nil {
+		t.Fatal("expected error on corrupted xref table")
+	}
+
+	file, err := Read(bytes.NewReader(data), &Configuration{RepairXref: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Info == nil {
+		t.Fatal("missing Info")
+	}
+	catalog, _ := file.ResolveObject(file.Root).(model.ObjDict)
+	if catalog["Type"] != model.ObjName("Catalog") {
+		t.Fatalf("invalid catalog %v", catalog)
+	}
+	pages, _ := file.ResolveObject(catalog["Pages"]).(model.ObjDict)
+	if pages["Count"] != model.ObjInt(2) {
+		t.Fatalf("invalid pages %v", pages)
+	}
+}
+
+func TestRepairMissingTrailer(t *testing.T) {
+	data := samplePDF(t)
+	// remove the xref table and the trailer
+	data = data[:bytes.Index(data, []byte("xref"))]
+
+	if _, err := Read(bytes.NewReader(data), nil); err == nil {
+		t.Fatal("expected error on missing xref table")
+	}
+
+	file, err := Read(bytes.NewReader(data), &Configuration{RepairXref: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	catalog, _ := file.ResolveObject(file.Root).(model.ObjDict)
+	if catalog["Type"] != model.ObjName("Catalog") {
+		t.Fatalf("invalid catalog %v", catalog)
+	}
+}
+
+func TestRepairLastDefinitionWins(t *testing.T) {
+	data := []byte("%PDF-1.7\n1 0 obj\n<</Type/Catalog>>\nendobj\n2 0 obj\n(old)\nendobj\n" +
+		"3 0 obj\n<</Length 12>>stream\n2 0 obj (x) \nendstream\nendobj\n" +
+		"2 0 obj\n(new)\nendobj\n")
+	file, err := Read(bytes.NewReader(data), &Configuration{RepairXref: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := IsString(file.XrefTable[2]); s != "new" {
+		t.Fatalf("unexpected object %v", file.XrefTable[2])
+	}
+	if file.Root.ObjectNumber != 1 {
+		t.Fatalf("unexpected Root %v", file.Root)
+	}
+	if _, ok := file.XrefTable[3].(model.ObjStream); !ok {
+		t.Fatalf("unexpected stream %v", file.XrefTable[3])
+	}
+}
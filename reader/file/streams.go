@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"fmt"
 	"io"
-	"log"
 
 	"github.com/benoitkugler/pdf/model"
 	"github.com/benoitkugler/pdf/reader/parser"
@@ -29,10 +28,10 @@ func (ctx *context) parseStreamDictAt(offset int64) (out streamDictHeader, err e
 	}
 
 	// parse this object
-	pr := parser.NewParserFromTokenizer(tk)
+	pr := ctx.newParser(tk)
 	o, err := pr.ParseObject()
 	if err != nil {
-		return out, fmt.Errorf("parseStreamDict: no object: %s", err)
+		return out, fmt.Errorf("parseStreamDict: no object: %w", err)
 	}
 
 	d, ok := o.(parser.Dict)
@@ -70,7 +69,7 @@ func (ctx *context) extractStreamContent(filters model.Filters, offset int64, ex
 	if err != nil {
 		// if the filtered content is badly formatted, try again
 		// with the heuristic approaches
-		log.Printf("reading PDF filtered stream : %s. trying to fix\n", err)
+		ctx.logger().Log(model.LogWarning, "reading PDF filtered stream: trying to fix", "error", err)
 
 		return ctx.readStreamFromLength(offset, expectedLength)
 	}
@@ -83,7 +82,7 @@ func (ctx *context) extractStreamContent(filters model.Filters, offset int64, ex
 func (ctx *context) decodeStreamContent(ref model.ObjIndirectRef, filters model.Filters, offset int64, expectedLengthPlain int) (content []byte, err error) {
 	content, err = ctx.extractStreamContent(filters, offset, expectedLengthPlain)
 	if err != nil {
-		return nil, fmt.Errorf("invalid stream content: %s", err)
+		return nil, fmt.Errorf("invalid stream content: %w", err)
 	}
 
 	if ctx.enc != nil {
@@ -92,7 +91,7 @@ func (ctx *context) decodeStreamContent(ref model.ObjIndirectRef, filters model.
 		} else {
 			content, err = ctx.enc.decryptStream(content, ref)
 			if err != nil {
-				return nil, fmt.Errorf("invalid stream content: %s", err)
+				return nil, fmt.Errorf("invalid stream content: %w", err)
 			}
 		}
 	}
@@ -100,9 +99,9 @@ func (ctx *context) decodeStreamContent(ref model.ObjIndirectRef, filters model.
 	// Decode stream content:
 	r, err := filters.DecodeReader(bytes.NewReader(content))
 	if err != nil {
-		return nil, fmt.Errorf("invalid stream content: %s", err)
+		return nil, fmt.Errorf("invalid stream content: %w", err)
 	}
-	return io.ReadAll(r)
+	return ctx.readDecoded(r)
 }
 
 // readStreamFromLength try to locate the end of the stream using `expectedLength`,
@@ -186,7 +185,7 @@ func (ctx *context) readStreamWithEOD(filter model.Filter, offset int64) ([]byte
 	}
 	_, err = ctx.rs.Seek(offset, io.SeekStart)
 	if err != nil {
-		return nil, fmt.Errorf("invalid stream offset %d: %s", offset, err)
+		return nil, fmt.Errorf("invalid stream offset %d: %w", offset, err)
 	}
 	trueLength, err := skipper.Skip(ctx.rs)
 	if err != nil {
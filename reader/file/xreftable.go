@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
-	"io"
 
 	"github.com/benoitkugler/pdf/model"
 	"github.com/benoitkugler/pdf/reader/parser"
@@ -32,6 +31,29 @@ func (table XrefTable) ResolveObject(o parser.Object) parser.Object {
 	return model.ObjNull{}
 }
 
+// ResolveObject use the xref table to resolve indirect reference,
+// parsing the object if needed when the file is read lazily (see Configuration.Lazy).
+// Invalid objects are logged and resolved as ObjNull{}.
+func (f PDFFile) ResolveObject(o parser.Object) parser.Object {
+	if f.lazy == nil {
+		return f.XrefTable.ResolveObject(o)
+	}
+	ref, ok := o.(parser.IndirectRef)
+	if !ok {
+		return o // return the direct object as it is
+	}
+	registered, has := f.lazy.xrefTable.numbers[ref.ObjectNumber]
+	if !has || f.lazy.xrefTable.objects[registered].free {
+		return model.ObjNull{}
+	}
+	object, err := f.lazy.resolveObjectNumber(registered)
+	if err != nil {
+		f.lazy.warnf("invalid object %v: %s", registered, err)
+		return model.ObjNull{}
+	}
+	return object
+}
+
 // xRefTableContext is the main access to PDF objects.
 // it is only used during the processing (see xrefTable for the final object)
 type xRefTableContext struct {
@@ -41,10 +63,73 @@ type xRefTableContext struct {
 	// object stream are special cases since we
 	// don't wan't to process them for each object they contain
 	objectStreams map[int]objectStream
+
+	// object number -> reference of the entry registered in `objects`
+	numbers map[int]parser.IndirectRef
+
+	// object number -> discarded definitions, used
+	// as fallback when `Configuration.PreferValidDuplicate` is true
+	duplicates map[int][]duplicateEntry
+
+	// index of the xref section being read,
+	// starting at 0 for the last one (the most recent)
+	currentSection int
+}
+
+type duplicateEntry struct {
+	ref   parser.IndirectRef
+	entry *xrefEntry
 }
 
 func newXRefTable() xRefTableContext {
-	return xRefTableContext{objects: make(map[parser.IndirectRef]*xrefEntry), objectStreams: make(map[int]objectStream)}
+	return xRefTableContext{
+		objects:       make(map[parser.IndirectRef]*xrefEntry),
+		objectStreams: make(map[int]objectStream),
+		numbers:       make(map[int]parser.IndirectRef),
+		duplicates:    make(map[int][]duplicateEntry),
+	}
+}
+
+// register adds `entry` to the xref table, applying the following policy
+// when the object number is already defined:
+//   - an entry from an older section (see 7.5.6 - Incremental Updates)
+//     never overrides an entry from a more recent one
+//   - in the same section, the last definition wins (as Acrobat does), and a warning
+//     is emitted
+//   - a free entry of the same section is silently replaced: this is how the
+//     XRefStm of an hybrid file defines the objects hidden from the classic table
+//
+// In the first two cases, the discarded definition is kept as fallback (see `Configuration.PreferValidDuplicate`).
+func (ctx *context) register(ref parser.IndirectRef, entry *xrefEntry) {
+	table := &ctx.xrefTable
+	entry.section = table.currentSection
+	number := ref.ObjectNumber
+	if previousRef, has := table.numbers[number]; has {
+		previous := table.objects[previousRef]
+		if previous.section != entry.section { // more recent definition: keep it
+			table.duplicates[number] = append(table.duplicates[number], duplicateEntry{ref: ref, entry: entry})
+			return
+		}
+		// same section
+		if previous.free && !entry.free { // hidden object of an hybrid file
+			delete(table.objects, previousRef)
+			table.numbers[number] = ref
+			table.objects[ref] = entry
+			return
+		}
+		ctx.warnf("object %d is defined several times in the same xref section: using the last definition", number)
+		delete(table.objects, previousRef)
+		table.duplicates[number] = append(table.duplicates[number], duplicateEntry{ref: previousRef, entry: previous})
+	}
+	table.numbers[number] = ref
+	table.objects[ref] = entry
+}
+
+// warnf logs a non fatal issue, and records it in `ctx.warnings`
+func (ctx *context) warnf(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	ctx.logger().Log(model.LogWarning, "reading PDF file: "+msg)
+	ctx.warnings = append(ctx.warnings, msg)
 }
 
 // populate object field of the xrefTable
@@ -84,6 +169,36 @@ func (ctx *context) resolveObjectNumber(objRef model.ObjIndirectRef) (parser.Obj
 		return entry.object, nil
 	}
 
+	if err := ctx.interrupted(); err != nil {
+		return nil, err
+	}
+
+	object, err := ctx.resolveEntry(objRef, entry)
+	if err == nil || !ctx.PreferValidDuplicate {
+		return object, err
+	}
+
+	// try the other definitions, if any
+	for _, duplicate := range ctx.xrefTable.duplicates[objRef.ObjectNumber] {
+		if duplicate.entry.free {
+			continue
+		}
+		object, errDuplicate := ctx.resolveEntry(duplicate.ref, duplicate.entry)
+		if errDuplicate == nil {
+			ctx.warnf("invalid object %v (%s): using another definition", objRef, err)
+			entry.object = object
+			return object, nil
+		}
+	}
+	return object, err
+}
+
+// resolveEntry actually parses the object, and stores it in `entry`.
+func (ctx *context) resolveEntry(objRef model.ObjIndirectRef, entry *xrefEntry) (parser.Object, error) {
+	if entry.object != nil { // already resolved
+		return entry.object, nil
+	}
+
 	isCompressedObject := entry.streamObjectNumber != 0
 	// Actually resolve the object. There are two cases:
 	//	- the object is compressed inside an object stream
@@ -98,25 +213,25 @@ func (ctx *context) resolveObjectNumber(objRef model.ObjIndirectRef) (parser.Obj
 			return nil, err
 		}
 
-		if entry.streamObjectIndex >= len(ob) {
-			return nil, fmt.Errorf("invalid object index (%d >= %d)", entry.streamObjectIndex, len(ob))
+		if entry.streamObjectIndex >= len(ob.objects) {
+			return nil, fmt.Errorf("invalid object index (%d >= %d)", entry.streamObjectIndex, len(ob.objects))
 		}
 
-		entry.object = ob[entry.streamObjectIndex]
+		entry.object = ob.objects[entry.streamObjectIndex]
 	} else {
 		tk, err := ctx.tokenizerAt(entry.offset)
 		if err != nil {
-			return nil, fmt.Errorf("invalid offset in xref table (%d): %s", entry.offset, err)
+			return nil, fmt.Errorf("invalid offset in xref table (%d): %w", entry.offset, err)
 		}
 
 		_, _, err = parseObjectDeclaration(tk)
 		if err != nil {
-			return nil, fmt.Errorf("invalid object declaration (%v): %s", objRef, err)
+			return nil, fmt.Errorf("invalid object declaration (%v): %w", objRef, err)
 		}
 
-		entry.object, err = parser.NewParserFromTokenizer(tk).ParseObject()
+		entry.object, err = ctx.newParser(tk).ParseObject()
 		if err != nil {
-			return nil, fmt.Errorf("invalid object content (%v): %s", objRef, err)
+			return nil, fmt.Errorf("invalid object content (%v): %w", objRef, err)
 		}
 
 		// stream object are dict with an additional content : lookup up for them
@@ -128,12 +243,12 @@ func (ctx *context) resolveObjectNumber(objRef model.ObjIndirectRef) (parser.Obj
 
 			filters, err := parser.ParseFilters(streamHeader["Filter"], streamHeader["DecodeParms"], ctx.resolve)
 			if err != nil {
-				return nil, fmt.Errorf("invalid stream: %s", err)
+				return nil, fmt.Errorf("invalid stream: %w", err)
 			}
 
 			lengthO, err := ctx.resolve(streamHeader["Length"])
 			if err != nil {
-				return nil, fmt.Errorf("invalid stream Length: %s", err)
+				return nil, fmt.Errorf("invalid stream Length: %w", err)
 			}
 			length, ok := lengthO.(parser.Integer)
 			if !ok {
@@ -143,7 +258,7 @@ func (ctx *context) resolveObjectNumber(objRef model.ObjIndirectRef) (parser.Obj
 			// we want the cryted not decoded content
 			content, err := ctx.extractStreamContent(filters, streamPosition, int(length))
 			if err != nil {
-				return nil, fmt.Errorf("failed to resolve %v: %s", objRef, err)
+				return nil, fmt.Errorf("failed to resolve %v: %w", objRef, err)
 			}
 
 			entry.object = model.ObjStream{Args: streamHeader, Content: content}
@@ -167,6 +282,8 @@ type xrefEntry struct {
 	free   bool // if true, won't be resolved
 	offset int64
 
+	section int // index of the defining xref section, 0 for the most recent
+
 	// for object in object streams
 	streamObjectNumber int // The object number of the object stream in which this object is stored.
 	streamObjectIndex  int // The index of this object within the object stream.
@@ -233,7 +350,7 @@ func (ctx *context) xRefStreamDict(d parser.Dict, streamOffset int64) (xrefStrea
 	if err != nil {
 		return details, nil, err
 	}
-	decoded, err := io.ReadAll(r)
+	decoded, err := ctx.readDecoded(r)
 	if err != nil {
 		return details, nil, err
 	}
@@ -305,10 +422,7 @@ func (ctx *context) extractXRefTableEntriesFromXRefStream(buf []byte, xrefDict x
 			}
 
 			ref := model.ObjIndirectRef{ObjectNumber: objectNumber, GenerationNumber: generation}
-			// skip already assigned
-			if _, has := ctx.xrefTable.objects[ref]; !has {
-				ctx.xrefTable.objects[ref] = &xRefTableEntry
-			}
+			ctx.register(ref, &xRefTableEntry)
 			j++
 		}
 	}
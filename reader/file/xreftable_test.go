@@ -1,8 +1,12 @@
 package file
 
 import (
+	"bytes"
+	"fmt"
 	"os"
 	"testing"
+
+	"github.com/benoitkugler/pdf/model"
 )
 
 func TestXrefStream(t *testing.T) {
@@ -27,3 +31,162 @@ func TestXrefStream(t *testing.T) {
 		}
 	}
 }
+
+// buildPDF returns a file with the given objects, written in order,
+// the object 1 being the catalog. Each object is registered in its own
+// subsection of the xref table, so that an object number may be defined twice.
+func buildPDF(objects ...testObject) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n")
+	offsets := make([]int, len(objects))
+	size := 1
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", obj.number, obj.content)
+		if obj.number >= size {
+			size = obj.number + 1
+		}
+	}
+	xref := buf.Len()
+	buf.WriteString("xref\n0 1\n0000000000 65535 f\r\n")
+	for i, obj := range objects {
+		fmt.Fprintf(&buf, "%d 1\n%010d 00000 n\r\n", obj.number, offsets[i])
+	}
+	fmt.Fprintf(&buf, "trailer\n<</Size %d/Root 1 0 R>>\nstartxref\n%d\n%%%%EOF\n", size, xref)
+	return buf.Bytes()
+}
+
+type testObject struct {
+	number  int
+	content string
+}
+
+// buildDuplicatePDF returns a file whose xref table defines the
+// object 2 twice, the last pointing to `last`.
+func buildDuplicatePDF(last string) []byte {
+	return buildPDF(testObject{1, "<</Type/Catalog>>"}, testObject{2, "(first)"}, testObject{2, last})
+}
+
+func TestDuplicateLastWins(t *testing.T) {
+	file, err := Read(bytes.NewReader(buildDuplicatePDF("(last)")), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := IsString(file.XrefTable[2]); s != "last" {
+		t.Fatalf("unexpected object %v", file.XrefTable[2])
+	}
+	if len(file.Warnings) != 1 {
+		t.Fatalf("expected one warning, got %v", file.Warnings)
+	}
+}
+
+func TestDuplicatePreferValid(t *testing.T) {
+	data := buildDuplicatePDF("<</Invalid")
+	if _, err := Read(bytes.NewReader(data), nil); err == nil {
+		t.Fatal("expected error on invalid object")
+	}
+
+	file, err := Read(bytes.NewReader(data), &Configuration{PreferValidDuplicate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := IsString(file.XrefTable[2]); s != "first" {
+		t.Fatalf("unexpected object %v", file.XrefTable[2])
+	}
+	if len(file.Warnings) != 2 {
+		t.Fatalf("expected two warnings, got %v", file.Warnings)
+	}
+}
+
+// buildHybridPDF returns a file whose classic xref table lists the object 2
+// as free, the object being defined by the cross-reference stream of the XRefStm entry.
+func buildHybridPDF() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.5\n")
+	catalog := buf.Len()
+	buf.WriteString("1 0 obj\n<</Type/Catalog>>\nendobj\n")
+	hidden := buf.Len()
+	buf.WriteString("2 0 obj\n(hidden)\nendobj\n")
+	xrefStream := buf.Len()
+	entry := []byte{1, byte(hidden >> 24), byte(hidden >> 16), byte(hidden >> 8), byte(hidden), 0}
+	fmt.Fprintf(&buf, "3 0 obj\n<</Type/XRef/Size 4/W [1 4 1]/Index [2 1]/Length %d>>\nstream\n", len(entry))
+	buf.Write(entry)
+	buf.WriteString("\nendstream\nendobj\n")
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 4\n0000000000 65535 f\r\n%010d 00000 n\r\n0000000000 00001 f\r\n%010d 00000 n\r\n", catalog, xrefStream)
+	fmt.Fprintf(&buf, "trailer\n<</Size 4/Root 1 0 R/XRefStm %d>>\nstartxref\n%d\n%%%%EOF\n", xrefStream, xref)
+	return buf.Bytes()
+}
+
+func TestHybridFile(t *testing.T) {
+	file, err := Read(bytes.NewReader(buildHybridPDF()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := IsString(file.XrefTable[2]); s != "hidden" {
+		t.Fatalf("unexpected object %v", file.XrefTable[2])
+	}
+	if len(file.Warnings) != 0 {
+		t.Fatalf("unexpected warnings %v", file.Warnings)
+	}
+}
+
+func TestLazy(t *testing.T) {
+	data := buildDuplicatePDF("<</Invalid")
+	file, err := Read(bytes.NewReader(data), &Configuration{Lazy: true})
+	if err != nil {
+		t.Fatal(err) // the invalid object is not parsed
+	}
+	if len(file.XrefTable) != 0 {
+		t.Fatalf("expected no resolved object, got %v", file.XrefTable)
+	}
+	if _, ok := file.ResolveObject(file.Root).(model.ObjDict); !ok {
+		t.Fatalf("unexpected Root %v", file.ResolveObject(file.Root))
+	}
+	if o := file.ResolveObject(model.ObjIndirectRef{ObjectNumber: 2}); o != (model.ObjNull{}) {
+		t.Fatalf("expected null for invalid object, got %v", o)
+	}
+	if o := file.ResolveObject(model.ObjIndirectRef{ObjectNumber: 10}); o != (model.ObjNull{}) {
+		t.Fatalf("expected null for undefined object, got %v", o)
+	}
+}
+
+func TestWriteTrailerEntries(t *testing.T) {
+	var doc model.Document
+	doc.Catalog.Pages.Kids = []model.PageNode{&model.PageObject{}}
+	doc.Trailer.Custom = model.ObjDict{"MyKey": model.ObjStringLiteral("value"), "Size": model.ObjInt(1)}
+	hook := func(w model.PDFWritter) map[model.Name]string {
+		ref := w.CreateObject()
+		w.WriteObject("<</Private true>>", ref)
+		return map[model.Name]string{"MyObject": ref.String(), "Root": "null"}
+	}
+
+	for _, xrefStream := range []bool{false, true} {
+		var buf bytes.Buffer
+		err := doc.WriteWithOptions(&buf, nil, model.WriteOptions{XRefStream: xrefStream, TrailerHook: hook})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := bytes.Contains(buf.Bytes(), []byte("/Type /XRef")); got != xrefStream {
+			t.Fatalf("unexpected cross-reference format")
+		}
+
+		file, err := Read(bytes.NewReader(buf.Bytes()), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(file.TrailerEntries) != 2 {
+			t.Fatalf("unexpected trailer entries %v", file.TrailerEntries)
+		}
+		if s, _ := IsString(file.TrailerEntries["MyKey"]); s != "value" {
+			t.Fatalf("unexpected custom entry %v", file.TrailerEntries["MyKey"])
+		}
+		obj, _ := file.ResolveObject(file.TrailerEntries["MyObject"]).(model.ObjDict)
+		if obj["Private"] != model.ObjBool(true) {
+			t.Fatalf("unexpected custom object %v", obj)
+		}
+		if _, isCatalog := file.ResolveObject(file.Root).(model.ObjDict); !isCatalog {
+			t.Fatal("invalid Root")
+		}
+	}
+}
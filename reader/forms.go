@@ -1,8 +1,9 @@
 package reader
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
-	"log"
 
 	"github.com/benoitkugler/pdf/model"
 	"github.com/benoitkugler/pdf/reader/file"
@@ -41,7 +42,10 @@ func (r resolver) processAcroForm(acroForm model.Object) (model.AcroForm, error)
 				return out, errType("Field reference for CO", c)
 			}
 			// we just ignore invalid reference
-			if field := r.formFields[ref]; field != nil {
+			r.lock()
+			field := r.formFields[ref]
+			r.unlock()
+			if field != nil {
 				out.CO = append(out.CO, field)
 			}
 		}
@@ -68,14 +72,17 @@ func (r resolver) processAcroForm(acroForm model.Object) (model.AcroForm, error)
 // so we will only use the Widget
 // the boolean returned is true if `form` is actually a form field.
 // the attibutes Parent,Kids, Widgets are not set
-func (r resolver) isFormField(form model.ObjDict) (field model.FormFieldDict, isField bool) {
+func (r resolver) isFormField(form model.ObjDict) (field model.FormFieldDict, isField bool, err error) {
 	if _, ok := r.resolveName(form["FT"]); ok {
 		isField = true
-		field.FT = r.processFormFieldType(form)
+		field.FT, err = r.processFormFieldType(form)
+		if err != nil {
+			return field, isField, err
+		}
 	}
 	if t, ok := file.IsString(r.resolve(form["T"])); ok {
 		isField = true
-		field.T = DecodeTextString(t)
+		field.T = r.decodeTextString(t)
 	}
 	if t, ok := file.IsString(r.resolve(form["TU"])); ok {
 		isField = true
@@ -90,7 +97,10 @@ func (r resolver) isFormField(form model.ObjDict) (field model.FormFieldDict, is
 		field.Ff = model.FormFlag(ff)
 	}
 	if aa := r.resolve(form["AA"]); aa != nil {
-		field.AA = r.processFormAA(aa)
+		field.AA, err = r.processFormAA(aa)
+		if err != nil {
+			return field, isField, err
+		}
 		if field.AA.IsEmpty() { // the AA entry may be the one of a widget
 			isField = true
 		}
@@ -105,37 +115,49 @@ func (r resolver) isFormField(form model.ObjDict) (field model.FormFieldDict, is
 	}
 	if t, ok := file.IsString(r.resolve(form["DS"])); ok {
 		isField = true
-		field.DS = DecodeTextString(t)
+		field.DS = r.decodeTextString(t)
 	}
 	if t, ok := file.IsString(r.resolve(form["RV"])); ok {
 		isField = true
-		field.RV = DecodeTextString(t)
+		field.RV = r.decodeTextString(t)
 	}
-	return field, isField
+	return field, isField, nil
 }
 
-func (r resolver) processFormAA(aa model.Object) model.FormFielAdditionalActions {
+// processFormAA ignores the invalid actions, but returns
+// the errors caused by the limits (see file.Limits)
+func (r resolver) processFormAA(aa model.Object) (model.FormFielAdditionalActions, error) {
 	aa = r.resolve(aa)
 	aaDict, _ := aa.(model.ObjDict)
 	var out model.FormFielAdditionalActions
-	out.K, _ = r.processAction(aaDict["K"])
-	out.F, _ = r.processAction(aaDict["F"])
-	out.V, _ = r.processAction(aaDict["V"])
-	out.C, _ = r.processAction(aaDict["C"])
-	return out
+	for _, entry := range [...]struct {
+		key    model.Name
+		action *model.Action
+	}{{"K", &out.K}, {"F", &out.F}, {"V", &out.V}, {"C", &out.C}} {
+		var err error
+		*entry.action, err = r.processAction(aaDict[entry.key])
+		if errors.Is(err, file.ErrLimitExceeded) {
+			return out, err
+		}
+	}
+	return out, nil
 }
 
 // extract a text string from either a string or a stream object,
-// after dereferencing
-func (r resolver) textOrStream(object model.Object) string {
+// after dereferencing.
+// An error is only returned if the decoded stream exceeds the MaxStreamSize limit.
+func (r resolver) textOrStream(object model.Object) (string, error) {
 	content := r.resolve(object)
 	var jsString string
 	if stream, ok := content.(model.ObjStream); ok {
 		s, ok, _ := r.resolveStream(stream)
 		if ok {
-			decoded, err := s.Decode()
+			decoded, err := r.decodeStream(s)
+			if errors.Is(err, file.ErrLimitExceeded) {
+				return "", err
+			}
 			if err != nil { // best effort: we return the raw stream
-				log.Println("failed to decode text stream", err)
+				r.logger.Log(model.LogWarning, "failed to decode text stream", "error", err)
 				decoded = s.Content
 			}
 			jsString = string(decoded)
@@ -143,7 +165,16 @@ func (r resolver) textOrStream(object model.Object) string {
 	} else {
 		jsString, _ = file.IsString(content)
 	}
-	return DecodeTextString(jsString)
+	return r.decodeTextString(jsString), nil
+}
+
+// decodeStream decodes `s`, respecting the MaxStreamSize limit
+func (r resolver) decodeStream(s model.Stream) ([]byte, error) {
+	rd, err := s.Filter.DecodeReader(bytes.NewReader(s.Content))
+	if err != nil {
+		return nil, err
+	}
+	return file.ReadLimited(rd, r.maxStreamSize)
 }
 
 // `parent` will be nil for the top-level fields
@@ -151,9 +182,15 @@ func (r resolver) textOrStream(object model.Object) string {
 func (r resolver) resolveFormField(o model.Object, parent *model.FormFieldDict) (*model.FormFieldDict, error) {
 	var err error
 	ref, isRef := o.(model.ObjIndirectRef)
-	if ff := r.formFields[ref]; isRef && ff != nil {
+	r.lock()
+	ff := r.formFields[ref]
+	r.unlock()
+	if isRef && ff != nil {
 		return ff, nil
 	}
+	if err := r.enter(); err != nil {
+		return nil, err
+	}
 	resolved := r.resolve(ref)
 	if resolved == nil {
 		return nil, nil
@@ -163,7 +200,10 @@ func (r resolver) resolveFormField(o model.Object, parent *model.FormFieldDict)
 		return nil, errType("FormField", o)
 	}
 
-	fi, _ := r.isFormField(f) // fill the simple attributes
+	fi, _, err := r.isFormField(f) // fill the simple attributes
+	if err != nil {
+		return nil, err
+	}
 
 	fi.Parent = parent
 
@@ -176,7 +216,10 @@ func (r resolver) resolveFormField(o model.Object, parent *model.FormFieldDict)
 		if kidDict == nil { // ignore the invalid entry
 			continue
 		}
-		_, isField := r.isFormField(kidDict) // could be optimized not to resolve entry
+		_, isField, err := r.isFormField(kidDict) // could be optimized not to resolve entry
+		if err != nil {
+			return nil, err
+		}
 		if isField {
 			kidField, err := r.resolveFormField(kid, &fi) // surely indirect ref
 			if err != nil {
@@ -203,6 +246,11 @@ func (r resolver) resolveFormField(o model.Object, parent *model.FormFieldDict)
 	}
 
 	if isRef {
+		r.lock()
+		defer r.unlock()
+		if existing := r.formFields[ref]; existing != nil { // resolved concurrently
+			return existing, nil
+		}
 		r.formFields[ref] = &fi
 	}
 
@@ -228,7 +276,7 @@ func (r resolver) resolveWidget(obj model.Object) (model.FormFieldWidget, bool,
 // ------------------- specialization of form fields -------------------
 
 // may return nil it the type if inherited
-func (r resolver) processFormFieldType(form model.ObjDict) model.FormField {
+func (r resolver) processFormFieldType(form model.ObjDict) (model.FormField, error) {
 	ft, _ := r.resolveName(form["FT"])
 	switch ft {
 	case "Btn":
@@ -239,19 +287,19 @@ func (r resolver) processFormFieldType(form model.ObjDict) model.FormField {
 		out.Opt = make([]string, len(opt))
 		for i, o := range opt {
 			os, _ := file.IsString(r.resolve(o))
-			out.Opt[i] = DecodeTextString(os)
+			out.Opt[i] = r.decodeTextString(os)
 		}
-		return out
+		return out, nil
 	case "Ch":
 		var out model.FormFieldChoice
 		v := r.resolve(form["V"])
 		if str, is := file.IsString(v); is {
-			out.V = []string{DecodeTextString(str)}
+			out.V = []string{r.decodeTextString(str)}
 		} else if ar, ok := v.(model.ObjArray); ok {
 			out.V = make([]string, len(ar))
 			for i, a := range ar {
 				s, _ := file.IsString(r.resolve(a))
-				out.V[i] = DecodeTextString(s)
+				out.V[i] = r.decodeTextString(s)
 			}
 		}
 		opts, _ := r.resolveArray(form["Opt"])
@@ -259,12 +307,12 @@ func (r resolver) processFormFieldType(form model.ObjDict) model.FormField {
 		for i, o := range opts {
 			o = r.resolve(o)
 			if s, ok := file.IsString(o); ok { // a single text string
-				out.Opt[i].Name = DecodeTextString(s)
+				out.Opt[i].Name = r.decodeTextString(s)
 			} else if s, _ := o.(model.ObjArray); len(s) == 2 { // [export name]
 				export, _ := file.IsString(r.resolve(s[0]))
 				name, _ := file.IsString(r.resolve(s[1]))
-				out.Opt[i].Export = DecodeTextString(export)
-				out.Opt[i].Name = DecodeTextString(name)
+				out.Opt[i].Export = r.decodeTextString(export)
+				out.Opt[i].Name = r.decodeTextString(name)
 			}
 		}
 		if ti, ok := r.resolveInt(form["TI"]); ok {
@@ -275,23 +323,296 @@ func (r resolver) processFormFieldType(form model.ObjDict) model.FormField {
 		for i, ii := range is {
 			out.I[i], _ = r.resolveInt(ii)
 		}
-		return out
+		return out, nil
 	case "Sig":
-		return r.processSignatureField(form)
+		return r.processSignatureField(form), nil
 	case "Tx":
-		var out model.FormFieldText
-		out.V = r.textOrStream(form["V"])
+		var (
+			out model.FormFieldText
+			err error
+		)
+		out.V, err = r.textOrStream(form["V"])
+		if err != nil {
+			return nil, err
+		}
 		if ml, ok := r.resolveInt(form["MaxLen"]); ok {
 			out.MaxLen = model.ObjInt(ml)
 		}
-		return out
+		if pmd, ok := r.resolve(form["PMD"]).(model.ObjDict); ok {
+			out.PMD = r.processPaperMetaData(pmd)
+		}
+		return out, nil
 	default: // nil or invalid
-		return nil
+		return nil, nil
+	}
+}
+
+func (r resolver) processPaperMetaData(pmd model.ObjDict) *model.PaperMetaData {
+	var out model.PaperMetaData
+	out.Symbology, _ = r.resolveName(pmd["Symbology"])
+	for _, entry := range [...]struct {
+		key   model.Name
+		value *model.MaybeInt
+	}{
+		{"ECC", &out.ECC}, {"nCodeWordRow", &out.NCodeWordRow}, {"nCodeWordCol", &out.NCodeWordCol},
+		{"X", &out.X}, {"Y", &out.Y}, {"Resolution", &out.Resolution},
+	} {
+		if v, ok := r.resolveInt(pmd[entry.key]); ok {
+			*entry.value = model.ObjInt(v)
+		}
 	}
+	out.Caption, _ = r.resolveBool(pmd["Caption"])
+	return &out
 }
 
-// TODO: process signature field
 func (r resolver) processSignatureField(form model.ObjDict) model.FormFieldSignature {
-	fmt.Println("TODO Signature field", form)
-	return model.FormFieldSignature{}
+	var out model.FormFieldSignature
+	if v := form["V"]; v != nil {
+		var err error
+		out.V, err = r.resolveSignature(v)
+		if err != nil { // signature fields are not critical
+			r.logger.Log(model.LogWarning, "invalid signature", "error", err)
+		}
+	}
+	if lock, ok := r.resolve(form["Lock"]).(model.ObjDict); ok {
+		out.Lock = &model.LockDict{Fields: r.resolveFieldNames(lock["Fields"])}
+		out.Lock.Action, _ = r.resolveName(lock["Action"])
+	}
+	if sv, ok := r.resolve(form["SV"]).(model.ObjDict); ok {
+		out.SV = r.processSeed(sv)
+	}
+	return out
+}
+
+// resolveStrings returns the strings of an array, possibly indirect,
+// decoded as text strings if `isText` is true
+func (r resolver) resolveStrings(obj model.Object, isText bool) []string {
+	ar, _ := r.resolveArray(obj)
+	var out []string
+	for _, item := range ar {
+		s, ok := file.IsString(r.resolve(item))
+		if !ok {
+			continue
+		}
+		if isText {
+			s = r.decodeTextString(s)
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+func (r resolver) processSeed(sv model.ObjDict) *model.SeedDict {
+	var out model.SeedDict
+	if ff, ok := r.resolveInt(sv["Ff"]); ok {
+		out.Ff = model.SeedFlag(ff)
+	}
+	out.Filter, _ = r.resolveName(sv["Filter"])
+	out.SubFilter = r.resolveNames(sv["SubFilter"])
+	out.DigestMethod = r.resolveNames(sv["DigestMethod"])
+	out.V, _ = r.resolveNumber(sv["V"])
+	if cert, ok := r.resolve(sv["Cert"]).(model.ObjDict); ok {
+		out.Cert = r.processCert(cert)
+	}
+	out.Reasons = r.resolveStrings(sv["Reasons"], true)
+	if mdp, ok := r.resolve(sv["MDP"]).(model.ObjDict); ok {
+		if p, ok := r.resolveInt(mdp["P"]); ok {
+			out.MDP = model.ObjInt(p)
+		}
+	}
+	if ts, ok := r.resolve(sv["TimeStamp"]).(model.ObjDict); ok {
+		var timeStamp model.TimeStampDict
+		timeStamp.URL, _ = file.IsString(r.resolve(ts["URL"]))
+		if ff, ok := r.resolveInt(ts["Ff"]); ok {
+			timeStamp.Ff = uint8(ff)
+		}
+		out.TimeStamp = &timeStamp
+	}
+	out.LegalAttestation = r.resolveStrings(sv["LegalAttestation"], true)
+	out.AddRevInfo, _ = r.resolveBool(sv["AddRevInfo"])
+	return &out
+}
+
+func (r resolver) processCert(cert model.ObjDict) *model.CertDict {
+	var out model.CertDict
+	if ff, ok := r.resolveInt(cert["Ff"]); ok {
+		out.Ff = uint8(ff)
+	}
+	out.Subject = r.resolveStrings(cert["Subject"], false)
+	dns, _ := r.resolveArray(cert["SubjectDN"])
+	for _, dn := range dns {
+		dict, ok := r.resolve(dn).(model.ObjDict)
+		if !ok {
+			continue
+		}
+		m := make(map[model.Name]string, len(dict))
+		for name, value := range dict {
+			s, _ := file.IsString(r.resolve(value))
+			m[name] = r.decodeTextString(s)
+		}
+		out.SubjectDN = append(out.SubjectDN, m)
+	}
+	out.KeyUsage = r.resolveStrings(cert["KeyUsage"], false)
+	out.Issuer = r.resolveStrings(cert["Issuer"], false)
+	out.OID = r.resolveStrings(cert["OID"], false)
+	out.URL, _ = file.IsString(r.resolve(cert["URL"]))
+	out.URLType, _ = r.resolveName(cert["URLType"])
+	return &out
+}
+
+func (r resolver) resolveSignature(obj model.Object) (*model.SignatureDict, error) {
+	ref, isRef := obj.(model.ObjIndirectRef)
+	r.lock()
+	cached := r.signatures[ref]
+	r.unlock()
+	if isRef && cached != nil {
+		return cached, nil
+	}
+	dict, ok := r.resolve(obj).(model.ObjDict)
+	if !ok {
+		return nil, errType("Signature", obj)
+	}
+	var (
+		out model.SignatureDict
+		err error
+	)
+	out.Filter, _ = r.resolveName(dict["Filter"])
+	out.SubFilter, _ = r.resolveName(dict["SubFilter"])
+	out.Contents, _ = file.IsString(r.resolve(dict["Contents"]))
+	switch cert := r.resolve(dict["Cert"]).(type) {
+	case model.ObjArray:
+		for _, c := range cert {
+			s, _ := file.IsString(r.resolve(c))
+			out.Cert = append(out.Cert, s)
+		}
+	default:
+		if s, ok := file.IsString(cert); ok {
+			out.Cert = []string{s}
+		}
+	}
+	byteRange, _ := r.resolveArray(dict["ByteRange"])
+	for i := 0; i+1 < len(byteRange); i += 2 {
+		start, _ := r.resolveInt(byteRange[i])
+		length, _ := r.resolveInt(byteRange[i+1])
+		out.ByteRange = append(out.ByteRange, [2]int{start, length})
+	}
+	refs, _ := r.resolveArray(dict["Reference"])
+	for _, ref := range refs {
+		sigRef, err := r.resolveSignatureRef(ref)
+		if err != nil {
+			return nil, err
+		}
+		out.Reference = append(out.Reference, sigRef)
+	}
+	if changes, _ := r.resolveArray(dict["Changes"]); len(changes) == 3 {
+		for i, c := range changes {
+			out.Changes[i], _ = r.resolveInt(c)
+		}
+	}
+	s, _ := file.IsString(r.resolve(dict["Name"]))
+	out.Name = r.decodeTextString(s)
+	if m, ok := file.IsString(r.resolve(dict["M"])); ok {
+		out.M, _ = DateTime(m)
+	}
+	s, _ = file.IsString(r.resolve(dict["Location"]))
+	out.Location = r.decodeTextString(s)
+	s, _ = file.IsString(r.resolve(dict["Reason"]))
+	out.Reason = r.decodeTextString(s)
+	s, _ = file.IsString(r.resolve(dict["ContactInfo"]))
+	out.ContactInfo = r.decodeTextString(s)
+	out.V, _ = r.resolveInt(dict["V"])
+	if build := dict["Prop_Build"]; build != nil {
+		out.Prop_Build, err = r.resolveCustomObject(build)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if m, ok := file.IsString(r.resolve(dict["Prop_AuthTime"])); ok {
+		out.Prop_AuthTime, _ = DateTime(m)
+	}
+	out.Prop_AuthType, _ = r.resolveName(dict["Prop_AuthType"])
+
+	if isRef {
+		r.lock()
+		defer r.unlock()
+		if existing := r.signatures[ref]; existing != nil { // resolved concurrently
+			return existing, nil
+		}
+		r.signatures[ref] = &out
+	}
+	return &out, nil
+}
+
+func (r resolver) resolveSignatureRef(obj model.Object) (out model.SignatureRefDict, err error) {
+	dict, ok := r.resolve(obj).(model.ObjDict)
+	if !ok {
+		return out, errType("Signature reference", obj)
+	}
+	out.TransformMethod, _ = r.resolveName(dict["TransformMethod"])
+	out.DigestMethod, _ = r.resolveName(dict["DigestMethod"])
+	params, _ := r.resolve(dict["TransformParams"]).(model.ObjDict)
+	switch out.TransformMethod {
+	case "DocMDP":
+		var tr model.TransformDocMDP
+		if p, ok := r.resolveInt(params["P"]); ok {
+			tr.P = uint(p)
+		}
+		tr.V, _ = r.resolveName(params["V"])
+		out.TransformParams = tr
+	case "UR", "UR3":
+		var tr model.TransformUR
+		tr.Document = r.resolveNames(params["Document"])
+		msg, _ := file.IsString(r.resolve(params["Msg"]))
+		tr.Msg = r.decodeTextString(msg)
+		tr.V, _ = r.resolveName(params["V"])
+		tr.Annots = r.resolveNames(params["Annots"])
+		tr.Form = r.resolveNames(params["Form"])
+		tr.Signature = r.resolveNames(params["Signature"])
+		tr.EF = r.resolveNames(params["EF"])
+		tr.P, _ = r.resolveBool(params["P"])
+		out.TransformParams = tr
+	case "FieldMDP":
+		var tr model.TransformFieldMDP
+		tr.Action, _ = r.resolveName(params["Action"])
+		fields, _ := r.resolveArray(params["Fields"])
+		for _, f := range fields {
+			s, _ := file.IsString(r.resolve(f))
+			tr.Fields = append(tr.Fields, r.decodeTextString(s))
+		}
+		tr.V, _ = r.resolveName(params["V"])
+		out.TransformParams = tr
+	default:
+		return out, fmt.Errorf("unsupported signature transform method %s", out.TransformMethod)
+	}
+	return out, nil
+}
+
+// resolveNames accepts an array of names, possibly indirect
+func (r resolver) resolveNames(obj model.Object) []model.Name {
+	ar, _ := r.resolveArray(obj)
+	return r.resolveNameArray(ar)
+}
+
+func (r resolver) resolvePerms(obj model.Object) (*model.Perms, error) {
+	dict, ok := r.resolve(obj).(model.ObjDict)
+	if !ok {
+		return nil, nil
+	}
+	var (
+		out model.Perms
+		err error
+	)
+	if docMDP := dict["DocMDP"]; docMDP != nil {
+		out.DocMDP, err = r.resolveSignature(docMDP)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if ur3 := dict["UR3"]; ur3 != nil {
+		out.UR3, err = r.resolveSignature(ur3)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &out, nil
 }
@@ -1,7 +1,9 @@
 package reader
 
 import (
+	"bytes"
 	"os"
+	"reflect"
 	"testing"
 
 	"github.com/benoitkugler/pdf/model"
@@ -74,3 +76,221 @@ func TestForms(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestParseAcroForm(t *testing.T) {
+	icon := &model.XObjectForm{ContentStream: model.ContentStream{Stream: model.Stream{Content: []byte("0 0 10 10 re f")}}}
+	app := &model.AppearanceDict{N: model.AppearanceEntry{"": icon}}
+	text := &model.AnnotationDict{BaseAnnotation: model.BaseAnnotation{Rect: model.Rectangle{Urx: 100, Ury: 20}, AP: app}, Subtype: model.AnnotationWidget{}}
+	button := &model.AnnotationDict{
+		BaseAnnotation: model.BaseAnnotation{Rect: model.Rectangle{Urx: 50, Ury: 50}, AP: app},
+		Subtype:        model.AnnotationWidget{MK: &model.AppearanceCharacteristics{CA: "Go", I: icon, TP: 1}},
+	}
+	page := &model.PageObject{
+		Contents: []model.ContentStream{{Stream: model.Stream{Content: []byte("BT ET")}}},
+		Annots:   []*model.AnnotationDict{text, button},
+	}
+	font := &model.FontDict{Subtype: model.FontType1{
+		BaseFont: "Custom", FirstChar: 32, Widths: []int{500},
+		FontDescriptor: model.FontDescriptor{FontName: "Custom", FontFile: &model.FontFile{Subtype: "Type1C", Stream: model.Stream{Content: []byte("font")}}},
+	}}
+
+	var doc model.Document
+	doc.Catalog.Pages.Kids = []model.PageNode{page}
+	parent := &model.FormFieldDict{T: "group"}
+	parent.Kids = []*model.FormFieldDict{
+		{Parent: parent, T: "name", FormFieldInheritable: model.FormFieldInheritable{FT: model.FormFieldText{V: "value"}}, Widgets: []model.FormFieldWidget{{AnnotationDict: text}}},
+		{Parent: parent, T: "ok", FormFieldInheritable: model.FormFieldInheritable{FT: model.FormFieldButton{}, Ff: model.Pushbutton}, Widgets: []model.FormFieldWidget{{AnnotationDict: button}}},
+	}
+	doc.Catalog.AcroForm = model.AcroForm{Fields: []*model.FormFieldDict{parent}, DA: "/F1 12 Tf 0 g", DR: model.ResourcesDict{Font: map[model.Name]*model.FontDict{"F1": font}}}
+
+	var buf bytes.Buffer
+	if err := doc.Write(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	form, err := ParseAcroFormReader(bytes.NewReader(buf.Bytes()), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields := form.Flatten()
+	if len(fields) != 3 || fields["group.name"].Merged.FT != (model.FormFieldText{V: "value"}) {
+		t.Fatalf("unexpected fields %v", fields)
+	}
+	for _, name := range []string{"group.name", "group.ok"} {
+		field := fields[name]
+		if len(field.Field.Widgets) != 1 {
+			t.Fatalf("unexpected widgets %v", field.Field.Widgets)
+		}
+		widget := field.Field.Widgets[0]
+		if widget.AP != nil || widget.Rect.Urx == 0 {
+			t.Fatalf("unexpected widget %v", widget.BaseAnnotation)
+		}
+	}
+	mk := fields["group.ok"].Field.Widgets[0].Subtype.(model.AnnotationWidget).MK
+	if mk == nil || mk.CA != "Go" || mk.TP != 1 || mk.I != nil {
+		t.Fatalf("unexpected MK %v", mk)
+	}
+	readFont := form.DR.Font["F1"]
+	if form.DA != "/F1 12 Tf 0 g" || readFont == nil {
+		t.Fatalf("unexpected default appearance %v", form)
+	}
+	if desc := readFont.Subtype.(model.FontType1).FontDescriptor; desc.FontName != "Custom" || desc.FontFile != nil {
+		t.Fatalf("unexpected font descriptor %v", desc)
+	}
+
+	// the full parsing resolves the appearances
+	full, _, err := ParsePDFReader(bytes.NewReader(buf.Bytes()), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if widget := full.Catalog.AcroForm.Flatten()["group.name"].Field.Widgets[0]; widget.AP == nil {
+		t.Fatal("missing appearance")
+	}
+
+	if _, err = ParseAcroForm("samples/missing.pdf", Options{}); err == nil {
+		t.Fatal("expected error for missing file")
+	}
+}
+
+func TestSignaturePerms(t *testing.T) {
+	docMDP := &model.SignatureDict{
+		Filter:    "Adobe.PPKLite",
+		SubFilter: "adbe.pkcs7.detached",
+		Contents:  "\x30\x80\x06\x09",
+		ByteRange: [][2]int{{0, 100}, {200, 50}},
+		Reason:    "Certification",
+		Reference: []model.SignatureRefDict{
+			{TransformMethod: "DocMDP", TransformParams: model.TransformDocMDP{P: 2, V: "1.2"}},
+		},
+	}
+	ur3 := &model.SignatureDict{
+		Filter:   "Adobe.PPKLite",
+		Contents: "\x30\x80",
+		Reference: []model.SignatureRefDict{
+			{TransformMethod: "UR3", TransformParams: model.TransformUR{Form: []model.Name{"FillIn"}, V: "2.2", P: true}},
+		},
+	}
+	var doc model.Document
+	doc.Catalog.Pages.Kids = []model.PageNode{&model.PageObject{}}
+	doc.Catalog.AcroForm.Fields = []*model.FormFieldDict{
+		{FormFieldInheritable: model.FormFieldInheritable{FT: model.FormFieldSignature{V: docMDP}}, T: "Signature1"},
+	}
+	doc.Catalog.Perms = &model.Perms{DocMDP: docMDP, UR3: ur3}
+
+	write := func(options model.WriteOptions) model.Document {
+		var buf bytes.Buffer
+		if err := doc.WriteWithOptions(&buf, nil, options); err != nil {
+			t.Fatal(err)
+		}
+		read, _, err := ParsePDFReader(bytes.NewReader(buf.Bytes()), Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return read
+	}
+
+	read := write(model.WriteOptions{})
+	if !read.Catalog.HasUsageRights() {
+		t.Fatal("expected usage rights")
+	}
+	perms := read.Catalog.Perms
+	field := read.Catalog.AcroForm.Fields[0].FT.(model.FormFieldSignature)
+	if field.V != perms.DocMDP {
+		t.Fatal("expected a shared signature dictionary")
+	}
+	if sig := perms.DocMDP; sig.Reason != "Certification" || sig.Contents != docMDP.Contents || len(sig.ByteRange) != 2 {
+		t.Fatalf("unexpected signature %v", sig)
+	}
+	if tr := perms.DocMDP.Reference[0].TransformParams; tr != docMDP.Reference[0].TransformParams {
+		t.Fatalf("unexpected transform params %v", tr)
+	}
+	tr, ok := perms.UR3.Reference[0].TransformParams.(model.TransformUR)
+	if !ok || !tr.P || len(tr.Form) != 1 || tr.Form[0] != "FillIn" {
+		t.Fatalf("unexpected transform params %v", perms.UR3.Reference[0].TransformParams)
+	}
+
+	read = write(model.WriteOptions{StripUsageRights: true})
+	if read.Catalog.HasUsageRights() || read.Catalog.Perms == nil || read.Catalog.Perms.DocMDP == nil {
+		t.Fatalf("unexpected Perms %v", read.Catalog.Perms)
+	}
+	if !doc.Catalog.HasUsageRights() {
+		t.Fatal("the original document should not be modified")
+	}
+}
+
+func TestBarcodeField(t *testing.T) {
+	pmd := &model.PaperMetaData{
+		Symbology: model.SymbologyPDF417, ECC: model.ObjInt(2),
+		NCodeWordRow: model.ObjInt(20), NCodeWordCol: model.ObjInt(4),
+		X: model.ObjInt(2), Y: model.ObjInt(3), Resolution: model.ObjInt(300), Caption: true,
+	}
+	widget := &model.AnnotationDict{Subtype: model.AnnotationWidget{}}
+	var doc model.Document
+	doc.Catalog.Pages.Kids = []model.PageNode{&model.PageObject{Annots: []*model.AnnotationDict{widget}}}
+	doc.Catalog.AcroForm.Fields = []*model.FormFieldDict{{
+		FormFieldInheritable: model.FormFieldInheritable{FT: model.FormFieldText{V: "data", PMD: pmd}},
+		T:                    "barcode",
+		Widgets:              []model.FormFieldWidget{{AnnotationDict: widget}},
+	}}
+
+	var buf bytes.Buffer
+	if err := doc.Write(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	read, _, err := ParsePDFReader(bytes.NewReader(buf.Bytes()), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := read.Catalog.AcroForm.Fields[0].FT.(model.FormFieldText)
+	if text.PMD == nil || *text.PMD != *pmd {
+		t.Fatalf("expected %v, got %v", pmd, text.PMD)
+	}
+	if annot := read.Catalog.Pages.Flatten()[0].Annots[0]; len(annot.Custom) != 0 {
+		t.Fatalf("unexpected custom entries %v", annot.Custom)
+	}
+}
+
+func TestSignatureLockSeed(t *testing.T) {
+	lock := &model.LockDict{Action: "Include", Fields: []string{"name", "address.city"}}
+	seed := &model.SeedDict{
+		Ff:           model.SeedFilter | model.SeedReasons,
+		Filter:       "Adobe.PPKLite",
+		SubFilter:    []model.Name{"adbe.pkcs7.detached"},
+		DigestMethod: []model.Name{"SHA256"},
+		V:            2,
+		Cert: &model.CertDict{
+			Ff:        1,
+			Subject:   []string{"\x30\x80"},
+			SubjectDN: []map[model.Name]string{{"CN": "John"}},
+			KeyUsage:  []string{"1XXXXXXXX"},
+			URL:       "https://example.com",
+			URLType:   "Browser",
+		},
+		Reasons:          []string{"Approval"},
+		MDP:              model.ObjInt(2),
+		TimeStamp:        &model.TimeStampDict{URL: "https://tsa.example.com", Ff: 1},
+		LegalAttestation: []string{"Attestation"},
+		AddRevInfo:       true,
+	}
+	var doc model.Document
+	doc.Catalog.Pages.Kids = []model.PageNode{&model.PageObject{}}
+	doc.Catalog.AcroForm.Fields = []*model.FormFieldDict{
+		{FormFieldInheritable: model.FormFieldInheritable{FT: model.FormFieldSignature{Lock: lock, SV: seed}}, T: "Signature1"},
+	}
+
+	var buf bytes.Buffer
+	if err := doc.Write(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	read, _, err := ParsePDFReader(bytes.NewReader(buf.Bytes()), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	field := read.Catalog.AcroForm.Fields[0].FT.(model.FormFieldSignature)
+	if !reflect.DeepEqual(field.Lock, lock) {
+		t.Fatalf("expected %v, got %v", lock, field.Lock)
+	}
+	if !reflect.DeepEqual(field.SV, seed) {
+		t.Fatalf("expected %v, got %v", seed, field.SV)
+	}
+}
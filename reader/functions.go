@@ -10,8 +10,11 @@ import (
 // if not error return a non nil pointer
 func (r resolver) resolveFunction(fn model.Object) (*model.FunctionDict, error) {
 	fnRef, isRef := fn.(model.ObjIndirectRef)
-	if fnM := r.functions[fnRef]; isRef && fnM != nil {
-		return fnM, nil
+	r.lock()
+	cached := r.functions[fnRef]
+	r.unlock()
+	if isRef && cached != nil {
+		return cached, nil
 	}
 	fn = r.resolve(fn)
 	var (
@@ -67,6 +70,11 @@ func (r resolver) resolveFunction(fn model.Object) (*model.FunctionDict, error)
 	}
 
 	if isRef {
+		r.lock()
+		defer r.unlock()
+		if existing := r.functions[fnRef]; existing != nil { // resolved concurrently
+			return existing, nil
+		}
 		r.functions[fnRef] = &out
 	}
 	return &out, nil
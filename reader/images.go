@@ -58,8 +58,11 @@ func (r resolver) resolveOneXObject(obj model.Object) (model.XObject, error) {
 // returns an error if img is nil
 func (r resolver) resolveOneXObjectImage(img model.Object) (*model.XObjectImage, error) {
 	imgRef, isRef := img.(model.ObjIndirectRef)
-	if imgModel := r.images[imgRef]; isRef && imgModel != nil {
-		return imgModel, nil
+	r.lock()
+	cached := r.images[imgRef]
+	r.unlock()
+	if isRef && cached != nil {
+		return cached, nil
 	}
 	var (
 		out    model.XObjectImage
@@ -122,8 +125,19 @@ func (r resolver) resolveOneXObjectImage(img model.Object) (*model.XObjectImage,
 	if st, ok := r.resolveInt(stream.Args["StructParent"]); ok {
 		out.StructParent = model.ObjInt(st)
 	}
+	if oc := stream.Args["OC"]; oc != nil {
+		out.OC, err = r.resolveOptionalContent(oc)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	if isRef {
+		r.lock()
+		defer r.unlock()
+		if existing := r.images[imgRef]; existing != nil { // resolved concurrently
+			return existing, nil
+		}
 		r.images[imgRef] = &out
 	}
 	return &out, nil
@@ -182,8 +196,11 @@ func (r resolver) resolveImage(img model.Object) (out model.Image, stream model.
 
 func (r resolver) resolveImageSMask(img model.Object) (*model.ImageSMask, error) {
 	imgRef, isRef := img.(model.ObjIndirectRef)
-	if imgModel := r.imageSMasks[imgRef]; isRef && imgModel != nil {
-		return imgModel, nil
+	r.lock()
+	cached := r.imageSMasks[imgRef]
+	r.unlock()
+	if isRef && cached != nil {
+		return cached, nil
 	}
 	var (
 		out    model.ImageSMask
@@ -199,6 +216,11 @@ func (r resolver) resolveImageSMask(img model.Object) (*model.ImageSMask, error)
 	out.Matte = r.processFloatArray(matte)
 
 	if isRef {
+		r.lock()
+		defer r.unlock()
+		if existing := r.imageSMasks[imgRef]; existing != nil { // resolved concurrently
+			return existing, nil
+		}
 		r.imageSMasks[imgRef] = &out
 	}
 	return &out, nil
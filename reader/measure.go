@@ -0,0 +1,108 @@
+package reader
+
+import (
+	"github.com/benoitkugler/pdf/model"
+	"github.com/benoitkugler/pdf/reader/file"
+)
+
+func (r resolver) resolveViewport(dict model.ObjDict) model.Viewport {
+	var out model.Viewport
+	if bbox := r.rectangleFromArray(dict["BBox"]); bbox != nil {
+		out.BBox = *bbox
+	}
+	name, _ := file.IsString(r.resolve(dict["Name"]))
+	out.Name = r.decodeTextString(name)
+	out.Measure = r.resolveMeasure(dict["Measure"])
+	return out
+}
+
+// resolveMeasure returns nil for missing or unsupported measure dictionaries
+func (r resolver) resolveMeasure(o model.Object) model.Measure {
+	dict, ok := r.resolve(o).(model.ObjDict)
+	if !ok {
+		return nil
+	}
+	subtype, _ := r.resolveName(dict["Subtype"])
+	switch subtype {
+	case "RL", "": // RL is the default
+		var out model.MeasureRL
+		ratio, _ := file.IsString(r.resolve(dict["R"]))
+		out.R = r.decodeTextString(ratio)
+		out.X = r.resolveNumberFormats(dict["X"])
+		out.Y = r.resolveNumberFormats(dict["Y"])
+		out.D = r.resolveNumberFormats(dict["D"])
+		out.A = r.resolveNumberFormats(dict["A"])
+		out.T = r.resolveNumberFormats(dict["T"])
+		out.S = r.resolveNumberFormats(dict["S"])
+		if origin, _ := r.resolveArray(dict["O"]); len(origin) == 2 {
+			out.O = new([2]Fl)
+			copy(out.O[:], r.processFloatArray(origin))
+		}
+		if cyx, ok := r.resolveNumber(dict["CYX"]); ok {
+			out.CYX = model.ObjFloat(cyx)
+		}
+		return out
+	case "GEO":
+		var out model.MeasureGEO
+		bounds, _ := r.resolveArray(dict["Bounds"])
+		if len(bounds) != 0 {
+			out.Bounds = r.processFloatArray(bounds)
+		}
+		if gcs, ok := r.resolve(dict["GCS"]).(model.ObjDict); ok {
+			out.GCS = r.resolveCoordinateSystem(gcs)
+		}
+		if dcs, ok := r.resolve(dict["DCS"]).(model.ObjDict); ok {
+			cs := r.resolveCoordinateSystem(dcs)
+			out.DCS = &cs
+		}
+		pdu, _ := r.resolveArray(dict["PDU"])
+		copy(out.PDU[:], r.resolveNameArray(pdu))
+		gpts, _ := r.resolveArray(dict["GPTS"])
+		out.GPTS = r.processFloatArray(gpts)
+		lpts, _ := r.resolveArray(dict["LPTS"])
+		if len(lpts) != 0 {
+			out.LPTS = r.processFloatArray(lpts)
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+func (r resolver) resolveNumberFormats(o model.Object) []model.NumberFormat {
+	ar, _ := r.resolveArray(o)
+	var out []model.NumberFormat
+	for _, nf := range ar {
+		dict, ok := r.resolve(nf).(model.ObjDict)
+		if !ok {
+			continue
+		}
+		var format model.NumberFormat
+		u, _ := file.IsString(r.resolve(dict["U"]))
+		format.U = r.decodeTextString(u)
+		format.C, _ = r.resolveNumber(dict["C"])
+		format.F, _ = r.resolveName(dict["F"])
+		format.D, _ = r.resolveInt(dict["D"])
+		format.FD, _ = r.resolveBool(dict["FD"])
+		rt, _ := file.IsString(r.resolve(dict["RT"]))
+		format.RT = r.decodeTextString(rt)
+		rd, _ := file.IsString(r.resolve(dict["RD"]))
+		format.RD = r.decodeTextString(rd)
+		ps, _ := file.IsString(r.resolve(dict["PS"]))
+		format.PS = r.decodeTextString(ps)
+		ss, _ := file.IsString(r.resolve(dict["SS"]))
+		format.SS = r.decodeTextString(ss)
+		format.O, _ = r.resolveName(dict["O"])
+		out = append(out, format)
+	}
+	return out
+}
+
+func (r resolver) resolveCoordinateSystem(dict model.ObjDict) model.CoordinateSystem {
+	var out model.CoordinateSystem
+	typ, _ := r.resolveName(dict["Type"])
+	out.Projected = typ == "PROJCS"
+	out.EPSG, _ = r.resolveInt(dict["EPSG"])
+	out.WKT, _ = file.IsString(r.resolve(dict["WKT"]))
+	return out
+}
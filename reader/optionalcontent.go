@@ -0,0 +1,232 @@
+package reader
+
+import (
+	"fmt"
+
+	"github.com/benoitkugler/pdf/model"
+	"github.com/benoitkugler/pdf/reader/file"
+)
+
+// isOptionalContentDict returns true for OCG and OCMD dictionaries
+func (r resolver) isOptionalContentDict(obj model.Object) bool {
+	dict, _ := r.resolve(obj).(model.ObjDict)
+	name, _ := r.resolveName(dict["Type"])
+	return name == "OCG" || name == "OCMD"
+}
+
+// resolveOptionalContent returns either an OCG or an OCMD
+func (r resolver) resolveOptionalContent(obj model.Object) (model.OptionalContent, error) {
+	dict, ok := r.resolve(obj).(model.ObjDict)
+	if !ok {
+		return nil, errType("Optional content", obj)
+	}
+	if name, _ := r.resolveName(dict["Type"]); name == "OCMD" {
+		return r.resolveOCMD(obj)
+	}
+	return r.resolveOCG(obj)
+}
+
+func (r resolver) resolveOCG(obj model.Object) (*model.OptionalContentGroup, error) {
+	ref, isRef := obj.(model.ObjIndirectRef)
+	r.lock()
+	cached := r.ocgs[ref]
+	r.unlock()
+	if isRef && cached != nil {
+		return cached, nil
+	}
+	dict, ok := r.resolve(obj).(model.ObjDict)
+	if !ok {
+		return nil, errType("OCG", obj)
+	}
+	var out model.OptionalContentGroup
+	name, _ := file.IsString(r.resolve(dict["Name"]))
+	out.Name = r.decodeTextString(name)
+	switch intent := r.resolve(dict["Intent"]).(type) {
+	case model.ObjName:
+		out.Intent = []model.Name{model.Name(intent)}
+	case model.ObjArray:
+		out.Intent = r.resolveNameArray(intent)
+	}
+	if isRef {
+		r.lock()
+		defer r.unlock()
+		if existing := r.ocgs[ref]; existing != nil { // resolved concurrently
+			return existing, nil
+		}
+		r.ocgs[ref] = &out
+	}
+	return &out, nil
+}
+
+func (r resolver) resolveOCMD(obj model.Object) (*model.OptionalContentMembership, error) {
+	ref, isRef := obj.(model.ObjIndirectRef)
+	r.lock()
+	cached := r.ocmds[ref]
+	r.unlock()
+	if isRef && cached != nil {
+		return cached, nil
+	}
+	dict, ok := r.resolve(obj).(model.ObjDict)
+	if !ok {
+		return nil, errType("OCMD", obj)
+	}
+	var (
+		out model.OptionalContentMembership
+		err error
+	)
+	// OCGs may be a single dictionary or an array
+	ocgs := r.resolve(dict["OCGs"])
+	if _, isDict := ocgs.(model.ObjDict); isDict {
+		ocgs = model.ObjArray{dict["OCGs"]}
+	}
+	out.OCGs, err = r.resolveOCGArray(ocgs)
+	if err != nil {
+		return nil, err
+	}
+	out.P, _ = r.resolveName(dict["P"])
+	if isRef {
+		r.lock()
+		defer r.unlock()
+		if existing := r.ocmds[ref]; existing != nil { // resolved concurrently
+			return existing, nil
+		}
+		r.ocmds[ref] = &out
+	}
+	return &out, nil
+}
+
+func (r resolver) resolveNameArray(ar model.ObjArray) []model.Name {
+	out := make([]model.Name, 0, len(ar))
+	for _, n := range ar {
+		if name, ok := r.resolveName(n); ok {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+func (r resolver) resolveOCGArray(obj model.Object) ([]*model.OptionalContentGroup, error) {
+	ar, _ := r.resolveArray(obj)
+	if ar == nil {
+		return nil, nil
+	}
+	out := make([]*model.OptionalContentGroup, 0, len(ar))
+	for _, item := range ar {
+		if r.resolve(item) == nil { // ignore invalid references
+			continue
+		}
+		oc, err := r.resolveOCG(item)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, oc)
+	}
+	return out, nil
+}
+
+func (r resolver) resolveOCProperties(obj model.Object) (*model.OptionalContentProperties, error) {
+	dict, ok := r.resolve(obj).(model.ObjDict)
+	if !ok {
+		return nil, nil
+	}
+	var (
+		out model.OptionalContentProperties
+		err error
+	)
+	out.OCGs, err = r.resolveOCGArray(dict["OCGs"])
+	if err != nil {
+		return nil, err
+	}
+	out.D, err = r.resolveOCConfig(dict["D"])
+	if err != nil {
+		return nil, err
+	}
+	configs, _ := r.resolveArray(dict["Configs"])
+	for _, c := range configs {
+		config, err := r.resolveOCConfig(c)
+		if err != nil {
+			return nil, err
+		}
+		out.Configs = append(out.Configs, config)
+	}
+	return &out, nil
+}
+
+func (r resolver) resolveOCConfig(obj model.Object) (out model.OptionalContentConfig, err error) {
+	dict, _ := r.resolve(obj).(model.ObjDict)
+	s, _ := file.IsString(r.resolve(dict["Name"]))
+	out.Name = r.decodeTextString(s)
+	s, _ = file.IsString(r.resolve(dict["Creator"]))
+	out.Creator = r.decodeTextString(s)
+	out.BaseState, _ = r.resolveName(dict["BaseState"])
+	out.ON, err = r.resolveOCGArray(dict["ON"])
+	if err != nil {
+		return out, err
+	}
+	out.OFF, err = r.resolveOCGArray(dict["OFF"])
+	if err != nil {
+		return out, err
+	}
+	switch intent := r.resolve(dict["Intent"]).(type) {
+	case model.ObjName:
+		out.Intent = []model.Name{model.Name(intent)}
+	case model.ObjArray:
+		out.Intent = r.resolveNameArray(intent)
+	}
+	order, _ := r.resolveArray(dict["Order"])
+	out.Order, err = r.resolveOCOrder(order, 0)
+	if err != nil {
+		return out, err
+	}
+	out.ListMode, _ = r.resolveName(dict["ListMode"])
+	rbGroups, _ := r.resolveArray(dict["RBGroups"])
+	for _, group := range rbGroups {
+		ocgs, err := r.resolveOCGArray(group)
+		if err != nil {
+			return out, err
+		}
+		out.RBGroups = append(out.RBGroups, ocgs)
+	}
+	out.Locked, err = r.resolveOCGArray(dict["Locked"])
+	return out, err
+}
+
+// maxOCOrderDepth protects against circular Order arrays
+const maxOCOrderDepth = 64
+
+func (r resolver) resolveOCOrder(ar model.ObjArray, depth int) ([]model.OptionalContentOrder, error) {
+	if depth > maxOCOrderDepth {
+		return nil, fmt.Errorf("too many nested Order arrays")
+	}
+	var out []model.OptionalContentOrder
+	for i, item := range ar {
+		switch resolved := r.resolve(item).(type) {
+		case model.ObjDict:
+			oc, err := r.resolveOCG(item)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, model.OptionalContentOrder{Group: oc})
+		case model.ObjArray:
+			var (
+				sub model.OptionalContentOrder
+				err error
+			)
+			// an optional label is the first element
+			if len(resolved) != 0 {
+				if label, ok := file.IsString(r.resolve(resolved[0])); ok {
+					sub.Label = r.decodeTextString(label)
+					resolved = resolved[1:]
+				}
+			}
+			sub.Kids, err = r.resolveOCOrder(resolved, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, sub)
+		default:
+			return nil, fmt.Errorf("invalid Order item at index %d: %v", i, item)
+		}
+	}
+	return out, nil
+}
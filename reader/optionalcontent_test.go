@@ -0,0 +1,83 @@
+package reader
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/benoitkugler/pdf/model"
+)
+
+func TestOptionalContent(t *testing.T) {
+	layer1 := &model.OptionalContentGroup{Name: "Layer 1"}
+	layer2 := &model.OptionalContentGroup{Name: "Layer 2", Intent: []model.Name{"View", "Design"}}
+	ocmd := &model.OptionalContentMembership{OCGs: []*model.OptionalContentGroup{layer1, layer2}, P: "AllOn"}
+
+	var doc model.Document
+	doc.Catalog.OCProperties = &model.OptionalContentProperties{
+		OCGs: []*model.OptionalContentGroup{layer1, layer2},
+		D: model.OptionalContentConfig{
+			Name: "Default",
+			OFF:  []*model.OptionalContentGroup{layer2},
+			Order: []model.OptionalContentOrder{
+				{Group: layer1},
+				{Label: "Nested", Kids: []model.OptionalContentOrder{{Group: layer2}}},
+			},
+			RBGroups: [][]*model.OptionalContentGroup{{layer1, layer2}},
+		},
+	}
+	form := &model.XObjectForm{BBox: model.Rectangle{Urx: 10, Ury: 10}, OC: layer2}
+	form.Content = []byte("0 0 10 10 re f")
+	res := model.NewResourcesDict()
+	res.XObject["X1"] = form
+	res.OptionalContents["MC0"] = ocmd
+	page := &model.PageObject{
+		Resources: &res,
+		Annots: []*model.AnnotationDict{
+			{BaseAnnotation: model.BaseAnnotation{OC: layer1}, Subtype: model.AnnotationSquare{}},
+		},
+	}
+	doc.Catalog.Pages.Kids = []model.PageNode{page}
+
+	var buf bytes.Buffer
+	if err := doc.Write(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	read, _, err := ParsePDFReader(bytes.NewReader(buf.Bytes()), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	props := read.Catalog.OCProperties
+	if props == nil || len(props.OCGs) != 2 {
+		t.Fatalf("unexpected OCProperties %v", props)
+	}
+	l1, l2 := props.OCGs[0], props.OCGs[1]
+	if l1.Name != "Layer 1" || l2.Name != "Layer 2" || len(l2.Intent) != 2 {
+		t.Fatalf("unexpected groups %v %v", l1, l2)
+	}
+	d := props.D
+	if d.Name != "Default" || len(d.OFF) != 1 || d.OFF[0] != l2 {
+		t.Fatalf("unexpected default config %v", d)
+	}
+	if len(d.Order) != 2 || d.Order[0].Group != l1 || d.Order[1].Label != "Nested" || d.Order[1].Kids[0].Group != l2 {
+		t.Fatalf("unexpected Order %v", d.Order)
+	}
+	if len(d.RBGroups) != 1 || len(d.RBGroups[0]) != 2 {
+		t.Fatalf("unexpected RBGroups %v", d.RBGroups)
+	}
+
+	readPage := read.Catalog.Pages.Flatten()[0]
+	if oc := readPage.Resources.XObject["X1"].(*model.XObjectForm).OC; oc != l2 {
+		t.Fatalf("unexpected XObject OC %v", oc)
+	}
+	m, ok := readPage.Resources.OptionalContents["MC0"].(*model.OptionalContentMembership)
+	if !ok || m.P != "AllOn" || len(m.OCGs) != 2 || m.OCGs[0] != l1 {
+		t.Fatalf("unexpected OCMD %v", readPage.Resources.OptionalContents["MC0"])
+	}
+	if len(readPage.Resources.Properties) != 0 {
+		t.Fatalf("unexpected Properties %v", readPage.Resources.Properties)
+	}
+	if oc := readPage.Annots[0].OC; oc != l1 {
+		t.Fatalf("unexpected annotation OC %v", oc)
+	}
+}
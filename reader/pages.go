@@ -1,8 +1,10 @@
 package reader
 
 import (
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"sync"
 
 	"github.com/benoitkugler/pdf/model"
 	"github.com/benoitkugler/pdf/reader/file"
@@ -18,25 +20,75 @@ func (r resolver) processPages(entry model.Object) (model.PageTree, error) {
 	// actions may require page object that are not yet processed
 	// so, we make two passes: a first pass to fill the map indirect ref -> page object
 	// and a second pass to do the real processing
-	r.allocatesPages(pagesDict)
+	r.allocatesPages(entry, make(map[model.ObjIndirectRef]bool))
 
-	root, err := r.resolvePageTree(pagesDict)
-	if err != nil {
-		return model.PageTree{}, err
+	if r.workers > 1 {
+		// the pages are collected while walking the tree,
+		// and resolved afterwards, concurrently
+		r.pageJobs = new([]pageJob)
+	}
+
+	var root *model.PageTree
+	if r.pageNodeType(pagesDict) == "Page" {
+		// some files directly reference a page from the catalog
+		page, err := r.processPageNode(entry, make(map[model.ObjIndirectRef]bool))
+		if err != nil {
+			return model.PageTree{}, err
+		}
+		root = &model.PageTree{Kids: []model.PageNode{page}}
+	} else {
+		visited := make(map[model.ObjIndirectRef]bool)
+		if ref, isRef := entry.(model.ObjIndirectRef); isRef {
+			visited[ref] = true
+		}
+		var err error
+		root, err = r.resolvePageTree(pagesDict, visited)
+		if err != nil {
+			return model.PageTree{}, err
+		}
+	}
+	if r.pageJobs != nil {
+		if err := r.resolvePagesConcurrently(*r.pageJobs); err != nil {
+			return model.PageTree{}, err
+		}
 	}
-	return *root, err
+	// flatten the degenerate trees, such as deep single-kid chains
+	root.Normalize()
+	return *root, nil
+}
+
+// pageNodeType returns "Pages" or "Page", using the presence
+// of the Kids entry when the Type entry is missing or invalid.
+func (r resolver) pageNodeType(node model.ObjDict) model.Name {
+	name, _ := r.resolveName(node["Type"])
+	if name == "Pages" || name == "Page" {
+		return name
+	}
+	if _, hasKids := r.resolveArray(node["Kids"]); hasKids {
+		return "Pages"
+	}
+	return "Page"
 }
 
 // delay error handling to the second pass
-func (r resolver) allocatesPages(pages model.Object) {
+// `seen` is used to break cycles in invalid trees
+func (r resolver) allocatesPages(pages model.Object, seen map[model.ObjIndirectRef]bool) {
 	ref, isRef := pages.(model.ObjIndirectRef)
-	pagesDict, _ := r.resolve(pages).(model.ObjDict)
-	name, _ := r.resolveName(pagesDict["Type"])
-	switch name {
+	if isRef {
+		if seen[ref] {
+			return
+		}
+		seen[ref] = true
+	}
+	pagesDict, ok := r.resolve(pages).(model.ObjDict)
+	if !ok {
+		return
+	}
+	switch r.pageNodeType(pagesDict) {
 	case "Pages": // recursion
 		kids, _ := r.resolveArray(pagesDict["Kids"])
 		for _, kid := range kids {
-			r.allocatesPages(kid)
+			r.allocatesPages(kid, seen)
 		}
 	case "Page": // allocate a page object and store it
 		if isRef {
@@ -70,6 +122,15 @@ func (r resolver) resolveStream(content model.Object) (model.Stream, bool, error
 	return out, true, nil
 }
 
+// pageKeys are the entries handled by the model (including the inheritable ones),
+// or which can't be copied (such as the beads, referencing the pages)
+var pageKeys = map[model.Name]bool{
+	"AA": true, "AF": true, "Annots": true, "ArtBox": true, "B": true, "BleedBox": true,
+	"Contents": true, "CropBox": true, "Group": true, "MediaBox": true, "Parent": true,
+	"Resources": true, "Rotate": true, "StructParents": true, "Tabs": true, "Thumb": true,
+	"TrimBox": true, "Type": true, "VP": true,
+}
+
 // `page` has been previously allocated and must be filled
 func (r resolver) resolvePageObject(node model.ObjDict, page *model.PageObject) error {
 	if node["Resources"] != nil {
@@ -86,16 +147,10 @@ func (r resolver) resolvePageObject(node model.ObjDict, page *model.PageObject)
 	page.ArtBox = r.rectangleFromArray(node["ArtBox"])
 
 	if group, ok := r.resolve(node["Group"]).(model.ObjDict); ok {
-		var (
-			gr  model.TransparencyGroup
-			err error
-		)
-		gr.CS, err = r.resolveOneColorSpace(group["CS"])
+		gr, err := r.resolveTransparencyGroup(group)
 		if err != nil {
 			return err
 		}
-		gr.I, _ = r.resolveBool(group["I"])
-		gr.K, _ = r.resolveBool(group["K"])
 		page.Group = &gr
 	}
 
@@ -134,6 +189,9 @@ func (r resolver) resolvePageObject(node model.ObjDict, page *model.PageObject)
 		if err != nil {
 			return err
 		}
+		if an.Subtype == nil { // popup annotation or form field
+			continue
+		}
 		page.Annots = append(page.Annots, an)
 	}
 	if st, ok := r.resolveInt(node["StructParents"]); ok {
@@ -142,20 +200,55 @@ func (r resolver) resolvePageObject(node model.ObjDict, page *model.PageObject)
 	if tabs, ok := r.resolveName(node["Tabs"]); ok {
 		page.Tabs = tabs
 	}
-	return nil
+	vps, _ := r.resolveArray(node["VP"])
+	for _, vp := range vps {
+		if vp, ok := r.resolve(vp).(model.ObjDict); ok {
+			page.VP = append(page.VP, r.resolveViewport(vp))
+		}
+	}
+	aa, err := r.resolvePageAA(node["AA"])
+	if err != nil {
+		return err
+	}
+	page.AA = aa
+	page.AF, err = r.resolveFileSpecArray(node["AF"])
+	if err != nil {
+		return err
+	}
+	if thumb := node["Thumb"]; thumb != nil && !r.formsOnly {
+		// an invalid thumbnail is not an issue: viewers generate them anyway
+		page.Thumb, err = r.resolveOneXObjectImage(thumb)
+		if err != nil {
+			r.logger.Log(model.LogWarning, "invalid page thumbnail", "error", err)
+			page.Thumb = nil
+		}
+	}
+	page.Custom, err = r.resolveCustomEntries(node, pageKeys)
+	return err
 }
 
 func (r resolver) resolveAnnotation(annot model.Object) (*model.AnnotationDict, error) {
 	annotRef, isRef := annot.(model.ObjIndirectRef)
-	if annotModel := r.annotations[annotRef]; isRef && annotModel != nil {
-		return annotModel, nil
+	r.lock()
+	cached := r.annotations[annotRef]
+	r.unlock()
+	if isRef && cached != nil {
+		return cached, nil
 	}
 	var out model.AnnotationDict
 	if isRef {
 		// annotation may have action which refer back to them
 		// to avoid loop we begin by register the new pointer
 		// which will be update soon
-		r.annotations[annotRef] = &out
+		r.lock()
+		existing := r.annotations[annotRef]
+		if existing == nil {
+			r.annotations[annotRef] = &out
+		}
+		r.unlock()
+		if existing != nil { // resolved concurrently
+			return existing, nil
+		}
 	}
 	annot = r.resolve(annot)
 	annotDict, isDict := annot.(model.ObjDict)
@@ -177,20 +270,62 @@ func (r resolver) resolveAnnotationFields(annotDict model.ObjDict, out *model.An
 	}
 
 	out.Subtype, err = r.resolveAnnotationSubType(annotDict)
+	if err != nil {
+		return err
+	}
 
+	// the specific entries of unsupported annotations are kept
+	if _, isCustom := out.Subtype.(model.AnnotationCustom); isCustom {
+		out.Custom, err = r.resolveCustomEntries(annotDict, baseAnnotationKeys)
+	}
 	return err
 }
 
+// baseAnnotationKeys are the entries handled by the model for all annotations,
+// or which can't be copied (such as the references to the page or to other annotations)
+var baseAnnotationKeys = map[model.Name]bool{
+	"AF": true, "AP": true, "AS": true, "Border": true, "C": true, "Contents": true, "F": true,
+	"IRT": true, "M": true, "NM": true, "OC": true, "P": true, "Parent": true, "Popup": true,
+	"Rect": true, "StructParent": true, "Subtype": true, "Type": true,
+}
+
+// annotationKeys are the entries handled by the model, for all the
+// annotation types and the merged form fields
+var annotationKeys = map[model.Name]bool{
+	"3DA": true, "3DB": true, "3DD": true, "3DI": true, "3DV": true, "A": true, "AA": true,
+	"AC": true, "AF": true, "AIS": true, "AP": true, "AS": true, "Aspect": true,
+	"Assets": true, "B": true, "BC": true, "BE": true, "BG": true, "BS": true, "Bl": true,
+	"Border": true, "C": true, "C2W": true, "CA": true, "CL": true, "CO": true, "CP": true,
+	"Cap": true, "Configurations": true, "Contents": true, "CreationDate": true, "D": true,
+	"DA": true, "DIS": true, "DS": true, "DV": true, "Dest": true, "E": true, "F": true,
+	"FB": true, "FS": true, "FT": true, "FWPosition": true, "FWScale": true, "Ff": true,
+	"FixedPrint": true, "Fo": true, "H": true, "I": true, "IC": true, "IF": true,
+	"IN": true, "IRT": true, "IT": true, "IX": true, "InkList": true, "Kids": true,
+	"L": true, "LE": true, "LL": true, "LLE": true, "LLO": true, "Lock": true, "M": true,
+	"MK": true, "MS": true, "Matrix": true, "MaxLen": true, "Measure": true, "Mode": true,
+	"Movie": true, "N": true, "NM": true, "NP": true, "Name": true, "O": true, "OC": true,
+	"Open": true, "Opt": true, "OverlayText": true, "P": true, "PA": true, "PC": true, "PMD": true,
+	"PI": true, "PO": true, "PV": true, "Parent": true, "Popup": true, "Poster": true,
+	"Q": true, "QuadPoints": true, "R": true, "RC": true, "RD": true, "RI": true,
+	"RO": true, "RT": true, "RV": true, "Rate": true, "Rect": true, "Repeat": true,
+	"RichMediaContent": true, "RichMediaSettings": true, "Rotate": true, "S": true,
+	"SV": true, "SW": true, "ShowControls": true, "Sound": true, "State": true,
+	"StateModel": true, "StructParent": true, "Subj": true, "Subtype": true, "Sy": true,
+	"Synchronous": true, "T": true, "TB": true, "TI": true, "TM": true, "TP": true,
+	"TU": true, "Type": true, "U": true, "V": true, "VA": true, "Vertices": true,
+	"Views": true, "Volume": true, "W": true, "X": true, "XN": true,
+}
+
 func (r resolver) resolveBaseAnnotation(annotDict model.ObjDict) (out model.BaseAnnotation, err error) {
 	if rect := r.rectangleFromArray(annotDict["Rect"]); rect != nil {
 		out.Rect = *rect
 	}
 
 	contents, _ := file.IsString(r.resolve(annotDict["Contents"]))
-	out.Contents = DecodeTextString(contents)
+	out.Contents = r.decodeTextString(contents)
 
 	nm, _ := file.IsString(r.resolve(annotDict["NM"]))
-	out.NM = DecodeTextString(nm)
+	out.NM = r.decodeTextString(nm)
 
 	if m, ok := file.IsString(r.resolve(annotDict["M"])); ok {
 		if mt, ok := DateTime(m); ok {
@@ -198,9 +333,11 @@ func (r resolver) resolveBaseAnnotation(annotDict model.ObjDict) (out model.Base
 		}
 	}
 
-	out.AP, err = r.resolveAppearanceDict(annotDict["AP"])
-	if err != nil {
-		return out, err
+	if !r.formsOnly {
+		out.AP, err = r.resolveAppearanceDict(annotDict["AP"])
+		if err != nil {
+			return out, err
+		}
 	}
 	if name, ok := r.resolveName(annotDict["AS"]); ok {
 		out.AS = name
@@ -231,7 +368,18 @@ func (r resolver) resolveBaseAnnotation(annotDict model.ObjDict) (out model.Base
 	if st, ok := r.resolveInt(annotDict["StructParent"]); ok {
 		out.StructParent = model.ObjInt(st)
 	}
-	return out, nil
+	if oc := annotDict["OC"]; oc != nil {
+		out.OC, err = r.resolveOptionalContent(oc)
+		if err != nil {
+			return out, err
+		}
+	}
+	out.AF, err = r.resolveFileSpecArray(annotDict["AF"])
+	if err != nil {
+		return out, err
+	}
+	out.Custom, err = r.resolveCustomEntries(annotDict, annotationKeys)
+	return out, err
 }
 
 func (r resolver) resolveBorderStyle(o model.Object) *model.BorderStyle {
@@ -252,7 +400,8 @@ func (r resolver) resolveBorderStyle(o model.Object) *model.BorderStyle {
 }
 
 // node, possibly root
-func (r resolver) resolvePageTree(node model.ObjDict) (*model.PageTree, error) {
+// `visited` is used to break cycles in invalid trees
+func (r resolver) resolvePageTree(node model.ObjDict, visited map[model.ObjIndirectRef]bool) (*model.PageTree, error) {
 	var page model.PageTree
 	if node["Resources"] != nil { // else, inherited
 		resources, err := r.resolveOneResourceDict(node["Resources"])
@@ -265,39 +414,87 @@ func (r resolver) resolvePageTree(node model.ObjDict) (*model.PageTree, error) {
 
 	kids, _ := r.resolveArray(node["Kids"])
 	for _, node := range kids {
-		kid, err := r.processPageNode(node)
+		kid, err := r.processPageNode(node, visited)
 		if err != nil {
 			return nil, err
 		}
-		page.Kids = append(page.Kids, kid)
+		if kid != nil {
+			page.Kids = append(page.Kids, kid)
+		}
 	}
 	return &page, nil
 }
 
-func (r resolver) processPageNode(node model.Object) (model.PageNode, error) {
+// processPageNode returns nil for nodes already visited,
+// which happens in invalid (cyclic) trees
+func (r resolver) processPageNode(node model.Object, visited map[model.ObjIndirectRef]bool) (model.PageNode, error) {
+	if err := r.enter(); err != nil {
+		return nil, err
+	}
 	// track the refs to page object, needed by destinations
 	ref, isRef := node.(model.ObjIndirectRef)
+	if isRef {
+		if visited[ref] {
+			return nil, nil
+		}
+		visited[ref] = true
+	}
 	node = r.resolve(node)
 	nodeDict, ok := node.(model.ObjDict)
 	if !ok {
 		return nil, errType("PageNode", node)
 	}
-	name, _ := r.resolveName(nodeDict["Type"])
-	switch name {
-	case "Pages":
-		return r.resolvePageTree(nodeDict)
-	case "Page":
-		var page *model.PageObject
-		if isRef {
-			page = r.pages[ref]
-		} else { // should not happen
-			page = new(model.PageObject)
+	if r.pageNodeType(nodeDict) == "Pages" {
+		return r.resolvePageTree(nodeDict, visited)
+	}
+	var page *model.PageObject
+	if isRef {
+		page = r.pages[ref]
+	} else { // should not happen
+		page = new(model.PageObject)
+	}
+	if r.pageJobs != nil {
+		*r.pageJobs = append(*r.pageJobs, pageJob{node: nodeDict, page: page})
+		return page, nil
+	}
+	err := r.resolvePageObject(nodeDict, page)
+	return page, err
+}
+
+// pageJob is a page allocated while walking the page tree,
+// and resolved afterwards
+type pageJob struct {
+	node model.ObjDict
+	page *model.PageObject
+}
+
+// resolvePagesConcurrently fills the pages using `r.workers` goroutines,
+// and returns the error of the first invalid page, if any
+func (r resolver) resolvePagesConcurrently(jobs []pageJob) error {
+	errs := make([]error, len(jobs))
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < r.workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				errs[i] = r.resolvePageObject(jobs[i].node, jobs[i].page)
+			}
+		}()
+	}
+	for i := range jobs {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
 		}
-		err := r.resolvePageObject(nodeDict, page)
-		return page, err
-	default:
-		return nil, fmt.Errorf("unexpected value for Type field of page node: %s", nodeDict["Type"])
 	}
+	return nil
 }
 
 func (r resolver) resolveDestinationLocation(dest model.ObjArray) (model.DestinationLocation, error) {
@@ -371,7 +568,7 @@ func (r resolver) processDestination(dest model.Object) (model.Destination, erro
 		return model.DestinationName(dest), nil
 	case model.ObjStringLiteral, model.ObjHexLiteral:
 		d, _ := file.IsString(dest)
-		return model.DestinationString(DecodeTextString(d)), nil
+		return model.DestinationString(r.decodeTextString(d)), nil
 	case model.ObjArray:
 		return r.resolveExplicitDestination(dest)
 	default:
@@ -379,7 +576,8 @@ func (r resolver) processDestination(dest model.Object) (model.Destination, erro
 	}
 }
 
-// TODO: more annotation subtypes
+// resolveAnnotationSubType returns nil for popup annotations, and
+// model.AnnotationCustom for unsupported subtypes
 func (r resolver) resolveAnnotationSubType(annot model.ObjDict) (model.Annotation, error) {
 	var err error
 	name, _ := r.resolveName(annot["Subtype"])
@@ -393,10 +591,10 @@ func (r resolver) resolveAnnotationSubType(annot model.ObjDict) (model.Annotatio
 		an.Open, _ = r.resolveBool(annot["Open"])
 		an.Name, _ = r.resolveName(annot["Name"])
 		if st, ok := file.IsString(r.resolve(annot["State"])); ok {
-			an.State = DecodeTextString(st)
+			an.State = r.decodeTextString(st)
 		}
 		if st, ok := file.IsString(r.resolve(annot["StateModel"])); ok {
-			an.StateModel = DecodeTextString(st)
+			an.StateModel = r.decodeTextString(st)
 		}
 		return an, nil
 	case "Link":
@@ -425,7 +623,7 @@ func (r resolver) resolveAnnotationSubType(annot model.ObjDict) (model.Annotatio
 	case "FileAttachment":
 		var an model.AnnotationFileAttachment
 		title, _ := file.IsString(r.resolve(annot["T"]))
-		an.T = DecodeTextString(title)
+		an.T = r.decodeTextString(title)
 		an.FS, err = r.resolveFileSpec(annot["FS"])
 		return an, err
 	case "Widget":
@@ -450,7 +648,7 @@ func (r resolver) resolveAnnotationSubType(annot model.ObjDict) (model.Annotatio
 	case "Screen":
 		var an model.AnnotationScreen
 		title, _ := file.IsString(r.resolve(annot["T"]))
-		an.T = DecodeTextString(title)
+		an.T = r.decodeTextString(title)
 		an.MK, err = r.resolveAnnotationMK(annot["MK"])
 		if err != nil {
 			return nil, err
@@ -467,31 +665,511 @@ func (r resolver) resolveAnnotationSubType(annot model.ObjDict) (model.Annotatio
 			an.P = r.pages[ref]
 		}
 		return an, nil
+	case "Redact":
+		var an model.AnnotationRedact
+		an.AnnotationMarkup, err = r.resolveAnnotationMarkup(annot)
+		if err != nil {
+			return nil, err
+		}
+		qp, _ := r.resolveArray(annot["QuadPoints"])
+		an.QuadPoints = r.processFloatArray(qp)
+		ic, _ := r.resolveArray(annot["IC"])
+		an.IC = r.processFloatArray(ic)
+		if ro := annot["RO"]; ro != nil {
+			an.RO, err = r.resolveOneXObjectForm(ro)
+			if err != nil {
+				return nil, err
+			}
+		}
+		text, _ := file.IsString(r.resolve(annot["OverlayText"]))
+		an.OverlayText = r.decodeTextString(text)
+		an.Repeat, _ = r.resolveBool(annot["Repeat"])
+		an.DA, _ = file.IsString(r.resolve(annot["DA"]))
+		if q, ok := r.resolveInt(annot["Q"]); ok {
+			an.Q = model.Quadding(q)
+		}
+		return an, nil
+	case "FreeText":
+		var an model.AnnotationFreeText
+		an.AnnotationMarkup, err = r.resolveAnnotationMarkup(annot)
+		if err != nil {
+			return nil, err
+		}
+		an.DA, _ = file.IsString(r.resolve(annot["DA"]))
+		if q, ok := r.resolveInt(annot["Q"]); ok {
+			an.Q = uint8(q)
+		}
+		ds, _ := file.IsString(r.resolve(annot["DS"]))
+		an.DS = r.decodeTextString(ds)
+		cl, _ := r.resolveArray(annot["CL"])
+		an.CL = r.processFloatArray(cl)
+		an.BE = r.resolveBorderEffect(annot["BE"])
+		if rd := r.rectangleFromArray(annot["RD"]); rd != nil {
+			an.RD = *rd
+		}
+		an.BS = r.resolveBorderStyle(annot["BS"])
+		an.LE, _ = r.resolveName(annot["LE"])
+		return an, nil
+	case "Line":
+		var an model.AnnotationLine
+		an.AnnotationMarkup, err = r.resolveAnnotationMarkup(annot)
+		if err != nil {
+			return nil, err
+		}
+		l, _ := r.resolveArray(annot["L"])
+		copy(an.L[:], r.processFloatArray(l))
+		an.BS = r.resolveBorderStyle(annot["BS"])
+		le, _ := r.resolveArray(annot["LE"])
+		copy(an.LE[:], r.resolveNameArray(le))
+		ic, _ := r.resolveArray(annot["IC"])
+		an.IC = r.processFloatArray(ic)
+		an.LL, _ = r.resolveNumber(annot["LL"])
+		an.LLE, _ = r.resolveNumber(annot["LLE"])
+		an.Cap, _ = r.resolveBool(annot["Cap"])
+		if llo, ok := r.resolveNumber(annot["LLO"]); ok {
+			an.LLO = model.ObjFloat(llo)
+		}
+		an.CP, _ = r.resolveName(annot["CP"])
+		co, _ := r.resolveArray(annot["CO"])
+		copy(an.CO[:], r.processFloatArray(co))
+		an.Measure = r.resolveMeasure(annot["Measure"])
+		return an, nil
+	case "Square", "Circle":
+		var an model.AnnotationSquare
+		an.AnnotationMarkup, err = r.resolveAnnotationMarkup(annot)
+		if err != nil {
+			return nil, err
+		}
+		an.BS = r.resolveBorderStyle(annot["BS"])
+		ic, _ := r.resolveArray(annot["IC"])
+		an.IC = r.processFloatArray(ic)
+		an.BE = r.resolveBorderEffect(annot["BE"])
+		if rd := r.rectangleFromArray(annot["RD"]); rd != nil {
+			an.RD = *rd
+		}
+		if name == "Circle" {
+			return model.AnnotationCircle(an), nil
+		}
+		return an, nil
+	case "Polygon", "PolyLine":
+		var an model.AnnotationPolygon
+		an.AnnotationMarkup, err = r.resolveAnnotationMarkup(annot)
+		if err != nil {
+			return nil, err
+		}
+		vertices, _ := r.resolveArray(annot["Vertices"])
+		an.Vertices = r.processFloatArray(vertices)
+		le, _ := r.resolveArray(annot["LE"])
+		copy(an.LE[:], r.resolveNameArray(le))
+		an.BS = r.resolveBorderStyle(annot["BS"])
+		ic, _ := r.resolveArray(annot["IC"])
+		an.IC = r.processFloatArray(ic)
+		an.BE = r.resolveBorderEffect(annot["BE"])
+		an.Measure = r.resolveMeasure(annot["Measure"])
+		if name == "PolyLine" {
+			return model.AnnotationPolyLine(an), nil
+		}
+		return an, nil
+	case "Highlight", "Underline", "Squiggly", "StrikeOut":
+		var an model.AnnotationHighlight
+		an.AnnotationMarkup, err = r.resolveAnnotationMarkup(annot)
+		if err != nil {
+			return nil, err
+		}
+		qp, _ := r.resolveArray(annot["QuadPoints"])
+		an.QuadPoints = r.processFloatArray(qp)
+		switch name {
+		case "Underline":
+			return model.AnnotationUnderline(an), nil
+		case "Squiggly":
+			return model.AnnotationSquiggly(an), nil
+		case "StrikeOut":
+			return model.AnnotationStrikeOut(an), nil
+		}
+		return an, nil
+	case "Caret":
+		var an model.AnnotationCaret
+		an.AnnotationMarkup, err = r.resolveAnnotationMarkup(annot)
+		if err != nil {
+			return nil, err
+		}
+		if rd := r.rectangleFromArray(annot["RD"]); rd != nil {
+			an.RD = *rd
+		}
+		an.Sy, _ = r.resolveName(annot["Sy"])
+		return an, nil
+	case "Stamp":
+		var an model.AnnotationStamp
+		an.AnnotationMarkup, err = r.resolveAnnotationMarkup(annot)
+		if err != nil {
+			return nil, err
+		}
+		an.Name, _ = r.resolveName(annot["Name"])
+		return an, nil
+	case "Ink":
+		var an model.AnnotationInk
+		an.AnnotationMarkup, err = r.resolveAnnotationMarkup(annot)
+		if err != nil {
+			return nil, err
+		}
+		inkList, _ := r.resolveArray(annot["InkList"])
+		for _, path := range inkList {
+			path, _ := r.resolveArray(path)
+			an.InkList = append(an.InkList, r.processFloatArray(path))
+		}
+		an.BS = r.resolveBorderStyle(annot["BS"])
+		return an, nil
+	case "Sound":
+		var an model.AnnotationSound
+		an.AnnotationMarkup, err = r.resolveAnnotationMarkup(annot)
+		if err != nil {
+			return nil, err
+		}
+		an.Sound, err = r.resolveSound(annot["Sound"])
+		if err != nil {
+			return nil, err
+		}
+		an.Name, _ = r.resolveName(annot["Name"])
+		return an, nil
+	case "Movie":
+		var an model.AnnotationMovie
+		title, _ := file.IsString(r.resolve(annot["T"]))
+		an.T = r.decodeTextString(title)
+		an.Movie, err = r.resolveMovie(annot["Movie"])
+		if err != nil {
+			return nil, err
+		}
+		switch a := r.resolve(annot["A"]).(type) {
+		case model.ObjBool:
+			an.NoPlay = !bool(a)
+		case model.ObjDict:
+			an.A = r.resolveMovieActivation(a)
+		}
+		return an, nil
+	case "Watermark":
+		var an model.AnnotationWatermark
+		if fp, ok := r.resolve(annot["FixedPrint"]).(model.ObjDict); ok {
+			an.FixedPrint = new(model.FixedPrint)
+			if m := r.matrixFromArray(fp["Matrix"]); m != nil {
+				an.FixedPrint.Matrix = *m
+			}
+			an.FixedPrint.H, _ = r.resolveNumber(fp["H"])
+			an.FixedPrint.V, _ = r.resolveNumber(fp["V"])
+		}
+		return an, nil
+	case "3D":
+		var an model.Annotation3D
+		an.DD, err = r.resolveStream3D(annot["3DD"])
+		if err != nil {
+			return nil, err
+		}
+		an.DV = r.resolveView3DSelect(annot["3DV"])
+		if da, ok := r.resolve(annot["3DA"]).(model.ObjDict); ok {
+			an.DA = r.resolveActivation3D(da)
+		}
+		if di, ok := r.resolveBool(annot["3DI"]); ok {
+			an.DI = model.ObjBool(di)
+		}
+		an.DB = r.rectangleFromArray(annot["3DB"])
+		return an, nil
+	case "RichMedia":
+		var an model.AnnotationRichMedia
+		if settings, ok := r.resolve(annot["RichMediaSettings"]).(model.ObjDict); ok {
+			an.RichMediaSettings = new(model.RichMediaSettings)
+			if activation, ok := r.resolve(settings["Activation"]).(model.ObjDict); ok {
+				an.RichMediaSettings.Activation, _ = r.resolveName(activation["Condition"])
+			}
+			if deactivation, ok := r.resolve(settings["Deactivation"]).(model.ObjDict); ok {
+				an.RichMediaSettings.Deactivation, _ = r.resolveName(deactivation["Condition"])
+			}
+		}
+		an.RichMediaContent, err = r.resolveRichMediaContent(annot["RichMediaContent"])
+		if err != nil {
+			return nil, err
+		}
+		return an, nil
+	case "Popup":
+		// popup annotations are stored in their parent (see AnnotationMarkup)
+		return nil, nil
 	case "": // a form field may come here
 		return nil, nil
 	default:
-		fmt.Println("TODO annot :", name, annot)
+		return model.AnnotationCustom{Subtype: name}, nil
+	}
+}
+
+func (r resolver) resolveBorderEffect(o model.Object) *model.BorderEffect {
+	dict, _ := r.resolve(o).(model.ObjDict)
+	if dict == nil {
+		return nil
+	}
+	var out model.BorderEffect
+	out.S, _ = r.resolveName(dict["S"])
+	out.I, _ = r.resolveNumber(dict["I"])
+	return &out
+}
+
+func (r resolver) resolveSound(o model.Object) (*model.SoundStream, error) {
+	ref, isRef := o.(model.ObjIndirectRef)
+	r.lock()
+	cached := r.sounds[ref]
+	r.unlock()
+	if isRef && cached != nil {
+		return cached, nil
+	}
+	stream, ok, err := r.resolveStream(o)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
 		return nil, nil
 	}
+	out := model.SoundStream{Stream: stream}
+	dict := r.resolve(o).(model.ObjStream).Args
+	out.R, _ = r.resolveNumber(dict["R"])
+	out.C, _ = r.resolveInt(dict["C"])
+	out.B, _ = r.resolveInt(dict["B"])
+	out.E, _ = r.resolveName(dict["E"])
+	out.CO, _ = r.resolveName(dict["CO"])
+	if isRef {
+		r.lock()
+		defer r.unlock()
+		if existing := r.sounds[ref]; existing != nil { // resolved concurrently
+			return existing, nil
+		}
+		r.sounds[ref] = &out
+	}
+	return &out, nil
+}
+
+func (r resolver) resolveMovie(o model.Object) (out model.Movie, err error) {
+	dict, _ := r.resolve(o).(model.ObjDict)
+	if dict == nil {
+		return out, errType("Movie", o)
+	}
+	if f := dict["F"]; f != nil {
+		out.F, err = r.resolveFileSpec(f)
+		if err != nil {
+			return out, err
+		}
+	}
+	if aspect, _ := r.resolveArray(dict["Aspect"]); len(aspect) == 2 {
+		out.Aspect[0], _ = r.resolveInt(aspect[0])
+		out.Aspect[1], _ = r.resolveInt(aspect[1])
+	}
+	out.Rotate, _ = r.resolveInt(dict["Rotate"])
+	switch poster := r.resolve(dict["Poster"]).(type) {
+	case model.ObjBool:
+		out.Poster = bool(poster)
+	case model.ObjStream:
+		out.PosterStream, err = r.resolveOneXObjectImage(dict["Poster"])
+		if err != nil {
+			return out, err
+		}
+	}
+	return out, nil
+}
+
+func (r resolver) resolveMovieActivation(dict model.ObjDict) *model.MovieActivation {
+	var out model.MovieActivation
+	out.Rate, _ = r.resolveNumber(dict["Rate"])
+	out.Volume, _ = r.resolveNumber(dict["Volume"])
+	out.ShowControls, _ = r.resolveBool(dict["ShowControls"])
+	out.Mode, _ = r.resolveName(dict["Mode"])
+	out.Synchronous, _ = r.resolveBool(dict["Synchronous"])
+	if scale, _ := r.resolveArray(dict["FWScale"]); len(scale) == 2 {
+		out.FWScale[0], _ = r.resolveInt(scale[0])
+		out.FWScale[1], _ = r.resolveInt(scale[1])
+	}
+	if pos, _ := r.resolveArray(dict["FWPosition"]); len(pos) == 2 {
+		copy(out.FWPosition[:], r.processFloatArray(pos))
+	}
+	return &out
+}
+
+func (r resolver) resolveStream3D(o model.Object) (*model.Stream3D, error) {
+	ref, isRef := o.(model.ObjIndirectRef)
+	r.lock()
+	cached := r.streams3D[ref]
+	r.unlock()
+	if isRef && cached != nil {
+		return cached, nil
+	}
+	stream, ok, err := r.resolveStream(o)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+	out := model.Stream3D{Stream: stream}
+	dict := r.resolve(o).(model.ObjStream).Args
+	out.Subtype, _ = r.resolveName(dict["Subtype"])
+	out.VA = r.resolveViews3D(dict["VA"])
+	out.DV = r.resolveView3DSelect(dict["DV"])
+	if isRef {
+		r.lock()
+		defer r.unlock()
+		if existing := r.streams3D[ref]; existing != nil { // resolved concurrently
+			return existing, nil
+		}
+		r.streams3D[ref] = &out
+	}
+	return &out, nil
+}
+
+func (r resolver) resolveViews3D(o model.Object) []model.View3D {
+	views, _ := r.resolveArray(o)
+	var out []model.View3D
+	for _, view := range views {
+		if dict, ok := r.resolve(view).(model.ObjDict); ok {
+			out = append(out, r.resolveView3D(dict))
+		}
+	}
+	return out
+}
+
+// resolveView3DSelect returns nil if `o` is missing or invalid
+func (r resolver) resolveView3DSelect(o model.Object) model.View3DSelect {
+	switch o := r.resolve(o).(type) {
+	case model.ObjInt:
+		return model.View3DIndex(o)
+	case model.ObjName:
+		return model.View3DName(o)
+	case model.ObjStringLiteral, model.ObjHexLiteral:
+		s, _ := file.IsString(o)
+		return model.View3DInternalName(r.decodeTextString(s))
+	case model.ObjDict:
+		view := r.resolveView3D(o)
+		return &view
+	}
+	return nil
+}
+
+func (r resolver) resolveView3D(dict model.ObjDict) (out model.View3D) {
+	xn, _ := file.IsString(r.resolve(dict["XN"]))
+	out.XN = r.decodeTextString(xn)
+	in, _ := file.IsString(r.resolve(dict["IN"]))
+	out.IN = r.decodeTextString(in)
+	out.MS, _ = r.resolveName(dict["MS"])
+	if c2w, _ := r.resolveArray(dict["C2W"]); len(c2w) == 12 {
+		out.C2W = r.processFloatArray(c2w)
+	}
+	switch path := r.resolve(dict["U3DPath"]).(type) {
+	case model.ObjStringLiteral, model.ObjHexLiteral:
+		s, _ := file.IsString(path)
+		out.U3DPath = []string{r.decodeTextString(s)}
+	case model.ObjArray:
+		out.U3DPath = r.resolveStrings(path, true)
+	}
+	out.CO, _ = r.resolveNumber(dict["CO"])
+	if p, ok := r.resolve(dict["P"]).(model.ObjDict); ok {
+		out.P = new(model.Projection3D)
+		out.P.Subtype, _ = r.resolveName(p["Subtype"])
+		out.P.CS, _ = r.resolveName(p["CS"])
+		out.P.F, _ = r.resolveNumber(p["F"])
+		out.P.N, _ = r.resolveNumber(p["N"])
+		out.P.FOV, _ = r.resolveNumber(p["FOV"])
+		out.P.OS, _ = r.resolveNumber(p["OS"])
+		out.P.OB, _ = r.resolveName(p["OB"])
+	}
+	if bg, ok := r.resolve(dict["BG"]).(model.ObjDict); ok {
+		out.BG = new(model.Background3D)
+		if c, _ := r.resolveArray(bg["C"]); len(c) != 0 {
+			out.BG.C = r.processFloatArray(c)
+		}
+		out.BG.EA, _ = r.resolveBool(bg["EA"])
+	}
+	// the other entries are stored as is
+	if rm, err := r.resolveCustomObject(dict["RM"]); err == nil {
+		out.RM, _ = rm.(model.ObjDict)
+	}
+	if ls, err := r.resolveCustomObject(dict["LS"]); err == nil {
+		out.LS, _ = ls.(model.ObjDict)
+	}
+	if sa, err := r.resolveCustomObject(dict["SA"]); err == nil {
+		out.SA, _ = sa.(model.ObjArray)
+	}
+	if na, err := r.resolveCustomObject(dict["NA"]); err == nil {
+		out.NA, _ = na.(model.ObjArray)
+	}
+	return out
+}
+
+func (r resolver) resolveActivation3D(dict model.ObjDict) *model.Activation3D {
+	var out model.Activation3D
+	out.A, _ = r.resolveName(dict["A"])
+	out.AIS, _ = r.resolveName(dict["AIS"])
+	out.D, _ = r.resolveName(dict["D"])
+	out.DIS, _ = r.resolveName(dict["DIS"])
+	if tb, ok := r.resolveBool(dict["TB"]); ok {
+		out.TB = model.ObjBool(tb)
+	}
+	out.NP, _ = r.resolveBool(dict["NP"])
+	return &out
+}
+
+func (r resolver) resolveRichMediaContent(o model.Object) (out model.RichMediaContent, err error) {
+	dict, _ := r.resolve(o).(model.ObjDict)
+	if dict == nil {
+		return out, errType("RichMediaContent", o)
+	}
+	err = r.resolveNameTree(dict["Assets"], embFileNameTree{out: &out.Assets})
+	if err != nil {
+		return out, err
+	}
+	configurations, _ := r.resolveArray(dict["Configurations"])
+	for _, conf := range configurations {
+		confDict, ok := r.resolve(conf).(model.ObjDict)
+		if !ok {
+			continue
+		}
+		var configuration model.RichMediaConfiguration
+		configuration.Subtype, _ = r.resolveName(confDict["Subtype"])
+		name, _ := file.IsString(r.resolve(confDict["Name"]))
+		configuration.Name = r.decodeTextString(name)
+		instances, _ := r.resolveArray(confDict["Instances"])
+		for _, inst := range instances {
+			instDict, ok := r.resolve(inst).(model.ObjDict)
+			if !ok {
+				continue
+			}
+			var instance model.RichMediaInstance
+			instance.Subtype, _ = r.resolveName(instDict["Subtype"])
+			if asset := instDict["Asset"]; asset != nil {
+				instance.Asset, err = r.resolveFileSpec(asset)
+				if err != nil {
+					return out, err
+				}
+			}
+			configuration.Instances = append(configuration.Instances, instance)
+		}
+		out.Configurations = append(out.Configurations, configuration)
+	}
+	out.Views = r.resolveViews3D(dict["Views"])
+	return out, nil
 }
 
 func (r resolver) resolveAnnotationMarkup(annot model.ObjDict) (out model.AnnotationMarkup, err error) {
 	t, _ := file.IsString(r.resolve(annot["T"]))
-	out.T = DecodeTextString(t)
-	out.Popup, err = r.resolveAnnotationPopup(annot)
+	out.T = r.decodeTextString(t)
+	out.Popup, err = r.resolveAnnotationPopup(annot["Popup"])
 	if err != nil {
 		return out, err
 	}
 	if ca, ok := r.resolveNumber(annot["CA"]); ok {
 		out.CA = model.ObjFloat(ca)
 	}
-	out.RC = r.textOrStream(annot["RC"])
+	out.RC, err = r.textOrStream(annot["RC"])
+	if err != nil {
+		return out, err
+	}
 
 	cd, _ := file.IsString(r.resolve(annot["CreationDate"]))
 	out.CreationDate, _ = DateTime(cd)
 
 	subj, _ := file.IsString(r.resolve(annot["Subj"]))
-	out.Subj = DecodeTextString(subj)
+	out.Subj = r.decodeTextString(subj)
 
 	out.IT, _ = r.resolveName(annot["IT"])
 	return out, nil
@@ -566,6 +1244,22 @@ func (r resolver) resolveAnnotationAA(o model.Object) (out model.AnnotationAddit
 	return out, nil
 }
 
+func (r resolver) resolvePageAA(o model.Object) (out model.PageAdditionalActions, err error) {
+	dict, _ := r.resolve(o).(model.ObjDict)
+	if dict == nil {
+		return out, nil
+	}
+	out.O, err = r.processAction(dict["O"])
+	if err != nil {
+		return out, err
+	}
+	out.C, err = r.processAction(dict["C"])
+	if err != nil {
+		return out, err
+	}
+	return out, nil
+}
+
 func (r resolver) resolveAnnotationMK(o model.Object) (*model.AppearanceCharacteristics, error) {
 	dict, _ := r.resolve(o).(model.ObjDict)
 	if dict == nil {
@@ -582,29 +1276,31 @@ func (r resolver) resolveAnnotationMK(o model.Object) (*model.AppearanceCharacte
 	out.BG = r.processFloatArray(bg)
 
 	ts, _ := file.IsString(r.resolve(dict["CA"]))
-	out.CA = DecodeTextString(ts)
+	out.CA = r.decodeTextString(ts)
 	ts, _ = file.IsString(r.resolve(dict["RC"]))
-	out.RC = DecodeTextString(ts)
+	out.RC = r.decodeTextString(ts)
 	ts, _ = file.IsString(r.resolve(dict["AC"]))
-	out.AC = DecodeTextString(ts)
+	out.AC = r.decodeTextString(ts)
 
-	var err error
-	if of := dict["I"]; r.resolve(of) != nil {
-		out.I, err = r.resolveOneXObjectForm(of)
-		if err != nil {
-			return nil, err
+	if !r.formsOnly { // the icons are not needed to fill the fields
+		var err error
+		if of := dict["I"]; r.resolve(of) != nil {
+			out.I, err = r.resolveOneXObjectForm(of)
+			if err != nil {
+				return nil, err
+			}
 		}
-	}
-	if of := dict["RI"]; r.resolve(of) != nil {
-		out.RI, err = r.resolveOneXObjectForm(of)
-		if err != nil {
-			return nil, err
+		if of := dict["RI"]; r.resolve(of) != nil {
+			out.RI, err = r.resolveOneXObjectForm(of)
+			if err != nil {
+				return nil, err
+			}
 		}
-	}
-	if of := dict["IX"]; r.resolve(of) != nil {
-		out.IX, err = r.resolveOneXObjectForm(of)
-		if err != nil {
-			return nil, err
+		if of := dict["IX"]; r.resolve(of) != nil {
+			out.IX, err = r.resolveOneXObjectForm(of)
+			if err != nil {
+				return nil, err
+			}
 		}
 	}
 	out.IF = r.resolveIconFit(dict["IF"])
@@ -634,8 +1330,11 @@ func (r resolver) resolveIconFit(o model.Object) *model.IconFit {
 
 func (r resolver) resolveFileSpec(fs model.Object) (*model.FileSpec, error) {
 	fsRef, isFsRef := fs.(model.ObjIndirectRef)
-	if fileSpec := r.fileSpecs[fsRef]; isFsRef && fileSpec != nil {
-		return fileSpec, nil
+	r.lock()
+	cached := r.fileSpecs[fsRef]
+	r.unlock()
+	if isFsRef && cached != nil {
+		return cached, nil
 	}
 	fs = r.resolve(fs)
 
@@ -650,42 +1349,68 @@ func (r resolver) resolveFileSpec(fs model.Object) (*model.FileSpec, error) {
 
 		// we give the priority to UF, and default to F
 		uf, _ := file.IsString(r.resolve(fsDict["UF"]))
-		fileSpec.UF = DecodeTextString(uf)
+		fileSpec.UF = r.decodeTextString(uf)
 		if fileSpec.UF == "" {
 			fileSpec.UF, _ = file.IsString(r.resolve(fsDict["F"]))
 		}
 
 		desc, _ := file.IsString(r.resolve(fsDict["Desc"]))
-		fileSpec.Desc = DecodeTextString(desc)
+		fileSpec.Desc = r.decodeTextString(desc)
+		fileSpec.CI = r.resolveCollectionItems(fsDict["CI"])
+		fileSpec.AFRelationship, _ = r.resolveName(fsDict["AFRelationship"])
 
-		ef := r.resolve(fsDict["EF"])
-		efDict, isDict := ef.(model.ObjDict)
-		if !isDict {
-			return nil, errType("EF Dict", ef)
-		}
-		fileEntry := efDict["UF"]
-		for _, alt := range [...]model.Name{"F", "DOS", "Mac", "Unix"} {
-			if fileEntry != nil {
-				break
+		// EF is only present for embedded files
+		if ef := r.resolve(fsDict["EF"]); ef != nil {
+			efDict, isDict := ef.(model.ObjDict)
+			if !isDict {
+				return nil, errType("EF Dict", ef)
+			}
+			fileEntry := efDict["UF"]
+			for _, alt := range [...]model.Name{"F", "DOS", "Mac", "Unix"} {
+				if fileEntry != nil {
+					break
+				}
+				fileEntry = efDict[alt]
+			}
+			var err error
+			fileSpec.EF, err = r.resolveFileContent(fileEntry)
+			if err != nil {
+				return nil, err
 			}
-			fileEntry = efDict[alt]
-		}
-		var err error
-		fileSpec.EF, err = r.resolveFileContent(fileEntry)
-		if err != nil {
-			return nil, err
 		}
 	}
 	if isFsRef { // write back to the cache
+		r.lock()
+		defer r.unlock()
+		if existing := r.fileSpecs[fsRef]; existing != nil { // resolved concurrently
+			return existing, nil
+		}
 		r.fileSpecs[fsRef] = &fileSpec
 	}
 	return &fileSpec, nil
 }
 
+// resolveFileSpecArray resolves an array of associated files (AF entry)
+func (r resolver) resolveFileSpecArray(o model.Object) ([]*model.FileSpec, error) {
+	ar, _ := r.resolveArray(o)
+	var out []*model.FileSpec
+	for _, fs := range ar {
+		fileSpec, err := r.resolveFileSpec(fs)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, fileSpec)
+	}
+	return out, nil
+}
+
 func (r resolver) resolveFileContent(fileEntry model.Object) (*model.EmbeddedFileStream, error) {
 	fileEntryRef, isFileRef := fileEntry.(model.ObjIndirectRef)
-	if emb := r.fileContents[fileEntryRef]; isFileRef && emb != nil {
-		return emb, nil
+	r.lock()
+	cached := r.fileContents[fileEntryRef]
+	r.unlock()
+	if isFileRef && cached != nil {
+		return cached, nil
 	}
 	fileEntry = r.resolve(fileEntry)
 	stream, isStream := fileEntry.(model.ObjStream)
@@ -702,8 +1427,13 @@ func (r resolver) resolveFileContent(fileEntry model.Object) (*model.EmbeddedFil
 		out.Params.Size = size
 	}
 
-	checkSum, _ := file.IsString(r.resolve(paramsDict["CheckSum"]))
-	out.Params.CheckSum = checkSum
+	// the model uses an hex encoded form
+	if checkSum, ok := file.IsString(r.resolve(paramsDict["CheckSum"])); ok {
+		out.Params.CheckSum = hex.EncodeToString([]byte(checkSum))
+	}
+	if subtype, ok := r.resolveName(stream.Args["Subtype"]); ok {
+		out.Subtype = string(subtype)
+	}
 
 	if cd, ok := file.IsString(r.resolve(paramsDict["CreationDate"])); ok {
 		out.Params.CreationDate, _ = DateTime(cd)
@@ -721,6 +1451,11 @@ func (r resolver) resolveFileContent(fileEntry model.Object) (*model.EmbeddedFil
 	}
 	out.Stream = cs
 	if isFileRef { // write back to the cache
+		r.lock()
+		defer r.unlock()
+		if existing := r.fileContents[fileEntryRef]; existing != nil { // resolved concurrently
+			return existing, nil
+		}
 		r.fileContents[fileEntryRef] = &out
 	}
 	return &out, err
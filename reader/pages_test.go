@@ -1,9 +1,13 @@
 package reader
 
 import (
+	"bytes"
+	"fmt"
+	"reflect"
 	"testing"
 
 	"github.com/benoitkugler/pdf/model"
+	"github.com/benoitkugler/pdf/reader/file"
 )
 
 func TestDestinations(t *testing.T) {
@@ -22,3 +26,435 @@ func TestDestinations(t *testing.T) {
 		}
 	}
 }
+
+func TestAnnotationsRoundTrip(t *testing.T) {
+	markup := model.AnnotationMarkup{T: "Author", Subj: "Review"}
+	withPopup := markup
+	withPopup.Popup = &model.AnnotationPopup{BaseAnnotation: model.BaseAnnotation{Rect: model.Rectangle{Llx: 10, Lly: 10, Urx: 50, Ury: 50}}, Open: true}
+	quads := []model.Fl{10, 20, 30, 20, 10, 10, 30, 10}
+	sound := &model.SoundStream{Stream: model.Stream{Content: []byte{1, 2, 3, 4}}, R: 8000, C: 2, B: 16, E: "Signed"}
+
+	subtypes := []model.Annotation{
+		model.AnnotationFreeText{AnnotationMarkup: markup, DA: "/Helv 12 Tf", Q: 1, CL: []model.Fl{1, 2, 3, 4}, LE: "OpenArrow"},
+		model.AnnotationLine{AnnotationMarkup: markup, L: [4]model.Fl{1, 2, 3, 4}, LE: [2]model.Name{"Square", "None"}, IC: []model.Fl{0}, Cap: true},
+		model.AnnotationSquare{AnnotationMarkup: markup, IC: []model.Fl{1, 0, 0}, BE: &model.BorderEffect{S: "C", I: 1}},
+		model.AnnotationCircle{AnnotationMarkup: markup, IC: []model.Fl{1}, RD: model.Rectangle{Llx: 1, Lly: 1, Urx: 1, Ury: 1}},
+		model.AnnotationPolygon{AnnotationMarkup: markup, Vertices: []model.Fl{0, 0, 10, 10, 20, 0}, IC: []model.Fl{0.5}},
+		model.AnnotationPolyLine{AnnotationMarkup: markup, Vertices: []model.Fl{0, 0, 10, 10}, IC: []model.Fl{0}, LE: [2]model.Name{"None", "ClosedArrow"}},
+		model.AnnotationHighlight{AnnotationMarkup: markup, QuadPoints: quads},
+		model.AnnotationUnderline{AnnotationMarkup: markup, QuadPoints: quads},
+		model.AnnotationSquiggly{AnnotationMarkup: markup, QuadPoints: quads},
+		model.AnnotationStrikeOut{AnnotationMarkup: markup, QuadPoints: quads},
+		model.AnnotationCaret{AnnotationMarkup: markup, Sy: "P"},
+		model.AnnotationStamp{AnnotationMarkup: markup, Name: "Approved"},
+		model.AnnotationInk{AnnotationMarkup: markup, InkList: [][]model.Fl{{0, 0, 1, 1}, {2, 2, 3, 3, 4, 4}}},
+		model.AnnotationSound{AnnotationMarkup: markup, Sound: sound, Name: "Speaker"},
+		model.AnnotationMovie{T: "Clip", Movie: model.Movie{Aspect: [2]int{640, 480}, Rotate: 90, Poster: true}, A: &model.MovieActivation{Mode: "Repeat", ShowControls: true, FWScale: [2]int{1, 2}}},
+		model.AnnotationMovie{T: "Disabled", NoPlay: true},
+		model.AnnotationWatermark{FixedPrint: &model.FixedPrint{Matrix: model.Matrix{1, 0, 0, 1, 10, 20}, H: 0.5}},
+		model.AnnotationText{AnnotationMarkup: withPopup},
+	}
+
+	page := &model.PageObject{}
+	for _, subtype := range subtypes {
+		page.Annots = append(page.Annots, &model.AnnotationDict{Subtype: subtype})
+	}
+	var doc model.Document
+	doc.Catalog.Pages.Kids = []model.PageNode{page}
+	var buf bytes.Buffer
+	if err := doc.Write(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	read, _, err := ParsePDFReader(bytes.NewReader(buf.Bytes()), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	annots := read.Catalog.Pages.Flatten()[0].Annots
+	if len(annots) != len(subtypes) {
+		t.Fatalf("expected %d annotations, got %d", len(subtypes), len(annots))
+	}
+	for i, annot := range annots {
+		switch got := annot.Subtype.(type) {
+		case model.AnnotationSound:
+			if got.Sound.R != 8000 || got.Sound.C != 2 || got.Sound.B != 16 || got.Sound.E != "Signed" || !bytes.Equal(got.Sound.Content, sound.Content) {
+				t.Fatalf("unexpected sound %v", got.Sound)
+			}
+		case model.AnnotationText:
+			if got.Popup == nil || got.Popup.Rect != withPopup.Popup.Rect || !got.Popup.Open {
+				t.Fatalf("unexpected popup %v", got.Popup)
+			}
+		default:
+			if !reflect.DeepEqual(got, subtypes[i]) {
+				t.Fatalf("expected %v, got %v", subtypes[i], got)
+			}
+		}
+	}
+}
+
+func Test3DRoundTrip(t *testing.T) {
+	view := model.View3D{
+		XN: "Front", IN: "front", MS: "M", C2W: []model.Fl{1, 0, 0, 0, 1, 0, 0, 0, 1, 0, 0, -10}, CO: 10,
+		P:  &model.Projection3D{Subtype: "P", FOV: 30},
+		BG: &model.Background3D{C: []model.Fl{0, 0, 1}, EA: true},
+	}
+	u3dViews := []model.View3D{
+		{
+			XN: "Camera", MS: "U3D", U3DPath: []string{"Cameras", "Camera1"},
+			RM: model.ObjDict{"Type": model.Name("3DRenderMode"), "Subtype": model.Name("Wireframe")},
+			LS: model.ObjDict{"Type": model.Name("3DLightingScheme"), "Subtype": model.Name("CAD")},
+			SA: model.ObjArray{model.ObjDict{"Type": model.Name("3DCrossSection"), "O": model.ObjArray{model.ObjInt(0), model.ObjInt(0), model.ObjInt(0)}}},
+			NA: model.ObjArray{model.ObjDict{"Type": model.Name("3DNode"), "N": model.ObjStringLiteral("Part1"), "V": model.ObjBool(false)}},
+		},
+		{XN: "Top", MS: "U3D", U3DPath: []string{"Top"}},
+	}
+	artwork := []byte("U3D\x00 binary content")
+	stream := &model.Stream3D{Stream: model.Stream{Content: artwork}, Subtype: "U3D", VA: append([]model.View3D{view}, u3dViews...), DV: model.View3DIndex(0)}
+	asset := &model.FileSpec{UF: "model.prc", EF: &model.EmbeddedFileStream{Stream: model.Stream{Content: []byte("PRC content")}}}
+
+	subtypes := []model.Annotation{
+		model.Annotation3D{DD: stream, DV: &view, DA: &model.Activation3D{A: "PO", D: "PC", TB: model.ObjBool(false)}, DI: model.ObjBool(true), DB: &model.Rectangle{Urx: 100, Ury: 50}},
+		model.Annotation3D{DD: stream, DV: model.View3DName("F")},
+		model.Annotation3D{DD: stream, DV: model.View3DInternalName("front")},
+		model.AnnotationRichMedia{
+			RichMediaSettings: &model.RichMediaSettings{Activation: "PV", Deactivation: "PI"},
+			RichMediaContent: model.RichMediaContent{
+				Assets: model.EmbeddedFileTree{{Name: "model.prc", FileSpec: asset}},
+				Configurations: []model.RichMediaConfiguration{
+					{Subtype: "3D", Name: "Default", Instances: []model.RichMediaInstance{{Subtype: "3D", Asset: asset}}},
+				},
+				Views: []model.View3D{view},
+			},
+		},
+	}
+
+	page := &model.PageObject{}
+	for _, subtype := range subtypes {
+		page.Annots = append(page.Annots, &model.AnnotationDict{Subtype: subtype})
+	}
+	var doc model.Document
+	doc.Catalog.Pages.Kids = []model.PageNode{page}
+	var buf bytes.Buffer
+	if err := doc.Write(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	read, _, err := ParsePDFReader(bytes.NewReader(buf.Bytes()), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	annots := read.Catalog.Pages.Flatten()[0].Annots
+	if len(annots) != len(subtypes) {
+		t.Fatalf("expected %d annotations, got %d", len(subtypes), len(annots))
+	}
+	var streams []*model.Stream3D
+	for i, annot := range annots {
+		switch got := annot.Subtype.(type) {
+		case model.Annotation3D:
+			content, err := got.DD.Decode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(content, artwork) || got.DD.Subtype != "U3D" || !reflect.DeepEqual(got.DD.VA, stream.VA) || got.DD.DV != stream.DV {
+				t.Fatalf("unexpected 3D stream %v", got.DD)
+			}
+			streams = append(streams, got.DD)
+			exp := subtypes[i].(model.Annotation3D)
+			got.DD, exp.DD = nil, nil
+			if !reflect.DeepEqual(got, exp) {
+				t.Fatalf("expected %v, got %v", exp, got)
+			}
+		case model.AnnotationRichMedia:
+			content := got.RichMediaContent
+			if len(content.Assets) != 1 || len(content.Configurations) != 1 || content.Configurations[0].Instances[0].Asset != content.Assets[0].FileSpec {
+				t.Fatalf("unexpected rich media content %v", content)
+			}
+			asset, err := content.Assets[0].FileSpec.EF.Decode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(asset) != "PRC content" || *got.RichMediaSettings != *subtypes[i].(model.AnnotationRichMedia).RichMediaSettings ||
+				!reflect.DeepEqual(content.Views, []model.View3D{view}) {
+				t.Fatalf("unexpected rich media %v", got)
+			}
+		default:
+			t.Fatalf("unexpected annotation %T", got)
+		}
+	}
+	if streams[0] != streams[1] || streams[0] != streams[2] {
+		t.Fatal("shared 3D stream should be read once")
+	}
+}
+
+func TestMeasureRoundTrip(t *testing.T) {
+	inches := []model.NumberFormat{{U: "in", C: 0.5, D: 10}}
+	rl := model.MeasureRL{
+		R: "1 in = 1 mi", X: []model.NumberFormat{{U: "mi", C: 0.25, F: "D", RT: " ", RD: ","}},
+		D: inches, A: inches, O: &[2]model.Fl{10, 20}, CYX: model.ObjFloat(2),
+	}
+	geo := model.MeasureGEO{
+		Bounds: []model.Fl{0, 0, 0, 1, 1, 1, 1, 0},
+		GCS:    model.CoordinateSystem{EPSG: 4326},
+		DCS:    &model.CoordinateSystem{Projected: true, WKT: `PROJCS["WGS 84 / UTM zone 31N"]`},
+		PDU:    [3]model.Name{"KM", "SQKM", "DEG"},
+		GPTS:   []model.Fl{45, 5, 46, 5, 46, 6, 45, 6},
+		LPTS:   []model.Fl{0, 0, 0, 1, 1, 1, 1, 0},
+	}
+	page := &model.PageObject{
+		VP: model.Viewports{
+			{BBox: model.Rectangle{Urx: 300, Ury: 400}, Name: "Map", Measure: geo},
+			{BBox: model.Rectangle{Llx: 300, Urx: 600, Ury: 400}},
+		},
+		Annots: []*model.AnnotationDict{
+			{Subtype: model.AnnotationLine{L: [4]model.Fl{0, 0, 100, 0}, IC: []model.Fl{}, Measure: rl}},
+			{Subtype: model.AnnotationPolygon{Vertices: []model.Fl{0, 0, 10, 10, 20, 0}, IC: []model.Fl{}, Measure: rl}},
+		},
+	}
+	var doc model.Document
+	doc.Catalog.Pages.Kids = []model.PageNode{page}
+	var buf bytes.Buffer
+	if err := doc.Write(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	read, _, err := ParsePDFReader(bytes.NewReader(buf.Bytes()), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := read.Catalog.Pages.Flatten()[0]
+	if !reflect.DeepEqual(got.VP, page.VP) {
+		t.Fatalf("expected %v, got %v", page.VP, got.VP)
+	}
+	for i, annot := range got.Annots {
+		if !reflect.DeepEqual(annot.Subtype, page.Annots[i].Subtype) {
+			t.Fatalf("expected %v, got %v", page.Annots[i].Subtype, annot.Subtype)
+		}
+	}
+}
+
+func TestTransparencyGroupRoundTrip(t *testing.T) {
+	mask := &model.XObjectTransparencyGroup{
+		XObjectForm: model.XObjectForm{BBox: model.Rectangle{Urx: 100, Ury: 100}, ContentStream: model.ContentStream{Stream: model.Stream{Content: []byte("0.5 g 0 0 50 50 re f")}}},
+		Group:       model.TransparencyGroup{CS: model.ColorSpaceGray, I: true},
+	}
+	state := &model.GraphicState{
+		BM:    []model.Name{"Multiply"},
+		SMask: model.SoftMaskDict{S: "Luminosity", G: mask},
+	}
+	page := &model.PageObject{
+		MediaBox:  &model.Rectangle{Urx: 100, Ury: 100},
+		Group:     &model.TransparencyGroup{CS: model.ColorSpaceRGB, I: true, K: true},
+		Resources: &model.ResourcesDict{ExtGState: map[model.Name]*model.GraphicState{"GS0": state}},
+	}
+	var doc model.Document
+	doc.Catalog.Pages.Kids = []model.PageNode{page}
+	var buf bytes.Buffer
+	if err := doc.Write(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	read, _, err := ParsePDFReader(bytes.NewReader(buf.Bytes()), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := read.Catalog.Pages.Flatten()[0]
+	if !reflect.DeepEqual(got.Group, page.Group) {
+		t.Fatalf("expected %v, got %v", page.Group, got.Group)
+	}
+	gotState := got.Resources.ExtGState["GS0"]
+	if !reflect.DeepEqual(gotState.BM, state.BM) {
+		t.Fatalf("expected %v, got %v", state.BM, gotState.BM)
+	}
+	if g := gotState.SMask.G; g == nil || !reflect.DeepEqual(g.Group, mask.Group) {
+		t.Fatalf("expected %v, got %v", mask.Group, g)
+	}
+}
+
+func TestThumbnailRoundTrip(t *testing.T) {
+	thumb := &model.XObjectImage{
+		Image: model.Image{
+			Stream:           model.NewCompressedStream(bytes.Repeat([]byte{0xff, 0, 0}, 8*6)),
+			Width:            8,
+			Height:           6,
+			BitsPerComponent: 8,
+		},
+		ColorSpace: model.ColorSpaceRGB,
+	}
+	page := &model.PageObject{MediaBox: &model.Rectangle{Urx: 80, Ury: 60}, Thumb: thumb}
+	var doc model.Document
+	doc.Catalog.Pages.Kids = []model.PageNode{page}
+	var buf bytes.Buffer
+	if err := doc.Write(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	read, _, err := ParsePDFReader(bytes.NewReader(buf.Bytes()), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := read.Catalog.Pages.Flatten()[0]
+	if got.Thumb == nil || got.Thumb.Width != 8 || got.Thumb.Height != 6 || got.Thumb.ColorSpace != model.ColorSpaceRGB {
+		t.Fatalf("unexpected thumbnail %v", got.Thumb)
+	}
+	if len(got.Custom) != 0 {
+		t.Fatalf("unexpected custom entries %v", got.Custom)
+	}
+	if n := read.RemoveThumbnails(); n != 1 || got.Thumb != nil {
+		t.Fatal("thumbnail not removed")
+	}
+}
+
+func TestDegeneratePageTrees(t *testing.T) {
+	for _, test := range []struct {
+		objects []string
+		count   int
+	}{
+		{ // single-kid chain, with missing Type entries and wrong counts
+			[]string{
+				"<</Type/Catalog/Pages 2 0 R>>",
+				"<</Type/Pages/Kids [3 0 R]/Count 7/MediaBox [0 0 50 50]>>",
+				"<</Kids [4 0 R]/Count 0>>",
+				"<</Type/Pages/Kids [5 0 R 6 0 R]>>",
+				"<</Type/Page/Parent 4 0 R>>",
+				"<</Parent 4 0 R/MediaBox [0 0 100 100]>>",
+			},
+			2,
+		},
+		{ // page referenced by the catalog
+			[]string{
+				"<</Type/Catalog/Pages 2 0 R>>",
+				"<</Type/Page/MediaBox [0 0 100 100]>>",
+			},
+			1,
+		},
+		{ // cyclic tree
+			[]string{
+				"<</Type/Catalog/Pages 2 0 R>>",
+				"<</Type/Pages/Kids [3 0 R 2 0 R]/MediaBox [0 0 100 100]>>",
+				"<</Type/Pages/Kids [4 0 R 2 0 R 4 0 R]>>",
+				"<</Type/Page>>",
+			},
+			1,
+		},
+	} {
+		doc, _, err := ParsePDFReader(bytes.NewReader(buildPDF(test.objects)), Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages := doc.Catalog.Pages.FlattenInherit()
+		if len(pages) != test.count {
+			t.Fatalf("expected %d pages, got %d", test.count, len(pages))
+		}
+		for _, page := range pages {
+			if page.MediaBox == nil {
+				t.Fatal("missing inherited media box")
+			}
+		}
+		for _, kid := range doc.Catalog.Pages.Kids {
+			if _, isTree := kid.(*model.PageTree); isTree {
+				t.Fatal("tree should be flattened")
+			}
+		}
+
+		var out bytes.Buffer
+		if err = doc.WriteWithOptions(&out, nil, model.WriteOptions{BalancePageTree: 2}); err != nil {
+			t.Fatal(err)
+		}
+		doc2, _, err := ParsePDFReader(bytes.NewReader(out.Bytes()), Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if doc2.Catalog.Pages.Count() != test.count {
+			t.Fatalf("expected %d pages after writing", test.count)
+		}
+	}
+}
+
+// manyPagesPDF returns a document with `n` pages, sharing
+// some resources, and with links between the pages
+func manyPagesPDF(n int) []byte {
+	font := &model.FontDict{Subtype: model.FontType1{BaseFont: "Helvetica"}}
+	state := &model.GraphicState{LW: 2}
+	logo := &model.XObjectImage{
+		Image:      model.Image{Stream: model.Stream{Content: make([]byte, 3*16*16)}, BitsPerComponent: 8, Width: 16, Height: 16},
+		ColorSpace: model.ColorSpaceRGB,
+	}
+	var doc model.Document
+	pages := make([]*model.PageObject, n)
+	for i := range pages {
+		img := &model.XObjectImage{
+			Image:      model.Image{Stream: model.Stream{Content: bytes.Repeat([]byte{byte(i)}, 64)}, BitsPerComponent: 8, Width: 8, Height: 8},
+			ColorSpace: model.ColorSpaceGray,
+		}
+		pages[i] = &model.PageObject{
+			MediaBox: &model.Rectangle{Urx: 595, Ury: 842},
+			Resources: &model.ResourcesDict{
+				Font:      map[model.Name]*model.FontDict{"F1": font},
+				ExtGState: map[model.Name]*model.GraphicState{"GS1": state},
+				XObject:   map[model.Name]model.XObject{"Logo": logo, "Im1": img},
+			},
+			Contents: []model.ContentStream{{Stream: model.Stream{Content: []byte(fmt.Sprintf("BT /F1 12 Tf (Page %d) Tj ET /Logo Do /Im1 Do", i+1))}}},
+		}
+		doc.Catalog.Pages.Kids = append(doc.Catalog.Pages.Kids, pages[i])
+	}
+	for i, page := range pages {
+		next := pages[(i+1)%n]
+		page.Annots = append(page.Annots, &model.AnnotationDict{
+			BaseAnnotation: model.BaseAnnotation{Rect: model.Rectangle{Urx: 100, Ury: 20}},
+			Subtype:        model.AnnotationLink{Dest: model.DestinationExplicitIntern{Page: next, Location: model.DestinationLocationFit("Fit")}},
+		})
+	}
+	var buf bytes.Buffer
+	if err := doc.Write(&buf, nil); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
+
+func TestParseWorkers(t *testing.T) {
+	content := manyPagesPDF(200)
+
+	sequential, _, err := ParsePDFReader(bytes.NewReader(content), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	concurrent, _, err := ParsePDFReader(bytes.NewReader(content), Options{Workers: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(sequential, concurrent) {
+		t.Fatal("concurrent parsing yields a different document")
+	}
+
+	pages := concurrent.Catalog.Pages.Flatten()
+	for i, page := range pages {
+		// shared resources must not be duplicated
+		if page.Resources.Font["F1"] != pages[0].Resources.Font["F1"] ||
+			page.Resources.XObject["Logo"] != pages[0].Resources.XObject["Logo"] {
+			t.Fatalf("page %d: shared resources are duplicated", i+1)
+		}
+		link := page.Annots[0].Subtype.(model.AnnotationLink)
+		if dest := link.Dest.(model.DestinationExplicitIntern); dest.Page != pages[(i+1)%len(pages)] {
+			t.Fatalf("page %d: invalid link destination", i+1)
+		}
+	}
+}
+
+func BenchmarkParseWorkers(b *testing.B) {
+	content := manyPagesPDF(1000)
+	ctx, err := file.Read(bytes.NewReader(content), nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers-%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				r := newResolver()
+				r.file = ctx
+				r.setWorkers(workers)
+				if _, _, err := r.processPDF(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
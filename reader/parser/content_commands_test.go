@@ -7,6 +7,7 @@ import (
 	"image/jpeg"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
@@ -329,7 +330,7 @@ func TestForgePDFInlineData(t *testing.T) {
 				Contents: []model.ContentStream{{Stream: model.Stream{Content: contentStream}}},
 			})
 	}
-	f, err := os.Create("test/inline_images.pdf")
+	f, err := os.Create(filepath.Join(t.TempDir(), "inline_images.pdf"))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -354,3 +355,78 @@ func TestHexText(t *testing.T) {
 	}
 	fmt.Println(out)
 }
+
+func TestInlineImageAbbreviations(t *testing.T) {
+	for _, test := range []struct {
+		content string
+		exp     contentstream.OpBeginImage
+	}{
+		{
+			// binary data containing EI
+			"BI /W 2 /H 2 /CS /G /BPC 8 ID \x00 EI EI Q",
+			contentstream.OpBeginImage{
+				Image:      model.Image{Width: 2, Height: 2, BitsPerComponent: 8, Stream: model.Stream{Content: []byte("\x00 EI")}},
+				ColorSpace: contentstream.ImageColorSpaceName{ColorSpaceName: model.ColorSpaceGray},
+			},
+		},
+		{
+			"BI /W 2 /H 1 /BPC 4 /CS [/I /RGB 1 <ff000000ff00>] /F /AHx ID 1f> EI Q",
+			contentstream.OpBeginImage{
+				Image: model.Image{
+					Width: 2, Height: 1, BitsPerComponent: 4,
+					Stream: model.Stream{Content: []byte("1f>"), Filter: model.Filters{{Name: model.ASCIIHex}}},
+				},
+				ColorSpace: contentstream.ImageColorSpaceIndexed{Base: model.ColorSpaceRGB, Hival: 1, Lookup: model.ColorTableBytes{0xff, 0, 0, 0, 0xff, 0}},
+			},
+		},
+		{
+			// image mask, without color space
+			"BI /W 16 /H 1 /IM true ID \xf0\x0f\nEI Q",
+			contentstream.OpBeginImage{
+				Image: model.Image{Width: 16, Height: 1, ImageMask: true, Stream: model.Stream{Content: []byte("\xf0\x0f")}},
+			},
+		},
+		{
+			// unsupported filter, relying on EI
+			"BI /W 4 /H 4 /CS /RGB /BPC 8 /F /JPXDecode ID xxEIxx EI Q",
+			contentstream.OpBeginImage{
+				Image: model.Image{
+					Width: 4, Height: 4, BitsPerComponent: 8,
+					Stream: model.Stream{Content: []byte("xxEIxx"), Filter: model.Filters{{Name: model.JPX}}},
+				},
+				ColorSpace: contentstream.ImageColorSpaceName{ColorSpaceName: model.ColorSpaceRGB},
+			},
+		},
+		{
+			// Length entry
+			"BI /W 4 /H 4 /CS /CMYK /BPC 8 /F /DCT /L 5 ID EI EI EI Q",
+			contentstream.OpBeginImage{
+				Image: model.Image{
+					Width: 4, Height: 4, BitsPerComponent: 8,
+					Stream: model.Stream{Content: []byte("EI EI"), Filter: model.Filters{{Name: model.DCT}}},
+				},
+				ColorSpace: contentstream.ImageColorSpaceName{ColorSpaceName: model.ColorSpaceCMYK},
+			},
+		},
+	} {
+		ops, err := ParseContent([]byte(test.content), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ops) != 2 {
+			t.Fatalf("expected 2 operations, got %v", ops)
+		}
+		if !reflect.DeepEqual(ops[0], test.exp) {
+			t.Fatalf("expected %v, got %v", test.exp, ops[0])
+		}
+
+		// check the serialization
+		ops2, err := ParseContent(contentstream.WriteOperations(ops...), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(ops, ops2) {
+			t.Fatalf("expected %v, got %v", ops, ops2)
+		}
+	}
+}
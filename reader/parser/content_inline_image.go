@@ -12,10 +12,37 @@ import (
 
 var errBIExpressionCorrupt = errors.New("corrupt BI (inline image) expression")
 
+// abbreviations used in inline images
+// See Table 93 – Additional abbreviations in an inline image object
+var (
+	inlineColorSpaces = map[Name]model.ColorSpaceName{
+		"G":    model.ColorSpaceGray,
+		"RGB":  model.ColorSpaceRGB,
+		"CMYK": model.ColorSpaceCMYK,
+	}
+	inlineFilters = map[model.Name]model.Name{
+		"AHx": model.ASCIIHex,
+		"A85": model.ASCII85,
+		"LZW": model.LZW,
+		"Fl":  model.Flate,
+		"RL":  model.RunLength,
+		"CCF": model.CCITTFax,
+		"DCT": model.DCT,
+	}
+)
+
+func expandColorSpaceName(name Name) model.ColorSpaceName {
+	if cs, ok := inlineColorSpaces[name]; ok {
+		return cs
+	}
+	return model.ColorSpaceName(name)
+}
+
 func (pr *Parser) parseInlineImage(res model.ResourcesColorSpace) (contentstream.OpBeginImage, error) {
 	var (
 		out                   contentstream.OpBeginImage
 		filters, decodeParams Object // parsing delayed
+		length                = -1   // optional Length entry
 	)
 	if err := assertLength(pr.opsStack, 0); err != nil {
 		return out, err
@@ -28,7 +55,7 @@ func (pr *Parser) parseInlineImage(res model.ResourcesColorSpace) (contentstream
 		}
 		if obj == Command("ID") {
 			// done with the characteristics;
-			err = pr.parseImageData(&out, filters, decodeParams, res)
+			err = pr.parseImageData(&out, filters, decodeParams, length, res)
 			// EI is consumed in parseImageData
 			return out, err
 		} else {
@@ -41,6 +68,14 @@ func (pr *Parser) parseInlineImage(res model.ResourcesColorSpace) (contentstream
 			if err != nil {
 				return out, errBIExpressionCorrupt
 			}
+			if name == "Length" || name == "L" {
+				l, ok := value.(Integer)
+				if !ok || l < 0 {
+					return out, errBIExpressionCorrupt
+				}
+				length = int(l)
+				continue
+			}
 			o1, o2, err := parseOneImgField(name, value, &out)
 			if err != nil {
 				return out, err
@@ -104,9 +139,11 @@ func parseOneImgField(name Name, value Object, img *contentstream.OpBeginImage)
 	case "ColorSpace", "CS":
 		switch value := value.(type) {
 		case Name:
-			img.ColorSpace = contentstream.ImageColorSpaceName{ColorSpaceName: model.ColorSpaceName(value)}
+			img.ColorSpace = contentstream.ImageColorSpaceName{ColorSpaceName: expandColorSpaceName(value)}
 		case Array:
 			img.ColorSpace, err = processIndexedCS(value)
+		default:
+			err = errBIExpressionCorrupt
 		}
 	case "Filter", "F": // parsing is delayed
 		return value, nil, nil
@@ -138,14 +175,14 @@ func processPoints(arr Array) ([][2]Fl, error) {
 
 func processIndexedCS(arr Array) (contentstream.ImageColorSpaceIndexed, error) {
 	var out contentstream.ImageColorSpaceIndexed
-	if len(arr) != 4 {
+	if len(arr) != 4 || (arr[0] != Name("Indexed") && arr[0] != Name("I")) {
 		return out, errBIExpressionCorrupt
 	}
 	b, ok := arr[1].(Name)
 	if !ok {
 		return out, errBIExpressionCorrupt
 	}
-	out.Base = model.ColorSpaceName(b)
+	out.Base = expandColorSpaceName(b)
 	h, ok := arr[2].(Integer)
 	if !ok {
 		return out, errBIExpressionCorrupt
@@ -259,45 +296,57 @@ func processOneDecodeParms(parms Object) map[string]int {
 }
 
 // read the inline data, store its content in img, and skip EI command
-func (pr *Parser) parseImageData(img *contentstream.OpBeginImage, fils, decodeParams Object, res model.ResourcesColorSpace) error {
+// `length` is the optional Length entry, or -1
+func (pr *Parser) parseImageData(img *contentstream.OpBeginImage, fils, decodeParams Object, length int, res model.ResourcesColorSpace) error {
 	var err error
 	// first we check update the filter list
 	img.Image.Filter, err = ParseDirectFilters(fils, decodeParams)
 	if err != nil {
 		return err
 	}
+	for i, fi := range img.Image.Filter {
+		if name, ok := inlineFilters[fi.Name]; ok {
+			img.Image.Filter[i].Name = name
+		}
+	}
+
+	pr.tokens.SkipBytes(1) // the white space after ID
 
-	// to read the binary data, there are 2 cases
+	// to read the binary data, there are 3 cases
+	//  - if the Length entry is present, we use it
 	// 	- if the data is not filtered, we use the image metadata to deduce the length
-	//	- if the data is filtered, we have to rely on the filter format End Of Data marker
+	//	- if the data is filtered, we have to rely on the filter format End Of Data marker,
+	//	  or, for unsupported filters, on the EI keyword
 
-	if len(img.Image.Filter) == 0 {
-		bits, comps, err := img.Metrics(res)
+	var dataLength int
+	if length >= 0 {
+		dataLength = length
+	} else if len(img.Image.Filter) == 0 {
+		comps, bits, err := img.Metrics(res)
 		if err != nil {
 			return err
 		}
-		n := img.Image.Height * ((img.Image.Width*comps*bits + 7) / 8)
-
-		img.Image.Content = pr.tokens.SkipBytes(n + 1) // with space after ID
+		dataLength = img.Image.Height * ((img.Image.Width*comps*bits + 7) / 8)
 	} else {
-		pr.tokens.SkipBytes(1) // with space after ID
 		input := pr.tokens.Bytes()
 
 		// we only apply the first filter
 		fi := img.Image.Stream.Filter[0]
 		skipper, err := filters.SkipperFromFilter(string(fi.Name), fi.DecodeParms)
-		if err != nil {
-			return err
-		}
-		encodedLength, err := skipper.Skip(bytes.NewReader(input))
-		if err != nil {
-			return fmt.Errorf("can't read compressed inline image data: %s", err)
+		if err == nil {
+			dataLength, err = skipper.Skip(bytes.NewReader(input))
+			if err != nil {
+				return fmt.Errorf("can't read compressed inline image data: %s", err)
+			}
+		} else {
+			dataLength = findEndImage(input)
+			if dataLength == -1 {
+				return errors.New("missing end of inline image")
+			}
 		}
-		// we return the compressed version ...
-		img.Image.Content = input[0:encodedLength]
-		// ... and move the tokenizer
-		pr.tokens.SkipBytes(encodedLength)
 	}
+	// we return the compressed version, and move the tokenizer
+	img.Image.Content = pr.tokens.SkipBytes(dataLength)
 	o, err := pr.ParseObject() // EI
 	if err != nil {
 		return err
@@ -307,3 +356,29 @@ func (pr *Parser) parseImageData(img *contentstream.OpBeginImage, fils, decodePa
 	}
 	return nil
 }
+
+// findEndImage returns the length of the data before the
+// first EI keyword (surrounded by white spaces), or -1
+func findEndImage(input []byte) int {
+	for start := 0; ; {
+		index := bytes.Index(input[start:], []byte("EI"))
+		if index == -1 {
+			return -1
+		}
+		index += start
+		end := index + 2
+		if index > 0 && isWhitespace(input[index-1]) && (end == len(input) || isWhitespace(input[end])) {
+			return index - 1 // exclude the white space
+		}
+		start = end
+	}
+}
+
+func isWhitespace(c byte) bool {
+	switch c {
+	case 0, ' ', '\t', '\n', '\f', '\r':
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,73 @@
+package filters
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+
+	"github.com/hhrutter/lzw"
+)
+
+// NewEncoder wraps `dst` to encode the data according to the given filter `name`,
+// or returns an error if the filter is not supported.
+// Only Flate, LZW and RunLength are supported, and the returned
+// writer must be closed to flush the encoded content.
+// The default parameters are used, so that no DecodeParms is
+// needed to decode the content.
+func NewEncoder(name string, dst io.Writer) (io.WriteCloser, error) {
+	switch name {
+	case Flate:
+		return zlib.NewWriter(dst), nil
+	case LZW:
+		return lzw.NewWriter(dst, true), nil
+	case RunLength:
+		return &runLengthEncoder{dst: dst}, nil
+	default:
+		return nil, fmt.Errorf("unsupported filter for encoding %s", name)
+	}
+}
+
+// runLengthEncoder buffers the input and encodes it on Close.
+type runLengthEncoder struct {
+	dst io.Writer
+	buf bytes.Buffer
+}
+
+func (r *runLengthEncoder) Write(p []byte) (int, error) { return r.buf.Write(p) }
+
+func (r *runLengthEncoder) Close() error {
+	_, err := r.dst.Write(encodeRunLength(r.buf.Bytes()))
+	return err
+}
+
+// encodeRunLength returns the encoded data, terminated by the EOD marker.
+// Repeated bytes are encoded as runs, other as literal sequences,
+// both with a maximum length of 128 bytes.
+func encodeRunLength(data []byte) []byte {
+	var out bytes.Buffer
+	for i := 0; i < len(data); {
+		run := 1
+		for i+run < len(data) && run < 128 && data[i+run] == data[i] {
+			run++
+		}
+		if run >= 2 {
+			out.WriteByte(byte(257 - run))
+			out.WriteByte(data[i])
+			i += run
+			continue
+		}
+
+		// literal sequence, until the next repeated bytes
+		start := i
+		for i++; i < len(data) && i-start < 128; i++ {
+			if i+1 < len(data) && data[i] == data[i+1] {
+				break
+			}
+		}
+		out.WriteByte(byte(i - start - 1))
+		out.Write(data[start:i])
+	}
+	out.WriteByte(eodRunLength)
+	return out.Bytes()
+}
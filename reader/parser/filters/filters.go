@@ -2,8 +2,7 @@
 // data encoded with PDF filters, such as inline data images.
 // Regular stream objects provide a Length information, but inline data images don't,
 // which requires to detect the End of Data marker, which depends on the filter.
-// This package only parse encoded content. See pdfcpu/filter for an alternative
-// to also encode data.
+// Encoding is also supported for the Flate, LZW and RunLength filters (see `NewEncoder`).
 package filters
 
 import (
@@ -26,8 +25,15 @@ const (
 	CCITTFax  = "CCITTFaxDecode"
 )
 
+// UnsupportedFilterErr is returned when a filter is not supported.
+type UnsupportedFilterErr string
+
+func (u UnsupportedFilterErr) Error() string {
+	return fmt.Sprintf("unsupported filter %s", string(u))
+}
+
 // NewFilter wraps the given `src` to decode the according to the given filter `name`,
-// or returns an error it the filter is not supported.
+// or returns an error it the filter is not supported (see UnsupportedFilterErr).
 func NewFilter(name string, params map[string]int, src io.Reader) (io.Reader, error) {
 	switch name {
 	case Flate:
@@ -43,7 +49,7 @@ func NewFilter(name string, params map[string]int, src io.Reader) (io.Reader, er
 	case RunLength:
 		return runLengthDecoder(src)
 	default:
-		return nil, fmt.Errorf("unsupported filter %s", name)
+		return nil, UnsupportedFilterErr(name)
 	}
 }
 
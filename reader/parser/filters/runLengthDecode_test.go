@@ -2,6 +2,7 @@ package filters
 
 import (
 	"bytes"
+	"io"
 	"math/rand"
 	"testing"
 )
@@ -19,3 +20,40 @@ func TestInvalidRunLength(t *testing.T) {
 		}
 	}
 }
+
+func TestRunLengthEncode(t *testing.T) {
+	inputs := [][]byte{
+		nil,
+		[]byte("a"),
+		[]byte("aaaaaaaaaabcdefgggh"),
+		bytes.Repeat([]byte{1}, 300),
+		bytes.Repeat([]byte{1, 2, 3}, 100),
+	}
+	random := make([]byte, 1000)
+	_, _ = rand.Read(random)
+	inputs = append(inputs, random)
+
+	for _, input := range inputs {
+		var encoded bytes.Buffer
+		w, err := NewEncoder(RunLength, &encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(input)
+		if err = w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		n, err := SkipperRunLength{}.Skip(bytes.NewReader(encoded.Bytes()))
+		if err != nil || n != encoded.Len() {
+			t.Fatalf("invalid EOD: %d %s", n, err)
+		}
+		r, err := NewFilter(RunLength, nil, &encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, _ := io.ReadAll(r)
+		if !bytes.Equal(decoded, input) {
+			t.Fatalf("expected %v, got %v", input, decoded)
+		}
+	}
+}
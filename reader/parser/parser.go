@@ -17,6 +17,10 @@ var (
 	errDictionaryDuplicateKey  = errors.New("parse: duplicate key")
 	errDictionaryNotTerminated = errors.New("parse: unterminated dictionary")
 	errBufNotAvailable         = errors.New("parse: no buffer available")
+
+	// ErrTooDeep is returned when the nesting of arrays and dictionaries
+	// exceeds Parser.MaxDepth
+	ErrTooDeep = errors.New("parse: maximum nesting depth exceeded")
 )
 
 type (
@@ -46,7 +50,13 @@ type Parser struct {
 	// but allow Commands
 	ContentStreamMode bool
 
+	// MaxDepth, if positive, is the maximum nesting
+	// of arrays and dictionaries, protecting against stack exhaustion
+	// on malicious inputs.
+	MaxDepth int
+
 	opsStack []Object // only used in content stream
+	depth    int      // current nesting
 }
 
 // NewParser uses a byte slice as input.
@@ -86,21 +96,30 @@ func (p *Parser) ParseObject() (Object, error) {
 	case tkn.StringHex:
 		value = HexLiteral(tk.Value)
 	case tkn.StartArray:
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
 		arr, err := p.parseArray()
+		p.depth--
 		if err != nil {
 			return nil, err
 		}
 		value = arr
 	case tkn.StartDic:
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
 		// Hack for #252: we start by parsing according to the SPEC
 		// which will be almost always successful
-		save := p.tokens.CurrentPosition()
+		save, depth := p.tokens.CurrentPosition(), p.depth
 		dict, err := p.parseDict(false)
-		if err != nil {
+		if err != nil && err != ErrTooDeep {
 			// try relaxed
 			p.tokens.SetPosition(save)
+			p.depth = depth
 			dict, err = p.parseDict(true)
 		}
+		p.depth--
 		if err != nil {
 			return nil, err
 		}
@@ -125,6 +144,16 @@ func (p *Parser) ParseObject() (Object, error) {
 	return value, err
 }
 
+// enter increments the nesting depth, checking MaxDepth
+func (p *Parser) enter() error {
+	p.depth++
+	if p.MaxDepth > 0 && p.depth > p.MaxDepth {
+		p.depth--
+		return ErrTooDeep
+	}
+	return nil
+}
+
 func (p *Parser) parseArray() (Array, error) {
 	a := Array{}
 	tk, err := p.tokens.PeekToken()
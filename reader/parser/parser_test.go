@@ -280,3 +280,21 @@ func BenchmarkParseTokenizer(b *testing.B) {
 		}
 	}
 }
+
+func TestMaxDepth(t *testing.T) {
+	for _, input := range []string{"[[[1]]]", "<</A<</B<</C 1>>>>>>", "[<</A[1]>>]"} {
+		p := NewParser([]byte(input))
+		p.MaxDepth = 2
+		if _, err := p.ParseObject(); err != ErrTooDeep {
+			t.Fatalf("expected error for %s, got %v", input, err)
+		}
+
+		p = NewParser([]byte(input + " " + input))
+		p.MaxDepth = 3
+		for i := 0; i < 2; i++ { // the depth is restored after each object
+			if _, err := p.ParseObject(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
@@ -30,8 +30,11 @@ func (r resolver) resolveShading(sh model.Object) (map[model.ObjName]*model.Shad
 
 func (r resolver) resolveOneShading(shadings model.Object) (*model.ShadingDict, error) {
 	shRef, isRef := shadings.(model.ObjIndirectRef)
-	if sh := r.shadings[shRef]; isRef && sh != nil {
-		return sh, nil
+	r.lock()
+	cached := r.shadings[shRef]
+	r.unlock()
+	if isRef && cached != nil {
+		return cached, nil
 	}
 	shadings = r.resolve(shadings)
 	var (
@@ -92,6 +95,11 @@ func (r resolver) resolveOneShading(shadings model.Object) (*model.ShadingDict,
 		return nil, err
 	}
 	if isRef {
+		r.lock()
+		defer r.unlock()
+		if existing := r.shadings[shRef]; existing != nil { // resolved concurrently
+			return existing, nil
+		}
 		r.shadings[shRef] = &out
 	}
 	return &out, nil
@@ -242,12 +250,21 @@ func (r resolver) resolveICCBased(ar model.ObjArray) (*model.ColorSpaceICCBased,
 	if len(ar) != 2 {
 		return nil, fmt.Errorf("expected 2-elements array in ICCBase Color, got %v", ar)
 	}
-	ref, isRef := ar[1].(model.ObjIndirectRef)
-	if icc := r.iccs[ref]; isRef && icc != nil {
-		return icc, nil
-	}
-	obj := r.resolve(ar[1]) // ar[1] should be indirect, but we accept direct object
-	common, ok, err := r.resolveStream(ar[1])
+	return r.resolveICCStream(ar[1])
+}
+
+// resolveICCStream resolves an ICC profile stream, also
+// used by output intents
+func (r resolver) resolveICCStream(iccStream model.Object) (*model.ColorSpaceICCBased, error) {
+	ref, isRef := iccStream.(model.ObjIndirectRef)
+	r.lock()
+	cached := r.iccs[ref]
+	r.unlock()
+	if isRef && cached != nil {
+		return cached, nil
+	}
+	obj := r.resolve(iccStream) // iccStream should be indirect, but we accept direct object
+	common, ok, err := r.resolveStream(iccStream)
 	if err != nil {
 		return nil, err
 	}
@@ -255,7 +272,7 @@ func (r resolver) resolveICCBased(ar model.ObjArray) (*model.ColorSpaceICCBased,
 		return nil, errors.New("missing ICCBased stream")
 	}
 	out := model.ColorSpaceICCBased{Stream: common}
-	stream, _ := obj.(model.ObjStream) // no error, ar[1] has type Stream
+	stream, _ := obj.(model.ObjStream) // no error, iccStream has type Stream
 
 	out.N, _ = r.resolveInt(stream.Args["N"])
 
@@ -269,6 +286,11 @@ func (r resolver) resolveICCBased(ar model.ObjArray) (*model.ColorSpaceICCBased,
 		return nil, err
 	}
 	if isRef {
+		r.lock()
+		defer r.unlock()
+		if existing := r.iccs[ref]; existing != nil { // resolved concurrently
+			return existing, nil
+		}
 		r.iccs[ref] = &out
 	}
 	return &out, nil
@@ -303,7 +325,9 @@ func (r resolver) resolveIndexed(ar model.ObjArray) (model.ColorSpaceIndexed, er
 		}
 		out.Lookup = (*model.ColorTableStream)(&cs)
 		if isRef {
+			r.lock()
 			r.colorTableStreams[lookupRef] = (*model.ColorTableStream)(&cs)
+			r.unlock()
 		}
 	}
 	return out, nil
@@ -604,8 +628,11 @@ func (r resolver) resolvePattern(pattern model.Object) (map[model.ObjName]model.
 
 func (r resolver) resolveOnePattern(pat model.Object) (model.Pattern, error) {
 	patRef, isRef := pat.(model.ObjIndirectRef)
-	if pattern := r.patterns[patRef]; isRef && pattern != nil {
-		return pattern, nil
+	r.lock()
+	cached := r.patterns[patRef]
+	r.unlock()
+	if isRef && cached != nil {
+		return cached, nil
 	}
 	pat = r.resolve(pat)
 	var (
@@ -639,6 +666,11 @@ func (r resolver) resolveOnePattern(pat model.Object) (model.Pattern, error) {
 		return nil, err
 	}
 	if isRef {
+		r.lock()
+		defer r.unlock()
+		if existing := r.patterns[patRef]; existing != nil { // resolved concurrently
+			return existing, nil
+		}
 		r.patterns[patRef] = out
 	}
 	return out, nil
@@ -4,14 +4,16 @@
 package reader
 
 import (
+	"context"
 	"fmt"
 	"io"
-	"log"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/benoitkugler/pdf/model"
 	"github.com/benoitkugler/pdf/reader/file"
+	"golang.org/x/text/encoding"
 	"golang.org/x/text/encoding/unicode"
 )
 
@@ -46,12 +48,38 @@ type resolver struct {
 	colorTableStreams map[model.ObjIndirectRef]*model.ColorTableStream
 	structure         map[model.ObjIndirectRef]*model.StructureElement
 	fontFiles         map[model.ObjIndirectRef]*model.FontFile
+	ocgs              map[model.ObjIndirectRef]*model.OptionalContentGroup
+	ocmds             map[model.ObjIndirectRef]*model.OptionalContentMembership
+	halftones         map[model.ObjIndirectRef]*model.HalftoneDict
+	signatures        map[model.ObjIndirectRef]*model.SignatureDict
+	sounds            map[model.ObjIndirectRef]*model.SoundStream
+	streams3D         map[model.ObjIndirectRef]*model.Stream3D
 
 	customResolve CustomObjectResolver // optional, default is nil
+	textFallback  encoding.Encoding    // optional, default is nil
+	logger        model.Logger         // never nil
+
+	// mu protects the caches when the pages are resolved concurrently;
+	// it is nil otherwise (see Options.Workers)
+	mu       *sync.Mutex
+	workers  int
+	pageJobs *[]pageJob // non nil when walking the page tree, with several workers
+
+	// formsOnly skips the appearance streams, icons and font files,
+	// when only the form fields are needed (see ParseAcroForm)
+	formsOnly bool
+
+	cancel        context.Context // optional, see ParsePDFReaderCtx
+	maxDepth      int             // see file.Limits.MaxDepth
+	maxStreamSize int64           // see file.Limits.MaxStreamSize
+	// depth is the nesting of the page tree or the form field being resolved.
+	// It is incremented on the copy of the resolver used by the recursive calls.
+	depth int
 }
 
 func newResolver() resolver {
 	return resolver{
+		logger:            model.DefaultLogger(),
 		formFields:        make(map[model.ObjIndirectRef]*model.FormFieldDict),
 		appearanceDicts:   make(map[model.ObjIndirectRef]*model.AppearanceDict),
 		resources:         make(map[model.ObjIndirectRef]model.ResourcesDict),
@@ -73,6 +101,35 @@ func newResolver() resolver {
 		colorTableStreams: make(map[model.ObjIndirectRef]*model.ColorTableStream),
 		structure:         make(map[model.ObjIndirectRef]*model.StructureElement),
 		fontFiles:         make(map[model.ObjIndirectRef]*model.FontFile),
+		ocgs:              make(map[model.ObjIndirectRef]*model.OptionalContentGroup),
+		ocmds:             make(map[model.ObjIndirectRef]*model.OptionalContentMembership),
+		halftones:         make(map[model.ObjIndirectRef]*model.HalftoneDict),
+		signatures:        make(map[model.ObjIndirectRef]*model.SignatureDict),
+		sounds:            make(map[model.ObjIndirectRef]*model.SoundStream),
+		streams3D:         make(map[model.ObjIndirectRef]*model.Stream3D),
+	}
+}
+
+// lock and unlock are no-ops when the pages are resolved
+// sequentially
+func (r resolver) lock() {
+	if r.mu != nil {
+		r.mu.Lock()
+	}
+}
+
+func (r resolver) unlock() {
+	if r.mu != nil {
+		r.mu.Unlock()
+	}
+}
+
+// setWorkers enables the concurrent resolution of the pages,
+// if `workers` is greater than 1
+func (r *resolver) setWorkers(workers int) {
+	if workers > 1 {
+		r.mu = new(sync.Mutex)
+		r.workers = workers
 	}
 }
 
@@ -92,18 +149,55 @@ var utf16Dec = unicode.UTF16(unicode.BigEndian, unicode.UseBOM).NewDecoder()
 // Note that encryption, escaping or hex-encoding should already
 // have been taken care of.
 func DecodeTextString(s string) string {
+	return decodeTextString(s, nil)
+}
+
+// isPDFDocEncoded returns false if `b` contains bytes
+// not defined in PDFDocEncoding
+func isPDFDocEncoded(b []byte) bool {
+	for _, c := range b {
+		if c != 0 && model.PDFDocEncoding[c] == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeTextString is the same as DecodeTextString, but
+// uses `fallback`, if not nil, for strings which are neither
+// valid UTF-16 nor valid PDFDocEncoding.
+func decodeTextString(s string, fallback encoding.Encoding) string {
+	return decodeTextStringLog(s, fallback, model.DefaultLogger())
+}
+
+// decodeTextStringLog reports the invalid strings to `logger`
+func decodeTextStringLog(s string, fallback encoding.Encoding, logger model.Logger) string {
 	b := []byte(s)
 
 	// Check for UTF-16: we also accept LE, since text/encoding handles it
 	if isUTF16(b) {
 		out, err := utf16Dec.Bytes(b)
-		if err != nil {
-			log.Printf("error decoding UTF16 string literal %v: %s \n", b, err)
+		if err == nil || fallback == nil {
+			if err != nil {
+				logger.Log(model.LogWarning, "error decoding UTF16 string literal", "string", b, "error", err)
+			}
+			return string(out)
 		}
-		return string(out)
+	} else if fallback == nil || isPDFDocEncoded(b) {
+		return model.PdfDocEncodingToString(b)
 	}
 
-	return model.PdfDocEncodingToString(b)
+	out, err := fallback.NewDecoder().Bytes(b)
+	if err != nil {
+		logger.Log(model.LogWarning, "error decoding string literal with fallback encoding", "string", b, "error", err)
+		return model.PdfDocEncodingToString(b)
+	}
+	return string(out)
+}
+
+// decodeTextString uses the fallback encoding provided in the options.
+func (r resolver) decodeTextString(s string) string {
+	return decodeTextStringLog(s, r.textFallback, r.logger)
 }
 
 // var replacer = strings.NewReplacer("\\\\", "\\", "\\(", ")", "\\)", "(", "\\r", "\r")
@@ -129,12 +223,12 @@ func (r resolver) info() model.Info {
 		creator, _ := file.IsString(r.resolve(d["Creator"]))
 		creationDate, _ := file.IsString(r.resolve(d["CreationDate"]))
 		modDate, _ := file.IsString(r.resolve(d["ModDate"]))
-		out.Producer = DecodeTextString(producer)
-		out.Title = DecodeTextString(title)
-		out.Subject = DecodeTextString(subject)
-		out.Author = DecodeTextString(author)
-		out.Keywords = DecodeTextString(keywords)
-		out.Creator = DecodeTextString(creator)
+		out.Producer = r.decodeTextString(producer)
+		out.Title = r.decodeTextString(title)
+		out.Subject = r.decodeTextString(subject)
+		out.Author = r.decodeTextString(author)
+		out.Keywords = r.decodeTextString(keywords)
+		out.Creator = r.decodeTextString(creator)
 		out.CreationDate, _ = DateTime(creationDate)
 		out.ModDate, _ = DateTime(modDate)
 	}
@@ -144,8 +238,53 @@ func (r resolver) info() model.Info {
 // Options enables greater control on the processing.
 // The zero value is a valid default configuration.
 type Options struct {
+	// CustomObjectResolver, if not nil, intercepts the objects
+	// not handled by the model (see CustomObjectResolver and CustomTypes).
 	CustomObjectResolver CustomObjectResolver
 	UserPassword         string
+
+	// RepairXref enables a fallback for corrupted xref tables,
+	// which are rebuilt by scanning the whole file.
+	RepairXref bool
+
+	// PreferValidDuplicate tries the other definitions of an object
+	// defined several times, if the most recent one is invalid.
+	PreferValidDuplicate bool
+
+	// TextEncodingFallback, if not nil, is used to decode text strings
+	// which are neither valid UTF-16 nor valid PDFDocEncoding, as written by some
+	// legacy producers (for instance, charmap.Windows1252 or charmap.ISO8859_1).
+	// By default, the invalid bytes are ignored.
+	TextEncodingFallback encoding.Encoding
+
+	// ObjectNumbers, if not nil, is filled with the object numbers
+	// of the shared indirect objects read (that is the ones implementing model.Referenceable),
+	// and may be used to preserve them when writing the document back
+	// (see model.WriteOptions.ObjectNumbers for the objects not covered).
+	ObjectNumbers map[model.Referenceable]model.Reference
+
+	// Logger, if not nil, receives the warnings about invalid or
+	// unsupported content, instead of the default logger (see model.SetLogger).
+	Logger model.Logger
+
+	// Workers, if greater than 1, is the number of goroutines used
+	// to resolve the pages and their resources, which is faster
+	// for large documents. The CustomObjectResolver and the Logger must
+	// then be safe for concurrent use.
+	Workers int
+
+	// Limits protects against malicious files. The MaxDepth limit also applies
+	// to the page tree and to the form fields hierarchy, and the MaxStreamSize
+	// limit to the text and JavaScript streams.
+	Limits file.Limits
+
+	// Mode controls the handling of the recoverable issues,
+	// defaulting to Lenient.
+	Mode Mode
+
+	// Warnings, if not nil, is filled with the recoverable issues
+	// found in the file (which are also sent to the Logger).
+	Warnings *[]Warning
 }
 
 // ParsePDFFile opens a file and calls `ParsePDFReader`,
@@ -160,6 +299,18 @@ func ParsePDFFile(filename string, options Options) (model.Document, *model.Encr
 	return ParsePDFReader(f, options)
 }
 
+// ParsePDFFileCtx is the same as `ParsePDFFile`,
+// but stops as soon as `ctx` is cancelled (see `ParsePDFReaderCtx`).
+func ParsePDFFileCtx(ctx context.Context, filename string, options Options) (model.Document, *model.Encrypt, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return model.Document{}, nil, fmt.Errorf("can't open file: %w", err)
+	}
+	defer f.Close()
+
+	return ParsePDFReaderCtx(ctx, f, options)
+}
+
 // ParsePDFReader reads a PDF file and builds a model.
 // This is done in two steps:
 //   - a first parsing step (involving lexing and parsing) builds a tree object
@@ -170,33 +321,149 @@ func ParsePDFFile(filename string, options Options) (model.Document, *model.Encr
 // Information about encryption are returned separately, and will be needed
 // if you want to encrypt the document back.
 func ParsePDFReader(source io.ReadSeeker, options Options) (model.Document, *model.Encrypt, error) {
-	config := file.Configuration{Password: options.UserPassword}
+	return parsePDFReader(nil, source, options)
+}
+
+// ParsePDFReaderCtx is the same as `ParsePDFReader`, but
+// stops with the error of `ctx` as soon as it is cancelled.
+// The cancellation is checked before parsing each object of the file,
+// and before resolving each page and form field.
+// Servers handling untrusted files should also set `options.Limits`.
+func ParsePDFReaderCtx(ctx context.Context, source io.ReadSeeker, options Options) (model.Document, *model.Encrypt, error) {
+	return parsePDFReader(ctx, source, options)
+}
+
+// parsePDFReader implements ParsePDFReader, with an optional context
+func parsePDFReader(cancel context.Context, source io.ReadSeeker, options Options) (model.Document, *model.Encrypt, error) {
+	options, warnings := options.collectWarnings()
+	config := options.configuration()
 
 	ti := time.Now()
 
-	ctx, err := file.Read(source, &config)
+	var (
+		ctx file.PDFFile
+		err error
+	)
+	if cancel != nil {
+		ctx, err = file.ReadContext(cancel, source, &config)
+	} else {
+		ctx, err = file.Read(source, &config)
+	}
 	if err != nil {
 		return model.Document{}, nil, fmt.Errorf("can't read PDF: %w", err)
 	}
 
 	if debug {
-		fmt.Printf("raw file processing: %s\n", time.Since(ti))
+		model.LoggerOrDefault(options.Logger).Log(model.LogDebug, "raw file processed", "duration", time.Since(ti))
 	}
 	ti = time.Now()
 
-	r := newResolver()
-	r.file = ctx
-	r.customResolve = options.CustomObjectResolver
+	r := options.newResolver(ctx)
+	r.cancel = cancel
+	r.setWorkers(options.Workers)
 
 	out, enc, err := r.processPDF()
+	if err == nil && options.ObjectNumbers != nil {
+		r.objectNumbers(options.ObjectNumbers)
+	}
 
 	if debug {
-		fmt.Printf("model processing: %s\n", time.Since(ti))
+		r.logger.Log(model.LogDebug, "model processed", "duration", time.Since(ti))
 	}
 
+	if err == nil {
+		err = options.reportWarnings(warnings)
+	}
 	return out, enc, err
 }
 
+// ParsePDFReaderAt is the same as `ParsePDFReader`, but reads the PDF file from
+// a random access source of `size` bytes, such as a memory-mapped file or
+// a reader issuing HTTP range requests.
+// Only the parts of the source needed by the parser are read, so that the whole
+// file is never loaded in memory at once.
+func ParsePDFReaderAt(source io.ReaderAt, size int64, options Options) (model.Document, *model.Encrypt, error) {
+	return ParsePDFReader(io.NewSectionReader(source, 0, size), options)
+}
+
+func (options Options) configuration() file.Configuration {
+	return file.Configuration{
+		Password:             options.UserPassword,
+		RepairXref:           options.RepairXref,
+		PreferValidDuplicate: options.PreferValidDuplicate,
+		Logger:               options.Logger,
+		Limits:               options.Limits,
+	}
+}
+
+func (options Options) newResolver(ctx file.PDFFile) resolver {
+	r := newResolver()
+	r.file = ctx
+	r.customResolve = options.CustomObjectResolver
+	r.textFallback = options.TextEncodingFallback
+	r.logger = model.LoggerOrDefault(options.Logger)
+	r.maxDepth = options.Limits.MaxDepth
+	r.maxStreamSize = options.Limits.MaxStreamSize
+	return r
+}
+
+// enter checks the cancellation, and increments
+// the nesting depth, checking the limit
+func (r *resolver) enter() error {
+	if r.cancel != nil {
+		if err := r.cancel.Err(); err != nil {
+			return err
+		}
+	}
+	r.depth++
+	if r.maxDepth > 0 && r.depth > r.maxDepth {
+		return fmt.Errorf("%w: maximum nesting depth exceeded (%d)", file.ErrLimitExceeded, r.maxDepth)
+	}
+	return nil
+}
+
+// ParseAcroForm opens a file and calls `ParseAcroFormReader`,
+// see the latter for details.
+func ParseAcroForm(filename string, options Options) (model.AcroForm, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return model.AcroForm{}, fmt.Errorf("can't open file: %w", err)
+	}
+	defer f.Close()
+
+	return ParseAcroFormReader(f, options)
+}
+
+// ParseAcroFormReader only reads the form fields of a PDF file, which is much
+// faster than `ParsePDFReader` when the fields are simply listed or filled.
+// The objects are parsed on demand, starting from the AcroForm entry of the catalog,
+// so that the pages, their content streams and images are never read.
+// Moreover, the appearance streams and icons of the widgets, and the
+// font files of the default resources, are not resolved.
+// As a consequence, the P entry of the widgets and the pages of the destinations are nil.
+// `options.Workers` is ignored.
+func ParseAcroFormReader(source io.ReadSeeker, options Options) (model.AcroForm, error) {
+	options, warnings := options.collectWarnings()
+	config := options.configuration()
+	config.Lazy = true
+	ctx, err := file.Read(source, &config)
+	if err != nil {
+		return model.AcroForm{}, fmt.Errorf("can't read PDF: %w", err)
+	}
+
+	r := options.newResolver(ctx)
+	r.formsOnly = true
+	catalog, ok := r.resolve(ctx.Root).(model.ObjDict)
+	if !ok {
+		return model.AcroForm{}, fmt.Errorf("can't resolve Catalog: expected dict, got %#v", r.resolve(ctx.Root))
+	}
+	form, err := r.processAcroForm(catalog["AcroForm"])
+	if err == nil {
+		err = options.reportWarnings(warnings)
+	}
+	return form, err
+}
+
 // ProcessContext walks through an already parsed PDF to build a model.
 // This function is exposed for debug purposes; you should probably use
 // one of `ParsePDFFile` or `ParsePDFReader` methods.
@@ -216,12 +483,97 @@ func (r resolver) processPDF() (model.Document, *model.Encrypt, error) {
 
 	out.Trailer.Info = r.info()
 
+	for k, v := range r.file.TrailerEntries {
+		entry, err := r.resolveCustomObject(v)
+		if err != nil {
+			return out, nil, fmt.Errorf("invalid trailer entry %s: %s", k, err)
+		}
+		if out.Trailer.Custom == nil {
+			out.Trailer.Custom = make(model.ObjDict)
+		}
+		out.Trailer.Custom[k] = entry
+	}
+
 	enc := r.file.Encrypt
 
 	out.Catalog, err = r.catalog()
 	return out, enc, err
 }
 
+// objectNumbers stores the object numbers of the cached
+// indirect objects into `dst`
+func (r resolver) objectNumbers(dst map[model.Referenceable]model.Reference) {
+	add := func(ref model.ObjIndirectRef, item model.Referenceable) {
+		dst[item] = model.Reference(ref.ObjectNumber)
+	}
+	for ref, v := range r.fonts {
+		add(ref, v)
+	}
+	for ref, v := range r.graphicsStates {
+		add(ref, v)
+	}
+	for ref, v := range r.encodings {
+		add(ref, v)
+	}
+	for ref, v := range r.annotations {
+		add(ref, v)
+	}
+	for ref, v := range r.fileSpecs {
+		add(ref, v)
+	}
+	for ref, v := range r.fileContents {
+		add(ref, v)
+	}
+	for ref, v := range r.shadings {
+		add(ref, v)
+	}
+	for ref, v := range r.functions {
+		add(ref, v)
+	}
+	for ref, v := range r.patterns {
+		add(ref, v)
+	}
+	for ref, v := range r.xObjectForms {
+		add(ref, v)
+	}
+	for ref, v := range r.images {
+		add(ref, v)
+	}
+	for ref, v := range r.xObjectsGroups {
+		add(ref, v)
+	}
+	for ref, v := range r.imageSMasks {
+		add(ref, v)
+	}
+	for ref, v := range r.iccs {
+		add(ref, v)
+	}
+	for ref, v := range r.colorTableStreams {
+		add(ref, v)
+	}
+	for ref, v := range r.fontFiles {
+		add(ref, v)
+	}
+	for ref, v := range r.ocgs {
+		add(ref, v)
+	}
+	for ref, v := range r.ocmds {
+		add(ref, v)
+	}
+	for ref, v := range r.halftones {
+		add(ref, v)
+	}
+	for ref, v := range r.signatures {
+		add(ref, v)
+	}
+	for ref, v := range r.sounds {
+		add(ref, v)
+	}
+	for ref, v := range r.streams3D {
+		add(ref, v)
+	}
+}
+
 // might return ObjNull{}, since, (PDF spec, clause 7.3.10)
 // An indirect reference to an undefined object shall not be considered an error by a conforming reader;
 // it shall be treated as a reference to the null object.
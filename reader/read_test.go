@@ -2,6 +2,8 @@ package reader
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -14,6 +16,8 @@ import (
 	"github.com/benoitkugler/pdf/fonts"
 	"github.com/benoitkugler/pdf/model"
 	"github.com/benoitkugler/pdf/reader/file"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
 )
 
 // the SPEC is a good test candidate
@@ -380,6 +384,68 @@ func TestWrite2(t *testing.T) {
 	}
 }
 
+func TestPreserveObjectNumbers(t *testing.T) {
+	annot := func(contents string) *model.AnnotationDict {
+		return &model.AnnotationDict{BaseAnnotation: model.BaseAnnotation{Contents: contents}, Subtype: model.AnnotationText{}}
+	}
+	var doc model.Document
+	doc.Catalog.Pages.Kids = []model.PageNode{&model.PageObject{
+		Annots: []*model.AnnotationDict{annot("1"), annot("2"), annot("3")},
+	}}
+	var buf bytes.Buffer
+	if err := doc.Write(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	numbers := make(map[model.Referenceable]model.Reference)
+	read, _, err := ParsePDFReader(bytes.NewReader(buf.Bytes()), Options{ObjectNumbers: numbers})
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := read.Catalog.Pages.Flatten()[0]
+	if len(numbers) != 3 {
+		t.Fatalf("expected 3 object numbers, got %v", numbers)
+	}
+	var max model.Reference
+	for _, ref := range numbers {
+		if ref > max {
+			max = ref
+		}
+	}
+	kept := page.Annots[1]
+	keptRef := numbers[kept]
+
+	// remove an annotation and add a new one
+	page.Annots = []*model.AnnotationDict{kept, page.Annots[2], annot("4")}
+
+	var out1, out2 bytes.Buffer
+	if err = read.WriteWithOptions(&out1, nil, model.WriteOptions{ObjectNumbers: numbers}); err != nil {
+		t.Fatal(err)
+	}
+	if err = read.WriteWithOptions(&out2, nil, model.WriteOptions{ObjectNumbers: numbers}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out1.Bytes(), out2.Bytes()) {
+		t.Fatal("expected deterministic output")
+	}
+
+	numbers2 := make(map[model.Referenceable]model.Reference)
+	read2, _, err := ParsePDFReader(bytes.NewReader(out1.Bytes()), Options{ObjectNumbers: numbers2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	annots := read2.Catalog.Pages.Flatten()[0].Annots
+	if len(annots) != 3 {
+		t.Fatalf("expected 3 annotations, got %d", len(annots))
+	}
+	if got := numbers2[annots[0]]; got != keptRef {
+		t.Fatalf("expected object number %d, got %d", keptRef, got)
+	}
+	if got := numbers2[annots[2]]; got <= max {
+		t.Fatalf("expected new object numbered after %d, got %d", max, got)
+	}
+}
+
 func TestJapanseOutline(t *testing.T) {
 	// https://github.com/benoitkugler/pdf/issues/5
 	file := "samples/JapaneseOutline.pdf"
@@ -407,3 +473,117 @@ func TestCerfaForm(t *testing.T) {
 		t.Fatal()
 	}
 }
+
+// countingReaderAt records the number of bytes read
+type countingReaderAt struct {
+	src  io.ReaderAt
+	read int64
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := c.src.ReadAt(p, off)
+	c.read += int64(n)
+	return n, err
+}
+
+func TestReaderAt(t *testing.T) {
+	f, err := os.Open("samples/CerfaArret.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source := &countingReaderAt{src: f}
+	doc, _, err := ParsePDFReaderAt(source, info.Size(), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if L := doc.Catalog.Pages.Count(); L != 5 {
+		t.Fatalf("expected 5 pages, got %d", L)
+	}
+	if source.read == 0 {
+		t.Fatal("source not used")
+	}
+}
+
+func TestParseContext(t *testing.T) {
+	var doc model.Document
+	inner := &model.PageTree{Kids: []model.PageNode{&model.PageObject{}}}
+	doc.Catalog.Pages.Kids = []model.PageNode{&model.PageTree{Kids: []model.PageNode{inner}}}
+	var buf bytes.Buffer
+	if err := doc.Write(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	source := bytes.NewReader(buf.Bytes())
+
+	read, _, err := ParsePDFReaderCtx(context.Background(), source, Options{Limits: file.Limits{MaxDepth: 3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if L := read.Catalog.Pages.Count(); L != 1 {
+		t.Fatalf("expected 1 page, got %d", L)
+	}
+
+	_, _, err = ParsePDFReaderCtx(context.Background(), source, Options{Limits: file.Limits{MaxDepth: 2}})
+	if !errors.Is(err, file.ErrLimitExceeded) {
+		t.Fatalf("expected limit error, got %v", err)
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err = ParsePDFReaderCtx(cancelled, source, Options{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation, got %v", err)
+	}
+	if _, _, err = ParsePDFFileCtx(cancelled, "samples/CerfaArret.pdf", Options{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation, got %v", err)
+	}
+}
+
+func TestStreamSizeLimit(t *testing.T) {
+	// a JavaScript stream decoding to 1 MB
+	bomb := model.NewCompressedStream(bytes.Repeat([]byte(" "), 1<<20))
+	data := buildPDF([]string{
+		"<</Type/Catalog/Pages 2 0 R/OpenAction<</S/JavaScript/JS 3 0 R>>>>",
+		"<</Type/Pages/Kids []/Count 0>>",
+		fmt.Sprintf("<</Length %d/Filter/FlateDecode>>\nstream\n%s\nendstream", len(bomb.Content), bomb.Content),
+	})
+
+	doc, _, err := ParsePDFReader(bytes.NewReader(data), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if js := doc.Catalog.OpenAction.ActionType.(model.ActionJavaScript).JS; len(js) != 1<<20 {
+		t.Fatalf("unexpected script length %d", len(js))
+	}
+
+	_, _, err = ParsePDFReader(bytes.NewReader(data), Options{Limits: file.Limits{MaxStreamSize: 1000}})
+	if !errors.Is(err, file.ErrLimitExceeded) {
+		t.Fatalf("expected limit error, got %v", err)
+	}
+}
+
+func TestTextEncodingFallback(t *testing.T) {
+	for _, test := range []struct {
+		input    string
+		fallback bool
+		expected string
+	}{
+		{"Caf\xe9", false, "Café"},
+		{"Caf\xe9", true, "Café"},                      // valid PDFDocEncoding
+		{"\x9fles d'Hy\xe8res", false, "les d'Hyères"}, // invalid byte ignored
+		{"\x9fles d'Hy\xe8res", true, "Ÿles d'Hyères"},
+		{"\xfe\xff\x00\xe9", true, "é"}, // UTF-16
+	} {
+		var fallback encoding.Encoding
+		if test.fallback {
+			fallback = charmap.Windows1252
+		}
+		if got := decodeTextString(test.input, fallback); got != test.expected {
+			t.Fatalf("expected %s, got %s", test.expected, got)
+		}
+	}
+}
@@ -3,7 +3,6 @@ package reader
 import (
 	"errors"
 	"fmt"
-	"log"
 
 	"github.com/benoitkugler/pdf/fonts/standardfonts"
 	"github.com/benoitkugler/pdf/model"
@@ -13,7 +12,10 @@ import (
 func (r resolver) resolveOneResourceDict(o model.Object) (model.ResourcesDict, error) {
 	ref, isRef := o.(model.ObjIndirectRef)
 	if isRef {
-		if res, ok := r.resources[ref]; isRef && ok {
+		r.lock()
+		res, ok := r.resources[ref]
+		r.unlock()
+		if ok {
 			return res, nil
 		}
 		o = r.resolve(ref)
@@ -60,22 +62,38 @@ func (r resolver) resolveOneResourceDict(o model.Object) (model.ResourcesDict, e
 		return out, err
 	}
 	// Properties
-	out.Properties, err = r.resolveProperties(resDict["Properties"])
+	out.Properties, out.OptionalContents, err = r.resolveProperties(resDict["Properties"])
 	if err != nil {
 		return out, err
 	}
 
 	if isRef { // write back to the cache
+		r.lock()
+		defer r.unlock()
+		if res, ok := r.resources[ref]; ok { // resolved concurrently
+			return res, nil
+		}
 		r.resources[ref] = out
 	}
 
 	return out, nil
 }
 
+// fontKeys are the entries handled by the model, for all the font types
+var fontKeys = map[model.Name]bool{
+	"BaseFont": true, "CharProcs": true, "DescendantFonts": true, "Encoding": true,
+	"FirstChar": true, "FontBBox": true, "FontDescriptor": true, "FontMatrix": true,
+	"LastChar": true, "Name": true, "Resources": true, "Subtype": true, "ToUnicode": true,
+	"Type": true, "Widths": true,
+}
+
 func (r resolver) resolveOneFont(font model.Object) (*model.FontDict, error) {
 	fontRef, isFontRef := font.(model.ObjIndirectRef)
 	if isFontRef {
-		if fontModel := r.fonts[fontRef]; isFontRef && fontModel != nil {
+		r.lock()
+		fontModel := r.fonts[fontRef]
+		r.unlock()
+		if fontModel != nil {
 			return fontModel, nil
 		}
 		font = r.resolve(fontRef)
@@ -96,7 +114,16 @@ func (r resolver) resolveOneFont(font model.Object) (*model.FontDict, error) {
 	if err != nil {
 		return nil, err
 	}
+	fontModel.Custom, err = r.resolveCustomEntries(fontDict, fontKeys)
+	if err != nil {
+		return nil, err
+	}
 	if isFontRef { // write back to the cache
+		r.lock()
+		defer r.unlock()
+		if existing := r.fonts[fontRef]; existing != nil { // resolved concurrently
+			return existing, nil
+		}
 		r.fonts[fontRef] = fontModel
 	}
 	return fontModel, nil
@@ -170,7 +197,9 @@ func (r resolver) resolveEncoding(encoding model.Object) (model.SimpleEncoding,
 		encModel.Differences = r.parseDiffArray(diff)
 	}
 	if isRef { // write back encoding to the cache
+		r.lock()
 		r.encodings[encRef] = &encModel
+		r.unlock()
 	}
 	return &encModel, nil
 }
@@ -204,6 +233,12 @@ func (r resolver) resolveFontTT1orTT(font model.ObjDict) (out model.FontType1, e
 		out.FirstChar = f
 		out.Widths = w
 		out.FontDescriptor = standard.Descriptor
+		// the font program may still be embedded (as required by PDF/A)
+		if fd := font["FontDescriptor"]; fd != nil {
+			if desc, err := r.resolveFontDescriptor(fd); err == nil {
+				out.FontDescriptor.FontFile = desc.FontFile
+			}
+		}
 		return out, nil
 	}
 
@@ -243,7 +278,7 @@ func (r resolver) resolveFontT3(font model.ObjDict) (out model.FontType3, err er
 			return out, err
 		}
 		if !ok {
-			log.Printf("missing content stream for CharProc %s\n", name)
+			r.logger.Log(model.LogWarning, "missing content stream for CharProc", "name", name)
 			continue
 		}
 		out.CharProcs[model.ObjName(name)] = model.ContentStream{Stream: cs}
@@ -329,7 +364,7 @@ func (r resolver) resolveFontMetrics(font model.ObjDict) (firstChar byte, widths
 	}
 	// be careful to byte overflow when LastChar = 255 and FirstChar = 0
 	if exp := int(lastChar) - int(firstChar) + 1; widths != nil && exp != len(widths) {
-		log.Printf("invalid length for font Widths array: expected %d, got %d", exp, len(widths))
+		r.logger.Log(model.LogWarning, "invalid length for font Widths array", "expected", exp, "got", len(widths))
 	}
 
 	return
@@ -405,9 +440,15 @@ func (r resolver) resolveFontDescriptor(entry model.Object) (model.FontDescripto
 }
 
 func (r resolver) processFontFile(object model.Object) (*model.FontFile, error) {
+	if r.formsOnly {
+		return nil, nil
+	}
 	ref, isRef := object.(model.ObjIndirectRef)
-	if out, has := r.fontFiles[ref]; isRef && has {
-		return out, nil
+	r.lock()
+	cached, has := r.fontFiles[ref]
+	r.unlock()
+	if isRef && has {
+		return cached, nil
 	}
 
 	cs, ok, err := r.resolveStream(object)
@@ -432,6 +473,11 @@ func (r resolver) processFontFile(object model.Object) (*model.FontFile, error)
 
 	// write back to the cache
 	if isRef {
+		r.lock()
+		defer r.unlock()
+		if existing, has := r.fontFiles[ref]; has { // resolved concurrently
+			return existing, nil
+		}
 		r.fontFiles[ref] = out
 	}
 	return out, nil
@@ -541,8 +587,8 @@ func (r resolver) resolveCIDFontDict(cid model.ObjDict) (model.CIDFontDictionary
 	out.W = r.processCIDWidths(cid["W"])
 
 	out.W2, err = r.processCIDVerticalMetrics(cid["W2"])
-	if err != nil {
-		return out, err
+	if err != nil { // keep the valid entries
+		r.logger.Log(model.LogWarning, "invalid font W2 array", "error", err)
 	}
 
 	if id, _ := r.resolveName(cid["CIDToGIDMap"]); id == "Identity" {
@@ -566,6 +612,7 @@ func (r resolver) processCIDWidths(wds model.Object) []model.CIDWidth {
 		first, _ := r.resolveInt(ar[i])
 		if i+1 >= len(ar) {
 			// invalid, ignore last element
+			r.logger.Log(model.LogWarning, "invalid font W array: missing widths")
 			return out
 		}
 		switch next := r.resolve(ar[i+1]).(type) {
@@ -573,6 +620,7 @@ func (r resolver) processCIDWidths(wds model.Object) []model.CIDWidth {
 			last := next
 			if i+2 >= len(ar) {
 				// invalid, ignore last element
+				r.logger.Log(model.LogWarning, "invalid font W array: missing width")
 				return out
 			}
 			w, _ := r.resolveNumber(ar[i+2])
@@ -594,6 +642,7 @@ func (r resolver) processCIDWidths(wds model.Object) []model.CIDWidth {
 			i += 2
 		default:
 			// invalid, return
+			r.logger.Log(model.LogWarning, "invalid font W array", "entry", next)
 			return out
 		}
 	}
@@ -688,7 +737,10 @@ func (r resolver) resolveExtGState(states model.Object) (map[model.ObjName]*mode
 func (r resolver) resolveOneExtGState(state model.Object) (*model.GraphicState, error) {
 	stateRef, isRef := state.(model.ObjIndirectRef)
 	if isRef {
-		if gState := r.graphicsStates[stateRef]; isRef && gState != nil {
+		r.lock()
+		gState := r.graphicsStates[stateRef]
+		r.unlock()
+		if gState != nil {
 			return gState, nil
 		}
 		state = r.resolve(stateRef)
@@ -705,6 +757,11 @@ func (r resolver) resolveOneExtGState(state model.Object) (*model.GraphicState,
 		return nil, err
 	}
 	if isRef {
+		r.lock()
+		defer r.unlock()
+		if existing := r.graphicsStates[stateRef]; existing != nil { // resolved concurrently
+			return existing, nil
+		}
 		r.graphicsStates[stateRef] = gStateModel
 	}
 	return gStateModel, nil
@@ -741,8 +798,7 @@ func (r resolver) parseStateDict(state model.ObjDict) (*model.GraphicState, erro
 	if d, _ := r.resolveArray(state["D"]); len(d) == 2 {
 		dash, _ := r.resolveArray(d[0])
 		phase, _ := r.resolveNumber(d[1])
-		out.D.Array = r.processFloatArray(dash)
-		out.D.Phase = phase
+		out.D = &model.DashPattern{Array: r.processFloatArray(dash), Phase: phase}
 	}
 
 	if font, _ := r.resolveArray(state["Font"]); len(font) == 2 {
@@ -768,6 +824,154 @@ func (r resolver) parseStateDict(state model.ObjDict) (*model.GraphicState, erro
 		return nil, err
 	}
 
+	if op, ok := r.resolveBool(state["OP"]); ok {
+		out.OP = model.ObjBool(op)
+	}
+	if op, ok := r.resolveBool(state["op"]); ok {
+		out.Op = model.ObjBool(op)
+	}
+	if opm, ok := r.resolveInt(state["OPM"]); ok {
+		out.OPM = model.ObjInt(opm)
+	}
+	if fl, ok := r.resolveNumber(state["FL"]); ok {
+		out.FL = model.ObjFloat(fl)
+	}
+	if tk, ok := r.resolveBool(state["TK"]); ok {
+		out.TK = model.ObjBool(tk)
+	}
+	for _, entry := range [...]struct {
+		key  model.Name
+		dest *model.GraphicStateFunction
+	}{
+		{"BG", &out.BG}, {"BG2", &out.BG2}, {"UCR", &out.UCR},
+		{"UCR2", &out.UCR2}, {"TR", &out.TR}, {"TR2", &out.TR2},
+	} {
+		*entry.dest, err = r.resolveGraphicStateFunction(state[entry.key])
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s entry: %s", entry.key, err)
+		}
+	}
+	if ht := state["HT"]; ht != nil {
+		out.HT, err = r.resolveHalftone(ht)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &out, nil
+}
+
+func (r resolver) resolveGraphicStateFunction(obj model.Object) (out model.GraphicStateFunction, err error) {
+	switch o := r.resolve(obj).(type) {
+	case nil:
+	case model.ObjName:
+		out.Name = o
+	case model.ObjArray:
+		out.Functions = make([]*model.FunctionDict, len(o))
+		for i, fn := range o {
+			out.Functions[i], err = r.resolveFunction(fn)
+			if err != nil {
+				return out, err
+			}
+		}
+	default:
+		fn, err := r.resolveFunction(obj)
+		if err != nil {
+			return out, err
+		}
+		out.Functions = []*model.FunctionDict{fn}
+	}
+	return out, nil
+}
+
+// resolveHalftone also accepts the /Default name
+func (r resolver) resolveHalftone(obj model.Object) (*model.HalftoneDict, error) {
+	ref, isRef := obj.(model.ObjIndirectRef)
+	r.lock()
+	cached := r.halftones[ref]
+	r.unlock()
+	if isRef && cached != nil {
+		return cached, nil
+	}
+	var (
+		out  model.HalftoneDict
+		dict model.ObjDict
+		err  error
+	)
+	switch o := r.resolve(obj).(type) {
+	case model.ObjName:
+		if o != "Default" {
+			return nil, fmt.Errorf("invalid name for halftone: %s", o)
+		}
+		return &out, nil
+	case model.ObjDict:
+		dict = o
+	case model.ObjStream:
+		dict = o.Args
+		stream, _, err := r.resolveStream(o)
+		if err != nil {
+			return nil, err
+		}
+		out.Stream = stream
+	default:
+		return nil, errType("Halftone", o)
+	}
+	if isRef { // protect against circular colorants
+		r.lock()
+		existing := r.halftones[ref]
+		if existing == nil {
+			r.halftones[ref] = &out
+		}
+		r.unlock()
+		if existing != nil { // resolved concurrently
+			return existing, nil
+		}
+	}
+
+	ht, _ := r.resolveInt(dict["HalftoneType"])
+	out.HalftoneType = model.HalftoneType(ht)
+	name, _ := file.IsString(r.resolve(dict["HalftoneName"]))
+	out.HalftoneName = name
+	out.TransferFunction, err = r.resolveGraphicStateFunction(dict["TransferFunction"])
+	if err != nil {
+		return nil, err
+	}
+	switch out.HalftoneType {
+	case model.HalftoneScreen:
+		out.Frequency, _ = r.resolveNumber(dict["Frequency"])
+		out.Angle, _ = r.resolveNumber(dict["Angle"])
+		spot := r.resolve(dict["SpotFunction"])
+		if names, isArray := spot.(model.ObjArray); isArray && len(names) != 0 {
+			if _, isName := r.resolve(names[0]).(model.ObjName); isName {
+				spot = names[0] // the first predefined spot function is used
+			}
+		}
+		out.SpotFunction, err = r.resolveGraphicStateFunction(spot)
+		if err != nil {
+			return nil, err
+		}
+		out.AccurateScreens, _ = r.resolveBool(dict["AccurateScreens"])
+	case model.HalftoneComposite:
+		out.Colorants = make(map[model.Name]*model.HalftoneDict)
+		for name, colorant := range dict {
+			switch name {
+			case "Type", "HalftoneType", "HalftoneName":
+				continue
+			}
+			out.Colorants[name], err = r.resolveHalftone(colorant)
+			if err != nil {
+				return nil, err
+			}
+		}
+	case model.HalftoneThreshold, model.HalftoneThreshold16:
+		out.Width, _ = r.resolveInt(dict["Width"])
+		out.Height, _ = r.resolveInt(dict["Height"])
+		out.Width2, _ = r.resolveInt(dict["Width2"])
+		out.Height2, _ = r.resolveInt(dict["Height2"])
+	case model.HalftoneTwoSquares:
+		out.Xsquare, _ = r.resolveInt(dict["Xsquare"])
+		out.Ysquare, _ = r.resolveInt(dict["Ysquare"])
+	}
 	return &out, nil
 }
 
@@ -824,19 +1028,36 @@ func (r resolver) resolveColorSpace(colorSpace model.Object) (model.ResourcesCol
 	return out, nil
 }
 
-func (r resolver) resolveProperties(obj model.Object) (map[model.ObjName]model.PropertyList, error) {
+// resolveProperties splits the optional contents from the other property lists
+func (r resolver) resolveProperties(obj model.Object) (map[model.ObjName]model.PropertyList, map[model.Name]model.OptionalContent, error) {
 	dict, _ := r.resolve(obj).(model.ObjDict)
 	out := map[model.ObjName]model.PropertyList{}
+	ocs := map[model.Name]model.OptionalContent{}
 	var err error
 	for k, v := range dict {
+		if r.isOptionalContentDict(v) {
+			ocs[model.Name(k)], err = r.resolveOptionalContent(v)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid property %s: %s", k, err)
+			}
+			continue
+		}
 		vDict, _ := r.resolve(v).(model.ObjDict)
 		propDict := make(model.ObjDict)
 		for pName, pValue := range vDict {
 			// special case Metadata, which is common
-			if pName == "Metadata" && r.customResolve == nil {
+			if pName == "Metadata" {
+				intercepted, err := r.interceptCustomObject(pValue)
+				if err != nil {
+					return nil, nil, fmt.Errorf("invalid Metadata entry: %s", err)
+				}
+				if intercepted != nil {
+					propDict["Metadata"] = intercepted
+					continue
+				}
 				cs, ok, err := r.resolveStream(pValue)
 				if err != nil {
-					return nil, fmt.Errorf("invalid Metadata entry: %s", err)
+					return nil, nil, fmt.Errorf("invalid Metadata entry: %s", err)
 				}
 				if ok {
 					propDict["Metadata"] = model.MetadataStream{Stream: cs}
@@ -844,11 +1065,11 @@ func (r resolver) resolveProperties(obj model.Object) (map[model.ObjName]model.P
 			} else {
 				propDict[model.ObjName(pName)], err = r.resolveCustomObject(pValue)
 				if err != nil {
-					return nil, fmt.Errorf("invalid property %s: %s", pName, err)
+					return nil, nil, fmt.Errorf("invalid property %s: %s", pName, err)
 				}
 			}
 		}
 		out[model.ObjName(k)] = propDict
 	}
-	return out, nil
+	return out, ocs, nil
 }
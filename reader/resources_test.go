@@ -1,6 +1,7 @@
 package reader
 
 import (
+	"bytes"
 	"fmt"
 	"reflect"
 	"testing"
@@ -122,3 +123,60 @@ func TestFontType1C(t *testing.T) {
 		}
 	}
 }
+
+func TestExtGStateDeviceControl(t *testing.T) {
+	fn := &model.FunctionDict{Domain: []model.Range{{0, 1}}, FunctionType: model.FunctionExpInterpolation{C0: []model.Fl{0}, C1: []model.Fl{1}, N: 1}}
+	threshold := &model.HalftoneDict{HalftoneType: 6, Width: 2, Height: 2, Stream: model.Stream{Content: []byte{0, 64, 128, 255}}}
+	gs := &model.GraphicState{
+		OP:   model.ObjBool(true),
+		Op:   model.ObjBool(false),
+		OPM:  model.ObjInt(1),
+		BG:   model.GraphicStateFunction{Functions: []*model.FunctionDict{fn}},
+		BG2:  model.GraphicStateFunction{Name: "Default"},
+		UCR2: model.GraphicStateFunction{Functions: []*model.FunctionDict{fn}},
+		TR:   model.GraphicStateFunction{Functions: []*model.FunctionDict{fn, fn, fn, fn}},
+		TR2:  model.GraphicStateFunction{Name: "Identity"},
+		HT: &model.HalftoneDict{HalftoneType: 5, Colorants: map[model.Name]*model.HalftoneDict{
+			"Default": {HalftoneType: 1, Frequency: 60, Angle: 45, SpotFunction: model.GraphicStateFunction{Name: "Round"}},
+			"Cyan":    threshold,
+		}},
+		FL: model.ObjFloat(2),
+		TK: model.ObjBool(false),
+	}
+	res := model.NewResourcesDict()
+	res.ExtGState["GS1"] = gs
+	var doc model.Document
+	doc.Catalog.Pages.Kids = []model.PageNode{&model.PageObject{Resources: &res}}
+
+	var buf bytes.Buffer
+	if err := doc.Write(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	doc2, _, err := ParsePDFReader(bytes.NewReader(buf.Bytes()), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := doc2.Catalog.Pages.Flatten()[0].Resources.ExtGState["GS1"]
+	if got.OP != model.ObjBool(true) || got.Op != model.ObjBool(false) || got.OPM != model.ObjInt(1) {
+		t.Fatalf("unexpected overprint %v %v %v", got.OP, got.Op, got.OPM)
+	}
+	if got.FL != model.ObjFloat(2) || got.TK != model.ObjBool(false) {
+		t.Fatalf("unexpected FL, TK: %v %v", got.FL, got.TK)
+	}
+	if len(got.BG.Functions) != 1 || got.BG2.Name != "Default" || got.UCR.Functions != nil || len(got.UCR2.Functions) != 1 {
+		t.Fatalf("unexpected BG, UCR: %v %v %v %v", got.BG, got.BG2, got.UCR, got.UCR2)
+	}
+	if len(got.TR.Functions) != 4 || got.TR.Functions[0] != got.TR.Functions[1] || got.TR2.Name != "Identity" {
+		t.Fatalf("unexpected TR: %v %v", got.TR, got.TR2)
+	}
+	def, cyan := got.HT.Colorants["Default"], got.HT.Colorants["Cyan"]
+	if got.HT.HalftoneType != 5 || len(got.HT.Colorants) != 2 {
+		t.Fatalf("unexpected halftone %v", got.HT)
+	}
+	if def.HalftoneType != 1 || def.Frequency != 60 || def.SpotFunction.Name != "Round" {
+		t.Fatalf("unexpected halftone %v", def)
+	}
+	if cyan.HalftoneType != 6 || cyan.Width != 2 || !reflect.DeepEqual(cyan.Content, threshold.Content) {
+		t.Fatalf("unexpected threshold halftone %v", cyan)
+	}
+}
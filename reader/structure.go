@@ -2,7 +2,6 @@ package reader
 
 import (
 	"fmt"
-	"log"
 
 	"github.com/benoitkugler/pdf/model"
 	"github.com/benoitkugler/pdf/reader/file"
@@ -73,7 +72,7 @@ func (r resolver) resolveStructureTree(obj model.Object) (*model.StructureTree,
 			}
 			attrs = []model.AttributeObject{a}
 		case model.ObjStream:
-			log.Println("unsupported attribute type : stream. skipping")
+			r.logger.Log(model.LogWarning, "unsupported attribute type: stream, skipping")
 		default:
 			return nil, errType("structure Attribute", v)
 		}
@@ -85,20 +84,63 @@ func (r resolver) resolveStructureTree(obj model.Object) (*model.StructureTree,
 
 // CustomObjectResolver provides a way
 // to overide the default reading behaviour
-// for custom objects
+// for custom objects, that is the objects not handled by the model,
+// such as property lists, signature build properties, and the unknown entries
+// of the trailer, catalog, pages, annotations (including the annotations with an unsupported subtype) and fonts.
+//
+// Resolve is called for each custom object, and then for the elements of the arrays and dictionaries
+// which are not intercepted. Returning a nil object selects the default processing, which
+// resolves the indirect objects and copies the basic PDF types.
+// The returned object is written back with its Write method, so that user types
+// may be used to round-trip extensions.
 type CustomObjectResolver interface {
 	Resolve(f *file.PDFFile, obj model.Object) (model.Object, error)
 }
 
-func (r resolver) resolveCustomObject(object model.Object) (model.Object, error) {
+// CustomTypes is a CustomObjectResolver intercepting the dictionaries
+// whose Type entry is one of its keys.
+// Other objects use the default processing.
+type CustomTypes map[model.Name]func(f *file.PDFFile, dict model.ObjDict) (model.Object, error)
+
+// Resolve implements CustomObjectResolver.
+func (ct CustomTypes) Resolve(f *file.PDFFile, obj model.Object) (model.Object, error) {
+	dict, ok := f.ResolveObject(obj).(model.ObjDict)
+	if !ok {
+		return nil, nil
+	}
+	typ, _ := f.ResolveObject(dict["Type"]).(model.ObjName)
+	if fn := ct[typ]; fn != nil {
+		return fn(f, dict)
+	}
+	return nil, nil
+}
+
+// interceptCustomObject returns the object returned by the user resolver,
+// or nil for the default processing
+func (r resolver) interceptCustomObject(object model.Object) (model.Object, error) {
 	if r.customResolve == nil {
-		return r.defaultProcessCustomObject(object)
+		return nil, nil
 	}
 	return r.customResolve.Resolve(&r.file, object)
 }
 
-// resolve indirect object
-func (r resolver) defaultProcessCustomObject(object model.Object) (model.Object, error) {
+func (r resolver) resolveCustomObject(object model.Object) (model.Object, error) {
+	return r.processCustomObject(object, make(map[model.ObjIndirectRef]bool))
+}
+
+// processCustomObject resolves the indirect objects; `visiting` stores the references
+// being resolved, so that cycles are replaced by null
+func (r resolver) processCustomObject(object model.Object, visiting map[model.ObjIndirectRef]bool) (model.Object, error) {
+	if out, err := r.interceptCustomObject(object); err != nil || out != nil {
+		return out, err
+	}
+	if ref, isRef := object.(model.ObjIndirectRef); isRef {
+		if visiting[ref] {
+			return model.ObjNull{}, nil
+		}
+		visiting[ref] = true
+		defer delete(visiting, ref)
+	}
 	var err error
 	object = r.resolve(object)
 	switch o := object.(type) {
@@ -117,7 +159,7 @@ func (r resolver) defaultProcessCustomObject(object model.Object) (model.Object,
 	case model.ObjArray:
 		out := make(model.ObjArray, len(o))
 		for i, v := range o {
-			out[i], err = r.defaultProcessCustomObject(v)
+			out[i], err = r.processCustomObject(v, visiting)
 			if err != nil {
 				return nil, err
 			}
@@ -126,7 +168,7 @@ func (r resolver) defaultProcessCustomObject(object model.Object) (model.Object,
 	case model.ObjDict:
 		out := make(model.ObjDict, len(o))
 		for name, v := range o {
-			out[model.Name(name)], err = r.defaultProcessCustomObject(v)
+			out[model.Name(name)], err = r.processCustomObject(v, visiting)
 			if err != nil {
 				return nil, err
 			}
@@ -135,17 +177,42 @@ func (r resolver) defaultProcessCustomObject(object model.Object) (model.Object,
 	case model.ObjStream:
 		args := make(model.ObjDict, len(o.Args))
 		for name, v := range o.Args {
-			args[model.Name(name)], err = r.defaultProcessCustomObject(v)
+			args[model.Name(name)], err = r.processCustomObject(v, visiting)
 			if err != nil {
 				return nil, err
 			}
 		}
 		return model.ObjStream{Args: args, Content: o.Content}, nil
+	case nil, model.ObjNull:
+		return model.ObjNull{}, nil
 	default:
 		return nil, fmt.Errorf("unsupported custom type %T in custom object", object)
 	}
 }
 
+// resolveCustomEntries returns the entries of `dict` which are not
+// in `known`, or nil if there are none.
+func (r resolver) resolveCustomEntries(dict model.ObjDict, known map[model.Name]bool) (model.ObjDict, error) {
+	var out model.ObjDict
+	for k, v := range dict {
+		if known[k] {
+			continue
+		}
+		entry, err := r.resolveCustomObject(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid entry %s: %s", k, err)
+		}
+		if _, isNull := entry.(model.ObjNull); isNull {
+			continue
+		}
+		if out == nil {
+			out = make(model.ObjDict)
+		}
+		out[k] = entry
+	}
+	return out, nil
+}
+
 func (r resolver) resolveAttributObject(attr model.Object) (out model.AttributeObject, err error) {
 	attr = r.resolve(attr)
 	attrDict, ok := attr.(model.ObjDict)
@@ -220,8 +287,11 @@ func (r resolver) resolveUserProperties(dict model.ObjDict, out *model.Attribute
 
 func (r resolver) resolveOneStructureElement(element model.Object, parent *model.StructureElement) (*model.StructureElement, error) {
 	ref, isRef := element.(model.ObjIndirectRef)
-	if out := r.structure[ref]; isRef && out != nil {
-		return out, nil
+	r.lock()
+	cached := r.structure[ref]
+	r.unlock()
+	if isRef && cached != nil {
+		return cached, nil
 	}
 
 	element = r.resolve(element)
@@ -235,7 +305,15 @@ func (r resolver) resolveOneStructureElement(element model.Object, parent *model
 	)
 
 	if isRef { // register the structure element
-		r.structure[ref] = &out
+		r.lock()
+		existing := r.structure[ref]
+		if existing == nil {
+			r.structure[ref] = &out
+		}
+		r.unlock()
+		if existing != nil { // resolved concurrently
+			return existing, nil
+		}
 	}
 
 	out.P = parent
@@ -304,19 +382,19 @@ func (r resolver) resolveOneStructureElement(element model.Object, parent *model
 
 	out.R, _ = r.resolveInt(dict["R"])
 	if s, ok := file.IsString(r.resolve(dict["T"])); ok {
-		out.T = DecodeTextString(s)
+		out.T = r.decodeTextString(s)
 	}
 	if s, ok := file.IsString(r.resolve(dict["Lang"])); ok {
-		out.Lang = DecodeTextString(s)
+		out.Lang = r.decodeTextString(s)
 	}
 	if s, ok := file.IsString(r.resolve(dict["Alt"])); ok {
-		out.Alt = DecodeTextString(s)
+		out.Alt = r.decodeTextString(s)
 	}
 	if s, ok := file.IsString(r.resolve(dict["E"])); ok {
-		out.E = DecodeTextString(s)
+		out.E = r.decodeTextString(s)
 	}
 	if s, ok := file.IsString(r.resolve(dict["ActualText"])); ok {
-		out.ActualText = DecodeTextString(s)
+		out.ActualText = r.decodeTextString(s)
 	}
 
 	return &out, nil
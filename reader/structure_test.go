@@ -1,12 +1,14 @@
 package reader
 
 import (
+	"bytes"
 	"fmt"
 	"reflect"
 	"testing"
 	"time"
 
 	"github.com/benoitkugler/pdf/model"
+	"github.com/benoitkugler/pdf/reader/file"
 )
 
 func TestStructureTree(t *testing.T) {
@@ -85,3 +87,58 @@ func TestStructureTree(t *testing.T) {
 		}
 	}
 }
+
+// vendorData is a user defined object
+type vendorData struct{ Value int }
+
+func (v vendorData) Write(model.PDFWritter, model.Reference) string {
+	return fmt.Sprintf("<</Type/VendorData/Value %d>>", v.Value)
+}
+
+func (v vendorData) Clone() model.Object { return v }
+
+func TestCustomTypesRoundTrip(t *testing.T) {
+	objects := []string{
+		"<</Type/Catalog/Pages 2 0 R/VendorRoot <</Nested <</Type/VendorData/Value 4>>/Other 7>>>>",
+		"<</Type/Pages/Kids [3 0 R]/Count 1>>",
+		"<</Type/Page/Parent 2 0 R/MediaBox [0 0 100 100]/Annots [4 0 R]>>",
+		"<</Type/Annot/Subtype/VendorNote/Rect [0 0 10 10]/Open true/Data 5 0 R/Popup 6 0 R/P 3 0 R>>",
+		"<</Type/VendorData/Value 3/Extra [1 2]>>",
+		"<</Type/Annot/Subtype/Popup/Rect [0 0 10 10]/Parent 4 0 R>>",
+	}
+	resolver := CustomTypes{"VendorData": func(f *file.PDFFile, dict model.ObjDict) (model.Object, error) {
+		value, _ := f.ResolveObject(dict["Value"]).(model.ObjInt)
+		return vendorData{Value: int(value)}, nil
+	}}
+	options := Options{CustomObjectResolver: resolver}
+	doc, _, err := ParsePDFReader(bytes.NewReader(buildPDF(objects)), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := func(doc model.Document) {
+		t.Helper()
+		annots := doc.Catalog.Pages.Flatten()[0].Annots
+		if len(annots) != 1 || annots[0].Subtype != (model.AnnotationCustom{Subtype: "VendorNote"}) {
+			t.Fatalf("unexpected annotations %v", annots)
+		}
+		exp := model.ObjDict{"Open": model.ObjBool(true), "Data": vendorData{Value: 3}}
+		if !reflect.DeepEqual(annots[0].Custom, exp) {
+			t.Fatalf("expected %v, got %v", exp, annots[0].Custom)
+		}
+		root := model.ObjDict{"VendorRoot": model.ObjDict{"Nested": vendorData{Value: 4}, "Other": model.ObjInt(7)}}
+		if !reflect.DeepEqual(doc.Catalog.Custom, root) {
+			t.Fatalf("expected %v, got %v", root, doc.Catalog.Custom)
+		}
+	}
+	check(doc)
+
+	var out bytes.Buffer
+	if err = doc.Write(&out, nil); err != nil {
+		t.Fatal(err)
+	}
+	doc2, _, err := ParsePDFReader(bytes.NewReader(out.Bytes()), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(doc2)
+}
@@ -99,6 +99,32 @@ func (d embFileNameTree) resolveLeafValueAppend(r resolver, name string, value m
 	return err
 }
 
+type javascriptNameTree struct {
+	out *model.JavaScriptTree // target which will be filled
+}
+
+func (d javascriptNameTree) createKid() nameTree {
+	return javascriptNameTree{out: new(model.JavaScriptTree)}
+}
+
+func (d javascriptNameTree) appendKid(kid nameTree) {
+	// we choose to flatten in the current node
+	values := *kid.(javascriptNameTree).out
+	*d.out = append(*d.out, values...)
+}
+
+func (d javascriptNameTree) resolveLeafValueAppend(r resolver, name string, value model.Object) error {
+	action, err := r.processAction(value)
+	if err != nil {
+		return err
+	}
+	if action.ActionType == nil { // ignore null or invalid values
+		return nil
+	}
+	*d.out = append(*d.out, model.NameToJavaScript{Name: name, Action: action})
+	return nil
+}
+
 type appearanceNameTree struct {
 	out *model.AppearanceTree // target which will be filled
 }
@@ -222,7 +248,7 @@ func (r resolver) processPageLabel(entry model.Object) (model.PageLabel, error)
 		out.S = s
 	}
 	p, _ := file.IsString(r.resolve(entryDict["P"]))
-	out.P = DecodeTextString(p)
+	out.P = r.decodeTextString(p)
 	out.St = 1 // default value
 	if st, ok := r.resolveInt(entryDict["St"]); ok {
 		out.St = st
@@ -251,10 +277,9 @@ func (d parentTree) resolveLeafValueAppend(r resolver, number int, value model.O
 	} else if array, ok := value.(model.ObjArray); ok {
 		parent.Parents = make([]*model.StructureElement, 0, len(array))
 		for _, p := range array {
-			ref, ok := p.(model.ObjIndirectRef)
-			if !ok { // invalid: ignore
-				continue
-			}
+			// null (or invalid) entries are kept as nil,
+			// since the array is indexed by MCID
+			ref, _ := p.(model.ObjIndirectRef)
 			parent.Parents = append(parent.Parents, r.structure[ref])
 		}
 	} else {
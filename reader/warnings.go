@@ -0,0 +1,71 @@
+package reader
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/benoitkugler/pdf/model"
+)
+
+// Mode controls the handling of the invalid constructs
+// which may be repaired or ignored, such as a Widths array with a wrong length.
+type Mode uint8
+
+const (
+	// Lenient repairs or ignores the recoverable issues,
+	// which are reported as warnings (see Options.Warnings).
+	Lenient Mode = iota
+	// Strict rejects the files with recoverable issues:
+	// the parsing functions then return an error describing the first one.
+	Strict
+)
+
+// Warning is a recoverable issue found when reading a file.
+type Warning struct {
+	Message string
+	// Context is given as alternating keys and values, such as "object", 12.
+	Context []interface{}
+}
+
+// String returns a one line description of the warning.
+func (w Warning) String() string {
+	return model.FormatLog(model.LogWarning, w.Message, w.Context...)
+}
+
+// warningCollector is a model.Logger recording the warnings,
+// and forwarding all the messages to `logger`
+type warningCollector struct {
+	logger model.Logger
+
+	mu       sync.Mutex // the pages may be resolved concurrently
+	warnings []Warning
+}
+
+func (wc *warningCollector) Log(level model.LogLevel, msg string, keysAndValues ...interface{}) {
+	if level == model.LogWarning {
+		wc.mu.Lock()
+		wc.warnings = append(wc.warnings, Warning{Message: msg, Context: keysAndValues})
+		wc.mu.Unlock()
+	}
+	wc.logger.Log(level, msg, keysAndValues...)
+}
+
+// collectWarnings returns a copy of `options` whose
+// logger records the warnings
+func (options Options) collectWarnings() (Options, *warningCollector) {
+	wc := &warningCollector{logger: model.LoggerOrDefault(options.Logger)}
+	options.Logger = wc
+	return options, wc
+}
+
+// reportWarnings fills `options.Warnings` and returns an error
+// in Strict mode if warnings were recorded
+func (options Options) reportWarnings(wc *warningCollector) error {
+	if options.Warnings != nil {
+		*options.Warnings = wc.warnings
+	}
+	if options.Mode == Strict && len(wc.warnings) != 0 {
+		return fmt.Errorf("strict mode: %s", wc.warnings[0])
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package reader
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/benoitkugler/pdf/model"
+)
+
+func TestWarnings(t *testing.T) {
+	data := buildPDF([]string{
+		"<</Type/Catalog/Pages 2 0 R>>",
+		"<</Type/Pages/Kids[3 0 R]/Count 1>>",
+		"<</Type/Page/Parent 2 0 R/MediaBox[0 0 100 100]/Resources<</Font<</F1 4 0 R/F2 5 0 R>>>>>>",
+		"<</Type/Font/Subtype/TrueType/BaseFont/Custom/FirstChar 32/LastChar 40/Widths[500 600]/FontDescriptor<</Type/FontDescriptor/FontName/Custom/Flags 32>>>>",
+		"<</Type/Font/Subtype/Type0/BaseFont/CID/Encoding/Identity-H/DescendantFonts[6 0 R]>>",
+		"<</Type/Font/Subtype/CIDFontType2/BaseFont/CID/CIDSystemInfo<</Registry(Adobe)/Ordering(Identity)/Supplement 0>>/FontDescriptor<</Type/FontDescriptor/FontName/CID/Flags 4>>/W[1 2 500 3]/W2[1 [1000 500]]>>",
+	})
+
+	var warnings []Warning
+	doc, _, err := ParsePDFReader(bytes.NewReader(data), Options{Logger: model.DiscardLogger, Warnings: &warnings})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 3 {
+		t.Fatalf("unexpected warnings %v", warnings)
+	}
+	for i, exp := range []string{"Widths", "W array", "W2"} {
+		if !strings.Contains(warnings[i].String(), exp) {
+			t.Fatalf("expected warning %d about %s, got %s", i, exp, warnings[i])
+		}
+	}
+	cid := doc.Catalog.Pages.Flatten()[0].Resources.Font["F2"].Subtype.(model.FontType0).DescendantFonts
+	if len(cid.W) != 1 {
+		t.Fatalf("expected the valid widths to be kept, got %v", cid.W)
+	}
+
+	_, _, err = ParsePDFReader(bytes.NewReader(data), Options{Logger: model.DiscardLogger, Mode: Strict})
+	if err == nil || !strings.Contains(err.Error(), "strict mode") {
+		t.Fatalf("expected strict mode error, got %v", err)
+	}
+
+	// valid files are accepted in strict mode
+	valid := buildPDF([]string{"<</Type/Catalog/Pages 2 0 R>>", "<</Type/Pages/Kids[]/Count 0>>"})
+	warnings = nil
+	if _, _, err = ParsePDFReader(bytes.NewReader(valid), Options{Mode: Strict, Warnings: &warnings}); err != nil || len(warnings) != 0 {
+		t.Fatalf("unexpected error %v and warnings %v", err, warnings)
+	}
+}
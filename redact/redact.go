@@ -0,0 +1,454 @@
+// Package redact implements a true redaction workflow:
+// the text and images covered by the redacted areas are
+// removed from the content streams, instead of being simply
+// hidden by a black box.
+//
+// The positions of the glyphs are computed from the font widths,
+// and the removed glyphs are replaced by positioning adjustments,
+// so that the rest of the text layout is preserved.
+package redact
+
+import (
+	"fmt"
+	"math"
+
+	cs "github.com/benoitkugler/pdf/contentstream"
+	"github.com/benoitkugler/pdf/fonts"
+	"github.com/benoitkugler/pdf/model"
+	"github.com/benoitkugler/pdf/reader/parser"
+)
+
+type Fl = model.Fl
+
+// maximum nesting of form XObjects
+const maxFormDepth = 20
+
+var identity = model.Matrix{1, 0, 0, 1, 0, 0}
+
+// Apply processes the Redact annotations of every page of `doc`:
+// the covered content is removed (see Page), the overlay described
+// by the annotation (RO, or IC) is drawn, and the annotation is removed.
+// Note that OverlayText is not supported.
+func Apply(doc *model.Document) error {
+	pages := doc.Catalog.Pages.Flatten()
+	inherited := doc.Catalog.Pages.FlattenInherit()
+	for index, page := range pages {
+		var (
+			annots  []*model.AnnotationDict
+			redacts []model.AnnotationRedact
+			rects   []model.Rectangle
+			areas   []model.Rectangle
+		)
+		for _, annot := range page.Annots {
+			if redact, ok := annot.Subtype.(model.AnnotationRedact); ok {
+				redacts = append(redacts, redact)
+				rects = append(rects, annot.Rect)
+				areas = append(areas, redact.Areas(annot.Rect)...)
+				continue
+			}
+			annots = append(annots, annot)
+		}
+		if len(redacts) == 0 {
+			continue
+		}
+
+		var res model.ResourcesDict
+		if inherited[index].Resources != nil {
+			res = *inherited[index].Resources
+		}
+		if err := Page(page, res, areas); err != nil {
+			return fmt.Errorf("page %d: %s", index, err)
+		}
+
+		var overlay []cs.Operation
+		for i, redact := range redacts {
+			overlay = append(overlay, overlayOps(page.Resources, redact, rects[i])...)
+		}
+		// the overlay appearances are already in the page resources
+		if err := page.AppendContent(cs.WriteOperations(overlay...), model.ResourcesDict{}); err != nil {
+			return fmt.Errorf("page %d: %s", index, err)
+		}
+		page.Annots = annots
+	}
+	return nil
+}
+
+// overlayOps returns the operations drawing the overlay of `redact`,
+// eventually adding its appearance to `res`
+func overlayOps(res *model.ResourcesDict, redact model.AnnotationRedact, rect model.Rectangle) []cs.Operation {
+	if ro := redact.RO; ro != nil {
+		box := ro.BBox
+		if box.Width() == 0 || box.Height() == 0 {
+			return nil
+		}
+		name := res.UnusedName("Overlay")
+		res.XObject[name] = ro
+		sx, sy := rect.Width()/box.Width(), rect.Height()/box.Height()
+		return []cs.Operation{
+			cs.OpSave{},
+			cs.OpConcat{Matrix: model.Matrix{sx, 0, 0, sy, rect.Llx - box.Llx*sx, rect.Lly - box.Lly*sy}},
+			cs.OpXObject{XObject: name},
+			cs.OpRestore{},
+		}
+	}
+
+	var color cs.Operation
+	switch ic := redact.IC; len(ic) {
+	case 1:
+		color = cs.OpSetFillGray{G: ic[0]}
+	case 3:
+		color = cs.OpSetFillRGBColor{R: ic[0], G: ic[1], B: ic[2]}
+	case 4:
+		color = cs.OpSetFillCMYKColor{C: ic[0], M: ic[1], Y: ic[2], K: ic[3]}
+	default: // no overlay
+		return nil
+	}
+	out := []cs.Operation{cs.OpSave{}, color}
+	for _, area := range redact.Areas(rect) {
+		area = area.Normalize()
+		out = append(out, cs.OpRectangle{X: area.Llx, Y: area.Lly, W: area.Width(), H: area.Height()})
+	}
+	return append(out, cs.OpFill{}, cs.OpRestore{})
+}
+
+// Page removes the text and images of `page` intersecting one of `areas`,
+// which are expressed in default user space.
+// `resources` are the resources used by the page (which may be inherited).
+//
+// The content of the page is parsed and rewritten, and its resources are
+// replaced by a copy, so that shared images and forms are not modified.
+// The glyphs intersecting the areas are removed, as well as inline images;
+// the pixels of images covered by the areas are cleared when the image format is
+// supported (8 bits per component, with a device color space and no lossy filter), otherwise
+// the whole image is removed. Forms are processed recursively.
+func Page(page *model.PageObject, resources model.ResourcesDict, areas []model.Rectangle) error {
+	content, err := page.DecodeAllContents()
+	if err != nil {
+		return err
+	}
+	res := resources.ShallowCopy()
+	r := redactor{areas: make([]model.Rectangle, len(areas)), fonts: map[*model.FontDict]fonts.Decoder{}}
+	for i, area := range areas {
+		r.areas[i] = area.Normalize()
+	}
+	ops, err := r.processContent(content, &res, identity, 0)
+	if err != nil {
+		return err
+	}
+	page.Contents = []model.ContentStream{{Stream: model.NewCompressedStream(cs.WriteOperations(ops...))}}
+	page.Resources = &res
+	return nil
+}
+
+type redactor struct {
+	areas []model.Rectangle
+	fonts map[*model.FontDict]fonts.Decoder
+}
+
+// state is the part of the graphic state used to locate the content
+type state struct {
+	ctm model.Matrix
+
+	font                          fonts.Decoder
+	fontSize                      Fl
+	charSpace, wordSpace, leading Fl
+	scale                         Fl // horizontal scaling, as a fraction
+	rise                          Fl
+}
+
+// text position, valid between BT and ET
+type textState struct {
+	tm, tlm model.Matrix
+}
+
+func (ts *textState) move(x, y Fl) {
+	ts.tlm = model.Matrix{1, 0, 0, 1, x, y}.Multiply(ts.tlm)
+	ts.tm = ts.tlm
+}
+
+func (r redactor) processContent(content []byte, res *model.ResourcesDict, ctm model.Matrix, depth int) ([]cs.Operation, error) {
+	ops, err := parser.ParseContent(content, res.ColorSpace)
+	if err != nil {
+		return nil, err
+	}
+
+	st := state{ctm: ctm, scale: 1}
+	var (
+		stack []state
+		text  textState
+		out   = make([]cs.Operation, 0, len(ops))
+	)
+	for _, op := range ops {
+		switch op := op.(type) {
+		case cs.OpSave:
+			stack = append(stack, st)
+		case cs.OpRestore:
+			if len(stack) != 0 {
+				st = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+		case cs.OpConcat:
+			st.ctm = op.Matrix.Multiply(st.ctm)
+		case cs.OpSetFont:
+			st.font = r.fontMetrics(res.Font[op.Font])
+			st.fontSize = op.Size
+		case cs.OpSetCharSpacing:
+			st.charSpace = op.CharSpace
+		case cs.OpSetWordSpacing:
+			st.wordSpace = op.WordSpace
+		case cs.OpSetTextLeading:
+			st.leading = op.L
+		case cs.OpSetHorizScaling:
+			st.scale = op.Scale / 100
+		case cs.OpSetTextRise:
+			st.rise = op.Rise
+		case cs.OpBeginText:
+			text = textState{tm: identity, tlm: identity}
+		case cs.OpSetTextMatrix:
+			text = textState{tm: op.Matrix, tlm: op.Matrix}
+		case cs.OpTextMove:
+			text.move(op.X, op.Y)
+		case cs.OpTextMoveSet:
+			st.leading = -op.Y
+			text.move(op.X, op.Y)
+		case cs.OpTextNextLine:
+			text.move(0, -st.leading)
+		case cs.OpShowText:
+			out = append(out, r.showText(st, &text, op, []fonts.TextSpaced{{CharCodes: []byte(op.Text)}}))
+			continue
+		case cs.OpShowSpaceText:
+			out = append(out, r.showText(st, &text, op, op.Texts))
+			continue
+		case cs.OpMoveShowText:
+			text.move(0, -st.leading)
+			shown := r.showText(st, &text, cs.OpShowText{Text: op.Text}, []fonts.TextSpaced{{CharCodes: []byte(op.Text)}})
+			if _, removed := shown.(cs.OpShowSpaceText); removed {
+				out = append(out, cs.OpTextNextLine{}, shown)
+				continue
+			}
+		case cs.OpMoveSetShowText:
+			st.wordSpace, st.charSpace = op.WordSpacing, op.CharacterSpacing
+			text.move(0, -st.leading)
+			shown := r.showText(st, &text, cs.OpShowText{Text: op.Text}, []fonts.TextSpaced{{CharCodes: []byte(op.Text)}})
+			if _, removed := shown.(cs.OpShowSpaceText); removed {
+				out = append(out, cs.OpSetWordSpacing{WordSpace: op.WordSpacing},
+					cs.OpSetCharSpacing{CharSpace: op.CharacterSpacing}, cs.OpTextNextLine{}, shown)
+				continue
+			}
+		case cs.OpBeginImage:
+			if r.intersects(unitSquare(st.ctm)) {
+				continue // remove the inline image
+			}
+		case cs.OpXObject:
+			replaced, keep, err := r.processXObject(res, op.XObject, st.ctm, depth)
+			if err != nil {
+				return nil, err
+			}
+			if !keep {
+				continue
+			}
+			out = append(out, cs.OpXObject{XObject: replaced})
+			continue
+		}
+		out = append(out, op)
+	}
+	return out, nil
+}
+
+// showText returns the operation replacing `op`, which shows `texts`,
+// and updates the text matrix.
+// If no glyph is removed, `op` is returned.
+func (r redactor) showText(st state, text *textState, op cs.Operation, texts []fonts.TextSpaced) cs.Operation {
+	var (
+		out      []fonts.TextSpaced
+		current  []byte
+		pending  Fl // adjustment in thousandths of text space unit
+		modified bool
+	)
+	size := st.fontSize * st.scale
+	// vertical extent of the glyphs, in text space
+	ascent, descent := st.font.Ascent*st.fontSize/1000+st.rise, st.font.Descent*st.fontSize/1000+st.rise
+	for _, ts := range texts {
+		for _, glyph := range st.font.Decode(ts.CharCodes) {
+			code := glyph.Code
+			w := glyph.Width * st.fontSize / 1000
+			tx := w + st.charSpace
+			if len(code) == 1 && code[0] == ' ' {
+				tx += st.wordSpace
+			}
+			tx *= st.scale
+
+			// glyph bounding box in user space
+			trm := model.Matrix{st.scale, 0, 0, 1, 0, 0}.Multiply(text.tm.Multiply(st.ctm))
+			box := model.Rectangle{Llx: 0, Lly: descent, Urx: w, Ury: ascent}.Transform(trm)
+			if w > 0 && size != 0 && r.intersects(box) {
+				modified = true
+				pending -= tx * 1000 / size
+			} else {
+				if pending != 0 {
+					out = append(out, fonts.TextSpaced{CharCodes: current, SpaceSubtractedAfter: int(math.Round(float64(pending)))})
+					current, pending = nil, 0
+				}
+				current = append(current, code...)
+			}
+			text.tm = model.Matrix{1, 0, 0, 1, tx, 0}.Multiply(text.tm)
+		}
+		if ts.SpaceSubtractedAfter != 0 {
+			pending += Fl(ts.SpaceSubtractedAfter)
+			text.tm = model.Matrix{1, 0, 0, 1, -Fl(ts.SpaceSubtractedAfter) / 1000 * size, 0}.Multiply(text.tm)
+		}
+	}
+	if !modified {
+		return op
+	}
+	out = append(out, fonts.TextSpaced{CharCodes: current, SpaceSubtractedAfter: int(math.Round(float64(pending)))})
+	return cs.OpShowSpaceText{Texts: out}
+}
+
+// processXObject returns the name of the XObject to use instead of `name`,
+// or false if it should be removed.
+func (r redactor) processXObject(res *model.ResourcesDict, name model.Name, ctm model.Matrix, depth int) (model.Name, bool, error) {
+	switch xObject := res.XObject[name].(type) {
+	case *model.XObjectImage:
+		box := unitSquare(ctm)
+		if !r.intersects(box) {
+			return name, true, nil
+		}
+		if r.covers(box) {
+			return "", false, nil
+		}
+		cleared, ok := r.clearImage(xObject, ctm)
+		if !ok {
+			return "", false, nil
+		}
+		newName := res.UnusedName("Redacted")
+		res.XObject[newName] = cleared
+		return newName, true, nil
+	case *model.XObjectForm:
+		matrix := xObject.Matrix
+		if matrix == (model.Matrix{}) {
+			matrix = identity
+		}
+		ctm = matrix.Multiply(ctm)
+		if !r.intersects(xObject.BBox.Transform(ctm)) {
+			return name, true, nil
+		}
+		if depth >= maxFormDepth {
+			return "", false, fmt.Errorf("maximum form nesting depth (%d) exceeded", maxFormDepth)
+		}
+		content, err := xObject.Decode()
+		if err != nil {
+			return "", false, err
+		}
+		form := *xObject // shallow copy
+		form.Resources = xObject.Resources.ShallowCopy()
+		ops, err := r.processContent(content, &form.Resources, ctm, depth+1)
+		if err != nil {
+			return "", false, err
+		}
+		form.ContentStream = model.ContentStream{Stream: model.NewCompressedStream(cs.WriteOperations(ops...))}
+		newName := res.UnusedName("Redacted")
+		res.XObject[newName] = &form
+		return newName, true, nil
+	default:
+		return name, true, nil
+	}
+}
+
+// clearImage returns a copy of `img` where the pixels covered by the
+// areas are cleared, or false if the image format is not supported
+func (r redactor) clearImage(img *model.XObjectImage, ctm model.Matrix) (*model.XObjectImage, bool) {
+	var comps int
+	switch img.ColorSpace {
+	case model.ColorSpaceGray:
+		comps = 1
+	case model.ColorSpaceRGB:
+		comps = 3
+	case model.ColorSpaceCMYK:
+		comps = 4
+	default:
+		return nil, false
+	}
+	if img.BitsPerComponent != 8 || img.ImageMask {
+		return nil, false
+	}
+	for _, filter := range img.Filter {
+		switch filter.Name {
+		case model.Flate, model.LZW, model.RunLength, model.ASCIIHex, model.ASCII85:
+		default: // lossy or unsupported filter
+			return nil, false
+		}
+	}
+	pixels, err := img.Stream.Decode()
+	if err != nil || len(pixels) < img.Width*img.Height*comps {
+		return nil, false
+	}
+
+	for j := 0; j < img.Height; j++ {
+		// the first row is at the top of the unit square
+		v := 1 - (Fl(j)+0.5)/Fl(img.Height)
+		for i := 0; i < img.Width; i++ {
+			u := (Fl(i) + 0.5) / Fl(img.Width)
+			x, y := ctm.TransformPoint(u, v)
+			if !r.contains(x, y) {
+				continue
+			}
+			sample := pixels[(j*img.Width+i)*comps : (j*img.Width+i+1)*comps]
+			for c := range sample {
+				sample[c] = 0
+			}
+			if comps == 4 { // black in CMYK
+				sample[3] = 0xFF
+			}
+		}
+	}
+
+	out := *img // shallow copy
+	out.Stream = model.NewCompressedStream(pixels)
+	return &out, true
+}
+
+// fontMetrics returns the (cached) metrics of `font`.
+func (r redactor) fontMetrics(font *model.FontDict) fonts.Decoder {
+	if f, ok := r.fonts[font]; ok {
+		return f
+	}
+	f := fonts.NewDecoder(font)
+	r.fonts[font] = f
+	return f
+}
+
+// intersects returns true if `box` overlaps one of the areas
+func (r redactor) intersects(box model.Rectangle) bool {
+	for _, area := range r.areas {
+		if box.Llx < area.Urx && area.Llx < box.Urx && box.Lly < area.Ury && area.Lly < box.Ury {
+			return true
+		}
+	}
+	return false
+}
+
+// covers returns true if `box` is inside one of the areas
+func (r redactor) covers(box model.Rectangle) bool {
+	for _, area := range r.areas {
+		if area.Llx <= box.Llx && box.Urx <= area.Urx && area.Lly <= box.Lly && box.Ury <= area.Ury {
+			return true
+		}
+	}
+	return false
+}
+
+// contains returns true if the point is inside one of the areas
+func (r redactor) contains(x, y Fl) bool {
+	for _, area := range r.areas {
+		if area.Contains(x, y) {
+			return true
+		}
+	}
+	return false
+}
+
+// unitSquare returns the bounding box of an image drawn with `ctm`
+func unitSquare(ctm model.Matrix) model.Rectangle {
+	return model.Rectangle{Urx: 1, Ury: 1}.Transform(ctm)
+}
@@ -0,0 +1,124 @@
+package redact
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/benoitkugler/pdf/fonts/standardfonts"
+	"github.com/benoitkugler/pdf/model"
+	"github.com/benoitkugler/pdf/reader"
+)
+
+func newPage(content string, res model.ResourcesDict) *model.PageObject {
+	return &model.PageObject{
+		MediaBox:  &model.Rectangle{Urx: 600, Ury: 800},
+		Resources: &res,
+		Contents:  []model.ContentStream{{Stream: model.Stream{Content: []byte(content)}}},
+	}
+}
+
+func decodeContent(t *testing.T, page *model.PageObject) string {
+	content, err := page.DecodeAllContents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestText(t *testing.T) {
+	res := model.NewResourcesDict()
+	res.Font["F1"] = &model.FontDict{Subtype: standardfonts.Helvetica.WesternType1Font()}
+	page := newPage("BT /F1 12 Tf 100 700 Td (Secret word) Tj 0 -20 Td (Other line) Tj ET", res)
+
+	if err := Page(page, *page.Resources, []model.Rectangle{{Llx: 99, Lly: 690, Urx: 135, Ury: 715}}); err != nil {
+		t.Fatal(err)
+	}
+	content := decodeContent(t, page)
+	if strings.Contains(content, "Secret") {
+		t.Fatalf("text should be removed: %s", content)
+	}
+	// the removed glyphs (Secret and the space) are replaced by an adjustment
+	if !strings.Contains(content, "()-3168(word)]TJ") {
+		t.Fatalf("unexpected content %s", content)
+	}
+	if !strings.Contains(content, "(Other line)Tj") {
+		t.Fatalf("line outside the area should be preserved: %s", content)
+	}
+}
+
+func TestImage(t *testing.T) {
+	img := &model.XObjectImage{ColorSpace: model.ColorSpaceRGB}
+	img.Width, img.Height, img.BitsPerComponent = 2, 1, 8
+	img.Content = []byte{255, 255, 255, 255, 255, 255}
+	res := model.NewResourcesDict()
+	res.XObject["Im0"] = img
+	page := newPage("q 100 0 0 100 0 0 cm /Im0 Do Q q 10 0 0 10 300 300 cm /Im0 Do Q", res)
+
+	if err := Page(page, *page.Resources, []model.Rectangle{{Llx: 0, Lly: 0, Urx: 50, Ury: 100}, {Llx: 290, Lly: 290, Urx: 320, Ury: 320}}); err != nil {
+		t.Fatal(err)
+	}
+	content := decodeContent(t, page)
+	if strings.Contains(content, "/Im0 Do") || strings.Count(content, "/Redacted0 Do") != 1 {
+		t.Fatalf("unexpected content %s", content)
+	}
+	if !bytes.Equal(img.Content, []byte{255, 255, 255, 255, 255, 255}) {
+		t.Fatal("original image should not be modified")
+	}
+	cleared := page.Resources.XObject["Redacted0"].(*model.XObjectImage)
+	pixels, err := cleared.Stream.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(pixels, []byte{0, 0, 0, 255, 255, 255}) {
+		t.Fatalf("unexpected pixels %v", pixels)
+	}
+	if len(res.XObject) != 1 {
+		t.Fatal("original resources should not be modified")
+	}
+}
+
+func TestApply(t *testing.T) {
+	res := model.NewResourcesDict()
+	res.Font["F1"] = &model.FontDict{Subtype: standardfonts.Helvetica.WesternType1Font()}
+	page := newPage("BT /F1 12 Tf 100 700 Td (Secret) Tj ET", res)
+	var redact model.AnnotationRedact
+	redact.IC = []Fl{0}
+	redact.QuadPoints = []Fl{99, 715, 140, 715, 99, 690, 140, 690}
+	page.Annots = []*model.AnnotationDict{
+		{BaseAnnotation: model.BaseAnnotation{Rect: model.Rectangle{Llx: 99, Lly: 690, Urx: 140, Ury: 715}}, Subtype: redact},
+	}
+	var doc model.Document
+	doc.Catalog.Pages.Kids = []model.PageNode{page}
+
+	// check the annotation round trip
+	var buf bytes.Buffer
+	if err := doc.Write(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	read, _, err := reader.ParsePDFReader(bytes.NewReader(buf.Bytes()), reader.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	readPage := read.Catalog.Pages.Flatten()[0]
+	if len(readPage.Annots) != 1 {
+		t.Fatalf("unexpected annotations %v", readPage.Annots)
+	}
+	if got, ok := readPage.Annots[0].Subtype.(model.AnnotationRedact); !ok || len(got.QuadPoints) != 8 || len(got.IC) != 1 {
+		t.Fatalf("unexpected annotation %v", readPage.Annots[0].Subtype)
+	}
+
+	if err := Apply(&read); err != nil {
+		t.Fatal(err)
+	}
+	if len(readPage.Annots) != 0 {
+		t.Fatal("redact annotation should be removed")
+	}
+	content := decodeContent(t, readPage)
+	if strings.Contains(content, "Secret") {
+		t.Fatalf("text should be removed: %s", content)
+	}
+	if !strings.Contains(content, "99 690 41 25 re f") {
+		t.Fatalf("missing overlay in %s", content)
+	}
+}
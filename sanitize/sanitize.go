@@ -0,0 +1,568 @@
+// Package sanitize removes the private or active content of a document,
+// such as metadata, embedded files or scripts, which is a frequent
+// requirement before publishing PDF files.
+//
+// The content to remove is selected by a Policy, and the document
+// is modified in place.
+package sanitize
+
+import (
+	"fmt"
+
+	cs "github.com/benoitkugler/pdf/contentstream"
+	"github.com/benoitkugler/pdf/model"
+	"github.com/benoitkugler/pdf/reader/parser"
+)
+
+// Policy selects the content removed by Sanitize.
+type Policy struct {
+	// Metadata removes the document information dictionary
+	// and the XMP metadata of the document, as well as the entries
+	// not supported by the model (see the Custom fields) of the trailer, catalog, pages,
+	// annotations and fonts, which store private data, like the page Metadata and PieceInfo.
+	Metadata bool
+	// EmbeddedFiles removes the attached files (Names.EmbeddedFiles),
+	// the associated files (AF) of the document, pages and annotations,
+	// the portable collection and the file attachment annotations.
+	EmbeddedFiles bool
+	// JavaScript removes the document-level scripts and the JavaScript
+	// actions, preserving the actions chained after them (see model.Document.StripScripts).
+	JavaScript bool
+	// HiddenLayers removes the content of the optional content groups
+	// which are hidden in the default configuration : marked-content
+	// sequences, XObjects and annotations (form widgets excepted), including
+	// in the forms, tiling patterns and Type3 glyphs.
+	// The groups no longer used are removed from the optional content properties,
+	// so that the groups of the kept widgets and appearances stay hidden.
+	HiddenLayers bool
+	// AnnotationAuthors removes the author (T entry) of the markup
+	// and file attachment annotations.
+	AnnotationAuthors bool
+}
+
+// All is the policy removing all the supported content.
+var All = Policy{
+	Metadata:          true,
+	EmbeddedFiles:     true,
+	JavaScript:        true,
+	HiddenLayers:      true,
+	AnnotationAuthors: true,
+}
+
+// Sanitize modifies `doc` in place, removing the content selected by `policy`.
+// An error is only returned if a content stream is invalid.
+func Sanitize(doc *model.Document, policy Policy) error {
+	if policy.Metadata {
+		doc.Trailer.Info = model.Info{}
+		doc.Catalog.Metadata = nil
+		removeCustom(doc)
+	}
+	if policy.JavaScript {
+		doc.StripScripts()
+		doc.Catalog.Names.JavaScript = nil
+	}
+	if policy.EmbeddedFiles {
+		removeEmbeddedFiles(doc)
+	}
+	if policy.HiddenLayers && doc.Catalog.OCProperties != nil {
+		if err := removeHiddenLayers(doc); err != nil {
+			return err
+		}
+	}
+	if policy.AnnotationAuthors {
+		for _, page := range doc.Catalog.Pages.Flatten() {
+			for _, annot := range page.Annots {
+				removeAuthor(annot)
+			}
+		}
+	}
+	return nil
+}
+
+// removeCustom clears the Custom entries of the trailer, catalog,
+// pages, annotations (including the form widgets) and fonts
+func removeCustom(doc *model.Document) {
+	doc.Trailer.Custom = nil
+	doc.Catalog.Custom = nil
+
+	forms := map[*model.XObjectForm]bool{}
+	var walkResources func(res *model.ResourcesDict)
+	walkForm := func(form *model.XObjectForm) {
+		if form == nil || forms[form] {
+			return
+		}
+		forms[form] = true
+		walkResources(&form.Resources)
+	}
+	walkResources = func(res *model.ResourcesDict) {
+		if res == nil {
+			return
+		}
+		for _, font := range res.Font {
+			if font != nil {
+				font.Custom = nil
+			}
+		}
+		for _, xObject := range res.XObject {
+			if form, ok := xObject.(*model.XObjectForm); ok {
+				walkForm(form)
+			}
+		}
+	}
+	clearAnnotation := func(annot *model.AnnotationDict) {
+		if annot == nil {
+			return
+		}
+		annot.Custom = nil
+		if ap := annot.AP; ap != nil {
+			for _, entry := range [...]model.AppearanceEntry{ap.N, ap.R, ap.D} {
+				for _, form := range entry {
+					walkForm(form)
+				}
+			}
+		}
+	}
+
+	inherited := doc.Catalog.Pages.FlattenInherit()
+	for index, page := range doc.Catalog.Pages.Flatten() {
+		page.Custom = nil
+		walkResources(inherited[index].Resources)
+		for _, annot := range page.Annots {
+			clearAnnotation(annot)
+		}
+	}
+
+	acroForm := &doc.Catalog.AcroForm
+	walkResources(&acroForm.DR)
+	var walkField func(field *model.FormFieldDict)
+	walkField = func(field *model.FormFieldDict) {
+		for _, widget := range field.Widgets {
+			clearAnnotation(widget.AnnotationDict)
+		}
+		for _, kid := range field.Kids {
+			walkField(kid)
+		}
+	}
+	for _, field := range acroForm.Fields {
+		walkField(field)
+	}
+}
+
+func removeEmbeddedFiles(doc *model.Document) {
+	cat := &doc.Catalog
+	cat.Names.EmbeddedFiles = nil
+	cat.AF = nil
+	cat.Collection = nil
+	for _, page := range cat.Pages.Flatten() {
+		page.AF = nil
+		annots := page.Annots[:0:0]
+		for _, annot := range page.Annots {
+			if _, isFile := annot.Subtype.(model.AnnotationFileAttachment); isFile {
+				continue
+			}
+			annot.AF = nil
+			annots = append(annots, annot)
+		}
+		page.Annots = annots
+	}
+}
+
+// removeAuthor clears the T entry of the annotations
+// for which it stores the author.
+func removeAuthor(annot *model.AnnotationDict) {
+	switch st := annot.Subtype.(type) {
+	case model.AnnotationText:
+		st.T = ""
+		annot.Subtype = st
+	case model.AnnotationFreeText:
+		st.T = ""
+		annot.Subtype = st
+	case model.AnnotationLine:
+		st.T = ""
+		annot.Subtype = st
+	case model.AnnotationSquare:
+		st.T = ""
+		annot.Subtype = st
+	case model.AnnotationRedact:
+		st.T = ""
+		annot.Subtype = st
+	case model.AnnotationPolygon:
+		st.T = ""
+		annot.Subtype = st
+	case model.AnnotationHighlight:
+		st.T = ""
+		annot.Subtype = st
+	case model.AnnotationCaret:
+		st.T = ""
+		annot.Subtype = st
+	case model.AnnotationStamp:
+		st.T = ""
+		annot.Subtype = st
+	case model.AnnotationInk:
+		st.T = ""
+		annot.Subtype = st
+	case model.AnnotationSound:
+		st.T = ""
+		annot.Subtype = st
+	case model.AnnotationFileAttachment:
+		st.T = ""
+		annot.Subtype = st
+	case model.AnnotationCustom:
+		delete(annot.Custom, "T")
+	}
+}
+
+// ------------------------- optional content -------------------------
+
+// layers stores the visibility of the optional content groups
+type layers struct {
+	on map[*model.OptionalContentGroup]bool // visibility in the default configuration
+	// groups referenced by visible memberships or kept objects, which must be kept
+	used map[*model.OptionalContentGroup]bool
+
+	processed map[interface{}]bool          // forms, tiling patterns and Type3 fonts
+	resources map[*model.ResourcesDict]bool // to prune once all the content is processed
+}
+
+func newLayers(props *model.OptionalContentProperties) *layers {
+	l := &layers{
+		on:        make(map[*model.OptionalContentGroup]bool),
+		used:      make(map[*model.OptionalContentGroup]bool),
+		processed: make(map[interface{}]bool),
+		resources: make(map[*model.ResourcesDict]bool),
+	}
+	base := props.D.BaseState != "OFF"
+	for _, group := range props.OCGs {
+		l.on[group] = base
+	}
+	for _, group := range props.D.ON {
+		l.on[group] = true
+	}
+	for _, group := range props.D.OFF {
+		l.on[group] = false
+	}
+	return l
+}
+
+// isHidden returns true if `oc` is hidden in the default configuration.
+// The groups not listed in the optional content properties are considered visible.
+func (l *layers) isHidden(oc model.OptionalContent) bool {
+	switch oc := oc.(type) {
+	case *model.OptionalContentGroup:
+		on, ok := l.on[oc]
+		return ok && !on
+	case *model.OptionalContentMembership:
+		if oc == nil || len(oc.OCGs) == 0 {
+			return false
+		}
+		anyOn, allOn := false, true
+		for _, group := range oc.OCGs {
+			visible := !l.isHidden(group)
+			anyOn = anyOn || visible
+			allOn = allOn && visible
+		}
+		var visible bool
+		switch oc.P {
+		case "AllOn":
+			visible = allOn
+		case "AnyOff":
+			visible = !allOn
+		case "AllOff":
+			visible = !anyOn
+		default: // AnyOn
+			visible = anyOn
+		}
+		if visible {
+			for _, group := range oc.OCGs {
+				l.used[group] = true
+			}
+		}
+		return !visible
+	default:
+		return false
+	}
+}
+
+// markUsed records the groups referenced by `oc`, so that
+// they are not removed from the optional content properties
+func (l *layers) markUsed(oc model.OptionalContent) {
+	switch oc := oc.(type) {
+	case *model.OptionalContentGroup:
+		if oc != nil {
+			l.used[oc] = true
+		}
+	case *model.OptionalContentMembership:
+		if oc != nil {
+			for _, group := range oc.OCGs {
+				l.used[group] = true
+			}
+		}
+	}
+}
+
+func (l *layers) isHiddenXObject(xObject model.XObject) bool {
+	switch xObject := xObject.(type) {
+	case *model.XObjectForm:
+		return xObject != nil && l.isHidden(xObject.OC)
+	case *model.XObjectImage:
+		return xObject != nil && l.isHidden(xObject.OC)
+	default:
+		return false
+	}
+}
+
+// filterContent removes the hidden marked-content sequences and XObjects
+// of `content`, returning false if nothing is removed
+func (l *layers) filterContent(content []byte, res *model.ResourcesDict) ([]byte, bool, error) {
+	ops, err := parser.ParseContent(content, res.ColorSpace)
+	if err != nil {
+		return nil, false, err
+	}
+	var (
+		out     []cs.Operation
+		changed bool
+		hidden  int // depth inside a hidden sequence
+	)
+	for _, op := range ops {
+		if hidden != 0 {
+			switch op.(type) {
+			case cs.OpBeginMarkedContent:
+				hidden++
+			case cs.OpEndMarkedContent:
+				hidden--
+			}
+			continue
+		}
+		switch op := op.(type) {
+		case cs.OpBeginMarkedContent:
+			if name, ok := op.Properties.(cs.PropertyListName); ok && op.Tag == "OC" &&
+				l.isHidden(res.OptionalContents[model.Name(name)]) {
+				hidden, changed = 1, true
+				continue
+			}
+		case cs.OpXObject:
+			if l.isHiddenXObject(res.XObject[model.Name(op.XObject)]) {
+				changed = true
+				continue
+			}
+		}
+		out = append(out, op)
+	}
+	if !changed {
+		return nil, false, nil
+	}
+	return cs.WriteOperations(out...), true, nil
+}
+
+// processResources filters the content of the form XObjects,
+// tiling patterns and Type3 fonts of `res`
+func (l *layers) processResources(res *model.ResourcesDict) error {
+	if res == nil || l.resources[res] {
+		return nil
+	}
+	l.resources[res] = true
+	for name, xObject := range res.XObject {
+		form, ok := xObject.(*model.XObjectForm)
+		if !ok || l.isHiddenXObject(form) {
+			continue
+		}
+		if err := l.processForm(form); err != nil {
+			return fmt.Errorf("XObject %s: %s", name, err)
+		}
+	}
+	for name, pattern := range res.Pattern {
+		tiling, ok := pattern.(*model.PatternTiling)
+		if !ok || tiling == nil || l.processed[tiling] {
+			continue
+		}
+		l.processed[tiling] = true
+		if err := l.filterStream(&tiling.ContentStream, &tiling.Resources); err != nil {
+			return fmt.Errorf("pattern %s: %s", name, err)
+		}
+	}
+	for name, font := range res.Font {
+		if font == nil || l.processed[font] {
+			continue
+		}
+		l.processed[font] = true
+		if err := l.processType3(font); err != nil {
+			return fmt.Errorf("font %s: %s", name, err)
+		}
+	}
+	return nil
+}
+
+// filterStream filters `stream` in place, and processes `res`
+func (l *layers) filterStream(stream *model.ContentStream, res *model.ResourcesDict) error {
+	content, err := stream.Decode()
+	if err != nil {
+		return err
+	}
+	filtered, changed, err := l.filterContent(content, res)
+	if err != nil {
+		return err
+	}
+	if changed {
+		*stream = model.ContentStream{Stream: model.NewCompressedStream(filtered)}
+	}
+	return l.processResources(res)
+}
+
+func (l *layers) processForm(form *model.XObjectForm) error {
+	if form == nil || l.processed[form] {
+		return nil
+	}
+	l.processed[form] = true
+	return l.filterStream(&form.ContentStream, &form.Resources)
+}
+
+// processType3 filters the glyph procedures of Type3 fonts
+func (l *layers) processType3(font *model.FontDict) error {
+	type3, ok := font.Subtype.(model.FontType3)
+	if !ok {
+		return nil
+	}
+	for name, proc := range type3.CharProcs {
+		if err := l.filterStream(&proc, &type3.Resources); err != nil {
+			return fmt.Errorf("glyph %s: %s", name, err)
+		}
+		type3.CharProcs[name] = proc
+	}
+	// the resources maps are shared with the font
+	font.Subtype = type3
+	return nil
+}
+
+// prune removes the hidden XObjects and optional contents
+// from the resources, once they are not referenced anymore
+func (l *layers) prune() {
+	for res := range l.resources {
+		for name, xObject := range res.XObject {
+			if l.isHiddenXObject(xObject) {
+				delete(res.XObject, name)
+			}
+		}
+		for name, oc := range res.OptionalContents {
+			if l.isHidden(oc) {
+				delete(res.OptionalContents, name)
+			}
+		}
+	}
+}
+
+func removeHiddenLayers(doc *model.Document) error {
+	l := newLayers(doc.Catalog.OCProperties)
+	pages := doc.Catalog.Pages.Flatten()
+	inherited := doc.Catalog.Pages.FlattenInherit()
+	for index, page := range pages {
+		res := inherited[index].Resources
+		if res == nil {
+			res = &model.ResourcesDict{}
+		}
+		content, err := page.DecodeAllContents()
+		if err != nil {
+			return fmt.Errorf("page %d: %s", index, err)
+		}
+		filtered, changed, err := l.filterContent(content, res)
+		if err != nil {
+			return fmt.Errorf("page %d: %s", index, err)
+		}
+		if changed {
+			page.Contents = []model.ContentStream{{Stream: model.NewCompressedStream(filtered)}}
+		}
+		if err := l.processResources(res); err != nil {
+			return fmt.Errorf("page %d: %s", index, err)
+		}
+
+		annots := page.Annots[:0:0]
+		for _, annot := range page.Annots {
+			if _, isWidget := annot.Subtype.(model.AnnotationWidget); !isWidget && l.isHidden(annot.OC) {
+				continue
+			}
+			// hidden widgets are kept, as well as their groups
+			l.markUsed(annot.OC)
+			if ap := annot.AP; ap != nil {
+				for _, entry := range [...]model.AppearanceEntry{ap.N, ap.R, ap.D} {
+					for _, form := range entry {
+						if form != nil {
+							l.markUsed(form.OC)
+						}
+						if err := l.processForm(form); err != nil {
+							return fmt.Errorf("page %d: annotation appearance: %s", index, err)
+						}
+					}
+				}
+			}
+			annots = append(annots, annot)
+		}
+		page.Annots = annots
+	}
+	l.prune()
+	l.pruneProperties(doc.Catalog.OCProperties)
+	return nil
+}
+
+// ------------------------- optional content properties -------------------------
+
+// isRemoved returns true for the hidden groups not used by visible memberships
+func (l *layers) isRemoved(group *model.OptionalContentGroup) bool {
+	return l.isHidden(group) && !l.used[group]
+}
+
+func (l *layers) filterGroups(groups []*model.OptionalContentGroup) []*model.OptionalContentGroup {
+	if groups == nil { // preserve nil
+		return nil
+	}
+	out := groups[:0:0]
+	for _, group := range groups {
+		if !l.isRemoved(group) {
+			out = append(out, group)
+		}
+	}
+	return out
+}
+
+func (l *layers) filterOrder(items []model.OptionalContentOrder) []model.OptionalContentOrder {
+	if items == nil { // preserve nil
+		return nil
+	}
+	out := items[:0:0]
+	for _, item := range items {
+		if item.Group != nil {
+			if !l.isRemoved(item.Group) {
+				out = append(out, item)
+			}
+			continue
+		}
+		item.Kids = l.filterOrder(item.Kids)
+		if len(item.Kids) != 0 {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func (l *layers) filterConfig(config *model.OptionalContentConfig) {
+	config.ON = l.filterGroups(config.ON)
+	config.OFF = l.filterGroups(config.OFF)
+	config.Order = l.filterOrder(config.Order)
+	config.Locked = l.filterGroups(config.Locked)
+	if config.RBGroups != nil {
+		groups := config.RBGroups[:0:0]
+		for _, group := range config.RBGroups {
+			if group = l.filterGroups(group); len(group) != 0 {
+				groups = append(groups, group)
+			}
+		}
+		config.RBGroups = groups
+	}
+}
+
+// pruneProperties removes the unused hidden groups from `props`
+func (l *layers) pruneProperties(props *model.OptionalContentProperties) {
+	props.OCGs = l.filterGroups(props.OCGs)
+	l.filterConfig(&props.D)
+	for i := range props.Configs {
+		l.filterConfig(&props.Configs[i])
+	}
+}
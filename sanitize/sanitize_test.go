@@ -0,0 +1,229 @@
+package sanitize
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/benoitkugler/pdf/model"
+)
+
+func pageContent(t *testing.T, page *model.PageObject) string {
+	content, err := page.DecodeAllContents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Join(strings.Fields(string(content)), " ")
+}
+
+func writeDocument(t *testing.T, doc model.Document) {
+	var b bytes.Buffer
+	if err := doc.Write(&b, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMetadataAndFiles(t *testing.T) {
+	var doc model.Document
+	page := &model.PageObject{MediaBox: &model.Rectangle{Urx: 100, Ury: 100}}
+	doc.Catalog.Pages.Kids = []model.PageNode{page}
+	doc.Trailer.Info = model.Info{Author: "John", Title: "Secret"}
+	doc.Catalog.Metadata = &model.MetadataStream{Stream: model.Stream{Content: []byte("<x:xmpmeta/>")}}
+	doc.Catalog.Custom = model.ObjDict{"PrivateKey": model.ObjStringLiteral("secret")}
+	page.Custom = model.ObjDict{"PieceInfo": model.ObjDict{}, "Metadata": model.ObjStringLiteral("<x:xmpmeta/>")}
+	font := &model.FontDict{Subtype: model.FontType1{BaseFont: "Helvetica"}, Custom: model.ObjDict{"Private": model.ObjInt(1)}}
+	page.Resources = &model.ResourcesDict{Font: map[model.Name]*model.FontDict{"F1": font}}
+	doc.AddAssociatedFile("invoice.xml", []byte("<invoice/>"), "text/xml", "", "Data")
+	fs := doc.AttachFile("notes.txt", []byte("notes"), "text/plain", "")
+	page.AF = []*model.FileSpec{fs}
+	note := &model.AnnotationDict{
+		BaseAnnotation: model.BaseAnnotation{AF: []*model.FileSpec{fs}, Custom: model.ObjDict{"Private": model.ObjInt(1)}},
+		Subtype:        model.AnnotationText{AnnotationMarkup: model.AnnotationMarkup{T: "John"}},
+	}
+	page.Annots = []*model.AnnotationDict{
+		note,
+		{Subtype: model.AnnotationFileAttachment{T: "John", FS: fs}},
+	}
+
+	// nothing to do
+	if err := Sanitize(&doc, Policy{}); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Trailer.Info.Author != "John" || len(doc.Catalog.Names.EmbeddedFiles) != 2 || len(page.Annots) != 2 {
+		t.Fatal("unexpected modification")
+	}
+
+	if err := Sanitize(&doc, Policy{Metadata: true, EmbeddedFiles: true, AnnotationAuthors: true}); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Trailer.Info != (model.Info{}) || doc.Catalog.Metadata != nil {
+		t.Fatal("metadata not removed")
+	}
+	if doc.Catalog.Custom != nil || page.Custom != nil || note.Custom != nil || font.Custom != nil {
+		t.Fatal("private entries not removed")
+	}
+	if len(doc.Catalog.Names.EmbeddedFiles) != 0 || len(doc.Catalog.AF) != 0 || len(page.AF) != 0 {
+		t.Fatal("embedded files not removed")
+	}
+	if len(page.Annots) != 1 || page.Annots[0] != note || len(note.AF) != 0 {
+		t.Fatalf("unexpected annotations %v", page.Annots)
+	}
+	if note.Subtype.(model.AnnotationText).T != "" {
+		t.Fatal("author not removed")
+	}
+	writeDocument(t, doc)
+}
+
+func TestJavaScript(t *testing.T) {
+	var doc model.Document
+	page := &model.PageObject{MediaBox: &model.Rectangle{Urx: 100, Ury: 100}}
+	doc.Catalog.Pages.Kids = []model.PageNode{page}
+	js := model.Action{ActionType: model.ActionJavaScript{JS: "app.alert('hello')"}}
+	doc.Catalog.OpenAction = model.Action{
+		ActionType: model.ActionJavaScript{JS: "this.print()"},
+		Next:       []model.Action{{ActionType: model.ActionURI{URI: "https://example.com"}}},
+	}
+	doc.Catalog.Names.JavaScript = model.JavaScriptTree{{Name: "init", Action: js}}
+	page.AA.O = js
+
+	if err := Sanitize(&doc, All); err != nil {
+		t.Fatal(err)
+	}
+	if scripts := doc.Scripts(); len(scripts) != 0 {
+		t.Fatalf("unexpected scripts %v", scripts)
+	}
+	if _, ok := doc.Catalog.OpenAction.ActionType.(model.ActionURI); !ok {
+		t.Fatalf("chained action should be preserved, got %v", doc.Catalog.OpenAction)
+	}
+	if len(doc.Catalog.Names.JavaScript) != 0 {
+		t.Fatal("document-level scripts not removed")
+	}
+	writeDocument(t, doc)
+}
+
+func TestHiddenLayers(t *testing.T) {
+	visible := &model.OptionalContentGroup{Name: "Visible"}
+	hidden := &model.OptionalContentGroup{Name: "Draft"}
+	// visible, since `visible` is on: `hidden` must be kept
+	membership := &model.OptionalContentMembership{OCGs: []*model.OptionalContentGroup{visible, hidden}}
+	var doc model.Document
+	doc.Catalog.OCProperties = &model.OptionalContentProperties{
+		OCGs: []*model.OptionalContentGroup{visible, hidden},
+		D: model.OptionalContentConfig{
+			OFF:   []*model.OptionalContentGroup{hidden},
+			Order: []model.OptionalContentOrder{{Group: visible}, {Label: "Drafts", Kids: []model.OptionalContentOrder{{Group: hidden}}}},
+		},
+	}
+
+	hiddenImage := &model.XObjectImage{OC: hidden}
+	form := &model.XObjectForm{
+		ContentStream: model.ContentStream{Stream: model.Stream{Content: []byte("/OC /D BDC 0 0 m 1 1 l S EMC /Im Do")}},
+		Resources: model.ResourcesDict{
+			OptionalContents: map[model.Name]model.OptionalContent{"D": hidden},
+			XObject:          map[model.Name]model.XObject{"Im": hiddenImage},
+		},
+	}
+	res := &model.ResourcesDict{
+		OptionalContents: map[model.Name]model.OptionalContent{"V": visible, "D": hidden},
+		XObject: map[model.Name]model.XObject{
+			"Fm": form,
+			"Im": hiddenImage,
+		},
+	}
+	page := &model.PageObject{
+		MediaBox:  &model.Rectangle{Urx: 100, Ury: 100},
+		Resources: res,
+		Contents: []model.ContentStream{{Stream: model.Stream{Content: []byte(
+			"/OC /V BDC 1 0 0 rg EMC /OC /D BDC /Span BMC 0 g EMC EMC /Fm Do /Im Do",
+		)}}},
+	}
+	keptAnnot := &model.AnnotationDict{BaseAnnotation: model.BaseAnnotation{OC: membership}, Subtype: model.AnnotationSquare{}}
+	page.Annots = []*model.AnnotationDict{
+		{BaseAnnotation: model.BaseAnnotation{OC: hidden}, Subtype: model.AnnotationSquare{}},
+		keptAnnot,
+	}
+	doc.Catalog.Pages.Kids = []model.PageNode{page}
+
+	if err := Sanitize(&doc, Policy{HiddenLayers: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got := pageContent(t, page); got != "/OC /V BDC 1 0 0 rg EMC /Fm Do" {
+		t.Fatalf("unexpected page content %s", got)
+	}
+	if content, _ := form.Decode(); strings.TrimSpace(string(content)) != "" {
+		t.Fatalf("unexpected form content %s", content)
+	}
+	if len(res.XObject) != 1 || len(form.Resources.XObject) != 0 {
+		t.Fatal("hidden XObjects not removed")
+	}
+	if len(page.Annots) != 1 || page.Annots[0] != keptAnnot {
+		t.Fatalf("unexpected annotations %v", page.Annots)
+	}
+	// `hidden` is still used by the membership
+	if len(doc.Catalog.OCProperties.OCGs) != 2 {
+		t.Fatal("group used by a membership should be kept")
+	}
+	writeDocument(t, doc)
+
+	// without the membership, the hidden group is removed
+	page.Annots = nil
+	if err := Sanitize(&doc, Policy{HiddenLayers: true}); err != nil {
+		t.Fatal(err)
+	}
+	props := doc.Catalog.OCProperties
+	if len(props.OCGs) != 1 || props.OCGs[0] != visible || len(props.D.OFF) != 0 || len(props.D.Order) != 1 {
+		t.Fatalf("unexpected optional content properties %v", props)
+	}
+	writeDocument(t, doc)
+}
+
+func TestHiddenLayersReferences(t *testing.T) {
+	hidden := &model.OptionalContentGroup{Name: "Draft"}
+	var doc model.Document
+	doc.Catalog.OCProperties = &model.OptionalContentProperties{
+		OCGs: []*model.OptionalContentGroup{hidden},
+		D:    model.OptionalContentConfig{OFF: []*model.OptionalContentGroup{hidden}},
+	}
+
+	hiddenImage := &model.XObjectImage{OC: hidden}
+	pattern := &model.PatternTiling{
+		ContentStream: model.ContentStream{Stream: model.Stream{Content: []byte("0 0 1 1 re f /Im Do")}},
+		Resources:     model.ResourcesDict{XObject: map[model.Name]model.XObject{"Im": hiddenImage}},
+	}
+	type3 := &model.FontDict{Subtype: model.FontType3{
+		Encoding:  model.WinAnsiEncoding,
+		CharProcs: map[model.Name]model.ContentStream{"a": {Stream: model.Stream{Content: []byte("0 0 d0 /Im Do")}}},
+		Resources: model.ResourcesDict{XObject: map[model.Name]model.XObject{"Im": hiddenImage}},
+	}}
+	page := &model.PageObject{
+		MediaBox: &model.Rectangle{Urx: 100, Ury: 100},
+		Resources: &model.ResourcesDict{
+			Pattern: map[model.Name]model.Pattern{"P": pattern},
+			Font:    map[model.Name]*model.FontDict{"T3": type3},
+		},
+		Contents: []model.ContentStream{{Stream: model.Stream{Content: []byte("/Pattern cs /P scn 0 0 10 10 re f")}}},
+	}
+	// hidden widgets are kept
+	widget := &model.AnnotationDict{BaseAnnotation: model.BaseAnnotation{OC: hidden}, Subtype: model.AnnotationWidget{}}
+	page.Annots = []*model.AnnotationDict{widget}
+	doc.Catalog.Pages.Kids = []model.PageNode{page}
+
+	if err := Sanitize(&doc, Policy{HiddenLayers: true}); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := pattern.Decode(); strings.Contains(string(content), "Do") || len(pattern.Resources.XObject) != 0 {
+		t.Fatalf("unexpected pattern content %s", content)
+	}
+	glyph := type3.Subtype.(model.FontType3).CharProcs["a"]
+	if content, _ := glyph.Decode(); strings.Contains(string(content), "Do") {
+		t.Fatalf("unexpected glyph content %s", content)
+	}
+	if len(page.Annots) != 1 {
+		t.Fatal("widget should be kept")
+	}
+	// the widget must stay hidden
+	if props := doc.Catalog.OCProperties; len(props.OCGs) != 1 || len(props.D.OFF) != 1 {
+		t.Fatalf("group used by a widget should be kept: %v", props)
+	}
+	writeDocument(t, doc)
+}
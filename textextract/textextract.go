@@ -0,0 +1,419 @@
+// Package textextract extracts the text shown in content streams,
+// as runs of glyphs sharing the same style (font, size, fill color and rise),
+// with their position on the page.
+//
+// The Unicode values of the glyphs are found using the ToUnicode entry
+// of the fonts, or their encoding (see fonts.Decoder). Unknown glyphs
+// are reported as U+FFFD.
+package textextract
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	cs "github.com/benoitkugler/pdf/contentstream"
+	"github.com/benoitkugler/pdf/fonts"
+	"github.com/benoitkugler/pdf/model"
+	"github.com/benoitkugler/pdf/reader/parser"
+)
+
+type Fl = model.Fl
+
+// maximum nesting of form XObjects
+const maxFormDepth = 20
+
+var identity = model.Matrix{1, 0, 0, 1, 0, 0}
+
+// decodeBuffers stores the buffers used to decode
+// the content streams, which are discarded once interpreted
+var decodeBuffers model.BufferPool
+
+// Color is a fill color.
+type Color struct {
+	// Space is the name of the color space: one of DeviceGray, DeviceRGB, DeviceCMYK, Pattern,
+	// or the name of a color space resource.
+	Space      model.Name
+	Components []Fl // may be empty for patterns
+}
+
+func (c Color) equal(other Color) bool {
+	if c.Space != other.Space || len(c.Components) != len(other.Components) {
+		return false
+	}
+	for i, v := range c.Components {
+		if other.Components[i] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Style describes the appearance of a run of text.
+type Style struct {
+	// Font is the PostScript name of the font (its BaseFont entry).
+	Font model.Name
+	// Size is the font size in default user space units, that is
+	// the font size scaled by the text and transformation matrices.
+	Size Fl
+	// Bold and Italic are deduced from the font descriptor flags
+	// and italic angle, and from the font name.
+	Bold, Italic bool
+	Fill         Color
+	// Rise is the text rise (Ts operator), in unscaled text space units.
+	// A positive value is typically used for superscripts.
+	Rise Fl
+}
+
+func (s Style) equal(other Style) bool {
+	return s.Font == other.Font && math.Abs(float64(s.Size-other.Size)) < 0.01 && s.Bold == other.Bold &&
+		s.Italic == other.Italic && s.Rise == other.Rise && s.Fill.equal(other.Fill)
+}
+
+// Run is a sequence of glyphs shown with the same style.
+type Run struct {
+	Text  string
+	Style Style
+	// X and Y are the coordinates of the origin of the first glyph,
+	// in default user space.
+	X, Y Fl
+	// Width is the length of the run along its baseline,
+	// in default user space units.
+	Width Fl
+
+	endX, endY Fl // origin of the next glyph
+}
+
+// approximate extents of the glyphs, relative to the font size,
+// used when the font metrics are not known
+const (
+	glyphAscent  = 0.8
+	glyphDescent = 0.2
+)
+
+// Bounds returns an approximation of the bounding box of the run,
+// in default user space, using the font size for the height of the glyphs.
+// Rotated runs are supported.
+func (r Run) Bounds() model.Rectangle {
+	dx, dy := Fl(1), Fl(0) // direction of the baseline
+	if length := Fl(math.Hypot(float64(r.endX-r.X), float64(r.endY-r.Y))); length != 0 {
+		dx, dy = (r.endX-r.X)/length, (r.endY-r.Y)/length
+	}
+	up, down := glyphAscent*r.Style.Size, glyphDescent*r.Style.Size
+	corners := [4][2]Fl{
+		{r.X - dy*up, r.Y + dx*up},
+		{r.X + dy*down, r.Y - dx*down},
+	}
+	for i := 0; i < 2; i++ {
+		corners[2+i] = [2]Fl{corners[i][0] + dx*r.Width, corners[i][1] + dy*r.Width}
+	}
+	out := model.Rectangle{Llx: corners[0][0], Lly: corners[0][1], Urx: corners[0][0], Ury: corners[0][1]}
+	for _, c := range corners[1:] {
+		out.Llx = Fl(math.Min(float64(out.Llx), float64(c[0])))
+		out.Urx = Fl(math.Max(float64(out.Urx), float64(c[0])))
+		out.Lly = Fl(math.Min(float64(out.Lly), float64(c[1])))
+		out.Ury = Fl(math.Max(float64(out.Ury), float64(c[1])))
+	}
+	return out
+}
+
+// Highlight adds to `page` a Highlight annotation covering the given runs,
+// typically returned by Page and filtered by the caller.
+// See model.NewHighlight for the other arguments.
+// It returns nil if `runs` is empty.
+func Highlight(page *model.PageObject, runs []Run, author string, color model.Color) *model.AnnotationDict {
+	areas := make([]model.Rectangle, len(runs))
+	for i, run := range runs {
+		areas[i] = run.Bounds()
+	}
+	return page.AddHighlight(areas, author, color)
+}
+
+// fontStyle returns the font name and the bold and italic flags of `font`
+func fontStyle(font *model.FontDict) (name model.Name, bold, italic bool) {
+	if font == nil {
+		return "", false, false
+	}
+	name = font.Subtype.FontName()
+	var desc *model.FontDescriptor
+	switch ft := font.Subtype.(type) {
+	case model.FontType1:
+		desc = &ft.FontDescriptor
+	case model.FontTrueType:
+		desc = &ft.FontDescriptor
+	case model.FontType3:
+		desc = ft.FontDescriptor
+	case model.FontType0:
+		desc = &ft.DescendantFonts.FontDescriptor
+	}
+	if desc != nil {
+		bold = desc.Flags&model.ForceBold != 0
+		italic = desc.Flags&model.Italic != 0 || desc.ItalicAngle != 0
+	}
+	lower := strings.ToLower(string(name))
+	bold = bold || strings.Contains(lower, "bold") || strings.Contains(lower, "black") || strings.Contains(lower, "heavy")
+	italic = italic || strings.Contains(lower, "italic") || strings.Contains(lower, "oblique")
+	return name, bold, italic
+}
+
+// font caches the information about a font dictionary
+type font struct {
+	decoder      fonts.Decoder
+	name         model.Name
+	bold, italic bool
+}
+
+// state is the part of the graphic state used to extract the text
+type state struct {
+	ctm model.Matrix
+
+	font                          *font
+	fontSize                      Fl
+	charSpace, wordSpace, leading Fl
+	scale                         Fl // horizontal scaling, as a fraction
+	rise                          Fl
+	fill                          Color
+}
+
+// text position, valid between BT and ET
+type textState struct {
+	tm, tlm model.Matrix
+}
+
+func (ts *textState) move(x, y Fl) {
+	ts.tlm = model.Matrix{1, 0, 0, 1, x, y}.Multiply(ts.tlm)
+	ts.tm = ts.tlm
+}
+
+type extractor struct {
+	fonts map[*model.FontDict]*font
+	runs  []Run
+	cache *model.StreamCache // optional, used to decode the forms
+}
+
+// Page returns the text runs of `page`, in content stream order.
+// `resources` are the resources used by the page (which may be inherited).
+// Consecutive text-showing operators with the same style are merged
+// when their glyphs are contiguous, inserting spaces for small gaps.
+func Page(page *model.PageObject, resources model.ResourcesDict) ([]Run, error) {
+	return extractPage(page, resources, nil)
+}
+
+func extractPage(page *model.PageObject, resources model.ResourcesDict, cache *model.StreamCache) ([]Run, error) {
+	content, err := page.DecodeAllContentsTo(decodeBuffers.Get())
+	if err != nil {
+		return nil, err
+	}
+	defer decodeBuffers.Put(content)
+	ex := extractor{fonts: make(map[*model.FontDict]*font), cache: cache}
+	if err = ex.processContent(content, resources, identity, 0); err != nil {
+		return nil, err
+	}
+	return ex.runs, nil
+}
+
+// Document returns the text runs of each page of `doc`.
+// The forms are decoded with the document cache, if any.
+func Document(doc *model.Document) ([][]Run, error) {
+	pages := doc.Catalog.Pages.Flatten()
+	inherited := doc.Catalog.Pages.FlattenInherit()
+	out := make([][]Run, len(pages))
+	for i, page := range pages {
+		var res model.ResourcesDict
+		if inherited[i].Resources != nil {
+			res = *inherited[i].Resources
+		}
+		runs, err := extractPage(page, res, doc.Cache)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %s", i, err)
+		}
+		out[i] = runs
+	}
+	return out, nil
+}
+
+func (ex *extractor) font(dict *model.FontDict) *font {
+	if f, ok := ex.fonts[dict]; ok {
+		return f
+	}
+	f := &font{decoder: fonts.NewDecoder(dict)}
+	f.name, f.bold, f.italic = fontStyle(dict)
+	ex.fonts[dict] = f
+	return f
+}
+
+func (ex *extractor) processContent(content []byte, res model.ResourcesDict, ctm model.Matrix, depth int) error {
+	ops, err := parser.ParseContent(content, res.ColorSpace)
+	if err != nil {
+		return err
+	}
+
+	st := state{ctm: ctm, scale: 1, font: ex.font(nil), fill: Color{Space: "DeviceGray", Components: []Fl{0}}}
+	var (
+		stack []state
+		text  textState
+	)
+	for _, op := range ops {
+		switch op := op.(type) {
+		case cs.OpSave:
+			stack = append(stack, st)
+		case cs.OpRestore:
+			if len(stack) != 0 {
+				st = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+		case cs.OpConcat:
+			st.ctm = op.Matrix.Multiply(st.ctm)
+		case cs.OpSetFillGray:
+			st.fill = Color{Space: "DeviceGray", Components: []Fl{op.G}}
+		case cs.OpSetFillRGBColor:
+			st.fill = Color{Space: "DeviceRGB", Components: []Fl{op.R, op.G, op.B}}
+		case cs.OpSetFillCMYKColor:
+			st.fill = Color{Space: "DeviceCMYK", Components: []Fl{op.C, op.M, op.Y, op.K}}
+		case cs.OpSetFillColorSpace:
+			st.fill = Color{Space: model.Name(op.ColorSpace)}
+		case cs.OpSetFillColor:
+			st.fill = Color{Space: st.fill.Space, Components: op.Color}
+		case cs.OpSetFillColorN:
+			st.fill = Color{Space: st.fill.Space, Components: op.Color}
+		case cs.OpSetFont:
+			st.font = ex.font(res.Font[op.Font])
+			st.fontSize = op.Size
+		case cs.OpSetCharSpacing:
+			st.charSpace = op.CharSpace
+		case cs.OpSetWordSpacing:
+			st.wordSpace = op.WordSpace
+		case cs.OpSetTextLeading:
+			st.leading = op.L
+		case cs.OpSetHorizScaling:
+			st.scale = op.Scale / 100
+		case cs.OpSetTextRise:
+			st.rise = op.Rise
+		case cs.OpBeginText:
+			text = textState{tm: identity, tlm: identity}
+		case cs.OpSetTextMatrix:
+			text = textState{tm: op.Matrix, tlm: op.Matrix}
+		case cs.OpTextMove:
+			text.move(op.X, op.Y)
+		case cs.OpTextMoveSet:
+			st.leading = -op.Y
+			text.move(op.X, op.Y)
+		case cs.OpTextNextLine:
+			text.move(0, -st.leading)
+		case cs.OpShowText:
+			ex.showText(st, &text, []fonts.TextSpaced{{CharCodes: []byte(op.Text)}})
+		case cs.OpShowSpaceText:
+			ex.showText(st, &text, op.Texts)
+		case cs.OpMoveShowText:
+			text.move(0, -st.leading)
+			ex.showText(st, &text, []fonts.TextSpaced{{CharCodes: []byte(op.Text)}})
+		case cs.OpMoveSetShowText:
+			st.wordSpace, st.charSpace = op.WordSpacing, op.CharacterSpacing
+			text.move(0, -st.leading)
+			ex.showText(st, &text, []fonts.TextSpaced{{CharCodes: []byte(op.Text)}})
+		case cs.OpXObject:
+			form, ok := res.XObject[op.XObject].(*model.XObjectForm)
+			if !ok {
+				continue
+			}
+			if depth >= maxFormDepth {
+				return fmt.Errorf("maximum form nesting depth (%d) exceeded", maxFormDepth)
+			}
+			var content []byte
+			if ex.cache != nil {
+				content, err = ex.cache.Decode(form.Stream)
+			} else {
+				content, err = form.DecodeTo(decodeBuffers.Get())
+			}
+			if err != nil {
+				return err
+			}
+			matrix := form.Matrix
+			if matrix == (model.Matrix{}) {
+				matrix = identity
+			}
+			err = ex.processContent(content, form.Resources, matrix.Multiply(st.ctm), depth+1)
+			if ex.cache == nil {
+				decodeBuffers.Put(content)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// showText appends the glyphs of `texts` to the runs,
+// and updates the text matrix.
+func (ex *extractor) showText(st state, text *textState, texts []fonts.TextSpaced) {
+	size := st.fontSize * st.scale
+	var (
+		b      strings.Builder
+		trm    = model.Matrix{st.scale, 0, 0, 1, 0, st.rise}.Multiply(text.tm.Multiply(st.ctm))
+		x, y   = trm.TransformPoint(0, 0)
+		effect = math.Hypot(float64(trm[2]), float64(trm[3])) // vertical scaling of the glyphs
+	)
+	for _, ts := range texts {
+		for _, glyph := range st.font.decoder.Decode(ts.CharCodes) {
+			if glyph.Text == nil {
+				b.WriteRune('\uFFFD')
+			} else {
+				b.WriteString(string(glyph.Text))
+			}
+			tx := glyph.Width*st.fontSize/1000 + st.charSpace
+			if len(glyph.Code) == 1 && glyph.Code[0] == ' ' {
+				tx += st.wordSpace
+			}
+			text.tm = model.Matrix{1, 0, 0, 1, tx * st.scale, 0}.Multiply(text.tm)
+		}
+		if ts.SpaceSubtractedAfter != 0 {
+			// large negative adjustments are typically used instead of spaces
+			if Fl(ts.SpaceSubtractedAfter) < -spaceThreshold*1000 && b.Len() != 0 && !strings.HasSuffix(b.String(), " ") {
+				b.WriteByte(' ')
+			}
+			text.tm = model.Matrix{1, 0, 0, 1, -Fl(ts.SpaceSubtractedAfter) / 1000 * size, 0}.Multiply(text.tm)
+		}
+	}
+	if b.Len() == 0 {
+		return
+	}
+
+	endTrm := model.Matrix{st.scale, 0, 0, 1, 0, st.rise}.Multiply(text.tm.Multiply(st.ctm))
+	endX, endY := endTrm.TransformPoint(0, 0)
+	run := Run{
+		Text: b.String(),
+		Style: Style{
+			Font: st.font.name, Size: Fl(math.Abs(float64(st.fontSize) * effect)),
+			Bold: st.font.bold, Italic: st.font.italic,
+			Fill: st.fill, Rise: st.rise,
+		},
+		X: x, Y: y, endX: endX, endY: endY,
+		Width: Fl(math.Hypot(float64(endX-x), float64(endY-y))),
+	}
+	ex.appendRun(run)
+}
+
+// spaceThreshold is the gap, relative to the font size,
+// above which a space is inserted between glyphs
+const spaceThreshold = 0.2
+
+// appendRun adds `run`, merging it with the previous one if possible
+func (ex *extractor) appendRun(run Run) {
+	if len(ex.runs) == 0 {
+		ex.runs = append(ex.runs, run)
+		return
+	}
+	last := &ex.runs[len(ex.runs)-1]
+	gap := Fl(math.Hypot(float64(run.X-last.endX), float64(run.Y-last.endY)))
+	if !last.Style.equal(run.Style) || gap > 2*spaceThreshold*run.Style.Size {
+		ex.runs = append(ex.runs, run)
+		return
+	}
+	if gap > spaceThreshold*run.Style.Size && !strings.HasSuffix(last.Text, " ") && !strings.HasPrefix(run.Text, " ") {
+		last.Text += " "
+	}
+	last.Text += run.Text
+	last.endX, last.endY = run.endX, run.endY
+	last.Width = Fl(math.Hypot(float64(last.endX-last.X), float64(last.endY-last.Y)))
+}
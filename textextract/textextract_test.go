@@ -0,0 +1,125 @@
+package textextract
+
+import (
+	"testing"
+
+	"github.com/benoitkugler/pdf/fonts/standardfonts"
+	"github.com/benoitkugler/pdf/model"
+)
+
+func newPage(content string, res model.ResourcesDict) *model.PageObject {
+	return &model.PageObject{
+		MediaBox:  &model.Rectangle{Urx: 600, Ury: 800},
+		Resources: &res,
+		Contents:  []model.ContentStream{{Stream: model.Stream{Content: []byte(content)}}},
+	}
+}
+
+func TestPage(t *testing.T) {
+	res := model.NewResourcesDict()
+	res.Font["F1"] = &model.FontDict{Subtype: standardfonts.Helvetica.WesternType1Font()}
+	res.Font["F2"] = &model.FontDict{Subtype: standardfonts.Helvetica_Bold.WesternType1Font()}
+	page := newPage(`q 1 0 0 rg BT /F2 24 Tf 100 700 Td (Title) Tj ET Q
+	BT /F1 12 Tf 100 650 Td (Some ) Tj (text ) Tj [(with)-300(spaces)] TJ 5 Ts (2) Tj ET
+	q 2 0 0 2 0 0 cm BT /F1 6 Tf 0 Ts 50 300 Td (Scaled) Tj ET Q`, res)
+
+	runs, err := Page(page, *page.Resources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 4 {
+		t.Fatalf("unexpected runs %v", runs)
+	}
+
+	title := runs[0]
+	if title.Text != "Title" || title.X != 100 || title.Y != 700 {
+		t.Fatalf("unexpected run %v", title)
+	}
+	if st := title.Style; st.Font != "Helvetica-Bold" || st.Size != 24 || !st.Bold || st.Italic ||
+		st.Fill.Space != "DeviceRGB" || len(st.Fill.Components) != 3 || st.Fill.Components[0] != 1 {
+		t.Fatalf("unexpected style %v", st)
+	}
+	if title.Width <= 0 {
+		t.Fatalf("unexpected width %v", title.Width)
+	}
+
+	body := runs[1]
+	if body.Text != "Some text with spaces" {
+		t.Fatalf("unexpected text %q", body.Text)
+	}
+	if st := body.Style; st.Font != "Helvetica" || st.Size != 12 || st.Bold || st.Fill.Space != "DeviceGray" {
+		t.Fatalf("unexpected style %v", st)
+	}
+	if sup := runs[2]; sup.Text != "2" || sup.Style.Rise != 5 || sup.Y != 655 {
+		t.Fatalf("unexpected superscript %v", sup)
+	}
+	if scaled := runs[3]; scaled.Text != "Scaled" || scaled.Style.Size != 12 || scaled.X != 100 || scaled.Y != 600 {
+		t.Fatalf("unexpected scaled run %v", scaled)
+	}
+}
+
+func TestForm(t *testing.T) {
+	res := model.NewResourcesDict()
+	formRes := model.NewResourcesDict()
+	formRes.Font["F1"] = &model.FontDict{Subtype: standardfonts.Times_Italic.WesternType1Font()}
+	res.XObject["Fm"] = &model.XObjectForm{
+		ContentStream: model.ContentStream{Stream: model.Stream{Content: []byte("BT /F1 10 Tf (In form) Tj ET")}},
+		Resources:     formRes,
+		Matrix:        model.Matrix{1, 0, 0, 1, 20, 30},
+	}
+	page := newPage("q 1 0 0 1 100 100 cm /Fm Do Q", res)
+
+	runs, err := Page(page, *page.Resources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].Text != "In form" || runs[0].X != 120 || runs[0].Y != 130 || !runs[0].Style.Italic {
+		t.Fatalf("unexpected runs %v", runs)
+	}
+
+	// the form is decoded with the document cache
+	doc := model.Document{Cache: &model.StreamCache{}}
+	doc.Catalog.Pages.Kids = []model.PageNode{page, page}
+	pages, err := Document(&doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 2 || len(pages[1]) != 1 || pages[1][0].Text != "In form" {
+		t.Fatalf("unexpected runs %v", pages)
+	}
+	if doc.Cache.Len() != 1 {
+		t.Fatalf("expected 1 cached stream, got %d", doc.Cache.Len())
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	res := model.NewResourcesDict()
+	res.Font["F1"] = &model.FontDict{Subtype: standardfonts.Helvetica.WesternType1Font()}
+	page := newPage(`BT /F1 10 Tf 100 700 Td (Horizontal) Tj ET
+	BT /F1 10 Tf -1 0 0 -1 300 100 Tm (Rotated) Tj ET`, res)
+
+	runs, err := Page(page, *page.Resources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 {
+		t.Fatalf("unexpected runs %v", runs)
+	}
+	if b := runs[0].Bounds(); b.Llx != 100 || b.Lly != 698 || b.Ury != 708 || b.Urx != 100+runs[0].Width {
+		t.Fatalf("unexpected bounds %v", b)
+	}
+	if b := runs[1].Bounds(); b.Llx != 300-runs[1].Width || b.Urx != 300 || b.Lly != 92 || b.Ury != 102 {
+		t.Fatalf("unexpected rotated bounds %v", b)
+	}
+
+	annot := Highlight(page, runs[:1], "Reviewer", nil)
+	if annot == nil || len(page.Annots) != 1 {
+		t.Fatal("missing annotation")
+	}
+	if quads := annot.Subtype.(model.AnnotationHighlight).QuadPoints; len(quads) != 8 || quads[1] != 708 || quads[5] != 698 {
+		t.Fatalf("unexpected QuadPoints %v", quads)
+	}
+	if Highlight(page, nil, "", nil) != nil {
+		t.Fatal("expected nil annotation")
+	}
+}
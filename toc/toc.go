@@ -0,0 +1,335 @@
+// Package toc renders the outline of a document as
+// table of contents pages, with dot leaders, page numbers
+// (using the page labels) and internal links.
+package toc
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	cs "github.com/benoitkugler/pdf/contentstream"
+	"github.com/benoitkugler/pdf/fonts"
+	"github.com/benoitkugler/pdf/model"
+)
+
+type Fl = model.Fl
+
+// A4 is the default page size
+var A4 = model.Rectangle{Urx: 595.28, Ury: 841.89}
+
+// Options configures the layout of the table of contents.
+type Options struct {
+	Font     fonts.BuiltFont // required
+	FontSize Fl              // optional, default to the document theme font size, or 12
+	Title    string          // optional, written on top of the first page
+
+	MediaBox model.Rectangle // optional, default to A4
+	Margin   Fl              // optional, default to 72 (one inch)
+	Indent   Fl              // optional, indentation for each outline level, default to 20
+
+	// Label is the page label used for the table of contents pages,
+	// when the document has page labels. It defaults to lower roman numerals.
+	// The labels of the other pages are preserved.
+	Label *model.PageLabel
+}
+
+func (opts *Options) setDefaults() {
+	if opts.FontSize == 0 {
+		opts.FontSize = 12
+	}
+	if opts.MediaBox == (model.Rectangle{}) {
+		opts.MediaBox = A4
+	}
+	if opts.Margin == 0 {
+		opts.Margin = 72
+	}
+	if opts.Indent == 0 {
+		opts.Indent = 20
+	}
+	if opts.Label == nil {
+		opts.Label = &model.PageLabel{S: "r", St: 1}
+	}
+}
+
+// entry is one line of the table of contents
+type entry struct {
+	title  string
+	level  int
+	target *model.PageObject // nil if the outline item has no internal destination
+}
+
+// Insert renders the outline of `doc` and inserts the resulting pages so that
+// the first one has index `index` (0-based). Use `doc.Catalog.Pages.Count()`
+// to append the pages at the end of the document.
+func Insert(doc *model.Document, index int, opts Options) error {
+	if opts.Font.Font == nil {
+		return errors.New("missing font for the table of contents")
+	}
+	count := doc.Catalog.Pages.Count()
+	if index < 0 || index > count {
+		return fmt.Errorf("invalid page index %d (for %d pages)", index, count)
+	}
+	if opts.FontSize == 0 && doc.Theme != nil {
+		opts.FontSize = doc.Theme.FontSize
+	}
+	opts.setDefaults()
+
+	var entries []entry
+	if doc.Catalog.Outlines != nil {
+		entries = collectEntries(doc.Catalog, doc.Catalog.Outlines.First, 0, nil)
+	}
+
+	lineHeight := opts.FontSize * 1.5
+	var titleHeight Fl
+	if opts.Title != "" {
+		titleHeight = 3 * lineHeight
+	}
+	available := opts.MediaBox.Height() - 2*opts.Margin
+	firstPageLines := int((available - titleHeight) / lineHeight)
+	linesPerPage := int(available / lineHeight)
+	if firstPageLines < 1 || linesPerPage < 1 {
+		return errors.New("page too small for the table of contents")
+	}
+
+	// split the entries into pages
+	pageEntries := [][]entry{entries}
+	if len(entries) > firstPageLines {
+		pageEntries = [][]entry{entries[:firstPageLines]}
+		for rest := entries[firstPageLines:]; len(rest) != 0; {
+			n := linesPerPage
+			if n > len(rest) {
+				n = len(rest)
+			}
+			pageEntries = append(pageEntries, rest[:n])
+			rest = rest[n:]
+		}
+	}
+
+	pages := make([]model.PageNode, len(pageEntries))
+	for i := range pages {
+		pages[i] = &model.PageObject{}
+	}
+	insertPages(&doc.Catalog.Pages, index, pages)
+	if doc.Catalog.PageLabels != nil {
+		doc.Catalog.PageLabels = insertLabels(*doc.Catalog.PageLabels, index, len(pages), count, *opts.Label)
+	}
+
+	// page numbers are computed once the pages are inserted
+	indices := map[*model.PageObject]int{}
+	for i, page := range doc.Catalog.Pages.Flatten() {
+		indices[page] = i
+	}
+	label := func(page *model.PageObject) string {
+		i, ok := indices[page]
+		if !ok {
+			return ""
+		}
+		if doc.Catalog.PageLabels != nil {
+			return doc.Catalog.PageLabels.Label(i)
+		}
+		return strconv.Itoa(i + 1)
+	}
+
+	for i, node := range pages {
+		title := ""
+		if i == 0 {
+			title = opts.Title
+		}
+		page := node.(*model.PageObject)
+		if err := renderPage(page, title, pageEntries[i], label, opts); err != nil {
+			return err
+		}
+		for _, annot := range page.Annots {
+			doc.ApplyTheme(annot) // links styling
+		}
+	}
+	return nil
+}
+
+// collectEntries walks the outline items (open or not), in depth-first order
+func collectEntries(catalog model.Catalog, item *model.OutlineItem, level int, out []entry) []entry {
+	for ; item != nil; item = item.Next {
+		dest := item.Dest
+		if goTo, ok := item.A.ActionType.(model.ActionGoTo); ok && dest == nil {
+			dest = goTo.D
+		}
+		out = append(out, entry{title: item.Title, level: level, target: resolveDestination(catalog, dest)})
+		out = collectEntries(catalog, item.First, level+1, out)
+	}
+	return out
+}
+
+// resolveDestination returns the target page of `dest`, or nil
+func resolveDestination(catalog model.Catalog, dest model.Destination) *model.PageObject {
+	switch dest := dest.(type) {
+	case model.DestinationExplicitIntern:
+		return dest.Page
+	case model.DestinationName:
+		if explicit, ok := catalog.Dests[model.Name(dest)].(model.DestinationExplicitIntern); ok {
+			return explicit.Page
+		}
+	case model.DestinationString:
+		if explicit, ok := catalog.Names.Dests.LookupTable()[dest].(model.DestinationExplicitIntern); ok {
+			return explicit.Page
+		}
+	}
+	return nil
+}
+
+// insertPages inserts `pages` so that the first one has index `index`,
+// and returns false if `index` is beyond the pages of `tree`.
+func insertPages(tree *model.PageTree, index int, pages []model.PageNode) bool {
+	count := 0
+	for i, kid := range tree.Kids {
+		switch kid := kid.(type) {
+		case *model.PageObject:
+			if count == index {
+				tree.Kids = append(tree.Kids[:i], append(pages, tree.Kids[i:]...)...)
+				return true
+			}
+			count++
+		case *model.PageTree:
+			c := kid.Count()
+			if index < count+c {
+				return insertPages(kid, index-count, pages)
+			}
+			count += c
+		}
+	}
+	if index == count {
+		tree.Kids = append(tree.Kids, pages...)
+		return true
+	}
+	return false
+}
+
+// insertLabels returns the page labels updated for `n` pages inserted at `index`
+// (in a document with `count` pages) and labeled with `label`,
+// preserving the labels of the other pages.
+func insertLabels(labels model.PageLabelsTree, index, n, count int, label model.PageLabel) *model.PageLabelsTree {
+	table := labels.LookupTable()
+	updated := map[int]model.PageLabel{index: label}
+	start, current := -1, model.PageLabel{S: "D", St: 1}
+	for num, l := range table {
+		if num >= index {
+			updated[num+n] = l
+		} else {
+			updated[num] = l
+			if num > start {
+				start, current = num, l
+			}
+		}
+	}
+	if _, has := table[index]; !has && index < count { // continue the interrupted range
+		if current.St < 1 {
+			current.St = 1
+		}
+		current.St += index - start
+		if start == -1 { // no range: decimal page numbers
+			current.St = index + 1
+		}
+		updated[index+n] = current
+	}
+
+	var out model.PageLabelsTree
+	for num, l := range updated {
+		out.Nums = append(out.Nums, model.NumToPageLabel{Num: num, PageLabel: l})
+	}
+	sort.Slice(out.Nums, func(i, j int) bool { return out.Nums[i].Num < out.Nums[j].Num })
+	return &out
+}
+
+// textWidth returns the width of `text` for the given font and size
+func textWidth(font fonts.BuiltFont, text string, size Fl) Fl {
+	return fonts.TextWidth(font, []rune(text), size)
+}
+
+// fitText truncates `text` (adding an ellipsis) so that its width is at most `width`
+func fitText(font fonts.BuiltFont, text string, size, width Fl) string {
+	if textWidth(font, text, size) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) != 0 {
+		runes = runes[:len(runes)-1]
+		if s := string(runes) + "..."; textWidth(font, s, size) <= width {
+			return s
+		}
+	}
+	return ""
+}
+
+func renderPage(page *model.PageObject, title string, entries []entry, label func(*model.PageObject) string, opts Options) error {
+	box := opts.MediaBox
+	left, right := box.Llx+opts.Margin, box.Urx-opts.Margin
+	lineHeight := opts.FontSize * 1.5
+	y := box.Ury - opts.Margin
+
+	gs := cs.NewGraphicStream(box)
+	gs.BeginText()
+	if title != "" {
+		titleSize := opts.FontSize * 1.5
+		gs.SetFontAndSize(opts.Font, titleSize)
+		y -= titleSize
+		gs.SetTextMatrix(1, 0, 0, 1, left, y)
+		if err := gs.ShowText(fitText(opts.Font, title, titleSize, right-left)); err != nil {
+			return err
+		}
+		y -= 3*lineHeight - titleSize
+	}
+
+	gs.SetFontAndSize(opts.Font, opts.FontSize)
+	dotWidth := textWidth(opts.Font, ".", opts.FontSize)
+	spaceWidth := textWidth(opts.Font, " ", opts.FontSize)
+	for _, entry := range entries {
+		y -= lineHeight
+		x := left + Fl(entry.level)*opts.Indent
+
+		number := ""
+		if entry.target != nil {
+			number = label(entry.target)
+		}
+		numberWidth := textWidth(opts.Font, number, opts.FontSize)
+		text := fitText(opts.Font, entry.title, opts.FontSize, right-x-numberWidth-4*dotWidth)
+		gs.SetTextMatrix(1, 0, 0, 1, x, y)
+		if err := gs.ShowText(text); err != nil {
+			return err
+		}
+		if number == "" {
+			continue
+		}
+
+		// dot leaders, then the right aligned page number
+		textEnd := x + textWidth(opts.Font, text, opts.FontSize) + spaceWidth
+		numberStart := right - numberWidth
+		if dotWidth > 0 {
+			if nbDots := int((numberStart - spaceWidth - textEnd) / dotWidth); nbDots > 0 {
+				gs.SetTextMatrix(1, 0, 0, 1, numberStart-spaceWidth-Fl(nbDots)*dotWidth, y)
+				if err := gs.ShowText(strings.Repeat(".", nbDots)); err != nil {
+					return err
+				}
+			}
+		}
+		gs.SetTextMatrix(1, 0, 0, 1, numberStart, y)
+		if err := gs.ShowText(number); err != nil {
+			return err
+		}
+
+		page.Annots = append(page.Annots, &model.AnnotationDict{
+			BaseAnnotation: model.BaseAnnotation{
+				Rect:   model.Rectangle{Llx: x, Lly: y - opts.FontSize*0.25, Urx: right, Ury: y + opts.FontSize},
+				Border: &model.Border{}, // no border
+			},
+			Subtype: model.AnnotationLink{
+				Dest: model.DestinationExplicitIntern{Page: entry.target, Location: model.DestinationLocationFit("Fit")},
+			},
+		})
+	}
+	gs.EndText()
+
+	gs.ApplyToPageObject(page, true)
+	return nil
+}
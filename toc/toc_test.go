@@ -0,0 +1,143 @@
+package toc
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/benoitkugler/pdf/fonts"
+	"github.com/benoitkugler/pdf/fonts/standardfonts"
+	"github.com/benoitkugler/pdf/model"
+	"github.com/benoitkugler/pdf/reader"
+)
+
+func sampleDocument() (model.Document, []*model.PageObject) {
+	var doc model.Document
+	pages := make([]*model.PageObject, 5)
+	for i := range pages {
+		pages[i] = &model.PageObject{MediaBox: &A4}
+	}
+	// use a nested page tree
+	doc.Catalog.Pages.Kids = []model.PageNode{
+		pages[0], pages[1],
+		&model.PageTree{Kids: []model.PageNode{pages[2], pages[3], pages[4]}},
+	}
+	doc.Catalog.PageLabels = &model.PageLabelsTree{Nums: []model.NumToPageLabel{
+		{Num: 0, PageLabel: model.PageLabel{S: "A", St: 1}},
+		{Num: 1, PageLabel: model.PageLabel{S: "D", St: 1}},
+	}}
+	doc.Catalog.Dests = map[model.Name]model.DestinationExplicit{
+		"chapter2": model.DestinationExplicitIntern{Page: pages[4], Location: model.DestinationLocationFit("Fit")},
+	}
+
+	outline := &model.Outline{}
+	chapter1 := &model.OutlineItem{Title: "Chapter 1", Parent: outline, Dest: model.DestinationExplicitIntern{Page: pages[1], Location: model.DestinationLocationFit("Fit")}}
+	section := &model.OutlineItem{Title: "Section 1.1", Parent: chapter1, A: model.Action{ActionType: model.ActionGoTo{D: model.DestinationExplicitIntern{Page: pages[3], Location: model.DestinationLocationFit("Fit")}}}}
+	chapter2 := &model.OutlineItem{Title: "Chapter 2", Parent: outline, Dest: model.DestinationName("chapter2")}
+	chapter1.First = section
+	chapter1.Next = chapter2
+	outline.First = chapter1
+	doc.Catalog.Outlines = outline
+	return doc, pages
+}
+
+func TestInsert(t *testing.T) {
+	doc, pages := sampleDocument()
+	labelsBefore := make([]string, len(pages))
+	for i := range pages {
+		labelsBefore[i] = doc.Catalog.PageLabels.Label(i)
+	}
+
+	font, err := fonts.BuildFont(&model.FontDict{Subtype: standardfonts.Helvetica.WesternType1Font()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Insert(&doc, 6, Options{Font: font}); err == nil {
+		t.Fatal("expected error for invalid index")
+	}
+	if err = Insert(&doc, 2, Options{Font: font, Title: "Contents"}); err != nil {
+		t.Fatal(err)
+	}
+
+	all := doc.Catalog.Pages.Flatten()
+	if len(all) != 6 || all[1] != pages[1] || all[3] != pages[2] {
+		t.Fatalf("unexpected pages %v", all)
+	}
+	for i := range pages {
+		index := i
+		if i >= 2 {
+			index++
+		}
+		if got := doc.Catalog.PageLabels.Label(index); got != labelsBefore[i] {
+			t.Errorf("page %d: label changed from %s to %s", i, labelsBefore[i], got)
+		}
+	}
+	if got := doc.Catalog.PageLabels.Label(2); got != "i" {
+		t.Errorf("unexpected table of contents label %s", got)
+	}
+
+	tocPage := all[2]
+	if len(tocPage.Annots) != 3 {
+		t.Fatalf("expected 3 links, got %d", len(tocPage.Annots))
+	}
+	for i, target := range []*model.PageObject{pages[1], pages[3], pages[4]} {
+		link := tocPage.Annots[i].Subtype.(model.AnnotationLink)
+		if link.Dest.(model.DestinationExplicitIntern).Page != target {
+			t.Errorf("link %d: unexpected target", i)
+		}
+	}
+	content, err := tocPage.Contents[0].Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"(Contents)", "(Chapter", "(Section", "(1)", "(3)", "(4)", "...."} {
+		if !strings.Contains(string(content), s) {
+			t.Errorf("missing %s in content %s", s, content)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err = doc.Write(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = reader.ParsePDFReader(bytes.NewReader(buf.Bytes()), reader.Options{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestManyEntries(t *testing.T) {
+	var doc model.Document
+	page := &model.PageObject{MediaBox: &A4}
+	doc.Catalog.Pages.Kids = []model.PageNode{page}
+	outline := &model.Outline{}
+	var last *model.OutlineItem
+	for i := 0; i < 100; i++ {
+		item := &model.OutlineItem{Title: strings.Repeat("Long title ", 20), Parent: outline, Dest: model.DestinationExplicitIntern{Page: page, Location: model.DestinationLocationFit("Fit")}}
+		if last == nil {
+			outline.First = item
+		} else {
+			last.Next = item
+		}
+		last = item
+	}
+	doc.Catalog.Outlines = outline
+
+	font, err := fonts.BuildFont(&model.FontDict{Subtype: standardfonts.Helvetica.WesternType1Font()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Insert(&doc, 1, Options{Font: font}); err != nil {
+		t.Fatal(err)
+	}
+	all := doc.Catalog.Pages.Flatten()
+	if len(all) != 4 { // 38 lines per page
+		t.Fatalf("unexpected number of pages %d", len(all))
+	}
+	nbLinks := 0
+	for _, p := range all[1:] {
+		nbLinks += len(p.Annots)
+	}
+	if nbLinks != 100 {
+		t.Fatalf("unexpected number of links %d", nbLinks)
+	}
+}
@@ -0,0 +1,300 @@
+// Package watermark provides a way to stamp text, images or
+// forms onto existing pages, for instance to add a watermark
+// or a "Confidential" mention.
+//
+// The stamp is built once, as a form XObject, and is then
+// referenced by each page, either above or below the existing content.
+package watermark
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+
+	cs "github.com/benoitkugler/pdf/contentstream"
+	"github.com/benoitkugler/pdf/fonts"
+	"github.com/benoitkugler/pdf/model"
+)
+
+type Fl = model.Fl
+
+// Position specifies where the stamp is placed on the page.
+// The stamp is aligned to the page box (the crop box, or the media box),
+// taking its rotation into account.
+type Position uint8
+
+const (
+	Center Position = iota
+	Top
+	Bottom
+	Left
+	Right
+	TopLeft
+	TopRight
+	BottomLeft
+	BottomRight
+)
+
+// alignment returns -1, 0 or 1 for each direction
+func (p Position) alignment() (h, v int) {
+	switch p {
+	case Top:
+		return 0, 1
+	case Bottom:
+		return 0, -1
+	case Left:
+		return -1, 0
+	case Right:
+		return 1, 0
+	case TopLeft:
+		return -1, 1
+	case TopRight:
+		return 1, 1
+	case BottomLeft:
+		return -1, -1
+	case BottomRight:
+		return 1, -1
+	default:
+		return 0, 0
+	}
+}
+
+// Options configures how the stamp is applied.
+type Options struct {
+	// Pages are the (0-based) indices of the pages to stamp.
+	// If nil, all the pages are stamped.
+	Pages []int
+
+	Position Position
+	// OffsetX and OffsetY are added to the position
+	// given by `Position`, in user space units.
+	OffsetX, OffsetY Fl
+
+	// Rotation is the (counter-clockwise) rotation of the stamp, in degrees.
+	// Note that the `Rotate` entry of the pages is not taken into account.
+	Rotation Fl
+	// Diagonal aligns the stamp with the diagonal of each page,
+	// from the bottom-left to the top-right corner, overriding Rotation.
+	Diagonal bool
+
+	// Opacity is the opacity of the stamp, between 0 and 1.
+	// The zero value means fully opaque.
+	Opacity Fl
+
+	// Below draws the stamp under the existing content,
+	// instead of above it.
+	Below bool
+
+	// Artifact, if not nil, marks the stamp as an artifact, so that it
+	// is ignored by assistive technologies. It should be used for tagged documents,
+	// for instance with {Type: "Pagination", Subtype: "Watermark"}.
+	Artifact *cs.Artifact
+}
+
+// StampText draws `text`, using `font`, with size `fontSize` and color `c`.
+// An error is returned if the options are invalid.
+func StampText(doc *model.Document, text string, font fonts.BuiltFont, fontSize Fl, c color.Color, opts Options) error {
+	width := fonts.TextWidth(font, []rune(text), fontSize)
+	desc := font.Desc()
+	ascent, descent := desc.Ascent*fontSize/1000, desc.Descent*fontSize/1000
+	if ascent <= 0 { // use a reasonable default
+		ascent, descent = fontSize, 0
+	}
+
+	gs := cs.NewGraphicStream(model.Rectangle{Urx: width, Ury: ascent - descent})
+	setOpacity(&gs, opts.Opacity)
+	gs.SetColorFill(c)
+	gs.BeginText()
+	gs.SetFontAndSize(font, fontSize)
+	gs.SetTextMatrix(1, 0, 0, 1, 0, -descent)
+	if err := gs.ShowText(text); err != nil {
+		return err
+	}
+	gs.EndText()
+
+	return stamp(doc, gs.ToXFormObject(true), opts)
+}
+
+// StampXObject draws the given image or form, scaled to `width` and `height`.
+// An error is returned if the options are invalid.
+func StampXObject(doc *model.Document, obj model.XObject, width, height Fl, opts Options) error {
+	gs := cs.NewGraphicStream(model.Rectangle{Urx: width, Ury: height})
+	setOpacity(&gs, opts.Opacity)
+	gs.SaveState()
+	switch obj := obj.(type) {
+	case *model.XObjectForm:
+		// map the bounding box of the form to the unit square
+		box := obj.BBox
+		if box.Width() == 0 || box.Height() == 0 {
+			return fmt.Errorf("invalid form bounding box %v", box)
+		}
+		sx, sy := width/box.Width(), height/box.Height()
+		gs.Transform(model.Matrix{sx, 0, 0, sy, -box.Llx * sx, -box.Lly * sy})
+	default: // images are drawn in the unit square
+		gs.Transform(model.Matrix{width, 0, 0, height, 0, 0})
+	}
+	gs.AddXObject(obj)
+	if err := gs.RestoreState(); err != nil {
+		return err
+	}
+
+	return stamp(doc, gs.ToXFormObject(true), opts)
+}
+
+func setOpacity(gs *cs.GraphicStream, opacity Fl) {
+	if opacity > 0 && opacity < 1 {
+		gs.SetFillAlpha(opacity)
+		gs.SetStrokeAlpha(opacity)
+	}
+}
+
+// page with its inherited attributes resolved
+type inheritedPage struct {
+	page      *model.PageObject
+	resources *model.ResourcesDict
+	mediaBox  *model.Rectangle
+}
+
+func flatten(tree *model.PageTree, resources *model.ResourcesDict, mediaBox *model.Rectangle) []inheritedPage {
+	if tree.Resources != nil {
+		resources = tree.Resources
+	}
+	if tree.MediaBox != nil {
+		mediaBox = tree.MediaBox
+	}
+	var out []inheritedPage
+	for _, kid := range tree.Kids {
+		switch kid := kid.(type) {
+		case *model.PageTree:
+			out = append(out, flatten(kid, resources, mediaBox)...)
+		case *model.PageObject:
+			page := inheritedPage{page: kid, resources: resources, mediaBox: mediaBox}
+			if kid.Resources != nil {
+				page.resources = kid.Resources
+			}
+			if kid.MediaBox != nil {
+				page.mediaBox = kid.MediaBox
+			}
+			out = append(out, page)
+		}
+	}
+	return out
+}
+
+// PageForm returns a form XObject with the content of the page at `index`,
+// its bounding box being the visible area of the page.
+// It may be used with StampXObject to overlay pages of another document,
+// such as a letterhead.
+func PageForm(doc *model.Document, index int) (*model.XObjectForm, error) {
+	pages := flatten(&doc.Catalog.Pages, nil, nil)
+	if index < 0 || index >= len(pages) {
+		return nil, fmt.Errorf("invalid page index %d (for %d pages)", index, len(pages))
+	}
+	page := pages[index]
+	var box model.Rectangle
+	if page.page.CropBox != nil {
+		box = *page.page.CropBox
+	} else if page.mediaBox != nil {
+		box = *page.mediaBox
+	} else {
+		return nil, fmt.Errorf("missing MediaBox for page %d", index)
+	}
+	content, err := page.page.DecodeAllContents()
+	if err != nil {
+		return nil, err
+	}
+	form := &model.XObjectForm{
+		ContentStream: model.ContentStream{Stream: model.Stream{Content: content}},
+		BBox:          box,
+	}
+	if page.resources != nil {
+		form.Resources = page.resources.ShallowCopy()
+	}
+	return form, nil
+}
+
+// stamp adds `form` to the selected pages
+func stamp(doc *model.Document, form *model.XObjectForm, opts Options) error {
+	pages := flatten(&doc.Catalog.Pages, nil, nil)
+	indices := opts.Pages
+	if indices == nil {
+		indices = make([]int, len(pages))
+		for i := range pages {
+			indices[i] = i
+		}
+	}
+	for _, index := range indices {
+		if index < 0 || index >= len(pages) {
+			return fmt.Errorf("invalid page index %d (for %d pages)", index, len(pages))
+		}
+	}
+
+	for _, index := range indices {
+		page := pages[index]
+		var box model.Rectangle
+		if page.page.CropBox != nil {
+			box = *page.page.CropBox
+		} else if page.mediaBox != nil {
+			box = *page.mediaBox
+		} else {
+			return fmt.Errorf("missing MediaBox for page %d", index)
+		}
+
+		var res model.ResourcesDict
+		if page.resources != nil {
+			res = *page.resources
+		}
+		name := res.UnusedName("Stamp")
+
+		ops := []cs.Operation{
+			cs.OpSave{},
+			cs.OpConcat{Matrix: placement(box, form.BBox, opts)},
+			cs.OpXObject{XObject: name},
+			cs.OpRestore{},
+		}
+		if opts.Artifact != nil {
+			ops = cs.ArtifactSequence(*opts.Artifact, ops...)
+		}
+		if opts.Below {
+			// resources may be shared between pages, so we always copy them
+			res = res.ShallowCopy()
+			res.XObject[name] = form
+			page.page.Resources = &res
+			content := model.ContentStream{Stream: model.Stream{Content: cs.WriteOperations(ops...)}}
+			page.page.Contents = append([]model.ContentStream{content}, page.page.Contents...)
+		} else {
+			// the inherited resources are copied by AppendContent
+			page.page.Resources = page.resources
+			stampRes := model.ResourcesDict{XObject: map[model.Name]model.XObject{name: form}}
+			if err := page.page.AppendContent(cs.WriteOperations(ops...), stampRes); err != nil {
+				return fmt.Errorf("page %d: %s", index, err)
+			}
+		}
+	}
+	return nil
+}
+
+// placement returns the matrix mapping the stamp bounding box to the page
+func placement(page, stamp model.Rectangle, opts Options) model.Matrix {
+	w, h := stamp.Width(), stamp.Height()
+	angle := float64(opts.Rotation) * math.Pi / 180
+	if opts.Diagonal {
+		angle = math.Atan2(float64(page.Height()), float64(page.Width()))
+	}
+	cos, sin := Fl(math.Cos(angle)), Fl(math.Sin(angle))
+	// dimensions of the rotated stamp
+	rw := Fl(math.Abs(float64(w*cos))) + Fl(math.Abs(float64(h*sin)))
+	rh := Fl(math.Abs(float64(w*sin))) + Fl(math.Abs(float64(h*cos)))
+
+	hAlign, vAlign := opts.Position.alignment()
+	cx := (page.Llx+page.Urx)/2 + Fl(hAlign)*(page.Width()-rw)/2 + opts.OffsetX
+	cy := (page.Lly+page.Ury)/2 + Fl(vAlign)*(page.Height()-rh)/2 + opts.OffsetY
+
+	// translate the center of the stamp to the origin, rotate, then translate to (cx, cy)
+	ox, oy := stamp.Llx+w/2, stamp.Lly+h/2
+	return model.Matrix{
+		cos, sin, -sin, cos,
+		cx - ox*cos + oy*sin,
+		cy - ox*sin - oy*cos,
+	}
+}
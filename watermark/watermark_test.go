@@ -0,0 +1,151 @@
+package watermark
+
+import (
+	"bytes"
+	"image/color"
+	"math"
+	"strings"
+	"testing"
+
+	cs "github.com/benoitkugler/pdf/contentstream"
+	"github.com/benoitkugler/pdf/fonts"
+	"github.com/benoitkugler/pdf/fonts/standardfonts"
+	"github.com/benoitkugler/pdf/model"
+	"github.com/benoitkugler/pdf/reader"
+)
+
+func newDocument() model.Document {
+	var doc model.Document
+	res := model.NewResourcesDict()
+	doc.Catalog.Pages.Resources = &res
+	doc.Catalog.Pages.MediaBox = &model.Rectangle{Urx: 600, Ury: 800}
+	for i := 0; i < 3; i++ {
+		page := &model.PageObject{
+			Contents: []model.ContentStream{{Stream: model.Stream{Content: []byte("0 0 10 10 re f")}}},
+		}
+		doc.Catalog.Pages.Kids = append(doc.Catalog.Pages.Kids, page)
+	}
+	return doc
+}
+
+func TestStampText(t *testing.T) {
+	doc := newDocument()
+	font, err := fonts.BuildFont(&model.FontDict{Subtype: standardfonts.Helvetica_Bold.WesternType1Font()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = StampText(&doc, "Confidential", font, 40, color.RGBA{R: 255, A: 255}, Options{Pages: []int{0, 2}, Rotation: 45, Opacity: 0.3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pages := doc.Catalog.Pages.Flatten()
+	if len(pages[1].Contents) != 1 || pages[1].Resources != nil {
+		t.Fatal("page 1 should not be modified")
+	}
+	for _, index := range []int{0, 2} {
+		page := pages[index]
+		if len(page.Contents) != 3 {
+			t.Fatalf("unexpected contents %v", page.Contents)
+		}
+		if _, ok := page.Resources.XObject["Stamp0"].(*model.XObjectForm); !ok {
+			t.Fatalf("missing stamp in %v", page.Resources.XObject)
+		}
+		if last := string(page.Contents[2].Content); !strings.Contains(last, "/Stamp0 Do") {
+			t.Fatalf("unexpected content %s", last)
+		}
+	}
+	if len(doc.Catalog.Pages.Resources.XObject) != 0 {
+		t.Fatal("inherited resources should not be modified")
+	}
+	form := pages[0].Resources.XObject["Stamp0"].(*model.XObjectForm)
+	if len(form.Resources.ExtGState) != 2 {
+		t.Fatalf("expected opacity states, got %v", form.Resources.ExtGState)
+	}
+
+	var buf bytes.Buffer
+	if err := doc.Write(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = reader.ParsePDFReader(bytes.NewReader(buf.Bytes()), reader.Options{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStampXObject(t *testing.T) {
+	doc := newDocument()
+	img := &model.XObjectImage{ColorSpace: model.ColorSpaceRGB}
+	img.Width, img.Height, img.BitsPerComponent = 1, 1, 8
+	img.Content = []byte{0, 0, 255}
+	err := StampXObject(&doc, img, 100, 50, Options{Position: BottomRight, Below: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, page := range doc.Catalog.Pages.Flatten() {
+		if len(page.Contents) != 2 || !strings.Contains(string(page.Contents[0].Content), "/Stamp0 Do") {
+			t.Fatalf("unexpected contents %v", page.Contents)
+		}
+	}
+
+	if err = StampXObject(&doc, img, 100, 50, Options{Pages: []int{3}}); err == nil {
+		t.Fatal("expected error for invalid page index")
+	}
+
+	artifact := &cs.Artifact{Type: "Pagination", Subtype: "Watermark"}
+	if err = StampXObject(&doc, img, 100, 50, Options{Pages: []int{0}, Below: true, Artifact: artifact}); err != nil {
+		t.Fatal(err)
+	}
+	content := strings.Join(strings.Fields(string(doc.Catalog.Pages.Flatten()[0].Contents[0].Content)), " ")
+	if !strings.HasPrefix(content, "/Artifact <<") || !strings.HasSuffix(content, "BDC q 1 0 0 1 250 375 cm /Stamp1 Do Q EMC") ||
+		!strings.Contains(content, "/Subtype /Watermark") {
+		t.Fatalf("unexpected content %s", content)
+	}
+}
+
+func TestPlacement(t *testing.T) {
+	page := model.Rectangle{Urx: 600, Ury: 800}
+	stamp := model.Rectangle{Urx: 100, Ury: 50}
+
+	apply := func(m model.Matrix, x, y Fl) (Fl, Fl) {
+		return m[0]*x + m[2]*y + m[4], m[1]*x + m[3]*y + m[5]
+	}
+	isClose := func(a, b Fl) bool { return math.Abs(float64(a-b)) < 1e-3 }
+
+	m := placement(page, stamp, Options{})
+	if x, y := apply(m, 50, 25); !isClose(x, 300) || !isClose(y, 400) {
+		t.Fatalf("unexpected center (%f, %f)", x, y)
+	}
+	m = placement(page, stamp, Options{Position: TopLeft, OffsetX: 10})
+	if x, y := apply(m, 0, 50); !isClose(x, 10) || !isClose(y, 800) {
+		t.Fatalf("unexpected corner (%f, %f)", x, y)
+	}
+	// once rotated, the stamp is 50 wide and 100 high
+	m = placement(page, stamp, Options{Position: BottomRight, Rotation: 90})
+	if x, y := apply(m, 0, 0); !isClose(x, 600) || !isClose(y, 0) {
+		t.Fatalf("unexpected corner (%f, %f)", x, y)
+	}
+	// the stamp direction follows the diagonal
+	m = placement(page, stamp, Options{Diagonal: true, Rotation: 20})
+	if !isClose(m[1]/m[0], 800./600) {
+		t.Fatalf("unexpected rotation %v", m)
+	}
+}
+
+func TestPageForm(t *testing.T) {
+	overlay := newDocument()
+	form, err := PageForm(&overlay, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if form.BBox != (model.Rectangle{Urx: 600, Ury: 800}) || string(form.Content) != "0 0 10 10 re f" {
+		t.Fatalf("unexpected form %v", form)
+	}
+	if _, err = PageForm(&overlay, 3); err == nil {
+		t.Fatal("expected error for invalid page index")
+	}
+
+	doc := newDocument()
+	if err = StampXObject(&doc, form, 600, 800, Options{Below: true}); err != nil {
+		t.Fatal(err)
+	}
+}